@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/helpers"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/middleware"
@@ -105,7 +109,13 @@ func restServer(_ *cobra.Command, _ []string) {
 		if config.AppBasePath != "" {
 			webhookPath = config.AppBasePath + webhookPath
 		}
-		app.Post(webhookPath, chatwootHandler.HandleWebhook)
+		webhookAuth := middleware.ChatwootWebhookAuth(config.ChatwootWebhookToken, config.ChatwootWebhookSecret)
+		app.Post(webhookPath, webhookAuth, chatwootHandler.HandleWebhook)
+		// Accounts of CHATWOOT_TARGETS post to /chatwoot/webhook/<target>
+		app.Post(webhookPath+"/:target", webhookAuth, chatwootHandler.HandleWebhook)
+		// Agent bot events are not signed, so only the token is checked, and it is required
+		botPath := config.AppBasePath + "/chatwoot/bot"
+		app.Post(botPath, middleware.ChatwootBotAuth(config.ChatwootWebhookToken), chatwootHandler.HandleBotWebhook)
 	}
 
 	if len(config.AppBasicAuthCredential) > 0 {
@@ -116,12 +126,19 @@ func restServer(_ *cobra.Command, _ []string) {
 	}
 
 	// Public health endpoint for load balancers/orchestrators.
+	// The ffmpeg version is left out since the endpoint is public.
 	app.Get("/healthz", func(c *fiber.Ctx) error {
+		ffmpeg := utils.DetectFFmpeg()
 		return c.JSON(fiber.Map{
 			"status":      "ok",
 			"service":     "go-whatsapp-web-multidevice",
 			"version":     config.AppVersion,
 			"server_time": time.Now().UTC().Format(time.RFC3339),
+			"ffmpeg": fiber.Map{
+				"available": ffmpeg.Available,
+				"opus":      ffmpeg.Opus,
+				"mp3":       ffmpeg.MP3,
+			},
 		})
 	})
 
@@ -174,6 +191,28 @@ func restServer(_ *cobra.Command, _ []string) {
 		rest.InitRestAuth(apiGroup.Group("", middleware.RequireScope("auth:manage")), apiKeyService)
 	}
 
+	// Webhook retry queue (dead letters and replay)
+	rest.InitRestWebhook(apiGroup.Group("", middleware.RequireScope("webhooks:manage")))
+
+	// Runtime diagnostics
+	rest.InitRestDebug(apiGroup.Group("", middleware.RequireScope("debug:read")))
+	rest.InitRestAdmin(apiGroup.Group("", middleware.RequireScope("debug:read")), chatStorageRepo)
+
+	// Chat storage retention
+	rest.InitRestAdminStorage(apiGroup.Group("", middleware.RequireScope("storage:manage")), chatStorageRepo)
+
+	// In-memory cache maintenance
+	rest.InitRestCache(apiGroup.Group("", middleware.RequireScope("cache:manage")))
+
+	// Maintenance mode (hold and later drain webhook/Chatwoot deliveries)
+	rest.InitRestMaintenance(apiGroup.Group("", middleware.RequireScope("maintenance:manage")))
+
+	// Prometheus metrics
+	rest.InitRestMetrics(apiGroup.Group("", middleware.RequireScope("metrics:read")))
+
+	// API metadata, readable by any authenticated caller
+	rest.InitRestMeta(apiGroup)
+
 	// Device-scoped operations (header-based)
 	headerDeviceGroup := apiGroup.Group("", middleware.DeviceMiddleware(dm))
 	registerDeviceScopedRoutes(headerDeviceGroup)
@@ -184,6 +223,31 @@ func restServer(_ *cobra.Command, _ []string) {
 		chatwootSyncGroup := apiGroup.Group("", middleware.RequireScope("chatwoot:sync"))
 		chatwootSyncGroup.Post("/chatwoot/sync", chatwootHandler.SyncHistory)
 		chatwootSyncGroup.Get("/chatwoot/sync/status", chatwootHandler.SyncStatus)
+		chatwootSyncGroup.Get("/chatwoot/sync/events", chatwootHandler.SyncEvents)
+		chatwootSyncGroup.Get("/chatwoot/sync/history", chatwootHandler.SyncRuns)
+		chatwootSyncGroup.Post("/chatwoot/sync/cancel", chatwootHandler.CancelSync)
+		chatwootSyncGroup.Post("/chatwoot/sync/chat", chatwootHandler.SyncChat)
+		chatwootSyncGroup.Post("/chatwoot/sync/retry-failed", chatwootHandler.RetryFailedSync)
+		chatwootSyncGroup.Post("/chatwoot/media/backfill", chatwootHandler.BackfillMedia)
+		chatwootSyncGroup.Get("/chatwoot/media/backfill/status", chatwootHandler.BackfillMediaStatus)
+		chatwootSyncGroup.Post("/chatwoot/cache/rebuild", chatwootHandler.RebuildConversationCache)
+		chatwootSyncGroup.Post("/chatwoot/messages/:wa_message_id/push", chatwootHandler.PushMessage)
+		chatwootSyncGroup.Get("/chatwoot/status-page", chatwootHandler.StatusPage)
+		chatwootSyncGroup.Get("/chatwoot/health", chatwootHandler.Health)
+		chatwootSyncGroup.Get("/chatwoot/auto-replies", chatwootHandler.ListAutoReplies)
+		chatwootSyncGroup.Post("/chatwoot/setup", chatwootHandler.SetupInbox)
+		chatwootSyncGroup.Post("/chatwoot/bot/setup", chatwootHandler.SetupAgentBot)
+		chatwootSyncGroup.Post("/chatwoot/contacts/link", chatwootHandler.LinkContacts)
+		chatwootSyncGroup.Post("/chatwoot/contacts/dedupe", chatwootHandler.DedupeContacts)
+		chatwootSyncGroup.Get("/chatwoot/audit", chatwootHandler.Audit)
+
+		chatwootConfigGroup := apiGroup.Group("", middleware.RequireScope("chatwoot:config"))
+		chatwootConfigGroup.Get("/chatwoot/config", chatwootHandler.GetConfig)
+		chatwootConfigGroup.Put("/chatwoot/config", chatwootHandler.UpdateConfig)
+
+		if config.ChatwootAutoSetup {
+			go setupChatwootInbox(dm)
+		}
 	}
 
 	apiGroup.Get("/", func(c *fiber.Ctx) error {
@@ -205,7 +269,48 @@ func restServer(_ *cobra.Command, _ []string) {
 	// Set auto reconnect checking with a guaranteed client instance
 	startAutoReconnectCheckerIfClientAvailable()
 
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		shutdownServer(app, <-quit)
+	}()
+
 	if err := app.Listen(config.AppHost + ":" + config.AppPort); err != nil {
 		logrus.Fatalln("Failed to start: ", err.Error())
 	}
+	<-stopped
+}
+
+// shutdownServer waits for the webhook and Chatwoot forwards still running, saving the rest for
+// replay, before it stops the HTTP server and disconnects the devices.
+func shutdownServer(app *fiber.App, sig os.Signal) {
+	logrus.Infof("Received %s, shutting down", sig)
+	whatsapp.DrainInflight(time.Duration(config.AppShutdownDrainTimeoutSec) * time.Second)
+
+	if err := app.ShutdownWithTimeout(5 * time.Second); err != nil {
+		logrus.Warnf("Failed to stop the HTTP server cleanly: %v", err)
+	}
+	if dm := whatsapp.GetDeviceManager(); dm != nil {
+		dm.DisconnectAll()
+	}
+}
+
+// setupChatwootInbox provisions the Chatwoot inbox of CHATWOOT_DEVICE_ID, or of the only device, at
+// startup when CHATWOOT_AUTO_SETUP is set.
+func setupChatwootInbox(dm *whatsapp.DeviceManager) {
+	_, deviceID, err := dm.ResolveDevice(config.ChatwootDeviceID)
+	if err != nil {
+		logrus.Warnf("Chatwoot: Skipping inbox setup, no device to set it up for: %v", err)
+		return
+	}
+	cwClient := chatwoot.GetDefaultClient()
+	if !cwClient.IsAccountConfigured() {
+		logrus.Warn("Chatwoot: Skipping inbox setup, CHATWOOT_URL, CHATWOOT_API_TOKEN and CHATWOOT_ACCOUNT_ID are required")
+		return
+	}
+	if _, err := cwClient.SetupInbox(chatStorageRepo, chatwoot.InboxSetupRequest{DeviceID: deviceID}); err != nil {
+		logrus.Errorf("Chatwoot: Inbox setup for device %s failed: %v", deviceID, err)
+	}
 }
@@ -23,6 +23,7 @@ import (
 	domainUser "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/user"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/apikey"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/usecase"
@@ -91,6 +92,9 @@ func initEnvConfig() {
 	if envDebug := viper.GetBool("app_debug"); envDebug {
 		config.AppDebug = envDebug
 	}
+	if envLogLevel := viper.GetString("app_log_level"); envLogLevel != "" {
+		config.AppLogLevel = envLogLevel
+	}
 	if envOs := viper.GetString("app_os"); envOs != "" {
 		config.AppOs = envOs
 	}
@@ -113,6 +117,24 @@ func initEnvConfig() {
 	if viper.IsSet("app_rate_limit_window_sec") {
 		config.AppRateLimitWindowSec = viper.GetInt("app_rate_limit_window_sec")
 	}
+	if viper.IsSet("app_lock_shards") {
+		config.AppLockShards = viper.GetInt("app_lock_shards")
+	}
+	if viper.IsSet("app_lock_wait_warn_sec") {
+		config.AppLockWaitWarnSec = viper.GetInt("app_lock_wait_warn_sec")
+	}
+	if viper.IsSet("app_shutdown_drain_timeout_sec") {
+		config.AppShutdownDrainTimeoutSec = viper.GetInt("app_shutdown_drain_timeout_sec")
+	}
+	if viper.IsSet("app_group_name_cache_size") {
+		config.AppGroupNameCacheSize = viper.GetInt("app_group_name_cache_size")
+	}
+	if viper.IsSet("app_maintenance_max_buffered") {
+		config.AppMaintenanceMaxBuffered = viper.GetInt("app_maintenance_max_buffered")
+	}
+	if viper.IsSet("app_maintenance_drain_interval_ms") {
+		config.AppMaintenanceDrainIntervalMs = viper.GetInt("app_maintenance_drain_interval_ms")
+	}
 	if envCorsOrigins := viper.GetString("app_cors_origins"); envCorsOrigins != "" {
 		origins := strings.Split(envCorsOrigins, ",")
 		config.AppCorsOrigins = origins
@@ -132,11 +154,38 @@ func initEnvConfig() {
 	if envDBKEYSURI := viper.GetString("db_keys_uri"); envDBKEYSURI != "" {
 		config.DBKeysURI = envDBKEYSURI
 	}
+	if viper.IsSet("chat_storage_retention_days") {
+		config.ChatStorageRetentionDays = viper.GetInt("chat_storage_retention_days")
+	}
+	if viper.IsSet("chat_storage_retention_keep_media") {
+		config.ChatStorageRetentionKeepMedia = viper.GetBool("chat_storage_retention_keep_media")
+	}
+	if viper.IsSet("chat_storage_dedup_retention_days") {
+		config.ChatStorageDedupRetentionDays = viper.GetInt("chat_storage_dedup_retention_days")
+	}
+	if viper.IsSet("chat_storage_search_max_results") {
+		config.ChatStorageSearchMaxResults = viper.GetInt("chat_storage_search_max_results")
+	}
+	if viper.IsSet("chat_storage_max_open_conns") {
+		config.ChatStorageMaxOpenConns = viper.GetInt("chat_storage_max_open_conns")
+	}
+	if viper.IsSet("chat_storage_max_idle_conns") {
+		config.ChatStorageMaxIdleConns = viper.GetInt("chat_storage_max_idle_conns")
+	}
+	if viper.IsSet("chat_storage_conn_max_lifetime_sec") {
+		config.ChatStorageConnMaxLifetimeSec = viper.GetInt("chat_storage_conn_max_lifetime_sec")
+	}
+	if viper.IsSet("chat_storage_busy_timeout_ms") {
+		config.ChatStorageBusyTimeoutMs = viper.GetInt("chat_storage_busy_timeout_ms")
+	}
 
 	// WhatsApp settings
 	if envAutoReply := viper.GetString("whatsapp_auto_reply"); envAutoReply != "" {
 		config.WhatsappAutoReplyMessage = envAutoReply
 	}
+	if viper.IsSet("whatsapp_auto_reply_cooldown") {
+		config.WhatsappAutoReplyCooldownSec = viper.GetInt("whatsapp_auto_reply_cooldown")
+	}
 	if viper.IsSet("whatsapp_auto_mark_read") {
 		config.WhatsappAutoMarkRead = viper.GetBool("whatsapp_auto_mark_read")
 	}
@@ -146,6 +195,12 @@ func initEnvConfig() {
 	if viper.IsSet("whatsapp_auto_download_status_media") {
 		config.WhatsappAutoDownloadStatusMedia = viper.GetBool("whatsapp_auto_download_status_media")
 	}
+	if viper.IsSet("whatsapp_forward_status") {
+		config.WhatsappForwardStatus = viper.GetBool("whatsapp_forward_status")
+	}
+	if viper.IsSet("whatsapp_skip_newsletters") {
+		config.WhatsappSkipNewsletters = viper.GetBool("whatsapp_skip_newsletters")
+	}
 	if viper.IsSet("whatsapp_history_sync_dump_enabled") {
 		config.WhatsappHistorySyncDumpEnabled = viper.GetBool("whatsapp_history_sync_dump_enabled")
 	}
@@ -163,8 +218,23 @@ func initEnvConfig() {
 		events := strings.Split(envWebhookEvents, ",")
 		config.WhatsappWebhookEvents = events
 	}
+	if viper.IsSet("whatsapp_webhook_retry_max_attempts") {
+		config.WhatsappWebhookRetryMaxAttempts = viper.GetInt("whatsapp_webhook_retry_max_attempts")
+	}
+	if viper.IsSet("whatsapp_forward_workers") {
+		config.WhatsappForwardWorkers = viper.GetInt("whatsapp_forward_workers")
+	}
+	if viper.IsSet("whatsapp_forward_queue_size") {
+		config.WhatsappForwardQueueSize = viper.GetInt("whatsapp_forward_queue_size")
+	}
+	if viper.IsSet("whatsapp_forward_queue_wait_ms") {
+		config.WhatsappForwardQueueWaitMs = viper.GetInt("whatsapp_forward_queue_wait_ms")
+	}
+	webhookTargets := whatsapp.ParseWebhookTargets(config.WhatsappWebhook)
+	whatsapp.SetWebhookTargets(webhookTargets)
+	config.WhatsappWebhook = whatsapp.WebhookTargetURLs(webhookTargets)
 	if len(config.WhatsappWebhook) > 0 && strings.TrimSpace(config.WhatsappWebhookSecret) == "" {
-		logrus.Fatalln("WHATSAPP_WEBHOOK_SECRET is required when WHATSAPP_WEBHOOK is configured")
+		logrus.Warn("WHATSAPP_WEBHOOK_SECRET is not set; webhook deliveries will be sent unsigned")
 	}
 	if config.WhatsappWebhookInsecureSkipVerify {
 		logrus.Warn("WHATSAPP_WEBHOOK_INSECURE_SKIP_VERIFY=true disables TLS verification; use only for development")
@@ -178,6 +248,14 @@ func initEnvConfig() {
 	if envPresenceOnConnect := viper.GetString("whatsapp_presence_on_connect"); envPresenceOnConnect != "" {
 		config.WhatsappPresenceOnConnect = envPresenceOnConnect
 	}
+	if viper.IsSet("whatsapp_phone_variant_countries") {
+		config.WhatsappPhoneVariantCountries = nil
+		for _, country := range strings.Split(viper.GetString("whatsapp_phone_variant_countries"), ",") {
+			if country = strings.TrimSpace(country); country != "" {
+				config.WhatsappPhoneVariantCountries = append(config.WhatsappPhoneVariantCountries, country)
+			}
+		}
+	}
 
 	// Chatwoot settings
 	if viper.IsSet("chatwoot_enabled") {
@@ -192,6 +270,9 @@ func initEnvConfig() {
 	if envChatwootWebhookToken := viper.GetString("chatwoot_webhook_token"); envChatwootWebhookToken != "" {
 		config.ChatwootWebhookToken = envChatwootWebhookToken
 	}
+	if envChatwootWebhookSecret := viper.GetString("chatwoot_webhook_secret"); envChatwootWebhookSecret != "" {
+		config.ChatwootWebhookSecret = envChatwootWebhookSecret
+	}
 	if viper.IsSet("chatwoot_account_id") {
 		config.ChatwootAccountID = viper.GetInt("chatwoot_account_id")
 	}
@@ -201,6 +282,132 @@ func initEnvConfig() {
 	if envChatwootDeviceID := viper.GetString("chatwoot_device_id"); envChatwootDeviceID != "" {
 		config.ChatwootDeviceID = envChatwootDeviceID
 	}
+	if viper.IsSet("chatwoot_device_label") {
+		config.ChatwootDeviceLabel = viper.GetBool("chatwoot_device_label")
+	}
+	if envInboxDeviceMap := viper.GetString("chatwoot_inbox_device_map"); envInboxDeviceMap != "" {
+		config.ChatwootInboxDeviceMap = strings.Split(envInboxDeviceMap, ",")
+	}
+	if envTargets := viper.GetString("chatwoot_targets"); envTargets != "" {
+		config.ChatwootTargets = strings.Split(envTargets, ",")
+	}
+	if envDeviceTargets := viper.GetString("chatwoot_device_targets"); envDeviceTargets != "" {
+		config.ChatwootDeviceTargets = strings.Split(envDeviceTargets, ",")
+	}
+	if viper.IsSet("chatwoot_config_locked") {
+		config.ChatwootConfigLocked = viper.GetBool("chatwoot_config_locked")
+	}
+	if viper.IsSet("chatwoot_csat_reply_window_hours") {
+		config.ChatwootCSATReplyWindowHours = viper.GetInt("chatwoot_csat_reply_window_hours")
+	}
+	if envDeliveryFailedLabel := viper.GetString("chatwoot_delivery_failed_label"); envDeliveryFailedLabel != "" {
+		config.ChatwootDeliveryFailedLabel = envDeliveryFailedLabel
+	}
+	if envRatingInboxes := viper.GetString("chatwoot_rating_prompt_inboxes"); envRatingInboxes != "" {
+		config.ChatwootRatingPromptInboxes = strings.Split(envRatingInboxes, ",")
+	}
+	if envRatingTemplate := viper.GetString("chatwoot_rating_prompt_template"); envRatingTemplate != "" {
+		config.ChatwootRatingPromptTemplate = strings.ReplaceAll(envRatingTemplate, `\n`, "\n")
+	}
+	if viper.IsSet("chatwoot_group_participant_notes") {
+		config.ChatwootGroupParticipantNotes = viper.GetBool("chatwoot_group_participant_notes")
+	}
+	if viper.IsSet("chatwoot_group_participant_contacts") {
+		config.ChatwootGroupParticipantContacts = viper.GetBool("chatwoot_group_participant_contacts")
+	}
+	if viper.IsSet("chatwoot_forward_status") {
+		config.ChatwootForwardStatus = viper.GetBool("chatwoot_forward_status")
+	}
+	if viper.IsSet("chatwoot_forward_api_sends") {
+		config.ChatwootForwardAPISends = viper.GetBool("chatwoot_forward_api_sends")
+	}
+	if envMapURL := viper.GetString("chatwoot_location_map_url"); envMapURL != "" {
+		config.ChatwootLocationMapURL = envMapURL
+	}
+	if viper.IsSet("chatwoot_error_body_limit") {
+		config.ChatwootErrorBodyLimit = viper.GetInt("chatwoot_error_body_limit")
+	}
+	if viper.IsSet("chatwoot_api_rate_limit") {
+		config.ChatwootAPIRateLimit = viper.GetFloat64("chatwoot_api_rate_limit")
+	}
+	if viper.IsSet("chatwoot_api_rate_burst") {
+		config.ChatwootAPIRateBurst = viper.GetInt("chatwoot_api_rate_burst")
+	}
+	if viper.IsSet("chatwoot_contact_search_max_pages") {
+		config.ChatwootContactSearchMaxPages = viper.GetInt("chatwoot_contact_search_max_pages")
+	}
+	if viper.IsSet("chatwoot_max_attachment_size") {
+		config.ChatwootMaxAttachmentSize = viper.GetInt64("chatwoot_max_attachment_size")
+	}
+	if envPollPrefix := viper.GetString("chatwoot_poll_prefix"); envPollPrefix != "" {
+		config.ChatwootPollPrefix = envPollPrefix
+	}
+	if viper.IsSet("chatwoot_expand_canned_responses") {
+		config.ChatwootExpandCannedResponses = viper.GetBool("chatwoot_expand_canned_responses")
+	}
+	if envViewOnce := viper.GetString("chatwoot_forward_view_once"); envViewOnce != "" {
+		config.ChatwootForwardViewOnce = envViewOnce
+	}
+	if envEphemeral := viper.GetString("chatwoot_forward_ephemeral"); envEphemeral != "" {
+		config.ChatwootForwardEphemeral = envEphemeral
+	}
+	if envLargeVideoMode := viper.GetString("chatwoot_large_video_mode"); envLargeVideoMode != "" {
+		config.ChatwootLargeVideoMode = envLargeVideoMode
+	}
+	if viper.IsSet("chatwoot_large_video_threshold") {
+		config.ChatwootLargeVideoThreshold = viper.GetInt64("chatwoot_large_video_threshold")
+	}
+	if viper.IsSet("chatwoot_large_video_retention_days") {
+		config.ChatwootLargeVideoRetentionDays = viper.GetInt("chatwoot_large_video_retention_days")
+	}
+	if envMediaLinkBaseURL := viper.GetString("chatwoot_media_link_base_url"); envMediaLinkBaseURL != "" {
+		config.ChatwootMediaLinkBaseURL = envMediaLinkBaseURL
+	}
+	if envMediaLinkTypes := viper.GetString("chatwoot_media_link_types"); envMediaLinkTypes != "" {
+		config.ChatwootMediaLinkTypes = strings.Split(envMediaLinkTypes, ",")
+	}
+	if viper.IsSet("chatwoot_media_link_threshold") {
+		config.ChatwootMediaLinkThreshold = viper.GetInt64("chatwoot_media_link_threshold")
+	}
+	if viper.IsSet("chatwoot_media_link_thumbnail") {
+		config.ChatwootMediaLinkThumbnail = viper.GetBool("chatwoot_media_link_thumbnail")
+	}
+	if envPublicURL := viper.GetString("chatwoot_public_url"); envPublicURL != "" {
+		config.ChatwootPublicURL = envPublicURL
+	}
+	if viper.IsSet("chatwoot_auto_setup") {
+		config.ChatwootAutoSetup = viper.GetBool("chatwoot_auto_setup")
+	}
+	if viper.IsSet("chatwoot_bot_enabled") {
+		config.ChatwootBotEnabled = viper.GetBool("chatwoot_bot_enabled")
+	}
+	if envBotName := viper.GetString("chatwoot_bot_name"); envBotName != "" {
+		config.ChatwootBotName = envBotName
+	}
+	if envBotRules := viper.GetString("chatwoot_bot_rules"); envBotRules != "" {
+		config.ChatwootBotRules = envBotRules
+	}
+	if viper.IsSet("chatwoot_bot_fallback_reply") {
+		config.ChatwootBotFallbackReply = strings.ReplaceAll(viper.GetString("chatwoot_bot_fallback_reply"), `\n`, "\n")
+	}
+	if envHandoffKeywords := viper.GetString("chatwoot_bot_handoff_keywords"); envHandoffKeywords != "" {
+		config.ChatwootBotHandoffKeywords = strings.Split(envHandoffKeywords, ",")
+	}
+	if viper.IsSet("chatwoot_bot_handoff_message") {
+		config.ChatwootBotHandoffMessage = strings.ReplaceAll(viper.GetString("chatwoot_bot_handoff_message"), `\n`, "\n")
+	}
+	if viper.IsSet("chatwoot_bot_handoff_team_id") {
+		config.ChatwootBotHandoffTeamID = viper.GetInt("chatwoot_bot_handoff_team_id")
+	}
+	if viper.IsSet("chatwoot_bot_max_unanswered") {
+		config.ChatwootBotMaxUnanswered = viper.GetInt("chatwoot_bot_max_unanswered")
+	}
+	if viper.IsSet("chatwoot_audit_retention_days") {
+		config.ChatwootAuditRetentionDays = viper.GetInt("chatwoot_audit_retention_days")
+	}
+	if viper.IsSet("chatwoot_temp_file_max_age_minutes") {
+		config.ChatwootTempFileMaxAgeMinutes = viper.GetInt("chatwoot_temp_file_max_age_minutes")
+	}
 	// Chatwoot History Sync settings
 	if viper.IsSet("chatwoot_import_messages") {
 		config.ChatwootImportMessages = viper.GetBool("chatwoot_import_messages")
@@ -229,10 +436,37 @@ func initEnvConfig() {
 	if viper.IsSet("chatwoot_sync_max_media_file_size") {
 		config.ChatwootSyncMaxMediaFileSize = viper.GetInt64("chatwoot_sync_max_media_file_size")
 	}
+	if viper.IsSet("chatwoot_sync_concurrency") {
+		config.ChatwootSyncConcurrency = viper.GetInt("chatwoot_sync_concurrency")
+	}
+	if envSyncTimezone := viper.GetString("chatwoot_sync_timezone"); envSyncTimezone != "" {
+		config.ChatwootSyncTimezone = envSyncTimezone
+	}
+	if envSyncTimeFormat := viper.GetString("chatwoot_sync_time_format"); envSyncTimeFormat != "" {
+		config.ChatwootSyncTimeFormat = envSyncTimeFormat
+	}
+	if envSyncTimestamp := viper.GetString("chatwoot_sync_timestamp"); envSyncTimestamp != "" {
+		config.ChatwootSyncTimestamp = envSyncTimestamp
+	}
+	if envMessageTemplates := viper.GetString("chatwoot_message_templates"); envMessageTemplates != "" {
+		config.ChatwootMessageTemplates = envMessageTemplates
+	}
+	if envMessageTemplatesFile := viper.GetString("chatwoot_message_templates_file"); envMessageTemplatesFile != "" {
+		config.ChatwootMessageTemplatesFile = envMessageTemplatesFile
+	}
+	if viper.IsSet("chatwoot_sync_max_concurrent_downloads") {
+		config.ChatwootSyncMaxConcurrentDownloads = viper.GetInt("chatwoot_sync_max_concurrent_downloads")
+	}
+	if viper.IsSet("chatwoot_failed_export_retention_days") {
+		config.ChatwootFailedExportRetentionDays = viper.GetInt("chatwoot_failed_export_retention_days")
+	}
 
 	if viper.IsSet("chatwoot_sync_avatar") {
 		config.ChatWootSyncAvatar = viper.GetBool("chatwoot_sync_avatar")
 	}
+	if viper.IsSet("chatwoot_avatar_check_interval_hours") {
+		config.ChatwootAvatarCheckIntervalHours = viper.GetInt("chatwoot_avatar_check_interval_hours")
+	}
 	if viper.IsSet("chatwoot_enable_typing_indicator") {
 		config.ChatWootEnableTypingIndicator = viper.GetBool("chatwoot_enable_typing_indicator")
 	}
@@ -260,6 +494,12 @@ func initFlags() {
 		config.AppDebug,
 		"hide or displaying log with --debug <true/false> | example: --debug=true",
 	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.AppLogLevel,
+		"log-level", "",
+		config.AppLogLevel,
+		`log level, overrides --debug; trace also logs full Chatwoot error bodies --log-level <string> | example: --log-level=trace`,
+	)
 	rootCmd.PersistentFlags().StringVarP(
 		&config.AppOs,
 		"os", "",
@@ -302,6 +542,48 @@ func initFlags() {
 		config.AppRateLimitWindowSec,
 		`rate limiter window in seconds --rate-limit-window-sec <int> | example: --rate-limit-window-sec=60`,
 	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.AppLockShards,
+		"lock-shards", "",
+		config.AppLockShards,
+		`number of shards per keyed lock set; raise it when many hot contacts contend --lock-shards <int> | example: --lock-shards=256`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.AppLockWaitWarnSec,
+		"lock-wait-warn-sec", "",
+		config.AppLockWaitWarnSec,
+		`warn with waiter and holder details when a lock wait exceeds this many seconds (0 = disabled) --lock-wait-warn-sec <int> | example: --lock-wait-warn-sec=10`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.AppShutdownDrainTimeoutSec,
+		"shutdown-drain-timeout-sec", "",
+		config.AppShutdownDrainTimeoutSec,
+		`on shutdown, wait this many seconds for running webhook and Chatwoot forwards before saving the rest for replay (0 = don't wait) --shutdown-drain-timeout-sec <int> | example: --shutdown-drain-timeout-sec=20`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.AppGroupNameCacheSize,
+		"group-name-cache-size", "",
+		config.AppGroupNameCacheSize,
+		`max group subjects cached in memory (LRU, 5 minute TTL) --group-name-cache-size <int> | example: --group-name-cache-size=5000`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.AppMaintenanceMaxBuffered,
+		"maintenance-max-buffered", "",
+		config.AppMaintenanceMaxBuffered,
+		`stop maintenance mode automatically once this many events are buffered (0 = no cap) --maintenance-max-buffered <int> | example: --maintenance-max-buffered=10000`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.AppMaintenanceDrainIntervalMs,
+		"maintenance-drain-interval-ms", "",
+		config.AppMaintenanceDrainIntervalMs,
+		`pause between buffered events when draining after maintenance --maintenance-drain-interval-ms <int> | example: --maintenance-drain-interval-ms=250`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.AppMigrateOnly,
+		"migrate-only", "",
+		config.AppMigrateOnly,
+		`apply the chat storage migrations and exit --migrate-only <true/false> | example: --migrate-only=true`,
+	)
 	rootCmd.PersistentFlags().StringSliceVarP(
 		&config.AppCorsOrigins,
 		"cors-origins", "",
@@ -334,6 +616,54 @@ func initFlags() {
 		config.DBKeysURI,
 		`the database uri to store the keys database uri (by default, we'll use the same database uri). database uri --db-keys-uri <string> | example: --db-keys-uri="file::memory:?cache=shared&_foreign_keys=on"`,
 	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatStorageRetentionDays,
+		"chat-storage-retention-days", "",
+		config.ChatStorageRetentionDays,
+		`days stored messages are kept, 0 keeps them forever --chat-storage-retention-days <int> | example: --chat-storage-retention-days=90`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatStorageRetentionKeepMedia,
+		"chat-storage-retention-keep-media", "",
+		config.ChatStorageRetentionKeepMedia,
+		`keep messages carrying media metadata past the retention window --chat-storage-retention-keep-media <true/false> | example: --chat-storage-retention-keep-media=true`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatStorageDedupRetentionDays,
+		"chat-storage-dedup-retention-days", "",
+		config.ChatStorageDedupRetentionDays,
+		`days Chatwoot dedup rows are kept, never fewer than the message retention --chat-storage-dedup-retention-days <int> | example: --chat-storage-dedup-retention-days=365`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatStorageSearchMaxResults,
+		"chat-storage-search-max-results", "",
+		config.ChatStorageSearchMaxResults,
+		`largest page of message search results --chat-storage-search-max-results <int> | example: --chat-storage-search-max-results=50`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatStorageMaxOpenConns,
+		"chat-storage-max-open-conns", "",
+		config.ChatStorageMaxOpenConns,
+		`most open connections to the chat storage database --chat-storage-max-open-conns <int> | example: --chat-storage-max-open-conns=50`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatStorageMaxIdleConns,
+		"chat-storage-max-idle-conns", "",
+		config.ChatStorageMaxIdleConns,
+		`most idle connections kept to the chat storage database --chat-storage-max-idle-conns <int> | example: --chat-storage-max-idle-conns=10`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatStorageConnMaxLifetimeSec,
+		"chat-storage-conn-max-lifetime-sec", "",
+		config.ChatStorageConnMaxLifetimeSec,
+		`seconds a chat storage connection is reused, 0 keeps it open --chat-storage-conn-max-lifetime-sec <int> | example: --chat-storage-conn-max-lifetime-sec=3600`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatStorageBusyTimeoutMs,
+		"chat-storage-busy-timeout-ms", "",
+		config.ChatStorageBusyTimeoutMs,
+		`milliseconds a query waits for a locked chat storage database --chat-storage-busy-timeout-ms <int> | example: --chat-storage-busy-timeout-ms=15000`,
+	)
 
 	// WhatsApp flags
 	rootCmd.PersistentFlags().StringVarP(
@@ -342,6 +672,12 @@ func initFlags() {
 		config.WhatsappAutoReplyMessage,
 		`auto reply when received message --autoreply <string> | example: --autoreply="Don't reply this message"`,
 	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.WhatsappAutoReplyCooldownSec,
+		"autoreply-cooldown", "",
+		config.WhatsappAutoReplyCooldownSec,
+		`minimum seconds between auto-replies to the same chat, 0 replies to every message --autoreply-cooldown <int> | example: --autoreply-cooldown=3600`,
+	)
 	rootCmd.PersistentFlags().BoolVarP(
 		&config.WhatsappAutoMarkRead,
 		"auto-mark-read", "",
@@ -360,6 +696,18 @@ func initFlags() {
 		config.WhatsappAutoDownloadStatusMedia,
 		`auto download status/story media from incoming events --auto-download-status-media <true/false> | example: --auto-download-status-media=false`,
 	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.WhatsappForwardStatus,
+		"forward-status", "",
+		config.WhatsappForwardStatus,
+		`forward contacts' statuses (stories) to webhooks as status.update events --forward-status <true/false> | example: --forward-status=true`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.WhatsappSkipNewsletters,
+		"skip-newsletters", "",
+		config.WhatsappSkipNewsletters,
+		`ignore messages from WhatsApp Channels: not stored, not forwarded --skip-newsletters <true/false> | example: --skip-newsletters=true`,
+	)
 	rootCmd.PersistentFlags().BoolVarP(
 		&config.WhatsappHistorySyncDumpEnabled,
 		"history-sync-dump-enabled", "",
@@ -370,7 +718,7 @@ func initFlags() {
 		&config.WhatsappWebhook,
 		"webhook", "w",
 		config.WhatsappWebhook,
-		`forward event to webhook --webhook <string> | example: --webhook="https://yourcallback.com/callback"`,
+		`forward event to webhook, optionally with per-URL events --webhook <string> | example: --webhook="https://yourcallback.com/callback" or --webhook="https://a.com/hook|message;https://b.com/hook|*"`,
 	)
 	rootCmd.PersistentFlags().StringVarP(
 		&config.WhatsappWebhookSecret,
@@ -390,6 +738,30 @@ func initFlags() {
 		config.WhatsappWebhookEvents,
 		`whitelist of events to forward to webhook (empty = all events) --webhook-events <string> | example: --webhook-events="message,message.ack,group.participants"`,
 	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.WhatsappWebhookRetryMaxAttempts,
+		"webhook-retry-max-attempts", "",
+		config.WhatsappWebhookRetryMaxAttempts,
+		`persistent retry attempts for failed webhook deliveries before they are dead-lettered (0 = disable) --webhook-retry-max-attempts <int> | example: --webhook-retry-max-attempts=10`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.WhatsappForwardWorkers,
+		"forward-workers", "",
+		config.WhatsappForwardWorkers,
+		`workers forwarding messages to webhooks and Chatwoot; the messages of a chat stay in order --forward-workers <int> | example: --forward-workers=8`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.WhatsappForwardQueueSize,
+		"forward-queue-size", "",
+		config.WhatsappForwardQueueSize,
+		`message forwards each worker holds waiting --forward-queue-size <int> | example: --forward-queue-size=200`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.WhatsappForwardQueueWaitMs,
+		"forward-queue-wait-ms", "",
+		config.WhatsappForwardQueueWaitMs,
+		`milliseconds to wait for room in a full forward queue before the forward is saved for replay (0 = save at once) --forward-queue-wait-ms <int> | example: --forward-queue-wait-ms=2000`,
+	)
 	rootCmd.PersistentFlags().BoolVarP(
 		&config.WhatsappAccountValidation,
 		"account-validation", "",
@@ -408,6 +780,12 @@ func initFlags() {
 		config.WhatsappPresenceOnConnect,
 		`presence to send on connect: "available", "unavailable", or "none" --presence-on-connect <string> | example: --presence-on-connect="unavailable"`,
 	)
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&config.WhatsappPhoneVariantCountries,
+		"phone-variant-countries", "",
+		config.WhatsappPhoneVariantCountries,
+		`country codes whose numbers are matched in both their forms, e.g. 55 for the Brazilian ninth digit (empty = none) --phone-variant-countries <string> | example: --phone-variant-countries="55"`,
+	)
 
 	// Chatwoot flags
 	rootCmd.PersistentFlags().BoolVarP(
@@ -422,12 +800,276 @@ func initFlags() {
 		config.ChatwootDeviceID,
 		`device ID for Chatwoot outbound messages --chatwoot-device-id <string> | example: --chatwoot-device-id="my-device"`,
 	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootDeviceLabel,
+		"chatwoot-device-label", "",
+		config.ChatwootDeviceLabel,
+		`label Chatwoot conversations with the device alias when several devices share one inbox --chatwoot-device-label <true/false> | example: --chatwoot-device-label=true`,
+	)
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&config.ChatwootInboxDeviceMap,
+		"chatwoot-inbox-device-map", "",
+		config.ChatwootInboxDeviceMap,
+		`route Chatwoot webhooks to a device by inbox ID --chatwoot-inbox-device-map <string> | example: --chatwoot-inbox-device-map="12:sales,34:support"`,
+	)
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&config.ChatwootTargets,
+		"chatwoot-targets", "",
+		config.ChatwootTargets,
+		`extra Chatwoot accounts devices can be routed to --chatwoot-targets <string> | example: --chatwoot-targets="sales=https://sales.example.com|token|1|4"`,
+	)
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&config.ChatwootDeviceTargets,
+		"chatwoot-device-targets", "",
+		config.ChatwootDeviceTargets,
+		`route devices to a Chatwoot target --chatwoot-device-targets <string> | example: --chatwoot-device-targets="sales-phone:sales,support-phone:support"`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootConfigLocked,
+		"chatwoot-config-locked", "",
+		config.ChatwootConfigLocked,
+		`use only the env Chatwoot settings and refuse PUT /chatwoot/config --chatwoot-config-locked <true/false> | example: --chatwoot-config-locked=true`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootCSATReplyWindowHours,
+		"chatwoot-csat-reply-window-hours", "",
+		config.ChatwootCSATReplyWindowHours,
+		`hours a bare 1-5 WhatsApp reply is submitted as the Chatwoot CSAT rating (0 disables) --chatwoot-csat-reply-window-hours <int> | example: --chatwoot-csat-reply-window-hours=48`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootAvatarCheckIntervalHours,
+		"chatwoot-avatar-check-interval-hours", "",
+		config.ChatwootAvatarCheckIntervalHours,
+		`hours before the WhatsApp avatar of a Chatwoot contact is checked again (0 checks on every message) --chatwoot-avatar-check-interval-hours <int> | example: --chatwoot-avatar-check-interval-hours=72`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootDeliveryFailedLabel,
+		"chatwoot-delivery-failed-label", "",
+		config.ChatwootDeliveryFailedLabel,
+		`Chatwoot label added to conversations whose reply could not be sent to WhatsApp (empty disables) --chatwoot-delivery-failed-label <string> | example: --chatwoot-delivery-failed-label="delivery-failed"`,
+	)
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&config.ChatwootRatingPromptInboxes,
+		"chatwoot-rating-prompt-inboxes", "",
+		config.ChatwootRatingPromptInboxes,
+		`Chatwoot inbox IDs whose resolved conversations get a rating prompt on WhatsApp (empty disables) --chatwoot-rating-prompt-inboxes <string> | example: --chatwoot-rating-prompt-inboxes="12,34"`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootRatingPromptTemplate,
+		"chatwoot-rating-prompt-template", "",
+		config.ChatwootRatingPromptTemplate,
+		`rating prompt sent when a conversation is resolved; {agent} and {link} are replaced --chatwoot-rating-prompt-template <string> | example: --chatwoot-rating-prompt-template="How did {agent} do? Reply 1-5"`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootGroupParticipantNotes,
+		"chatwoot-group-participant-notes", "",
+		config.ChatwootGroupParticipantNotes,
+		`post group participant changes as Chatwoot private notes --chatwoot-group-participant-notes <true/false> | example: --chatwoot-group-participant-notes=true`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootGroupParticipantContacts,
+		"chatwoot-group-participant-contacts", "",
+		config.ChatwootGroupParticipantContacts,
+		`give group senders their own Chatwoot contact and tag their messages with it --chatwoot-group-participant-contacts <true/false> | example: --chatwoot-group-participant-contacts=true`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootForwardStatus,
+		"chatwoot-forward-status", "",
+		config.ChatwootForwardStatus,
+		`post contacts' statuses (stories) to a whatsapp-status conversation per contact --chatwoot-forward-status <true/false> | example: --chatwoot-forward-status=true`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootForwardAPISends,
+		"chatwoot-forward-api-sends", "",
+		config.ChatwootForwardAPISends,
+		`post messages sent through the send API to their Chatwoot conversation --chatwoot-forward-api-sends <true/false> | example: --chatwoot-forward-api-sends=true`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootLocationMapURL,
+		"chatwoot-location-map-url", "",
+		config.ChatwootLocationMapURL,
+		`map link template for shared locations, {lat} and {lon} are replaced --chatwoot-location-map-url <string> | example: --chatwoot-location-map-url="https://www.openstreetmap.org/?mlat={lat}&mlon={lon}#map=17/{lat}/{lon}"`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootErrorBodyLimit,
+		"chatwoot-error-body-limit", "",
+		config.ChatwootErrorBodyLimit,
+		`max bytes of a Chatwoot response body quoted in errors, 0 = unlimited --chatwoot-error-body-limit <int> | example: --chatwoot-error-body-limit=4096`,
+	)
+	rootCmd.PersistentFlags().Float64VarP(
+		&config.ChatwootAPIRateLimit,
+		"chatwoot-api-rate-limit", "",
+		config.ChatwootAPIRateLimit,
+		`Chatwoot API requests per second, 0 = unlimited --chatwoot-api-rate-limit <float> | example: --chatwoot-api-rate-limit=5`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootAPIRateBurst,
+		"chatwoot-api-rate-burst", "",
+		config.ChatwootAPIRateBurst,
+		`Chatwoot API requests sent at once before the rate limit applies --chatwoot-api-rate-burst <int> | example: --chatwoot-api-rate-burst=10`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootContactSearchMaxPages,
+		"chatwoot-contact-search-max-pages", "",
+		config.ChatwootContactSearchMaxPages,
+		`pages of Chatwoot contact search results read when looking for a contact --chatwoot-contact-search-max-pages <int> | example: --chatwoot-contact-search-max-pages=10`,
+	)
+	rootCmd.PersistentFlags().Int64VarP(
+		&config.ChatwootMaxAttachmentSize,
+		"chatwoot-max-attachment-size", "",
+		config.ChatwootMaxAttachmentSize,
+		`max size (bytes) of a file uploaded to Chatwoot, larger ones are replaced by a note, 0 = unlimited --chatwoot-max-attachment-size <int> | example: --chatwoot-max-attachment-size=40000000`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootPollPrefix,
+		"chatwoot-poll-prefix", "",
+		config.ChatwootPollPrefix,
+		`prefix of agent messages sent as WhatsApp polls --chatwoot-poll-prefix <string> | example: --chatwoot-poll-prefix="!poll"`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootExpandCannedResponses,
+		"chatwoot-expand-canned-responses", "",
+		config.ChatwootExpandCannedResponses,
+		`replace a ::short_code starting an agent message by the Chatwoot canned response --chatwoot-expand-canned-responses <true/false> | example: --chatwoot-expand-canned-responses=true`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootForwardViewOnce,
+		"chatwoot-forward-view-once", "",
+		config.ChatwootForwardViewOnce,
+		`how view-once photos and videos are forwarded to Chatwoot: full, placeholder or blur --chatwoot-forward-view-once <string> | example: --chatwoot-forward-view-once=blur`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootForwardEphemeral,
+		"chatwoot-forward-ephemeral", "",
+		config.ChatwootForwardEphemeral,
+		`how messages of chats with disappearing messages reach Chatwoot: export, skip or redact --chatwoot-forward-ephemeral <string> | example: --chatwoot-forward-ephemeral=redact`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootLargeVideoMode,
+		"chatwoot-large-video-mode", "",
+		config.ChatwootLargeVideoMode,
+		`how videos above --chatwoot-large-video-threshold reach Chatwoot: full, thumbnail or link --chatwoot-large-video-mode <string> | example: --chatwoot-large-video-mode=thumbnail`,
+	)
+	rootCmd.PersistentFlags().Int64VarP(
+		&config.ChatwootLargeVideoThreshold,
+		"chatwoot-large-video-threshold", "",
+		config.ChatwootLargeVideoThreshold,
+		`size (bytes) above which videos follow --chatwoot-large-video-mode --chatwoot-large-video-threshold <int> | example: --chatwoot-large-video-threshold=16000000`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootLargeVideoRetentionDays,
+		"chatwoot-large-video-retention-days", "",
+		config.ChatwootLargeVideoRetentionDays,
+		`days the history sync keeps large videos it saved for linking, 0 keeps them --chatwoot-large-video-retention-days <int> | example: --chatwoot-large-video-retention-days=7`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootMediaLinkBaseURL,
+		"chatwoot-media-link-base-url", "",
+		config.ChatwootMediaLinkBaseURL,
+		`public URL of this server, used to link to saved media from Chatwoot --chatwoot-media-link-base-url <string> | example: --chatwoot-media-link-base-url="https://wa.example.com"`,
+	)
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&config.ChatwootMediaLinkTypes,
+		"chatwoot-media-link-types", "",
+		config.ChatwootMediaLinkTypes,
+		`media types posted to Chatwoot as a link to the saved file instead of uploaded (image, audio, video, document) --chatwoot-media-link-types <string> | example: --chatwoot-media-link-types="video,document"`,
+	)
+	rootCmd.PersistentFlags().Int64VarP(
+		&config.ChatwootMediaLinkThreshold,
+		"chatwoot-media-link-threshold", "",
+		config.ChatwootMediaLinkThreshold,
+		`size (bytes) above which media of --chatwoot-media-link-types is linked, 0 links every size --chatwoot-media-link-threshold <int> | example: --chatwoot-media-link-threshold=5000000`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootMediaLinkThumbnail,
+		"chatwoot-media-link-thumbnail", "",
+		config.ChatwootMediaLinkThumbnail,
+		`attach a small preview to images posted as links --chatwoot-media-link-thumbnail <true/false> | example: --chatwoot-media-link-thumbnail=false`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootPublicURL,
+		"chatwoot-public-url", "",
+		config.ChatwootPublicURL,
+		`public URL of this server that provisioned Chatwoot inboxes post webhooks to --chatwoot-public-url <string> | example: --chatwoot-public-url="https://wa.example.com"`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootAutoSetup,
+		"chatwoot-auto-setup", "",
+		config.ChatwootAutoSetup,
+		`provision the Chatwoot inbox of the device at startup --chatwoot-auto-setup <true/false> | example: --chatwoot-auto-setup=true`,
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&config.ChatwootBotEnabled,
+		"chatwoot-bot-enabled", "",
+		config.ChatwootBotEnabled,
+		`answer pending Chatwoot conversations as an agent bot until an agent takes over --chatwoot-bot-enabled <true/false> | example: --chatwoot-bot-enabled=true`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootBotName,
+		"chatwoot-bot-name", "",
+		config.ChatwootBotName,
+		`name of the Chatwoot agent bot registered by POST /chatwoot/bot/setup --chatwoot-bot-name <string> | example: --chatwoot-bot-name="Support Bot"`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootBotRules,
+		"chatwoot-bot-rules", "",
+		config.ChatwootBotRules,
+		`JSON object of agent bot replies keyed by comma-separated keywords --chatwoot-bot-rules <string> | example: --chatwoot-bot-rules='{"price,prices":"See https://example.com/prices"}'`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootBotFallbackReply,
+		"chatwoot-bot-fallback-reply", "",
+		config.ChatwootBotFallbackReply,
+		`agent bot reply to messages no rule answers (empty stays silent) --chatwoot-bot-fallback-reply <string> | example: --chatwoot-bot-fallback-reply="Type agent to talk to a person"`,
+	)
+	rootCmd.PersistentFlags().StringSliceVarP(
+		&config.ChatwootBotHandoffKeywords,
+		"chatwoot-bot-handoff-keywords", "",
+		config.ChatwootBotHandoffKeywords,
+		`words that make the agent bot hand the conversation to the agents --chatwoot-bot-handoff-keywords <string> | example: --chatwoot-bot-handoff-keywords="agent,human"`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootBotHandoffMessage,
+		"chatwoot-bot-handoff-message", "",
+		config.ChatwootBotHandoffMessage,
+		`message the agent bot sends when it hands a conversation over (empty sends nothing) --chatwoot-bot-handoff-message <string> | example: --chatwoot-bot-handoff-message="An agent will be with you shortly"`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootBotHandoffTeamID,
+		"chatwoot-bot-handoff-team-id", "",
+		config.ChatwootBotHandoffTeamID,
+		`Chatwoot team handed-off conversations are assigned to (0 assigns none) --chatwoot-bot-handoff-team-id <int> | example: --chatwoot-bot-handoff-team-id=2`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootBotMaxUnanswered,
+		"chatwoot-bot-max-unanswered", "",
+		config.ChatwootBotMaxUnanswered,
+		`messages in a row the agent bot has no reply for before it hands the conversation over (0 never) --chatwoot-bot-max-unanswered <int> | example: --chatwoot-bot-max-unanswered=2`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootAuditRetentionDays,
+		"chatwoot-audit-retention-days", "",
+		config.ChatwootAuditRetentionDays,
+		`days Chatwoot bridging decisions are kept in the audit log, 0 disables it --chatwoot-audit-retention-days <int> | example: --chatwoot-audit-retention-days=7`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootTempFileMaxAgeMinutes,
+		"chatwoot-temp-file-max-age-minutes", "",
+		config.ChatwootTempFileMaxAgeMinutes,
+		`minutes before orphaned Chatwoot temp files are swept, 0 disables the sweep --chatwoot-temp-file-max-age-minutes <int> | example: --chatwoot-temp-file-max-age-minutes=60`,
+	)
 	rootCmd.PersistentFlags().StringVarP(
 		&config.ChatwootWebhookToken,
 		"chatwoot-webhook-token", "",
 		config.ChatwootWebhookToken,
 		`optional shared token for /chatwoot/webhook (header X-Chatwoot-Token or query token) --chatwoot-webhook-token <string> | example: --chatwoot-webhook-token="cw-secret"`,
 	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootWebhookSecret,
+		"chatwoot-webhook-secret", "",
+		config.ChatwootWebhookSecret,
+		`optional HMAC-SHA256 secret verifying X-Chatwoot-Signature on /chatwoot/webhook --chatwoot-webhook-secret <string> | example: --chatwoot-webhook-secret="cw-hmac-secret"`,
+	)
 	rootCmd.PersistentFlags().BoolVarP(
 		&config.ChatwootImportMessages,
 		"chatwoot-import-messages", "",
@@ -482,6 +1124,54 @@ func initFlags() {
 		config.ChatwootSyncMaxMediaFileSize,
 		`max media file size (bytes) to download during Chatwoot sync (0 = unlimited) --chatwoot-sync-max-media-file-size <int> | example: --chatwoot-sync-max-media-file-size=20000000`,
 	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootSyncConcurrency,
+		"chatwoot-sync-concurrency", "",
+		config.ChatwootSyncConcurrency,
+		`chats synced at the same time during Chatwoot sync (max 16) --chatwoot-sync-concurrency <int> | example: --chatwoot-sync-concurrency=4`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootSyncTimezone,
+		"chatwoot-sync-timezone", "",
+		config.ChatwootSyncTimezone,
+		`IANA time zone of the times shown on synced messages (empty = server zone) --chatwoot-sync-timezone <string> | example: --chatwoot-sync-timezone=America/Sao_Paulo`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootSyncTimeFormat,
+		"chatwoot-sync-time-format", "",
+		config.ChatwootSyncTimeFormat,
+		`Go time layout of the times shown on synced messages --chatwoot-sync-time-format <string> | example: --chatwoot-sync-time-format="02/01/2006 15:04"`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootSyncTimestamp,
+		"chatwoot-sync-timestamp", "",
+		config.ChatwootSyncTimestamp,
+		`synced messages are backdated; prefix also starts them with their time, created_at only backdates them --chatwoot-sync-timestamp <string> | example: --chatwoot-sync-timestamp=created_at`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootMessageTemplates,
+		"chatwoot-message-templates", "",
+		config.ChatwootMessageTemplates,
+		`JSON object of Go text templates for the text the bridge writes into Chatwoot, by key --chatwoot-message-templates <string> | example: --chatwoot-message-templates='{"edited":"Edited: {{.Content}}"}'`,
+	)
+	rootCmd.PersistentFlags().StringVarP(
+		&config.ChatwootMessageTemplatesFile,
+		"chatwoot-message-templates-file", "",
+		config.ChatwootMessageTemplatesFile,
+		`JSON file of Chatwoot message templates --chatwoot-message-templates-file <string> | example: --chatwoot-message-templates-file=/app/templates.json`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootSyncMaxConcurrentDownloads,
+		"chatwoot-sync-max-concurrent-downloads", "",
+		config.ChatwootSyncMaxConcurrentDownloads,
+		`max media files downloaded at once during Chatwoot sync --chatwoot-sync-max-concurrent-downloads <int> | example: --chatwoot-sync-max-concurrent-downloads=2`,
+	)
+	rootCmd.PersistentFlags().IntVarP(
+		&config.ChatwootFailedExportRetentionDays,
+		"chatwoot-failed-export-retention-days", "",
+		config.ChatwootFailedExportRetentionDays,
+		`days messages the Chatwoot sync failed to export are kept for a retry --chatwoot-failed-export-retention-days <int> | example: --chatwoot-failed-export-retention-days=30`,
+	)
 }
 
 func initChatStorage() (*sql.DB, error) {
@@ -490,7 +1180,7 @@ func initChatStorage() (*sql.DB, error) {
 	if strings.Contains(connStr, "?") {
 		separator = "&"
 	}
-	connStr = fmt.Sprintf("%s%s_journal_mode=WAL", connStr, separator)
+	connStr = fmt.Sprintf("%s%s_journal_mode=WAL&_busy_timeout=%d", connStr, separator, config.ChatStorageBusyTimeoutMs)
 	if config.ChatStorageEnableForeignKeys {
 		connStr += "&_foreign_keys=on"
 	}
@@ -501,8 +1191,9 @@ func initChatStorage() (*sql.DB, error) {
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	db.SetMaxOpenConns(config.ChatStorageMaxOpenConns)
+	db.SetMaxIdleConns(config.ChatStorageMaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(config.ChatStorageConnMaxLifetimeSec) * time.Second)
 
 	// Test connection
 	if err := db.Ping(); err != nil {
@@ -518,6 +1209,23 @@ func initApp() {
 		config.WhatsappLogLevel = "DEBUG"
 		logrus.SetLevel(logrus.DebugLevel)
 	}
+	if config.AppLogLevel != "" {
+		level, err := logrus.ParseLevel(config.AppLogLevel)
+		if err != nil {
+			logrus.Warnf("Ignoring invalid log level %q: %v", config.AppLogLevel, err)
+		} else {
+			logrus.SetLevel(level)
+		}
+	}
+
+	// ffmpeg is looked up once; /healthz and the Chatwoot status page report the result
+	if ffmpeg := utils.DetectFFmpeg(); !ffmpeg.Available {
+		logrus.Warn("ffmpeg not found in PATH: voice notes (PTT), video thumbnails and audio conversion for Chatwoot are unavailable")
+	} else if !ffmpeg.Opus || !ffmpeg.MP3 {
+		logrus.Warnf("ffmpeg %s found without libopus (%v) or libmp3lame (%v): voice notes or Chatwoot audio conversion are unavailable", ffmpeg.Version, ffmpeg.Opus, ffmpeg.MP3)
+	} else {
+		logrus.Debugf("ffmpeg %s found with libopus and libmp3lame", ffmpeg.Version)
+	}
 
 	//preparing folder if not exist
 	err := utils.CreateFolder(config.PathQrCode, config.PathSendItems, config.PathStorages, config.PathMedia)
@@ -534,11 +1242,70 @@ func initApp() {
 	}
 
 	chatStorageRepo = chatstorage.NewStorageRepository(chatStorageDB)
-	chatStorageRepo.InitializeSchema()
+	if err := chatStorageRepo.InitializeSchema(); err != nil {
+		logrus.Fatalf("failed to migrate chat storage: %v", err)
+	}
+	whatsapp.SetWebhookOutboxRepository(chatStorageRepo)
+	whatsapp.StartWebhookOutboxDispatcher(ctx)
+	whatsapp.StartStoragePruner(ctx, chatStorageRepo)
+	whatsapp.SetReceiptRepository(chatStorageRepo)
+	whatsapp.SetMaintenanceBufferRepository(chatStorageRepo)
+	whatsapp.ResumeMaintenanceDrain()
+	chatwoot.SetCSATSurveyRepository(chatStorageRepo)
+	chatwoot.SetContactRepository(chatStorageRepo)
+	chatwoot.LoadStoredConfig(chatStorageRepo)
+	chatwoot.ApplyStoredInboxes(chatStorageRepo)
+	if _, err := chatwoot.CheckFingerprint(chatStorageRepo); err != nil {
+		logrus.Errorf("Chatwoot: %v", err)
+	}
+	chatwoot.MarkInterruptedSyncRuns(chatStorageRepo)
+	chatwoot.StartTempFileSweeper(ctx)
+	if !chatwoot.IsValidViewOnceMode(config.ChatwootForwardViewOnce) {
+		logrus.Warnf("Chatwoot: invalid CHATWOOT_FORWARD_VIEW_ONCE %q (expected full, placeholder or blur), using %s", config.ChatwootForwardViewOnce, chatwoot.ViewOncePlaceholder)
+	}
+	if !chatwoot.IsValidEphemeralMode(config.ChatwootForwardEphemeral) {
+		logrus.Warnf("Chatwoot: invalid CHATWOOT_FORWARD_EPHEMERAL %q (expected export, skip or redact), using %s", config.ChatwootForwardEphemeral, chatwoot.EphemeralExport)
+	}
+	if !chatwoot.IsValidSyncTimestampMode(config.ChatwootSyncTimestamp) {
+		logrus.Warnf("Chatwoot: invalid CHATWOOT_SYNC_TIMESTAMP %q (expected prefix or created_at), using %s", config.ChatwootSyncTimestamp, chatwoot.SyncTimestampPrefix)
+	}
+	if !chatwoot.IsValidSyncTimeFormat(config.ChatwootSyncTimeFormat) {
+		logrus.Warnf("Chatwoot: invalid CHATWOOT_SYNC_TIME_FORMAT %q (expected a Go time layout like %s), using %s", config.ChatwootSyncTimeFormat, chatwoot.DefaultSyncTimeFormat, chatwoot.DefaultSyncTimeFormat)
+	}
+	if err := chatwoot.CheckSyncTimezone(); err != nil {
+		logrus.Fatalf("Chatwoot: %v", err)
+	}
+	if err := chatwoot.CheckMessageTemplates(); err != nil {
+		logrus.Fatalf("Chatwoot: %v", err)
+	}
+	if err := chatwoot.CheckBotRules(); err != nil {
+		logrus.Fatalf("Chatwoot: %v", err)
+	}
+	if err := chatwoot.CheckBotAuth(); err != nil {
+		logrus.Fatalf("Chatwoot: %v", err)
+	}
+	if !chatwoot.IsValidLargeVideoMode(config.ChatwootLargeVideoMode) {
+		logrus.Warnf("Chatwoot: invalid CHATWOOT_LARGE_VIDEO_MODE %q (expected full, thumbnail or link), using %s", config.ChatwootLargeVideoMode, chatwoot.LargeVideoFull)
+	} else if chatwoot.LargeVideoMode() != chatwoot.LargeVideoFull && config.ChatwootMediaLinkBaseURL == "" {
+		logrus.Warnf("Chatwoot: CHATWOOT_LARGE_VIDEO_MODE=%s needs CHATWOOT_MEDIA_LINK_BASE_URL; large videos are uploaded in full", chatwoot.LargeVideoMode())
+	}
+	whatsapp.SetGroupInfoRepository(chatStorageRepo)
+	chatwoot.SetGroupNameResolver(whatsapp.ResolveGroupName)
+	chatwoot.SetSenderNameResolver(whatsapp.ResolveSenderName)
+	chatwoot.SetNewsletterNameResolver(whatsapp.ResolveNewsletterName)
+	chatwoot.SetDeviceAliasResolver(whatsapp.DeviceAliasForJID)
 	apiKeyService = apikey.NewService(chatStorageDB)
 	if err := apiKeyService.InitializeSchema(); err != nil {
 		logrus.Fatalf("failed to initialize api key schema: %v", err)
 	}
+	if config.AppMigrateOnly {
+		version, err := chatStorageRepo.GetSchemaVersion()
+		if err != nil {
+			logrus.Fatalf("failed to read chat storage schema version: %v", err)
+		}
+		logrus.Infof("Chat storage migrated to version %d of %d", version.Version, version.Latest)
+		os.Exit(0)
+	}
 
 	whatsappDB := whatsapp.InitWaDB(ctx, config.DBURI)
 	var keysDB *sqlstore.Container
@@ -9,6 +9,7 @@ var (
 	AppPort                = "3000"
 	AppHost                = "0.0.0.0"
 	AppDebug               = false
+	AppLogLevel            = "" // Overrides the level set by AppDebug (trace, debug, info, warn, error)
 	AppOs                  = "Chrome"
 	AppPlatform            = waCompanionReg.DeviceProps_PlatformType(1)
 	AppBasicAuthCredential []string
@@ -20,6 +21,17 @@ var (
 	AppRateLimitWindowSec  = 60
 	AppBasePath            = ""
 	AppTrustedProxies      []string // Trusted proxy IP ranges (e.g., "0.0.0.0/0" for all, or specific CIDRs)
+	AppLockShards          = 64     // Shards per keyed lock set (contact/avatar locks), read once at startup
+	AppLockWaitWarnSec     = 10     // Log a warning naming waiter and holder when a lock wait exceeds this (0 = disabled)
+
+	AppGroupNameCacheSize = 1000 // Max group subjects kept in memory; least recently used are evicted first
+
+	AppMaintenanceMaxBuffered     = 10000 // Maintenance mode ends on its own once this many events are buffered (0 = no cap)
+	AppMaintenanceDrainIntervalMs = 100   // Pause between buffered events when draining after maintenance
+
+	AppShutdownDrainTimeoutSec = 20 // On SIGINT/SIGTERM, wait this long for running webhook and Chatwoot forwards (0 = don't wait)
+
+	AppMigrateOnly = false // Apply the chat storage migrations and exit without starting a server
 
 	McpPort = "8080"
 	McpHost = "localhost"
@@ -33,14 +45,21 @@ var (
 	DBKeysURI = ""
 
 	WhatsappAutoReplyMessage          string
+	WhatsappAutoReplyCooldownSec      = 0     // Min seconds between auto-replies to the same chat (0 = reply to every message)
 	WhatsappAutoMarkRead              = false // Auto-mark incoming messages as read
 	WhatsappAutoDownloadMedia         = true  // Auto-download media from incoming messages
 	WhatsappAutoDownloadStatusMedia   = false // Auto-download status/story media from incoming events
+	WhatsappForwardStatus             = false // Forward contacts' statuses (stories) to webhooks as status.update events
+	WhatsappSkipNewsletters           = false // Ignore messages from WhatsApp Channels: not stored, not forwarded to webhooks or Chatwoot
 	WhatsappHistorySyncDumpEnabled    = false // Persist raw WhatsApp history sync payload to disk (can be large/sensitive)
 	WhatsappWebhook                   []string
 	WhatsappWebhookSecret             = ""
 	WhatsappWebhookInsecureSkipVerify = false          // Skip TLS certificate verification for webhooks (insecure)
 	WhatsappWebhookEvents             []string         // Whitelist of events to forward to webhook (empty = all events)
+	WhatsappWebhookRetryMaxAttempts            = 10    // Persistent retry attempts for failed webhook deliveries before dead-lettering (0 = disable the retry queue)
+	WhatsappForwardWorkers                     = 8     // Workers forwarding messages to webhooks and Chatwoot; a chat always uses the same worker
+	WhatsappForwardQueueSize                   = 200   // Message forwards each worker holds waiting
+	WhatsappForwardQueueWaitMs                 = 2000  // Wait for room in a full forward queue before saving the forward for replay (0 = save at once)
 	WhatsappAutoRejectCall                     = false // Auto-reject incoming calls
 	WhatsappLogLevel                           = "ERROR"
 	WhatsappSettingMaxImageSize       int64    = 20000000  // 20MB
@@ -53,29 +72,124 @@ var (
 	WhatsappAccountValidation                  = true
 	WhatsappPresenceOnConnect                  = "unavailable" // Presence to send on connect: "available", "unavailable", or "none"
 
+	// Country codes whose numbers WhatsApp knows in two forms, e.g. 55 for the Brazilian ninth digit
+	WhatsappPhoneVariantCountries = []string{"55"}
+
 	ChatStorageURI               = "file:storages/chatstorage.db"
 	ChatStorageEnableForeignKeys = true
 	ChatStorageEnableWAL         = true
 
-	ChatwootEnabled      = false
-	ChatwootURL          = ""
-	ChatwootAPIToken     = ""
-	ChatwootWebhookToken = "" // Optional token to secure /chatwoot/webhook (header X-Chatwoot-Token or query token)
-	ChatwootAccountID    = 0
-	ChatwootInboxID      = 0
-	ChatwootDeviceID     = "" // Device ID for outbound messages (required for multi-device)
+	// Retention of chat storage rows, enforced by a background pruner (0 days keeps everything)
+	ChatStorageRetentionDays      = 0
+	ChatStorageRetentionKeepMedia = false // Keep messages carrying media metadata past the retention window
+	ChatStorageDedupRetentionDays = 0     // Days Chatwoot dedup rows are kept, never fewer than ChatStorageRetentionDays
+
+	ChatStorageSearchMaxResults = 100 // Largest page GET /messages/search returns
+
+	// Connection pool of the chat storage database
+	ChatStorageMaxOpenConns       = 25
+	ChatStorageMaxIdleConns       = 5
+	ChatStorageConnMaxLifetimeSec = 0    // 0 keeps connections open
+	ChatStorageBusyTimeoutMs      = 5000 // How long a query waits for a locked database before failing
+
+	ChatwootEnabled                 = false
+	ChatwootURL                     = ""
+	ChatwootAPIToken                = ""
+	ChatwootWebhookToken            = "" // Optional token to secure /chatwoot/webhook (header X-Chatwoot-Token or query token)
+	ChatwootWebhookSecret           = "" // Optional HMAC-SHA256 secret verifying X-Chatwoot-Signature on /chatwoot/webhook
+	ChatwootAccountID               = 0
+	ChatwootInboxID                 = 0
+	ChatwootDeviceID                = ""    // Device ID for outbound messages (required for multi-device)
+	ChatwootDeviceLabel             = false // Label conversations with the device alias when several devices share the inbox
+	ChatwootInboxDeviceMap []string         // Inbox to device routing for the Chatwoot webhook (format: inboxID:deviceID)
+
+	// Extra Chatwoot accounts and the devices routed to them; unrouted devices use ChatwootURL
+	ChatwootTargets       []string // Named Chatwoot targets (format: name=url|token|accountID|inboxID)
+	ChatwootDeviceTargets []string // Device to target routing (format: deviceID:target)
+
+	ChatwootConfigLocked = false // Keep the CHATWOOT_* env settings: ignore and refuse changes from PUT /chatwoot/config
 
 	ChatWootSyncAvatar            = false // Sync WhatsApp profile picture to Chatwoot contacts
 	ChatWootEnableTypingIndicator = false // Enable typing indicators in Chatwoot based on WhatsApp activity
 
+	ChatwootAvatarCheckIntervalHours = 24 // Hours before the avatar of a contact is checked again on a new message (0 = every message)
+
+	ChatwootCSATReplyWindowHours     = 24    // Hours a bare 1-5 reply is taken as the answer to a CSAT survey (0 = disabled)
+	ChatwootGroupParticipantNotes    = false // Post group join/leave/promote/demote as private notes on the group conversation
+	ChatwootGroupParticipantContacts = false // Give each group sender a contact of their own and tag their messages with it
+	ChatwootForwardStatus            = false // Post contacts' statuses (stories) to a whatsapp-status conversation per contact
+	ChatwootForwardAPISends          = false // Post messages sent through the send API to their Chatwoot conversation as outgoing messages
+
+	ChatwootDeliveryFailedLabel = "" // Label added to conversations whose reply could not be sent to WhatsApp (empty = disabled)
+
+	ChatwootRatingPromptInboxes []string // Inbox IDs whose resolved conversations get a rating prompt (empty = disabled)
+	// Rating prompt text; {agent} and {link} are replaced
+	ChatwootRatingPromptTemplate = "How would you rate your conversation with {agent}? Reply with a number from 1 (poor) to 5 (excellent).\n{link}"
+
+	ChatwootLocationMapURL = "https://www.google.com/maps/search/?api=1&query={lat},{lon}" // Map link for shared locations; {lat} and {lon} are replaced
+
+	ChatwootErrorBodyLimit = 1024 // Max bytes of a Chatwoot response body quoted in errors and logs (0 = unlimited)
+
+	ChatwootAPIRateLimit float64 = 20 // Chatwoot API requests per second across all callers (0 = unlimited)
+	ChatwootAPIRateBurst         = 40 // Requests that may be sent at once before ChatwootAPIRateLimit applies
+
+	ChatwootContactSearchMaxPages = 5 // Pages of Chatwoot contact search read when looking for a WhatsApp contact
+
+	ChatwootMaxAttachmentSize int64 = 40000000 // Attachments above this size (bytes) are not uploaded to Chatwoot (0 = unlimited)
+
+	ChatwootPollPrefix = "/poll" // Agent messages starting with this are sent as WhatsApp polls: "/poll Question | A | B"
+
+	ChatwootExpandCannedResponses = false // Replace a "::short_code" starting an agent message by the account's canned response
+
+	ChatwootForwardViewOnce = "placeholder" // How view-once media reaches Chatwoot: "full", "placeholder" or "blur"
+
+	ChatwootForwardEphemeral = "export" // How messages of chats with disappearing messages reach Chatwoot: "export", "skip" or "redact"
+
+	ChatwootLargeVideoMode                = "full"   // How videos above ChatwootLargeVideoThreshold reach Chatwoot: "full", "thumbnail" or "link"
+	ChatwootLargeVideoThreshold     int64 = 16000000 // Videos above this size (bytes) follow ChatwootLargeVideoMode
+	ChatwootLargeVideoRetentionDays       = 30       // Days the history sync keeps large videos it saved for linking (0 keeps them)
+	ChatwootMediaLinkBaseURL              = ""       // Public URL of this server, used to link to saved media, e.g. "https://wa.example.com"
+
+	// Saved media posted to Chatwoot as a link to this server instead of uploaded again
+	ChatwootMediaLinkTypes     []string        // Media types linked: "image", "audio", "video" or "document" (empty = upload everything)
+	ChatwootMediaLinkThreshold int64    = 0    // Only files above this size (bytes) are linked (0 = every size)
+	ChatwootMediaLinkThumbnail          = true // Attach a small preview to linked images
+
+	ChatwootPublicURL = ""    // Public URL of this server that provisioned inboxes post webhooks to (defaults to ChatwootMediaLinkBaseURL)
+	ChatwootAutoSetup = false // Provision the Chatwoot inbox of the device at startup, like POST /chatwoot/setup
+
+	// Chatwoot agent bot answering pending conversations until an agent takes over
+	ChatwootBotEnabled         = false                                                       // Answer the events posted to /chatwoot/bot
+	ChatwootBotName            = "WhatsApp Bot"                                              // Name of the agent bot POST /chatwoot/bot/setup registers
+	ChatwootBotRules           = ""                                                          // JSON object of replies keyed by comma-separated keywords
+	ChatwootBotFallbackReply   = ""                                                          // Reply to messages no rule answers (empty = stay silent)
+	ChatwootBotHandoffKeywords = []string{"agente", "atendente", "humano", "agent", "human"} // Words that hand the conversation to the agents
+	ChatwootBotHandoffMessage  = "Transferring you to an agent, please wait."                // Sent to the customer on handoff (empty = nothing)
+	ChatwootBotHandoffTeamID   = 0                                                           // Team handed-off conversations are assigned to (0 = none)
+	ChatwootBotMaxUnanswered   = 3                                                           // Messages in a row no rule answers before a handoff (0 = never)
+
+	ChatwootAuditRetentionDays = 7 // Days bridging decisions are kept for GET /chatwoot/audit (0 disables the audit log)
+
+	ChatwootTempFileMaxAgeMinutes = 60 // Orphaned chatwoot-* temp files older than this are swept (0 disables the sweep)
+
 	// Chatwoot History Sync settings
-	ChatwootImportMessages                = false    // Enable message history import to Chatwoot
-	ChatwootDaysLimitImportMessages       = 3        // Days of history to import (default: 3)
-	ChatwootSyncIncludeMedia              = true     // Download media attachments during sync
-	ChatwootSyncIncludeGroups             = true     // Include group chats during sync
-	ChatwootSyncIncludeStatus             = false    // Include status/story chat in Chatwoot sync
-	ChatwootSyncMaxMessagesPerChat        = 500      // Max messages to sync per chat
-	ChatwootSyncBatchSize                 = 10       // Number of messages per batch before delay
-	ChatwootSyncDelayMs                   = 500      // Delay between batches in milliseconds
-	ChatwootSyncMaxMediaFileSize    int64 = 20000000 // Max media size to download during sync (20MB, 0 = unlimited)
+	ChatwootImportMessages                   = false    // Enable message history import to Chatwoot
+	ChatwootDaysLimitImportMessages          = 3        // Days of history to import (default: 3)
+	ChatwootSyncIncludeMedia                 = true     // Download media attachments during sync
+	ChatwootSyncIncludeGroups                = true     // Include group chats during sync
+	ChatwootSyncIncludeStatus                = false    // Include status/story chat in Chatwoot sync
+	ChatwootSyncMaxMessagesPerChat           = 500      // Max messages to sync per chat
+	ChatwootSyncBatchSize                    = 10       // Number of messages per batch before delay
+	ChatwootSyncDelayMs                      = 500      // Delay between batches in milliseconds
+	ChatwootSyncMaxMediaFileSize       int64 = 20000000 // Max media size to download during sync (20MB, 0 = unlimited)
+	ChatwootSyncMaxConcurrentDownloads       = 2        // Max media files downloaded at once across all syncs
+	ChatwootSyncConcurrency                  = 4        // Chats synced at the same time (max 16)
+	ChatwootFailedExportRetentionDays        = 30       // Days failed sync exports are kept for a retry
+
+	ChatwootSyncTimezone   = ""                 // IANA zone of the times synced messages show, e.g. "America/Sao_Paulo" (empty = server zone)
+	ChatwootSyncTimeFormat = "2006-01-02 15:04" // Go time layout of the times synced messages show
+	ChatwootSyncTimestamp  = "prefix"           // Synced messages are backdated; "prefix" also starts them with their time, "created_at" does not
+
+	ChatwootMessageTemplates     = "" // JSON object of Go text templates replacing the text the bridge writes into Chatwoot, by key
+	ChatwootMessageTemplatesFile = "" // JSON file of such templates; CHATWOOT_MESSAGE_TEMPLATES wins on a key set in both
 )
@@ -1,5 +1,7 @@
 package chat
 
+import "io"
+
 // Request and Response structures for chat operations
 
 type ListChatsRequest struct {
@@ -31,6 +33,57 @@ type GetChatMessagesResponse struct {
 	ChatInfo   ChatInfo           `json:"chat_info"`
 }
 
+type SearchMessagesRequest struct {
+	Query   string  `json:"q" query:"q"`
+	ChatJID string  `json:"chat_jid" query:"chat_jid"`
+	From    *string `json:"from" query:"from"`
+	To      *string `json:"to" query:"to"`
+	Limit   int     `json:"limit" query:"limit"`
+	Cursor  string  `json:"cursor" query:"cursor"`
+}
+
+type SearchMessagesResponse struct {
+	Data       []MessageSearchHit `json:"data"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type MessageSearchHit struct {
+	ID        string `json:"id"`
+	ChatJID   string `json:"chat_jid"`
+	SenderJID string `json:"sender_jid"`
+	Timestamp string `json:"timestamp"`
+	MediaType string `json:"media_type"`
+	Snippet   string `json:"snippet"`
+
+	Receipt *MessageReceiptInfo `json:"receipt,omitempty"` // Only for messages we sent that got a receipt
+}
+
+// MessageReceiptInfo is the delivery and read state of a sent message. In groups the counts are
+// per participant.
+type MessageReceiptInfo struct {
+	Summary     string `json:"summary"` // e.g. "read by 2 of 3"
+	Recipients  int    `json:"recipients"`
+	Delivered   int    `json:"delivered"`
+	Read        int    `json:"read"`
+	DeliveredAt string `json:"delivered_at,omitempty"`
+	ReadAt      string `json:"read_at,omitempty"`
+}
+
+type ExportChatRequest struct {
+	ChatJID string  `json:"chat_jid" uri:"chat_jid"`
+	Format  string  `json:"format" query:"format"` // json, csv or html
+	Media   string  `json:"media" query:"media"`   // zip bundles the transcript with the media files
+	From    *string `json:"from" query:"from"`
+	To      *string `json:"to" query:"to"`
+}
+
+// ExportChatResponse describes a transcript that Write streams once the request was validated.
+type ExportChatResponse struct {
+	Filename    string
+	ContentType string
+	Write       func(w io.Writer) error
+}
+
 // Pin Chat operations
 type PinChatRequest struct {
 	ChatJID string `json:"chat_jid" uri:"chat_jid"`
@@ -49,6 +102,7 @@ type ChatInfo struct {
 	Name                string `json:"name"`
 	LastMessageTime     string `json:"last_message_time"`
 	EphemeralExpiration uint32 `json:"ephemeral_expiration"`
+	Type                string `json:"type"` // user, group, newsletter or broadcast
 	CreatedAt           string `json:"created_at"`
 	UpdatedAt           string `json:"updated_at"`
 }
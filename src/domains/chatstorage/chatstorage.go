@@ -3,9 +3,13 @@ package chatstorage
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 )
 
+// PostgresRepository holds Postgres versions of the Chatwoot export queries. Nothing constructs it:
+// chat storage runs on SQLite through infrastructure/chatstorage.SQLiteRepository, which implements
+// all of IChatStorageRepository, so no Postgres server is needed.
 type PostgresRepository struct {
 	DB *sql.DB
 }
@@ -74,11 +78,33 @@ func (r *PostgresRepository) MarkMessageExported(deviceID, chatJID, messageKey s
 		INSERT INTO chatwoot_exported_messages (device_id, chat_jid, message_key, chatwoot_message_id, created_at)
 		VALUES ($1, $2, $3, $4, NOW())
 		ON CONFLICT (device_id, chat_jid, message_key)
-		DO NOTHING
+		DO UPDATE SET chatwoot_message_id = EXCLUDED.chatwoot_message_id
 	`, deviceID, chatJID, messageKey, chatwootMessageID)
 	return err
 }
 
+func (r *PostgresRepository) GetExportedChatwootMessageID(deviceID, chatJID, messageKey string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	row := r.DB.QueryRowContext(ctx, `
+		SELECT chatwoot_message_id
+		FROM chatwoot_exported_messages
+		WHERE device_id = $1 AND chat_jid = $2 AND message_key = $3
+		LIMIT 1
+	`, deviceID, chatJID, messageKey)
+
+	var id int
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
 func (r *PostgresRepository) IsChatwootMessageFromUs(chatwootMessageID int) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -108,10 +134,33 @@ type Chat struct {
 	Name                string    `db:"name"`
 	LastMessageTime     time.Time `db:"last_message_time"`
 	EphemeralExpiration uint32    `db:"ephemeral_expiration"`
+	Type                string    `db:"chat_type"` // One of the ChatType constants; StoreChat fills it in from the JID
 	CreatedAt           time.Time `db:"created_at"`
 	UpdatedAt           time.Time `db:"updated_at"`
 }
 
+// Chat types, as stored in chats.chat_type
+const (
+	ChatTypeUser       = "user"
+	ChatTypeGroup      = "group"
+	ChatTypeNewsletter = "newsletter"
+	ChatTypeBroadcast  = "broadcast"
+)
+
+// ChatTypeOf returns the chat type of a chat JID.
+func ChatTypeOf(jid string) string {
+	switch {
+	case strings.HasSuffix(jid, "@g.us"):
+		return ChatTypeGroup
+	case strings.HasSuffix(jid, "@newsletter"):
+		return ChatTypeNewsletter
+	case strings.HasSuffix(jid, "@broadcast"):
+		return ChatTypeBroadcast
+	default:
+		return ChatTypeUser
+	}
+}
+
 // Message represents a WhatsApp message
 type Message struct {
 	ID            string    `db:"id"`
@@ -128,6 +177,7 @@ type Message struct {
 	FileSHA256    []byte    `db:"file_sha256"`
 	FileEncSHA256 []byte    `db:"file_enc_sha256"`
 	FileLength    uint64    `db:"file_length"`
+	IsViewOnce    bool      `db:"is_view_once"`
 	CreatedAt     time.Time `db:"created_at"`
 	UpdatedAt     time.Time `db:"updated_at"`
 }
@@ -164,6 +214,35 @@ type MessageFilter struct {
 	EndTime   *time.Time
 	MediaOnly bool
 	IsFromMe  *bool
+
+	OldestFirst bool // Return messages in chronological order instead of newest first
+}
+
+// MessageSearchFilter represents a full-text search over the messages of a device
+type MessageSearchFilter struct {
+	DeviceID string
+	Query    string // Words that must all appear, matched regardless of case and accents
+	ChatJID  string // Optional: search one chat only
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Cursor   string // NextCursor of the previous page
+}
+
+// MessageSearchHit is a message matched by a full-text search
+type MessageSearchHit struct {
+	ID        string
+	ChatJID   string
+	Sender    string
+	Timestamp time.Time
+	MediaType string
+	Snippet   string // Matched words wrapped in ** ... **
+}
+
+// MessageSearchPage is one page of full-text search results, newest first
+type MessageSearchPage struct {
+	Hits       []*MessageSearchHit
+	NextCursor string // Empty on the last page
 }
 
 // ChatFilter represents query filters for chats
@@ -15,6 +15,233 @@ type ChatExportState struct {
 	UpdatedAt      time.Time
 }
 
+// Chatwoot sync run statuses
+const (
+	ChatwootSyncRunning     = "running"
+	ChatwootSyncCompleted   = "completed"
+	ChatwootSyncFailed      = "failed"
+	ChatwootSyncCancelled   = "cancelled"
+	ChatwootSyncInterrupted = "interrupted" // still running when the service stopped
+)
+
+// ChatwootSyncRun is one Chatwoot history sync of a device, kept across restarts
+type ChatwootSyncRun struct {
+	ID             int64      `json:"id"`
+	DeviceID       string     `json:"device_id"`
+	Status         string     `json:"status"`
+	TotalChats     int        `json:"total_chats"`
+	SyncedChats    int        `json:"synced_chats"`
+	FailedChats    int        `json:"failed_chats"`
+	TotalMessages  int        `json:"total_messages"`
+	SyncedMessages int        `json:"synced_messages"`
+	FailedMessages int        `json:"failed_messages"`
+	Error          string     `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// ChatwootFailedExport is a message the history sync could not export, kept until a retry succeeds
+type ChatwootFailedExport struct {
+	DeviceID   string    `json:"device_id"`
+	ChatJID    string    `json:"chat_jid"`
+	MessageID  string    `json:"message_id"`
+	MessageKey string    `json:"message_key"`
+	Reason     string    `json:"reason"` // download_failed, upload_failed or rate_limited
+	Error      string    `json:"error,omitempty"`
+	Attempts   int       `json:"attempts"`
+	CreatedAt  time.Time `json:"created_at"` // First failure
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Webhook outbox statuses
+const (
+	WebhookOutboxPending = "pending"
+	WebhookOutboxDead    = "dead"
+)
+
+// WebhookOutboxEntry is a webhook delivery that failed and is waiting to be retried
+type WebhookOutboxEntry struct {
+	ID            int64     `json:"id"`
+	URL           string    `json:"url"`
+	Event         string    `json:"event"`
+	Payload       string    `json:"payload"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Maintenance buffer targets
+const (
+	MaintenanceTargetWebhook  = "webhook"
+	MaintenanceTargetChatwoot = "chatwoot"
+)
+
+// MaintenanceBufferEntry is an event held back while maintenance mode is active, delivered in ID order afterwards
+type MaintenanceBufferEntry struct {
+	ID        int64     `json:"id"`
+	Target    string    `json:"target"`
+	Event     string    `json:"event"`
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PendingCSATSurvey is a Chatwoot CSAT survey sent to a contact that can still be answered with a bare rating
+type PendingCSATSurvey struct {
+	Identifier     string
+	ConversationID int
+	SurveyUUID     string
+	ExpiresAt      time.Time
+	CreatedAt      time.Time
+}
+
+// LIDMapping pairs the LID of a WhatsApp user with their phone number JID. ChatwootLinkedAt is set
+// once Chatwoot contacts made for each of them were linked into one.
+type LIDMapping struct {
+	LID              string
+	PhoneJID         string
+	ChatwootLinkedAt *time.Time
+	UpdatedAt        time.Time
+}
+
+// ChatwootInbox is the Chatwoot inbox provisioned for a device by POST /chatwoot/setup.
+type ChatwootInbox struct {
+	DeviceID  string    `json:"device_id"`
+	InboxID   int       `json:"inbox_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChatwootConfig is the Chatwoot connection saved with PUT /chatwoot/config. Once saved it replaces the
+// CHATWOOT_* environment settings it covers, unless CHATWOOT_CONFIG_LOCKED is set.
+type ChatwootConfig struct {
+	URL                     string    `json:"url"`
+	APIToken                string    `json:"api_token"`
+	AccountID               int       `json:"account_id"`
+	InboxID                 int       `json:"inbox_id"`
+	ImportMessages          bool      `json:"import_messages"`
+	DaysLimitImportMessages int       `json:"days_limit_import_messages"`
+	SyncIncludeMedia        bool      `json:"sync_include_media"`
+	SyncIncludeGroups       bool      `json:"sync_include_groups"`
+	SyncAvatar              bool      `json:"sync_avatar"`
+	TypingIndicator         bool      `json:"typing_indicator"`
+	UpdatedAt               time.Time `json:"updated_at"`
+}
+
+// ChatwootSentMessage is a WhatsApp message sent for a message in a Chatwoot conversation. A message
+// with attachments is sent as one WhatsApp message per attachment.
+type ChatwootSentMessage struct {
+	MessageID         string    `json:"message_id"` // WhatsApp message ID
+	ChatJID           string    `json:"chat_jid"`
+	ConversationID    int       `json:"conversation_id"`
+	ChatwootMessageID int       `json:"chatwoot_message_id,omitempty"` // 0 for messages the bridge sent on its own, like rating prompts
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Chatwoot audit decisions
+const (
+	ChatwootAuditReceived  = "received"
+	ChatwootAuditSkipped   = "skipped"
+	ChatwootAuditForwarded = "forwarded"
+	ChatwootAuditFailed    = "failed"
+)
+
+// ChatwootAuditEntry is one decision the Chatwoot bridge took for a message, kept for GET /chatwoot/audit
+type ChatwootAuditEntry struct {
+	ID                int64     `json:"id"`
+	DeviceID          string    `json:"device_id,omitempty"`
+	ChatJID           string    `json:"chat_jid"`
+	MessageID         string    `json:"message_id,omitempty"` // WhatsApp message ID
+	Direction         string    `json:"direction"`            // whatsapp_to_chatwoot or chatwoot_to_whatsapp
+	Decision          string    `json:"decision"`
+	Reason            string    `json:"reason,omitempty"`
+	ChatwootMessageID int       `json:"chatwoot_message_id,omitempty"`
+	Detail            string    `json:"detail,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// StoragePruneOptions selects the rows PruneStorage removes. Cutoffs are compared by UTC day and a
+// zero cutoff leaves its tables alone.
+type StoragePruneOptions struct {
+	MessagesBefore time.Time // Messages sent earlier, and Chatwoot export state not updated since
+	DedupBefore    time.Time // Chatwoot exported-message dedup rows created earlier
+	KeepMedia      bool      // Keep messages carrying media metadata
+	BatchSize      int       // Rows deleted per statement
+	DryRun         bool      // Count the rows without deleting them
+}
+
+// StoragePruneResult reports the rows PruneStorage deleted, or would delete on a dry run, per table
+type StoragePruneResult struct {
+	DryRun  bool             `json:"dry_run"`
+	Deleted map[string]int64 `json:"deleted"`
+}
+
+// SchemaVersion is the migration state of the chat storage database
+type SchemaVersion struct {
+	Version   int        `json:"version"` // Last migration applied
+	Latest    int        `json:"latest"`  // Last migration this build ships
+	Pending   int        `json:"pending"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"` // When the last migration was applied
+}
+
+// DBStats is the state of the chat storage connection pool, from sql.DBStats
+type DBStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`       // Connections waited for
+	WaitDurationMs     int64 `json:"wait_duration_ms"` // Total time spent waiting for a connection
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+// ChatAutoReply is an auto-reply set for one chat of a device, answered instead of WHATSAPP_AUTO_REPLY
+type ChatAutoReply struct {
+	DeviceID       string     `json:"device_id"`
+	ChatJID        string     `json:"chat_jid"`
+	Message        string     `json:"message"`
+	ConversationID int        `json:"conversation_id,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Poll is a WhatsApp poll kept so votes, which only carry option hashes, can be tallied.
+type Poll struct {
+	MessageID       string    `json:"message_id"`
+	ChatJID         string    `json:"chat_jid"`
+	Question        string    `json:"question"`
+	Options         []string  `json:"options"`
+	SelectableCount int       `json:"selectable_count"` // 1 for single choice, 0 for any number
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// PollVote is the current choice of one voter. WhatsApp sends the full selection on every change,
+// so a vote replaces the voter's previous one and an empty Options is a retraction.
+type PollVote struct {
+	PollMessageID string    `json:"poll_message_id"`
+	VoterJID      string    `json:"voter_jid"`
+	Options       []string  `json:"options"`
+	VotedAt       time.Time `json:"voted_at"`
+}
+
+// PollResults is a poll with the number of votes per option, in the poll's option order.
+type PollResults struct {
+	Poll   Poll              `json:"poll"`
+	Tally  []PollOptionTally `json:"tally"`
+	Voters int               `json:"voters"`
+}
+
+// PollOptionTally is the number of voters that selected one option.
+type PollOptionTally struct {
+	Option string `json:"option"`
+	Votes  int    `json:"votes"`
+}
+
 type IChatStorageRepository interface {
 	IsChatwootMessageFromUs(chatwootMessageID int) (bool, error)
 
@@ -22,13 +249,50 @@ type IChatStorageRepository interface {
 	UpsertChatExportState(state *ChatExportState) error
 
 	IsMessageExported(deviceID, chatJID, messageKey string) (bool, error)
-	MarkMessageExported(deviceID, chatJID, messageKey string, chatwootMessageID int) error
+	GetExportedChatwootMessageID(deviceID, chatJID, messageKey string) (int, error)        // 0 when not exported
+	MarkMessageExported(deviceID, chatJID, messageKey string, chatwootMessageID int) error // Replaces the Chatwoot ID of a message exported again
+
+	// Chatwoot account/inbox the stored Chatwoot IDs belong to
+	GetChatwootFingerprint() (string, error)
+	SaveChatwootFingerprint(fingerprint string) error
+	ClearChatwootState() (int64, error) // Drops exported-message IDs, export state, pending surveys, sent-message conversations and contact IDs
+
+	// Chatwoot settings changed with PUT /chatwoot/config
+	GetChatwootConfig() (*ChatwootConfig, error) // nil when none was saved
+	SaveChatwootConfig(cfg *ChatwootConfig) error
+
+	// WhatsApp messages sent from a Chatwoot conversation, so delivery failures reach its agents and
+	// neither direction bridges them again
+	SaveChatwootSentMessage(sent *ChatwootSentMessage) error
+	GetChatwootSentMessageConversation(messageID string) (int, error)              // 0 when the message did not come from Chatwoot
+	GetChatwootSentMessageIDs(chatwootMessageID int) ([]string, error)             // WhatsApp IDs sent for a Chatwoot message, oldest first
+	GetChatwootSentMessages(chatwootMessageID int) ([]*ChatwootSentMessage, error) // Oldest first
+
+	// Chatwoot contact used for each WhatsApp identifier, by account, so a contact agents merged is
+	// still found after a restart
+	GetChatwootContactID(account, identifier string) (int, error) // 0 when none is recorded
+	SaveChatwootContactID(account, identifier string, contactID int) error
+	DeleteChatwootContactID(account, identifier string) error
+
+	// Chatwoot history sync runs
+	CreateChatwootSyncRun(run *ChatwootSyncRun) error // Sets run.ID
+	UpdateChatwootSyncRun(run *ChatwootSyncRun) error
+	GetChatwootSyncRuns(deviceID string, limit int) ([]*ChatwootSyncRun, error) // Newest first
+	MarkInterruptedChatwootSyncRuns() (int64, error)                            // Running runs become interrupted
+
+	// Messages the history sync failed to export
+	SaveChatwootFailedExport(failure *ChatwootFailedExport) error // Counts another attempt when the message failed before
+	DeleteChatwootFailedExport(deviceID, chatJID, messageKey string) error
+	GetChatwootFailedExports(deviceID string, limit int) ([]*ChatwootFailedExport, error) // Oldest first
+	CountChatwootFailedExports(deviceID string) (map[string]int, error)                   // By reason
+	PurgeChatwootFailedExports(olderThan time.Time) (int64, error)
 
 	// Chat operations
 	CreateMessage(ctx context.Context, evt *events.Message) error
 	StoreChat(chat *Chat) error
 	GetChat(jid string) (*Chat, error)
 	GetChatByDevice(deviceID, jid string) (*Chat, error)
+	UpdateChatName(jid, name string) error // Renames the chat on every device
 	GetChats(filter *ChatFilter) ([]*Chat, error)
 	DeleteChat(jid string) error
 	DeleteChatByDevice(deviceID, jid string) error
@@ -36,9 +300,11 @@ type IChatStorageRepository interface {
 	// Message operations
 	StoreMessage(message *Message) error
 	StoreMessagesBatch(messages []*Message) error
+	CreateMessagesBatch(ctx context.Context, messages []*Message) error
 	GetMessageByID(id string) (*Message, error) // New method for efficient ID-only search
 	GetMessages(filter *MessageFilter) ([]*Message, error)
 	SearchMessages(deviceID, chatJID, searchText string, limit int) ([]*Message, error) // Database-level search with device isolation
+	SearchMessagesFullText(filter *MessageSearchFilter) (*MessageSearchPage, error)     // Full-text search across chats, paged by cursor
 	DeleteMessage(id, chatJID string) error
 	DeleteMessageByDevice(deviceID, id, chatJID string) error
 	StoreSentMessageWithContext(ctx context.Context, messageID string, senderJID string, recipientJID string, content string, timestamp time.Time) error
@@ -63,6 +329,61 @@ type IChatStorageRepository interface {
 	GetDeviceRecord(deviceID string) (*DeviceRecord, error)
 	DeleteDeviceRecord(deviceID string) error
 
+	// Webhook outbox operations
+	EnqueueWebhookOutbox(entry *WebhookOutboxEntry) error
+	GetDueWebhookOutbox(now time.Time, limit int) ([]*WebhookOutboxEntry, error)
+	GetWebhookOutboxEntry(id int64) (*WebhookOutboxEntry, error)
+	ListWebhookOutbox(status string, limit int) ([]*WebhookOutboxEntry, error)
+	UpdateWebhookOutbox(entry *WebhookOutboxEntry) error
+	DeleteWebhookOutbox(id int64) error
+	CountWebhookOutbox() (map[string]int64, error)
+
+	// Chatwoot CSAT survey operations
+	SavePendingCSATSurvey(survey *PendingCSATSurvey) error
+	GetPendingCSATSurvey(identifier string, now time.Time) (*PendingCSATSurvey, error)
+	DeletePendingCSATSurvey(identifier string) error
+
+	// LID to phone number mappings, kept for contacts the device store no longer resolves
+	SaveLIDMapping(lid, phoneJID string) error
+	GetPhoneForLID(lid string) (string, error) // "" when the LID was never resolved
+	GetUnlinkedLIDMappings(limit int) ([]*LIDMapping, error)
+	MarkLIDMappingLinked(lid string) error
+
+	// Chatwoot inboxes provisioned per device
+	SaveChatwootInbox(inbox *ChatwootInbox) error
+	ListChatwootInboxes() ([]*ChatwootInbox, error)
+
+	// Chatwoot bridging audit trail
+	AddChatwootAuditEntry(entry *ChatwootAuditEntry) error
+	GetChatwootAuditEntries(chatJID string, limit int) ([]*ChatwootAuditEntry, error) // The latest limit, oldest first
+	PurgeChatwootAuditEntries(olderThan time.Time) (int64, error)
+
+	// Per-chat auto-replies
+	SaveChatAutoReply(reply *ChatAutoReply) error
+	GetChatAutoReply(deviceID, chatJID string, now time.Time) (*ChatAutoReply, error)
+	ListChatAutoReplies(now time.Time) ([]*ChatAutoReply, error)
+	DeleteChatAutoReply(deviceID, chatJID string) (bool, error)
+
+	// Polls
+	SavePoll(poll *Poll) error
+	SavePollVote(vote *PollVote) error
+	GetPollResults(pollMessageID string) (*PollResults, error)
+
+	// Maintenance buffer
+	EnqueueMaintenanceEvent(entry *MaintenanceBufferEntry) error
+	ListMaintenanceEvents(limit int) ([]*MaintenanceBufferEntry, error)
+	DeleteMaintenanceEvent(id int64) error
+	CountMaintenanceEvents() (int64, error)
+
+	// Receipts
+	UpdateMessageReceipt(receipts ...*MessageReceipt) error
+	GetMessageReceipts(deviceID string, messageIDs []string) (map[string][]*MessageReceipt, error) // By message ID
+
+	// Retention
+	PruneStorage(ctx context.Context, opts StoragePruneOptions) (*StoragePruneResult, error)
+
 	// Schema operations
 	InitializeSchema() error
+	GetSchemaVersion() (*SchemaVersion, error)
+	GetDBStats() DBStats
 }
@@ -0,0 +1,64 @@
+package chatstorage
+
+import (
+	"fmt"
+	"time"
+)
+
+// MessageReceipt is the delivery and read state of a sent message for one recipient. In a group
+// every participant has their own receipt; in a direct chat the participant is the contact.
+type MessageReceipt struct {
+	DeviceID    string
+	ChatJID     string
+	MessageID   string
+	Participant string
+	DeliveredAt *time.Time
+	ReadAt      *time.Time
+}
+
+// ReceiptSummary aggregates the receipts of one message
+type ReceiptSummary struct {
+	Recipients  int        // Recipients the message went to
+	Delivered   int        // Recipients whose device received it
+	Read        int        // Recipients who read or played it
+	DeliveredAt *time.Time // First delivery
+	ReadAt      *time.Time // First read
+}
+
+// String describes the summary as "read by N of M", or the delivery state before anyone read it.
+func (s ReceiptSummary) String() string {
+	switch {
+	case s.Read > 0:
+		return fmt.Sprintf("read by %d of %d", s.Read, s.Recipients)
+	case s.Delivered > 0:
+		return fmt.Sprintf("delivered to %d of %d", s.Delivered, s.Recipients)
+	default:
+		return "sent"
+	}
+}
+
+// SummarizeReceipts aggregates the receipts of one message. recipients is the number of people the
+// message went to, such as the participants of a group besides us; when it is unknown (0) the
+// recipients that sent a receipt are counted instead.
+func SummarizeReceipts(receipts []*MessageReceipt, recipients int) ReceiptSummary {
+	summary := ReceiptSummary{Recipients: recipients}
+	for _, receipt := range receipts {
+		if receipt.DeliveredAt != nil {
+			summary.Delivered++
+			summary.DeliveredAt = earliest(summary.DeliveredAt, receipt.DeliveredAt)
+		}
+		if receipt.ReadAt != nil {
+			summary.Read++
+			summary.ReadAt = earliest(summary.ReadAt, receipt.ReadAt)
+		}
+	}
+	summary.Recipients = max(summary.Recipients, len(receipts))
+	return summary
+}
+
+func earliest(current, candidate *time.Time) *time.Time {
+	if current == nil || candidate.Before(*current) {
+		return candidate
+	}
+	return current
+}
@@ -4,7 +4,8 @@ import "mime/multipart"
 
 type FileRequest struct {
 	BaseRequest
-	File    *multipart.FileHeader `json:"file" form:"file"`
-	FileURL *string               `json:"file_url" form:"file_url"`
-	Caption string                `json:"caption" form:"caption"`
+	File     *multipart.FileHeader `json:"file" form:"file"`
+	FileURL  *string               `json:"file_url" form:"file_url"`
+	Caption  string                `json:"caption" form:"caption"`
+	Filename string                `json:"filename" form:"filename"` // Name the recipient sees, defaults to the name of the file
 }
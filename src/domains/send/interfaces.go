@@ -7,6 +7,8 @@ import (
 // ITextSender handles text message sending operations
 type ITextSender interface {
 	SendText(ctx context.Context, request MessageRequest) (response GenericResponse, err error)
+	EditMessage(ctx context.Context, request EditMessageRequest) (response GenericResponse, err error)
+	RevokeMessage(ctx context.Context, request RevokeMessageRequest) (response GenericResponse, err error)
 }
 
 // IMediaSender handles media message sending operations
@@ -6,3 +6,16 @@ type MessageRequest struct {
 	ReplyMessageID *string  `json:"reply_message_id" form:"reply_message_id"`
 	Mentions       []string `json:"mentions,omitempty" form:"mentions"` // List of phone numbers/JIDs to mention (ghost mentions)
 }
+
+// EditMessageRequest replaces the text of a message sent by this device.
+type EditMessageRequest struct {
+	BaseRequest
+	MessageID string `json:"message_id" form:"message_id"`
+	Message   string `json:"message" form:"message"`
+}
+
+// RevokeMessageRequest deletes a message sent by this device for everyone in the chat.
+type RevokeMessageRequest struct {
+	BaseRequest
+	MessageID string `json:"message_id" form:"message_id"`
+}
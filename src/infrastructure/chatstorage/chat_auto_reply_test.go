@@ -0,0 +1,65 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestChatAutoReply_ReplacesExpiresAndDeletes(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	now := time.Now().UTC()
+	week := now.Add(7 * 24 * time.Hour)
+
+	for _, reply := range []*domainChatStorage.ChatAutoReply{
+		{DeviceID: "device-1", ChatJID: "5511999@s.whatsapp.net", Message: "Away", ConversationID: 1},
+		{DeviceID: "device-1", ChatJID: "5511999@s.whatsapp.net", Message: "Away until Monday", ConversationID: 2, ExpiresAt: &week},
+		{DeviceID: "device-1", ChatJID: "5511888@s.whatsapp.net", Message: "On leave"},
+	} {
+		if err := repo.SaveChatAutoReply(reply); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+	if err := repo.SaveChatAutoReply(&domainChatStorage.ChatAutoReply{DeviceID: "device-1", ChatJID: "5511777@s.whatsapp.net"}); err == nil {
+		t.Fatal("expected an error for an auto-reply without a message")
+	}
+
+	got, err := repo.GetChatAutoReply("device-1", "5511999@s.whatsapp.net", now)
+	if err != nil || got == nil {
+		t.Fatalf("expected an auto-reply, got %v (err %v)", got, err)
+	}
+	if got.Message != "Away until Monday" || got.ConversationID != 2 || got.ExpiresAt == nil || !got.ExpiresAt.Equal(week) {
+		t.Fatalf("expected the latest auto-reply to win, got %+v", got)
+	}
+
+	if got, _ := repo.GetChatAutoReply("device-1", "5511999@s.whatsapp.net", week.Add(time.Minute)); got != nil {
+		t.Fatalf("expected the auto-reply to expire, got %+v", got)
+	}
+	if list, err := repo.ListChatAutoReplies(week.Add(time.Minute)); err != nil || len(list) != 1 || list[0].ChatJID != "5511888@s.whatsapp.net" {
+		t.Fatalf("expected only the auto-reply without expiry to be listed, got %v (err %v)", list, err)
+	}
+
+	if deleted, err := repo.DeleteChatAutoReply("device-1", "5511888@s.whatsapp.net"); err != nil || !deleted {
+		t.Fatalf("expected the auto-reply to be deleted, got %v (err %v)", deleted, err)
+	}
+	if deleted, _ := repo.DeleteChatAutoReply("device-1", "5511888@s.whatsapp.net"); deleted {
+		t.Fatal("expected a second delete to report nothing removed")
+	}
+}
+
+func TestChatAutoReply_ScopedByDevice(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	now := time.Now().UTC()
+	chatJID := "5511999@s.whatsapp.net"
+
+	if err := repo.SaveChatAutoReply(&domainChatStorage.ChatAutoReply{DeviceID: "device-1", ChatJID: chatJID, Message: "Away"}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if got, _ := repo.GetChatAutoReply("device-2", chatJID, now); got != nil {
+		t.Fatalf("expected no auto-reply for another device, got %+v", got)
+	}
+	if deleted, _ := repo.DeleteChatAutoReply("device-2", chatJID); deleted {
+		t.Fatal("expected another device not to clear the auto-reply")
+	}
+}
@@ -0,0 +1,36 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestStoreChat_RecordsTheChatType(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	want := map[string]string{
+		"628123456789@s.whatsapp.net":   domainChatStorage.ChatTypeUser,
+		"120363000000000201@g.us":       domainChatStorage.ChatTypeGroup,
+		"120363144038483540@newsletter": domainChatStorage.ChatTypeNewsletter,
+		"status@broadcast":              domainChatStorage.ChatTypeBroadcast,
+	}
+	for jid := range want {
+		if err := repo.StoreChat(&domainChatStorage.Chat{DeviceID: "dev-a", JID: jid, Name: jid, LastMessageTime: time.Now()}); err != nil {
+			t.Fatalf("store chat %s failed: %v", jid, err)
+		}
+	}
+
+	chats, err := repo.GetChats(&domainChatStorage.ChatFilter{DeviceID: "dev-a"})
+	if err != nil {
+		t.Fatalf("get chats failed: %v", err)
+	}
+	if len(chats) != len(want) {
+		t.Fatalf("expected %d chats, got %d", len(want), len(chats))
+	}
+	for _, chat := range chats {
+		if chat.Type != want[chat.JID] {
+			t.Errorf("chat %s has type %q, want %q", chat.JID, chat.Type, want[chat.JID])
+		}
+	}
+}
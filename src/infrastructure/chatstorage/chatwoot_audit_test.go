@@ -0,0 +1,57 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestChatwootAudit_ReturnsLatestEntriesInOrder(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	chat := "5511999990000@s.whatsapp.net"
+	decisions := []string{domainChatStorage.ChatwootAuditReceived, domainChatStorage.ChatwootAuditSkipped, domainChatStorage.ChatwootAuditReceived, domainChatStorage.ChatwootAuditForwarded}
+	for i, decision := range decisions {
+		if err := repo.AddChatwootAuditEntry(&domainChatStorage.ChatwootAuditEntry{
+			ChatJID: chat, MessageID: "M" + string(rune('A'+i)), Direction: "whatsapp_to_chatwoot", Decision: decision,
+		}); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+	}
+	if err := repo.AddChatwootAuditEntry(&domainChatStorage.ChatwootAuditEntry{ChatJID: "other@s.whatsapp.net", Decision: domainChatStorage.ChatwootAuditReceived}); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if err := repo.AddChatwootAuditEntry(&domainChatStorage.ChatwootAuditEntry{Decision: domainChatStorage.ChatwootAuditReceived}); err == nil {
+		t.Fatal("expected an entry without chat to be rejected")
+	}
+
+	entries, err := repo.GetChatwootAuditEntries(chat, 3)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].MessageID != "MB" || entries[2].MessageID != "MD" || entries[2].Decision != domainChatStorage.ChatwootAuditForwarded {
+		t.Fatalf("expected the latest three entries oldest first, got %+v", entries)
+	}
+}
+
+func TestChatwootAudit_Purge(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	old := &domainChatStorage.ChatwootAuditEntry{ChatJID: "a@s.whatsapp.net", Decision: domainChatStorage.ChatwootAuditReceived, CreatedAt: time.Now().Add(-48 * time.Hour)}
+	recent := &domainChatStorage.ChatwootAuditEntry{ChatJID: "a@s.whatsapp.net", Decision: domainChatStorage.ChatwootAuditReceived}
+	for _, entry := range []*domainChatStorage.ChatwootAuditEntry{old, recent} {
+		if err := repo.AddChatwootAuditEntry(entry); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+	}
+
+	purged, err := repo.PurgeChatwootAuditEntries(time.Now().Add(-24 * time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("expected one entry purged, got %d (%v)", purged, err)
+	}
+	entries, _ := repo.GetChatwootAuditEntries("a@s.whatsapp.net", 10)
+	if len(entries) != 1 || entries[0].ID != recent.ID {
+		t.Fatalf("expected the recent entry to remain, got %+v", entries)
+	}
+}
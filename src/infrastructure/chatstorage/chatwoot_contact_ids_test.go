@@ -0,0 +1,31 @@
+package chatstorage
+
+import "testing"
+
+func TestChatwootContactID_SaveLookupAndDelete(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	if id, err := repo.GetChatwootContactID("https://cw|1", "5511999990001"); err != nil || id != 0 {
+		t.Fatalf("expected no contact for an unknown identifier, got %d (err %v)", id, err)
+	}
+	if err := repo.SaveChatwootContactID("https://cw|1", "5511999990001", 5); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	// A merge moves the identifier to the surviving contact
+	if err := repo.SaveChatwootContactID("https://cw|1", "5511999990001", 9); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if id, _ := repo.GetChatwootContactID("https://cw|1", "5511999990001"); id != 9 {
+		t.Errorf("expected contact 9, got %d", id)
+	}
+	if id, _ := repo.GetChatwootContactID("https://cw|2", "5511999990001"); id != 0 {
+		t.Errorf("expected another account not to see the contact, got %d", id)
+	}
+
+	if err := repo.DeleteChatwootContactID("https://cw|1", "5511999990001"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if id, _ := repo.GetChatwootContactID("https://cw|1", "5511999990001"); id != 0 {
+		t.Errorf("expected the contact to be forgotten, got %d", id)
+	}
+}
@@ -0,0 +1,54 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestChatwootFailedExports(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	failures := []*domainChatStorage.ChatwootFailedExport{
+		{DeviceID: "dev1", ChatJID: "a@s.whatsapp.net", MessageID: "M1", MessageKey: "k1", Reason: "download_failed", Error: "timeout"},
+		{DeviceID: "dev1", ChatJID: "a@s.whatsapp.net", MessageID: "M2", MessageKey: "k2", Reason: "upload_failed"},
+		{DeviceID: "dev2", ChatJID: "b@s.whatsapp.net", MessageID: "M3", MessageKey: "k3", Reason: "rate_limited"},
+	}
+	for _, f := range failures {
+		if err := repo.SaveChatwootFailedExport(f); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+	// Failing again counts an attempt and keeps the latest reason
+	again := *failures[0]
+	again.Reason, again.Error = "rate_limited", "429"
+	if err := repo.SaveChatwootFailedExport(&again); err != nil {
+		t.Fatalf("save again failed: %v", err)
+	}
+
+	got, err := repo.GetChatwootFailedExports("dev1", 10)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("expected 2 failures of dev1, got %d (err %v)", len(got), err)
+	}
+	if got[0].MessageKey != "k1" || got[0].Attempts != 2 || got[0].Reason != "rate_limited" || got[0].Error != "429" {
+		t.Errorf("repeated failure not updated: %+v", got[0])
+	}
+
+	counts, err := repo.CountChatwootFailedExports("dev1")
+	if err != nil || counts["rate_limited"] != 1 || counts["upload_failed"] != 1 || len(counts) != 2 {
+		t.Errorf("unexpected counts %v (err %v)", counts, err)
+	}
+
+	if err := repo.DeleteChatwootFailedExport("dev1", "a@s.whatsapp.net", "k2"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got, _ := repo.GetChatwootFailedExports("dev1", 10); len(got) != 1 {
+		t.Errorf("expected 1 failure after delete, got %d", len(got))
+	}
+
+	purged, err := repo.PurgeChatwootFailedExports(time.Now().Add(time.Minute))
+	if err != nil || purged != 2 {
+		t.Errorf("expected both remaining failures purged, got %d (err %v)", purged, err)
+	}
+}
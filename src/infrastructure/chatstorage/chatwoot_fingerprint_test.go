@@ -0,0 +1,88 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestChatwootFingerprint_SaveAndClearState(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	if got, err := repo.GetChatwootFingerprint(); err != nil || got != "" {
+		t.Fatalf("expected no fingerprint, got %q (err %v)", got, err)
+	}
+	for _, fp := range []string{"old", "new"} {
+		if err := repo.SaveChatwootFingerprint(fp); err != nil {
+			t.Fatalf("save fingerprint failed: %v", err)
+		}
+	}
+	if got, _ := repo.GetChatwootFingerprint(); got != "new" {
+		t.Fatalf("expected the last fingerprint, got %q", got)
+	}
+
+	if err := repo.MarkMessageExported("dev", "1@s.whatsapp.net", "k1", 10); err != nil {
+		t.Fatalf("mark exported failed: %v", err)
+	}
+	if err := repo.MarkMessageExported("dev", "1@s.whatsapp.net", "k2", 11); err != nil {
+		t.Fatalf("mark exported failed: %v", err)
+	}
+	if err := repo.UpsertChatExportState(&domainChatStorage.ChatExportState{DeviceID: "dev", ChatJID: "1@s.whatsapp.net", LastExportedAt: time.Now()}); err != nil {
+		t.Fatalf("upsert export state failed: %v", err)
+	}
+	if err := repo.SavePendingCSATSurvey(&domainChatStorage.PendingCSATSurvey{Identifier: "1@s.whatsapp.net", ConversationID: 5, SurveyUUID: "u", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("save survey failed: %v", err)
+	}
+
+	removed, err := repo.ClearChatwootState()
+	if err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 exported messages removed, got %d", removed)
+	}
+	if exported, _ := repo.IsMessageExported("dev", "1@s.whatsapp.net", "k1"); exported {
+		t.Error("exported message survived the clear")
+	}
+	if fromUs, _ := repo.IsChatwootMessageFromUs(10); fromUs {
+		t.Error("Chatwoot message ID of the old inbox is still recognised")
+	}
+	if state, _ := repo.GetChatExportState("dev", "1@s.whatsapp.net"); state != nil {
+		t.Errorf("export state survived the clear: %+v", state)
+	}
+	if survey, _ := repo.GetPendingCSATSurvey("1@s.whatsapp.net", time.Now()); survey != nil {
+		t.Errorf("pending survey survived the clear: %+v", survey)
+	}
+	if got, _ := repo.GetChatwootFingerprint(); got != "new" {
+		t.Errorf("clearing state must keep the fingerprint, got %q", got)
+	}
+}
+
+func TestChatwootConfig_SaveAndGet(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	if cfg, err := repo.GetChatwootConfig(); err != nil || cfg != nil {
+		t.Fatalf("expected no stored config, got %+v (err %v)", cfg, err)
+	}
+	for _, inboxID := range []int{7, 9} {
+		cfg := &domainChatStorage.ChatwootConfig{URL: "https://chat.example.com", APIToken: "secret", AccountID: 3, InboxID: inboxID, ImportMessages: true, DaysLimitImportMessages: 5}
+		if err := repo.SaveChatwootConfig(cfg); err != nil {
+			t.Fatalf("save config failed: %v", err)
+		}
+	}
+
+	got, err := repo.GetChatwootConfig()
+	if err != nil || got == nil {
+		t.Fatalf("expected the stored config, got %+v (err %v)", got, err)
+	}
+	if got.InboxID != 9 || got.APIToken != "secret" || !got.ImportMessages || got.DaysLimitImportMessages != 5 {
+		t.Errorf("expected the last saved config, got %+v", got)
+	}
+	if got.UpdatedAt.IsZero() {
+		t.Error("expected the time the config was saved")
+	}
+	if fp, _ := repo.GetChatwootFingerprint(); fp != "" {
+		t.Errorf("saving the config must not touch the fingerprint, got %q", fp)
+	}
+}
@@ -0,0 +1,29 @@
+package chatstorage
+
+import (
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestChatwootInbox_SaveReplacesInboxOfDevice(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	if err := repo.SaveChatwootInbox(&domainChatStorage.ChatwootInbox{DeviceID: "sales", InboxID: 3, Name: "WhatsApp sales"}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := repo.SaveChatwootInbox(&domainChatStorage.ChatwootInbox{DeviceID: "sales", InboxID: 7, Name: "WhatsApp sales"}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := repo.SaveChatwootInbox(&domainChatStorage.ChatwootInbox{DeviceID: "", InboxID: 9}); err == nil {
+		t.Fatal("expected an inbox without device to be rejected")
+	}
+
+	inboxes, err := repo.ListChatwootInboxes()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(inboxes) != 1 || inboxes[0].InboxID != 7 || inboxes[0].Name != "WhatsApp sales" || inboxes[0].CreatedAt.IsZero() {
+		t.Fatalf("expected the replaced inbox, got %+v", inboxes)
+	}
+}
@@ -0,0 +1,54 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestChatwootSentMessage_SaveLookupAndClear(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	if conv, err := repo.GetChatwootSentMessageConversation("unknown"); err != nil || conv != 0 {
+		t.Fatalf("expected no conversation for an unknown message, got %d (err %v)", conv, err)
+	}
+	if err := repo.SaveChatwootSentMessage(&domainChatStorage.ChatwootSentMessage{MessageID: "MSG1", ChatJID: "1@s.whatsapp.net", ConversationID: 42, ChatwootMessageID: 900}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if conv, _ := repo.GetChatwootSentMessageConversation("MSG1"); conv != 42 {
+		t.Fatalf("expected conversation 42, got %d", conv)
+	}
+	// A message with two attachments is sent as two WhatsApp messages
+	if err := repo.SaveChatwootSentMessage(&domainChatStorage.ChatwootSentMessage{MessageID: "MSG1B", ChatJID: "2@s.whatsapp.net", ConversationID: 42, ChatwootMessageID: 900}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if ids, err := repo.GetChatwootSentMessageIDs(900); err != nil || len(ids) != 2 || ids[0] != "MSG1" || ids[1] != "MSG1B" {
+		t.Fatalf("expected MSG1 and MSG1B for Chatwoot message 900, got %v (err %v)", ids, err)
+	}
+	sent, err := repo.GetChatwootSentMessages(900)
+	if err != nil || len(sent) != 2 || sent[0].ChatJID != "1@s.whatsapp.net" || sent[1].ChatJID != "2@s.whatsapp.net" || sent[1].ConversationID != 42 {
+		t.Fatalf("expected both messages of Chatwoot message 900 with their chats, got %+v (err %v)", sent, err)
+	}
+	if ids, _ := repo.GetChatwootSentMessageIDs(999); len(ids) != 0 {
+		t.Errorf("expected no WhatsApp messages for an unknown Chatwoot message, got %v", ids)
+	}
+
+	// Old records are kept: agents edit and delete messages sent long ago
+	if _, err := repo.db.Exec(`UPDATE chatwoot_sent_messages SET created_at = ? WHERE message_id = 'MSG1'`, time.Now().UTC().Add(-30*24*time.Hour)); err != nil {
+		t.Fatalf("backdate failed: %v", err)
+	}
+	if err := repo.SaveChatwootSentMessage(&domainChatStorage.ChatwootSentMessage{MessageID: "MSG2", ChatJID: "1@s.whatsapp.net", ConversationID: 43, ChatwootMessageID: 901}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if conv, _ := repo.GetChatwootSentMessageConversation("MSG1"); conv != 42 {
+		t.Errorf("expected the old record to be kept, got conversation %d", conv)
+	}
+
+	if _, err := repo.ClearChatwootState(); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if conv, _ := repo.GetChatwootSentMessageConversation("MSG2"); conv != 0 {
+		t.Errorf("sent message conversation survived the clear: %d", conv)
+	}
+}
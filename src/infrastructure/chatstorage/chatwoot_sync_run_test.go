@@ -0,0 +1,79 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestChatwootSyncRun_CreateUpdateAndList(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	start := time.Now().Add(-time.Hour)
+
+	first := &domainChatStorage.ChatwootSyncRun{DeviceID: "dev1", Status: domainChatStorage.ChatwootSyncRunning, StartedAt: start}
+	if err := repo.CreateChatwootSyncRun(first); err != nil || first.ID == 0 {
+		t.Fatalf("create failed: id %d, err %v", first.ID, err)
+	}
+	finished := start.Add(time.Minute)
+	first.Status = domainChatStorage.ChatwootSyncCompleted
+	first.TotalChats, first.SyncedChats, first.SyncedMessages = 3, 3, 42
+	first.FinishedAt = &finished
+	if err := repo.UpdateChatwootSyncRun(first); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	second := &domainChatStorage.ChatwootSyncRun{DeviceID: "dev1", Status: domainChatStorage.ChatwootSyncRunning, StartedAt: start.Add(time.Hour)}
+	other := &domainChatStorage.ChatwootSyncRun{DeviceID: "dev2", Status: domainChatStorage.ChatwootSyncRunning, StartedAt: start}
+	for _, run := range []*domainChatStorage.ChatwootSyncRun{second, other} {
+		if err := repo.CreateChatwootSyncRun(run); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+
+	runs, err := repo.GetChatwootSyncRuns("dev1", 20)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != second.ID || runs[1].ID != first.ID {
+		t.Fatalf("expected the two dev1 runs newest first, got %+v", runs)
+	}
+	got := runs[1]
+	if got.Status != domainChatStorage.ChatwootSyncCompleted || got.SyncedChats != 3 || got.SyncedMessages != 42 || got.FinishedAt == nil {
+		t.Errorf("update not persisted: %+v", got)
+	}
+	if runs[0].FinishedAt != nil {
+		t.Errorf("running run has a finish time: %v", runs[0].FinishedAt)
+	}
+	if runs, _ := repo.GetChatwootSyncRuns("dev1", 1); len(runs) != 1 || runs[0].ID != second.ID {
+		t.Errorf("limit 1 should return only the latest run, got %+v", runs)
+	}
+}
+
+func TestChatwootSyncRun_MarkInterrupted(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	running := &domainChatStorage.ChatwootSyncRun{DeviceID: "dev1", Status: domainChatStorage.ChatwootSyncRunning}
+	done := &domainChatStorage.ChatwootSyncRun{DeviceID: "dev1", Status: domainChatStorage.ChatwootSyncCompleted}
+	for _, run := range []*domainChatStorage.ChatwootSyncRun{running, done} {
+		if err := repo.CreateChatwootSyncRun(run); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+
+	n, err := repo.MarkInterruptedChatwootSyncRuns()
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 interrupted run, got %d (err %v)", n, err)
+	}
+	runs, _ := repo.GetChatwootSyncRuns("dev1", 20)
+	statuses := make(map[int64]*domainChatStorage.ChatwootSyncRun)
+	for _, run := range runs {
+		statuses[run.ID] = run
+	}
+	if run := statuses[running.ID]; run.Status != domainChatStorage.ChatwootSyncInterrupted || run.FinishedAt == nil {
+		t.Errorf("running run: status %q, finished %v; want interrupted with a finish time", run.Status, run.FinishedAt)
+	}
+	if run := statuses[done.ID]; run.Status != domainChatStorage.ChatwootSyncCompleted {
+		t.Errorf("completed run changed to %q", run.Status)
+	}
+}
@@ -50,6 +50,11 @@ func (r *DeviceRepository) GetChatByDevice(deviceID, jid string) (*domainChatSto
 	return r.base.GetChatByDevice(deviceID, jid)
 }
 
+// UpdateChatName is not device-scoped: a group subject is the same on every device.
+func (r *DeviceRepository) UpdateChatName(jid, name string) error {
+	return r.base.UpdateChatName(jid, name)
+}
+
 func (r *DeviceRepository) GetChats(filter *domainChatStorage.ChatFilter) ([]*domainChatStorage.Chat, error) {
 	if filter != nil && filter.DeviceID == "" {
 		filter.DeviceID = r.deviceID
@@ -73,6 +78,10 @@ func (r *DeviceRepository) StoreMessagesBatch(messages []*domainChatStorage.Mess
 	return r.base.StoreMessagesBatch(messages)
 }
 
+func (r *DeviceRepository) CreateMessagesBatch(ctx context.Context, messages []*domainChatStorage.Message) error {
+	return r.base.CreateMessagesBatch(ctx, messages)
+}
+
 func (r *DeviceRepository) GetMessageByID(id string) (*domainChatStorage.Message, error) {
 	return r.base.GetMessageByID(id)
 }
@@ -92,6 +101,15 @@ func (r *DeviceRepository) SearchMessages(deviceID, chatJID, searchText string,
 	return r.base.SearchMessages(targetDeviceID, chatJID, searchText, limit)
 }
 
+func (r *DeviceRepository) SearchMessagesFullText(filter *domainChatStorage.MessageSearchFilter) (*domainChatStorage.MessageSearchPage, error) {
+	if filter != nil && filter.DeviceID == "" {
+		scoped := *filter
+		scoped.DeviceID = r.deviceID
+		filter = &scoped
+	}
+	return r.base.SearchMessagesFullText(filter)
+}
+
 func (r *DeviceRepository) DeleteMessage(id, chatJID string) error {
 	return r.base.DeleteMessageByDevice(r.deviceID, id, chatJID)
 }
@@ -144,6 +162,29 @@ func (r *DeviceRepository) InitializeSchema() error {
 	return r.base.InitializeSchema()
 }
 
+func (r *DeviceRepository) UpdateMessageReceipt(receipts ...*domainChatStorage.MessageReceipt) error {
+	return r.base.UpdateMessageReceipt(receipts...)
+}
+
+func (r *DeviceRepository) GetMessageReceipts(deviceID string, messageIDs []string) (map[string][]*domainChatStorage.MessageReceipt, error) {
+	if deviceID == "" {
+		deviceID = r.deviceID
+	}
+	return r.base.GetMessageReceipts(deviceID, messageIDs)
+}
+
+func (r *DeviceRepository) PruneStorage(ctx context.Context, opts domainChatStorage.StoragePruneOptions) (*domainChatStorage.StoragePruneResult, error) {
+	return r.base.PruneStorage(ctx, opts)
+}
+
+func (r *DeviceRepository) GetSchemaVersion() (*domainChatStorage.SchemaVersion, error) {
+	return r.base.GetSchemaVersion()
+}
+
+func (r *DeviceRepository) GetDBStats() domainChatStorage.DBStats {
+	return r.base.GetDBStats()
+}
+
 func (r *DeviceRepository) DeleteDeviceData(deviceID string) error {
 	target := deviceID
 	if target == "" {
@@ -180,10 +221,221 @@ func (r *DeviceRepository) IsMessageExported(deviceID, chatJID, messageKey strin
 	return r.base.IsMessageExported(deviceID, chatJID, messageKey)
 }
 
+func (r *DeviceRepository) GetExportedChatwootMessageID(deviceID, chatJID, messageKey string) (int, error) {
+	return r.base.GetExportedChatwootMessageID(deviceID, chatJID, messageKey)
+}
+
 func (r *DeviceRepository) MarkMessageExported(deviceID, chatJID, messageKey string, chatwootMessageID int) error {
 	return r.base.MarkMessageExported(deviceID, chatJID, messageKey, chatwootMessageID)
 }
 
+func (r *DeviceRepository) GetChatwootFingerprint() (string, error) {
+	return r.base.GetChatwootFingerprint()
+}
+
+func (r *DeviceRepository) SaveChatwootFingerprint(fingerprint string) error {
+	return r.base.SaveChatwootFingerprint(fingerprint)
+}
+
+func (r *DeviceRepository) GetChatwootConfig() (*domainChatStorage.ChatwootConfig, error) {
+	return r.base.GetChatwootConfig()
+}
+
+func (r *DeviceRepository) SaveChatwootConfig(cfg *domainChatStorage.ChatwootConfig) error {
+	return r.base.SaveChatwootConfig(cfg)
+}
+
+func (r *DeviceRepository) ClearChatwootState() (int64, error) {
+	return r.base.ClearChatwootState()
+}
+
+func (r *DeviceRepository) SaveChatwootSentMessage(sent *domainChatStorage.ChatwootSentMessage) error {
+	return r.base.SaveChatwootSentMessage(sent)
+}
+
+func (r *DeviceRepository) GetChatwootSentMessageIDs(chatwootMessageID int) ([]string, error) {
+	return r.base.GetChatwootSentMessageIDs(chatwootMessageID)
+}
+
+func (r *DeviceRepository) GetChatwootSentMessages(chatwootMessageID int) ([]*domainChatStorage.ChatwootSentMessage, error) {
+	return r.base.GetChatwootSentMessages(chatwootMessageID)
+}
+
+func (r *DeviceRepository) GetChatwootContactID(account, identifier string) (int, error) {
+	return r.base.GetChatwootContactID(account, identifier)
+}
+
+func (r *DeviceRepository) SaveChatwootContactID(account, identifier string, contactID int) error {
+	return r.base.SaveChatwootContactID(account, identifier, contactID)
+}
+
+func (r *DeviceRepository) DeleteChatwootContactID(account, identifier string) error {
+	return r.base.DeleteChatwootContactID(account, identifier)
+}
+
+func (r *DeviceRepository) GetChatwootSentMessageConversation(messageID string) (int, error) {
+	return r.base.GetChatwootSentMessageConversation(messageID)
+}
+
+func (r *DeviceRepository) CreateChatwootSyncRun(run *domainChatStorage.ChatwootSyncRun) error {
+	return r.base.CreateChatwootSyncRun(run)
+}
+
+func (r *DeviceRepository) UpdateChatwootSyncRun(run *domainChatStorage.ChatwootSyncRun) error {
+	return r.base.UpdateChatwootSyncRun(run)
+}
+
+func (r *DeviceRepository) GetChatwootSyncRuns(deviceID string, limit int) ([]*domainChatStorage.ChatwootSyncRun, error) {
+	return r.base.GetChatwootSyncRuns(deviceID, limit)
+}
+
+func (r *DeviceRepository) MarkInterruptedChatwootSyncRuns() (int64, error) {
+	return r.base.MarkInterruptedChatwootSyncRuns()
+}
+
+func (r *DeviceRepository) SaveChatwootFailedExport(failure *domainChatStorage.ChatwootFailedExport) error {
+	return r.base.SaveChatwootFailedExport(failure)
+}
+
+func (r *DeviceRepository) DeleteChatwootFailedExport(deviceID, chatJID, messageKey string) error {
+	return r.base.DeleteChatwootFailedExport(deviceID, chatJID, messageKey)
+}
+
+func (r *DeviceRepository) GetChatwootFailedExports(deviceID string, limit int) ([]*domainChatStorage.ChatwootFailedExport, error) {
+	return r.base.GetChatwootFailedExports(deviceID, limit)
+}
+
+func (r *DeviceRepository) CountChatwootFailedExports(deviceID string) (map[string]int, error) {
+	return r.base.CountChatwootFailedExports(deviceID)
+}
+
+func (r *DeviceRepository) PurgeChatwootFailedExports(olderThan time.Time) (int64, error) {
+	return r.base.PurgeChatwootFailedExports(olderThan)
+}
+
 func (r *DeviceRepository) IsChatwootMessageFromUs(chatwootMessageID int) (bool, error) {
 	return r.base.IsChatwootMessageFromUs(chatwootMessageID)
 }
+
+func (r *DeviceRepository) EnqueueWebhookOutbox(entry *domainChatStorage.WebhookOutboxEntry) error {
+	return r.base.EnqueueWebhookOutbox(entry)
+}
+
+func (r *DeviceRepository) GetDueWebhookOutbox(now time.Time, limit int) ([]*domainChatStorage.WebhookOutboxEntry, error) {
+	return r.base.GetDueWebhookOutbox(now, limit)
+}
+
+func (r *DeviceRepository) GetWebhookOutboxEntry(id int64) (*domainChatStorage.WebhookOutboxEntry, error) {
+	return r.base.GetWebhookOutboxEntry(id)
+}
+
+func (r *DeviceRepository) ListWebhookOutbox(status string, limit int) ([]*domainChatStorage.WebhookOutboxEntry, error) {
+	return r.base.ListWebhookOutbox(status, limit)
+}
+
+func (r *DeviceRepository) UpdateWebhookOutbox(entry *domainChatStorage.WebhookOutboxEntry) error {
+	return r.base.UpdateWebhookOutbox(entry)
+}
+
+func (r *DeviceRepository) DeleteWebhookOutbox(id int64) error {
+	return r.base.DeleteWebhookOutbox(id)
+}
+
+func (r *DeviceRepository) CountWebhookOutbox() (map[string]int64, error) {
+	return r.base.CountWebhookOutbox()
+}
+
+func (r *DeviceRepository) SavePendingCSATSurvey(survey *domainChatStorage.PendingCSATSurvey) error {
+	return r.base.SavePendingCSATSurvey(survey)
+}
+
+func (r *DeviceRepository) GetPendingCSATSurvey(identifier string, now time.Time) (*domainChatStorage.PendingCSATSurvey, error) {
+	return r.base.GetPendingCSATSurvey(identifier, now)
+}
+
+func (r *DeviceRepository) DeletePendingCSATSurvey(identifier string) error {
+	return r.base.DeletePendingCSATSurvey(identifier)
+}
+
+func (r *DeviceRepository) EnqueueMaintenanceEvent(entry *domainChatStorage.MaintenanceBufferEntry) error {
+	return r.base.EnqueueMaintenanceEvent(entry)
+}
+
+func (r *DeviceRepository) ListMaintenanceEvents(limit int) ([]*domainChatStorage.MaintenanceBufferEntry, error) {
+	return r.base.ListMaintenanceEvents(limit)
+}
+
+func (r *DeviceRepository) SaveChatAutoReply(reply *domainChatStorage.ChatAutoReply) error {
+	if reply != nil && reply.DeviceID == "" {
+		reply.DeviceID = r.deviceID
+	}
+	return r.base.SaveChatAutoReply(reply)
+}
+
+func (r *DeviceRepository) GetChatAutoReply(deviceID, chatJID string, now time.Time) (*domainChatStorage.ChatAutoReply, error) {
+	return r.base.GetChatAutoReply(deviceID, chatJID, now)
+}
+
+func (r *DeviceRepository) ListChatAutoReplies(now time.Time) ([]*domainChatStorage.ChatAutoReply, error) {
+	return r.base.ListChatAutoReplies(now)
+}
+
+func (r *DeviceRepository) DeleteChatAutoReply(deviceID, chatJID string) (bool, error) {
+	return r.base.DeleteChatAutoReply(deviceID, chatJID)
+}
+
+func (r *DeviceRepository) SaveLIDMapping(lid, phoneJID string) error {
+	return r.base.SaveLIDMapping(lid, phoneJID)
+}
+
+func (r *DeviceRepository) GetPhoneForLID(lid string) (string, error) {
+	return r.base.GetPhoneForLID(lid)
+}
+
+func (r *DeviceRepository) GetUnlinkedLIDMappings(limit int) ([]*domainChatStorage.LIDMapping, error) {
+	return r.base.GetUnlinkedLIDMappings(limit)
+}
+
+func (r *DeviceRepository) MarkLIDMappingLinked(lid string) error {
+	return r.base.MarkLIDMappingLinked(lid)
+}
+
+func (r *DeviceRepository) SaveChatwootInbox(inbox *domainChatStorage.ChatwootInbox) error {
+	return r.base.SaveChatwootInbox(inbox)
+}
+
+func (r *DeviceRepository) ListChatwootInboxes() ([]*domainChatStorage.ChatwootInbox, error) {
+	return r.base.ListChatwootInboxes()
+}
+
+func (r *DeviceRepository) AddChatwootAuditEntry(entry *domainChatStorage.ChatwootAuditEntry) error {
+	return r.base.AddChatwootAuditEntry(entry)
+}
+
+func (r *DeviceRepository) GetChatwootAuditEntries(chatJID string, limit int) ([]*domainChatStorage.ChatwootAuditEntry, error) {
+	return r.base.GetChatwootAuditEntries(chatJID, limit)
+}
+
+func (r *DeviceRepository) PurgeChatwootAuditEntries(olderThan time.Time) (int64, error) {
+	return r.base.PurgeChatwootAuditEntries(olderThan)
+}
+
+func (r *DeviceRepository) SavePoll(poll *domainChatStorage.Poll) error {
+	return r.base.SavePoll(poll)
+}
+
+func (r *DeviceRepository) SavePollVote(vote *domainChatStorage.PollVote) error {
+	return r.base.SavePollVote(vote)
+}
+
+func (r *DeviceRepository) GetPollResults(pollMessageID string) (*domainChatStorage.PollResults, error) {
+	return r.base.GetPollResults(pollMessageID)
+}
+
+func (r *DeviceRepository) DeleteMaintenanceEvent(id int64) error {
+	return r.base.DeleteMaintenanceEvent(id)
+}
+
+func (r *DeviceRepository) CountMaintenanceEvents() (int64, error) {
+	return r.base.CountMaintenanceEvents()
+}
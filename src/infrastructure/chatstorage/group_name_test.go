@@ -0,0 +1,51 @@
+package chatstorage
+
+import (
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestUpdateChatName_RenamesEveryDevice(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	groupJID := "120363000000000101@g.us"
+
+	for _, deviceID := range []string{"dev-a", "dev-b"} {
+		if err := repo.StoreChat(&domainChatStorage.Chat{DeviceID: deviceID, JID: groupJID, Name: "Group 120363000000000101"}); err != nil {
+			t.Fatalf("store chat failed: %v", err)
+		}
+	}
+	if err := repo.UpdateChatName(groupJID, "Team"); err != nil {
+		t.Fatalf("update chat name failed: %v", err)
+	}
+	if err := repo.UpdateChatName("120363000000000102@g.us", "Unknown"); err != nil {
+		t.Fatalf("update of a missing chat failed: %v", err)
+	}
+
+	for _, deviceID := range []string{"dev-a", "dev-b"} {
+		chat, err := repo.GetChatByDevice(deviceID, groupJID)
+		if err != nil || chat == nil || chat.Name != "Team" {
+			t.Fatalf("expected %s to hold the new name, got %+v (err %v)", deviceID, chat, err)
+		}
+	}
+	if chat, _ := repo.GetChat("120363000000000102@g.us"); chat != nil {
+		t.Fatalf("expected no chat to be created, got %+v", chat)
+	}
+}
+
+func TestGetChatNameWithPushName_UsesCachedGroupSubject(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	jid := types.NewJID("120363000000000103", types.GroupServer)
+	t.Cleanup(func() { whatsapp.InvalidateGroupName(jid.String()) })
+
+	if name := repo.GetChatNameWithPushName(jid, jid.String(), "", ""); name != "Group 120363000000000103" {
+		t.Fatalf("expected the placeholder before the subject is known, got %q", name)
+	}
+
+	whatsapp.GetGroupInfoService().Set(jid.String(), "Team")
+	if name := repo.GetChatNameWithPushNameByDevice("dev-a", jid, jid.String(), "", ""); name != "Team" {
+		t.Fatalf("expected the cached subject, got %q", name)
+	}
+}
@@ -0,0 +1,42 @@
+package chatstorage
+
+import "testing"
+
+func TestLIDMapping_LinkedUntilPhoneChanges(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	if err := repo.SaveLIDMapping("123456789@lid", "5511999990001@s.whatsapp.net"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if phone, err := repo.GetPhoneForLID("123456789@lid"); err != nil || phone != "5511999990001@s.whatsapp.net" {
+		t.Fatalf("expected stored phone number, got %q (err %v)", phone, err)
+	}
+	if phone, err := repo.GetPhoneForLID("987@lid"); err != nil || phone != "" {
+		t.Fatalf("expected no phone number for an unknown LID, got %q (err %v)", phone, err)
+	}
+
+	unlinked, err := repo.GetUnlinkedLIDMappings(10)
+	if err != nil || len(unlinked) != 1 || unlinked[0].LID != "123456789@lid" {
+		t.Fatalf("expected the new mapping to be unlinked, got %+v (err %v)", unlinked, err)
+	}
+	if err := repo.MarkLIDMappingLinked("123456789@lid"); err != nil {
+		t.Fatalf("mark failed: %v", err)
+	}
+
+	// Saving the same pair again keeps it linked
+	if err := repo.SaveLIDMapping("123456789@lid", "5511999990001@s.whatsapp.net"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if unlinked, _ := repo.GetUnlinkedLIDMappings(10); len(unlinked) != 0 {
+		t.Fatalf("expected no unlinked mappings, got %+v", unlinked)
+	}
+
+	// A new phone number has to be linked again
+	if err := repo.SaveLIDMapping("123456789@lid", "5511999990002@s.whatsapp.net"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	unlinked, _ = repo.GetUnlinkedLIDMappings(10)
+	if len(unlinked) != 1 || unlinked[0].PhoneJID != "5511999990002@s.whatsapp.net" {
+		t.Fatalf("expected the changed mapping to be unlinked, got %+v", unlinked)
+	}
+}
@@ -0,0 +1,35 @@
+package chatstorage
+
+import (
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestMaintenanceBuffer_KeepsInsertionOrder(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	for _, target := range []string{domainChatStorage.MaintenanceTargetWebhook, domainChatStorage.MaintenanceTargetChatwoot, domainChatStorage.MaintenanceTargetWebhook} {
+		if err := repo.EnqueueMaintenanceEvent(&domainChatStorage.MaintenanceBufferEntry{Target: target, Event: "message", Payload: `{}`}); err != nil {
+			t.Fatalf("enqueue failed: %v", err)
+		}
+	}
+	if err := repo.EnqueueMaintenanceEvent(&domainChatStorage.MaintenanceBufferEntry{Payload: `{}`}); err == nil {
+		t.Fatal("expected an error for an entry without a target")
+	}
+
+	entries, err := repo.ListMaintenanceEvents(2)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d (err %v)", len(entries), err)
+	}
+	if entries[0].ID >= entries[1].ID || entries[1].Target != domainChatStorage.MaintenanceTargetChatwoot {
+		t.Fatalf("entries are not in insertion order: %+v, %+v", entries[0], entries[1])
+	}
+
+	if err := repo.DeleteMaintenanceEvent(entries[0].ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if count, err := repo.CountMaintenanceEvents(); err != nil || count != 2 {
+		t.Fatalf("expected 2 buffered events, got %d (err %v)", count, err)
+	}
+}
@@ -0,0 +1,186 @@
+package chatstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// countingDriver is the sqlite3 driver counting the statements sent to the database.
+type countingDriver struct {
+	sqlite3.SQLiteDriver
+	statements atomic.Int64
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), statements: &d.statements}, nil
+}
+
+type countingConn struct {
+	*sqlite3.SQLiteConn
+	statements *atomic.Int64
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.statements.Add(1)
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.statements.Add(1)
+	return c.SQLiteConn.QueryContext(ctx, query, args)
+}
+
+func (c *countingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &countingStmt{SQLiteStmt: stmt.(*sqlite3.SQLiteStmt), statements: c.statements}, nil
+}
+
+type countingStmt struct {
+	*sqlite3.SQLiteStmt
+	statements *atomic.Int64
+}
+
+func (s *countingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	s.statements.Add(1)
+	return s.SQLiteStmt.ExecContext(ctx, args)
+}
+
+func (s *countingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	s.statements.Add(1)
+	return s.SQLiteStmt.QueryContext(ctx, args)
+}
+
+var (
+	statementCounter         = &countingDriver{}
+	registerStatementCounter sync.Once
+)
+
+func newCountingSQLiteRepository(t *testing.T) *SQLiteRepository {
+	t.Helper()
+	registerStatementCounter.Do(func() { sql.Register("sqlite3_counting", statementCounter) })
+	db, err := sql.Open("sqlite3_counting", filepath.Join(t.TempDir(), "chatstorage_test.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := &SQLiteRepository{db: db}
+	if err := repo.InitializeSchema(); err != nil {
+		if strings.Contains(err.Error(), "CGO_ENABLED=0") || strings.Contains(err.Error(), "requires cgo") {
+			t.Skipf("skipping chatstorage sqlite tests without cgo: %v", err)
+		}
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+	return repo
+}
+
+func historyMessages(deviceID string, count int) []*domainChatStorage.Message {
+	chat := "5511999990000@s.whatsapp.net"
+	base := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	messages := make([]*domainChatStorage.Message, 0, count)
+	for i := 0; i < count; i++ {
+		messages = append(messages, &domainChatStorage.Message{
+			ID: fmt.Sprintf("H%04d", i), ChatJID: chat, DeviceID: deviceID, Sender: chat,
+			Content: fmt.Sprintf("history %d", i), Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+	}
+	return messages
+}
+
+func countStoredMessages(t *testing.T, repo *SQLiteRepository, deviceID string) int {
+	t.Helper()
+	var count int
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE device_id = ?`, deviceID).Scan(&count); err != nil {
+		t.Fatalf("count messages failed: %v", err)
+	}
+	return count
+}
+
+func TestCreateMessagesBatch_FewerRoundTrips(t *testing.T) {
+	repo := newCountingSQLiteRepository(t)
+
+	statementCounter.statements.Store(0)
+	if err := repo.StoreMessagesBatch(historyMessages("per-row", 1000)); err != nil {
+		t.Fatalf("store batch failed: %v", err)
+	}
+	perRow := statementCounter.statements.Load()
+
+	statementCounter.statements.Store(0)
+	if err := repo.CreateMessagesBatch(context.Background(), historyMessages("bulk", 1000)); err != nil {
+		t.Fatalf("create batch failed: %v", err)
+	}
+	bulk := statementCounter.statements.Load()
+
+	if countStoredMessages(t, repo, "bulk") != 1000 {
+		t.Fatal("expected every message to be inserted")
+	}
+	if bulk*10 > perRow {
+		t.Fatalf("expected at least 10x fewer round trips, got %d against %d", bulk, perRow)
+	}
+	t.Logf("1000 messages: %d statements in bulk, %d row by row", bulk, perRow)
+}
+
+func TestCreateMessagesBatch_SkipsDuplicates(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	ctx := context.Background()
+	messages := historyMessages("dev", 3)
+	live := *messages[0]
+	live.Content = "live copy"
+	if err := repo.StoreMessage(&live); err != nil {
+		t.Fatalf("store message failed: %v", err)
+	}
+
+	duplicate := *messages[1]
+	duplicate.Content = "second copy in the batch"
+	if err := repo.CreateMessagesBatch(ctx, append(messages, &duplicate)); err != nil {
+		t.Fatalf("create batch failed: %v", err)
+	}
+	// Running the same history again is a no-op
+	if err := repo.CreateMessagesBatch(ctx, historyMessages("dev", 3)); err != nil {
+		t.Fatalf("create batch failed: %v", err)
+	}
+
+	if count := countStoredMessages(t, repo, "dev"); count != 3 {
+		t.Fatalf("expected 3 messages, got %d", count)
+	}
+	stored, err := repo.GetMessages(&domainChatStorage.MessageFilter{DeviceID: "dev", ChatJID: messages[0].ChatJID, OldestFirst: true})
+	if err != nil {
+		t.Fatalf("get messages failed: %v", err)
+	}
+	if stored[0].Content != "live copy" || stored[1].Content != "history 1" {
+		t.Fatalf("expected stored and first copies to be kept, got %q and %q", stored[0].Content, stored[1].Content)
+	}
+}
+
+func TestCreateMessagesBatch_FallsBackPerRowOnConstraintError(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	if _, err := repo.db.Exec(`CREATE TRIGGER reject_bad_message BEFORE INSERT ON messages
+		WHEN NEW.id = 'H0001' BEGIN SELECT RAISE(ABORT, 'bad message'); END`); err != nil {
+		t.Fatalf("create trigger failed: %v", err)
+	}
+
+	if err := repo.CreateMessagesBatch(context.Background(), historyMessages("dev", 5)); err != nil {
+		t.Fatalf("create batch failed: %v", err)
+	}
+	if count := countStoredMessages(t, repo, "dev"); count != 4 {
+		t.Fatalf("expected every message but the rejected one, got %d", count)
+	}
+}
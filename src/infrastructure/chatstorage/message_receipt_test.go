@@ -0,0 +1,76 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestMessageReceipts_KeepFirstTimesPerParticipant(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	group := "120363000000000000@g.us"
+	first := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	later := first.Add(time.Hour)
+
+	if err := repo.UpdateMessageReceipt(
+		&domainChatStorage.MessageReceipt{DeviceID: "dev", ChatJID: group, MessageID: "M1", Participant: "a@s.whatsapp.net", DeliveredAt: &first},
+		&domainChatStorage.MessageReceipt{DeviceID: "dev", ChatJID: group, MessageID: "M1", Participant: "b@s.whatsapp.net", ReadAt: &first},
+		&domainChatStorage.MessageReceipt{DeviceID: "dev2", ChatJID: group, MessageID: "M1", Participant: "a@s.whatsapp.net", ReadAt: &first},
+	); err != nil {
+		t.Fatalf("update receipts failed: %v", err)
+	}
+	// A repeated delivery must not move the first one; the read arrives later
+	if err := repo.UpdateMessageReceipt(
+		&domainChatStorage.MessageReceipt{DeviceID: "dev", ChatJID: group, MessageID: "M1", Participant: "a@s.whatsapp.net", DeliveredAt: &later, ReadAt: &later},
+	); err != nil {
+		t.Fatalf("update receipts failed: %v", err)
+	}
+
+	receipts, err := repo.GetMessageReceipts("dev", []string{"M1", "M2"})
+	if err != nil {
+		t.Fatalf("get receipts failed: %v", err)
+	}
+	if len(receipts) != 1 || len(receipts["M1"]) != 2 {
+		t.Fatalf("expected the two participants of M1 on this device, got %v", receipts)
+	}
+	byParticipant := map[string]*domainChatStorage.MessageReceipt{}
+	for _, receipt := range receipts["M1"] {
+		byParticipant[receipt.Participant] = receipt
+	}
+	a, b := byParticipant["a@s.whatsapp.net"], byParticipant["b@s.whatsapp.net"]
+	if a == nil || !a.DeliveredAt.Equal(first) || !a.ReadAt.Equal(later) {
+		t.Fatalf("expected the first delivery and the later read, got %+v", a)
+	}
+	if b == nil || b.DeliveredAt == nil || !b.ReadAt.Equal(first) {
+		t.Fatalf("expected a read to count as delivered, got %+v", b)
+	}
+
+	summary := domainChatStorage.SummarizeReceipts(receipts["M1"], 3)
+	if summary.String() != "read by 2 of 3" || !summary.ReadAt.Equal(first) {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+}
+
+func TestMessageReceipts_RemovedWithMessage(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	chat := "5511999990000@s.whatsapp.net"
+	now := time.Now()
+	if err := repo.StoreMessage(&domainChatStorage.Message{ID: "M1", ChatJID: chat, DeviceID: "dev", Sender: chat, Content: "hi", IsFromMe: true, Timestamp: now}); err != nil {
+		t.Fatalf("store message failed: %v", err)
+	}
+	if err := repo.UpdateMessageReceipt(&domainChatStorage.MessageReceipt{DeviceID: "dev", ChatJID: chat, MessageID: "M1", Participant: chat, DeliveredAt: &now}); err != nil {
+		t.Fatalf("update receipts failed: %v", err)
+	}
+	if err := repo.DeleteMessage("M1", chat); err != nil {
+		t.Fatalf("delete message failed: %v", err)
+	}
+
+	receipts, err := repo.GetMessageReceipts("dev", []string{"M1"})
+	if err != nil {
+		t.Fatalf("get receipts failed: %v", err)
+	}
+	if len(receipts) != 0 {
+		t.Fatalf("expected receipts to go with their message, got %v", receipts)
+	}
+}
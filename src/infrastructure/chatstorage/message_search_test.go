@@ -0,0 +1,125 @@
+package chatstorage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func seedSearchMessages(t *testing.T, repo *SQLiteRepository) time.Time {
+	t.Helper()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	messages := []struct {
+		id, chat, content string
+	}{
+		{"m1", "a@s.whatsapp.net", "Quero agendar uma reunião amanhã"},
+		{"m2", "b@s.whatsapp.net", "A REUNIAO foi cancelada, vamos agendar outra"},
+		{"m3", "a@s.whatsapp.net", "Obrigado pela reunião"},
+		{"m4", "a@s.whatsapp.net", "agendar entrega"},
+		{"m5", "c@s.whatsapp.net", `"reunião" OR NOT agendar`},
+	}
+	for i, m := range messages {
+		if err := repo.StoreMessage(&domainChatStorage.Message{
+			ID: m.id, ChatJID: m.chat, DeviceID: "dev", Sender: m.chat, Content: m.content, Timestamp: base.Add(time.Duration(i) * time.Hour),
+		}); err != nil {
+			t.Fatalf("store message failed: %v", err)
+		}
+	}
+	if err := repo.StoreMessage(&domainChatStorage.Message{
+		ID: "other-device", ChatJID: "a@s.whatsapp.net", DeviceID: "dev2", Sender: "x", Content: "agendar reunião", Timestamp: base,
+	}); err != nil {
+		t.Fatalf("store message failed: %v", err)
+	}
+	return base
+}
+
+func hitIDs(page *domainChatStorage.MessageSearchPage) string {
+	ids := make([]string, 0, len(page.Hits))
+	for _, hit := range page.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSearchMessagesFullText_MultiWordAccentInsensitive(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	seedSearchMessages(t, repo)
+
+	page, err := repo.SearchMessagesFullText(&domainChatStorage.MessageSearchFilter{DeviceID: "dev", Query: "reuniao AGENDAR"})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if got := hitIDs(page); got != "m5,m2,m1" {
+		t.Fatalf("expected messages holding both words, newest first, got %s", got)
+	}
+	if !strings.Contains(page.Hits[2].Snippet, "**reunião**") || page.NextCursor != "" {
+		t.Fatalf("expected a highlighted snippet and no next page, got %+v", page)
+	}
+
+	page, err = repo.SearchMessagesFullText(&domainChatStorage.MessageSearchFilter{DeviceID: "dev", Query: "reunião", ChatJID: "a@s.whatsapp.net"})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if got := hitIDs(page); got != "m3,m1" {
+		t.Fatalf("expected hits of one chat, got %s", got)
+	}
+}
+
+func TestSearchMessagesFullText_CursorAndTimeRange(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	base := seedSearchMessages(t, repo)
+
+	var pages []string
+	filter := &domainChatStorage.MessageSearchFilter{DeviceID: "dev", Query: "agendar", Limit: 2}
+	for range 3 {
+		page, err := repo.SearchMessagesFullText(filter)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		pages = append(pages, hitIDs(page))
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if strings.Join(pages, "|") != "m5,m4|m2,m1" {
+		t.Fatalf("unexpected pages: %v", pages)
+	}
+
+	from, to := base.Add(time.Hour), base.Add(3*time.Hour)
+	page, err := repo.SearchMessagesFullText(&domainChatStorage.MessageSearchFilter{DeviceID: "dev", Query: "agendar", From: &from, To: &to})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if got := hitIDs(page); got != "m4,m2" {
+		t.Fatalf("expected hits within the time range, got %s", got)
+	}
+
+	if _, err := repo.SearchMessagesFullText(&domainChatStorage.MessageSearchFilter{DeviceID: "dev", Query: "agendar", Cursor: "not-a-cursor"}); err == nil {
+		t.Fatal("expected an invalid cursor to be rejected")
+	}
+}
+
+func TestSearchMessagesFullText_FollowsEditsAndDeletes(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	seedSearchMessages(t, repo)
+
+	if err := repo.StoreMessage(&domainChatStorage.Message{
+		ID: "m4", ChatJID: "a@s.whatsapp.net", DeviceID: "dev", Sender: "a@s.whatsapp.net", Content: "entrega confirmada", Timestamp: time.Now(),
+	}); err != nil {
+		t.Fatalf("update message failed: %v", err)
+	}
+	if err := repo.DeleteMessageByDevice("dev", "m1", "a@s.whatsapp.net"); err != nil {
+		t.Fatalf("delete message failed: %v", err)
+	}
+
+	page, err := repo.SearchMessagesFullText(&domainChatStorage.MessageSearchFilter{DeviceID: "dev", Query: "agendar"})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if got := hitIDs(page); got != "m5,m2" {
+		t.Fatalf("expected the index to follow edits and deletes, got %s", got)
+	}
+}
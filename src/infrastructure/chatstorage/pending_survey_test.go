@@ -0,0 +1,40 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestPendingCSATSurvey_ReplacesAndExpires(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	now := time.Now().UTC()
+
+	first := &domainChatStorage.PendingCSATSurvey{Identifier: "5511999", ConversationID: 1, SurveyUUID: "old", ExpiresAt: now.Add(time.Hour)}
+	second := &domainChatStorage.PendingCSATSurvey{Identifier: "5511999", ConversationID: 2, SurveyUUID: "new", ExpiresAt: now.Add(time.Hour)}
+	for _, s := range []*domainChatStorage.PendingCSATSurvey{first, second} {
+		if err := repo.SavePendingCSATSurvey(s); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+
+	got, err := repo.GetPendingCSATSurvey("5511999", now)
+	if err != nil || got == nil {
+		t.Fatalf("expected pending survey, got %v (err %v)", got, err)
+	}
+	if got.ConversationID != 2 || got.SurveyUUID != "new" {
+		t.Fatalf("expected the latest survey to win, got %+v", got)
+	}
+
+	if got, _ := repo.GetPendingCSATSurvey("5511999", now.Add(2*time.Hour)); got != nil {
+		t.Fatalf("expected expired survey to be ignored, got %+v", got)
+	}
+
+	if err := repo.DeletePendingCSATSurvey("5511999"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got, _ := repo.GetPendingCSATSurvey("5511999", now); got != nil {
+		t.Fatalf("expected survey to be deleted, got %+v", got)
+	}
+}
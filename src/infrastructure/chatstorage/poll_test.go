@@ -0,0 +1,59 @@
+package chatstorage
+
+import (
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestPollResults_CountsChangesMultiSelectAndRetractions(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	at := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if got, err := repo.GetPollResults("POLL1"); err != nil || got != nil {
+		t.Fatalf("expected no results for an unknown poll, got %+v (err %v)", got, err)
+	}
+	if err := repo.SavePoll(&domainChatStorage.Poll{
+		MessageID: "POLL1",
+		ChatJID:   "120363@g.us",
+		Question:  "Lunch?",
+		Options:   []string{"Pizza", "Sushi", "Salad"},
+	}); err != nil {
+		t.Fatalf("save poll failed: %v", err)
+	}
+
+	votes := []*domainChatStorage.PollVote{
+		{PollMessageID: "POLL1", VoterJID: "a@s.whatsapp.net", Options: []string{"Pizza", "Sushi"}, VotedAt: at},
+		{PollMessageID: "POLL1", VoterJID: "b@s.whatsapp.net", Options: []string{"Pizza"}, VotedAt: at},
+		{PollMessageID: "POLL1", VoterJID: "c@s.whatsapp.net", Options: []string{"Salad"}, VotedAt: at},
+		// b changes their mind, c retracts
+		{PollMessageID: "POLL1", VoterJID: "b@s.whatsapp.net", Options: []string{"Sushi"}, VotedAt: at.Add(time.Minute)},
+		{PollMessageID: "POLL1", VoterJID: "c@s.whatsapp.net", Options: nil, VotedAt: at.Add(time.Minute)},
+		// a late copy of c's first vote must not bring it back
+		{PollMessageID: "POLL1", VoterJID: "c@s.whatsapp.net", Options: []string{"Salad"}, VotedAt: at},
+	}
+	for _, vote := range votes {
+		if err := repo.SavePollVote(vote); err != nil {
+			t.Fatalf("save vote failed: %v", err)
+		}
+	}
+
+	got, err := repo.GetPollResults("POLL1")
+	if err != nil || got == nil {
+		t.Fatalf("expected results, got %+v (err %v)", got, err)
+	}
+	if got.Poll.Question != "Lunch?" || got.Voters != 2 {
+		t.Fatalf("expected 2 voters on Lunch?, got %+v", got)
+	}
+	want := []domainChatStorage.PollOptionTally{{Option: "Pizza", Votes: 1}, {Option: "Sushi", Votes: 2}, {Option: "Salad", Votes: 0}}
+	for i, tally := range want {
+		if got.Tally[i] != tally {
+			t.Fatalf("unexpected tally %+v, want %+v", got.Tally, want)
+		}
+	}
+
+	if err := repo.SavePollVote(&domainChatStorage.PollVote{PollMessageID: "POLL1"}); err == nil {
+		t.Fatal("expected an error for a vote without a voter")
+	}
+}
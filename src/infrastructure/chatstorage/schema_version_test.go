@@ -0,0 +1,70 @@
+package chatstorage
+
+import (
+	"testing"
+)
+
+func TestSchemaVersion_FreshDatabaseIsAtLatest(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	version, err := repo.GetSchemaVersion()
+	if err != nil {
+		t.Fatalf("get version failed: %v", err)
+	}
+	if version.Version != len(repo.getMigrations()) || version.Latest != version.Version || version.Pending != 0 {
+		t.Fatalf("expected a fresh database at the latest migration, got %+v", version)
+	}
+	if version.UpdatedAt == nil {
+		t.Fatal("expected the time of the last migration")
+	}
+
+	var indexes int
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'chatwoot_exported_messages'`).Scan(&indexes); err != nil {
+		t.Fatalf("count indexes failed: %v", err)
+	}
+	if indexes < 2 {
+		t.Fatalf("expected the chatwoot_message_id and primary key indexes, got %d", indexes)
+	}
+}
+
+func TestSchemaVersion_RerunIsNoop(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	before, err := repo.GetSchemaVersion()
+	if err != nil {
+		t.Fatalf("get version failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repo.InitializeSchema(); err != nil {
+			t.Fatalf("re-running migrations failed: %v", err)
+		}
+	}
+
+	after, err := repo.GetSchemaVersion()
+	if err != nil {
+		t.Fatalf("get version failed: %v", err)
+	}
+	if after.Version != before.Version || !after.UpdatedAt.Equal(*before.UpdatedAt) {
+		t.Fatalf("expected re-running to apply nothing, before %+v after %+v", before, after)
+	}
+	var rows int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM schema_info").Scan(&rows); err != nil {
+		t.Fatalf("count schema_info failed: %v", err)
+	}
+	if rows != after.Version {
+		t.Fatalf("expected one schema_info row per migration, got %d for version %d", rows, after.Version)
+	}
+}
+
+func TestDBStats_ReportsPoolSettings(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	repo.db.SetMaxOpenConns(7)
+
+	if _, err := repo.GetSchemaVersion(); err != nil {
+		t.Fatalf("get version failed: %v", err)
+	}
+	stats := repo.GetDBStats()
+	if stats.MaxOpenConnections != 7 || stats.OpenConnections < 1 || stats.InUse != 0 {
+		t.Fatalf("expected the configured pool with an idle connection, got %+v", stats)
+	}
+}
@@ -3,13 +3,20 @@ package chatstorage
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/mattn/go-sqlite3"
 	"github.com/sirupsen/logrus"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
@@ -60,12 +67,15 @@ CREATE INDEX IF NOT EXISTS idx_chatwoot_exported_messages_chatwoot_id
 func (r *SQLiteRepository) StoreChat(chat *domainChatStorage.Chat) error {
 	now := time.Now()
 	chat.UpdatedAt = now
+	if chat.Type == "" {
+		chat.Type = domainChatStorage.ChatTypeOf(chat.JID)
+	}
 
 	// Try update first, then insert if no rows affected (cross-db compatible)
 	result, err := r.db.Exec(`
-		UPDATE chats SET name = ?, last_message_time = ?, ephemeral_expiration = ?, updated_at = ?
+		UPDATE chats SET name = ?, last_message_time = ?, ephemeral_expiration = ?, chat_type = ?, updated_at = ?
 		WHERE jid = ? AND device_id = ?
-	`, chat.Name, chat.LastMessageTime, chat.EphemeralExpiration, chat.UpdatedAt, chat.JID, chat.DeviceID)
+	`, chat.Name, chat.LastMessageTime, chat.EphemeralExpiration, chat.Type, chat.UpdatedAt, chat.JID, chat.DeviceID)
 	if err != nil {
 		return err
 	}
@@ -73,17 +83,24 @@ func (r *SQLiteRepository) StoreChat(chat *domainChatStorage.Chat) error {
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
 		_, err = r.db.Exec(`
-			INSERT INTO chats (jid, device_id, name, last_message_time, ephemeral_expiration, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?)
-		`, chat.JID, chat.DeviceID, chat.Name, chat.LastMessageTime, chat.EphemeralExpiration, now, chat.UpdatedAt)
+			INSERT INTO chats (jid, device_id, name, last_message_time, ephemeral_expiration, chat_type, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, chat.JID, chat.DeviceID, chat.Name, chat.LastMessageTime, chat.EphemeralExpiration, chat.Type, now, chat.UpdatedAt)
 	}
 	return err
 }
 
+// UpdateChatName renames an existing chat on every device that stores it. Chats that were never
+// stored are left alone.
+func (r *SQLiteRepository) UpdateChatName(jid, name string) error {
+	_, err := r.db.Exec(`UPDATE chats SET name = ?, updated_at = ? WHERE jid = ? AND name != ?`, name, time.Now(), jid, name)
+	return err
+}
+
 // GetChat retrieves a chat by JID
 func (r *SQLiteRepository) GetChat(jid string) (*domainChatStorage.Chat, error) {
 	query := `
-		SELECT device_id, jid, name, last_message_time, ephemeral_expiration, created_at, updated_at
+		SELECT device_id, jid, name, last_message_time, ephemeral_expiration, chat_type, created_at, updated_at
 		FROM chats
 		WHERE jid = ?
 	`
@@ -99,7 +116,7 @@ func (r *SQLiteRepository) GetChat(jid string) (*domainChatStorage.Chat, error)
 // GetChatByDevice retrieves a chat by JID for a specific device
 func (r *SQLiteRepository) GetChatByDevice(deviceID, jid string) (*domainChatStorage.Chat, error) {
 	query := `
-		SELECT device_id, jid, name, last_message_time, ephemeral_expiration, created_at, updated_at
+		SELECT device_id, jid, name, last_message_time, ephemeral_expiration, chat_type, created_at, updated_at
 		FROM chats
 		WHERE jid = ? AND device_id = ?
 	`
@@ -118,7 +135,7 @@ func (r *SQLiteRepository) GetMessageByID(id string) (*domainChatStorage.Message
 	query := `
 		SELECT id, chat_jid, device_id, sender, content, timestamp, is_from_me,
 			media_type, filename, url, media_key, file_sha256,
-			file_enc_sha256, file_length, created_at, updated_at
+			file_enc_sha256, file_length, is_view_once, created_at, updated_at
 		FROM messages
 		WHERE id = ?
 		LIMIT 1
@@ -138,7 +155,7 @@ func (r *SQLiteRepository) GetChats(filter *domainChatStorage.ChatFilter) ([]*do
 	var args []any
 
 	query := `
-		SELECT c.device_id, c.jid, c.name, c.last_message_time, c.ephemeral_expiration, c.created_at, c.updated_at
+		SELECT c.device_id, c.jid, c.name, c.last_message_time, c.ephemeral_expiration, c.chat_type, c.created_at, c.updated_at
 		FROM chats c
 	`
 
@@ -257,11 +274,11 @@ func (r *SQLiteRepository) StoreMessage(message *domainChatStorage.Message) erro
 	result, err := r.db.Exec(`
 		UPDATE messages SET sender = ?, content = ?, timestamp = ?, is_from_me = ?,
 			media_type = ?, filename = ?, url = ?, media_key = ?, file_sha256 = ?,
-			file_enc_sha256 = ?, file_length = ?, updated_at = ?
+			file_enc_sha256 = ?, file_length = ?, is_view_once = ?, updated_at = ?
 		WHERE id = ? AND chat_jid = ? AND device_id = ?
 	`, message.Sender, message.Content, message.Timestamp, message.IsFromMe,
 		message.MediaType, message.Filename, message.URL, message.MediaKey, message.FileSHA256,
-		message.FileEncSHA256, message.FileLength, message.UpdatedAt,
+		message.FileEncSHA256, message.FileLength, message.IsViewOnce, message.UpdatedAt,
 		message.ID, message.ChatJID, message.DeviceID)
 	if err != nil {
 		return err
@@ -273,12 +290,12 @@ func (r *SQLiteRepository) StoreMessage(message *domainChatStorage.Message) erro
 			INSERT INTO messages (
 				id, chat_jid, device_id, sender, content, timestamp, is_from_me,
 				media_type, filename, url, media_key, file_sha256,
-				file_enc_sha256, file_length, created_at, updated_at
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				file_enc_sha256, file_length, is_view_once, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`, message.ID, message.ChatJID, message.DeviceID, message.Sender, message.Content,
 			message.Timestamp, message.IsFromMe, message.MediaType, message.Filename,
 			message.URL, message.MediaKey, message.FileSHA256, message.FileEncSHA256,
-			message.FileLength, message.CreatedAt, message.UpdatedAt)
+			message.FileLength, message.IsViewOnce, message.CreatedAt, message.UpdatedAt)
 	}
 	return err
 }
@@ -299,7 +316,7 @@ func (r *SQLiteRepository) StoreMessagesBatch(messages []*domainChatStorage.Mess
 	updateStmt, err := tx.Prepare(`
 		UPDATE messages SET sender = ?, content = ?, timestamp = ?, is_from_me = ?,
 			media_type = ?, filename = ?, url = ?, media_key = ?, file_sha256 = ?,
-			file_enc_sha256 = ?, file_length = ?, updated_at = ?
+			file_enc_sha256 = ?, file_length = ?, is_view_once = ?, updated_at = ?
 		WHERE id = ? AND chat_jid = ? AND device_id = ?
 	`)
 	if err != nil {
@@ -311,8 +328,8 @@ func (r *SQLiteRepository) StoreMessagesBatch(messages []*domainChatStorage.Mess
 		INSERT INTO messages (
 			id, chat_jid, device_id, sender, content, timestamp, is_from_me,
 			media_type, filename, url, media_key, file_sha256,
-			file_enc_sha256, file_length, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			file_enc_sha256, file_length, is_view_once, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %w", err)
@@ -331,7 +348,7 @@ func (r *SQLiteRepository) StoreMessagesBatch(messages []*domainChatStorage.Mess
 		result, err := updateStmt.Exec(
 			message.Sender, message.Content, message.Timestamp, message.IsFromMe,
 			message.MediaType, message.Filename, message.URL, message.MediaKey, message.FileSHA256,
-			message.FileEncSHA256, message.FileLength, message.UpdatedAt,
+			message.FileEncSHA256, message.FileLength, message.IsViewOnce, message.UpdatedAt,
 			message.ID, message.ChatJID, message.DeviceID,
 		)
 		if err != nil {
@@ -344,7 +361,7 @@ func (r *SQLiteRepository) StoreMessagesBatch(messages []*domainChatStorage.Mess
 				message.ID, message.ChatJID, message.DeviceID, message.Sender, message.Content,
 				message.Timestamp, message.IsFromMe, message.MediaType, message.Filename,
 				message.URL, message.MediaKey, message.FileSHA256, message.FileEncSHA256,
-				message.FileLength, message.CreatedAt, message.UpdatedAt,
+				message.FileLength, message.IsViewOnce, message.CreatedAt, message.UpdatedAt,
 			)
 			if err != nil {
 				return fmt.Errorf("failed to insert message %s: %w", message.ID, err)
@@ -355,6 +372,88 @@ func (r *SQLiteRepository) StoreMessagesBatch(messages []*domainChatStorage.Mess
 	return tx.Commit()
 }
 
+// messageInsertColumns are the columns CreateMessagesBatch fills, in the order of messageInsertArgs.
+const messageInsertColumns = `id, chat_jid, device_id, sender, content, timestamp, is_from_me,
+	media_type, filename, url, media_key, file_sha256,
+	file_enc_sha256, file_length, is_view_once, created_at, updated_at`
+
+// messageInsertChunk bounds the rows of one multi-row insert, keeping its 17 variables per row under
+// the 999 SQLite allows by default.
+const messageInsertChunk = 50
+
+// CreateMessagesBatch inserts messages with one multi-row statement per chunk, all in one
+// transaction. Messages already stored are left as they are. When a chunk fails on a constraint its
+// rows are inserted one at a time instead, so only the offending rows are skipped.
+func (r *SQLiteRepository) CreateMessagesBatch(ctx context.Context, messages []*domainChatStorage.Message) error {
+	now := time.Now()
+	rows := make([]*domainChatStorage.Message, 0, len(messages))
+	for _, message := range messages {
+		if message == nil || (message.Content == "" && message.MediaType == "") {
+			continue
+		}
+		message.CreatedAt = now
+		message.UpdatedAt = now
+		rows = append(rows, message)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(rows); start += messageInsertChunk {
+		chunk := rows[start:min(start+messageInsertChunk, len(rows))]
+		_, err := tx.ExecContext(ctx, insertMessagesQuery(len(chunk)), messageInsertArgs(chunk)...)
+		if err == nil {
+			continue
+		}
+		if !isConstraintError(err) {
+			return fmt.Errorf("failed to insert messages: %w", err)
+		}
+		for _, message := range chunk {
+			_, err := tx.ExecContext(ctx, insertMessagesQuery(1), messageInsertArgs([]*domainChatStorage.Message{message})...)
+			if err == nil {
+				continue
+			}
+			if !isConstraintError(err) {
+				return fmt.Errorf("failed to insert message %s: %w", message.ID, err)
+			}
+			logrus.Warnf("Skipping message %s of chat %s: %v", message.ID, message.ChatJID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertMessagesQuery builds an insert of rows messages that skips those already stored.
+func insertMessagesQuery(rows int) string {
+	placeholders := "(?" + strings.Repeat(", ?", 16) + ")"
+	return "INSERT INTO messages (" + messageInsertColumns + ") VALUES " + placeholders +
+		strings.Repeat(", "+placeholders, rows-1) + " ON CONFLICT (id, chat_jid, device_id) DO NOTHING"
+}
+
+func messageInsertArgs(messages []*domainChatStorage.Message) []any {
+	args := make([]any, 0, len(messages)*17)
+	for _, message := range messages {
+		args = append(args,
+			message.ID, message.ChatJID, message.DeviceID, message.Sender, message.Content,
+			message.Timestamp, message.IsFromMe, message.MediaType, message.Filename,
+			message.URL, message.MediaKey, message.FileSHA256, message.FileEncSHA256,
+			message.FileLength, message.IsViewOnce, message.CreatedAt, message.UpdatedAt,
+		)
+	}
+	return args
+}
+
+func isConstraintError(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint
+}
+
 // GetMessages retrieves messages with filtering
 func (r *SQLiteRepository) GetMessages(filter *domainChatStorage.MessageFilter) ([]*domainChatStorage.Message, error) {
 	// Require device_id for data isolation - fail fast if missing
@@ -391,13 +490,18 @@ func (r *SQLiteRepository) GetMessages(filter *domainChatStorage.MessageFilter)
 		args = append(args, *filter.IsFromMe)
 	}
 
+	order := "timestamp DESC"
+	if filter.OldestFirst {
+		// rowid breaks timestamp ties so offset pages neither skip nor repeat messages
+		order = "timestamp ASC, rowid ASC"
+	}
 	query := `
 		SELECT id, chat_jid, device_id, sender, content, timestamp, is_from_me,
 			media_type, filename, url, media_key, file_sha256,
-			file_enc_sha256, file_length, created_at, updated_at
+			file_enc_sha256, file_length, is_view_once, created_at, updated_at
 		FROM messages
 		WHERE ` + strings.Join(conditions, " AND ") + `
-		ORDER BY timestamp DESC
+		ORDER BY ` + order + `
 	`
 
 	// Safely add LIMIT and OFFSET using parameterized values
@@ -460,7 +564,7 @@ func (r *SQLiteRepository) SearchMessages(deviceID, chatJID, searchText string,
 	query := `
 		SELECT id, chat_jid, device_id, sender, content, timestamp, is_from_me,
 			media_type, filename, url, media_key, file_sha256,
-			file_enc_sha256, file_length, created_at, updated_at
+			file_enc_sha256, file_length, is_view_once, created_at, updated_at
 		FROM messages
 		WHERE ` + strings.Join(conditions, " AND ") + `
 		ORDER BY timestamp DESC
@@ -524,7 +628,7 @@ func (r *SQLiteRepository) scanMessage(scanner interface{ Scan(...any) error })
 		&message.ID, &message.ChatJID, &message.DeviceID, &message.Sender, &message.Content,
 		&message.Timestamp, &message.IsFromMe, &message.MediaType, &message.Filename,
 		&message.URL, &message.MediaKey, &message.FileSHA256, &message.FileEncSHA256,
-		&message.FileLength, &message.CreatedAt, &message.UpdatedAt,
+		&message.FileLength, &message.IsViewOnce, &message.CreatedAt, &message.UpdatedAt,
 	)
 	return message, err
 }
@@ -533,7 +637,7 @@ func (r *SQLiteRepository) scanMessage(scanner interface{ Scan(...any) error })
 func (r *SQLiteRepository) scanChat(scanner interface{ Scan(...any) error }) (*domainChatStorage.Chat, error) {
 	chat := &domainChatStorage.Chat{}
 	err := scanner.Scan(
-		&chat.DeviceID, &chat.JID, &chat.Name, &chat.LastMessageTime, &chat.EphemeralExpiration,
+		&chat.DeviceID, &chat.JID, &chat.Name, &chat.LastMessageTime, &chat.EphemeralExpiration, &chat.Type,
 		&chat.CreatedAt, &chat.UpdatedAt,
 	)
 	return chat, err
@@ -694,11 +798,26 @@ func (r *SQLiteRepository) DeleteDeviceRecord(deviceID string) error {
 	return err
 }
 
+// cachedGroupName returns the group subject held by the shared group info service, or the name of a
+// channel, without asking WhatsApp.
+func cachedGroupName(jid types.JID, chatJID string) (string, bool) {
+	switch jid.Server {
+	case types.GroupServer:
+		return whatsapp.GetGroupInfoService().Cached(chatJID)
+	case types.NewsletterServer:
+		return whatsapp.CachedNewsletterName(chatJID)
+	}
+	return "", false
+}
+
 // GetChatNameWithPushName determines the appropriate name for a chat with pushname support
 func (r *SQLiteRepository) GetChatNameWithPushName(jid types.JID, chatJID string, senderUser string, pushName string) string {
 	// First, check if chat already exists with a name
 	existingChat, err := r.GetChat(chatJID)
 	if err == nil && existingChat != nil && existingChat.Name != "" {
+		if name, ok := cachedGroupName(jid, chatJID); ok {
+			return name
+		}
 		// If we have a pushname and the existing name is just a phone number/JID user, update it
 		if pushName != "" && (existingChat.Name == jid.User || existingChat.Name == senderUser) {
 			return pushName
@@ -711,12 +830,19 @@ func (r *SQLiteRepository) GetChatNameWithPushName(jid types.JID, chatJID string
 
 	switch jid.Server {
 	case "g.us":
-		// This is a group chat
-		// For now, use a generic name - this can be enhanced later with group info
-		name = fmt.Sprintf("Group %s", jid.User)
+		// This is a group chat; the subject is known once any consumer looked it up
+		if cached, ok := cachedGroupName(jid, chatJID); ok {
+			name = cached
+		} else {
+			name = fmt.Sprintf("Group %s", jid.User)
+		}
 	case "newsletter":
-		// This is a newsletter/channel
-		name = fmt.Sprintf("Newsletter %s", jid.User)
+		// This is a newsletter/channel; its name is known once any consumer looked it up
+		if cached, ok := cachedGroupName(jid, chatJID); ok {
+			name = cached
+		} else {
+			name = fmt.Sprintf("Newsletter %s", jid.User)
+		}
 	default:
 		// This is an individual contact
 		// Priority: pushName > senderUser > JID user
@@ -742,6 +868,9 @@ func (r *SQLiteRepository) GetChatNameWithPushNameByDevice(deviceID string, jid
 	// First, check if chat already exists with a name (device-scoped!)
 	existingChat, err := r.GetChatByDevice(deviceID, chatJID)
 	if err == nil && existingChat != nil && existingChat.Name != "" {
+		if name, ok := cachedGroupName(jid, chatJID); ok {
+			return name
+		}
 		// If we have a pushname and the existing name is just a phone number/JID user, update it
 		if pushName != "" && (existingChat.Name == jid.User || existingChat.Name == senderUser) {
 			return pushName
@@ -754,12 +883,19 @@ func (r *SQLiteRepository) GetChatNameWithPushNameByDevice(deviceID string, jid
 
 	switch jid.Server {
 	case "g.us":
-		// This is a group chat
-		// For now, use a generic name - this can be enhanced later with group info
-		name = fmt.Sprintf("Group %s", jid.User)
+		// This is a group chat; the subject is known once any consumer looked it up
+		if cached, ok := cachedGroupName(jid, chatJID); ok {
+			name = cached
+		} else {
+			name = fmt.Sprintf("Group %s", jid.User)
+		}
 	case "newsletter":
-		// This is a newsletter/channel
-		name = fmt.Sprintf("Newsletter %s", jid.User)
+		// This is a newsletter/channel; its name is known once any consumer looked it up
+		if cached, ok := cachedGroupName(jid, chatJID); ok {
+			name = cached
+		} else {
+			name = fmt.Sprintf("Newsletter %s", jid.User)
+		}
 	default:
 		// This is an individual contact
 		// Priority: pushName > senderUser > JID user
@@ -860,6 +996,7 @@ func (r *SQLiteRepository) CreateMessage(ctx context.Context, evt *events.Messag
 		FileSHA256:    fileSHA256,
 		FileEncSHA256: fileEncSHA256,
 		FileLength:    fileLength,
+		IsViewOnce:    evt.IsViewOnce || utils.IsViewOnceMessage(evt.Message),
 	}
 
 	// Store the message
@@ -1044,6 +1181,39 @@ func (r *SQLiteRepository) getSchemaVersion() (int, error) {
 	return version, nil
 }
 
+// GetDBStats reports the connection pool of the database.
+func (r *SQLiteRepository) GetDBStats() domainChatStorage.DBStats {
+	stats := r.db.Stats()
+	return domainChatStorage.DBStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
+
+// GetSchemaVersion reports the last migration applied and how many this build has yet to apply.
+func (r *SQLiteRepository) GetSchemaVersion() (*domainChatStorage.SchemaVersion, error) {
+	version, err := r.getSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	latest := len(r.getMigrations())
+	info := &domainChatStorage.SchemaVersion{Version: version, Latest: latest, Pending: max(latest-version, 0)}
+	if version > 0 {
+		var updatedAt time.Time
+		if err := r.db.QueryRow("SELECT updated_at FROM schema_info WHERE version = ?", version).Scan(&updatedAt); err == nil {
+			info.UpdatedAt = &updatedAt
+		}
+	}
+	return info, nil
+}
+
 // runMigration executes a migration
 func (r *SQLiteRepository) runMigration(migration string, version int) error {
 	// Execute migration (single statement)
@@ -1155,6 +1325,211 @@ func (r *SQLiteRepository) getMigrations() []string {
 		// Migration 15: index by chatwoot_message_id
 		`CREATE INDEX IF NOT EXISTS idx_chatwoot_exported_messages_chatwoot_id
   ON chatwoot_exported_messages (chatwoot_message_id)`,
+
+		// Migration 16: Durable queue for failed webhook deliveries
+		`CREATE TABLE IF NOT EXISTS webhook_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			url TEXT NOT NULL,
+			event VARCHAR(100) DEFAULT '',
+			payload TEXT NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			next_attempt_at TIMESTAMP NOT NULL,
+			last_error TEXT DEFAULT '',
+			status VARCHAR(20) DEFAULT 'pending',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Migration 17
+		`CREATE INDEX IF NOT EXISTS idx_webhook_outbox_status_next ON webhook_outbox(status, next_attempt_at)`,
+
+		// Migration 18: Chatwoot CSAT surveys awaiting a numeric reply
+		`CREATE TABLE IF NOT EXISTS chatwoot_pending_surveys (
+			identifier TEXT PRIMARY KEY,
+			conversation_id INTEGER NOT NULL,
+			survey_uuid TEXT NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Migration 19: Events held back during maintenance mode
+		`CREATE TABLE IF NOT EXISTS maintenance_buffer (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target VARCHAR(20) NOT NULL,
+			event VARCHAR(100) DEFAULT '',
+			payload TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Migration 20: Per-chat auto-replies set from Chatwoot
+		`CREATE TABLE IF NOT EXISTS chat_auto_replies (
+			device_id VARCHAR(255) NOT NULL,
+			chat_jid VARCHAR(255) NOT NULL,
+			message TEXT NOT NULL,
+			conversation_id INTEGER DEFAULT 0,
+			expires_at TIMESTAMP NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (device_id, chat_jid)
+		)`,
+
+		// Migration 21: Polls, kept to resolve the option hashes in votes
+		`CREATE TABLE IF NOT EXISTS polls (
+			message_id VARCHAR(255) PRIMARY KEY,
+			chat_jid VARCHAR(255) NOT NULL,
+			question TEXT NOT NULL,
+			options TEXT NOT NULL,
+			selectable_count INTEGER DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Migration 22: Latest vote of each voter per poll
+		`CREATE TABLE IF NOT EXISTS poll_votes (
+			poll_message_id VARCHAR(255) NOT NULL,
+			voter_jid VARCHAR(255) NOT NULL,
+			options TEXT NOT NULL,
+			voted_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (poll_message_id, voter_jid)
+		)`,
+
+		// Migration 23: Chatwoot account/inbox fingerprint of the stored Chatwoot IDs
+		`CREATE TABLE IF NOT EXISTS chatwoot_settings (
+			key VARCHAR(100) PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Migration 24: View-once media, forwarded to Chatwoot according to CHATWOOT_FORWARD_VIEW_ONCE
+		`ALTER TABLE messages ADD COLUMN is_view_once BOOLEAN DEFAULT FALSE`,
+
+		// Migration 25: Chatwoot conversation of each WhatsApp message sent from Chatwoot
+		`CREATE TABLE IF NOT EXISTS chatwoot_sent_messages (
+			message_id VARCHAR(255) PRIMARY KEY,
+			conversation_id INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_chatwoot_sent_messages_created ON chatwoot_sent_messages(created_at)`,
+
+		// Migration 26: Chatwoot history sync runs, so progress and past runs survive a restart
+		`CREATE TABLE IF NOT EXISTS chatwoot_sync_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id VARCHAR(255) NOT NULL,
+			status VARCHAR(20) NOT NULL,
+			total_chats INTEGER DEFAULT 0,
+			synced_chats INTEGER DEFAULT 0,
+			failed_chats INTEGER DEFAULT 0,
+			total_messages INTEGER DEFAULT 0,
+			synced_messages INTEGER DEFAULT 0,
+			failed_messages INTEGER DEFAULT 0,
+			error TEXT DEFAULT '',
+			started_at TIMESTAMP NOT NULL,
+			finished_at TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_chatwoot_sync_runs_device ON chatwoot_sync_runs(device_id, started_at)`,
+
+		// Migration 27: messages the history sync failed to export, for POST /chatwoot/sync/retry-failed
+		`CREATE TABLE IF NOT EXISTS chatwoot_failed_exports (
+			device_id VARCHAR(255) NOT NULL,
+			chat_jid VARCHAR(255) NOT NULL,
+			message_id VARCHAR(255) NOT NULL,
+			message_key VARCHAR(255) NOT NULL,
+			reason VARCHAR(32) NOT NULL,
+			error TEXT DEFAULT '',
+			attempts INTEGER NOT NULL DEFAULT 1,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (device_id, chat_jid, message_key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_chatwoot_failed_exports_created ON chatwoot_failed_exports(created_at)`,
+
+		// Migration 28: the Chatwoot message a WhatsApp message was sent for, so webhook retries are not sent again
+		`ALTER TABLE chatwoot_sent_messages ADD COLUMN chatwoot_message_id INTEGER NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_chatwoot_sent_messages_chatwoot_id ON chatwoot_sent_messages(chatwoot_message_id)`,
+
+		// Migration 29: Chatwoot contact used for each WhatsApp identifier, by account
+		`CREATE TABLE IF NOT EXISTS chatwoot_contact_ids (
+			account VARCHAR(255) NOT NULL,
+			identifier VARCHAR(255) NOT NULL,
+			contact_id INTEGER NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (account, identifier)
+		)`,
+
+		// Migration 30: LID to phone number mappings, to link Chatwoot contacts made for either
+		`CREATE TABLE IF NOT EXISTS lid_mappings (
+			lid VARCHAR(255) PRIMARY KEY,
+			phone_jid VARCHAR(255) NOT NULL,
+			chatwoot_linked_at TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lid_mappings_phone ON lid_mappings(phone_jid)`,
+
+		// Migration 31: Chatwoot inboxes provisioned per device by POST /chatwoot/setup
+		`CREATE TABLE IF NOT EXISTS chatwoot_inboxes (
+			device_id VARCHAR(255) PRIMARY KEY,
+			inbox_id INTEGER NOT NULL,
+			inbox_name TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+
+		// Migration 32: audit trail of Chatwoot bridging decisions, for GET /chatwoot/audit
+		`CREATE TABLE IF NOT EXISTS chatwoot_audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id VARCHAR(255) NOT NULL DEFAULT '',
+			chat_jid VARCHAR(255) NOT NULL,
+			message_id VARCHAR(255) NOT NULL DEFAULT '',
+			direction VARCHAR(32) NOT NULL,
+			decision VARCHAR(16) NOT NULL,
+			reason VARCHAR(64) NOT NULL DEFAULT '',
+			chatwoot_message_id INTEGER NOT NULL DEFAULT 0,
+			detail TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_chatwoot_audit_log_chat ON chatwoot_audit_log(chat_jid, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_chatwoot_audit_log_created ON chatwoot_audit_log(created_at)`,
+
+		// Migration 33: full-text index of message content for GET /messages/search. Each document
+		// has the rowid of its message as docid and is kept in step by the triggers below.
+		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts4(content, tokenize=unicode61 "remove_diacritics=1")`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages
+		WHEN COALESCE(new.content, '') != '' BEGIN
+			INSERT INTO messages_fts(docid, content) VALUES (new.rowid, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+			DELETE FROM messages_fts WHERE docid = old.rowid;
+			INSERT INTO messages_fts(docid, content) SELECT new.rowid, new.content WHERE COALESCE(new.content, '') != '';
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+			DELETE FROM messages_fts WHERE docid = old.rowid;
+		END`,
+		`INSERT INTO messages_fts(docid, content)
+		SELECT rowid, content FROM messages
+		WHERE COALESCE(content, '') != '' AND rowid NOT IN (SELECT docid FROM messages_fts)`,
+
+		// Migration 34: delivery and read receipts of sent messages, one row per recipient
+		`CREATE TABLE IF NOT EXISTS message_receipts (
+			device_id VARCHAR(255) NOT NULL,
+			chat_jid VARCHAR(255) NOT NULL,
+			message_id VARCHAR(255) NOT NULL,
+			participant VARCHAR(255) NOT NULL,
+			delivered_at TIMESTAMP,
+			read_at TIMESTAMP,
+			PRIMARY KEY (device_id, message_id, participant)
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS message_receipts_delete AFTER DELETE ON messages BEGIN
+			DELETE FROM message_receipts WHERE device_id = old.device_id AND message_id = old.id;
+		END`,
+
+		// Migration 35: chat each message sent from Chatwoot went to
+		`ALTER TABLE chatwoot_sent_messages ADD COLUMN chat_jid VARCHAR(255) NOT NULL DEFAULT ''`,
+
+		// Migration 36: chat type, so channels can be told apart from people and groups
+		`ALTER TABLE chats ADD COLUMN chat_type VARCHAR(16) NOT NULL DEFAULT 'user'`,
+		`UPDATE chats SET chat_type = CASE
+			WHEN jid LIKE '%@g.us' THEN 'group'
+			WHEN jid LIKE '%@newsletter' THEN 'newsletter'
+			WHEN jid LIKE '%@broadcast' THEN 'broadcast'
+			ELSE 'user' END`,
 	}
 }
 func (r *SQLiteRepository) GetChatExportState(deviceID, chatJID string) (*domainChatStorage.ChatExportState, error) {
@@ -1231,11 +1606,30 @@ func (r *SQLiteRepository) MarkMessageExported(deviceID, chatJID, messageKey str
 	_, err := r.db.Exec(`
 INSERT INTO chatwoot_exported_messages (device_id, chat_jid, message_key, chatwoot_message_id, created_at)
 VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
-ON CONFLICT(device_id, chat_jid, message_key) DO NOTHING
+ON CONFLICT(device_id, chat_jid, message_key) DO UPDATE SET chatwoot_message_id = excluded.chatwoot_message_id
 `, deviceID, chatJID, messageKey, chatwootMessageID)
 	return err
 }
 
+func (r *SQLiteRepository) GetExportedChatwootMessageID(deviceID, chatJID, messageKey string) (int, error) {
+	row := r.db.QueryRow(`
+SELECT chatwoot_message_id
+FROM chatwoot_exported_messages
+WHERE device_id = ? AND chat_jid = ? AND message_key = ?
+LIMIT 1
+`, deviceID, chatJID, messageKey)
+
+	var id int
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
 func (r *SQLiteRepository) IsChatwootMessageFromUs(chatwootMessageID int) (bool, error) {
 	row := r.db.QueryRow(`
 SELECT 1
@@ -1254,3 +1648,1227 @@ LIMIT 1
 	}
 	return true, nil
 }
+
+// GetChatwootFingerprint returns the fingerprint saved by SaveChatwootFingerprint, or "" when none was saved yet.
+func (r *SQLiteRepository) GetChatwootFingerprint() (string, error) {
+	var fingerprint string
+	err := r.db.QueryRow(`SELECT value FROM chatwoot_settings WHERE key = 'fingerprint'`).Scan(&fingerprint)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return fingerprint, err
+}
+
+// SaveChatwootFingerprint records the Chatwoot account/inbox the stored Chatwoot IDs belong to.
+func (r *SQLiteRepository) SaveChatwootFingerprint(fingerprint string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO chatwoot_settings (key, value, updated_at)
+		VALUES ('fingerprint', ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+	`, fingerprint)
+	return err
+}
+
+// GetChatwootConfig returns the configuration saved by SaveChatwootConfig, or nil when none was saved yet.
+func (r *SQLiteRepository) GetChatwootConfig() (*domainChatStorage.ChatwootConfig, error) {
+	var value string
+	var updatedAt time.Time
+	err := r.db.QueryRow(`SELECT value, updated_at FROM chatwoot_settings WHERE key = 'config'`).Scan(&value, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg domainChatStorage.ChatwootConfig
+	if err := json.Unmarshal([]byte(value), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid stored Chatwoot config: %w", err)
+	}
+	cfg.UpdatedAt = updatedAt
+	return &cfg, nil
+}
+
+// SaveChatwootConfig stores the Chatwoot configuration set at runtime, replacing the previous one.
+func (r *SQLiteRepository) SaveChatwootConfig(cfg *domainChatStorage.ChatwootConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("chatwoot config is required")
+	}
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		INSERT INTO chatwoot_settings (key, value, updated_at)
+		VALUES ('config', ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+	`, string(value))
+	return err
+}
+
+// ClearChatwootState forgets every Chatwoot ID recorded for the current account/inbox: exported
+// message IDs, per-chat export progress, pending CSAT surveys, the conversations of sent messages and
+// the contacts of WhatsApp identifiers.
+// It returns the number of exported message rows removed.
+func (r *SQLiteRepository) ClearChatwootState() (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM chatwoot_exported_messages`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear exported messages: %w", err)
+	}
+	removed, _ := result.RowsAffected()
+
+	if _, err := tx.Exec(`DELETE FROM chatwoot_export_state`); err != nil {
+		return 0, fmt.Errorf("failed to clear export state: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM chatwoot_failed_exports`); err != nil {
+		return 0, fmt.Errorf("failed to clear failed exports: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM chatwoot_pending_surveys`); err != nil {
+		return 0, fmt.Errorf("failed to clear pending surveys: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM chatwoot_sent_messages`); err != nil {
+		return 0, fmt.Errorf("failed to clear sent messages: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM chatwoot_contact_ids`); err != nil {
+		return 0, fmt.Errorf("failed to clear contact IDs: %w", err)
+	}
+	return removed, tx.Commit()
+}
+
+// SaveChatwootSentMessage records the chat, Chatwoot conversation and Chatwoot message a WhatsApp
+// message was sent for. Records are kept for as long as the message can be edited, deleted or
+// quoted, so they are not pruned.
+func (r *SQLiteRepository) SaveChatwootSentMessage(sent *domainChatStorage.ChatwootSentMessage) error {
+	if sent == nil || sent.MessageID == "" || sent.ConversationID == 0 {
+		return nil
+	}
+	if sent.CreatedAt.IsZero() {
+		sent.CreatedAt = time.Now().UTC()
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO chatwoot_sent_messages (message_id, chat_jid, conversation_id, chatwoot_message_id, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(message_id) DO UPDATE SET
+			chat_jid = excluded.chat_jid, conversation_id = excluded.conversation_id,
+			chatwoot_message_id = excluded.chatwoot_message_id
+	`, sent.MessageID, sent.ChatJID, sent.ConversationID, sent.ChatwootMessageID, sent.CreatedAt)
+	return err
+}
+
+// GetChatwootSentMessageConversation returns the Chatwoot conversation messageID was sent from, or 0.
+func (r *SQLiteRepository) GetChatwootSentMessageConversation(messageID string) (int, error) {
+	var conversationID int
+	err := r.db.QueryRow(`SELECT conversation_id FROM chatwoot_sent_messages WHERE message_id = ?`, messageID).Scan(&conversationID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return conversationID, err
+}
+
+// GetChatwootSentMessageIDs returns the WhatsApp messages sent for a Chatwoot message, oldest first.
+// A message with attachments is sent as one WhatsApp message per attachment.
+func (r *SQLiteRepository) GetChatwootSentMessageIDs(chatwootMessageID int) ([]string, error) {
+	sent, err := r.GetChatwootSentMessages(chatwootMessageID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, msg := range sent {
+		ids = append(ids, msg.MessageID)
+	}
+	return ids, nil
+}
+
+// GetChatwootSentMessages returns the WhatsApp messages sent for a Chatwoot message with the chat
+// each went to, oldest first.
+func (r *SQLiteRepository) GetChatwootSentMessages(chatwootMessageID int) ([]*domainChatStorage.ChatwootSentMessage, error) {
+	if chatwootMessageID == 0 {
+		return nil, nil
+	}
+	rows, err := r.db.Query(`
+		SELECT message_id, chat_jid, conversation_id, chatwoot_message_id, created_at FROM chatwoot_sent_messages
+		WHERE chatwoot_message_id = ?
+		ORDER BY created_at ASC
+	`, chatwootMessageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sent []*domainChatStorage.ChatwootSentMessage
+	for rows.Next() {
+		msg := &domainChatStorage.ChatwootSentMessage{}
+		if err := rows.Scan(&msg.MessageID, &msg.ChatJID, &msg.ConversationID, &msg.ChatwootMessageID, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		sent = append(sent, msg)
+	}
+	return sent, rows.Err()
+}
+
+// GetChatwootContactID returns the Chatwoot contact recorded for identifier in account, 0 when none.
+func (r *SQLiteRepository) GetChatwootContactID(account, identifier string) (int, error) {
+	var contactID int
+	err := r.db.QueryRow(`SELECT contact_id FROM chatwoot_contact_ids WHERE account = ? AND identifier = ?`, account, identifier).Scan(&contactID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return contactID, err
+}
+
+// SaveChatwootContactID records the Chatwoot contact of identifier in account, replacing any earlier one.
+func (r *SQLiteRepository) SaveChatwootContactID(account, identifier string, contactID int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO chatwoot_contact_ids (account, identifier, contact_id, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(account, identifier) DO UPDATE SET
+			contact_id = excluded.contact_id,
+			updated_at = excluded.updated_at
+	`, account, identifier, contactID, time.Now().UTC())
+	return err
+}
+
+// DeleteChatwootContactID forgets the Chatwoot contact of identifier in account.
+func (r *SQLiteRepository) DeleteChatwootContactID(account, identifier string) error {
+	_, err := r.db.Exec(`DELETE FROM chatwoot_contact_ids WHERE account = ? AND identifier = ?`, account, identifier)
+	return err
+}
+
+const chatwootSyncRunColumns = `id, device_id, status, total_chats, synced_chats, failed_chats, total_messages, synced_messages, failed_messages, error, started_at, finished_at, updated_at`
+
+// CreateChatwootSyncRun records the start of a history sync and sets run.ID to the new row id.
+func (r *SQLiteRepository) CreateChatwootSyncRun(run *domainChatStorage.ChatwootSyncRun) error {
+	if run == nil || strings.TrimSpace(run.DeviceID) == "" {
+		return fmt.Errorf("sync run requires a device id")
+	}
+	if run.StartedAt.IsZero() {
+		run.StartedAt = time.Now()
+	}
+	run.UpdatedAt = time.Now().UTC()
+
+	res, err := r.db.Exec(`
+		INSERT INTO chatwoot_sync_runs (device_id, status, total_chats, synced_chats, failed_chats, total_messages, synced_messages, failed_messages, error, started_at, finished_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.DeviceID, run.Status, run.TotalChats, run.SyncedChats, run.FailedChats, run.TotalMessages, run.SyncedMessages, run.FailedMessages,
+		run.Error, run.StartedAt.UTC(), nullableUTC(run.FinishedAt), run.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	run.ID, err = res.LastInsertId()
+	return err
+}
+
+// UpdateChatwootSyncRun saves the status and counters of a run created by CreateChatwootSyncRun.
+func (r *SQLiteRepository) UpdateChatwootSyncRun(run *domainChatStorage.ChatwootSyncRun) error {
+	if run == nil || run.ID == 0 {
+		return fmt.Errorf("sync run requires an id")
+	}
+	run.UpdatedAt = time.Now().UTC()
+
+	_, err := r.db.Exec(`
+		UPDATE chatwoot_sync_runs
+		SET status = ?, total_chats = ?, synced_chats = ?, failed_chats = ?, total_messages = ?, synced_messages = ?, failed_messages = ?,
+			error = ?, finished_at = ?, updated_at = ?
+		WHERE id = ?
+	`, run.Status, run.TotalChats, run.SyncedChats, run.FailedChats, run.TotalMessages, run.SyncedMessages, run.FailedMessages,
+		run.Error, nullableUTC(run.FinishedAt), run.UpdatedAt, run.ID)
+	return err
+}
+
+// GetChatwootSyncRuns returns the latest sync runs of a device, newest first.
+func (r *SQLiteRepository) GetChatwootSyncRuns(deviceID string, limit int) ([]*domainChatStorage.ChatwootSyncRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := r.db.Query(`
+		SELECT `+chatwootSyncRunColumns+`
+		FROM chatwoot_sync_runs
+		WHERE device_id = ?
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?
+	`, deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*domainChatStorage.ChatwootSyncRun
+	for rows.Next() {
+		run := &domainChatStorage.ChatwootSyncRun{}
+		var finishedAt sql.NullTime
+		if err := rows.Scan(
+			&run.ID, &run.DeviceID, &run.Status, &run.TotalChats, &run.SyncedChats, &run.FailedChats,
+			&run.TotalMessages, &run.SyncedMessages, &run.FailedMessages, &run.Error, &run.StartedAt, &finishedAt, &run.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// MarkInterruptedChatwootSyncRuns marks runs still recorded as running, which a previous process
+// left behind when it stopped, as interrupted.
+func (r *SQLiteRepository) MarkInterruptedChatwootSyncRuns() (int64, error) {
+	now := time.Now().UTC()
+	res, err := r.db.Exec(`
+		UPDATE chatwoot_sync_runs SET status = ?, finished_at = ?, updated_at = ? WHERE status = ?
+	`, domainChatStorage.ChatwootSyncInterrupted, now, now, domainChatStorage.ChatwootSyncRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SaveChatwootFailedExport records a message the history sync could not export. A message that
+// failed before keeps its first failure time and counts one more attempt.
+func (r *SQLiteRepository) SaveChatwootFailedExport(failure *domainChatStorage.ChatwootFailedExport) error {
+	if failure == nil || failure.DeviceID == "" || failure.MessageKey == "" {
+		return fmt.Errorf("failed export requires a device id and message key")
+	}
+	now := time.Now().UTC()
+	_, err := r.db.Exec(`
+		INSERT INTO chatwoot_failed_exports (device_id, chat_jid, message_id, message_key, reason, error, attempts, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT(device_id, chat_jid, message_key) DO UPDATE SET
+			reason = excluded.reason, error = excluded.error, attempts = attempts + 1, updated_at = excluded.updated_at
+	`, failure.DeviceID, failure.ChatJID, failure.MessageID, failure.MessageKey, failure.Reason, failure.Error, now, now)
+	return err
+}
+
+// DeleteChatwootFailedExport forgets a failed message, e.g. once it was exported.
+func (r *SQLiteRepository) DeleteChatwootFailedExport(deviceID, chatJID, messageKey string) error {
+	_, err := r.db.Exec(`DELETE FROM chatwoot_failed_exports WHERE device_id = ? AND chat_jid = ? AND message_key = ?`, deviceID, chatJID, messageKey)
+	return err
+}
+
+// GetChatwootFailedExports returns the failed messages of a device, oldest first.
+func (r *SQLiteRepository) GetChatwootFailedExports(deviceID string, limit int) ([]*domainChatStorage.ChatwootFailedExport, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	rows, err := r.db.Query(`
+		SELECT device_id, chat_jid, message_id, message_key, reason, error, attempts, created_at, updated_at
+		FROM chatwoot_failed_exports
+		WHERE device_id = ?
+		ORDER BY created_at ASC
+		LIMIT ?
+	`, deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []*domainChatStorage.ChatwootFailedExport
+	for rows.Next() {
+		f := &domainChatStorage.ChatwootFailedExport{}
+		if err := rows.Scan(&f.DeviceID, &f.ChatJID, &f.MessageID, &f.MessageKey, &f.Reason, &f.Error, &f.Attempts, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+// CountChatwootFailedExports returns how many messages of a device failed, by reason.
+func (r *SQLiteRepository) CountChatwootFailedExports(deviceID string) (map[string]int, error) {
+	rows, err := r.db.Query(`SELECT reason, COUNT(*) FROM chatwoot_failed_exports WHERE device_id = ? GROUP BY reason`, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var reason string
+		var n int
+		if err := rows.Scan(&reason, &n); err != nil {
+			return nil, err
+		}
+		counts[reason] = n
+	}
+	return counts, rows.Err()
+}
+
+// PurgeChatwootFailedExports drops failed messages that first failed before olderThan.
+func (r *SQLiteRepository) PurgeChatwootFailedExports(olderThan time.Time) (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM chatwoot_failed_exports WHERE created_at < ?`, olderThan.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func nullableUTC(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+const webhookOutboxColumns = `id, url, event, payload, attempts, next_attempt_at, last_error, status, created_at, updated_at`
+
+// EnqueueWebhookOutbox stores a failed webhook delivery and sets entry.ID to the new row id.
+func (r *SQLiteRepository) EnqueueWebhookOutbox(entry *domainChatStorage.WebhookOutboxEntry) error {
+	if entry == nil || strings.TrimSpace(entry.URL) == "" {
+		return fmt.Errorf("webhook outbox entry requires a url")
+	}
+
+	now := time.Now().UTC()
+	if entry.Status == "" {
+		entry.Status = domainChatStorage.WebhookOutboxPending
+	}
+	if entry.NextAttemptAt.IsZero() {
+		entry.NextAttemptAt = now
+	}
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+
+	res, err := r.db.Exec(`
+		INSERT INTO webhook_outbox (url, event, payload, attempts, next_attempt_at, last_error, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.URL, entry.Event, entry.Payload, entry.Attempts, entry.NextAttemptAt.UTC(), entry.LastError, entry.Status, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+	return nil
+}
+
+// GetDueWebhookOutbox returns pending entries whose next attempt is at or before now, oldest first.
+func (r *SQLiteRepository) GetDueWebhookOutbox(now time.Time, limit int) ([]*domainChatStorage.WebhookOutboxEntry, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.db.Query(`
+		SELECT `+webhookOutboxColumns+`
+		FROM webhook_outbox
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?
+	`, domainChatStorage.WebhookOutboxPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanWebhookOutboxRows(rows)
+}
+
+// GetWebhookOutboxEntry returns a single outbox entry, or nil when it does not exist.
+func (r *SQLiteRepository) GetWebhookOutboxEntry(id int64) (*domainChatStorage.WebhookOutboxEntry, error) {
+	rows, err := r.db.Query(`SELECT `+webhookOutboxColumns+` FROM webhook_outbox WHERE id = ? LIMIT 1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries, err := scanWebhookOutboxRows(rows)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// ListWebhookOutbox lists outbox entries with the given status (all statuses when empty), newest first.
+func (r *SQLiteRepository) ListWebhookOutbox(status string, limit int) ([]*domainChatStorage.WebhookOutboxEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `SELECT ` + webhookOutboxColumns + ` FROM webhook_outbox`
+	var args []any
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY updated_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanWebhookOutboxRows(rows)
+}
+
+// UpdateWebhookOutbox persists the retry state of an outbox entry.
+func (r *SQLiteRepository) UpdateWebhookOutbox(entry *domainChatStorage.WebhookOutboxEntry) error {
+	if entry == nil {
+		return fmt.Errorf("webhook outbox entry is required")
+	}
+	entry.UpdatedAt = time.Now().UTC()
+	_, err := r.db.Exec(`
+		UPDATE webhook_outbox
+		SET attempts = ?, next_attempt_at = ?, last_error = ?, status = ?, updated_at = ?
+		WHERE id = ?
+	`, entry.Attempts, entry.NextAttemptAt.UTC(), entry.LastError, entry.Status, entry.UpdatedAt, entry.ID)
+	return err
+}
+
+// DeleteWebhookOutbox removes an outbox entry, typically after a successful retry.
+func (r *SQLiteRepository) DeleteWebhookOutbox(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM webhook_outbox WHERE id = ?`, id)
+	return err
+}
+
+// CountWebhookOutbox returns the number of outbox entries per status.
+func (r *SQLiteRepository) CountWebhookOutbox() (map[string]int64, error) {
+	rows, err := r.db.Query(`SELECT status, COUNT(*) FROM webhook_outbox GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{
+		domainChatStorage.WebhookOutboxPending: 0,
+		domainChatStorage.WebhookOutboxDead:    0,
+	}
+	for rows.Next() {
+		var (
+			status string
+			count  int64
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+func scanWebhookOutboxRows(rows *sql.Rows) ([]*domainChatStorage.WebhookOutboxEntry, error) {
+	var entries []*domainChatStorage.WebhookOutboxEntry
+	for rows.Next() {
+		entry := &domainChatStorage.WebhookOutboxEntry{}
+		if err := rows.Scan(
+			&entry.ID, &entry.URL, &entry.Event, &entry.Payload, &entry.Attempts,
+			&entry.NextAttemptAt, &entry.LastError, &entry.Status, &entry.CreatedAt, &entry.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// SavePendingCSATSurvey records the latest survey sent to a contact, replacing any earlier one.
+func (r *SQLiteRepository) SavePendingCSATSurvey(survey *domainChatStorage.PendingCSATSurvey) error {
+	if survey == nil || strings.TrimSpace(survey.Identifier) == "" {
+		return fmt.Errorf("pending survey requires an identifier")
+	}
+	survey.CreatedAt = time.Now().UTC()
+
+	_, err := r.db.Exec(`
+		INSERT INTO chatwoot_pending_surveys (identifier, conversation_id, survey_uuid, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(identifier) DO UPDATE SET
+			conversation_id = excluded.conversation_id,
+			survey_uuid = excluded.survey_uuid,
+			expires_at = excluded.expires_at,
+			created_at = excluded.created_at
+	`, survey.Identifier, survey.ConversationID, survey.SurveyUUID, survey.ExpiresAt.UTC(), survey.CreatedAt)
+	return err
+}
+
+// GetPendingCSATSurvey returns the survey awaiting a reply from identifier, or nil when there is
+// none or it expired before now.
+func (r *SQLiteRepository) GetPendingCSATSurvey(identifier string, now time.Time) (*domainChatStorage.PendingCSATSurvey, error) {
+	var survey domainChatStorage.PendingCSATSurvey
+	err := r.db.QueryRow(`
+		SELECT identifier, conversation_id, survey_uuid, expires_at, created_at
+		FROM chatwoot_pending_surveys
+		WHERE identifier = ? AND expires_at > ?
+	`, identifier, now.UTC()).Scan(&survey.Identifier, &survey.ConversationID, &survey.SurveyUUID, &survey.ExpiresAt, &survey.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// DeletePendingCSATSurvey removes the survey awaiting a reply from identifier.
+func (r *SQLiteRepository) DeletePendingCSATSurvey(identifier string) error {
+	_, err := r.db.Exec(`DELETE FROM chatwoot_pending_surveys WHERE identifier = ?`, identifier)
+	return err
+}
+
+// SaveLIDMapping records the phone number JID of a LID. A mapping that changed phone number has to
+// be linked in Chatwoot again.
+func (r *SQLiteRepository) SaveLIDMapping(lid, phoneJID string) error {
+	if strings.TrimSpace(lid) == "" || strings.TrimSpace(phoneJID) == "" {
+		return fmt.Errorf("LID mapping requires a LID and a phone number")
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO lid_mappings (lid, phone_jid, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(lid) DO UPDATE SET
+			phone_jid = excluded.phone_jid,
+			chatwoot_linked_at = CASE WHEN lid_mappings.phone_jid = excluded.phone_jid THEN lid_mappings.chatwoot_linked_at ELSE NULL END,
+			updated_at = excluded.updated_at
+	`, lid, phoneJID, time.Now().UTC())
+	return err
+}
+
+// GetPhoneForLID returns the phone number JID recorded for lid, or "".
+func (r *SQLiteRepository) GetPhoneForLID(lid string) (string, error) {
+	var phoneJID string
+	err := r.db.QueryRow(`SELECT phone_jid FROM lid_mappings WHERE lid = ?`, lid).Scan(&phoneJID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return phoneJID, err
+}
+
+// GetUnlinkedLIDMappings returns up to limit mappings not yet linked in Chatwoot, oldest first.
+func (r *SQLiteRepository) GetUnlinkedLIDMappings(limit int) ([]*domainChatStorage.LIDMapping, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := r.db.Query(`
+		SELECT lid, phone_jid, updated_at
+		FROM lid_mappings
+		WHERE chatwoot_linked_at IS NULL
+		ORDER BY updated_at ASC, lid ASC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings []*domainChatStorage.LIDMapping
+	for rows.Next() {
+		var mapping domainChatStorage.LIDMapping
+		if err := rows.Scan(&mapping.LID, &mapping.PhoneJID, &mapping.UpdatedAt); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, &mapping)
+	}
+	return mappings, rows.Err()
+}
+
+// MarkLIDMappingLinked records that the Chatwoot contacts of lid were linked.
+func (r *SQLiteRepository) MarkLIDMappingLinked(lid string) error {
+	_, err := r.db.Exec(`UPDATE lid_mappings SET chatwoot_linked_at = ? WHERE lid = ?`, time.Now().UTC(), lid)
+	return err
+}
+
+// SaveChatwootInbox records the Chatwoot inbox of a device, replacing any earlier one.
+func (r *SQLiteRepository) SaveChatwootInbox(inbox *domainChatStorage.ChatwootInbox) error {
+	if inbox == nil || strings.TrimSpace(inbox.DeviceID) == "" || inbox.InboxID <= 0 {
+		return fmt.Errorf("chatwoot inbox requires a device and an inbox ID")
+	}
+	if inbox.CreatedAt.IsZero() {
+		inbox.CreatedAt = time.Now().UTC()
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO chatwoot_inboxes (device_id, inbox_id, inbox_name, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(device_id) DO UPDATE SET
+			inbox_id = excluded.inbox_id,
+			inbox_name = excluded.inbox_name,
+			created_at = excluded.created_at
+	`, inbox.DeviceID, inbox.InboxID, inbox.Name, inbox.CreatedAt)
+	return err
+}
+
+// ListChatwootInboxes returns the provisioned Chatwoot inboxes by device.
+func (r *SQLiteRepository) ListChatwootInboxes() ([]*domainChatStorage.ChatwootInbox, error) {
+	rows, err := r.db.Query(`
+		SELECT device_id, inbox_id, inbox_name, created_at
+		FROM chatwoot_inboxes
+		ORDER BY device_id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inboxes []*domainChatStorage.ChatwootInbox
+	for rows.Next() {
+		var inbox domainChatStorage.ChatwootInbox
+		if err := rows.Scan(&inbox.DeviceID, &inbox.InboxID, &inbox.Name, &inbox.CreatedAt); err != nil {
+			return nil, err
+		}
+		inboxes = append(inboxes, &inbox)
+	}
+	return inboxes, rows.Err()
+}
+
+// AddChatwootAuditEntry appends a bridging decision to the audit trail and sets entry.ID.
+func (r *SQLiteRepository) AddChatwootAuditEntry(entry *domainChatStorage.ChatwootAuditEntry) error {
+	if entry == nil || strings.TrimSpace(entry.ChatJID) == "" || entry.Decision == "" {
+		return fmt.Errorf("chatwoot audit entry requires a chat and a decision")
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	res, err := r.db.Exec(`
+		INSERT INTO chatwoot_audit_log (device_id, chat_jid, message_id, direction, decision, reason, chatwoot_message_id, detail, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.DeviceID, entry.ChatJID, entry.MessageID, entry.Direction, entry.Decision, entry.Reason, entry.ChatwootMessageID, entry.Detail, entry.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetChatwootAuditEntries returns the latest limit decisions of a chat, oldest first.
+func (r *SQLiteRepository) GetChatwootAuditEntries(chatJID string, limit int) ([]*domainChatStorage.ChatwootAuditEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT id, device_id, chat_jid, message_id, direction, decision, reason, chatwoot_message_id, detail, created_at
+		FROM (
+			SELECT * FROM chatwoot_audit_log
+			WHERE chat_jid = ?
+			ORDER BY id DESC
+			LIMIT ?
+		)
+		ORDER BY id ASC
+	`, chatJID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*domainChatStorage.ChatwootAuditEntry
+	for rows.Next() {
+		var entry domainChatStorage.ChatwootAuditEntry
+		if err := rows.Scan(&entry.ID, &entry.DeviceID, &entry.ChatJID, &entry.MessageID, &entry.Direction, &entry.Decision,
+			&entry.Reason, &entry.ChatwootMessageID, &entry.Detail, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// PurgeChatwootAuditEntries drops audit entries recorded before olderThan.
+func (r *SQLiteRepository) PurgeChatwootAuditEntries(olderThan time.Time) (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM chatwoot_audit_log WHERE created_at < ?`, olderThan.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdateMessageReceipt records receipts in one transaction. A receipt only fills the times still
+// unknown, so the first delivery and read are kept, and a read also counts as delivered.
+func (r *SQLiteRepository) UpdateMessageReceipt(receipts ...*domainChatStorage.MessageReceipt) error {
+	if len(receipts) == 0 {
+		return nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO message_receipts (device_id, chat_jid, message_id, participant, delivered_at, read_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(device_id, message_id, participant) DO UPDATE SET
+			delivered_at = COALESCE(message_receipts.delivered_at, excluded.delivered_at),
+			read_at = COALESCE(message_receipts.read_at, excluded.read_at)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, receipt := range receipts {
+		deliveredAt := receipt.DeliveredAt
+		if deliveredAt == nil {
+			deliveredAt = receipt.ReadAt
+		}
+		if _, err := stmt.Exec(receipt.DeviceID, receipt.ChatJID, receipt.MessageID, receipt.Participant,
+			nullableUTC(deliveredAt), nullableUTC(receipt.ReadAt)); err != nil {
+			return fmt.Errorf("failed to store receipt of %s: %w", receipt.MessageID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// receiptLookupBatch bounds the message IDs of one GetMessageReceipts query.
+const receiptLookupBatch = 500
+
+// GetMessageReceipts returns the receipts of the given messages of a device, by message ID.
+func (r *SQLiteRepository) GetMessageReceipts(deviceID string, messageIDs []string) (map[string][]*domainChatStorage.MessageReceipt, error) {
+	receipts := make(map[string][]*domainChatStorage.MessageReceipt)
+	for start := 0; start < len(messageIDs); start += receiptLookupBatch {
+		batch := messageIDs[start:min(start+receiptLookupBatch, len(messageIDs))]
+		args := []any{deviceID}
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		rows, err := r.db.Query(`
+			SELECT device_id, chat_jid, message_id, participant, delivered_at, read_at
+			FROM message_receipts
+			WHERE device_id = ? AND message_id IN (?`+strings.Repeat(", ?", len(batch)-1)+`)
+			ORDER BY message_id, participant`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var receipt domainChatStorage.MessageReceipt
+			var deliveredAt, readAt sql.NullTime
+			if err := rows.Scan(&receipt.DeviceID, &receipt.ChatJID, &receipt.MessageID, &receipt.Participant, &deliveredAt, &readAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if deliveredAt.Valid {
+				receipt.DeliveredAt = &deliveredAt.Time
+			}
+			if readAt.Valid {
+				receipt.ReadAt = &readAt.Time
+			}
+			receipts[receipt.MessageID] = append(receipts[receipt.MessageID], &receipt)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return receipts, nil
+}
+
+// defaultPruneBatchSize bounds each DELETE of PruneStorage so writers are never locked out for long.
+const defaultPruneBatchSize = 1000
+
+// PruneStorage deletes messages, Chatwoot export state and exported-message dedup rows older than the
+// cutoffs of opts, in batches, until done or ctx ends. On failure the rows deleted so far are reported
+// with the error.
+func (r *SQLiteRepository) PruneStorage(ctx context.Context, opts domainChatStorage.StoragePruneOptions) (*domainChatStorage.StoragePruneResult, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultPruneBatchSize
+	}
+	messagesWhere := "timestamp < ?"
+	if opts.KeepMedia {
+		messagesWhere += " AND COALESCE(media_type, '') = ''"
+	}
+	targets := []struct {
+		table  string
+		where  string
+		before time.Time
+	}{
+		{"messages", messagesWhere, opts.MessagesBefore},
+		{"chatwoot_export_state", "updated_at < ?", opts.MessagesBefore},
+		{"chatwoot_exported_messages", "created_at < ?", opts.DedupBefore},
+	}
+
+	result := &domainChatStorage.StoragePruneResult{DryRun: opts.DryRun, Deleted: map[string]int64{}}
+	for _, target := range targets {
+		if target.before.IsZero() {
+			continue
+		}
+		// Timestamps are stored in several layouts that all start with the date, so comparing with
+		// the day keeps the cutoff exact to the day whatever the layout.
+		day := target.before.UTC().Format(time.DateOnly)
+		if opts.DryRun {
+			var count int64
+			if err := r.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", target.table, target.where), day).Scan(&count); err != nil {
+				return result, fmt.Errorf("count %s: %w", target.table, err)
+			}
+			result.Deleted[target.table] = count
+			continue
+		}
+
+		query := fmt.Sprintf("DELETE FROM %[1]s WHERE rowid IN (SELECT rowid FROM %[1]s WHERE %[2]s LIMIT ?)", target.table, target.where)
+		for {
+			res, err := r.db.ExecContext(ctx, query, day, batchSize)
+			if err != nil {
+				return result, fmt.Errorf("prune %s: %w", target.table, err)
+			}
+			deleted, err := res.RowsAffected()
+			if err != nil {
+				return result, fmt.Errorf("prune %s: %w", target.table, err)
+			}
+			result.Deleted[target.table] += deleted
+			if deleted < int64(batchSize) {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// defaultMessageSearchLimit is the page size of SearchMessagesFullText when the filter sets none.
+const defaultMessageSearchLimit = 20
+
+// SearchMessagesFullText finds the messages of a device holding every word of the query, newest first.
+// Words match regardless of case and accents through the messages_fts index.
+func (r *SQLiteRepository) SearchMessagesFullText(filter *domainChatStorage.MessageSearchFilter) (*domainChatStorage.MessageSearchPage, error) {
+	if filter == nil || filter.DeviceID == "" {
+		return nil, fmt.Errorf("device_id is required for message search (data isolation)")
+	}
+	page := &domainChatStorage.MessageSearchPage{Hits: []*domainChatStorage.MessageSearchHit{}}
+	match := ftsMatchQuery(filter.Query)
+	if match == "" {
+		return page, nil
+	}
+
+	conditions := []string{"messages_fts MATCH ?", "m.device_id = ?"}
+	args := []any{match, filter.DeviceID}
+	if filter.ChatJID != "" {
+		conditions = append(conditions, "m.chat_jid = ?")
+		args = append(args, filter.ChatJID)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "m.timestamp >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "m.timestamp <= ?")
+		args = append(args, *filter.To)
+	}
+	if filter.Cursor != "" {
+		timestamp, rowID, err := decodeSearchCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "(m.timestamp < ? OR (m.timestamp = ? AND m.rowid < ?))")
+		args = append(args, timestamp, timestamp, rowID)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultMessageSearchLimit
+	}
+	args = append(args, limit+1)
+
+	rows, err := r.db.Query(`
+		SELECT m.rowid, CAST(m.timestamp AS TEXT), m.id, m.chat_jid, m.sender, m.timestamp,
+			COALESCE(m.media_type, ''), snippet(messages_fts, '**', '**', '...', -1, 16)
+		FROM messages_fts
+		JOIN messages m ON m.rowid = messages_fts.docid
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY m.timestamp DESC, m.rowid DESC
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var lastRowID int64
+	var lastTimestamp string
+	for rows.Next() {
+		if len(page.Hits) == limit {
+			page.NextCursor = encodeSearchCursor(lastTimestamp, lastRowID)
+			break
+		}
+		var hit domainChatStorage.MessageSearchHit
+		if err := rows.Scan(&lastRowID, &lastTimestamp, &hit.ID, &hit.ChatJID, &hit.Sender, &hit.Timestamp,
+			&hit.MediaType, &hit.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to scan search hit: %w", err)
+		}
+		page.Hits = append(page.Hits, &hit)
+	}
+	return page, rows.Err()
+}
+
+// ftsMatchQuery turns free text into an FTS query requiring every word. Words are split the way the
+// unicode61 tokenizer splits them and quoted, so FTS operators in the text are searched as words.
+func ftsMatchQuery(text string) string {
+	words := strings.FieldsFunc(text, func(c rune) bool { return !unicode.IsLetter(c) && !unicode.IsNumber(c) })
+	for i, word := range words {
+		words[i] = `"` + word + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// encodeSearchCursor keeps the stored timestamp and rowid of the last hit of a page.
+func encodeSearchCursor(timestamp string, rowID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(timestamp + "|" + strconv.FormatInt(rowID, 10)))
+}
+
+func decodeSearchCursor(cursor string) (string, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if sep := strings.LastIndex(string(raw), "|"); sep > 0 {
+			rowID, convErr := strconv.ParseInt(string(raw[sep+1:]), 10, 64)
+			if convErr == nil {
+				return string(raw[:sep]), rowID, nil
+			}
+		}
+	}
+	return "", 0, pkgError.ValidationError("invalid search cursor")
+}
+
+// SaveChatAutoReply sets the auto-reply of a chat of a device, replacing any earlier one.
+func (r *SQLiteRepository) SaveChatAutoReply(reply *domainChatStorage.ChatAutoReply) error {
+	if reply == nil || strings.TrimSpace(reply.ChatJID) == "" || strings.TrimSpace(reply.Message) == "" {
+		return fmt.Errorf("chat auto-reply requires a chat and a message")
+	}
+	reply.CreatedAt = time.Now().UTC()
+
+	var expiresAt sql.NullTime
+	if reply.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: reply.ExpiresAt.UTC(), Valid: true}
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO chat_auto_replies (device_id, chat_jid, message, conversation_id, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(device_id, chat_jid) DO UPDATE SET
+			message = excluded.message,
+			conversation_id = excluded.conversation_id,
+			expires_at = excluded.expires_at,
+			created_at = excluded.created_at
+	`, reply.DeviceID, reply.ChatJID, reply.Message, reply.ConversationID, expiresAt, reply.CreatedAt)
+	return err
+}
+
+// GetChatAutoReply returns the auto-reply of a chat of a device, or nil when there is none or it
+// expired before now.
+func (r *SQLiteRepository) GetChatAutoReply(deviceID, chatJID string, now time.Time) (*domainChatStorage.ChatAutoReply, error) {
+	row := r.db.QueryRow(`
+		SELECT device_id, chat_jid, message, conversation_id, expires_at, created_at
+		FROM chat_auto_replies
+		WHERE device_id = ? AND chat_jid = ? AND (expires_at IS NULL OR expires_at > ?)
+	`, deviceID, chatJID, now.UTC())
+	reply, err := scanChatAutoReply(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return reply, err
+}
+
+// ListChatAutoReplies returns the auto-replies of every device that have not expired before now, newest first.
+func (r *SQLiteRepository) ListChatAutoReplies(now time.Time) ([]*domainChatStorage.ChatAutoReply, error) {
+	rows, err := r.db.Query(`
+		SELECT device_id, chat_jid, message, conversation_id, expires_at, created_at
+		FROM chat_auto_replies
+		WHERE expires_at IS NULL OR expires_at > ?
+		ORDER BY created_at DESC
+	`, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var replies []*domainChatStorage.ChatAutoReply
+	for rows.Next() {
+		reply, err := scanChatAutoReply(rows)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, rows.Err()
+}
+
+// DeleteChatAutoReply removes the auto-reply of a chat of a device and reports whether one was set.
+func (r *SQLiteRepository) DeleteChatAutoReply(deviceID, chatJID string) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM chat_auto_replies WHERE device_id = ? AND chat_jid = ?`, deviceID, chatJID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanChatAutoReply(scanner interface{ Scan(...any) error }) (*domainChatStorage.ChatAutoReply, error) {
+	var (
+		reply     domainChatStorage.ChatAutoReply
+		expiresAt sql.NullTime
+	)
+	if err := scanner.Scan(&reply.DeviceID, &reply.ChatJID, &reply.Message, &reply.ConversationID, &expiresAt, &reply.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		reply.ExpiresAt = &expiresAt.Time
+	}
+	return &reply, nil
+}
+
+// SavePoll stores a poll, replacing an earlier copy of the same message.
+func (r *SQLiteRepository) SavePoll(poll *domainChatStorage.Poll) error {
+	if poll == nil || poll.MessageID == "" || len(poll.Options) == 0 {
+		return fmt.Errorf("poll requires a message ID and options")
+	}
+	options, err := json.Marshal(poll.Options)
+	if err != nil {
+		return err
+	}
+	if poll.CreatedAt.IsZero() {
+		poll.CreatedAt = time.Now().UTC()
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO polls (message_id, chat_jid, question, options, selectable_count, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(message_id) DO UPDATE SET
+			chat_jid = excluded.chat_jid,
+			question = excluded.question,
+			options = excluded.options,
+			selectable_count = excluded.selectable_count
+	`, poll.MessageID, poll.ChatJID, poll.Question, string(options), poll.SelectableCount, poll.CreatedAt)
+	return err
+}
+
+// SavePollVote replaces the voter's previous vote on the poll. Votes older than the stored one are
+// ignored, so a late delivery cannot undo a change or a retraction.
+func (r *SQLiteRepository) SavePollVote(vote *domainChatStorage.PollVote) error {
+	if vote == nil || vote.PollMessageID == "" || vote.VoterJID == "" {
+		return fmt.Errorf("poll vote requires a poll message ID and a voter")
+	}
+	if vote.Options == nil {
+		vote.Options = []string{}
+	}
+	options, err := json.Marshal(vote.Options)
+	if err != nil {
+		return err
+	}
+	if vote.VotedAt.IsZero() {
+		vote.VotedAt = time.Now().UTC()
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO poll_votes (poll_message_id, voter_jid, options, voted_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(poll_message_id, voter_jid) DO UPDATE SET
+			options = excluded.options,
+			voted_at = excluded.voted_at
+		WHERE excluded.voted_at >= poll_votes.voted_at
+	`, vote.PollMessageID, vote.VoterJID, string(options), vote.VotedAt.UTC())
+	return err
+}
+
+// GetPollResults returns the poll with its current tally, or nil when the poll is not stored.
+// Selections naming options the poll does not have are not counted.
+func (r *SQLiteRepository) GetPollResults(pollMessageID string) (*domainChatStorage.PollResults, error) {
+	var (
+		results domainChatStorage.PollResults
+		options string
+	)
+	err := r.db.QueryRow(`
+		SELECT message_id, chat_jid, question, options, selectable_count, created_at
+		FROM polls
+		WHERE message_id = ?
+	`, pollMessageID).Scan(&results.Poll.MessageID, &results.Poll.ChatJID, &results.Poll.Question, &options, &results.Poll.SelectableCount, &results.Poll.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(options), &results.Poll.Options); err != nil {
+		return nil, fmt.Errorf("invalid options stored for poll %s: %w", pollMessageID, err)
+	}
+
+	index := make(map[string]int, len(results.Poll.Options))
+	results.Tally = make([]domainChatStorage.PollOptionTally, len(results.Poll.Options))
+	for i, option := range results.Poll.Options {
+		results.Tally[i].Option = option
+		index[option] = i
+	}
+
+	rows, err := r.db.Query(`SELECT options FROM poll_votes WHERE poll_message_id = ?`, pollMessageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var selected []string
+		if err := json.Unmarshal([]byte(raw), &selected); err != nil {
+			logrus.Warnf("Ignoring unreadable vote on poll %s: %v", pollMessageID, err)
+			continue
+		}
+		counted := false
+		for _, option := range selected {
+			if i, ok := index[option]; ok {
+				results.Tally[i].Votes++
+				counted = true
+			}
+		}
+		if counted {
+			results.Voters++
+		}
+	}
+	return &results, rows.Err()
+}
+
+// EnqueueMaintenanceEvent appends an event to the maintenance buffer and sets entry.ID to the new row id.
+func (r *SQLiteRepository) EnqueueMaintenanceEvent(entry *domainChatStorage.MaintenanceBufferEntry) error {
+	if entry == nil || strings.TrimSpace(entry.Target) == "" {
+		return fmt.Errorf("maintenance buffer entry requires a target")
+	}
+	entry.CreatedAt = time.Now().UTC()
+
+	res, err := r.db.Exec(`
+		INSERT INTO maintenance_buffer (target, event, payload, created_at)
+		VALUES (?, ?, ?, ?)
+	`, entry.Target, entry.Event, entry.Payload, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+	return nil
+}
+
+// ListMaintenanceEvents returns up to limit buffered events in the order they were received.
+func (r *SQLiteRepository) ListMaintenanceEvents(limit int) ([]*domainChatStorage.MaintenanceBufferEntry, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.db.Query(`
+		SELECT id, target, event, payload, created_at
+		FROM maintenance_buffer
+		ORDER BY id ASC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*domainChatStorage.MaintenanceBufferEntry
+	for rows.Next() {
+		entry := &domainChatStorage.MaintenanceBufferEntry{}
+		if err := rows.Scan(&entry.ID, &entry.Target, &entry.Event, &entry.Payload, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteMaintenanceEvent removes a buffered event once it has been handed to its target.
+func (r *SQLiteRepository) DeleteMaintenanceEvent(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM maintenance_buffer WHERE id = ?`, id)
+	return err
+}
+
+// CountMaintenanceEvents returns the number of buffered events.
+func (r *SQLiteRepository) CountMaintenanceEvents() (int64, error) {
+	var count int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM maintenance_buffer`).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,137 @@
+package chatstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func seedPruneRows(t *testing.T, repo *SQLiteRepository, now time.Time) {
+	t.Helper()
+	chat := "5511999990000@s.whatsapp.net"
+	messages := []*domainChatStorage.Message{
+		{ID: "old-text", Timestamp: now.AddDate(0, 0, -40)},
+		{ID: "old-media", Timestamp: now.AddDate(0, 0, -40), MediaType: "image", MediaKey: []byte("key")},
+		{ID: "recent", Timestamp: now.AddDate(0, 0, -2)},
+	}
+	for _, message := range messages {
+		message.ChatJID, message.DeviceID, message.Sender, message.Content = chat, "dev", chat, "hello"
+		if err := repo.StoreMessage(message); err != nil {
+			t.Fatalf("store message failed: %v", err)
+		}
+	}
+
+	for i, age := range []int{40, 100, 2} {
+		key := []string{"old", "ancient", "recent"}[i]
+		if err := repo.MarkMessageExported("dev", chat, key, i+1); err != nil {
+			t.Fatalf("mark exported failed: %v", err)
+		}
+		if _, err := repo.db.Exec(`UPDATE chatwoot_exported_messages SET created_at = ? WHERE message_key = ?`,
+			now.AddDate(0, 0, -age).UTC().Format("2006-01-02 15:04:05"), key); err != nil {
+			t.Fatalf("backdate failed: %v", err)
+		}
+	}
+
+	for i, age := range []int{40, 2} {
+		jid := []string{"old@s.whatsapp.net", "recent@s.whatsapp.net"}[i]
+		if err := repo.UpsertChatExportState(&domainChatStorage.ChatExportState{DeviceID: "dev", ChatJID: jid, LastExportedAt: now}); err != nil {
+			t.Fatalf("upsert export state failed: %v", err)
+		}
+		if _, err := repo.db.Exec(`UPDATE chatwoot_export_state SET updated_at = ? WHERE chat_jid = ?`,
+			now.AddDate(0, 0, -age).UTC().Format("2006-01-02T15:04:05.000Z"), jid); err != nil {
+			t.Fatalf("backdate failed: %v", err)
+		}
+	}
+}
+
+func TestPruneStorage_DryRunCountsWithoutDeleting(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	now := time.Now()
+	seedPruneRows(t, repo, now)
+
+	opts := domainChatStorage.StoragePruneOptions{MessagesBefore: now.AddDate(0, 0, -30), DedupBefore: now.AddDate(0, 0, -90), DryRun: true}
+	result, err := repo.PruneStorage(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	want := map[string]int64{"messages": 2, "chatwoot_export_state": 1, "chatwoot_exported_messages": 1}
+	for table, count := range want {
+		if result.Deleted[table] != count {
+			t.Fatalf("expected %d %s rows counted, got %v", count, table, result.Deleted)
+		}
+	}
+
+	var messages int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&messages); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if !result.DryRun || messages != 3 {
+		t.Fatalf("expected a dry run to keep every message, got %d", messages)
+	}
+}
+
+func TestPruneStorage_DeletesInBatchesAndKeepsRecentRows(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	now := time.Now()
+	seedPruneRows(t, repo, now)
+
+	result, err := repo.PruneStorage(context.Background(), domainChatStorage.StoragePruneOptions{
+		MessagesBefore: now.AddDate(0, 0, -30),
+		DedupBefore:    now.AddDate(0, 0, -90),
+		KeepMedia:      true,
+		BatchSize:      1,
+	})
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if result.Deleted["messages"] != 1 || result.Deleted["chatwoot_export_state"] != 1 || result.Deleted["chatwoot_exported_messages"] != 1 {
+		t.Fatalf("unexpected deleted counts: %v", result.Deleted)
+	}
+
+	var ids []string
+	rows, err := repo.db.Query("SELECT id FROM messages ORDER BY id")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || ids[0] != "old-media" || ids[1] != "recent" {
+		t.Fatalf("expected the media and recent messages to remain, got %v", ids)
+	}
+
+	for _, key := range []string{"old", "recent"} {
+		if exported, err := repo.IsMessageExported("dev", "5511999990000@s.whatsapp.net", key); err != nil || !exported {
+			t.Fatalf("expected dedup row %s within the horizon to remain (err %v)", key, err)
+		}
+	}
+	if state, err := repo.GetChatExportState("dev", "recent@s.whatsapp.net"); err != nil || state == nil {
+		t.Fatalf("expected recent export state to remain, got %v (err %v)", state, err)
+	}
+}
+
+func TestPruneStorage_StopsWhenContextEnds(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	now := time.Now()
+	seedPruneRows(t, repo, now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := repo.PruneStorage(ctx, domainChatStorage.StoragePruneOptions{MessagesBefore: now.AddDate(0, 0, -30)}); err == nil {
+		t.Fatal("expected a cancelled context to stop the prune")
+	}
+	var count int
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&count); err != nil {
+		t.Fatalf("count messages failed: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected nothing deleted, got %d messages left", count)
+	}
+}
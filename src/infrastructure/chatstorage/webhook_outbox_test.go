@@ -0,0 +1,108 @@
+package chatstorage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestSQLiteRepository(t *testing.T) *SQLiteRepository {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "chatstorage_test.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo := &SQLiteRepository{db: db}
+	if err := repo.InitializeSchema(); err != nil {
+		if strings.Contains(err.Error(), "CGO_ENABLED=0") || strings.Contains(err.Error(), "requires cgo") {
+			t.Skipf("skipping chatstorage sqlite tests without cgo: %v", err)
+		}
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+	return repo
+}
+
+func TestWebhookOutbox_EnqueueAndDue(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+	now := time.Now().UTC()
+
+	due := &domainChatStorage.WebhookOutboxEntry{URL: "https://a", Event: "message", Payload: `{"a":1}`, Attempts: 1, NextAttemptAt: now.Add(-time.Minute)}
+	later := &domainChatStorage.WebhookOutboxEntry{URL: "https://b", Event: "message", Payload: `{"b":1}`, Attempts: 1, NextAttemptAt: now.Add(time.Hour)}
+	for _, e := range []*domainChatStorage.WebhookOutboxEntry{due, later} {
+		if err := repo.EnqueueWebhookOutbox(e); err != nil {
+			t.Fatalf("enqueue failed: %v", err)
+		}
+		if e.ID == 0 {
+			t.Fatalf("expected id to be set")
+		}
+	}
+
+	entries, err := repo.GetDueWebhookOutbox(now, 10)
+	if err != nil {
+		t.Fatalf("get due failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != due.ID {
+		t.Fatalf("expected only entry %d to be due, got %d entries", due.ID, len(entries))
+	}
+	if entries[0].Payload != `{"a":1}` || entries[0].Status != domainChatStorage.WebhookOutboxPending {
+		t.Fatalf("unexpected entry: payload=%s status=%s", entries[0].Payload, entries[0].Status)
+	}
+}
+
+func TestWebhookOutbox_DeadLetterCountsAndDelete(t *testing.T) {
+	repo := newTestSQLiteRepository(t)
+
+	entry := &domainChatStorage.WebhookOutboxEntry{URL: "https://a", Event: "message.ack", Payload: `{}`, Attempts: 1}
+	if err := repo.EnqueueWebhookOutbox(entry); err != nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+
+	entry.Attempts = 5
+	entry.Status = domainChatStorage.WebhookOutboxDead
+	entry.LastError = "webhook returned status 500"
+	if err := repo.UpdateWebhookOutbox(entry); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	due, err := repo.GetDueWebhookOutbox(time.Now().Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("get due failed: %v", err)
+	}
+	if len(due) != 0 {
+		t.Fatalf("dead entries must not be due, got %d", len(due))
+	}
+
+	dead, err := repo.ListWebhookOutbox(domainChatStorage.WebhookOutboxDead, 10)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 5 || dead[0].LastError != "webhook returned status 500" {
+		t.Fatalf("unexpected dead entries: %d", len(dead))
+	}
+
+	counts, err := repo.CountWebhookOutbox()
+	if err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if counts[domainChatStorage.WebhookOutboxDead] != 1 || counts[domainChatStorage.WebhookOutboxPending] != 0 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+
+	if err := repo.DeleteWebhookOutbox(entry.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	got, err := repo.GetWebhookOutboxEntry(entry.ID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected entry to be deleted")
+	}
+}
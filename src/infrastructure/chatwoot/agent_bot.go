@@ -0,0 +1,152 @@
+package chatwoot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/sirupsen/logrus"
+)
+
+// AgentBot is a Chatwoot agent bot. Chatwoot posts the events of the inboxes it is connected to to
+// OutgoingURL, and keeps their new conversations pending until the bot hands them off.
+type AgentBot struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	OutgoingURL string `json:"outgoing_url"`
+}
+
+// AgentBotSetupRequest names the bot POST /chatwoot/bot/setup registers and the inbox it is connected
+// to. Name defaults to CHATWOOT_BOT_NAME and InboxID to the client's inbox.
+type AgentBotSetupRequest struct {
+	Name    string `json:"name"`
+	InboxID int    `json:"inbox_id"`
+}
+
+// AgentBotSetupResult reports what SetupAgentBot found and changed.
+type AgentBotSetupResult struct {
+	BotID       int      `json:"bot_id"`
+	BotName     string   `json:"bot_name"`
+	InboxID     int      `json:"inbox_id"`
+	OutgoingURL string   `json:"outgoing_url"`
+	Created     bool     `json:"created"`
+	URLUpdated  bool     `json:"url_updated"`
+	Actions     []string `json:"actions"`
+}
+
+// agentBotSetupMu keeps concurrent setups from registering the same bot twice.
+var agentBotSetupMu sync.Mutex
+
+// AgentBotWebhookURL returns the URL Chatwoot should post agent bot events to: /chatwoot/bot under
+// CHATWOOT_PUBLIC_URL, carrying CHATWOOT_WEBHOOK_TOKEN like InboxWebhookURL.
+func AgentBotWebhookURL() (string, error) {
+	return publicWebhookURL("/chatwoot/bot")
+}
+
+// ListAgentBots returns the agent bots of the account.
+func (c *Client) ListAgentBots() ([]AgentBot, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/agent_bots", c.BaseURL, c.AccountID)
+
+	var bots []AgentBot
+	if _, err := c.doRequest("GET", endpoint, nil, &bots); err != nil {
+		return nil, fmt.Errorf("failed to list agent bots: %w", err)
+	}
+	return bots, nil
+}
+
+// CreateAgentBot registers an agent bot whose events are posted to outgoingURL.
+func (c *Client) CreateAgentBot(name, outgoingURL string) (*AgentBot, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/agent_bots", c.BaseURL, c.AccountID)
+	payload := map[string]string{
+		"name":         name,
+		"description":  "Answers WhatsApp chats with keyword replies until an agent takes over",
+		"outgoing_url": outgoingURL,
+	}
+
+	var bot AgentBot
+	if _, err := c.doRequest("POST", endpoint, payload, &bot); err != nil {
+		return nil, fmt.Errorf("failed to create agent bot: %w", err)
+	}
+	if bot.ID == 0 {
+		return nil, fmt.Errorf("failed to create agent bot: response has no bot ID")
+	}
+	return &bot, nil
+}
+
+// UpdateAgentBotURL points an agent bot at outgoingURL.
+func (c *Client) UpdateAgentBotURL(botID int, outgoingURL string) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/agent_bots/%d", c.BaseURL, c.AccountID, botID)
+	if _, err := c.doRequest("PATCH", endpoint, map[string]string{"outgoing_url": outgoingURL}, nil); err != nil {
+		return fmt.Errorf("failed to update agent bot %d: %w", botID, err)
+	}
+	return nil
+}
+
+// SetInboxAgentBot connects an agent bot to an inbox.
+func (c *Client) SetInboxAgentBot(inboxID, botID int) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/inboxes/%d/set_agent_bot", c.BaseURL, c.AccountID, inboxID)
+	if _, err := c.doRequest("POST", endpoint, map[string]int{"agent_bot": botID}, nil); err != nil {
+		return fmt.Errorf("failed to connect agent bot %d to inbox %d: %w", botID, inboxID, err)
+	}
+	return nil
+}
+
+// SetupAgentBot registers the agent bot of CHATWOOT_BOT_ENABLED, idempotently: a bot with the
+// requested name is reused and pointed at this server, else one is created. The bot is then
+// connected to the inbox.
+func (c *Client) SetupAgentBot(req AgentBotSetupRequest) (*AgentBotSetupResult, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		req.Name = config.ChatwootBotName
+	}
+	if req.InboxID == 0 {
+		req.InboxID = c.InboxID
+	}
+	if req.InboxID == 0 {
+		return nil, fmt.Errorf("inbox ID is required")
+	}
+	outgoingURL, err := AgentBotWebhookURL()
+	if err != nil {
+		return nil, err
+	}
+
+	agentBotSetupMu.Lock()
+	defer agentBotSetupMu.Unlock()
+
+	bots, err := c.ListAgentBots()
+	if err != nil {
+		return nil, err
+	}
+	result := &AgentBotSetupResult{BotName: req.Name, InboxID: req.InboxID, OutgoingURL: outgoingURL, Actions: []string{}}
+	for _, bot := range bots {
+		if bot.Name != req.Name {
+			continue
+		}
+		result.BotID = bot.ID
+		if bot.OutgoingURL != outgoingURL {
+			if err := c.UpdateAgentBotURL(bot.ID, outgoingURL); err != nil {
+				return nil, err
+			}
+			result.URLUpdated = true
+			result.Actions = append(result.Actions, fmt.Sprintf("pointed agent bot %d at %s", bot.ID, outgoingURL))
+		}
+		break
+	}
+	if result.BotID == 0 {
+		bot, err := c.CreateAgentBot(req.Name, outgoingURL)
+		if err != nil {
+			return nil, err
+		}
+		result.BotID, result.Created = bot.ID, true
+		result.Actions = append(result.Actions, fmt.Sprintf("created agent bot %d", bot.ID))
+	}
+
+	if err := c.SetInboxAgentBot(req.InboxID, result.BotID); err != nil {
+		return nil, err
+	}
+	result.Actions = append(result.Actions, fmt.Sprintf("connected agent bot %d to inbox %d", result.BotID, req.InboxID))
+	logrus.Infof("Chatwoot: Agent bot %q (%d) is set up for inbox %d", req.Name, result.BotID, req.InboxID)
+	return result, nil
+}
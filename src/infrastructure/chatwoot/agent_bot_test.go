@@ -0,0 +1,117 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+// agentBotServer answers like a Chatwoot account with the agent bots in bots.
+type agentBotServer struct {
+	mu        sync.Mutex
+	bots      []AgentBot
+	created   int
+	updated   map[int]string
+	connected map[int]int // agent bot per inbox
+}
+
+func (s *agentBotServer) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/accounts/1/agent_bots":
+			_ = json.NewEncoder(w).Encode(s.bots)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/accounts/1/agent_bots":
+			s.created++
+			bot := AgentBot{ID: 40 + len(s.bots), Name: body["name"].(string), OutgoingURL: body["outgoing_url"].(string)}
+			s.bots = append(s.bots, bot)
+			_ = json.NewEncoder(w).Encode(bot)
+		case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/api/v1/accounts/1/agent_bots/"):
+			for i := range s.bots {
+				if r.URL.Path == "/api/v1/accounts/1/agent_bots/"+strconv.Itoa(s.bots[i].ID) {
+					s.bots[i].OutgoingURL = body["outgoing_url"].(string)
+					s.updated[s.bots[i].ID] = s.bots[i].OutgoingURL
+				}
+			}
+			_, _ = w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/set_agent_bot"):
+			var inboxID int
+			_, _ = fmt.Sscanf(r.URL.Path, "/api/v1/accounts/1/inboxes/%d/set_agent_bot", &inboxID)
+			s.connected[inboxID] = int(body["agent_bot"].(float64))
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+		}
+	})
+}
+
+func TestSetupAgentBot_CreatesThenReuses(t *testing.T) {
+	prevPublic, prevToken, prevName := config.ChatwootPublicURL, config.ChatwootWebhookToken, config.ChatwootBotName
+	config.ChatwootPublicURL, config.ChatwootWebhookToken, config.ChatwootBotName = "https://wa.example.com", "s3cret", "WhatsApp Bot"
+	t.Cleanup(func() {
+		config.ChatwootPublicURL, config.ChatwootWebhookToken, config.ChatwootBotName = prevPublic, prevToken, prevName
+	})
+
+	s := &agentBotServer{
+		bots:      []AgentBot{{ID: 7, Name: "Other bot", OutgoingURL: "https://elsewhere.example.com"}},
+		updated:   map[int]string{},
+		connected: map[int]int{},
+	}
+	srv := httptest.NewServer(s.handler(t))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 3, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+
+	first, err := c.SetupAgentBot(AgentBotSetupRequest{})
+	if err != nil {
+		t.Fatalf("SetupAgentBot returned error: %v", err)
+	}
+	wantURL := "https://wa.example.com/chatwoot/bot?token=s3cret"
+	if !first.Created || first.BotName != "WhatsApp Bot" || first.InboxID != 3 || first.OutgoingURL != wantURL {
+		t.Fatalf("unexpected result %+v", first)
+	}
+	if s.connected[3] != first.BotID {
+		t.Fatalf("expected bot %d to be connected to inbox 3, got %v", first.BotID, s.connected)
+	}
+
+	// The bot is found by name and pointed at the new URL
+	config.ChatwootPublicURL = "https://wa2.example.com"
+	second, err := c.SetupAgentBot(AgentBotSetupRequest{InboxID: 5})
+	if err != nil {
+		t.Fatalf("second SetupAgentBot returned error: %v", err)
+	}
+	if second.Created || !second.URLUpdated || second.BotID != first.BotID || s.created != 1 {
+		t.Fatalf("expected the bot to be reused, got %+v after %d creations", second, s.created)
+	}
+	if s.updated[first.BotID] != "https://wa2.example.com/chatwoot/bot?token=s3cret" || s.connected[5] != first.BotID {
+		t.Fatalf("expected the bot to be updated and connected to inbox 5, got %v and %v", s.updated, s.connected)
+	}
+	if _, ok := s.updated[7]; ok {
+		t.Fatal("expected the other bot to be left alone")
+	}
+}
+
+func TestSetupAgentBot_NeedsPublicURLAndInbox(t *testing.T) {
+	prevPublic, prevMedia := config.ChatwootPublicURL, config.ChatwootMediaLinkBaseURL
+	config.ChatwootPublicURL, config.ChatwootMediaLinkBaseURL = "", ""
+	t.Cleanup(func() { config.ChatwootPublicURL, config.ChatwootMediaLinkBaseURL = prevPublic, prevMedia })
+
+	c := &Client{BaseURL: "https://cw.example.com", APIToken: "t", AccountID: 1}
+	if _, err := c.SetupAgentBot(AgentBotSetupRequest{}); err == nil || !strings.Contains(err.Error(), "inbox ID") {
+		t.Fatalf("expected an inbox to be required, got %v", err)
+	}
+	if _, err := c.SetupAgentBot(AgentBotSetupRequest{InboxID: 3}); err == nil || !strings.Contains(err.Error(), "CHATWOOT_PUBLIC_URL") {
+		t.Fatalf("expected the public URL to be required, got %v", err)
+	}
+}
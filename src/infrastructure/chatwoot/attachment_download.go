@@ -0,0 +1,130 @@
+package chatwoot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DownloadedAttachment is an agent's attachment saved to a temp file by DownloadAttachment. Remove
+// it once sent.
+type DownloadedAttachment struct {
+	Path     string
+	Filename string // Last segment of the URL path
+	MimeType string // Content-Type of the response, or sniffed from the file when it has none
+	Size     int64
+}
+
+// Remove deletes the temp file.
+func (a *DownloadedAttachment) Remove() {
+	removeTempFile(a.Path)
+}
+
+// ConvertWebPToPNG turns a static WebP image into a PNG, which WhatsApp accepts as an image.
+func (a *DownloadedAttachment) ConvertWebPToPNG() error {
+	converted, err := convertWebPToPNG(a.Path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(converted)
+	if err != nil {
+		removeTempFile(converted)
+		return err
+	}
+	a.Remove()
+	a.Path, a.MimeType, a.Size = converted, "image/png", info.Size()
+	a.Filename = strings.TrimSuffix(a.Filename, filepath.Ext(a.Filename)) + ".png"
+	return nil
+}
+
+// DownloadAttachment saves the file behind the data_url of an attachment to a temp file. The API
+// token is sent when the URL is on the Chatwoot host, so attachments of private installs and
+// expiring ActiveStorage links can be fetched, and dropped when redirected to another host. Files
+// over maxSize bytes are rejected (0 = unlimited).
+func (c *Client) DownloadAttachment(ctx context.Context, dataURL string, maxSize int64) (*DownloadedAttachment, error) {
+	target, err := url.Parse(dataURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return nil, fmt.Errorf("invalid attachment URL %q", dataURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dataURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.isChatwootHost(target) {
+		req.Header.Set("api_access_token", c.APIToken)
+	}
+
+	httpClient := *c.HTTPClient
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Host != via[0].URL.Host {
+			req.Header.Del("api_access_token")
+		}
+		return nil
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("attachment download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("attachment download failed: HTTP %d", resp.StatusCode)
+	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("attachment is too large (%d bytes, limit %d)", resp.ContentLength, maxSize)
+	}
+
+	filename, _ := url.PathUnescape(path.Base(resp.Request.URL.Path))
+	if filename == "" || filename == "." || filename == "/" {
+		filename = "attachment"
+	}
+	tmpFile, err := createTempFile("attachment-*" + filepath.Ext(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	attachment := &DownloadedAttachment{Path: tmpFile.Name(), Filename: filename}
+
+	body := io.Reader(resp.Body)
+	if maxSize > 0 {
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	attachment.Size, err = io.Copy(tmpFile, body)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	switch {
+	case err != nil:
+		attachment.Remove()
+		return nil, fmt.Errorf("attachment download failed: %w", err)
+	case attachment.Size == 0:
+		attachment.Remove()
+		return nil, errors.New("attachment download returned an empty file")
+	case maxSize > 0 && attachment.Size > maxSize:
+		attachment.Remove()
+		return nil, fmt.Errorf("attachment is too large (over %d bytes)", maxSize)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if base := strings.TrimSpace(strings.Split(contentType, ";")[0]); base == "" || base == "application/octet-stream" {
+		contentType, _ = detectContentType(attachment.Path)
+	}
+	attachment.MimeType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return attachment, nil
+}
+
+// isChatwootHost reports whether u points at the configured Chatwoot server.
+func (c *Client) isChatwootHost(u *url.URL) bool {
+	base, err := url.Parse(c.BaseURL)
+	return err == nil && base.Host != "" && strings.EqualFold(base.Host, u.Host)
+}
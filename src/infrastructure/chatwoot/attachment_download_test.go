@@ -0,0 +1,80 @@
+package chatwoot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDownloadAttachment_SendsTokenOnlyToChatwoot(t *testing.T) {
+	useTempDir(t)
+	var foreignToken string
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignToken = r.Header.Get("api_access_token")
+		_, _ = w.Write([]byte("%PDF-1.4 stored"))
+	}))
+	t.Cleanup(foreign.Close)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api_access_token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/redirect/report.pdf") {
+			http.Redirect(w, r, foreign.URL+"/bucket/report.pdf", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "audio/ogg; codecs=opus")
+		_, _ = w.Write([]byte("OggS voice"))
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "secret", HTTPClient: &http.Client{}}
+
+	file, err := c.DownloadAttachment(t.Context(), srv.URL+"/rails/active_storage/blobs/voice%20note.ogg", 0)
+	if err != nil {
+		t.Fatalf("DownloadAttachment: %v", err)
+	}
+	data, _ := os.ReadFile(file.Path)
+	if string(data) != "OggS voice" || file.Filename != "voice note.ogg" || file.MimeType != "audio/ogg" || file.Size != 10 {
+		t.Errorf("unexpected download %+v with %q", file, data)
+	}
+	file.Remove()
+	if _, err := os.Stat(file.Path); !os.IsNotExist(err) {
+		t.Errorf("expected the temp file to be removed, got %v", err)
+	}
+
+	file, err = c.DownloadAttachment(t.Context(), srv.URL+"/redirect/report.pdf", 0)
+	if err != nil {
+		t.Fatalf("DownloadAttachment after redirect: %v", err)
+	}
+	defer file.Remove()
+	if foreignToken != "" {
+		t.Errorf("expected the token to be dropped on the redirect to storage, got %q", foreignToken)
+	}
+	if file.Filename != "report.pdf" || file.MimeType != "application/pdf" {
+		t.Errorf("expected the sniffed pdf, got %+v", file)
+	}
+
+	if _, err := (&Client{BaseURL: "https://chatwoot.example", APIToken: "secret", HTTPClient: &http.Client{}}).DownloadAttachment(t.Context(), srv.URL+"/voice.ogg", 0); err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Errorf("expected the token not to be sent to another host, got %v", err)
+	}
+}
+
+func TestDownloadAttachment_RejectsOversizedFiles(t *testing.T) {
+	dir := useTempDir(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing first leaves the response without a Content-Length, so only the copy can notice
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "secret", HTTPClient: &http.Client{}}
+
+	if _, err := c.DownloadAttachment(t.Context(), srv.URL+"/big.bin", 50); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected the size limit to apply, got %v", err)
+	}
+	if names := tempDirNames(t, dir); len(names) != 0 {
+		t.Errorf("expected the partial download to be removed, found %v", names)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/sirupsen/logrus"
 )
 
@@ -161,19 +162,16 @@ func detectContentType(filePath string) (string, error) {
 }
 
 func transcodeAudioToMP3(sourcePath string) (string, error) {
-	if _, err := exec.LookPath("ffmpeg"); err != nil {
+	if ffmpeg := utils.DetectFFmpeg(); !ffmpeg.Available {
 		return "", fmt.Errorf("ffmpeg not found in PATH")
+	} else if !ffmpeg.MP3 {
+		return "", fmt.Errorf("ffmpeg has no libmp3lame encoder")
 	}
 
-	tmpFile, err := os.CreateTemp("", "chatwoot-audio-*.mp3")
+	targetPath, err := createTempPath("audio-*.mp3")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file for mp3: %w", err)
 	}
-	targetPath := tmpFile.Name()
-	if err := tmpFile.Close(); err != nil {
-		_ = os.Remove(targetPath)
-		return "", fmt.Errorf("failed to close temp file: %w", err)
-	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
 	defer cancel()
@@ -192,7 +190,7 @@ func transcodeAudioToMP3(sourcePath string) (string, error) {
 	)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		_ = os.Remove(targetPath)
+		removeTempFile(targetPath)
 		if ctx.Err() == context.DeadlineExceeded {
 			return "", fmt.Errorf("ffmpeg timeout while transcoding %s", sourcePath)
 		}
@@ -206,6 +204,9 @@ func transcodeAudioToMP3(sourcePath string) (string, error) {
 }
 
 func prepareAttachmentForUpload(filePath string) (string, func()) {
+	if isWebPAttachment(filePath) {
+		return prepareImageForUpload(filePath)
+	}
 	if !shouldTranscodeToMP3(filePath) {
 		return filePath, func() {}
 	}
@@ -216,9 +217,5 @@ func prepareAttachmentForUpload(filePath string) (string, func()) {
 		return filePath, func() {}
 	}
 
-	return convertedPath, func() {
-		if err := os.Remove(convertedPath); err != nil && !os.IsNotExist(err) {
-			logrus.Debugf("Chatwoot: failed to cleanup temp audio file %s: %v", convertedPath, err)
-		}
-	}
+	return convertedPath, tempFileCleanup(convertedPath)
 }
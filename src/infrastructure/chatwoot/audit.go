@@ -0,0 +1,72 @@
+package chatwoot
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+// Reasons recorded with skipped and failed audit entries
+const (
+	AuditReasonDuplicate        = "duplicate"          // Forwarded moments ago
+	AuditReasonAlreadyForwarded = "already_forwarded"  // An export record exists
+	AuditReasonSentFromChatwoot = "sent_from_chatwoot" // The message is the echo of an agent reply
+	AuditReasonUnsupportedType  = "unsupported_type"
+	AuditReasonNotWhitelisted   = "not_whitelisted" // WHATSAPP_WEBHOOK_EVENTS leaves out message events
+	AuditReasonNoContact        = "no_contact"
+	AuditReasonCSATReply        = "csat_reply"
+	AuditReasonEcho             = "echo" // The agent message came from WhatsApp
+	AuditReasonNoDevice         = "no_device"
+	AuditReasonInvalidNumber    = "invalid_number"
+	AuditReasonNotOnWhatsApp    = "not_on_whatsapp"
+	AuditReasonSendFailed       = "send_failed"
+	AuditReasonChatwootFailed   = "chatwoot_failed"
+	AuditReasonShutdown         = "shutdown"       // The server stopped before the forward finished
+	AuditReasonQueueFull        = "queue_full"     // No forward worker had room for the message
+	AuditReasonEphemeralChat    = "ephemeral_chat" // The chat has disappearing messages and CHATWOOT_FORWARD_EPHEMERAL is skip
+)
+
+// auditPurgeInterval is how often RecordAudit drops entries past CHATWOOT_AUDIT_RETENTION_DAYS.
+const auditPurgeInterval = time.Hour
+
+var auditPurge = struct {
+	mu   sync.Mutex
+	last time.Time
+}{}
+
+// RecordAudit appends entry to the audit trail of repo, read by GET /chatwoot/audit. Entries
+// without a chat, a nil repo or CHATWOOT_AUDIT_RETENTION_DAYS=0 record nothing, and a failed write
+// is only logged: the audit never stops a message.
+func RecordAudit(repo domainChatStorage.IChatStorageRepository, entry *domainChatStorage.ChatwootAuditEntry) {
+	if repo == nil || entry == nil || strings.TrimSpace(entry.ChatJID) == "" || config.ChatwootAuditRetentionDays <= 0 {
+		return
+	}
+	if err := repo.AddChatwootAuditEntry(entry); err != nil {
+		logrus.Warnf("Chatwoot: Failed to record %s decision for message %s: %v", entry.Decision, entry.MessageID, err)
+		return
+	}
+	purgeAudit(repo, time.Now())
+}
+
+func purgeAudit(repo domainChatStorage.IChatStorageRepository, now time.Time) {
+	auditPurge.mu.Lock()
+	if now.Sub(auditPurge.last) < auditPurgeInterval {
+		auditPurge.mu.Unlock()
+		return
+	}
+	auditPurge.last = now
+	auditPurge.mu.Unlock()
+
+	purged, err := repo.PurgeChatwootAuditEntries(now.AddDate(0, 0, -config.ChatwootAuditRetentionDays))
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to purge the audit log: %v", err)
+		return
+	}
+	if purged > 0 {
+		logrus.Debugf("Chatwoot: Purged %d audit entries older than %d days", purged, config.ChatwootAuditRetentionDays)
+	}
+}
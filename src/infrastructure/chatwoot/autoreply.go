@@ -0,0 +1,74 @@
+package chatwoot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutoReplyCommandPrefix starts the private-note command that sets or clears a per-chat auto-reply:
+// "#autoreply <text> [for <n><m|h|d|w>]" or "#autoreply off".
+const AutoReplyCommandPrefix = "#autoreply"
+
+var reAutoReplyDuration = regexp.MustCompile(`(?i)(?:^|\s+)for\s+(\d+)\s*([mhdw])$`)
+
+// AutoReplyCommand is a parsed "#autoreply" private note.
+type AutoReplyCommand struct {
+	Off  bool
+	Text string
+	For  time.Duration // 0 means no expiry
+}
+
+// ParseAutoReplyCommand parses a private note. ok is false when the note is not an auto-reply command;
+// err is set when it is one but cannot be applied.
+func ParseAutoReplyCommand(content string) (cmd AutoReplyCommand, ok bool, err error) {
+	rest, ok := cutCommandPrefix(content, AutoReplyCommandPrefix)
+	if !ok {
+		return cmd, false, nil
+	}
+
+	if strings.EqualFold(rest, "off") {
+		return AutoReplyCommand{Off: true}, true, nil
+	}
+
+	if m := reAutoReplyDuration.FindStringSubmatchIndex(rest); m != nil {
+		n, _ := strconv.Atoi(rest[m[2]:m[3]])
+		if n <= 0 {
+			return cmd, true, fmt.Errorf("duration must be positive")
+		}
+		unit := map[string]time.Duration{"m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+		cmd.For = time.Duration(n) * unit[strings.ToLower(rest[m[4]:m[5]])]
+		rest = strings.TrimSpace(rest[:m[0]])
+	}
+	if rest == "" {
+		return cmd, true, fmt.Errorf("usage: %s <text> [for 7d] or %s off", AutoReplyCommandPrefix, AutoReplyCommandPrefix)
+	}
+	cmd.Text = rest
+	return cmd, true, nil
+}
+
+// cutCommandPrefix reports whether content starts with the command prefix, ignoring case and leading
+// whitespace, and returns the trimmed rest. The prefix must be followed by whitespace or the end, so
+// "#autoreplyfoo" and a prefix in the middle of a message do not count.
+func cutCommandPrefix(content, prefix string) (string, bool) {
+	content = strings.TrimSpace(content)
+	if prefix == "" || len(content) < len(prefix) || !strings.EqualFold(content[:len(prefix)], prefix) {
+		return "", false
+	}
+	rest := content[len(prefix):]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\n' && rest[0] != '\t' {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// AutoReplyConfirmation is the private note posted after an auto-reply was set for a chat.
+func AutoReplyConfirmation(text string, expiresAt *time.Time) string {
+	until := "until turned off with \"" + AutoReplyCommandPrefix + " off\""
+	if expiresAt != nil {
+		until = "until " + expiresAt.UTC().Format("2006-01-02 15:04 UTC")
+	}
+	return fmt.Sprintf("Auto-reply enabled for this chat %s:\n\n%s", until, text)
+}
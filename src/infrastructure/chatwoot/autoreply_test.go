@@ -0,0 +1,50 @@
+package chatwoot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAutoReplyCommand(t *testing.T) {
+	tests := []struct {
+		content string
+		ok      bool
+		wantErr bool
+		want    AutoReplyCommand
+	}{
+		{content: "customer asked for a refund", ok: false},
+		{content: "#autoreplyfoo hi", ok: false},
+		{content: "#autoreply off", ok: true, want: AutoReplyCommand{Off: true}},
+		{content: "  #AutoReply OFF ", ok: true, want: AutoReplyCommand{Off: true}},
+		{content: "#autoreply I'm away until Monday, contact Ana", ok: true, want: AutoReplyCommand{Text: "I'm away until Monday, contact Ana"}},
+		{content: "#autoreply Away for a while for 7d", ok: true, want: AutoReplyCommand{Text: "Away for a while", For: 7 * 24 * time.Hour}},
+		{content: "#autoreply Back soon\nContact X FOR 2H", ok: true, want: AutoReplyCommand{Text: "Back soon\nContact X", For: 2 * time.Hour}},
+		{content: "#autoreply Away for 1w", ok: true, want: AutoReplyCommand{Text: "Away", For: 7 * 24 * time.Hour}},
+		{content: "#autoreply", ok: true, wantErr: true},
+		{content: "#autoreply for 7d", ok: true, wantErr: true},
+		{content: "#autoreply Away for 0d", ok: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, ok, err := ParseAutoReplyCommand(tt.content)
+		if ok != tt.ok || (err != nil) != tt.wantErr {
+			t.Fatalf("%q: got ok=%v err=%v, want ok=%v wantErr=%v", tt.content, ok, err, tt.ok, tt.wantErr)
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Fatalf("%q: got %+v, want %+v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestAutoReplyConfirmation_EchoesTextAndExpiry(t *testing.T) {
+	expiresAt := time.Date(2026, 10, 23, 9, 30, 0, 0, time.UTC)
+	note := AutoReplyConfirmation("Away until Monday", &expiresAt)
+	if !strings.Contains(note, "until 2026-10-23 09:30 UTC") || !strings.HasSuffix(note, "Away until Monday") {
+		t.Fatalf("unexpected confirmation %q", note)
+	}
+
+	if note := AutoReplyConfirmation("Away", nil); !strings.Contains(note, "#autoreply off") {
+		t.Fatalf("expected the confirmation to explain how to turn it off, got %q", note)
+	}
+}
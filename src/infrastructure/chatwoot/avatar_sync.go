@@ -8,85 +8,122 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"go.mau.fi/whatsmeow"
 	waTypes "go.mau.fi/whatsmeow/types"
 )
 
-type jidLocks struct {
-	shards []chan struct{}
+// Contact creation and avatar sync use separate lock sets: SyncContactAvatarSmart holds its
+// lock while calling FindOrCreateContact, and the shard locks are not reentrant.
+var (
+	contactLocksOnce sync.Once
+	contactLocks     *utils.ShardedLock
+	avatarLocksOnce  sync.Once
+	avatarLocks      *utils.ShardedLock
+)
+
+func getContactLocks() *utils.ShardedLock {
+	contactLocksOnce.Do(func() {
+		contactLocks = utils.NewShardedLock("chatwoot.contact", config.AppLockShards, time.Duration(config.AppLockWaitWarnSec)*time.Second)
+	})
+	return contactLocks
 }
 
-func newJIDLocks(n int) *jidLocks {
-	ls := &jidLocks{shards: make([]chan struct{}, n)}
-	for i := 0; i < n; i++ {
-		ls.shards[i] = make(chan struct{}, 1)
-		ls.shards[i] <- struct{}{}
-	}
-	return ls
+func getAvatarLocks() *utils.ShardedLock {
+	avatarLocksOnce.Do(func() {
+		avatarLocks = utils.NewShardedLock("chatwoot.avatar", config.AppLockShards, time.Duration(config.AppLockWaitWarnSec)*time.Second)
+	})
+	return avatarLocks
 }
 
-func fnv32a(s string) uint32 {
-	var h uint32 = 2166136261
-	for i := 0; i < len(s); i++ {
-		h ^= uint32(s[i])
-		h *= 16777619
-	}
-	return h
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
 
-func (l *jidLocks) lock(key string) func() {
-	idx := int(fnv32a(key) % uint32(len(l.shards)))
-	ch := l.shards[idx]
-	<-ch
-	return func() { ch <- struct{}{} }
+// avatarChecks holds when the avatar of each JID was last checked in each account, so messages
+// arriving within CHATWOOT_AVATAR_CHECK_INTERVAL_HOURS skip the sync without asking Chatwoot.
+var avatarChecks = utils.NewTTLCache[string, time.Time](10000, 7*24*time.Hour)
+
+func avatarCheckInterval() time.Duration {
+	return time.Duration(config.ChatwootAvatarCheckIntervalHours) * time.Hour
 }
 
-var contactLocks = newJIDLocks(64)
+func (c *Client) avatarCheckKey(contactJID string) string {
+	return c.accountKey() + "|" + contactJID
+}
 
-func sha256Hex(b []byte) string {
-	sum := sha256.Sum256(b)
-	return hex.EncodeToString(sum[:])
+// avatarCheckedRecently reports whether the avatar of contactJID was checked within the interval.
+func (c *Client) avatarCheckedRecently(contactJID string) bool {
+	checkedAt, ok := avatarChecks.Get(c.avatarCheckKey(contactJID))
+	return ok && time.Since(checkedAt) < avatarCheckInterval()
 }
 
+// contactAvatarCheckedAt reads the waha_avatar_checked_at attribute of contact.
+func contactAvatarCheckedAt(contact *Contact) (time.Time, bool) {
+	value, _ := contact.CustomAttributes["waha_avatar_checked_at"].(string)
+	checkedAt, err := time.Parse(time.RFC3339, value)
+	return checkedAt, err == nil
+}
+
+// fetchProfilePicture asks WhatsApp for the full-size profile picture of jid. Tests replace it.
+var fetchProfilePicture = func(ctx context.Context, waClient *whatsmeow.Client, jid waTypes.JID) (*waTypes.ProfilePictureInfo, error) {
+	return waClient.GetProfilePictureInfo(ctx, jid, &whatsmeow.GetProfilePictureParams{Preview: false})
+}
+
+// SyncContactAvatarSmart copies the WhatsApp profile picture of contactJID to its Chatwoot contact when
+// it changed. A contact checked within CHATWOOT_AVATAR_CHECK_INTERVAL_HOURS is skipped, from memory or
+// from its waha_avatar_checked_at attribute; force checks it anyway.
 func (s *SyncService) SyncContactAvatarSmart(
 	ctx context.Context,
 	contactJID string,
 	contactName string,
 	waClient *whatsmeow.Client,
+	force bool,
 ) error {
+	cw := s.clientFor(waClient)
+	if !force && cw.avatarCheckedRecently(contactJID) {
+		return nil
+	}
 	if waClient == nil {
 		return fmt.Errorf("whatsapp client is nil")
 	}
 
-	unlock := contactLocks.lock(contactJID)
+	unlock := getAvatarLocks().Lock(contactJID, "SyncContactAvatarSmart")
 	defer unlock()
 
+	// Another message of the same sender may have checked while this one waited for the lock
+	if !force && cw.avatarCheckedRecently(contactJID) {
+		return nil
+	}
+
 	isGroup := strings.HasSuffix(contactJID, "@g.us")
 	if contactName == "" {
-		contactName = utils.ExtractPhoneFromJID(contactJID)
+		contactName = fallbackContactName(contactJID)
 	}
 
-	contact, err := s.client.FindOrCreateContact(contactName, contactJID, isGroup)
+	contact, err := cw.FindOrCreateContact(contactName, contactJID, isGroup)
 	if err != nil {
 		return err
 	}
+	if checkedAt, ok := contactAvatarCheckedAt(contact); ok && !force && time.Since(checkedAt) < avatarCheckInterval() {
+		avatarChecks.Set(cw.avatarCheckKey(contactJID), checkedAt)
+		return nil
+	}
 
 	jid, err := waTypes.ParseJID(contactJID)
 	if err != nil {
 		return err
 	}
 
-	picInfo, err := waClient.GetProfilePictureInfo(ctx, jid, &whatsmeow.GetProfilePictureParams{Preview: false})
+	picInfo, err := fetchProfilePicture(ctx, waClient, jid)
 	if err != nil || picInfo == nil || picInfo.URL == "" {
-		attrs := map[string]interface{}{
-			"waha_whatsapp_jid":      contactJID,
-			"waha_avatar_checked_at": time.Now().UTC().Format(time.RFC3339),
-		}
-		_ = s.client.UpdateContactAttributes(contact.ID, contactJID, attrs, isGroup)
+		cw.markAvatarChecked(contact, contactJID, isGroup, "")
 		return nil
 	}
 
@@ -103,11 +140,7 @@ func (s *SyncService) SyncContactAvatarSmart(
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		attrs := map[string]interface{}{
-			"waha_whatsapp_jid":      contactJID,
-			"waha_avatar_checked_at": time.Now().UTC().Format(time.RFC3339),
-		}
-		_ = s.client.UpdateContactAttributes(contact.ID, contactJID, attrs, isGroup)
+		cw.markAvatarChecked(contact, contactJID, isGroup, "")
 		return nil
 	}
 
@@ -116,11 +149,7 @@ func (s *SyncService) SyncContactAvatarSmart(
 		return err
 	}
 	if len(imgData) == 0 {
-		attrs := map[string]interface{}{
-			"waha_whatsapp_jid":      contactJID,
-			"waha_avatar_checked_at": time.Now().UTC().Format(time.RFC3339),
-		}
-		_ = s.client.UpdateContactAttributes(contact.ID, contactJID, attrs, isGroup)
+		cw.markAvatarChecked(contact, contactJID, isGroup, "")
 		return nil
 	}
 
@@ -134,27 +163,30 @@ func (s *SyncService) SyncContactAvatarSmart(
 	}
 
 	if oldHash != "" && oldHash == newHash {
-		attrs := map[string]interface{}{
-			"waha_whatsapp_jid":      contactJID,
-			"waha_avatar_checked_at": time.Now().UTC().Format(time.RFC3339),
-		}
-		_ = s.client.UpdateContactAttributes(contact.ID, contactJID, attrs, isGroup)
-
+		cw.markAvatarChecked(contact, contactJID, isGroup, "")
 		return nil
 	}
 
-	if err := s.client.UpdateContactAvatar(contact.ID, imgData); err != nil {
+	if err := cw.UpdateContactAvatar(contact.ID, imgData); err != nil {
 		return err
 	}
+	cw.markAvatarChecked(contact, contactJID, isGroup, newHash)
 
+	logrus.Infof("Chatwoot Sync: avatar updated jid=%s contact_id=%d", contactJID, contact.ID)
+	return nil
+}
+
+// markAvatarChecked records on the contact, and in memory, that its avatar was just checked. hash is
+// the new avatar hash, or "" when it did not change.
+func (c *Client) markAvatarChecked(contact *Contact, contactJID string, isGroup bool, hash string) {
+	now := time.Now().UTC()
 	attrs := map[string]interface{}{
 		"waha_whatsapp_jid":      contactJID,
-		"waha_avatar_hash":       newHash,
-		"waha_avatar_checked_at": time.Now().UTC().Format(time.RFC3339),
+		"waha_avatar_checked_at": now.Format(time.RFC3339),
 	}
-	_ = s.client.UpdateContactAttributes(contact.ID, contactJID, attrs, isGroup)
-
-
-	logrus.Infof("Chatwoot Sync: avatar updated jid=%s contact_id=%d", contactJID, contact.ID)
-	return nil
+	if hash != "" {
+		attrs["waha_avatar_hash"] = hash
+	}
+	_ = c.UpdateContactAttributes(contact.ID, contactJID, attrs, isGroup)
+	avatarChecks.Set(c.avatarCheckKey(contactJID), now)
 }
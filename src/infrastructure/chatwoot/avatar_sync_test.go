@@ -0,0 +1,107 @@
+package chatwoot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"go.mau.fi/whatsmeow"
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+// avatarContactServer serves a group contact whose avatar was checked at checkedAt and counts every
+// request Chatwoot receives. Avatars are checked every 24 hours.
+func avatarContactServer(t *testing.T, groupJID string, checkedAt time.Time) (*SyncService, *atomic.Int64) {
+	t.Helper()
+	originalInterval := config.ChatwootAvatarCheckIntervalHours
+	t.Cleanup(func() { config.ChatwootAvatarCheckIntervalHours = originalInterval })
+	config.ChatwootAvatarCheckIntervalHours = 24
+
+	var requests atomic.Int64
+	contact := Contact{ID: 9, Name: "Team", Identifier: groupJID, CustomAttributes: map[string]interface{}{
+		"waha_whatsapp_jid":      groupJID,
+		"waha_avatar_checked_at": checkedAt.UTC().Format(time.RFC3339),
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/accounts/1/contacts/filter" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"meta": map[string]any{"count": 1}, "payload": []Contact{contact}})
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	client := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	return NewSyncService(client, nil), &requests
+}
+
+func stubProfilePicture(t *testing.T) *atomic.Int64 {
+	t.Helper()
+	var fetches atomic.Int64
+	original := fetchProfilePicture
+	t.Cleanup(func() { fetchProfilePicture = original })
+	fetchProfilePicture = func(context.Context, *whatsmeow.Client, waTypes.JID) (*waTypes.ProfilePictureInfo, error) {
+		fetches.Add(1)
+		return nil, nil
+	}
+	return &fetches
+}
+
+func TestSyncContactAvatarSmart_SkipsWithinCheckInterval(t *testing.T) {
+	groupJID := "120363000000000001@g.us"
+	svc, requests := avatarContactServer(t, groupJID, time.Now().Add(-time.Hour))
+	fetches := stubProfilePicture(t)
+	t.Cleanup(func() { avatarChecks.Delete(svc.client.avatarCheckKey(groupJID)) })
+
+	// The attribute is newer than the interval: Chatwoot is asked once, WhatsApp never
+	if err := svc.SyncContactAvatarSmart(context.Background(), groupJID, "Team", &whatsmeow.Client{}, false); err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	if requests.Load() == 0 || fetches.Load() != 0 {
+		t.Fatalf("expected only the contact lookup, got %d requests and %d picture fetches", requests.Load(), fetches.Load())
+	}
+
+	requests.Store(0)
+	if err := svc.SyncContactAvatarSmart(context.Background(), groupJID, "Team", &whatsmeow.Client{}, false); err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if requests.Load() != 0 || fetches.Load() != 0 {
+		t.Fatalf("expected no requests within the interval, got %d requests and %d picture fetches", requests.Load(), fetches.Load())
+	}
+
+	if err := svc.SyncContactAvatarSmart(context.Background(), groupJID, "Team", &whatsmeow.Client{}, true); err != nil {
+		t.Fatalf("forced sync failed: %v", err)
+	}
+	if requests.Load() == 0 || fetches.Load() != 1 {
+		t.Fatalf("expected force to check the picture, got %d requests and %d picture fetches", requests.Load(), fetches.Load())
+	}
+}
+
+func TestSyncContactAvatarSmart_ChecksStaleAvatar(t *testing.T) {
+	groupJID := "120363000000000002@g.us"
+	svc, requests := avatarContactServer(t, groupJID, time.Now().Add(-48*time.Hour))
+	fetches := stubProfilePicture(t)
+	t.Cleanup(func() { avatarChecks.Delete(svc.client.avatarCheckKey(groupJID)) })
+
+	if err := svc.SyncContactAvatarSmart(context.Background(), groupJID, "Team", &whatsmeow.Client{}, false); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if fetches.Load() != 1 {
+		t.Fatalf("expected a stale avatar to be checked, got %d picture fetches", fetches.Load())
+	}
+
+	// The check is remembered, so the next message of the group costs nothing
+	requests.Store(0)
+	if err := svc.SyncContactAvatarSmart(context.Background(), groupJID, "Team", &whatsmeow.Client{}, false); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if requests.Load() != 0 || fetches.Load() != 1 {
+		t.Fatalf("expected no requests after a check, got %d requests and %d picture fetches", requests.Load(), fetches.Load())
+	}
+}
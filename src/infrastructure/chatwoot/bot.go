@@ -0,0 +1,169 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// BotRule is a canned reply the agent bot sends when a message contains one of Keywords as whole words.
+type BotRule struct {
+	Keywords []string
+	Reply    string
+}
+
+// BotTurn is what the agent bot does about a customer message.
+type BotTurn struct {
+	Reply   string // sent to the customer; empty when the bot stays silent
+	Handoff bool   // the conversation is handed over to the agents
+}
+
+// botUnanswered counts the messages of each conversation the bot had no reply for since its last
+// answer, to hand the conversation over after CHATWOOT_BOT_MAX_UNANSWERED of them.
+var botUnanswered = utils.NewTTLCache[scopedID, int](10000, 24*time.Hour)
+
+// parseBotRules reads CHATWOOT_BOT_RULES: a JSON object of replies keyed by comma-separated keywords,
+// e.g. {"price, prices": "Our prices are at https://example.com/prices"}.
+func parseBotRules(raw string) ([]BotRule, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var replies map[string]string
+	if err := json.Unmarshal([]byte(raw), &replies); err != nil {
+		return nil, fmt.Errorf("invalid CHATWOOT_BOT_RULES (expected a JSON object of replies keyed by keywords): %w", err)
+	}
+
+	keys := make([]string, 0, len(replies))
+	for key := range replies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rules := make([]BotRule, 0, len(keys))
+	for _, key := range keys {
+		rule := BotRule{Reply: strings.TrimSpace(replies[key])}
+		for _, keyword := range strings.Split(key, ",") {
+			if keyword = normalizeBotText(keyword); keyword != "" {
+				rule.Keywords = append(rule.Keywords, keyword)
+			}
+		}
+		if len(rule.Keywords) == 0 {
+			return nil, fmt.Errorf("invalid CHATWOOT_BOT_RULES: %q has no keyword", key)
+		}
+		if rule.Reply == "" {
+			return nil, fmt.Errorf("invalid CHATWOOT_BOT_RULES: the reply to %q is empty", key)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// CheckBotRules reports an error when CHATWOOT_BOT_RULES cannot be used, so bad rules stop the server
+// at startup.
+func CheckBotRules() error {
+	_, err := parseBotRules(config.ChatwootBotRules)
+	return err
+}
+
+// CheckBotAuth reports an error when the agent bot is enabled without CHATWOOT_WEBHOOK_TOKEN. Chatwoot
+// does not sign agent bot events, so without the token anyone could post them.
+func CheckBotAuth() error {
+	if config.ChatwootBotEnabled && strings.TrimSpace(config.ChatwootWebhookToken) == "" {
+		return fmt.Errorf("CHATWOOT_BOT_ENABLED needs CHATWOOT_WEBHOOK_TOKEN: agent bot events are not signed, so the token is their only check")
+	}
+	return nil
+}
+
+// normalizeBotText lowercases text and reduces everything but letters and digits to single spaces,
+// so "Price?" matches the keyword "price".
+func normalizeBotText(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// containsKeyword reports whether the normalized text holds the normalized keyword as whole words.
+func containsKeyword(text, keyword string) bool {
+	return keyword != "" && strings.Contains(" "+text+" ", " "+keyword+" ")
+}
+
+// matchBotRule returns the reply of the rule whose keyword occurs in the normalized text. When several
+// match, the longest keyword wins, so "opening hours" beats "hours".
+func matchBotRule(rules []BotRule, text string) (string, bool) {
+	reply, best := "", ""
+	for _, rule := range rules {
+		for _, keyword := range rule.Keywords {
+			if len(keyword) > len(best) && containsKeyword(text, keyword) {
+				reply, best = rule.Reply, keyword
+			}
+		}
+	}
+	return reply, best != ""
+}
+
+// isHandoffRequest reports whether the normalized text asks for a human, per CHATWOOT_BOT_HANDOFF_KEYWORDS.
+func isHandoffRequest(text string) bool {
+	for _, keyword := range config.ChatwootBotHandoffKeywords {
+		if containsKeyword(text, normalizeBotText(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecideBotTurn decides how the agent bot answers a customer message of a conversation: with the
+// reply of a matching CHATWOOT_BOT_RULES rule, or by handing the conversation over when the customer
+// asks for a human or after CHATWOOT_BOT_MAX_UNANSWERED messages in a row no rule answered. Other
+// messages get CHATWOOT_BOT_FALLBACK_REPLY.
+func (c *Client) DecideBotTurn(conversationID int, content string) BotTurn {
+	key := c.scopedID(conversationID)
+	text := normalizeBotText(content)
+	handoff := BotTurn{Reply: strings.TrimSpace(config.ChatwootBotHandoffMessage), Handoff: true}
+
+	if isHandoffRequest(text) {
+		botUnanswered.Delete(key)
+		return handoff
+	}
+	rules, err := parseBotRules(config.ChatwootBotRules)
+	if err != nil {
+		logrus.Warnf("Chatwoot: %v; the agent bot answers no keyword", err)
+	}
+	if reply, ok := matchBotRule(rules, text); ok {
+		botUnanswered.Delete(key)
+		return BotTurn{Reply: reply}
+	}
+
+	unanswered, _ := botUnanswered.Get(key)
+	unanswered++
+	if limit := config.ChatwootBotMaxUnanswered; limit > 0 && unanswered >= limit {
+		botUnanswered.Delete(key)
+		return handoff
+	}
+	botUnanswered.Set(key, unanswered)
+	return BotTurn{Reply: strings.TrimSpace(config.ChatwootBotFallbackReply)}
+}
+
+// ForgetBotTurns forgets the unanswered messages counted for a conversation.
+func (c *Client) ForgetBotTurns(conversationID int) {
+	botUnanswered.Delete(c.scopedID(conversationID))
+}
+
+// HandOffConversation hands a conversation from the agent bot to the agents: it is opened, which stops
+// the bot, and assigned to CHATWOOT_BOT_HANDOFF_TEAM_ID when set.
+func (c *Client) HandOffConversation(conversationID int) error {
+	c.ForgetBotTurns(conversationID)
+	if err := c.ToggleConversationStatus(conversationID, "open"); err != nil {
+		return err
+	}
+	if teamID := config.ChatwootBotHandoffTeamID; teamID > 0 {
+		return c.AssignConversation(conversationID, teamID)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func useBotConfig(t *testing.T, rules, fallback string, maxUnanswered int) {
+	t.Helper()
+	prevRules, prevFallback, prevMax := config.ChatwootBotRules, config.ChatwootBotFallbackReply, config.ChatwootBotMaxUnanswered
+	prevKeywords, prevMessage := config.ChatwootBotHandoffKeywords, config.ChatwootBotHandoffMessage
+	config.ChatwootBotRules, config.ChatwootBotFallbackReply, config.ChatwootBotMaxUnanswered = rules, fallback, maxUnanswered
+	config.ChatwootBotHandoffKeywords, config.ChatwootBotHandoffMessage = []string{"agente", "human"}, "Transferring you"
+	t.Cleanup(func() {
+		config.ChatwootBotRules, config.ChatwootBotFallbackReply, config.ChatwootBotMaxUnanswered = prevRules, prevFallback, prevMax
+		config.ChatwootBotHandoffKeywords, config.ChatwootBotHandoffMessage = prevKeywords, prevMessage
+	})
+}
+
+func TestParseBotRules(t *testing.T) {
+	rules, err := parseBotRules(`{"Price, prices": "See our prices", "opening hours,hours": "9 to 5"}`)
+	if err != nil {
+		t.Fatalf("parseBotRules returned error: %v", err)
+	}
+	if len(rules) != 2 || strings.Join(rules[0].Keywords, "|") != "price|prices" || strings.Join(rules[1].Keywords, "|") != "opening hours|hours" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	for raw, wantErr := range map[string]string{
+		``:                     "",
+		`["price"]`:            "CHATWOOT_BOT_RULES",
+		`{" , ": "hi"}`:        "has no keyword",
+		`{"price": "  "}`:      "is empty",
+		`{"preço": "R$ 10"}`:   "",
+		`{"a,b": "x", "c":""}`: "is empty",
+	} {
+		_, err := parseBotRules(raw)
+		if wantErr == "" && err != nil {
+			t.Errorf("parseBotRules(%s) returned error: %v", raw, err)
+		}
+		if wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)) {
+			t.Errorf("parseBotRules(%s) = %v, want an error with %q", raw, err, wantErr)
+		}
+	}
+}
+
+func TestCheckBotAuth(t *testing.T) {
+	prevEnabled, prevToken := config.ChatwootBotEnabled, config.ChatwootWebhookToken
+	t.Cleanup(func() { config.ChatwootBotEnabled, config.ChatwootWebhookToken = prevEnabled, prevToken })
+
+	config.ChatwootBotEnabled, config.ChatwootWebhookToken = true, ""
+	if err := CheckBotAuth(); err == nil {
+		t.Error("expected an error for the bot without a webhook token")
+	}
+	config.ChatwootWebhookToken = "bot-token"
+	if err := CheckBotAuth(); err != nil {
+		t.Errorf("expected the bot with a token accepted, got %v", err)
+	}
+	config.ChatwootBotEnabled, config.ChatwootWebhookToken = false, ""
+	if err := CheckBotAuth(); err != nil {
+		t.Errorf("expected no token needed while the bot is off, got %v", err)
+	}
+}
+
+func TestMatchBotRule(t *testing.T) {
+	rules, _ := parseBotRules(`{"hours": "9 to 5", "opening hours": "Mon-Fri 9 to 5", "price": "R$ 10", "preço": "R$ 10"}`)
+	tests := []struct {
+		content string
+		want    string
+		ok      bool
+	}{
+		{"What are your HOURS?", "9 to 5", true},
+		{"opening   hours please", "Mon-Fri 9 to 5", true},
+		{"Qual o preço?", "R$ 10", true},
+		{"priceless", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := matchBotRule(rules, normalizeBotText(tt.content))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("matchBotRule(%q) = %q, %v, want %q, %v", tt.content, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDecideBotTurn(t *testing.T) {
+	useBotConfig(t, `{"price": "R$ 10"}`, "Sorry, type agente for a person", 2)
+	c := &Client{BaseURL: "https://cw.example.com", AccountID: 1}
+	t.Cleanup(func() { c.ForgetBotTurns(5) })
+
+	if turn := c.DecideBotTurn(5, "hello"); turn.Handoff || turn.Reply != "Sorry, type agente for a person" {
+		t.Fatalf("expected the fallback reply, got %+v", turn)
+	}
+	// An answered message starts the count over
+	if turn := c.DecideBotTurn(5, "price?"); turn.Handoff || turn.Reply != "R$ 10" {
+		t.Fatalf("expected the rule reply, got %+v", turn)
+	}
+	if turn := c.DecideBotTurn(5, "hmm"); turn.Handoff {
+		t.Fatalf("expected no handoff after one unanswered message, got %+v", turn)
+	}
+	if turn := c.DecideBotTurn(5, "??"); !turn.Handoff || turn.Reply != "Transferring you" {
+		t.Fatalf("expected a handoff after two unanswered messages, got %+v", turn)
+	}
+	if turn := c.DecideBotTurn(5, "Quero falar com um AGENTE"); !turn.Handoff {
+		t.Fatalf("expected a handoff keyword to hand over, got %+v", turn)
+	}
+
+	config.ChatwootBotMaxUnanswered = 0
+	for i := 0; i < 5; i++ {
+		if turn := c.DecideBotTurn(5, "hello"); turn.Handoff {
+			t.Fatalf("expected no handoff with CHATWOOT_BOT_MAX_UNANSWERED=0, got %+v", turn)
+		}
+	}
+}
+
+func TestHandOffConversation(t *testing.T) {
+	prevTeam := config.ChatwootBotHandoffTeamID
+	t.Cleanup(func() { config.ChatwootBotHandoffTeamID = prevTeam })
+
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		raw, _ := json.Marshal(body)
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(raw))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+
+	config.ChatwootBotHandoffTeamID = 0
+	if err := c.HandOffConversation(9); err != nil {
+		t.Fatalf("HandOffConversation returned error: %v", err)
+	}
+	config.ChatwootBotHandoffTeamID = 4
+	if err := c.HandOffConversation(9); err != nil {
+		t.Fatalf("HandOffConversation returned error: %v", err)
+	}
+
+	want := []string{
+		`POST /api/v1/accounts/1/conversations/9/toggle_status {"status":"open"}`,
+		`POST /api/v1/accounts/1/conversations/9/toggle_status {"status":"open"}`,
+		`POST /api/v1/accounts/1/conversations/9/assignments {"team_id":4}`,
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests:\n%s", strings.Join(requests, "\n"))
+	}
+}
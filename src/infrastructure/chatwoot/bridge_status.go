@@ -0,0 +1,94 @@
+package chatwoot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/metrics"
+)
+
+// Bridge directions
+const (
+	BridgeToChatwoot = "whatsapp_to_chatwoot"
+	BridgeToWhatsApp = "chatwoot_to_whatsapp"
+)
+
+const maxRecentBridgeErrors = 20
+
+// BridgeActivity is the last message bridged in one direction.
+type BridgeActivity struct {
+	ChatID string    `json:"chat_id"`
+	At     time.Time `json:"at"`
+}
+
+// BridgeError is a recent failure of the bridge.
+type BridgeError struct {
+	Direction string    `json:"direction"`
+	Message   string    `json:"message"`
+	At        time.Time `json:"at"`
+}
+
+// BridgeStatus is a snapshot of recent bridge activity.
+type BridgeStatus struct {
+	LastToChatwoot  *BridgeActivity `json:"last_to_chatwoot,omitempty"`
+	LastToWhatsApp  *BridgeActivity `json:"last_to_whatsapp,omitempty"`
+	RecentErrors    []BridgeError   `json:"recent_errors"`
+	ChatwootCircuit CircuitSnapshot `json:"chatwoot_circuit"`
+}
+
+var bridgeStatus = struct {
+	mu             sync.Mutex
+	lastToChatwoot *BridgeActivity
+	lastToWhatsApp *BridgeActivity
+	errors         []BridgeError
+}{}
+
+// RecordBridgedMessage counts a message bridged in the given direction and remembers it as the last.
+func RecordBridgedMessage(direction, chatID string) {
+	metrics.BridgedMessage(direction)
+	activity := &BridgeActivity{ChatID: chatID, At: time.Now()}
+
+	bridgeStatus.mu.Lock()
+	defer bridgeStatus.mu.Unlock()
+	if direction == BridgeToWhatsApp {
+		bridgeStatus.lastToWhatsApp = activity
+	} else {
+		bridgeStatus.lastToChatwoot = activity
+	}
+}
+
+// RecordBridgeError counts a bridge failure and keeps the most recent ones for the status page.
+func RecordBridgeError(direction string, err error) {
+	if err == nil {
+		return
+	}
+	metrics.BridgeError(direction)
+
+	bridgeStatus.mu.Lock()
+	defer bridgeStatus.mu.Unlock()
+	bridgeStatus.errors = append(bridgeStatus.errors, BridgeError{Direction: direction, Message: err.Error(), At: time.Now()})
+	if len(bridgeStatus.errors) > maxRecentBridgeErrors {
+		bridgeStatus.errors = bridgeStatus.errors[len(bridgeStatus.errors)-maxRecentBridgeErrors:]
+	}
+}
+
+// GetBridgeStatus returns recent activity, newest errors first, and the default client's circuit state.
+func GetBridgeStatus() BridgeStatus {
+	bridgeStatus.mu.Lock()
+	status := BridgeStatus{RecentErrors: make([]BridgeError, 0, len(bridgeStatus.errors))}
+	if bridgeStatus.lastToChatwoot != nil {
+		activity := *bridgeStatus.lastToChatwoot
+		status.LastToChatwoot = &activity
+	}
+	if bridgeStatus.lastToWhatsApp != nil {
+		activity := *bridgeStatus.lastToWhatsApp
+		status.LastToWhatsApp = &activity
+	}
+	for i := len(bridgeStatus.errors) - 1; i >= 0; i-- {
+		status.RecentErrors = append(status.RecentErrors, bridgeStatus.errors[i])
+	}
+	bridgeStatus.mu.Unlock()
+
+	status.ChatwootCircuit = GetDefaultClient().CircuitState()
+	return status
+}
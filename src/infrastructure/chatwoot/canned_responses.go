@@ -0,0 +1,89 @@
+package chatwoot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// CannedResponse is a Chatwoot canned response: agents type "/short_code" in the composer to insert it.
+type CannedResponse struct {
+	ID        int    `json:"id"`
+	ShortCode string `json:"short_code"`
+	Content   string `json:"content"`
+}
+
+// cannedResponses caches the canned responses of each account, so expanding a shortcut does not
+// list them on every message.
+var cannedResponses = utils.NewTTLCache[string, []CannedResponse](64, 5*time.Minute)
+
+var (
+	reCannedShortcut    = regexp.MustCompile(`^::([\p{L}\p{N}_-]+)`)
+	reCannedPlaceholder = regexp.MustCompile(`\{\{\s*([a-z_]+\.[a-z_]+)\s*\}\}`)
+)
+
+// ListCannedResponses returns the canned responses of the account. They are cached for five minutes.
+func (c *Client) ListCannedResponses() ([]CannedResponse, error) {
+	if responses, ok := cannedResponses.Get(c.accountKey()); ok {
+		return responses, nil
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/canned_responses", c.BaseURL, c.AccountID)
+
+	var responses []CannedResponse
+	if _, err := c.doRequest("GET", endpoint, nil, &responses); err != nil {
+		return nil, fmt.Errorf("failed to list canned responses: %w", err)
+	}
+	cannedResponses.Set(c.accountKey(), responses)
+	return responses, nil
+}
+
+// ExpandCannedShortcut replaces a "::short_code" that content consists of or starts with by the
+// canned response of that short code, with its contact placeholders filled from contact. Content
+// without a shortcut, or with one the account has no canned response for, is returned unchanged, as
+// is every message when CHATWOOT_EXPAND_CANNED_RESPONSES is off.
+func (c *Client) ExpandCannedShortcut(content string, contact Contact) string {
+	if !config.ChatwootExpandCannedResponses {
+		return content
+	}
+	trimmed := strings.TrimLeft(content, " \t\n")
+	m := reCannedShortcut.FindStringSubmatch(trimmed)
+	if m == nil {
+		return content
+	}
+
+	responses, err := c.ListCannedResponses()
+	if err != nil {
+		logrus.Warnf("Chatwoot: Shortcut ::%s not expanded: %v", m[1], err)
+		return content
+	}
+	for _, response := range responses {
+		if strings.EqualFold(response.ShortCode, m[1]) {
+			return fillCannedPlaceholders(response.Content, contact) + trimmed[len(m[0]):]
+		}
+	}
+	return content
+}
+
+// fillCannedPlaceholders fills the contact placeholders of a canned response, e.g. {{contact.name}}.
+// Other placeholders are left as they are.
+func fillCannedPlaceholders(text string, contact Contact) string {
+	name := strings.TrimSpace(contact.Name)
+	values := map[string]string{
+		"contact.name":         name,
+		"contact.first_name":   strings.SplitN(name, " ", 2)[0],
+		"contact.email":        contact.Email,
+		"contact.phone_number": contact.PhoneNumber,
+	}
+	return reCannedPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value, ok := values[reCannedPlaceholder.FindStringSubmatch(placeholder)[1]]
+		if !ok {
+			return placeholder
+		}
+		return value
+	})
+}
@@ -0,0 +1,75 @@
+package chatwoot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func TestExpandCannedShortcut(t *testing.T) {
+	prev := config.ChatwootExpandCannedResponses
+	config.ChatwootExpandCannedResponses = true
+	t.Cleanup(func() { config.ChatwootExpandCannedResponses = prev })
+
+	var lists atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v1/accounts/1/canned_responses" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+		}
+		lists.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			{"id": 1, "short_code": "greeting", "content": "Hello {{contact.first_name}}!"},
+			{"id": 2, "short_code": "pix", "content": "Our Pix key is {{ contact.email }} for {{contact.name}}, ref {{conversation.id}}"}
+		]`))
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	t.Cleanup(func() { cannedResponses.Delete(c.accountKey()) })
+	contact := Contact{Name: "Maria Silva", Email: "maria@example.com"}
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"::greeting", "Hello Maria!"},
+		{"  ::GREETING How can I help?", "Hello Maria! How can I help?"},
+		{"::pix", "Our Pix key is maria@example.com for Maria Silva, ref {{conversation.id}}"},
+		{"::unknown hi", "::unknown hi"},
+		{"see ::greeting", "see ::greeting"},
+		{"plain text", "plain text"},
+	}
+	for _, tt := range tests {
+		if got := c.ExpandCannedShortcut(tt.content, contact); got != tt.want {
+			t.Errorf("ExpandCannedShortcut(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+	if lists.Load() != 1 {
+		t.Fatalf("expected the canned responses to be listed once, got %d", lists.Load())
+	}
+
+	config.ChatwootExpandCannedResponses = false
+	if got := c.ExpandCannedShortcut("::greeting", contact); got != "::greeting" {
+		t.Fatalf("expected no expansion when disabled, got %q", got)
+	}
+}
+
+func TestExpandCannedShortcut_ListFailurePassesThrough(t *testing.T) {
+	prev := config.ChatwootExpandCannedResponses
+	config.ChatwootExpandCannedResponses = true
+	t.Cleanup(func() { config.ChatwootExpandCannedResponses = prev })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+
+	if got := c.ExpandCannedShortcut("::greeting", Contact{}); got != "::greeting" {
+		t.Fatalf("expected the shortcut to pass through, got %q", got)
+	}
+}
@@ -0,0 +1,82 @@
+package chatwoot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// InfoCommandPrefix starts the note an agent types to get the technical details of a conversation.
+const InfoCommandPrefix = "#info"
+
+// IsInfoCommand reports whether an agent message is the #info command. Anything after the command is
+// ignored.
+func IsInfoCommand(content string) bool {
+	_, ok := cutCommandPrefix(content, InfoCommandPrefix)
+	return ok
+}
+
+// ChatInfo is what #info reports about the WhatsApp chat behind a conversation.
+type ChatInfo struct {
+	ChatJID       string
+	IsGroup       bool
+	DeviceID      string
+	DeviceJID     string
+	DeviceState   string
+	Messages      int64
+	LastExported  *time.Time
+	ChatLink      string
+	DeviceProblem string // why no device could be resolved, if so
+}
+
+// FormatChatInfo renders a ChatInfo as a fixed-width block for a private note.
+func FormatChatInfo(info ChatInfo) string {
+	rows := [][2]string{{"Chat JID", info.ChatJID}}
+	if info.IsGroup {
+		rows = append(rows, [2]string{"Type", "group"})
+	} else {
+		rows = append(rows, [2]string{"Type", "direct"})
+	}
+
+	device := info.DeviceID
+	if info.DeviceJID != "" && info.DeviceJID != info.DeviceID {
+		device += " (" + info.DeviceJID + ")"
+	}
+	if info.DeviceState != "" {
+		device += ", " + info.DeviceState
+	}
+	if info.DeviceProblem != "" {
+		device = "unavailable: " + info.DeviceProblem
+	}
+	rows = append(rows, [2]string{"Device", device})
+
+	rows = append(rows, [2]string{"Messages", fmt.Sprintf("%d stored", info.Messages)})
+	exported := "never"
+	if info.LastExported != nil {
+		exported = info.LastExported.UTC().Format("2006-01-02 15:04 UTC")
+	}
+	rows = append(rows, [2]string{"History sync", exported})
+
+	link := info.ChatLink
+	if link == "" {
+		link = "none"
+	}
+	rows = append(rows, [2]string{"Chat link", link})
+
+	var b strings.Builder
+	b.WriteString("```\n")
+	for _, row := range rows {
+		fmt.Fprintf(&b, "%-13s %s\n", row[0]+":", row[1])
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+// WhatsAppChatLink is the wa.me link that opens a direct chat with phone. Groups have no such link
+// without an invite, so it returns "" for them.
+func WhatsAppChatLink(phone string, isGroup bool) string {
+	if isGroup || phone == "" {
+		return ""
+	}
+	return "https://wa.me/" + phone
+}
@@ -0,0 +1,54 @@
+package chatwoot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsInfoCommand(t *testing.T) {
+	tests := map[string]bool{
+		"#info":            true,
+		"  #INFO\n":        true,
+		"#info please":     true,
+		"#information":     false,
+		"see #info":        false,
+		"#autoreply hello": false,
+	}
+	for content, want := range tests {
+		if got := IsInfoCommand(content); got != want {
+			t.Errorf("IsInfoCommand(%q) = %v, want %v", content, got, want)
+		}
+	}
+}
+
+func TestFormatChatInfo(t *testing.T) {
+	exported := time.Date(2026, 10, 16, 9, 30, 0, 0, time.FixedZone("BRT", -3*3600))
+	got := FormatChatInfo(ChatInfo{
+		ChatJID:      "5511987654321@s.whatsapp.net",
+		DeviceID:     "sales",
+		DeviceJID:    "5511900000000@s.whatsapp.net",
+		DeviceState:  "connected",
+		Messages:     152,
+		LastExported: &exported,
+		ChatLink:     WhatsAppChatLink("5511987654321", false),
+	})
+	want := "```\n" +
+		"Chat JID:     5511987654321@s.whatsapp.net\n" +
+		"Type:         direct\n" +
+		"Device:       sales (5511900000000@s.whatsapp.net), connected\n" +
+		"Messages:     152 stored\n" +
+		"History sync: 2026-10-16 12:30 UTC\n" +
+		"Chat link:    https://wa.me/5511987654321\n" +
+		"```"
+	if got != want {
+		t.Fatalf("unexpected block:\n%s\nwant:\n%s", got, want)
+	}
+
+	group := FormatChatInfo(ChatInfo{ChatJID: "120363000000000001@g.us", IsGroup: true, DeviceProblem: "device mapped to inbox is disconnected"})
+	for _, line := range []string{"Type:         group", "Device:       unavailable: device mapped to inbox is disconnected", "History sync: never", "Chat link:    none"} {
+		if !strings.Contains(group, line) {
+			t.Errorf("expected %q in group block:\n%s", line, group)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package chatwoot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+const (
+	circuitFailureThreshold = 5
+	circuitCooldown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned for Chatwoot requests while the API is considered down.
+var ErrCircuitOpen = errors.New("chatwoot API unavailable: circuit breaker is open")
+
+// CircuitSnapshot is the observable state of the Chatwoot API circuit breaker.
+type CircuitSnapshot struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+// circuitBreaker opens after circuitFailureThreshold consecutive transport errors or 5xx
+// responses, rejects requests for circuitCooldown, then lets a single probe through.
+type circuitBreaker struct {
+	mu            sync.Mutex
+	threshold     int
+	cooldown      time.Duration
+	failures      int
+	state         string
+	openedAt      time.Time
+	probing       bool
+	lastError     string
+	lastFailureAt time.Time
+	lastSuccessAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: CircuitClosed}
+}
+
+func (b *circuitBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *circuitBreaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		b.state = CircuitClosed
+		b.lastSuccessAt = now
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	b.lastFailureAt = now
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = now
+	}
+}
+
+func (b *circuitBreaker) snapshot() CircuitSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snap := CircuitSnapshot{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if !b.lastFailureAt.IsZero() {
+		t := b.lastFailureAt
+		snap.LastFailureAt = &t
+	}
+	if !b.lastSuccessAt.IsZero() {
+		t := b.lastSuccessAt
+		snap.LastSuccessAt = &t
+	}
+	if b.state == CircuitOpen {
+		t := b.openedAt.Add(b.cooldown)
+		snap.RetryAt = &t
+	}
+	return snap
+}
+
+// breakerTransport feeds every Chatwoot HTTP round trip through the circuit breaker.
+type breakerTransport struct {
+	base    http.RoundTripper
+	breaker *circuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.allow(time.Now()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil:
+		t.breaker.record(err, time.Now())
+	case resp.StatusCode >= 500:
+		t.breaker.record(fmt.Errorf("chatwoot returned status %d", resp.StatusCode), time.Now())
+	default:
+		t.breaker.record(nil, time.Now())
+	}
+	return resp, err
+}
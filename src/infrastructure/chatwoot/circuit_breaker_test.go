@@ -0,0 +1,74 @@
+package chatwoot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_OpensAndProbes(t *testing.T) {
+	b := newCircuitBreaker(3, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if err := b.allow(now); err != nil {
+			t.Fatalf("attempt %d should be allowed: %v", i, err)
+		}
+		b.record(errors.New("connection refused"), now)
+	}
+	if got := b.snapshot(); got.State != CircuitOpen || got.RetryAt == nil {
+		t.Fatalf("expected open circuit with retry time, got %+v", got.State)
+	}
+	if err := b.allow(now.Add(time.Second)); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen during cooldown, got %v", err)
+	}
+
+	later := now.Add(time.Minute)
+	if err := b.allow(later); err != nil {
+		t.Fatalf("probe should be allowed after cooldown: %v", err)
+	}
+	if err := b.allow(later); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("only one probe may run while half-open, got %v", err)
+	}
+	b.record(nil, later)
+	if got := b.snapshot(); got.State != CircuitClosed || got.ConsecutiveFailures != 0 {
+		t.Fatalf("expected closed circuit after successful probe, got %s/%d", got.State, got.ConsecutiveFailures)
+	}
+}
+
+func TestBreakerTransport_CountsServerErrorsOnly(t *testing.T) {
+	status := http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	b := newCircuitBreaker(2, time.Minute)
+	client := &http.Client{Transport: &breakerTransport{base: http.DefaultTransport, breaker: b}}
+
+	status = http.StatusNotFound
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if got := b.snapshot(); got.State != CircuitClosed {
+		t.Fatalf("4xx responses must not open the circuit, got %s", got.State)
+	}
+
+	status = http.StatusBadGateway
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected requests to fail fast once open, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package chatwoot
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
@@ -11,12 +12,16 @@ import (
 	"net/textproto"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/dustin/go-humanize"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,61 +31,60 @@ type Client struct {
 	AccountID  int
 	InboxID    int
 	HTTPClient *http.Client
+
+	breaker *circuitBreaker
+	limiter *rateLimiter
+
+	createdAtRejected atomic.Bool // The server answered a backdated message with an error, see CreateMessageFromRequest
+	detectVersion     bool        // Capabilities detects the server version on first use, see NewTargetClient
 }
 
 var (
-	defaultClient     *Client
-	defaultClientOnce sync.Once
+	defaultClient   *Client
+	defaultClientMu sync.RWMutex
 
 	sentMessageIDs    sync.Map
 	sentMessageIDsTTL = 5 * time.Minute
 )
 
-type shardLocks struct {
-	shards []chan struct{}
-}
-
-func newShardLocks(n int) *shardLocks {
-	s := &shardLocks{shards: make([]chan struct{}, n)}
-	for i := 0; i < n; i++ {
-		s.shards[i] = make(chan struct{}, 1)
-		s.shards[i] <- struct{}{}
+func GetDefaultClient() *Client {
+	defaultClientMu.RLock()
+	client := defaultClient
+	defaultClientMu.RUnlock()
+	if client != nil {
+		return client
 	}
-	return s
-}
-
-func (l *shardLocks) lock(key string) func() {
-	idx := int(fnv32a(key) % uint32(len(l.shards)))
-	ch := l.shards[idx]
-	<-ch
-	return func() { ch <- struct{}{} }
-}
 
-func GetDefaultClient() *Client {
-	defaultClientOnce.Do(func() {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+	if defaultClient == nil {
 		defaultClient = NewClient()
-	})
+	}
 	return defaultClient
 }
 
-func MarkMessageAsSent(messageID int) {
+// MarkMessageAsSent remembers a message the bridge created in this client's account, so its webhook
+// is not sent back to WhatsApp.
+func (c *Client) MarkMessageAsSent(messageID int) {
 	if messageID == 0 {
 		return
 	}
-	sentMessageIDs.Store(messageID, time.Now())
+	sentMessageIDs.Store(c.scopedID(messageID), time.Now())
 }
 
-func IsMessageSentByUs(messageID int) bool {
+// IsMessageSentByUs reports whether the bridge created the message in this client's account lately.
+func (c *Client) IsMessageSentByUs(messageID int) bool {
 	if messageID == 0 {
 		return false
 	}
-	val, ok := sentMessageIDs.Load(messageID)
+	key := c.scopedID(messageID)
+	val, ok := sentMessageIDs.Load(key)
 	if !ok {
 		return false
 	}
 	storedAt := val.(time.Time)
 	if time.Since(storedAt) > sentMessageIDsTTL {
-		sentMessageIDs.Delete(messageID)
+		sentMessageIDs.Delete(key)
 		return false
 	}
 	return true
@@ -101,18 +105,73 @@ func init() {
 	}()
 }
 
+// clientTimeout bounds every Chatwoot request, including the time it waits on the rate limiter.
+const clientTimeout = 30 * time.Second
+
 func NewClient() *Client {
+	cfg := CurrentConfig()
+	return NewTargetClient(Target{
+		URL:       cfg.URL,
+		APIToken:  cfg.APIToken,
+		AccountID: cfg.AccountID,
+		InboxID:   cfg.InboxID,
+	})
+}
+
+// NewTargetClient creates a client for a Chatwoot account, with its own circuit breaker and rate limit.
+func NewTargetClient(target Target) *Client {
+	breaker := newCircuitBreaker(circuitFailureThreshold, circuitCooldown)
+	limiter := newRateLimiter(config.ChatwootAPIRateLimit, config.ChatwootAPIRateBurst, nil)
 	return &Client{
-		BaseURL:   strings.TrimRight(config.ChatwootURL, "/"),
-		APIToken:  config.ChatwootAPIToken,
-		AccountID: config.ChatwootAccountID,
-		InboxID:   config.ChatwootInboxID,
+		BaseURL:   strings.TrimRight(target.URL, "/"),
+		APIToken:  target.APIToken,
+		AccountID: target.AccountID,
+		InboxID:   target.InboxID,
 		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: clientTimeout,
+			Transport: &rateLimitTransport{
+				base:    &breakerTransport{base: &metricsTransport{base: http.DefaultTransport}, breaker: breaker},
+				limiter: limiter,
+			},
 		},
+		breaker:       breaker,
+		limiter:       limiter,
+		detectVersion: true,
 	}
 }
 
+// accountKey identifies the Chatwoot account of the client, by URL and account ID. Caches keyed by
+// Chatwoot IDs are scoped with it, since two Chatwoot targets number their records independently.
+func (c *Client) accountKey() string {
+	return fmt.Sprintf("%s|%d", c.BaseURL, c.AccountID)
+}
+
+// scopedID is a Chatwoot record ID within the account of a client.
+type scopedID struct {
+	account string
+	id      int
+}
+
+func (c *Client) scopedID(id int) scopedID {
+	return scopedID{account: c.accountKey(), id: id}
+}
+
+// ThrottledTime returns how long requests of this client have waited on the Chatwoot rate limit.
+func (c *Client) ThrottledTime() time.Duration {
+	if c == nil || c.limiter == nil {
+		return 0
+	}
+	return c.limiter.throttledTime()
+}
+
+// CircuitState reports the Chatwoot API circuit breaker state of this client.
+func (c *Client) CircuitState() CircuitSnapshot {
+	if c.breaker == nil {
+		return CircuitSnapshot{State: CircuitClosed}
+	}
+	return c.breaker.snapshot()
+}
+
 func (c *Client) IsConfigured() bool {
 	return c.BaseURL != "" && c.APIToken != "" && c.AccountID != 0 && c.InboxID != 0
 }
@@ -149,7 +208,7 @@ func (c *Client) doRequest(method, endpoint string, payload interface{}, result
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return bodyBytes, fmt.Errorf("request failed: status %d body %s", resp.StatusCode, string(bodyBytes))
+		return bodyBytes, responseError("request failed", resp, bodyBytes)
 	}
 
 	if result != nil && len(bodyBytes) > 0 {
@@ -161,79 +220,262 @@ func (c *Client) doRequest(method, endpoint string, payload interface{}, result
 	return bodyBytes, nil
 }
 
+// identifierBased reports whether the contact of identifier is kept by its JID in the contact
+// identifier rather than by phone number: groups, LIDs and channels have no number.
+func identifierBased(identifier string, isGroup bool) bool {
+	return isGroup || strings.HasSuffix(identifier, "@lid") || utils.IsNewsletterJID(identifier)
+}
+
+// FindContactByIdentifier returns the contact of a WhatsApp identifier: a group or LID JID, or a
+// phone number. Chatwoot's contact filter is asked for an exact match first; when it has none, or the
+// Chatwoot version has no filter API, up to ChatwootContactSearchMaxPages pages of contact search are
+// read. A phone number is looked up in the form WhatsApp registers it, then in its other form, whose
+// contact is given the registered form. A contact remembered for the identifier is the last resort.
 func (c *Client) FindContactByIdentifier(identifier string, isGroup bool) (*Contact, error) {
-	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/search", c.BaseURL, c.AccountID)
-	logrus.Debugf("Chatwoot: Finding contact by identifier endpoint=%s identifier=%s isGroup=%v", endpoint, identifier, isGroup)
+	searchTerm := identifier
+	isIdentifierBased := identifierBased(identifier, isGroup)
+	alternate := ""
+	if !isIdentifierBased {
+		var canonical string
+		canonical, alternate = utils.PhoneVariants(identifier)
+		searchTerm = "+" + canonical
+	}
+	logrus.Debugf("Chatwoot: Finding contact by identifier identifier=%s isGroup=%v", identifier, isGroup)
 
-	req, err := http.NewRequest("GET", endpoint, nil)
+	contact, err := c.lookupContact(searchTerm, identifier, isIdentifierBased)
 	if err != nil {
 		return nil, err
 	}
+	if contact != nil {
+		return contact, nil
+	}
 
-	searchTerm := identifier
-	isIdentifierBased := isGroup || strings.HasSuffix(identifier, "@lid")
-	if !isIdentifierBased {
-		searchTerm = utils.NormalizePhoneE164(identifier)
+	// The same number in its other form, e.g. a Brazilian mobile saved without the ninth digit
+	if alternate != "" {
+		contact, err = c.lookupContact("+"+alternate, identifier, false)
+		if err != nil {
+			return nil, err
+		}
+		if contact != nil {
+			if err := c.updateContactPhone(contact.ID, searchTerm); err != nil {
+				logrus.Warnf("Chatwoot: Failed to change the phone number of contact %d to %s: %v", contact.ID, searchTerm, err)
+			} else {
+				logrus.Infof("Chatwoot: Changed the phone number of contact %d from %s to %s", contact.ID, contact.PhoneNumber, searchTerm)
+				contact.PhoneNumber = searchTerm
+			}
+			return contact, nil
+		}
 	}
 
-	q := req.URL.Query()
-	q.Add("q", searchTerm)
-	req.URL.RawQuery = q.Encode()
-	req.Header.Set("api_access_token", c.APIToken)
+	// A merge can leave the surviving contact without the phone, identifier or attribute searched for
+	if contact := c.rememberedContact(identifier); contact != nil {
+		logrus.Debugf("Chatwoot: Using contact %d remembered for %s", contact.ID, identifier)
+		return contact, nil
+	}
 
-	resp, err := c.HTTPClient.Do(req)
+	return nil, nil
+}
+
+// lookupContact asks the contact filter for searchTerm, then contact search when the filter has no
+// match or is not supported.
+func (c *Client) lookupContact(searchTerm, identifier string, isIdentifierBased bool) (*Contact, error) {
+	filterKey := "phone_number"
+	if isIdentifierBased {
+		filterKey = "identifier"
+	}
+	contact, err := c.filterContacts(filterKey, searchTerm, identifier, isIdentifierBased)
 	if err != nil {
-		return nil, err
+		logrus.Debugf("Chatwoot: Contact filter failed, searching instead: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to search contact: status %d body %s", resp.StatusCode, string(body))
+	if contact != nil {
+		return contact, nil
 	}
+	return c.searchContacts(searchTerm, identifier, isIdentifierBased)
+}
 
-	var result struct {
-		Payload []Contact `json:"payload"`
+// contactSearchPageSize is the number of contacts Chatwoot returns per page.
+const contactSearchPageSize = 15
+
+// contactFilterUnsupported holds the base URLs of Chatwoot servers without POST /contacts/filter.
+var contactFilterUnsupported sync.Map
+
+type contactPage struct {
+	Meta *struct {
+		Count int `json:"count"`
+	} `json:"meta"`
+	Payload []Contact `json:"payload"`
+}
+
+// filterContacts asks the contact filter API for contacts whose key equals value.
+func (c *Client) filterContacts(key, value, identifier string, isIdentifierBased bool) (*Contact, error) {
+	if _, unsupported := contactFilterUnsupported.Load(c.BaseURL); unsupported || !c.Capabilities().SupportsFilterAPI {
+		return nil, nil
+	}
+	body := map[string]interface{}{
+		"payload": []map[string]interface{}{{
+			"attribute_key":   key,
+			"filter_operator": "equal_to",
+			"values":          []string{value},
+			"query_operator":  nil,
+		}},
+	}
+	return c.pagedContacts(identifier, value, isIdentifierBased, func(page int) (contactPage, error) {
+		endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/filter?page=%d", c.BaseURL, c.AccountID, page)
+		var result contactPage
+		_, err := c.doRequest("POST", endpoint, body, &result)
+		var statusErr *statusError
+		if (errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound) || (err == nil && result.Meta == nil) {
+			// Older Chatwoot versions answer without the filter API
+			contactFilterUnsupported.Store(c.BaseURL, struct{}{})
+			return contactPage{}, fmt.Errorf("contact filter is not supported by %s", c.BaseURL)
+		}
+		return result, err
+	})
+}
+
+// searchContacts looks for the contact in the results of a contact search for term.
+func (c *Client) searchContacts(term, identifier string, isIdentifierBased bool) (*Contact, error) {
+	return c.pagedContacts(identifier, term, isIdentifierBased, func(page int) (contactPage, error) {
+		endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/search", c.BaseURL, c.AccountID)
+		req, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return contactPage{}, err
+		}
+		q := req.URL.Query()
+		q.Add("q", term)
+		q.Add("page", strconv.Itoa(page))
+		req.URL.RawQuery = q.Encode()
+		req.Header.Set("api_access_token", c.APIToken)
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return contactPage{}, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return contactPage{}, responseError("failed to search contact", resp, body)
+		}
+
+		var result contactPage
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return contactPage{}, err
+		}
+		return result, nil
+	})
+}
+
+// pagedContacts reads pages from fetch until one holds the contact, the results end or
+// ChatwootContactSearchMaxPages pages were read.
+func (c *Client) pagedContacts(identifier, searchTerm string, isIdentifierBased bool, fetch func(page int) (contactPage, error)) (*Contact, error) {
+	maxPages := config.ChatwootContactSearchMaxPages
+	if maxPages < 1 {
+		maxPages = 1
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+	seen := 0
+	for page := 1; page <= maxPages; page++ {
+		result, err := fetch(page)
+		if err != nil {
+			return nil, err
+		}
+		if contact := matchContact(result.Payload, identifier, searchTerm, isIdentifierBased); contact != nil {
+			return contact, nil
+		}
+		seen += len(result.Payload)
+		if len(result.Payload) < contactSearchPageSize || (result.Meta != nil && seen >= result.Meta.Count) {
+			return nil, nil
+		}
 	}
+	logrus.Warnf("Chatwoot: No contact for %s in the first %d pages of results", identifier, maxPages)
+	return nil, nil
+}
 
-	for _, contact := range result.Payload {
-		if isIdentifierBased {
-			if contact.Identifier == identifier {
-				return &contact, nil
+// matchContact picks the contact of identifier from one page of results. An exact identifier wins,
+// then the same phone number, then the waha_whatsapp_jid and waha_whatsapp_lid attributes. Contacts
+// marked by DedupeContacts as duplicates only match when nothing else does; see pickContactMatch.
+func matchContact(contacts []Contact, identifier, searchTerm string, isIdentifierBased bool) *Contact {
+	phone := ""
+	if !isIdentifierBased {
+		phone = phoneDigits(searchTerm)
+	}
+	tiers := []func(Contact) bool{
+		func(contact Contact) bool {
+			return contact.Identifier != "" && contact.Identifier == identifier
+		},
+		func(contact Contact) bool {
+			return phone != "" && phoneDigits(contact.PhoneNumber) == phone
+		},
+		func(contact Contact) bool {
+			if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); ok && jid != "" && contactIDKey(jid) == contactIDKey(identifier) {
+				return true
 			}
-			if contact.CustomAttributes != nil {
-				if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); ok && jid == identifier {
-					return &contact, nil
-				}
+			lid, ok := contact.CustomAttributes["waha_whatsapp_lid"].(string)
+			return ok && lid != "" && lid == identifier
+		},
+	}
+	for _, matches := range tiers {
+		var found []*Contact
+		for i := range contacts {
+			if matches(contacts[i]) {
+				found = append(found, &contacts[i])
 			}
-			continue
 		}
+		if contact := pickContactMatch(found, identifier); contact != nil {
+			return contact
+		}
+	}
+	return nil
+}
 
-		if contact.PhoneNumber == searchTerm {
-			return &contact, nil
+// contactConflictsWarned holds the identifiers, with the contact IDs that matched them, already
+// warned about by pickContactMatch, so a busy chat does not repeat the warning for every message.
+var contactConflictsWarned sync.Map
+
+// pickContactMatch returns the first of the contacts that matched identifier equally well, passing
+// over contacts marked as duplicates. Several unmarked matches are a conflict: the first one is
+// used and a warning names them all.
+func pickContactMatch(found []*Contact, identifier string) *Contact {
+	if len(found) == 0 {
+		return nil
+	}
+	var unmarked []*Contact
+	for _, contact := range found {
+		if !isDuplicateContact(*contact) {
+			unmarked = append(unmarked, contact)
 		}
-		if contact.CustomAttributes != nil {
-			if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); ok && jid == identifier {
-				return &contact, nil
-			}
+	}
+	if len(unmarked) == 0 {
+		return found[0]
+	}
+	if len(unmarked) > 1 {
+		ids := make([]string, len(unmarked))
+		for i, contact := range unmarked {
+			ids[i] = strconv.Itoa(contact.ID)
+		}
+		key := identifier + "|" + strings.Join(ids, ",")
+		if _, warned := contactConflictsWarned.LoadOrStore(key, struct{}{}); !warned {
+			logrus.Warnf("Chatwoot: Contacts %s all match %s, using contact %s; run POST /chatwoot/contacts/dedupe to repair them",
+				strings.Join(ids, ", "), identifier, ids[0])
 		}
 	}
-
-	return nil, nil
+	return unmarked[0]
 }
 
 func (c *Client) CreateContact(name, identifier string, isGroup bool) (*Contact, error) {
+	return c.createContact(name, identifier, "", isGroup)
+}
+
+// createContact creates the contact of identifier, recording lid in waha_whatsapp_lid when set.
+func (c *Client) createContact(name, identifier, lid string, isGroup bool) (*Contact, error) {
 	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts", c.BaseURL, c.AccountID)
 
 	var phoneNumber, contactIdentifier string
-	isIdentifierBased := isGroup || strings.HasSuffix(identifier, "@lid")
+	isIdentifierBased := identifierBased(identifier, isGroup)
 	if isIdentifierBased {
 		contactIdentifier = identifier
 	} else {
-		phoneNumber = utils.NormalizePhoneE164(identifier)
+		phoneNumber = utils.CanonicalPhoneE164(identifier)
 	}
 
 	payload := CreateContactRequest{
@@ -245,6 +487,12 @@ func (c *Client) CreateContact(name, identifier string, isGroup bool) (*Contact,
 			"waha_whatsapp_jid": identifier,
 		},
 	}
+	if strings.HasSuffix(identifier, "@lid") {
+		lid = identifier
+	}
+	if lid != "" {
+		payload.CustomAttributes["waha_whatsapp_lid"] = lid
+	}
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -268,14 +516,14 @@ func (c *Client) CreateContact(name, identifier string, isGroup bool) (*Contact,
 	defer resp.Body.Close()
 
 	bodyBytes, _ := io.ReadAll(resp.Body)
-	logrus.Debugf("Chatwoot CreateContact: Response status=%d body=%s", resp.StatusCode, string(bodyBytes))
+	logrus.Debugf("Chatwoot CreateContact: Response status=%d body=%s", resp.StatusCode, describeResponseBody(resp, bodyBytes))
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		existing, findErr := c.FindContactByIdentifier(identifier, isGroup)
 		if findErr == nil && existing != nil {
 			return existing, nil
 		}
-		return nil, fmt.Errorf("failed to create contact: status %d body %s", resp.StatusCode, string(bodyBytes))
+		return nil, responseError("failed to create contact", resp, bodyBytes)
 	}
 
 	var nestedResult struct {
@@ -299,17 +547,26 @@ func (c *Client) CreateContact(name, identifier string, isGroup bool) (*Contact,
 		return &contact, nil
 	}
 
-	return nil, fmt.Errorf("failed to decode contact response (no valid ID found): %s", string(bodyBytes))
+	return nil, fmt.Errorf("failed to decode contact response (no valid ID found): %s", describeResponseBody(resp, bodyBytes))
 }
 
 func (c *Client) FindOrCreateContact(name, identifier string, isGroup bool) (*Contact, error) {
-	unlock := contactLocks.lock(identifier)
+	unlock := getContactLocks().Lock(identifier, "FindOrCreateContact")
 	defer unlock()
 
+	return c.findOrCreateContact(name, identifier, "", isGroup)
+}
+
+// findOrCreateContact finds or creates the contact of identifier while its lock is held. lid is the
+// LID of a phone number identifier, or "".
+func (c *Client) findOrCreateContact(name, identifier, lid string, isGroup bool) (*Contact, error) {
 	contact, err := c.FindContactByIdentifier(identifier, isGroup)
 	if err != nil {
 		return nil, err
 	}
+	if lid != "" {
+		contact = c.linkLIDContact(contact, identifier, lid)
+	}
 
 	if contact != nil {
 		if contact.Name != name && name != "" {
@@ -321,30 +578,50 @@ func (c *Client) FindOrCreateContact(name, identifier string, isGroup bool) (*Co
 			}
 		}
 
-		if contact.CustomAttributes == nil {
-			contact.CustomAttributes = map[string]interface{}{}
-		}
-		if _, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); !ok {
-			attrs := map[string]interface{}{
-				"waha_whatsapp_jid": identifier,
-			}
-			_ = c.UpdateContactAttributes(contact.ID, identifier, attrs, isGroup)
-		}
-
+		c.storeWhatsappAttributes(contact, identifier, lid, isGroup)
 		return contact, nil
 	}
 
-	created, err := c.CreateContact(name, identifier, isGroup)
+	created, err := c.createContact(name, identifier, lid, isGroup)
 	if err != nil {
 		again, findErr := c.FindContactByIdentifier(identifier, isGroup)
 		if findErr == nil && again != nil {
+			c.rememberContactID(identifier, again.ID)
 			return again, nil
 		}
 		return nil, err
 	}
+	c.rememberContactID(identifier, created.ID)
+	c.rememberContactID(lid, created.ID)
 	return created, nil
 }
 
+// storeWhatsappAttributes sets the waha_whatsapp_jid attribute of a contact found for identifier when
+// it is missing or still holds the LID, and waha_whatsapp_lid when lid is new, and remembers the
+// contact for both.
+func (c *Client) storeWhatsappAttributes(contact *Contact, identifier, lid string, isGroup bool) {
+	if contact.CustomAttributes == nil {
+		contact.CustomAttributes = map[string]interface{}{}
+	}
+	attrs := map[string]interface{}{}
+	if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); !ok || (lid != "" && strings.HasSuffix(jid, "@lid")) {
+		attrs["waha_whatsapp_jid"] = identifier
+	}
+	if current, _ := contact.CustomAttributes["waha_whatsapp_lid"].(string); lid != "" && current != lid {
+		attrs["waha_whatsapp_lid"] = lid
+	}
+	if len(attrs) > 0 {
+		if err := c.UpdateContactAttributes(contact.ID, identifier, attrs, isGroup); err == nil {
+			for key, value := range attrs {
+				contact.CustomAttributes[key] = value
+			}
+		}
+	}
+
+	c.rememberContactID(identifier, contact.ID)
+	c.rememberContactID(lid, contact.ID)
+}
+
 func (c *Client) UpdateContactName(contactID int, name string) error {
 	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/%d", c.BaseURL, c.AccountID, contactID)
 
@@ -373,7 +650,7 @@ func (c *Client) UpdateContactName(contactID int, name string) error {
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to update contact: status %d body %s", resp.StatusCode, string(body))
+		return responseError("failed to update contact", resp, body)
 	}
 
 	return nil
@@ -407,7 +684,7 @@ func (c *Client) UploadAvatar(contactID int, imageBytes []byte) error {
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("falha no upload: status %d body %s", resp.StatusCode, string(respBody))
+		return responseError("falha no upload", resp, respBody)
 	}
 
 	return nil
@@ -444,10 +721,10 @@ func (c *Client) CreateConversation(contactID int) (*Conversation, error) {
 	bodyBytes, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to create conversation: status %d body %s", resp.StatusCode, string(bodyBytes))
+		return nil, responseError("failed to create conversation", resp, bodyBytes)
 	}
 
-	logrus.Debugf("Chatwoot CreateConversation: Response body=%s", string(bodyBytes))
+	logrus.Debugf("Chatwoot CreateConversation: Response body=%s", describeResponseBody(resp, bodyBytes))
 
 	var result struct {
 		Payload Conversation `json:"payload"`
@@ -461,10 +738,37 @@ func (c *Client) CreateConversation(contactID int) (*Conversation, error) {
 		return &conversation, nil
 	}
 
-	return nil, fmt.Errorf("failed to decode conversation response (no valid ID found): %s", string(bodyBytes))
+	return nil, fmt.Errorf("failed to decode conversation response (no valid ID found): %s", describeResponseBody(resp, bodyBytes))
 }
 
+// FindConversation returns the oldest open conversation of the contact in the inbox, or nil.
 func (c *Client) FindConversation(contactID int) (*Conversation, error) {
+	conversations, err := c.openConversations(contactID)
+	if err != nil || len(conversations) == 0 {
+		return nil, err
+	}
+	return &conversations[0], nil
+}
+
+// openConversations lists the conversations of the contact in the inbox that are not resolved, lowest
+// ID first. The conversation collecting the contact's WhatsApp statuses is left out.
+func (c *Client) openConversations(contactID int) ([]Conversation, error) {
+	all, err := c.listOpenConversations(contactID)
+	if err != nil {
+		return nil, err
+	}
+	conversations := all[:0]
+	for _, conv := range all {
+		if !isStatusConversation(conv) {
+			conversations = append(conversations, conv)
+		}
+	}
+	return conversations, nil
+}
+
+// listOpenConversations lists the conversations of the contact in the inbox that are not resolved,
+// with their labels, lowest ID first.
+func (c *Client) listOpenConversations(contactID int) ([]Conversation, error) {
 	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/%d/conversations", c.BaseURL, c.AccountID, contactID)
 	req, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
@@ -481,14 +785,16 @@ func (c *Client) FindConversation(contactID int) (*Conversation, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to list contact conversations: status %d body %s", resp.StatusCode, string(body))
+		return nil, responseError("failed to list contact conversations", resp, body)
 	}
 
 	var result struct {
 		Payload []struct {
-			ID      int    `json:"id"`
-			InboxID int    `json:"inbox_id"`
-			Status  string `json:"status"`
+			ID               int                    `json:"id"`
+			InboxID          int                    `json:"inbox_id"`
+			Status           string                 `json:"status"`
+			Labels           []string               `json:"labels"`
+			CustomAttributes map[string]interface{} `json:"custom_attributes"`
 		} `json:"payload"`
 	}
 
@@ -496,32 +802,158 @@ func (c *Client) FindConversation(contactID int) (*Conversation, error) {
 		return nil, err
 	}
 
+	var conversations []Conversation
 	for _, conv := range result.Payload {
 		if conv.InboxID == c.InboxID && conv.Status != "resolved" {
-			return &Conversation{
+			conversations = append(conversations, Conversation{
 				ID:        conv.ID,
 				ContactID: contactID,
 				InboxID:   conv.InboxID,
 				Status:    conv.Status,
-			}, nil
+				Labels:    conv.Labels,
+
+				CustomAttributes: conv.CustomAttributes,
+			})
 		}
 	}
-
-	return nil, nil
+	sort.Slice(conversations, func(i, j int) bool { return conversations[i].ID < conversations[j].ID })
+	return conversations, nil
 }
 
+// FindOrCreateConversation returns the open conversation of the contact in the inbox, creating it when
+// there is none. Find and create run under a per-contact lock so messages arriving together from a new
+// contact share one conversation; should another process have created one as well, the oldest is kept
+// and the new one resolved.
 func (c *Client) FindOrCreateConversation(contactID int) (*Conversation, error) {
+	unlock := getContactLocks().Lock(fmt.Sprintf("conversation:%d", contactID), "FindOrCreateConversation")
+	defer unlock()
+
+	if conv, ok := contactConversations.Get(c.scopedID(contactID)); ok && conv.InboxID == c.InboxID {
+		return &conv, nil
+	}
+
 	conv, err := c.FindConversation(contactID)
 	if err != nil {
 		logrus.Errorf("Error finding conversation: %v", err)
 	}
-	if conv != nil {
-		return conv, nil
+	if conv == nil {
+		if conv, err = c.CreateConversation(contactID); err != nil {
+			return nil, err
+		}
+		conv = c.keepOldestConversation(contactID, conv)
+	}
+	contactConversations.Set(c.scopedID(contactID), *conv)
+	return conv, nil
+}
+
+// keepOldestConversation resolves created when the contact already had an older open conversation,
+// and returns the conversation to use.
+func (c *Client) keepOldestConversation(contactID int, created *Conversation) *Conversation {
+	conversations, err := c.openConversations(contactID)
+	if err != nil || len(conversations) == 0 || conversations[0].ID >= created.ID {
+		return created
+	}
+	oldest := conversations[0]
+	logrus.Warnf("Chatwoot: Contact %d already had conversation %d, resolving duplicate conversation %d", contactID, oldest.ID, created.ID)
+	if err := c.ResolveConversation(created.ID); err != nil {
+		logrus.Warnf("Chatwoot: Failed to resolve duplicate conversation %d: %v", created.ID, err)
+	}
+	return &oldest
+}
+
+// ResolveConversation marks a conversation as resolved.
+func (c *Client) ResolveConversation(conversationID int) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/toggle_status", c.BaseURL, c.AccountID, conversationID)
+	if _, err := c.doRequest("POST", endpoint, map[string]string{"status": "resolved"}, nil); err != nil {
+		return fmt.Errorf("failed to resolve conversation: %w", err)
+	}
+	return nil
+}
+
+// ToggleConversationStatus sets the status of a conversation: "open", "pending", "snoozed" or "resolved".
+func (c *Client) ToggleConversationStatus(conversationID int, status string) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/toggle_status", c.BaseURL, c.AccountID, conversationID)
+	if _, err := c.doRequest("POST", endpoint, map[string]string{"status": status}, nil); err != nil {
+		return fmt.Errorf("failed to set the status of conversation %d to %s: %w", conversationID, status, err)
+	}
+	return nil
+}
+
+// AssignConversation assigns a conversation to a team, whose agents then see it in their queue.
+func (c *Client) AssignConversation(conversationID, teamID int) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/assignments", c.BaseURL, c.AccountID, conversationID)
+	if _, err := c.doRequest("POST", endpoint, map[string]int{"team_id": teamID}, nil); err != nil {
+		return fmt.Errorf("failed to assign conversation %d to team %d: %w", conversationID, teamID, err)
+	}
+	return nil
+}
+
+// GetConversationLabels returns the labels currently attached to a conversation
+func (c *Client) GetConversationLabels(conversationID int) ([]string, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/labels", c.BaseURL, c.AccountID, conversationID)
+
+	var result struct {
+		Payload []string `json:"payload"`
+	}
+	if _, err := c.doRequest("GET", endpoint, nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get conversation labels: %w", err)
 	}
-	return c.CreateConversation(contactID)
+	return result.Payload, nil
 }
 
+// AddConversationLabel attaches a label to a conversation, keeping the labels it already has.
+// Chatwoot replaces the whole label list on POST, so the current list is fetched and merged first.
+func (c *Client) AddConversationLabel(conversationID int, label string) error {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil
+	}
+
+	current, err := c.GetConversationLabels(conversationID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range current {
+		if existing == label {
+			return nil
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/labels", c.BaseURL, c.AccountID, conversationID)
+	payload := map[string]interface{}{
+		"labels": append(current, label),
+	}
+	if _, err := c.doRequest("POST", endpoint, payload, nil); err != nil {
+		return fmt.Errorf("failed to add conversation label: %w", err)
+	}
+	return nil
+}
+
+// DeviceLabel converts a device alias into a valid Chatwoot label name
+// (lowercase letters, digits, '-' and '_').
+func DeviceLabel(alias string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(alias)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	label := strings.Trim(b.String(), "-")
+	if label == "" {
+		return ""
+	}
+	return "wa-" + label
+}
+
+// DeleteMessage deletes a message of the conversation. It fails with ErrMessageDeleteUnsupported on
+// Chatwoot versions without the endpoint.
 func (c *Client) DeleteMessage(conversationID int, messageID int) error {
+	if !c.Capabilities().SupportsMessageDelete {
+		return ErrMessageDeleteUnsupported
+	}
 	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/messages/%d", c.BaseURL, c.AccountID, conversationID, messageID)
 	req, err := http.NewRequest("DELETE", endpoint, nil)
 	if err != nil {
@@ -538,31 +970,118 @@ func (c *Client) DeleteMessage(conversationID int, messageID int) error {
 
 	if resp.StatusCode != 200 && resp.StatusCode != 204 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to delete message: status=%d body=%s", resp.StatusCode, string(body))
+		return responseError("failed to delete message", resp, body)
 	}
 
 	return nil
 }
-func (c *Client) CreateMessage(conversationID int, content string, messageType string, attachments []string, sourceID string, contentType string) (int, error) {
+
+// CreateMessage posts content to the conversation. A non-zero createdAt backdates the message to
+// that time, see CreateMessageFromRequest.
+func (c *Client) CreateMessage(conversationID int, content string, messageType string, attachments []string, sourceID string, contentType string, createdAt time.Time) (int, error) {
+	return c.CreateMessageFromRequest(conversationID, CreateMessageRequest{
+		Content:     content,
+		MessageType: messageType,
+		ContentType: contentType,
+		CreatedAt:   createdAt,
+	}, attachments, sourceID)
+}
+
+// CreatePrivateNote adds a note to the conversation that only agents can see.
+func (c *Client) CreatePrivateNote(conversationID int, content string) (int, error) {
+	return c.CreateMessageFromRequest(conversationID, CreateMessageRequest{
+		Content:     content,
+		MessageType: "outgoing",
+		Private:     true,
+	}, nil, "")
+}
+
+// CreateMessageFromRequest posts msg to the conversation, uploading attachments when given.
+//
+// A message with CreatedAt is backdated to it. Chatwoot versions that do not accept created_at
+// answer with a 400 or 422; the message is then posted again without it, and once that works,
+// later messages of the client are posted without it too, until ProbeCreatedAt. Versions known not
+// to support it are posted without it right away.
+func (c *Client) CreateMessageFromRequest(conversationID int, msg CreateMessageRequest, attachments []string, sourceID string) (int, error) {
 	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/messages", c.BaseURL, c.AccountID, conversationID)
 
 	if len(attachments) > 0 {
-		return c.createMessageWithAttachments(endpoint, content, messageType, attachments, sourceID)
+		var notes []string
+		attachments, notes = omitOversizedAttachments(attachments)
+		if len(notes) > 0 {
+			msg.Content = strings.TrimSpace(msg.Content + "\n" + strings.Join(notes, "\n"))
+			if msg.UndatedContent != "" {
+				msg.UndatedContent = strings.TrimSpace(msg.UndatedContent + "\n" + strings.Join(notes, "\n"))
+			}
+		}
+	}
+	if msg.CreatedAt.IsZero() {
+		return c.postMessage(endpoint, msg, attachments, sourceID)
+	}
+	if c.createdAtRejected.Load() {
+		return c.postMessage(endpoint, msg.undated(), attachments, sourceID)
+	}
+
+	id, err := c.postMessage(endpoint, msg, attachments, sourceID)
+	var statusErr *statusError
+	if err == nil || !errors.As(err, &statusErr) || (statusErr.status != http.StatusBadRequest && statusErr.status != http.StatusUnprocessableEntity) {
+		return id, err
+	}
+	id, retryErr := c.postMessage(endpoint, msg.undated(), attachments, sourceID)
+	if retryErr != nil {
+		// Not about created_at after all
+		return 0, err
+	}
+	if c.createdAtRejected.CompareAndSwap(false, true) {
+		logrus.Warnf("Chatwoot: The server does not accept backdated messages (%v); imported messages keep the time of the import", err)
+	}
+	return id, nil
+}
+
+// ProbeCreatedAt lets the next backdated message try created_at again, at the start of a sync run.
+func (c *Client) ProbeCreatedAt() {
+	if c == nil {
+		return
+	}
+	c.createdAtRejected.Store(false)
+}
+
+// undated returns msg without CreatedAt, with UndatedContent as its content when set.
+func (msg CreateMessageRequest) undated() CreateMessageRequest {
+	msg.CreatedAt = time.Time{}
+	if msg.UndatedContent != "" {
+		msg.Content = msg.UndatedContent
+	}
+	return msg
+}
+
+// postMessage sends one create-message request: multipart with attachments, else JSON.
+func (c *Client) postMessage(endpoint string, msg CreateMessageRequest, attachments []string, sourceID string) (int, error) {
+	if len(attachments) > 0 {
+		return c.createMessageWithAttachments(endpoint, msg, attachments, sourceID)
 	}
 
 	// Usamos um map para evitar erros com structs restritas e injetar o source_id
 	payload := map[string]interface{}{
-		"content":      content,
-		"message_type": messageType,
-		"private":      false,
+		"content":      msg.Content,
+		"message_type": msg.MessageType,
+		"private":      msg.Private,
 	}
 
 	if sourceID != "" {
 		payload["source_id"] = sourceID
 	}
 
-	if contentType != "" {
-		payload["content_type"] = contentType
+	if msg.ContentType != "" {
+		payload["content_type"] = msg.ContentType
+	}
+
+	if len(msg.ContentAttributes) > 0 {
+		payload["content_attributes"] = msg.ContentAttributes
+	}
+
+	if !msg.CreatedAt.IsZero() {
+		payload["created_at"] = msg.CreatedAt.UTC().Format(time.RFC3339)
 	}
 
 	jsonPayload, err := json.Marshal(payload)
@@ -587,7 +1106,7 @@ func (c *Client) CreateMessage(conversationID int, content string, messageType s
 	bodyBytes, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("failed to create message: status %d body %s", resp.StatusCode, string(bodyBytes))
+		return 0, responseError("failed to create message", resp, bodyBytes)
 	}
 
 	var result struct {
@@ -601,9 +1120,11 @@ func (c *Client) CreateMessage(conversationID int, content string, messageType s
 }
 
 type ChatwootMessage struct {
-	ID       int    `json:"id"`
-	Content  string `json:"content"`
-	SourceID string `json:"source_id"`
+	ID          int          `json:"id"`
+	Content     string       `json:"content"`
+	SourceID    string       `json:"source_id"`
+	CreatedAt   int64        `json:"created_at"`
+	Attachments []Attachment `json:"attachments"`
 }
 
 // ToggleTypingStatus envia o estado de digitação para o Chatwoot ("on" ou "off")
@@ -635,63 +1156,166 @@ func (c *Client) ToggleTypingStatus(conversationID int, status string) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to toggle typing status: status %d body %s", resp.StatusCode, string(body))
+		return responseError("failed to toggle typing status", resp, body)
 	}
 
 	return nil
 }
 
-func (c *Client) GetConversationMessages(conversationID int) ([]ChatwootMessage, error) {
-	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/messages", c.BaseURL, c.AccountID, conversationID)
-	req, err := http.NewRequest("GET", endpoint, nil)
+// maxConversationMessagePages bounds how far back GetConversationMessages pages through a conversation.
+const maxConversationMessagePages = 50
+
+// GetConversationMessages returns the messages of a conversation created since the given time, oldest
+// first. Chatwoot only returns the latest page of a conversation, so older pages are fetched with the
+// before cursor until they reach since or maxConversationMessagePages were read. A zero since reads as
+// far back as the page limit allows.
+func (c *Client) GetConversationMessages(conversationID int, since time.Time) ([]ChatwootMessage, error) {
+	var all []ChatwootMessage
+	before := 0
+
+	for page := 0; page < maxConversationMessagePages; page++ {
+		endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d/messages", c.BaseURL, c.AccountID, conversationID)
+		if before > 0 {
+			endpoint = fmt.Sprintf("%s?before=%d", endpoint, before)
+		}
+
+		var result struct {
+			Payload []ChatwootMessage `json:"payload"`
+		}
+		if _, err := c.doRequest("GET", endpoint, nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to get messages: %w", err)
+		}
+		if len(result.Payload) == 0 {
+			break
+		}
+
+		reachedSince := false
+		oldestID := 0
+		for _, msg := range result.Payload {
+			if oldestID == 0 || msg.ID < oldestID {
+				oldestID = msg.ID
+			}
+			if msg.CreatedAt > 0 && time.Unix(msg.CreatedAt, 0).Before(since) {
+				reachedSince = true
+				continue
+			}
+			all = append(all, msg)
+		}
+
+		if reachedSince || oldestID == 0 || oldestID == before {
+			break
+		}
+		before = oldestID
+		if page == maxConversationMessagePages-1 {
+			logrus.Warnf("Chatwoot: Stopped reading conversation %d after %d pages of messages", conversationID, maxConversationMessagePages)
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].CreatedAt != all[j].CreatedAt {
+			return all[i].CreatedAt < all[j].CreatedAt
+		}
+		return all[i].ID < all[j].ID
+	})
+	return all, nil
+}
+
+// omitOversizedAttachments drops attachments larger than CHATWOOT_MAX_ATTACHMENT_SIZE, which Chatwoot
+// would only reject after the whole upload, and returns a note for each one left out.
+func omitOversizedAttachments(attachments []string) (kept, notes []string) {
+	limit := config.ChatwootMaxAttachmentSize
+	if limit <= 0 {
+		return attachments, nil
+	}
+	kept = make([]string, 0, len(attachments))
+	for _, fp := range attachments {
+		info, err := os.Stat(fp)
+		if err == nil && info.Size() > limit {
+			logrus.Warnf("Chatwoot: Omitting attachment %s: %d bytes exceeds the %d byte limit", fp, info.Size(), limit)
+			notes = append(notes, fmt.Sprintf("[attachment omitted: %s exceeds %s limit]", humanize.Bytes(uint64(info.Size())), humanize.Bytes(uint64(limit))))
+			continue
+		}
+		kept = append(kept, fp)
+	}
+	return kept, notes
+}
+
+// createMessageWithAttachments streams the multipart body to Chatwoot as it is written, so uploads
+// do not hold whole files in memory.
+func (c *Client) createMessageWithAttachments(endpoint string, msg CreateMessageRequest, attachments []string, sourceID string) (int, error) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	writer := multipart.NewWriter(pw)
+	contentType := writer.FormDataContentType()
+
+	req, err := http.NewRequest("POST", endpoint, pr)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	go func() {
+		pw.CloseWithError(writeMessageMultipart(writer, msg, attachments, sourceID))
+	}()
 
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("api_access_token", c.APIToken)
+
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
+	respBody, _ := io.ReadAll(resp.Body)
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get messages: status %d body %s", resp.StatusCode, string(body))
+		return 0, responseError("failed to create message with attachments", resp, respBody)
+	}
+
+	if logrus.IsLevelEnabled(logrus.DebugLevel) {
+		logrus.Debugf("Chatwoot: createMessageWithAttachments response status=%d body=%s", resp.StatusCode, describeResponseBody(resp, respBody))
 	}
 
 	var result struct {
-		Payload []ChatwootMessage `json:"payload"`
+		ID int `json:"id"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+	if err := json.Unmarshal(respBody, &result); err == nil && result.ID != 0 {
+		return result.ID, nil
 	}
-	return result.Payload, nil
-}
 
-func (c *Client) createMessageWithAttachments(endpoint, content, messageType string, attachments []string, sourceID string) (int, error) {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	return 0, nil
+}
 
-	_ = writer.WriteField("content", content)
-	_ = writer.WriteField("message_type", messageType)
-	_ = writer.WriteField("private", "false")
+// writeMessageMultipart writes the multipart body of a message with attachments. Voice notes are
+// listed in is_recorded_audio, which Chatwoot versions without recorded audio ignore.
+func writeMessageMultipart(writer *multipart.Writer, msg CreateMessageRequest, attachments []string, sourceID string) error {
+	_ = writer.WriteField("content", msg.Content)
+	_ = writer.WriteField("message_type", msg.MessageType)
+	_ = writer.WriteField("private", strconv.FormatBool(msg.Private))
 
 	if sourceID != "" {
 		_ = writer.WriteField("source_id", sourceID)
 	}
+	if len(msg.ContentAttributes) > 0 {
+		// Chatwoot parses a JSON string here into the attributes hash
+		if attrs, err := json.Marshal(msg.ContentAttributes); err == nil {
+			_ = writer.WriteField("content_attributes", string(attrs))
+		}
+	}
+	if !msg.CreatedAt.IsZero() {
+		_ = writer.WriteField("created_at", msg.CreatedAt.UTC().Format(time.RFC3339))
+	}
 	recordedAudioFilenames := make([]string, 0, len(attachments))
 	recordedAudioSeen := make(map[string]struct{}, len(attachments))
 
 	for _, filePath := range attachments {
-		func(fp string) {
+		err := func(fp string) error {
 			uploadPath, cleanup := prepareAttachmentForUpload(fp)
 			defer cleanup()
 
 			file, err := os.Open(uploadPath)
 			if err != nil {
 				logrus.Errorf("Failed to open file %s: %v", uploadPath, err)
-				return
+				return nil
 			}
 			defer file.Close()
 
@@ -727,14 +1351,16 @@ func (c *Client) createMessageWithAttachments(endpoint, content, messageType str
 
 			part, err := writer.CreatePart(h)
 			if err != nil {
-				logrus.Errorf("Failed to create form part for %s: %v", uploadPath, err)
-				return
+				return fmt.Errorf("failed to create form part for %s: %w", uploadPath, err)
 			}
 			if _, err := io.Copy(part, file); err != nil {
-				logrus.Errorf("Failed to copy file %s to multipart body: %v", uploadPath, err)
-				return
+				return fmt.Errorf("failed to copy file %s to multipart body: %w", uploadPath, err)
 			}
+			return nil
 		}(filePath)
+		if err != nil {
+			return err
+		}
 	}
 
 	if len(recordedAudioFilenames) > 0 {
@@ -748,45 +1374,9 @@ func (c *Client) createMessageWithAttachments(endpoint, content, messageType str
 	}
 
 	if err := writer.Close(); err != nil {
-		return 0, fmt.Errorf("failed to close multipart writer: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", endpoint, body)
-	if err != nil {
-		return 0, err
-	}
-
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("api_access_token", c.APIToken)
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	respBody, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("failed to create message with attachments: status %d body %s", resp.StatusCode, string(respBody))
-	}
-
-	if logrus.IsLevelEnabled(logrus.DebugLevel) {
-		bodyForLog := string(respBody)
-		if len(bodyForLog) > 2048 {
-			bodyForLog = bodyForLog[:2048] + "...(truncated)"
-		}
-		logrus.Debugf("Chatwoot: createMessageWithAttachments response status=%d body=%s", resp.StatusCode, bodyForLog)
-	}
-
-	var result struct {
-		ID int `json:"id"`
+		return fmt.Errorf("failed to close multipart writer: %w", err)
 	}
-	if err := json.Unmarshal(respBody, &result); err == nil && result.ID != 0 {
-		return result.ID, nil
-	}
-
-	return 0, nil
+	return nil
 }
 
 func (c *Client) UpdateContactAvatar(contactID int, avatarData []byte) error {
@@ -835,18 +1425,29 @@ func (c *Client) UpdateContactAvatar(contactID int, avatarData []byte) error {
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to update avatar: status %d body %s", resp.StatusCode, string(respBody))
+		return responseError("failed to update avatar", resp, respBody)
 	}
 
 	return nil
 }
 
+// UpdateContactAttributes sets the identifier of a group or LID contact and merges custom attributes
+// into the contact. When the first attribute write of an account is rejected, the missing attribute
+// definitions are created and the write is tried again.
 func (c *Client) UpdateContactAttributes(contactID int, identifier string, customAttributes map[string]interface{}, isGroup bool) error {
+	err := c.updateContactAttributes(contactID, identifier, customAttributes, isGroup)
+	if err != nil && len(customAttributes) > 0 && c.ensureAttributesAfter(err) {
+		return c.updateContactAttributes(contactID, identifier, customAttributes, isGroup)
+	}
+	return err
+}
+
+func (c *Client) updateContactAttributes(contactID int, identifier string, customAttributes map[string]interface{}, isGroup bool) error {
 	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/%d", c.BaseURL, c.AccountID, contactID)
 
 	payload := map[string]interface{}{}
 
-	if identifier != "" && (isGroup || strings.HasSuffix(identifier, "@lid")) {
+	if identifier != "" && identifierBased(identifier, isGroup) {
 		payload["identifier"] = identifier
 	}
 
@@ -875,7 +1476,7 @@ func (c *Client) UpdateContactAttributes(contactID int, identifier string, custo
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to update contact attributes: status %d body %s", resp.StatusCode, string(body))
+		return responseError("failed to update contact attributes", resp, body)
 	}
 
 	return nil
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 )
 
 func TestCreateMessageWithAttachments_SendsRecordedAudioField(t *testing.T) {
@@ -51,7 +53,7 @@ func TestCreateMessageWithAttachments_SendsRecordedAudioField(t *testing.T) {
 			Timeout: 30 * time.Second,
 		},
 	}
-	msgID, err := c.CreateMessage(123, "audio", "incoming", []string{audioPath}, "", "")
+	msgID, err := c.CreateMessage(123, "audio", "incoming", []string{audioPath}, "", "", time.Time{})
 	if err != nil {
 		t.Fatalf("CreateMessage returned error: %v", err)
 	}
@@ -75,3 +77,85 @@ func TestCreateMessageWithAttachments_SendsRecordedAudioField(t *testing.T) {
 		t.Fatalf("expected is_recorded_audio to contain %q, got %#v", filepath.Base(audioPath), recorded)
 	}
 }
+
+func TestCreateMessageWithAttachments_OmitsOversizedFilesAndStreams(t *testing.T) {
+	prevLimit := config.ChatwootMaxAttachmentSize
+	config.ChatwootMaxAttachmentSize = 1000
+	t.Cleanup(func() { config.ChatwootMaxAttachmentSize = prevLimit })
+
+	tmpDir := t.TempDir()
+	bigPath := filepath.Join(tmpDir, "big.mp4")
+	smallPath := filepath.Join(tmpDir, "small.pdf")
+	if err := os.WriteFile(bigPath, make([]byte, 1800), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(smallPath, []byte("%PDF-1.4 small"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		gotContent       string
+		gotFiles         []string
+		gotContentLength int64
+		gotChunked       bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotContentLength = r.ContentLength
+		gotChunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			t.Errorf("failed to parse multipart form: %v", err)
+			return
+		}
+		gotContent = r.FormValue("content")
+		for _, f := range r.MultipartForm.File["attachments[]"] {
+			gotFiles = append(gotFiles, f.Filename)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":7}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+	if _, err := c.CreateMessage(1, "files", "incoming", []string{bigPath, smallPath}, "", "", time.Time{}); err != nil {
+		t.Fatalf("CreateMessage returned error: %v", err)
+	}
+
+	if want := "files\n[attachment omitted: 1.8 kB exceeds 1.0 kB limit]"; gotContent != want {
+		t.Fatalf("content = %q, want %q", gotContent, want)
+	}
+	if len(gotFiles) != 1 || gotFiles[0] != "small.pdf" {
+		t.Fatalf("uploaded %v, want only small.pdf", gotFiles)
+	}
+	if gotContentLength != -1 || !gotChunked {
+		t.Fatalf("body was sent with Content-Length %d (chunked: %v), want a streamed body", gotContentLength, gotChunked)
+	}
+}
+
+func TestCreateMessage_AllAttachmentsOmittedSendsNoteOnly(t *testing.T) {
+	prevLimit := config.ChatwootMaxAttachmentSize
+	config.ChatwootMaxAttachmentSize = 10
+	t.Cleanup(func() { config.ChatwootMaxAttachmentSize = prevLimit })
+
+	bigPath := filepath.Join(t.TempDir(), "big.mp4")
+	if err := os.WriteFile(bigPath, make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("expected a JSON body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":8}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+	if _, err := c.CreateMessage(1, "", "incoming", []string{bigPath}, "", "", time.Time{}); err != nil {
+		t.Fatalf("CreateMessage returned error: %v", err)
+	}
+	if want := "[attachment omitted: 100 B exceeds 10 B limit]"; got["content"] != want {
+		t.Fatalf("content = %v, want %q", got["content"], want)
+	}
+}
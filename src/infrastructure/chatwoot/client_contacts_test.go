@@ -0,0 +1,177 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+// pagedContactServer serves total contacts sharing a phone prefix over pages of 15, like Chatwoot
+// search. The contact of match is at position matchAt. filter sets whether POST /contacts/filter exists.
+func pagedContactServer(t *testing.T, total, matchAt int, match Contact, filter bool) (*Client, *[]string) {
+	t.Helper()
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/1/")
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		requests = append(requests, fmt.Sprintf("%s %s page=%d", r.Method, path, page))
+		switch {
+		case r.Method == http.MethodPost && path == "contacts/filter":
+			if !filter {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var body struct {
+				Payload []struct {
+					AttributeKey string   `json:"attribute_key"`
+					Values       []string `json:"values"`
+				} `json:"payload"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			var found []Contact
+			if len(body.Payload) == 1 && body.Payload[0].AttributeKey == "phone_number" && body.Payload[0].Values[0] == match.PhoneNumber {
+				found = append(found, match)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"meta": map[string]any{"count": len(found)}, "payload": found})
+		case r.Method == http.MethodGet && path == "contacts/search":
+			var contacts []Contact
+			for i := (page - 1) * contactSearchPageSize; i < page*contactSearchPageSize && i < total; i++ {
+				if i == matchAt {
+					contacts = append(contacts, match)
+					continue
+				}
+				contacts = append(contacts, Contact{ID: 1000 + i, PhoneNumber: fmt.Sprintf("%s%02d", match.PhoneNumber, i)})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"meta": map[string]any{"count": total}, "payload": contacts})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}, &requests
+}
+
+func TestFindContactByIdentifier_ReadsLaterSearchPages(t *testing.T) {
+	match := Contact{ID: 7, PhoneNumber: "+628123456"}
+	c, requests := pagedContactServer(t, 40, 20, match, false)
+
+	contact, err := c.FindContactByIdentifier("628123456", false)
+	if err != nil || contact == nil || contact.ID != 7 {
+		t.Fatalf("expected contact 7 from page 2, got %+v, %v", contact, err)
+	}
+	want := []string{"POST contacts/filter page=1", "GET contacts/search page=1", "GET contacts/search page=2"}
+	if strings.Join(*requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("requests = %v, want %v", *requests, want)
+	}
+
+	// The missing filter API is remembered
+	*requests = nil
+	if _, err := c.FindContactByIdentifier("628123456", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(*requests) == 0 || strings.HasPrefix((*requests)[0], "POST") {
+		t.Errorf("expected no second filter request, got %v", *requests)
+	}
+}
+
+func TestFindContactByIdentifier_StopsAtPageCap(t *testing.T) {
+	prev := config.ChatwootContactSearchMaxPages
+	config.ChatwootContactSearchMaxPages = 2
+	t.Cleanup(func() { config.ChatwootContactSearchMaxPages = prev })
+
+	c, requests := pagedContactServer(t, 60, 50, Contact{ID: 7, PhoneNumber: "+628123456"}, false)
+
+	contact, err := c.FindContactByIdentifier("628123456", false)
+	if err != nil || contact != nil {
+		t.Fatalf("expected no contact within 2 pages, got %+v, %v", contact, err)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("expected the filter and 2 search pages, got %v", *requests)
+	}
+}
+
+func TestFindContactByIdentifier_UsesFilterWhenAvailable(t *testing.T) {
+	c, requests := pagedContactServer(t, 40, 20, Contact{ID: 7, PhoneNumber: "+628123456"}, true)
+
+	contact, err := c.FindContactByIdentifier("628123456@s.whatsapp.net", false)
+	if err != nil || contact == nil || contact.ID != 7 {
+		t.Fatalf("expected contact 7 from the filter, got %+v, %v", contact, err)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("expected a single filter request, got %v", *requests)
+	}
+}
+
+func TestMatchContact_PrefersExactMatches(t *testing.T) {
+	contacts := []Contact{
+		{ID: 1, PhoneNumber: "+55 11 99999-0000", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "5511999990001"}},
+		{ID: 2, PhoneNumber: "+55 11 99999-0001"},
+		{ID: 3, PhoneNumber: "+5511999990001000"},
+		{ID: 4, Identifier: "5511999990001@s.whatsapp.net"},
+	}
+
+	if got := matchContact(contacts, "5511999990001@s.whatsapp.net", "+5511999990001", false); got == nil || got.ID != 4 {
+		t.Errorf("expected the identifier match, got %+v", got)
+	}
+	if got := matchContact(contacts[:3], "5511999990001", "+5511999990001", false); got == nil || got.ID != 2 {
+		t.Errorf("expected the phone match, got %+v", got)
+	}
+	if got := matchContact(contacts[:1], "5511999990001", "+5511999990001", false); got == nil || got.ID != 1 {
+		t.Errorf("expected the attribute match, got %+v", got)
+	}
+	if got := matchContact(contacts[2:3], "5511999990001", "+5511999990001", false); got != nil {
+		t.Errorf("expected a longer number not to match, got %+v", got)
+	}
+}
+
+func TestFindContactByIdentifier_FindsOtherPhoneForm(t *testing.T) {
+	var updates []map[string]string
+	var searched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/contacts/filter"):
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/contacts/search"):
+			q := r.URL.Query().Get("q")
+			searched = append(searched, q)
+			if q == "+5531987654321" {
+				_, _ = w.Write([]byte(`{"meta":{"count":1},"payload":[{"id":7,"name":"Joao","phone_number":"+5531987654321"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"meta":{"count":0},"payload":[]}`))
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/contacts/7"):
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			updates = append(updates, body)
+			_, _ = w.Write([]byte(`{"payload":{}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+
+	// WhatsApp registered this Belo Horizonte mobile without the ninth digit the agent saved
+	contact, err := c.FindContactByIdentifier("553187654321", false)
+	if err != nil {
+		t.Fatalf("FindContactByIdentifier returned error: %v", err)
+	}
+	if contact == nil || contact.ID != 7 {
+		t.Fatalf("expected contact 7, got %+v", contact)
+	}
+	if want := []string{"+553187654321", "+5531987654321"}; strings.Join(searched, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected searches %v, got %v", want, searched)
+	}
+	if len(updates) != 1 || updates[0]["phone_number"] != "+553187654321" || contact.PhoneNumber != "+553187654321" {
+		t.Fatalf("expected the phone number to be changed to the registered form, got %v (%s)", updates, contact.PhoneNumber)
+	}
+}
@@ -0,0 +1,57 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeviceLabel(t *testing.T) {
+	tests := []struct {
+		alias string
+		want  string
+	}{
+		{alias: "sales", want: "wa-sales"},
+		{alias: "Support Team", want: "wa-support-team"},
+		{alias: "628123@s.whatsapp.net", want: "wa-628123-s-whatsapp-net"},
+		{alias: "   ", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := DeviceLabel(tt.alias); got != tt.want {
+			t.Fatalf("DeviceLabel(%q)=%q want=%q", tt.alias, got, tt.want)
+		}
+	}
+}
+
+func TestAddConversationLabel_MergesExistingLabels(t *testing.T) {
+	var posted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`{"payload":["vip"]}`))
+		case http.MethodPost:
+			var body struct {
+				Labels []string `json:"labels"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode labels body: %v", err)
+			}
+			posted = body.Labels
+			_, _ = w.Write([]byte(`{"payload":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	if err := c.AddConversationLabel(10, "wa-sales"); err != nil {
+		t.Fatalf("AddConversationLabel returned error: %v", err)
+	}
+
+	if len(posted) != 2 || posted[0] != "vip" || posted[1] != "wa-sales" {
+		t.Fatalf("expected merged labels [vip wa-sales], got %v", posted)
+	}
+}
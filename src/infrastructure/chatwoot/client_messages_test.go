@@ -0,0 +1,216 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreatePrivateNote_SendsPrivateFlag(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode message body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":55}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	id, err := c.CreatePrivateNote(10, "➕ +5511999 added by +5511888")
+	if err != nil {
+		t.Fatalf("CreatePrivateNote returned error: %v", err)
+	}
+	if id != 55 {
+		t.Fatalf("expected message id 55, got %d", id)
+	}
+	if body["private"] != true || body["message_type"] != "outgoing" {
+		t.Fatalf("expected private outgoing note, got %v", body)
+	}
+}
+
+func TestCreateMessage_IsPublic(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	if _, err := c.CreateMessage(10, "hi", "incoming", nil, "src", "", time.Time{}); err != nil {
+		t.Fatalf("CreateMessage returned error: %v", err)
+	}
+	if body["private"] != false || body["source_id"] != "src" {
+		t.Fatalf("expected public message with source_id, got %v", body)
+	}
+}
+
+// createdAtServer records the content and created_at of the messages posted to it, as JSON or
+// multipart. With rejectCreatedAt it answers messages carrying created_at like an older Chatwoot.
+type createdAtServer struct {
+	rejectCreatedAt bool
+	posts           []createdAtPost
+}
+
+type createdAtPost struct {
+	content   string
+	createdAt string
+	multipart bool
+}
+
+func (s *createdAtServer) client(t *testing.T) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var post createdAtPost
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("failed to parse multipart form: %v", err)
+			}
+			post = createdAtPost{content: r.FormValue("content"), createdAt: r.FormValue("created_at"), multipart: true}
+		} else {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			post.content, _ = body["content"].(string)
+			post.createdAt, _ = body["created_at"].(string)
+		}
+		s.posts = append(s.posts, post)
+		if s.rejectCreatedAt && post.createdAt != "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"message":"unknown attribute 'created_at' for Message."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":1}`))
+	}))
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func TestCreateMessage_SendsCreatedAt(t *testing.T) {
+	attachment := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(attachment, []byte("jpeg"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sent := time.Date(2024, 5, 1, 9, 30, 0, 0, time.FixedZone("WIB", 7*3600))
+	srv := &createdAtServer{}
+	c := srv.client(t)
+
+	if _, err := c.CreateMessage(10, "hi", "incoming", nil, "src", "", sent); err != nil {
+		t.Fatalf("CreateMessage returned error: %v", err)
+	}
+	if _, err := c.CreateMessage(10, "photo", "incoming", []string{attachment}, "src2", "", sent); err != nil {
+		t.Fatalf("CreateMessage with an attachment returned error: %v", err)
+	}
+	if _, err := c.CreateMessage(10, "live", "incoming", nil, "src3", "", time.Time{}); err != nil {
+		t.Fatalf("CreateMessage returned error: %v", err)
+	}
+
+	want := []createdAtPost{
+		{content: "hi", createdAt: "2024-05-01T02:30:00Z"},
+		{content: "photo", createdAt: "2024-05-01T02:30:00Z", multipart: true},
+		{content: "live"},
+	}
+	if fmt.Sprint(srv.posts) != fmt.Sprint(want) {
+		t.Fatalf("unexpected posts:\n got %+v\nwant %+v", srv.posts, want)
+	}
+}
+
+func TestCreateMessageFromRequest_FallsBackWhenCreatedAtIsRejected(t *testing.T) {
+	srv := &createdAtServer{rejectCreatedAt: true}
+	c := srv.client(t)
+	msg := CreateMessageRequest{Content: "hi", UndatedContent: "[2024-05-01 09:30] hi", MessageType: "incoming", CreatedAt: time.Unix(1714530600, 0)}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.CreateMessageFromRequest(10, msg, nil, "src"); err != nil {
+			t.Fatalf("CreateMessageFromRequest returned error: %v", err)
+		}
+	}
+	// The first message is tried once with created_at, the second goes without it right away
+	want := []createdAtPost{
+		{content: "hi", createdAt: "2024-05-01T02:30:00Z"},
+		{content: "[2024-05-01 09:30] hi"},
+		{content: "[2024-05-01 09:30] hi"},
+	}
+	if fmt.Sprint(srv.posts) != fmt.Sprint(want) {
+		t.Fatalf("unexpected posts:\n got %+v\nwant %+v", srv.posts, want)
+	}
+
+	srv.rejectCreatedAt = false
+	c.ProbeCreatedAt()
+	if _, err := c.CreateMessageFromRequest(10, msg, nil, "src"); err != nil {
+		t.Fatalf("CreateMessageFromRequest returned error: %v", err)
+	}
+	if last := srv.posts[len(srv.posts)-1]; last.content != "hi" || last.createdAt == "" {
+		t.Errorf("expected created_at tried again after ProbeCreatedAt, got %+v", last)
+	}
+}
+
+func TestCreateMessageFromRequest_OtherErrorsKeepCreatedAt(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"message":"Conversation is resolved"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	msg := CreateMessageRequest{Content: "hi", MessageType: "incoming", CreatedAt: time.Unix(1714530600, 0)}
+	if _, err := c.CreateMessageFromRequest(10, msg, nil, "src"); err == nil || !strings.Contains(err.Error(), "Conversation is resolved") {
+		t.Fatalf("expected the original error, got %v", err)
+	}
+	if calls != 2 || c.createdAtRejected.Load() {
+		t.Errorf("expected one retry and created_at kept, got %d calls, rejected %v", calls, c.createdAtRejected.Load())
+	}
+}
+
+func TestGetConversationMessages_ThreePagesInChronologicalOrder(t *testing.T) {
+	base := time.Now().Add(-time.Hour).Unix()
+	var befores []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before := r.URL.Query().Get("before")
+		befores = append(befores, before)
+		w.Header().Set("Content-Type", "application/json")
+		// Each page is the 2 messages before the cursor, oldest first like Chatwoot
+		switch before {
+		case "":
+			fmt.Fprintf(w, `{"payload":[{"id":5,"created_at":%d},{"id":6,"created_at":%d,"attachments":[{"id":60,"file_type":"image","file_size":2048}]}]}`, base+5, base+6)
+		case "5":
+			fmt.Fprintf(w, `{"payload":[{"id":3,"created_at":%d},{"id":4,"created_at":%d}]}`, base+3, base+4)
+		case "3":
+			fmt.Fprintf(w, `{"payload":[{"id":1,"created_at":%d},{"id":2,"created_at":%d}]}`, base+1, base+2)
+		case "1":
+			_, _ = w.Write([]byte(`{"payload":[]}`))
+		default:
+			t.Errorf("unexpected cursor %q", before)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	msgs, err := c.GetConversationMessages(9, time.Time{})
+	if err != nil {
+		t.Fatalf("GetConversationMessages returned error: %v", err)
+	}
+
+	var ids []int
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+	if fmt.Sprint(ids) != "[1 2 3 4 5 6]" {
+		t.Errorf("expected messages oldest first, got %v", ids)
+	}
+	if fmt.Sprint(befores) != "[ 5 3 1]" {
+		t.Errorf("unexpected page cursors %q", befores)
+	}
+	if att := msgs[5].Attachments; len(att) != 1 || att[0].FileType != "image" || att[0].FileSize != 2048 {
+		t.Errorf("expected attachment metadata, got %+v", att)
+	}
+}
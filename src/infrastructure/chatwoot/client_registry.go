@@ -0,0 +1,301 @@
+package chatwoot
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Target is a named Chatwoot account the bridge can post to, besides the one of CHATWOOT_URL.
+type Target struct {
+	Name      string
+	URL       string
+	APIToken  string
+	AccountID int
+	InboxID   int
+}
+
+// ParseTargets turns "name=url|token|accountID|inboxID" entries into targets; the inbox ID may be
+// left out. Malformed entries and repeated names are skipped with a warning.
+func ParseTargets(entries []string) []Target {
+	targets := make([]Target, 0, len(entries))
+	seen := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, rawTarget, _ := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		fields := strings.Split(rawTarget, "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if name == "" || strings.Contains(name, ":") || len(fields) < 3 || len(fields) > 4 || fields[0] == "" || fields[1] == "" {
+			logrus.Warnf("Chatwoot: ignoring invalid target %q (expected name=url|token|accountID|inboxID)", redactTarget(entry))
+			continue
+		}
+		target := Target{Name: name, URL: strings.TrimRight(fields[0], "/"), APIToken: fields[1]}
+		var err error
+		if target.AccountID, err = strconv.Atoi(fields[2]); err != nil || target.AccountID <= 0 {
+			logrus.Warnf("Chatwoot: ignoring target %s, invalid account ID %q", name, fields[2])
+			continue
+		}
+		if len(fields) == 4 && fields[3] != "" {
+			if target.InboxID, err = strconv.Atoi(fields[3]); err != nil || target.InboxID < 0 {
+				logrus.Warnf("Chatwoot: ignoring target %s, invalid inbox ID %q", name, fields[3])
+				continue
+			}
+		}
+		if _, ok := seen[name]; ok {
+			logrus.Warnf("Chatwoot: ignoring repeated target %s", name)
+			continue
+		}
+		seen[name] = struct{}{}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// redactTarget hides the API token of a target entry quoted in logs.
+func redactTarget(entry string) string {
+	fields := strings.Split(entry, "|")
+	if len(fields) > 1 {
+		fields[1] = "***"
+	}
+	return strings.Join(fields, "|")
+}
+
+// ParseDeviceTargets turns "deviceID:target" entries into a lookup table. The entry is split at its
+// last colon, since device JIDs may contain one.
+func ParseDeviceTargets(entries []string) map[string]string {
+	result := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 || strings.TrimSpace(entry[:i]) == "" || strings.TrimSpace(entry[i+1:]) == "" {
+			logrus.Warnf("Chatwoot: ignoring invalid device target %q (expected deviceID:target)", entry)
+			continue
+		}
+		result[strings.TrimSpace(entry[:i])] = strings.ToLower(strings.TrimSpace(entry[i+1:]))
+	}
+	return result
+}
+
+// ClientRegistry holds a client per Chatwoot target and the devices routed to each. Devices without
+// a route, and every device when no target is configured, use the default client of CHATWOOT_URL.
+type ClientRegistry struct {
+	clients map[string]*Client
+	routes  map[string]string // device ID or JID -> target name
+
+	// defaultClient returns the client of unrouted devices; GetDefaultClient unless replaced in tests
+	defaultClient func() *Client
+}
+
+// NewClientRegistry creates a client for each target and routes devices to them. Routes to unknown
+// targets are dropped with a warning so the device falls back to the default client.
+func NewClientRegistry(targets []Target, deviceTargets map[string]string) *ClientRegistry {
+	r := &ClientRegistry{
+		clients:       make(map[string]*Client, len(targets)),
+		routes:        make(map[string]string, len(deviceTargets)),
+		defaultClient: GetDefaultClient,
+	}
+	for _, target := range targets {
+		r.clients[target.Name] = NewTargetClient(target)
+	}
+	for deviceID, name := range deviceTargets {
+		if _, ok := r.clients[name]; !ok {
+			logrus.Warnf("Chatwoot: device %s is routed to unknown target %s, using the default Chatwoot", deviceID, name)
+			continue
+		}
+		r.routes[deviceID] = name
+	}
+	return r
+}
+
+var (
+	clientRegistry     *ClientRegistry
+	clientRegistryOnce sync.Once
+)
+
+// GetClientRegistry returns the registry built from CHATWOOT_TARGETS and CHATWOOT_DEVICE_TARGETS.
+func GetClientRegistry() *ClientRegistry {
+	clientRegistryOnce.Do(func() {
+		clientRegistry = NewClientRegistry(ParseTargets(config.ChatwootTargets), ParseDeviceTargets(config.ChatwootDeviceTargets))
+	})
+	return clientRegistry
+}
+
+// Client returns the client of a named target.
+func (r *ClientRegistry) Client(name string) (*Client, bool) {
+	client, ok := r.clients[strings.ToLower(strings.TrimSpace(name))]
+	return client, ok
+}
+
+// Targets returns the names of the configured targets, sorted.
+func (r *ClientRegistry) Targets() []string {
+	names := make([]string, 0, len(r.clients))
+	for name := range r.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TargetForDevice returns the target a device is routed to. Each ID is tried in turn, so a device
+// can be given by its alias and its JID; a JID is also matched through the alias of its device.
+func (r *ClientRegistry) TargetForDevice(deviceIDs ...string) (string, bool) {
+	if len(r.routes) == 0 {
+		return "", false
+	}
+	for _, deviceID := range deviceIDs {
+		deviceID = strings.TrimSpace(deviceID)
+		if deviceID == "" {
+			continue
+		}
+		if name, ok := r.routes[deviceID]; ok {
+			return name, true
+		}
+		if alias := resolveDeviceAlias(deviceID); alias != "" && alias != deviceID {
+			if name, ok := r.routes[alias]; ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// RoutedClient returns the client of the target the device is routed to, if it is routed.
+func (r *ClientRegistry) RoutedClient(deviceIDs ...string) (*Client, bool) {
+	name, ok := r.TargetForDevice(deviceIDs...)
+	if !ok {
+		return nil, false
+	}
+	return r.clients[name], true
+}
+
+// ClientForDevice returns the client of the target the device is routed to, else the default client,
+// posting to the inbox provisioned for the device by POST /chatwoot/setup when there is one.
+func (r *ClientRegistry) ClientForDevice(deviceIDs ...string) *Client {
+	if client, ok := r.RoutedClient(deviceIDs...); ok {
+		return client
+	}
+	client := r.defaultClient()
+	if inboxID, ok := provisionedInboxForDevice(deviceIDs...); ok && client != nil && inboxID != client.InboxID {
+		return client.forInbox(inboxID)
+	}
+	return client
+}
+
+// inboxClientKey identifies a client made by forInbox.
+type inboxClientKey struct {
+	base    *Client
+	inboxID int
+}
+
+// inboxClients holds the clients made by forInbox, so the forwards of a device reuse one.
+var inboxClients sync.Map // inboxClientKey -> *Client
+
+// forInbox returns a client of the same account as c that posts to inboxID. It shares the HTTP
+// client, circuit breaker and rate limit of c, which count per account.
+func (c *Client) forInbox(inboxID int) *Client {
+	key := inboxClientKey{base: c, inboxID: inboxID}
+	if client, ok := inboxClients.Load(key); ok {
+		return client.(*Client)
+	}
+	client, _ := inboxClients.LoadOrStore(key, &Client{
+		BaseURL:       c.BaseURL,
+		APIToken:      c.APIToken,
+		AccountID:     c.AccountID,
+		InboxID:       inboxID,
+		HTTPClient:    c.HTTPClient,
+		breaker:       c.breaker,
+		limiter:       c.limiter,
+		detectVersion: c.detectVersion,
+	})
+	return client.(*Client)
+}
+
+// TargetOfClient returns the name of the target a client was created for; false for any other client,
+// such as the default one.
+func (r *ClientRegistry) TargetOfClient(client *Client) (string, bool) {
+	for name, c := range r.clients {
+		if c == client {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// DevicesForTarget returns the device IDs routed to a target, sorted.
+func (r *ClientRegistry) DevicesForTarget(name string) []string {
+	var devices []string
+	for deviceID, target := range r.routes {
+		if target == name {
+			devices = append(devices, deviceID)
+		}
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// ClientForDevice returns the Chatwoot client of a device, by its alias or JID, through the registry.
+func ClientForDevice(deviceIDs ...string) *Client {
+	return GetClientRegistry().ClientForDevice(deviceIDs...)
+}
+
+// DeviceAliasResolver returns the alias of the device with a JID, or "" when it is unknown.
+type DeviceAliasResolver func(deviceJID string) string
+
+var (
+	deviceAliasResolverMu sync.RWMutex
+	deviceAliasResolver   DeviceAliasResolver
+)
+
+// SetDeviceAliasResolver sets how device JIDs are matched against routes given by device alias. It
+// is wired to the WhatsApp device manager, which this package cannot import.
+func SetDeviceAliasResolver(fn DeviceAliasResolver) {
+	deviceAliasResolverMu.Lock()
+	defer deviceAliasResolverMu.Unlock()
+	deviceAliasResolver = fn
+}
+
+func resolveDeviceAlias(deviceJID string) string {
+	deviceAliasResolverMu.RLock()
+	fn := deviceAliasResolver
+	deviceAliasResolverMu.RUnlock()
+	if fn == nil || !strings.Contains(deviceJID, "@") {
+		return ""
+	}
+	return fn(deviceJID)
+}
+
+type clientContextKey struct{}
+
+// ContextWithClient stores the Chatwoot client a request was resolved to in ctx.
+func ContextWithClient(ctx context.Context, client *Client) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext returns the Chatwoot client stored in ctx, else the default client.
+func ClientFromContext(ctx context.Context) *Client {
+	if ctx != nil {
+		if client, ok := ctx.Value(clientContextKey{}).(*Client); ok && client != nil {
+			return client
+		}
+	}
+	return GetDefaultClient()
+}
@@ -0,0 +1,110 @@
+package chatwoot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets := ParseTargets([]string{
+		"Sales=https://sales.example.com/|tok-1|3|7",
+		" support = https://help.example.com | tok-2 | 5 ",
+		"sales=https://other.example.com|tok-3|1",
+		"broken=https://x.example.com|tok-4",
+		"bad:name=https://x.example.com|tok-5|1",
+		"noaccount=https://x.example.com|tok-6|abc",
+		"",
+	})
+	want := []Target{
+		{Name: "sales", URL: "https://sales.example.com", APIToken: "tok-1", AccountID: 3, InboxID: 7},
+		{Name: "support", URL: "https://help.example.com", APIToken: "tok-2", AccountID: 5},
+	}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("unexpected targets:\n got %+v\nwant %+v", targets, want)
+	}
+}
+
+func TestRedactTarget(t *testing.T) {
+	if got := redactTarget("sales=https://x.example.com|secret|1"); got != "sales=https://x.example.com|***|1" {
+		t.Errorf("expected the token hidden, got %q", got)
+	}
+}
+
+func TestParseDeviceTargets(t *testing.T) {
+	got := ParseDeviceTargets([]string{"shop:Sales", "5511999990000@s.whatsapp.net:support", "nocolon", ":sales", "x:"})
+	want := map[string]string{"shop": "sales", "5511999990000@s.whatsapp.net": "support"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected device targets: %v", got)
+	}
+}
+
+func TestClientRegistry_Routing(t *testing.T) {
+	fallback := &Client{BaseURL: "https://default.example.com", AccountID: 1}
+	r := NewClientRegistry(
+		[]Target{{Name: "sales", URL: "https://sales.example.com", APIToken: "t", AccountID: 3, InboxID: 7}},
+		map[string]string{"shop": "sales", "5511999990000@s.whatsapp.net": "sales", "lost": "missing"},
+	)
+	r.defaultClient = func() *Client { return fallback }
+
+	sales, ok := r.Client("Sales")
+	if !ok || sales.BaseURL != "https://sales.example.com" || sales.AccountID != 3 || sales.InboxID != 7 {
+		t.Fatalf("expected the sales client, got %+v", sales)
+	}
+	if got := r.ClientForDevice("shop"); got != sales {
+		t.Errorf("expected device shop routed to sales")
+	}
+	if got := r.ClientForDevice("other", "5511999990000@s.whatsapp.net"); got != sales {
+		t.Errorf("expected the device JID to route to sales")
+	}
+	if got := r.ClientForDevice("lost"); got != fallback {
+		t.Errorf("expected a route to an unknown target to use the default client")
+	}
+	if got := r.ClientForDevice("unrouted"); got != fallback {
+		t.Errorf("expected an unrouted device to use the default client")
+	}
+	if name, ok := r.TargetOfClient(sales); !ok || name != "sales" {
+		t.Errorf("expected the sales client to belong to target sales, got %q", name)
+	}
+	if _, ok := r.TargetOfClient(fallback); ok {
+		t.Errorf("expected the default client to belong to no target")
+	}
+	if got := r.DevicesForTarget("sales"); !reflect.DeepEqual(got, []string{"5511999990000@s.whatsapp.net", "shop"}) {
+		t.Errorf("unexpected devices of sales: %v", got)
+	}
+}
+
+func TestClientRegistry_RoutesJIDThroughAlias(t *testing.T) {
+	SetDeviceAliasResolver(func(deviceJID string) string {
+		if deviceJID == "5511999990001@s.whatsapp.net" {
+			return "shop"
+		}
+		return ""
+	})
+	t.Cleanup(func() { SetDeviceAliasResolver(nil) })
+
+	r := NewClientRegistry([]Target{{Name: "sales", URL: "https://sales.example.com", APIToken: "t", AccountID: 3}}, map[string]string{"shop": "sales"})
+	if name, ok := r.TargetForDevice("5511999990001@s.whatsapp.net"); !ok || name != "sales" {
+		t.Fatalf("expected the JID of device shop to route to sales, got %q", name)
+	}
+	if _, ok := r.TargetForDevice("5511999990002@s.whatsapp.net"); ok {
+		t.Errorf("expected another JID to stay unrouted")
+	}
+}
+
+func TestClientCachesAreScopedByAccount(t *testing.T) {
+	a := &Client{BaseURL: "https://a.example.com", AccountID: 1}
+	b := &Client{BaseURL: "https://b.example.com", AccountID: 1}
+	t.Cleanup(ResetConversationDestinations)
+
+	a.RememberConversationDestination(4242, "5511999990001")
+	if _, ok := b.ConversationDestination(4242); ok {
+		t.Errorf("conversation 4242 of one account leaked into another")
+	}
+	a.MarkMessageAsSent(4243)
+	if b.IsMessageSentByUs(4243) {
+		t.Errorf("message 4243 of one account was taken as sent by the bridge in another")
+	}
+	if !a.IsMessageSentByUs(4243) {
+		t.Errorf("expected message 4243 to be remembered in its own account")
+	}
+}
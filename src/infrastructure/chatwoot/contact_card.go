@@ -0,0 +1,40 @@
+package chatwoot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+)
+
+// FormatContactCards summarises shared contacts for agents, one line per contact with every number.
+// The vCards themselves are attached as a .vcf file.
+func FormatContactCards(contacts []utils.VCardContact) string {
+	lines := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		if line := contactCardLine(contact); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	switch len(lines) {
+	case 0:
+		return RenderMessage(MessageContactUnknown, MessageData{})
+	case 1:
+		return RenderMessage(MessageContact, MessageData{Content: lines[0]})
+	default:
+		return RenderMessage(MessageContacts, MessageData{Items: lines})
+	}
+}
+
+func contactCardLine(contact utils.VCardContact) string {
+	phones := strings.Join(contact.Phones, ", ")
+	switch {
+	case contact.Name != "" && phones != "":
+		return fmt.Sprintf("%s (%s)", contact.Name, phones)
+	case contact.Name != "":
+		return contact.Name
+	default:
+		return phones
+	}
+}
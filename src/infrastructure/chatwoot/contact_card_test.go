@@ -0,0 +1,25 @@
+package chatwoot
+
+import (
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+)
+
+func TestFormatContactCards(t *testing.T) {
+	tests := []struct {
+		contacts []utils.VCardContact
+		want     string
+	}{
+		{nil, "Contact shared"},
+		{[]utils.VCardContact{{}}, "Contact shared"},
+		{[]utils.VCardContact{{Name: "Ana", Phones: []string{"+55 11 91234-5678"}}}, "Contact: Ana (+55 11 91234-5678)"},
+		{[]utils.VCardContact{{Phones: []string{"+1 415", "+1 212"}}}, "Contact: +1 415, +1 212"},
+		{[]utils.VCardContact{{Name: "Ana"}, {Name: "Bob", Phones: []string{"+1 415"}}}, "Contacts (2):\n- Ana\n- Bob (+1 415)"},
+	}
+	for _, tt := range tests {
+		if got := FormatContactCards(tt.contacts); got != tt.want {
+			t.Fatalf("FormatContactCards(%+v) = %q, want %q", tt.contacts, got, tt.want)
+		}
+	}
+}
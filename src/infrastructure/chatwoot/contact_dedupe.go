@@ -0,0 +1,305 @@
+package chatwoot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// duplicateOfAttribute marks a contact DedupeContacts found to duplicate another one, with the ID of
+// the contact kept. Marked contacts are left out of contact lookups while another contact matches.
+const duplicateOfAttribute = "waha_duplicate_of"
+
+// Actions DedupeContacts takes on a duplicate contact.
+const (
+	DedupeActionUnlink = "unlink" // the WhatsApp attributes are cleared and the contact is marked
+	DedupeActionMerge  = "merge"  // the contact is merged into the canonical one by Chatwoot
+)
+
+// ContactDedupeRequest selects the account of a device and what DedupeContacts does. DryRun is on
+// unless set to false, since merging cannot be undone. With Merge the duplicates are merged into the
+// canonical contact, moving their conversations; without it they are only unlinked.
+type ContactDedupeRequest struct {
+	DeviceID string `json:"device_id"`
+	DryRun   *bool  `json:"dry_run"`
+	Merge    bool   `json:"merge"`
+}
+
+// ContactDuplicate is a contact that shares its WhatsApp identity with the canonical one.
+type ContactDuplicate struct {
+	ContactID     int    `json:"contact_id"`
+	Name          string `json:"name"`
+	PhoneNumber   string `json:"phone_number,omitempty"`
+	Conversations int    `json:"conversations"`
+	Action        string `json:"action"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ContactConflict is a set of contacts with the same WhatsApp identity. The canonical contact is the
+// one with the most conversations, the oldest one on a tie.
+type ContactConflict struct {
+	Identifier             string             `json:"identifier"`
+	CanonicalContactID     int                `json:"canonical_contact_id"`
+	CanonicalName          string             `json:"canonical_name"`
+	CanonicalConversations int                `json:"canonical_conversations"`
+	Duplicates             []ContactDuplicate `json:"duplicates"`
+}
+
+// ContactDedupeResult reports the conflicts DedupeContacts found and what it did, or would do in a
+// dry run, with each duplicate.
+type ContactDedupeResult struct {
+	DeviceID   string            `json:"device_id"`
+	DryRun     bool              `json:"dry_run"`
+	Merge      bool              `json:"merge"`
+	Scanned    int               `json:"scanned"`
+	Duplicates int               `json:"duplicates"`
+	Repaired   int               `json:"repaired"`
+	Failed     int               `json:"failed"`
+	Conflicts  []ContactConflict `json:"conflicts"`
+}
+
+// DedupeContacts finds the contacts of the account that share a WhatsApp identity, by the
+// waha_whatsapp_jid attribute, a JID identifier or the phone number, in both forms of a Brazilian
+// mobile. Contacts sharing any of these are one set, also when each pair shares a different one.
+// For each set the canonical contact gets the attribute and identifier again and is used for the
+// identity from now on; the others are unlinked, or merged into it with merge. Nothing is changed in
+// a dry run.
+func (c *Client) DedupeContacts(ctx context.Context, dryRun, merge bool) (*ContactDedupeResult, error) {
+	result := &ContactDedupeResult{DryRun: dryRun, Merge: merge, Conflicts: []ContactConflict{}}
+
+	// Union-find over the contacts: each one joins the set of every earlier contact with a key in common
+	var contacts []Contact
+	var parent []int
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	owners := map[string]int{} // Dedupe key -> first contact with it
+	seen := 0
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		listed, total, err := c.ListContacts(page)
+		if err != nil {
+			return result, err
+		}
+		for _, contact := range listed {
+			result.Scanned++
+			if isDuplicateContact(contact) {
+				continue
+			}
+			keys := contactDedupeKeys(contact)
+			if len(keys) == 0 {
+				continue
+			}
+			i := len(contacts)
+			contacts = append(contacts, contact)
+			parent = append(parent, i)
+			for _, key := range keys {
+				owner, ok := owners[key]
+				if !ok {
+					owners[key] = i
+					continue
+				}
+				// The set that appeared first keeps its root, so sets are reported in listing order
+				if a, b := find(owner), find(i); a != b {
+					parent[max(a, b)] = min(a, b)
+				}
+			}
+		}
+		seen += len(listed)
+		if len(listed) == 0 || seen >= total {
+			break
+		}
+	}
+
+	groups := map[int][]Contact{}
+	var roots []int
+	for i, contact := range contacts {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], contact)
+	}
+
+	for _, root := range roots {
+		if len(groups[root]) < 2 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		conflict, err := c.dedupeGroup(groups[root], dryRun, merge)
+		if err != nil {
+			return result, err
+		}
+		for _, duplicate := range conflict.Duplicates {
+			result.Duplicates++
+			switch {
+			case duplicate.Error != "":
+				result.Failed++
+			case !dryRun:
+				result.Repaired++
+			}
+		}
+		result.Conflicts = append(result.Conflicts, conflict)
+	}
+
+	logrus.Infof("Chatwoot: Contact dedupe scanned %d contacts: %d conflicts, %d duplicates, %d repaired, %d failed (dry run %v, merge %v)",
+		result.Scanned, len(result.Conflicts), result.Duplicates, result.Repaired, result.Failed, dryRun, merge)
+	return result, nil
+}
+
+// dedupeGroup picks the canonical contact of contacts sharing one identity and repairs the others.
+func (c *Client) dedupeGroup(contacts []Contact, dryRun, merge bool) (ContactConflict, error) {
+	counts := make(map[int]int, len(contacts))
+	for _, contact := range contacts {
+		count, err := c.countContactConversations(contact.ID)
+		if err != nil {
+			return ContactConflict{}, err
+		}
+		counts[contact.ID] = count
+	}
+	sort.SliceStable(contacts, func(i, j int) bool {
+		if counts[contacts[i].ID] != counts[contacts[j].ID] {
+			return counts[contacts[i].ID] > counts[contacts[j].ID]
+		}
+		return contacts[i].ID < contacts[j].ID
+	})
+	canonical := contacts[0]
+
+	// The identity as the bridge wrote it, which may only be on a duplicate
+	identifier := ""
+	for _, contact := range contacts {
+		if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); ok && strings.TrimSpace(jid) != "" {
+			identifier = strings.TrimSpace(jid)
+			break
+		}
+	}
+	if identifier == "" {
+		identifier = contactIdentifier(canonical)
+	}
+
+	conflict := ContactConflict{
+		Identifier:             identifier,
+		CanonicalContactID:     canonical.ID,
+		CanonicalName:          canonical.Name,
+		CanonicalConversations: counts[canonical.ID],
+	}
+	action := DedupeActionUnlink
+	if merge {
+		action = DedupeActionMerge
+	}
+	for _, contact := range contacts[1:] {
+		duplicate := ContactDuplicate{
+			ContactID:     contact.ID,
+			Name:          contact.Name,
+			PhoneNumber:   contact.PhoneNumber,
+			Conversations: counts[contact.ID],
+			Action:        action,
+		}
+		if !dryRun {
+			var err error
+			if merge {
+				err = c.MergeContacts(canonical.ID, contact.ID)
+			} else {
+				err = c.unlinkDuplicateContact(contact, canonical.ID)
+			}
+			if err != nil {
+				logrus.Warnf("Chatwoot: Failed to %s duplicate contact %d of %s: %v", action, contact.ID, identifier, err)
+				duplicate.Error = err.Error()
+			} else {
+				c.ForgetContactConversation(contact.ID)
+				c.ForgetContactConversation(canonical.ID)
+			}
+		}
+		conflict.Duplicates = append(conflict.Duplicates, duplicate)
+	}
+	if dryRun {
+		return conflict, nil
+	}
+
+	isGroup := utils.IsGroupJID(identifier)
+	attrs := map[string]interface{}{"waha_whatsapp_jid": identifier}
+	if err := c.UpdateContactAttributes(canonical.ID, identifier, attrs, isGroup); err != nil {
+		logrus.Warnf("Chatwoot: Failed to set %s on canonical contact %d: %v", identifier, canonical.ID, err)
+	}
+	c.rememberContactID(identifier, canonical.ID)
+	return conflict, nil
+}
+
+// contactDedupeKeys returns every key contact is known by: its waha_whatsapp_jid attribute, its JID
+// identifier and its phone number, each as contactIDKey gives it.
+func contactDedupeKeys(contact Contact) []string {
+	var candidates []string
+	if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); ok {
+		candidates = append(candidates, jid)
+	}
+	if strings.Contains(contact.Identifier, "@") {
+		candidates = append(candidates, contact.Identifier)
+	}
+	candidates = append(candidates, phoneDigits(contact.PhoneNumber))
+
+	var keys []string
+	for _, candidate := range candidates {
+		if key := contactIDKey(candidate); key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// isDuplicateContact reports whether DedupeContacts marked the contact as a duplicate.
+func isDuplicateContact(contact Contact) bool {
+	value, ok := contact.CustomAttributes[duplicateOfAttribute]
+	if !ok || value == nil {
+		return false
+	}
+	marked := fmt.Sprint(value)
+	return marked != "" && marked != "0"
+}
+
+// unlinkDuplicateContact clears the WhatsApp identity of a duplicate contact, its JID identifier
+// included, and marks it with the contact kept.
+func (c *Client) unlinkDuplicateContact(contact Contact, canonicalID int) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/%d", c.BaseURL, c.AccountID, contact.ID)
+	payload := map[string]interface{}{
+		"custom_attributes": map[string]interface{}{
+			"waha_whatsapp_jid":  "",
+			"waha_whatsapp_lid":  "",
+			duplicateOfAttribute: canonicalID,
+		},
+	}
+	if strings.Contains(contact.Identifier, "@") {
+		payload["identifier"] = ""
+	}
+	if _, err := c.doRequest("PUT", endpoint, payload, nil); err != nil {
+		return fmt.Errorf("failed to unlink contact %d: %w", contact.ID, err)
+	}
+	return nil
+}
+
+// countContactConversations returns the number of conversations of a contact in every inbox.
+func (c *Client) countContactConversations(contactID int) (int, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/%d/conversations", c.BaseURL, c.AccountID, contactID)
+
+	var result struct {
+		Payload []struct {
+			ID int `json:"id"`
+		} `json:"payload"`
+	}
+	if _, err := c.doRequest("GET", endpoint, nil, &result); err != nil {
+		return 0, fmt.Errorf("failed to list the conversations of contact %d: %w", contactID, err)
+	}
+	return len(result.Payload), nil
+}
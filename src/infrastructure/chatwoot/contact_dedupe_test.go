@@ -0,0 +1,186 @@
+package chatwoot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dedupeServer serves contacts with their conversation counts and records the changes made.
+type dedupeServer struct {
+	contacts      []Contact
+	conversations map[int]int
+	updates       map[int]map[string]interface{}
+	merges        [][2]int
+}
+
+func (s *dedupeServer) client(t *testing.T) *Client {
+	t.Helper()
+	s.updates = map[int]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/1/")
+		switch {
+		case r.Method == http.MethodGet && path == "contacts":
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			var payload []Contact
+			for i := (page - 1) * contactSearchPageSize; i < page*contactSearchPageSize && i < len(s.contacts); i++ {
+				payload = append(payload, s.contacts[i])
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"meta": map[string]any{"count": len(s.contacts)}, "payload": payload})
+		case r.Method == http.MethodGet && strings.HasSuffix(path, "/conversations"):
+			id, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, "contacts/"), "/conversations"))
+			conversations := []map[string]int{}
+			for i := 0; i < s.conversations[id]; i++ {
+				conversations = append(conversations, map[string]int{"id": id*100 + i})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"payload": conversations})
+		case r.Method == http.MethodPut && strings.HasPrefix(path, "contacts/"):
+			id, _ := strconv.Atoi(strings.TrimPrefix(path, "contacts/"))
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			s.updates[id] = body
+			_, _ = w.Write([]byte(`{"payload":{}}`))
+		case r.Method == http.MethodPost && path == "actions/contact_merge":
+			var body map[string]int
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			s.merges = append(s.merges, [2]int{body["base_contact_id"], body["mergee_contact_id"]})
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func dedupeContacts() []Contact {
+	return []Contact{
+		{ID: 10, Name: "Ana", PhoneNumber: "+5511999990001", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "5511999990001"}},
+		{ID: 11, Name: "Ana (CRM)", PhoneNumber: "+55 11 99999-0001"},
+		{ID: 12, Name: "Ana old", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "5511999990001@s.whatsapp.net"}},
+		{ID: 20, Name: "Team", Identifier: "120363000000000000@g.us"},
+		{ID: 21, Name: "Team copy", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "120363000000000000@g.us"}},
+		{ID: 30, Name: "Solo", PhoneNumber: "+6281234567890"},
+		{ID: 31, Name: "Already marked", PhoneNumber: "+6281234567890", CustomAttributes: map[string]interface{}{duplicateOfAttribute: float64(30)}},
+	}
+}
+
+func TestDedupeContacts_DryRunReportsConflicts(t *testing.T) {
+	srv := &dedupeServer{contacts: dedupeContacts(), conversations: map[int]int{10: 1, 11: 3, 12: 1}}
+	c := srv.client(t)
+
+	result, err := c.DedupeContacts(context.Background(), true, false)
+	if err != nil {
+		t.Fatalf("DedupeContacts returned error: %v", err)
+	}
+	if result.Scanned != 7 || len(result.Conflicts) != 2 || result.Duplicates != 3 || result.Repaired != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(srv.updates) != 0 || len(srv.merges) != 0 {
+		t.Fatalf("expected nothing changed in a dry run, got updates %v merges %v", srv.updates, srv.merges)
+	}
+
+	phone := result.Conflicts[0]
+	// The contact with the most conversations wins; the oldest breaks the tie of the others
+	if phone.CanonicalContactID != 11 || phone.Identifier != "5511999990001" {
+		t.Fatalf("expected contact 11 kept for 5511999990001, got %+v", phone)
+	}
+	if got := fmt.Sprint(phone.Duplicates[0].ContactID, phone.Duplicates[1].ContactID); got != "10 12" {
+		t.Errorf("expected duplicates 10 and 12, got %s", got)
+	}
+	if group := result.Conflicts[1]; group.CanonicalContactID != 20 || group.Duplicates[0].ContactID != 21 || group.Duplicates[0].Action != DedupeActionUnlink {
+		t.Errorf("unexpected group conflict %+v", group)
+	}
+}
+
+func TestDedupeContacts_UnlinksDuplicates(t *testing.T) {
+	srv := &dedupeServer{contacts: dedupeContacts(), conversations: map[int]int{11: 2}}
+	c := srv.client(t)
+
+	result, err := c.DedupeContacts(context.Background(), false, false)
+	if err != nil {
+		t.Fatalf("DedupeContacts returned error: %v", err)
+	}
+	if result.Repaired != 3 || result.Failed != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	attrs, _ := srv.updates[10]["custom_attributes"].(map[string]interface{})
+	if attrs["waha_whatsapp_jid"] != "" || attrs[duplicateOfAttribute] != float64(11) {
+		t.Errorf("expected contact 10 unlinked and marked, got %v", srv.updates[10])
+	}
+	if attrs, _ := srv.updates[11]["custom_attributes"].(map[string]interface{}); attrs["waha_whatsapp_jid"] != "5511999990001" {
+		t.Errorf("expected the attribute on canonical contact 11, got %v", srv.updates[11])
+	}
+	if srv.updates[20]["identifier"] != "120363000000000000@g.us" {
+		t.Errorf("expected the group identifier re-asserted on contact 20, got %v", srv.updates[20])
+	}
+	if id, ok := c.rememberedContactID("5511999990001"); !ok || id != 11 {
+		t.Errorf("expected contact 11 remembered, got %d %v", id, ok)
+	}
+
+	// Marked duplicates lose lookups to the canonical contact, even when they come first
+	contacts := []Contact{{ID: 10, PhoneNumber: "+5511999990001", CustomAttributes: map[string]interface{}{duplicateOfAttribute: float64(11)}}, {ID: 11, PhoneNumber: "+5511999990001"}}
+	if got := matchContact(contacts, "5511999990001", "+5511999990001", false); got == nil || got.ID != 11 {
+		t.Errorf("expected contact 11 matched, got %+v", got)
+	}
+}
+
+func TestDedupeContacts_JoinsOverlappingSets(t *testing.T) {
+	// 40 and 42 share no key, but each shares one with 41: its phone with 40, its LID with 42
+	srv := &dedupeServer{contacts: []Contact{
+		{ID: 40, Name: "Budi", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "6281111222333@s.whatsapp.net"}},
+		{ID: 41, Name: "Budi (CRM)", PhoneNumber: "+62 811-1122-2333", Identifier: "99887766@lid"},
+		{ID: 42, Name: "Budi LID", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "99887766@lid"}},
+		{ID: 43, Name: "Other", PhoneNumber: "+6281999000111"},
+	}}
+	c := srv.client(t)
+
+	result, err := c.DedupeContacts(context.Background(), true, false)
+	if err != nil {
+		t.Fatalf("DedupeContacts returned error: %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Duplicates != 2 {
+		t.Fatalf("expected one set of three contacts, got %+v", result)
+	}
+	conflict := result.Conflicts[0]
+	if got := fmt.Sprint(conflict.CanonicalContactID, conflict.Duplicates[0].ContactID, conflict.Duplicates[1].ContactID); got != "40 41 42" {
+		t.Errorf("expected contact 40 kept with duplicates 41 and 42, got %s", got)
+	}
+}
+
+func TestDedupeContacts_Merges(t *testing.T) {
+	srv := &dedupeServer{contacts: dedupeContacts()[3:5]}
+	c := srv.client(t)
+
+	result, err := c.DedupeContacts(context.Background(), false, true)
+	if err != nil {
+		t.Fatalf("DedupeContacts returned error: %v", err)
+	}
+	if result.Repaired != 1 || len(srv.merges) != 1 || srv.merges[0] != [2]int{20, 21} {
+		t.Fatalf("expected contact 21 merged into 20, got %+v merges %v", result, srv.merges)
+	}
+	if result.Conflicts[0].Duplicates[0].Action != DedupeActionMerge {
+		t.Errorf("expected the merge action reported, got %+v", result.Conflicts[0].Duplicates[0])
+	}
+}
+
+func TestMatchContact_ConflictPicksFirst(t *testing.T) {
+	contacts := []Contact{
+		{ID: 5, PhoneNumber: "+6281234567890"},
+		{ID: 6, PhoneNumber: "+62 812-3456-7890"},
+	}
+	if got := matchContact(contacts, "6281234567890", "+6281234567890", false); got == nil || got.ID != 5 {
+		t.Fatalf("expected the first of the conflicting contacts, got %+v", got)
+	}
+	if _, warned := contactConflictsWarned.Load("6281234567890|5,6"); !warned {
+		t.Error("expected the conflict to be warned about")
+	}
+}
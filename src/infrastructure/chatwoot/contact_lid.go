@@ -0,0 +1,217 @@
+package chatwoot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+// lidLinkBatchSize is the number of stored LID mappings LinkLIDContacts reads at a time.
+const lidLinkBatchSize = 100
+
+// FindOrCreateContactWithLID is FindOrCreateContact for a private chat whose LID is known besides
+// its phone number. A contact made earlier for the LID alone is linked to the phone number: merged
+// into the contact of the number when both exist, else given the number. The contact found carries
+// the phone number in waha_whatsapp_jid and the LID in waha_whatsapp_lid.
+func (c *Client) FindOrCreateContactWithLID(name, identifier, lid string) (*Contact, error) {
+	if lid == "" || lid == identifier || strings.HasSuffix(identifier, "@lid") {
+		return c.FindOrCreateContact(name, identifier, false)
+	}
+	unlock := getContactLocks().Lock(identifier, "FindOrCreateContactWithLID")
+	defer unlock()
+
+	return c.findOrCreateContact(name, identifier, lid, false)
+}
+
+// LinkLIDContact links the contacts of a phone number and of its LID like FindOrCreateContactWithLID,
+// without creating one. It returns nil when neither exists.
+func (c *Client) LinkLIDContact(identifier, lid string) (*Contact, error) {
+	unlock := getContactLocks().Lock(identifier, "LinkLIDContact")
+	defer unlock()
+
+	contact, err := c.FindContactByIdentifier(identifier, false)
+	if err != nil {
+		return nil, err
+	}
+	contact = c.linkLIDContact(contact, identifier, lid)
+	if contact != nil {
+		c.storeWhatsappAttributes(contact, identifier, lid, false)
+	}
+	return contact, nil
+}
+
+// linkLIDContact returns the contact to use for a phone number once the contact of its LID, if any,
+// was linked to contact. contact is nil when the phone number has no contact yet.
+func (c *Client) linkLIDContact(contact *Contact, identifier, lid string) *Contact {
+	if contact != nil {
+		if linkedID, ok := c.rememberedContactID(lid); ok && linkedID == contact.ID {
+			return contact
+		}
+	}
+	lidContact, err := c.FindContactByIdentifier(lid, false)
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to look up the contact of %s: %v", lid, err)
+		return contact
+	}
+	if lidContact == nil || (contact != nil && lidContact.ID == contact.ID) {
+		return contact
+	}
+
+	if contact == nil {
+		phone := utils.CanonicalPhoneE164(identifier)
+		if err := c.updateContactPhone(lidContact.ID, phone); err != nil {
+			logrus.Warnf("Chatwoot: Failed to add phone number %s to contact %d of %s: %v", phone, lidContact.ID, lid, err)
+			return lidContact
+		}
+		lidContact.PhoneNumber = phone
+		logrus.Infof("Chatwoot: Linked contact %d of %s to phone number %s", lidContact.ID, lid, phone)
+		return lidContact
+	}
+
+	if err := c.MergeContacts(contact.ID, lidContact.ID); err != nil {
+		logrus.Warnf("Chatwoot: Failed to merge contact %d of %s into contact %d of %s: %v", lidContact.ID, lid, contact.ID, identifier, err)
+		return contact
+	}
+	c.ForgetContactConversation(lidContact.ID)
+	c.ForgetContactConversation(contact.ID)
+	logrus.Infof("Chatwoot: Merged contact %d of %s into contact %d of %s", lidContact.ID, lid, contact.ID, identifier)
+	return contact
+}
+
+// MergeContacts merges the contact mergeeID into baseID, which keeps its conversations and notes.
+// The mergee is deleted.
+func (c *Client) MergeContacts(baseID, mergeeID int) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/actions/contact_merge", c.BaseURL, c.AccountID)
+	payload := map[string]int{
+		"base_contact_id":   baseID,
+		"mergee_contact_id": mergeeID,
+	}
+	if _, err := c.doRequest("POST", endpoint, payload, nil); err != nil {
+		return fmt.Errorf("failed to merge contacts: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) updateContactPhone(contactID int, phone string) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/%d", c.BaseURL, c.AccountID, contactID)
+	if _, err := c.doRequest("PUT", endpoint, map[string]string{"phone_number": phone}, nil); err != nil {
+		return fmt.Errorf("failed to update contact phone number: %w", err)
+	}
+	return nil
+}
+
+// chatContact finds or creates the contact of a chat being synced. The LID and the phone number of a
+// private chat are resolved into each other first, so both reach the same contact.
+func (s *SyncService) chatContact(ctx context.Context, name, chatJID string, isGroup bool, waClient *whatsmeow.Client) (*Contact, error) {
+	cw := s.clientFor(waClient)
+	if isGroup {
+		return cw.FindOrCreateContact(name, chatJID, true)
+	}
+	phoneJID, lid := s.resolveChatLID(ctx, chatJID, waClient)
+	if phoneJID == "" {
+		return cw.FindOrCreateContact(name, chatJID, false)
+	}
+	return cw.FindOrCreateContactWithLID(name, phoneJID, lid)
+}
+
+// resolveChatLID returns the phone number JID and the LID of a private chat, either "" when unknown.
+// The device store is asked first, then the mappings kept in chat storage, where a pair found in the
+// store is recorded.
+func (s *SyncService) resolveChatLID(ctx context.Context, chatJID string, waClient *whatsmeow.Client) (phoneJID, lid string) {
+	jid, err := waTypes.ParseJID(chatJID)
+	if err != nil {
+		return "", ""
+	}
+	var lids store.LIDStore
+	if waClient != nil && waClient.Store != nil && waClient.Store.LIDs != nil {
+		lids = waClient.Store.LIDs
+	}
+
+	switch jid.Server {
+	case waTypes.HiddenUserServer:
+		lid = jid.String()
+		if lids != nil {
+			if pn, err := lids.GetPNForLID(ctx, jid); err == nil && !pn.IsEmpty() {
+				phoneJID = pn.ToNonAD().String()
+				s.saveLIDMapping(lid, phoneJID)
+				return phoneJID, lid
+			}
+		}
+		if s.chatStorageRepo != nil {
+			phoneJID, err = s.chatStorageRepo.GetPhoneForLID(lid)
+			if err != nil {
+				logrus.Debugf("Chatwoot Sync: Failed to look up the phone number of %s: %v", lid, err)
+			}
+		}
+		return phoneJID, lid
+	case waTypes.DefaultUserServer:
+		phoneJID = jid.ToNonAD().String()
+		if lids != nil {
+			if l, err := lids.GetLIDForPN(ctx, jid); err == nil && !l.IsEmpty() {
+				lid = l.ToNonAD().String()
+				s.saveLIDMapping(lid, phoneJID)
+			}
+		}
+		return phoneJID, lid
+	}
+	return "", ""
+}
+
+func (s *SyncService) saveLIDMapping(lid, phoneJID string) {
+	if s.chatStorageRepo == nil {
+		return
+	}
+	if err := s.chatStorageRepo.SaveLIDMapping(lid, phoneJID); err != nil {
+		logrus.Debugf("Chatwoot Sync: Failed to store the phone number of %s: %v", lid, err)
+	}
+}
+
+// LinkLIDContacts links the Chatwoot contacts made separately for the LID and the phone number of
+// one person, before LIDs were resolved consistently. LID chats in chat storage are resolved with the
+// device store first so their mappings are known; then every mapping not linked yet is linked once.
+// It returns the number of mappings linked.
+func (s *SyncService) LinkLIDContacts(ctx context.Context, deviceID string, waClient *whatsmeow.Client) (int, error) {
+	if s.chatStorageRepo == nil {
+		return 0, nil
+	}
+	chats, err := s.chatStorageRepo.GetChats(&domainChatStorage.ChatFilter{DeviceID: deviceID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list chats: %w", err)
+	}
+	for _, chat := range chats {
+		if strings.HasSuffix(chat.JID, "@lid") {
+			s.resolveChatLID(ctx, chat.JID, waClient)
+		}
+	}
+
+	cw := s.clientFor(waClient)
+	linked := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return linked, err
+		}
+		mappings, err := s.chatStorageRepo.GetUnlinkedLIDMappings(lidLinkBatchSize)
+		if err != nil {
+			return linked, fmt.Errorf("failed to list LID mappings: %w", err)
+		}
+		if len(mappings) == 0 {
+			return linked, nil
+		}
+		for _, mapping := range mappings {
+			if _, err := cw.LinkLIDContact(utils.ExtractPhoneFromJID(mapping.PhoneJID), mapping.LID); err != nil {
+				return linked, fmt.Errorf("failed to link the contacts of %s: %w", mapping.LID, err)
+			}
+			if err := s.chatStorageRepo.MarkLIDMappingLinked(mapping.LID); err != nil {
+				return linked, fmt.Errorf("failed to mark %s as linked: %w", mapping.LID, err)
+			}
+			linked++
+		}
+	}
+}
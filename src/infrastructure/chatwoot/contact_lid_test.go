@@ -0,0 +1,173 @@
+package chatwoot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+// lidServer answers like Chatwoot holding contact 5 made for a LID and, when withPhone, contact 9
+// made for the phone number of the same person.
+type lidServer struct {
+	withPhone bool
+
+	mu       sync.Mutex
+	searches []string
+	merges   []map[string]interface{}
+	updates  []map[string]interface{}
+}
+
+func (s *lidServer) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/contacts/filter"):
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/contacts/search"):
+			q := r.URL.Query().Get("q")
+			s.searches = append(s.searches, q)
+			switch {
+			case q == "123456789@lid":
+				_, _ = w.Write([]byte(`{"meta":{"count":1},"payload":[{"id":5,"name":"Maria","identifier":"123456789@lid","custom_attributes":{"waha_whatsapp_jid":"123456789@lid"}}]}`))
+			case s.withPhone && q == "+5511999990001":
+				_, _ = w.Write([]byte(`{"meta":{"count":1},"payload":[{"id":9,"name":"Maria","phone_number":"+5511999990001","custom_attributes":{"waha_whatsapp_jid":"5511999990001"}}]}`))
+			default:
+				_, _ = w.Write([]byte(`{"meta":{"count":0},"payload":[]}`))
+			}
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/actions/contact_merge"):
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			s.merges = append(s.merges, body)
+			_, _ = w.Write([]byte(`{"id":9}`))
+		case r.Method == http.MethodPut:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			s.updates = append(s.updates, body)
+			_, _ = w.Write([]byte(`{"payload":{}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+		}
+	})
+}
+
+func newLIDTestClient(t *testing.T, withPhone bool) (*Client, *lidServer) {
+	t.Helper()
+	s := &lidServer{withPhone: withPhone}
+	srv := httptest.NewServer(s.handler(t))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { contactIDs.Purge() })
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}, s
+}
+
+func TestFindOrCreateContactWithLID_MergesLIDContact(t *testing.T) {
+	c, srv := newLIDTestClient(t, true)
+
+	contact, err := c.FindOrCreateContactWithLID("Maria", "5511999990001", "123456789@lid")
+	if err != nil {
+		t.Fatalf("FindOrCreateContactWithLID returned error: %v", err)
+	}
+	if contact.ID != 9 {
+		t.Fatalf("expected the contact of the phone number, got %d", contact.ID)
+	}
+	if len(srv.merges) != 1 || srv.merges[0]["base_contact_id"] != float64(9) || srv.merges[0]["mergee_contact_id"] != float64(5) {
+		t.Fatalf("expected contact 5 to be merged into 9, got %v", srv.merges)
+	}
+	if len(srv.updates) != 1 {
+		t.Fatalf("expected one attribute update, got %v", srv.updates)
+	}
+	attrs, _ := srv.updates[0]["custom_attributes"].(map[string]interface{})
+	if attrs["waha_whatsapp_lid"] != "123456789@lid" {
+		t.Fatalf("expected the LID to be stored on the contact, got %v", srv.updates[0])
+	}
+
+	// Once linked, the LID is not looked up again
+	searches := len(srv.searches)
+	if _, err := c.FindOrCreateContactWithLID("Maria", "5511999990001", "123456789@lid"); err != nil {
+		t.Fatalf("second call returned error: %v", err)
+	}
+	if len(srv.searches) != searches+1 || len(srv.merges) != 1 {
+		t.Fatalf("expected only the phone number to be searched again, got searches %v merges %v", srv.searches[searches:], srv.merges)
+	}
+}
+
+func TestFindOrCreateContactWithLID_AddsPhoneToLIDContact(t *testing.T) {
+	c, srv := newLIDTestClient(t, false)
+
+	contact, err := c.FindOrCreateContactWithLID("Maria", "5511999990001", "123456789@lid")
+	if err != nil {
+		t.Fatalf("FindOrCreateContactWithLID returned error: %v", err)
+	}
+	if contact.ID != 5 || contact.PhoneNumber != "+5511999990001" {
+		t.Fatalf("expected the LID contact with the phone number, got %+v", contact)
+	}
+	if len(srv.merges) != 0 {
+		t.Fatalf("expected no merge, got %v", srv.merges)
+	}
+	if len(srv.updates) != 2 || srv.updates[0]["phone_number"] != "+5511999990001" {
+		t.Fatalf("expected the phone number and then the attributes to be updated, got %v", srv.updates)
+	}
+	attrs, _ := srv.updates[1]["custom_attributes"].(map[string]interface{})
+	if attrs["waha_whatsapp_jid"] != "5511999990001" || attrs["waha_whatsapp_lid"] != "123456789@lid" {
+		t.Fatalf("expected the WhatsApp JID to move to the phone number, got %v", attrs)
+	}
+}
+
+type lidMappingRepo struct {
+	domainChatStorage.IChatStorageRepository
+	mappings []*domainChatStorage.LIDMapping
+	linked   []string
+}
+
+func (r *lidMappingRepo) GetChats(*domainChatStorage.ChatFilter) ([]*domainChatStorage.Chat, error) {
+	return nil, nil
+}
+
+func (r *lidMappingRepo) GetUnlinkedLIDMappings(int) ([]*domainChatStorage.LIDMapping, error) {
+	var unlinked []*domainChatStorage.LIDMapping
+	for _, mapping := range r.mappings {
+		if mapping.ChatwootLinkedAt == nil {
+			unlinked = append(unlinked, mapping)
+		}
+	}
+	return unlinked, nil
+}
+
+func (r *lidMappingRepo) MarkLIDMappingLinked(lid string) error {
+	now := time.Now()
+	for _, mapping := range r.mappings {
+		if mapping.LID == lid {
+			mapping.ChatwootLinkedAt = &now
+		}
+	}
+	r.linked = append(r.linked, lid)
+	return nil
+}
+
+func TestLinkLIDContacts_LinksStoredMappings(t *testing.T) {
+	c, srv := newLIDTestClient(t, true)
+	repo := &lidMappingRepo{mappings: []*domainChatStorage.LIDMapping{
+		{LID: "123456789@lid", PhoneJID: "5511999990001@s.whatsapp.net"},
+		{LID: "555@lid", PhoneJID: "5511999990009@s.whatsapp.net"},
+	}}
+	s := NewSyncService(c, repo)
+
+	linked, err := s.LinkLIDContacts(context.Background(), "device", nil)
+	if err != nil {
+		t.Fatalf("LinkLIDContacts returned error: %v", err)
+	}
+	if linked != 2 || len(repo.linked) != 2 {
+		t.Fatalf("expected both mappings to be linked, got %d (%v)", linked, repo.linked)
+	}
+	if len(srv.merges) != 1 || srv.merges[0]["mergee_contact_id"] != float64(5) {
+		t.Fatalf("expected only the duplicate pair to be merged, got %v", srv.merges)
+	}
+}
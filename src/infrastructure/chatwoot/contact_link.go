@@ -0,0 +1,164 @@
+package chatwoot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// contactLinkBatchSize is the number of phone numbers asked of WhatsApp at once.
+	contactLinkBatchSize = 50
+	// contactLinkMaxChanges caps the contacts listed in a ContactLinkResult; the counts cover all.
+	contactLinkMaxChanges = 500
+)
+
+// contactLinkBatchInterval is the pause between two WhatsApp registration lookups, so linking a large
+// account does not flood WhatsApp with usync queries. Shortened in tests.
+var contactLinkBatchInterval = 3 * time.Second
+
+// ContactLinkRequest names the device whose WhatsApp account checks the phone numbers. With DryRun
+// the contacts are checked but not changed.
+type ContactLinkRequest struct {
+	DeviceID string `json:"device_id"`
+	DryRun   bool   `json:"dry_run"`
+}
+
+// ContactLinkChange is a contact LinkContacts linked, or would link in a dry run.
+type ContactLinkChange struct {
+	ContactID   int    `json:"contact_id"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+	JID         string `json:"jid"`
+}
+
+// ContactLinkResult reports what LinkContacts did with the contacts of the account. Skipped counts
+// contacts already linked, groups and other JID contacts, duplicates marked by DedupeContacts and
+// contacts without a phone number.
+type ContactLinkResult struct {
+	DeviceID      string              `json:"device_id"`
+	DryRun        bool                `json:"dry_run"`
+	Scanned       int                 `json:"scanned"`
+	Linked        int                 `json:"linked"`
+	Skipped       int                 `json:"skipped"`
+	NotOnWhatsApp int                 `json:"not_on_whatsapp"`
+	Failed        int                 `json:"failed"`
+	Changes       []ContactLinkChange `json:"changes"`
+}
+
+// WhatsAppLookup returns the JIDs of the phone numbers, given as digits, that are registered on
+// WhatsApp, keyed by the phone number asked for. Numbers missing from the map are not registered.
+type WhatsAppLookup func(ctx context.Context, phones []string) (map[string]string, error)
+
+// ListContacts returns one page of the contacts of the account, and the number of contacts in all.
+// Pages start at 1 and hold contactSearchPageSize contacts.
+func (c *Client) ListContacts(page int) ([]Contact, int, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts?page=%d&sort=name", c.BaseURL, c.AccountID, page)
+
+	var result contactPage
+	if _, err := c.doRequest("GET", endpoint, nil, &result); err != nil {
+		return nil, 0, fmt.Errorf("failed to list contacts page %d: %w", page, err)
+	}
+	total := len(result.Payload)
+	if result.Meta != nil {
+		total = result.Meta.Count
+	}
+	return result.Payload, total, nil
+}
+
+// LinkContacts sets the waha_whatsapp_jid attribute of the account contacts that have a phone number
+// but were never bridged, e.g. contacts created by another WhatsApp integration, so the next
+// message of the number lands in them instead of a new contact. Each number is checked with lookup
+// first, contactLinkBatchSize at a time with contactLinkBatchInterval between batches; numbers not
+// on WhatsApp are left alone.
+func (c *Client) LinkContacts(ctx context.Context, lookup WhatsAppLookup, dryRun bool) (*ContactLinkResult, error) {
+	result := &ContactLinkResult{DryRun: dryRun, Changes: []ContactLinkChange{}}
+
+	var candidates []Contact
+	seen := 0
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		contacts, total, err := c.ListContacts(page)
+		if err != nil {
+			return result, err
+		}
+		for _, contact := range contacts {
+			result.Scanned++
+			if contactLinkPhone(contact) == "" {
+				result.Skipped++
+				continue
+			}
+			candidates = append(candidates, contact)
+		}
+		seen += len(contacts)
+		if len(contacts) == 0 || seen >= total {
+			break
+		}
+	}
+
+	for start := 0; start < len(candidates); start += contactLinkBatchSize {
+		if start > 0 {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(contactLinkBatchInterval):
+			}
+		}
+		batch := candidates[start:min(start+contactLinkBatchSize, len(candidates))]
+		phones := make([]string, len(batch))
+		for i, contact := range batch {
+			phones[i] = contactLinkPhone(contact)
+		}
+		registered, err := lookup(ctx, phones)
+		if err != nil {
+			return result, fmt.Errorf("failed to check %d phone numbers on WhatsApp: %w", len(phones), err)
+		}
+
+		for i, contact := range batch {
+			jid, ok := registered[phones[i]]
+			if !ok || jid == "" {
+				result.NotOnWhatsApp++
+				continue
+			}
+			identifier, _, _ := strings.Cut(jid, "@")
+			if !dryRun {
+				attrs := map[string]interface{}{"waha_whatsapp_jid": identifier}
+				if err := c.UpdateContactAttributes(contact.ID, "", attrs, false); err != nil {
+					logrus.Warnf("Chatwoot: Failed to link contact %d to %s: %v", contact.ID, jid, err)
+					result.Failed++
+					continue
+				}
+				c.rememberContactID(identifier, contact.ID)
+			}
+			result.Linked++
+			if len(result.Changes) < contactLinkMaxChanges {
+				result.Changes = append(result.Changes, ContactLinkChange{ContactID: contact.ID, Name: contact.Name, PhoneNumber: contact.PhoneNumber, JID: jid})
+			}
+		}
+	}
+
+	logrus.Infof("Chatwoot: Contact link scanned %d contacts: %d linked, %d skipped, %d not on WhatsApp, %d failed (dry run %v)",
+		result.Scanned, result.Linked, result.Skipped, result.NotOnWhatsApp, result.Failed, dryRun)
+	return result, nil
+}
+
+// contactLinkPhone returns the phone number LinkContacts checks for a contact, as digits; "" when
+// the contact is already linked, is kept by a JID identifier, is a marked duplicate or has no phone
+// number.
+func contactLinkPhone(contact Contact) string {
+	if isDuplicateContact(contact) {
+		return ""
+	}
+	if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); ok && strings.TrimSpace(jid) != "" {
+		return ""
+	}
+	if strings.Contains(contact.Identifier, "@") {
+		return ""
+	}
+	return phoneDigits(contact.PhoneNumber)
+}
@@ -0,0 +1,124 @@
+package chatwoot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contactListServer serves contacts over pages of 15 and records the attributes PUT on each.
+func contactListServer(t *testing.T, contacts []Contact) (*Client, map[int]map[string]interface{}) {
+	t.Helper()
+	updates := map[int]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/1/")
+		switch {
+		case r.Method == http.MethodGet && path == "contacts":
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			var payload []Contact
+			for i := (page - 1) * contactSearchPageSize; i < page*contactSearchPageSize && i < len(contacts); i++ {
+				payload = append(payload, contacts[i])
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"meta": map[string]any{"count": len(contacts), "current_page": page}, "payload": payload})
+		case r.Method == http.MethodPut && strings.HasPrefix(path, "contacts/"):
+			id, _ := strconv.Atoi(strings.TrimPrefix(path, "contacts/"))
+			var body struct {
+				Identifier       string                 `json:"identifier"`
+				CustomAttributes map[string]interface{} `json:"custom_attributes"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body.Identifier != "" {
+				t.Errorf("expected no identifier for a phone contact, got %q", body.Identifier)
+			}
+			updates[id] = body.CustomAttributes
+			_, _ = w.Write([]byte(`{"payload":{}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}, updates
+}
+
+func TestLinkContacts(t *testing.T) {
+	origInterval := contactLinkBatchInterval
+	contactLinkBatchInterval = time.Millisecond
+	t.Cleanup(func() { contactLinkBatchInterval = origInterval })
+
+	contacts := []Contact{
+		{ID: 1, Name: "Linked", PhoneNumber: "+5511999990001", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "5511999990001"}},
+		{ID: 2, Name: "Group", Identifier: "120363000000000000@g.us"},
+		{ID: 3, Name: "Email only"},
+		{ID: 4, Name: "Not on WhatsApp", PhoneNumber: "+15550000000"},
+	}
+	// Enough contacts with numbers for two pages and two lookup batches
+	for i := 0; i < contactLinkBatchSize+2; i++ {
+		contacts = append(contacts, Contact{ID: 100 + i, Name: fmt.Sprintf("Customer %d", i), PhoneNumber: fmt.Sprintf("+62 812-0000-%04d", i)})
+	}
+
+	var batches [][]string
+	lookup := func(_ context.Context, phones []string) (map[string]string, error) {
+		batches = append(batches, phones)
+		registered := map[string]string{}
+		for _, phone := range phones {
+			if strings.HasPrefix(phone, "62") {
+				registered[phone] = phone + "@s.whatsapp.net"
+			}
+		}
+		return registered, nil
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		c, updates := contactListServer(t, contacts)
+		batches = nil
+		result, err := c.LinkContacts(context.Background(), lookup, true)
+		if err != nil {
+			t.Fatalf("LinkContacts returned error: %v", err)
+		}
+		if result.Scanned != len(contacts) || result.Linked != contactLinkBatchSize+2 || result.Skipped != 3 || result.NotOnWhatsApp != 1 || result.Failed != 0 {
+			t.Fatalf("unexpected counts %+v", result)
+		}
+		if len(updates) != 0 {
+			t.Fatalf("expected no contact changed in a dry run, got %v", updates)
+		}
+		if len(batches) != 2 || len(batches[0]) != contactLinkBatchSize || batches[0][0] != "15550000000" {
+			t.Fatalf("expected two lookup batches of digits, got %v", batches)
+		}
+		if change := result.Changes[0]; change.ContactID != 100 || change.JID != "6281200000000@s.whatsapp.net" {
+			t.Errorf("unexpected first change %+v", change)
+		}
+	})
+
+	t.Run("link", func(t *testing.T) {
+		c, updates := contactListServer(t, contacts)
+		result, err := c.LinkContacts(context.Background(), lookup, false)
+		if err != nil {
+			t.Fatalf("LinkContacts returned error: %v", err)
+		}
+		if len(updates) != result.Linked || result.Linked != contactLinkBatchSize+2 {
+			t.Fatalf("expected %d contacts updated, got %d (%+v)", result.Linked, len(updates), result)
+		}
+		if got := updates[101]["waha_whatsapp_jid"]; got != "6281200000001" {
+			t.Errorf("expected the phone of the JID in waha_whatsapp_jid, got %v", got)
+		}
+		ids := make([]int, 0, len(updates))
+		for id := range updates {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		if ids[0] != 100 {
+			t.Errorf("expected only the unlinked contacts updated, got %v", ids)
+		}
+		if id, ok := c.rememberedContactID("6281200000001"); !ok || id != 101 {
+			t.Errorf("expected the linked contact remembered for its number, got %d %v", id, ok)
+		}
+	})
+}
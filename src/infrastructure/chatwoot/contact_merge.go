@@ -0,0 +1,231 @@
+package chatwoot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// contactIDs remembers the Chatwoot contact found or created for each WhatsApp identifier, keyed by
+// contactIDKey within the account, so the contact is still found when Chatwoot search no longer
+// matches it, e.g. after agents merged it into a contact imported from a CRM. It caches the records
+// kept in the contact repository, which survive restarts.
+var contactIDs = utils.NewTTLCache[string, int](10000, 24*time.Hour)
+
+var (
+	contactRepoMu sync.RWMutex
+	contactRepo   domainChatStorage.IChatStorageRepository
+)
+
+// SetContactRepository sets the storage that keeps the contact of each WhatsApp identifier.
+func SetContactRepository(repo domainChatStorage.IChatStorageRepository) {
+	contactRepoMu.Lock()
+	defer contactRepoMu.Unlock()
+	contactRepo = repo
+}
+
+func getContactRepository() domainChatStorage.IChatStorageRepository {
+	contactRepoMu.RLock()
+	defer contactRepoMu.RUnlock()
+	return contactRepo
+}
+
+// contactIDKey makes the bare phone and the user JID of one chat, in either form of the number, the
+// same key.
+func contactIDKey(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if strings.HasSuffix(identifier, "@s.whatsapp.net") || !strings.Contains(identifier, "@") {
+		canonical, _ := utils.PhoneVariants(identifier)
+		return canonical
+	}
+	return identifier
+}
+
+// contactIDCacheKey is the contactIDs key of identifier in the account of the client.
+func (c *Client) contactIDCacheKey(identifier string) string {
+	key := contactIDKey(identifier)
+	if key == "" {
+		return ""
+	}
+	return c.accountKey() + "|" + key
+}
+
+func (c *Client) rememberContactID(identifier string, contactID int) {
+	key := c.contactIDCacheKey(identifier)
+	if key == "" || contactID == 0 {
+		return
+	}
+	if previous, ok := contactIDs.Get(key); ok && previous == contactID {
+		return
+	}
+	contactIDs.Set(key, contactID)
+	if repo := getContactRepository(); repo != nil {
+		if err := repo.SaveChatwootContactID(c.accountKey(), contactIDKey(identifier), contactID); err != nil {
+			logrus.Warnf("Chatwoot: Failed to store contact %d of %s: %v", contactID, identifier, err)
+		}
+	}
+}
+
+// rememberedContactID returns the contact last used for identifier in the account.
+func (c *Client) rememberedContactID(identifier string) (int, bool) {
+	key := c.contactIDCacheKey(identifier)
+	if key == "" {
+		return 0, false
+	}
+	if contactID, ok := contactIDs.Get(key); ok {
+		return contactID, true
+	}
+	repo := getContactRepository()
+	if repo == nil {
+		return 0, false
+	}
+	contactID, err := repo.GetChatwootContactID(c.accountKey(), contactIDKey(identifier))
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to look up the contact of %s: %v", identifier, err)
+		return 0, false
+	}
+	if contactID == 0 {
+		return 0, false
+	}
+	contactIDs.Set(key, contactID)
+	return contactID, true
+}
+
+// forgetContactID drops the contact remembered for identifier in the account.
+func (c *Client) forgetContactID(identifier string) {
+	key := c.contactIDCacheKey(identifier)
+	if key == "" {
+		return
+	}
+	contactIDs.Delete(key)
+	if repo := getContactRepository(); repo != nil {
+		if err := repo.DeleteChatwootContactID(c.accountKey(), contactIDKey(identifier)); err != nil {
+			logrus.Warnf("Chatwoot: Failed to forget the contact of %s: %v", identifier, err)
+		}
+	}
+}
+
+// contactIdentifier returns the WhatsApp identifier of a contact: its waha_whatsapp_jid attribute,
+// else a JID identifier, else the digits of its phone number.
+func contactIdentifier(contact Contact) string {
+	if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); ok && strings.TrimSpace(jid) != "" {
+		return strings.TrimSpace(jid)
+	}
+	if strings.Contains(contact.Identifier, "@") {
+		return strings.TrimSpace(contact.Identifier)
+	}
+	return phoneDigits(contact.PhoneNumber)
+}
+
+// contactJID returns identifier as a full JID: bare phone numbers become user JIDs.
+func contactJID(identifier string) string {
+	if strings.Contains(identifier, "@") {
+		return identifier
+	}
+	return types.NewJID(identifier, types.DefaultUserServer).String()
+}
+
+// phoneDigits drops everything but the digits of a phone number.
+func phoneDigits(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	return digits.String()
+}
+
+// GetContact returns the contact with the given ID.
+func (c *Client) GetContact(contactID int) (*Contact, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/contacts/%d", c.BaseURL, c.AccountID, contactID)
+
+	var result struct {
+		Payload Contact `json:"payload"`
+	}
+	if _, err := c.doRequest("GET", endpoint, nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get contact: %w", err)
+	}
+	if result.Payload.ID == 0 {
+		return nil, fmt.Errorf("contact %d not found", contactID)
+	}
+	return &result.Payload, nil
+}
+
+// rememberedContact returns the contact last used for identifier when it still exists.
+func (c *Client) rememberedContact(identifier string) *Contact {
+	contactID, ok := c.rememberedContactID(identifier)
+	if !ok {
+		return nil
+	}
+	contact, err := c.GetContact(contactID)
+	if err != nil {
+		logrus.Debugf("Chatwoot: Contact %d remembered for %s is gone: %v", contactID, identifier, err)
+		c.forgetContactID(identifier)
+		return nil
+	}
+	return contact
+}
+
+// RepairContactMapping keeps a contact reachable from WhatsApp after Chatwoot merged it with another
+// one, which can drop the waha_whatsapp_jid attribute or the identifier set by the bridge. It is run
+// on contact_updated. Only contacts the bridge used for their identifier are looked at, and only when
+// the contact replaced the one remembered or its attribute no longer holds the full JID; any other
+// update returns without asking Chatwoot. A contact not remembered yet but carrying the attribute is
+// remembered.
+//
+// When the attribute is restored it is also set on contact. The open conversation of the contact in
+// the inbox is returned so its destination can be refreshed; nil when the contact needed no repair.
+func (c *Client) RepairContactMapping(contact *Contact) (*Conversation, error) {
+	identifier := contactIdentifier(*contact)
+	if contact.ID == 0 || identifier == "" {
+		return nil, nil
+	}
+
+	jid := contactJID(identifier)
+	current, _ := contact.CustomAttributes["waha_whatsapp_jid"].(string)
+	previousID, known := c.rememberedContactID(identifier)
+	if !known {
+		if current != "" {
+			c.rememberContactID(identifier, contact.ID)
+		}
+		return nil, nil
+	}
+	merged := previousID != contact.ID
+	if !merged && current == jid {
+		return nil, nil
+	}
+
+	conversation, err := c.FindConversation(contact.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current != jid {
+		isGroup := utils.IsGroupJID(identifier)
+		restoreIdentifier := ""
+		if contact.Identifier == "" {
+			restoreIdentifier = jid
+		}
+		attrs := map[string]interface{}{"waha_whatsapp_jid": jid}
+		if err := c.UpdateContactAttributes(contact.ID, restoreIdentifier, attrs, isGroup); err != nil {
+			return nil, err
+		}
+		if contact.CustomAttributes == nil {
+			contact.CustomAttributes = map[string]interface{}{}
+		}
+		contact.CustomAttributes["waha_whatsapp_jid"] = jid
+		logrus.Infof("Chatwoot: Restored WhatsApp identifier %s on contact %d", jid, contact.ID)
+	}
+	if merged {
+		logrus.Infof("Chatwoot: Contact %d of %s was merged into contact %d", previousID, identifier, contact.ID)
+	}
+	c.rememberContactID(identifier, contact.ID)
+	return conversation, nil
+}
@@ -0,0 +1,212 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+// mergeServer answers like Chatwoot after contact 5 was merged into contact 9.
+type mergeServer struct {
+	mu       sync.Mutex
+	requests int
+	updates  []map[string]interface{}
+}
+
+func (s *mergeServer) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/contacts/filter"):
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/contacts/search"):
+			_, _ = w.Write([]byte(`{"payload":[]}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/contacts/9/conversations"):
+			_, _ = w.Write([]byte(`{"payload":[{"id":77,"inbox_id":1,"status":"open"}]}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/conversations"):
+			_, _ = w.Write([]byte(`{"payload":[]}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/contacts/9"):
+			_, _ = w.Write([]byte(`{"payload":{"id":9,"name":"Maria (CRM)","phone_number":"+55 11 99999-0001"}}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/contacts/5"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"Resource could not be found"}`))
+		case r.Method == http.MethodPut:
+			var body map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode update: %v", err)
+			}
+			s.mu.Lock()
+			s.updates = append(s.updates, body)
+			s.mu.Unlock()
+			_, _ = w.Write([]byte(`{"payload":{}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+}
+
+func newMergeTestClient(t *testing.T) (*Client, *mergeServer) {
+	t.Helper()
+	s := &mergeServer{}
+	srv := httptest.NewServer(s.handler(t))
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}, s
+}
+
+func TestRepairContactMapping_MergedContact(t *testing.T) {
+	c, srv := newMergeTestClient(t)
+	c.rememberContactID("5511999990001", 5)
+	t.Cleanup(func() { contactIDs.Delete(c.contactIDCacheKey("5511999990001")) })
+
+	// contact_updated for the surviving CRM contact, which lost the attribute in the merge
+	var payload WebhookPayload
+	if err := json.Unmarshal([]byte(`{
+		"event": "contact_updated",
+		"id": 9,
+		"name": "Maria (CRM)",
+		"phone_number": "+55 11 99999-0001",
+		"identifier": "crm-4411",
+		"custom_attributes": {"crm_tier": "gold"}
+	}`), &payload); err != nil {
+		t.Fatal(err)
+	}
+	contact := payload.EventContact()
+
+	conversation, err := c.RepairContactMapping(&contact)
+	if err != nil {
+		t.Fatalf("RepairContactMapping returned error: %v", err)
+	}
+	if conversation == nil || conversation.ID != 77 {
+		t.Fatalf("expected the open conversation 77, got %+v", conversation)
+	}
+	if len(srv.updates) != 1 {
+		t.Fatalf("expected one contact update, got %v", srv.updates)
+	}
+	update := srv.updates[0]
+	if attrs, _ := update["custom_attributes"].(map[string]interface{}); attrs["waha_whatsapp_jid"] != "5511999990001@s.whatsapp.net" {
+		t.Errorf("expected the full JID attribute to be restored, got %v", update)
+	}
+	if _, ok := update["identifier"]; ok {
+		t.Errorf("expected the CRM identifier to be kept, got %v", update)
+	}
+	if contact.CustomAttributes["waha_whatsapp_jid"] != "5511999990001@s.whatsapp.net" {
+		t.Errorf("expected the attribute on the contact, got %v", contact.CustomAttributes)
+	}
+	if id, _ := contactIDs.Get(c.contactIDCacheKey("5511999990001")); id != 9 {
+		t.Errorf("expected the identifier to map to contact 9, got %d", id)
+	}
+}
+
+func TestRepairContactMapping_IgnoresContactsNeverBridged(t *testing.T) {
+	c, srv := newMergeTestClient(t)
+
+	contact := Contact{ID: 12, Name: "Lead", PhoneNumber: "+1 415 555 0199"}
+	conversation, err := c.RepairContactMapping(&contact)
+	if err != nil || conversation != nil {
+		t.Fatalf("expected nothing to repair, got %+v, %v", conversation, err)
+	}
+	if srv.requests != 0 {
+		t.Errorf("expected Chatwoot not to be asked, got %d requests", srv.requests)
+	}
+}
+
+func TestRepairContactMapping_UnchangedContactSkipsChatwoot(t *testing.T) {
+	c, srv := newMergeTestClient(t)
+	c.rememberContactID("5511999990001", 9)
+	t.Cleanup(func() { contactIDs.Delete(c.contactIDCacheKey("5511999990001")) })
+
+	contact := Contact{ID: 9, PhoneNumber: "+55 11 99999-0001", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "5511999990001@s.whatsapp.net"}}
+	conversation, err := c.RepairContactMapping(&contact)
+	if err != nil || conversation != nil {
+		t.Fatalf("expected nothing to repair, got %+v, %v", conversation, err)
+	}
+	if srv.requests != 0 {
+		t.Errorf("expected Chatwoot not to be asked for an unchanged contact, got %d requests", srv.requests)
+	}
+
+	// Bare digits left by an earlier version are replaced with the full JID
+	contact.CustomAttributes["waha_whatsapp_jid"] = "5511999990001"
+	if _, err := c.RepairContactMapping(&contact); err != nil {
+		t.Fatalf("RepairContactMapping returned error: %v", err)
+	}
+	if len(srv.updates) != 1 || contact.CustomAttributes["waha_whatsapp_jid"] != "5511999990001@s.whatsapp.net" {
+		t.Errorf("expected the attribute to become the full JID, got %v and %v", srv.updates, contact.CustomAttributes)
+	}
+}
+
+// memoryContactRepo keeps contact IDs like the chat storage does.
+type memoryContactRepo struct {
+	domainChatStorage.IChatStorageRepository
+	ids map[string]int
+}
+
+func (r *memoryContactRepo) GetChatwootContactID(account, identifier string) (int, error) {
+	return r.ids[account+"|"+identifier], nil
+}
+
+func (r *memoryContactRepo) SaveChatwootContactID(account, identifier string, contactID int) error {
+	r.ids[account+"|"+identifier] = contactID
+	return nil
+}
+
+func (r *memoryContactRepo) DeleteChatwootContactID(account, identifier string) error {
+	delete(r.ids, account+"|"+identifier)
+	return nil
+}
+
+func TestRepairContactMapping_MergeSurvivesRestart(t *testing.T) {
+	repo := &memoryContactRepo{ids: map[string]int{}}
+	SetContactRepository(repo)
+	t.Cleanup(func() { SetContactRepository(nil) })
+	c, srv := newMergeTestClient(t)
+	c.rememberContactID("5511999990001", 5)
+
+	// A restart empties the cache; the stored record still ties the number to contact 5
+	contactIDs.Delete(c.contactIDCacheKey("5511999990001"))
+	t.Cleanup(func() { contactIDs.Delete(c.contactIDCacheKey("5511999990001")) })
+
+	contact := Contact{ID: 9, Name: "Maria (CRM)", PhoneNumber: "+55 11 99999-0001"}
+	conversation, err := c.RepairContactMapping(&contact)
+	if err != nil || conversation == nil || conversation.ID != 77 {
+		t.Fatalf("expected the merged contact to be repaired, got %+v, %v", conversation, err)
+	}
+	if len(srv.updates) != 1 {
+		t.Errorf("expected one contact update, got %v", srv.updates)
+	}
+	if id := repo.ids[c.accountKey()+"|5511999990001"]; id != 9 {
+		t.Errorf("expected the stored record to follow the merge to contact 9, got %d", id)
+	}
+}
+
+func TestFindContactByIdentifier_FallsBackToRememberedContact(t *testing.T) {
+	c, _ := newMergeTestClient(t)
+	c.rememberContactID("5511999990001@s.whatsapp.net", 9)
+	c.rememberContactID("5511999990002", 5)
+	t.Cleanup(func() {
+		contactIDs.Delete(c.contactIDCacheKey("5511999990001"))
+		contactIDs.Delete(c.contactIDCacheKey("5511999990002"))
+	})
+
+	contact, err := c.FindContactByIdentifier("5511999990001", false)
+	if err != nil || contact == nil || contact.ID != 9 {
+		t.Fatalf("expected remembered contact 9, got %+v, %v", contact, err)
+	}
+
+	// The merged-away contact no longer exists, so a new one will be created
+	contact, err = c.FindContactByIdentifier("5511999990002", false)
+	if err != nil || contact != nil {
+		t.Fatalf("expected no contact, got %+v, %v", contact, err)
+	}
+	if _, ok := contactIDs.Get(c.contactIDCacheKey("5511999990002")); ok {
+		t.Error("expected the stale contact ID to be forgotten")
+	}
+}
@@ -0,0 +1,113 @@
+package chatwoot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ConversationMetadata is what the bridge records on a conversation as custom attributes, so
+// automation rules can branch on the device and the kind of chat.
+type ConversationMetadata struct {
+	DeviceID            string // ID of the device handling the chat, as used by the API
+	ChatJID             string
+	IsGroup             bool
+	EphemeralExpiration uint32 // disappearing-message timer of the chat in seconds, 0 when off
+}
+
+// NewConversationMetadata describes the chat chatJID of a device. deviceID may be the JID a device is
+// stored under; it is recorded as the device ID when one is known for it.
+func NewConversationMetadata(deviceID, chatJID string, ephemeralExpiration uint32) ConversationMetadata {
+	if alias := resolveDeviceAlias(deviceID); alias != "" {
+		deviceID = alias
+	}
+	return ConversationMetadata{
+		DeviceID:            deviceID,
+		ChatJID:             chatJID,
+		IsGroup:             utils.IsGroupJID(chatJID),
+		EphemeralExpiration: ephemeralExpiration,
+	}
+}
+
+// Attributes returns the conversation custom attributes of m.
+func (m ConversationMetadata) Attributes() map[string]interface{} {
+	return map[string]interface{}{
+		"waha_device_id": m.DeviceID,
+		"waha_chat_jid":  m.ChatJID,
+		"waha_is_group":  m.IsGroup,
+		"waha_ephemeral": ephemeralLabel(m.EphemeralExpiration),
+	}
+}
+
+// ephemeralLabel names a disappearing-message timer the way WhatsApp offers it: "off", "1d", "7d", "90d".
+func ephemeralLabel(seconds uint32) string {
+	switch {
+	case seconds == 0:
+		return "off"
+	case seconds%86400 == 0:
+		return fmt.Sprintf("%dd", seconds/86400)
+	case seconds%3600 == 0:
+		return fmt.Sprintf("%dh", seconds/3600)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+// conversationMetadata remembers the metadata last written to each conversation, so it is written
+// again only when it changes, e.g. when another device takes the chat over.
+var conversationMetadata = utils.NewTTLCache[scopedID, ConversationMetadata](10000, 30*time.Minute)
+
+// UpdateConversationAttributes sets custom attributes of a conversation. When the first attribute
+// write of an account is rejected, the missing attribute definitions are created and the write is
+// tried again.
+func (c *Client) UpdateConversationAttributes(conversationID int, attrs map[string]interface{}) error {
+	err := c.updateConversationAttributes(conversationID, attrs)
+	if err != nil && c.ensureAttributesAfter(err) {
+		return c.updateConversationAttributes(conversationID, attrs)
+	}
+	return err
+}
+
+func (c *Client) updateConversationAttributes(conversationID int, attrs map[string]interface{}) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/conversations/%d", c.BaseURL, c.AccountID, conversationID)
+	payload := map[string]interface{}{"custom_attributes": attrs}
+	if _, err := c.doRequest("PATCH", endpoint, payload, nil); err != nil {
+		return fmt.Errorf("failed to update the attributes of conversation %d: %w", conversationID, err)
+	}
+	return nil
+}
+
+// StoreConversationMetadata writes meta to the custom attributes of conv, unless the conversation
+// already carries it. A failed write is logged; the message it came with is posted regardless.
+func (c *Client) StoreConversationMetadata(conv *Conversation, meta ConversationMetadata) {
+	if conv == nil || conv.ID == 0 || meta.ChatJID == "" {
+		return
+	}
+	key := c.scopedID(conv.ID)
+	if written, ok := conversationMetadata.Get(key); ok && written == meta {
+		return
+	}
+	attrs := meta.Attributes()
+	if hasAttributes(conv.CustomAttributes, attrs) {
+		conversationMetadata.Set(key, meta)
+		return
+	}
+	if err := c.UpdateConversationAttributes(conv.ID, attrs); err != nil {
+		logrus.Warnf("Chatwoot: Failed to store the metadata of chat %s on conversation %d: %v", meta.ChatJID, conv.ID, err)
+		return
+	}
+	conversationMetadata.Set(key, meta)
+}
+
+// hasAttributes reports whether current holds every attribute of want with the same value.
+func hasAttributes(current, want map[string]interface{}) bool {
+	for key, value := range want {
+		got, ok := current[key]
+		if !ok || fmt.Sprint(got) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
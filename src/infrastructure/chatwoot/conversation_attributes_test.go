@@ -0,0 +1,79 @@
+package chatwoot
+
+import (
+	"testing"
+)
+
+func TestEphemeralLabel(t *testing.T) {
+	for seconds, want := range map[uint32]string{0: "off", 86400: "1d", 604800: "7d", 7776000: "90d", 7200: "2h", 90: "90s"} {
+		if got := ephemeralLabel(seconds); got != want {
+			t.Errorf("ephemeralLabel(%d) = %q, want %q", seconds, got, want)
+		}
+	}
+}
+
+func TestStoreConversationMetadata_PatchesAttributes(t *testing.T) {
+	c, srv := newAttributeTestClient(t)
+	t.Cleanup(func() { conversationMetadata.Delete(c.scopedID(42)) })
+
+	conv := &Conversation{ID: 42}
+	meta := NewConversationMetadata("sales", "120363000000000000@g.us", 604800)
+	c.StoreConversationMetadata(conv, meta)
+
+	got := srv.conversations["/api/v1/accounts/1/conversations/42"]
+	want := map[string]interface{}{
+		"waha_device_id": "sales",
+		"waha_chat_jid":  "120363000000000000@g.us",
+		"waha_is_group":  true,
+		"waha_ephemeral": "7d",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected attributes %v, got %v", want, got)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Fatalf("expected attributes %v, got %v", want, got)
+		}
+	}
+	// The first write is rejected until the definitions exist
+	if srv.updates != 2 || len(srv.created) == 0 {
+		t.Fatalf("expected the definitions to be created and the write retried, got %d updates", srv.updates)
+	}
+
+	// Metadata already written is not written again
+	c.StoreConversationMetadata(conv, meta)
+	if srv.updates != 2 {
+		t.Fatalf("expected unchanged metadata to be skipped, got %d updates", srv.updates)
+	}
+
+	// Another device taking the chat over is recorded
+	c.StoreConversationMetadata(conv, NewConversationMetadata("support", "120363000000000000@g.us", 604800))
+	if srv.updates != 3 || srv.conversations["/api/v1/accounts/1/conversations/42"]["waha_device_id"] != "support" {
+		t.Fatalf("expected the new device to be written, got %v", srv.conversations)
+	}
+}
+
+func TestStoreConversationMetadata_SkipsAttributesAlreadySet(t *testing.T) {
+	c, srv := newAttributeTestClient(t)
+	t.Cleanup(func() { conversationMetadata.Delete(c.scopedID(7)) })
+
+	meta := NewConversationMetadata("sales", "628111@s.whatsapp.net", 0)
+	conv := &Conversation{ID: 7, CustomAttributes: map[string]interface{}{
+		"waha_device_id": "sales",
+		"waha_chat_jid":  "628111@s.whatsapp.net",
+		"waha_is_group":  false,
+		"waha_ephemeral": "off",
+		"priority":       "high",
+	}}
+	c.StoreConversationMetadata(conv, meta)
+	if srv.updates != 0 {
+		t.Fatalf("expected a conversation carrying the metadata to be left alone, got %d updates", srv.updates)
+	}
+
+	conv.CustomAttributes["waha_ephemeral"] = "1d"
+	conversationMetadata.Delete(c.scopedID(7))
+	c.StoreConversationMetadata(conv, meta)
+	if srv.updates == 0 || srv.conversations["/api/v1/accounts/1/conversations/7"]["waha_ephemeral"] != "off" {
+		t.Fatalf("expected a stale attribute to be rewritten, got %v", srv.conversations)
+	}
+}
@@ -0,0 +1,47 @@
+package chatwoot
+
+import (
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+)
+
+// conversationDestinations remembers the WhatsApp destination resolved on conversation_created so the
+// message_created that follows does not depend on contact attributes Chatwoot may not have filled yet.
+// Entries expire after 30 minutes; being bounded, the cache needs no sweeper.
+var conversationDestinations = utils.NewTTLCache[scopedID, string](10000, 30*time.Minute)
+
+// RememberConversationDestination caches the destination resolved for a conversation of the account.
+func (c *Client) RememberConversationDestination(conversationID int, destination string) {
+	if conversationID == 0 || destination == "" {
+		return
+	}
+	conversationDestinations.Set(c.scopedID(conversationID), destination)
+}
+
+// ConversationDestination returns the cached destination for a conversation of the account, if still
+// fresh.
+func (c *Client) ConversationDestination(conversationID int) (string, bool) {
+	if conversationID == 0 {
+		return "", false
+	}
+	return conversationDestinations.Get(c.scopedID(conversationID))
+}
+
+// ResetConversationDestinations forgets every cached destination, e.g. after the Chatwoot inbox changed.
+func ResetConversationDestinations() {
+	conversationDestinations.Purge()
+	contactConversations.Purge()
+}
+
+// contactConversations remembers the open conversation of each contact, so FindOrCreateConversation
+// does not list the contact's conversations for every message. Entries are dropped when the
+// conversation is resolved; the TTL bounds how long a resolved one is used when that event is not
+// received.
+var contactConversations = utils.NewTTLCache[scopedID, Conversation](10000, 10*time.Minute)
+
+// ForgetContactConversation drops the conversation remembered for a contact of the account, so the
+// next message looks it up again.
+func (c *Client) ForgetContactConversation(contactID int) {
+	contactConversations.Delete(c.scopedID(contactID))
+}
@@ -0,0 +1,136 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// conversationServer keeps the conversations of one contact like Chatwoot. existing are conversations
+// another process creates together with the first POST /conversations.
+type conversationServer struct {
+	mu       sync.Mutex
+	convs    []map[string]any
+	existing []map[string]any
+	creates  int
+	resolved []string
+}
+
+func (s *conversationServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/1/")
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/conversations"):
+		// Let concurrent lookups overlap, as they would against a real server
+		s.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		s.mu.Lock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"payload": s.convs})
+	case r.Method == http.MethodPost && path == "conversations":
+		s.creates++
+		s.convs = append(s.convs, s.existing...)
+		conv := map[string]any{"id": 50 + s.creates, "inbox_id": 1, "status": "open"}
+		s.convs = append(s.convs, conv)
+		_ = json.NewEncoder(w).Encode(conv)
+	case strings.HasSuffix(path, "/labels"):
+		conv := s.conversation(path)
+		if conv == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost {
+			var body struct {
+				Labels []string `json:"labels"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			conv["labels"] = body.Labels
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"payload": conv["labels"]})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/toggle_status"):
+		s.resolved = append(s.resolved, path)
+		_, _ = w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// conversation returns the conversation a conversations/{id}/... path refers to.
+func (s *conversationServer) conversation(path string) map[string]any {
+	id, _ := strconv.Atoi(strings.Split(path, "/")[1])
+	for _, conv := range s.convs {
+		if conv["id"] == id {
+			return conv
+		}
+	}
+	return nil
+}
+
+func newConversationTestClient(t *testing.T, s *conversationServer) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func TestFindOrCreateConversation_ConcurrentMessagesShareOneConversation(t *testing.T) {
+	s := &conversationServer{}
+	c := newConversationTestClient(t, s)
+	t.Cleanup(func() { c.ForgetContactConversation(811) })
+
+	var wg sync.WaitGroup
+	ids := make([]int, 20)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conv, err := c.FindOrCreateConversation(811)
+			if err != nil {
+				t.Errorf("FindOrCreateConversation returned error: %v", err)
+				return
+			}
+			ids[i] = conv.ID
+		}(i)
+	}
+	wg.Wait()
+
+	if s.creates != 1 {
+		t.Fatalf("expected exactly one POST /conversations, got %d", s.creates)
+	}
+	for _, id := range ids {
+		if id != 51 {
+			t.Fatalf("expected every caller to get conversation 51, got %v", ids)
+		}
+	}
+}
+
+func TestFindOrCreateConversation_ResolvesNewerDuplicate(t *testing.T) {
+	s := &conversationServer{existing: []map[string]any{{"id": 40, "inbox_id": 1, "status": "open"}}}
+	c := newConversationTestClient(t, s)
+	t.Cleanup(func() { c.ForgetContactConversation(812) })
+
+	conv, err := c.FindOrCreateConversation(812)
+	if err != nil {
+		t.Fatalf("FindOrCreateConversation returned error: %v", err)
+	}
+	if conv.ID != 40 {
+		t.Errorf("expected the older conversation 40, got %d", conv.ID)
+	}
+	if len(s.resolved) != 1 || s.resolved[0] != "conversations/51/toggle_status" {
+		t.Errorf("expected the duplicate to be resolved, got %v", s.resolved)
+	}
+
+	// Later messages use the remembered conversation
+	s.mu.Lock()
+	s.convs = nil
+	s.mu.Unlock()
+	if again, err := c.FindOrCreateConversation(812); err != nil || again.ID != 40 || s.creates != 1 {
+		t.Errorf("expected the remembered conversation, got %+v, %v (creates %d)", again, err, s.creates)
+	}
+}
@@ -0,0 +1,137 @@
+package chatwoot
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+// CSATContentType is the content_type of the survey message Chatwoot creates when a conversation
+// is resolved in an inbox with CSAT enabled.
+const CSATContentType = "input_csat"
+
+var reCSATSurveyLink = regexp.MustCompile(`/survey/responses/([0-9a-fA-F-]{36})`)
+
+var (
+	csatRepoMu sync.RWMutex
+	csatRepo   domainChatStorage.IChatStorageRepository
+)
+
+// SetCSATSurveyRepository sets the storage used to track surveys awaiting a reply.
+func SetCSATSurveyRepository(repo domainChatStorage.IChatStorageRepository) {
+	csatRepoMu.Lock()
+	defer csatRepoMu.Unlock()
+	csatRepo = repo
+}
+
+func getCSATSurveyRepository() domainChatStorage.IChatStorageRepository {
+	csatRepoMu.RLock()
+	defer csatRepoMu.RUnlock()
+	return csatRepo
+}
+
+// CSATSurveyLink returns the public survey page of a conversation.
+func CSATSurveyLink(baseURL, surveyUUID string) string {
+	return fmt.Sprintf("%s/survey/responses/%s", strings.TrimRight(baseURL, "/"), surveyUUID)
+}
+
+// SurveyUUIDFromContent extracts the conversation UUID from a survey link in a message.
+func SurveyUUIDFromContent(content string) string {
+	if m := reCSATSurveyLink.FindStringSubmatch(content); len(m) == 2 {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// ParseCSATRating returns the rating of a reply that consists only of a digit from 1 to 5.
+func ParseCSATRating(text string) (int, bool) {
+	text = strings.TrimSpace(text)
+	if len(text) != 1 || text[0] < '1' || text[0] > '5' {
+		return 0, false
+	}
+	rating, _ := strconv.Atoi(text)
+	return rating, true
+}
+
+// TrackCSATSurvey remembers that identifier was sent the survey of a conversation, so a bare rating
+// received within ChatwootCSATReplyWindowHours is submitted to Chatwoot.
+func TrackCSATSurvey(identifier string, conversationID int, surveyUUID string) error {
+	repo := getCSATSurveyRepository()
+	if repo == nil || config.ChatwootCSATReplyWindowHours <= 0 || identifier == "" || surveyUUID == "" {
+		return nil
+	}
+
+	return repo.SavePendingCSATSurvey(&domainChatStorage.PendingCSATSurvey{
+		Identifier:     identifier,
+		ConversationID: conversationID,
+		SurveyUUID:     surveyUUID,
+		ExpiresAt:      time.Now().Add(time.Duration(config.ChatwootCSATReplyWindowHours) * time.Hour),
+	})
+}
+
+// SubmitCSATRating sends a rating to Chatwoot's public CSAT endpoint, the same call the survey page makes.
+func (c *Client) SubmitCSATRating(surveyUUID string, rating int) error {
+	endpoint := fmt.Sprintf("%s/public/api/v1/csat_survey/%s", c.BaseURL, url.PathEscape(surveyUUID))
+	payload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"submitted_values": map[string]interface{}{
+				"csat_survey_response": map[string]interface{}{
+					"rating": rating,
+				},
+			},
+		},
+	}
+
+	if _, err := c.doRequest(http.MethodPut, endpoint, payload, nil); err != nil {
+		return fmt.Errorf("failed to submit CSAT rating: %w", err)
+	}
+	return nil
+}
+
+// HandleCSATReply submits text as the rating of the survey pending for identifier and reports whether
+// the reply was consumed. The answer to a rating prompt, which has no survey, is posted as a private
+// note on its conversation instead. Replies that are not a bare rating, or that arrive without a survey in the
+// window, are left to be forwarded as normal messages.
+func (c *Client) HandleCSATReply(identifier, text string) (bool, error) {
+	rating, ok := ParseCSATRating(text)
+	if !ok {
+		return false, nil
+	}
+	repo := getCSATSurveyRepository()
+	if repo == nil || config.ChatwootCSATReplyWindowHours <= 0 {
+		return false, nil
+	}
+
+	survey, err := repo.GetPendingCSATSurvey(identifier, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to load pending survey for %s: %w", identifier, err)
+	}
+	if survey == nil {
+		return false, nil
+	}
+
+	if survey.SurveyUUID == "" {
+		noteID, err := c.CreatePrivateNote(survey.ConversationID, RatingNote(rating))
+		if err != nil {
+			return false, fmt.Errorf("conversation %d: failed to post rating: %w", survey.ConversationID, err)
+		}
+		c.MarkMessageAsSent(noteID)
+	} else if err := c.SubmitCSATRating(survey.SurveyUUID, rating); err != nil {
+		return false, fmt.Errorf("conversation %d: %w", survey.ConversationID, err)
+	}
+	if err := repo.DeletePendingCSATSurvey(identifier); err != nil {
+		logrus.Warnf("Chatwoot: Failed to clear pending survey for %s: %v", identifier, err)
+	}
+
+	logrus.Infof("Chatwoot: Submitted CSAT rating %d for conversation %d from %s", rating, survey.ConversationID, identifier)
+	return true, nil
+}
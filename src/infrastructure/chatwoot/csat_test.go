@@ -0,0 +1,134 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+type memorySurveyRepo struct {
+	domainChatStorage.IChatStorageRepository
+	surveys map[string]*domainChatStorage.PendingCSATSurvey
+}
+
+func (m *memorySurveyRepo) SavePendingCSATSurvey(s *domainChatStorage.PendingCSATSurvey) error {
+	m.surveys[s.Identifier] = s
+	return nil
+}
+
+func (m *memorySurveyRepo) GetPendingCSATSurvey(identifier string, now time.Time) (*domainChatStorage.PendingCSATSurvey, error) {
+	s, ok := m.surveys[identifier]
+	if !ok || !s.ExpiresAt.After(now) {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (m *memorySurveyRepo) DeletePendingCSATSurvey(identifier string) error {
+	delete(m.surveys, identifier)
+	return nil
+}
+
+func useSurveyRepo(t *testing.T) *memorySurveyRepo {
+	t.Helper()
+	repo := &memorySurveyRepo{surveys: map[string]*domainChatStorage.PendingCSATSurvey{}}
+	prevWindow := config.ChatwootCSATReplyWindowHours
+	SetCSATSurveyRepository(repo)
+	config.ChatwootCSATReplyWindowHours = 24
+	t.Cleanup(func() {
+		SetCSATSurveyRepository(nil)
+		config.ChatwootCSATReplyWindowHours = prevWindow
+	})
+	return repo
+}
+
+func TestParseCSATRating(t *testing.T) {
+	tests := []struct {
+		text   string
+		rating int
+		ok     bool
+	}{
+		{text: "5", rating: 5, ok: true},
+		{text: " 1\n", rating: 1, ok: true},
+		{text: "0", ok: false},
+		{text: "6", ok: false},
+		{text: "10", ok: false},
+		{text: "5 stars", ok: false},
+		{text: "", ok: false},
+	}
+	for _, tt := range tests {
+		rating, ok := ParseCSATRating(tt.text)
+		if ok != tt.ok || rating != tt.rating {
+			t.Fatalf("ParseCSATRating(%q)=(%d,%v) want (%d,%v)", tt.text, rating, ok, tt.rating, tt.ok)
+		}
+	}
+}
+
+func TestSurveyUUIDFromContent(t *testing.T) {
+	content := "Please rate this conversation\nhttps://cw.example.com/survey/responses/0F8E6A1C-1111-4222-8333-944455556666"
+	if got := SurveyUUIDFromContent(content); got != "0f8e6a1c-1111-4222-8333-944455556666" {
+		t.Fatalf("unexpected survey uuid %q", got)
+	}
+	if got := SurveyUUIDFromContent("no link here"); got != "" {
+		t.Fatalf("expected no uuid, got %q", got)
+	}
+}
+
+func TestHandleCSATReply_SubmitsRatingWithinWindow(t *testing.T) {
+	repo := useSurveyRepo(t)
+
+	var gotPath string
+	var gotRating float64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.Method + " " + r.URL.Path
+		var body map[string]map[string]map[string]map[string]float64
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode body: %v", err)
+		}
+		gotRating = body["message"]["submitted_values"]["csat_survey_response"]["rating"]
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+
+	if err := TrackCSATSurvey("5511999", 42, "abc-uuid"); err != nil {
+		t.Fatalf("TrackCSATSurvey returned error: %v", err)
+	}
+
+	handled, err := c.HandleCSATReply("5511999", "hello")
+	if handled || err != nil {
+		t.Fatalf("expected non-numeric reply to pass through, got handled=%v err=%v", handled, err)
+	}
+
+	handled, err = c.HandleCSATReply("5511999", "4")
+	if !handled || err != nil {
+		t.Fatalf("expected rating to be submitted, got handled=%v err=%v", handled, err)
+	}
+	if gotPath != "PUT /public/api/v1/csat_survey/abc-uuid" || gotRating != 4 {
+		t.Fatalf("unexpected submission %s rating=%v", gotPath, gotRating)
+	}
+	if len(repo.surveys) != 0 {
+		t.Fatalf("expected pending survey to be cleared")
+	}
+
+	handled, _ = c.HandleCSATReply("5511999", "5")
+	if handled {
+		t.Fatalf("expected a second rating to pass through once the survey was answered")
+	}
+}
+
+func TestHandleCSATReply_IgnoresExpiredSurvey(t *testing.T) {
+	repo := useSurveyRepo(t)
+	repo.surveys["5511999"] = &domainChatStorage.PendingCSATSurvey{Identifier: "5511999", SurveyUUID: "abc", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	c := &Client{BaseURL: "http://127.0.0.1:0", HTTPClient: &http.Client{Timeout: time.Second}}
+	handled, err := c.HandleCSATReply("5511999", "3")
+	if handled || err != nil {
+		t.Fatalf("expected expired survey to be ignored, got handled=%v err=%v", handled, err)
+	}
+}
@@ -0,0 +1,168 @@
+package chatwoot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Chatwoot enum values of custom attribute definitions.
+const (
+	attributeDisplayTypeText     = 0
+	attributeDisplayTypeCheckbox = 7
+	attributeModelConversation   = 0
+	attributeModelContact        = 1
+)
+
+// CustomAttributeDefinition is a custom attribute an account defines for contacts or conversations.
+type CustomAttributeDefinition struct {
+	ID                   int    `json:"id"`
+	AttributeKey         string `json:"attribute_key"`
+	AttributeDisplayName string `json:"attribute_display_name"`
+}
+
+// contactAttributeDefinitions are the contact attributes the bridge writes, so a fresh account shows
+// them and strict installs accept them.
+var contactAttributeDefinitions = []struct {
+	key, name, description string
+}{
+	{"waha_whatsapp_jid", "WhatsApp JID", "WhatsApp phone number or group JID of the contact"},
+	{"waha_whatsapp_lid", "WhatsApp LID", "WhatsApp LID of the contact"},
+	{"waha_duplicate_of", "WhatsApp duplicate of", "ID of the contact that replaced this duplicate WhatsApp contact"},
+	{"waha_device", "WhatsApp device", "WhatsApp device the contact writes to"},
+	{"waha_avatar_hash", "WhatsApp avatar hash", "Hash of the last WhatsApp profile picture synced"},
+	{"waha_avatar_checked_at", "WhatsApp avatar checked at", "When the WhatsApp profile picture was last checked"},
+}
+
+// conversationAttributeDefinitions are the conversation attributes the bridge writes, see
+// ConversationMetadata, so automation rules can use them.
+var conversationAttributeDefinitions = []struct {
+	key, name, description string
+	displayType            int
+}{
+	{"waha_device_id", "WhatsApp device ID", "WhatsApp device the chat is handled by", attributeDisplayTypeText},
+	{"waha_chat_jid", "WhatsApp chat JID", "WhatsApp JID of the chat", attributeDisplayTypeText},
+	{"waha_is_group", "WhatsApp group", "Whether the chat is a WhatsApp group", attributeDisplayTypeCheckbox},
+	{"waha_ephemeral", "WhatsApp disappearing messages", "Disappearing-message timer of the chat, or off", attributeDisplayTypeText},
+}
+
+// ensuredAttributeAccounts holds the accounts, by Chatwoot URL and account ID, whose contact and
+// conversation attribute definitions are known to exist.
+var (
+	ensuredAttributeAccounts sync.Map
+	ensureAttributesMu       sync.Mutex
+)
+
+// ListCustomAttributeDefinitions returns the contact attribute definitions of the account.
+func (c *Client) ListCustomAttributeDefinitions() ([]CustomAttributeDefinition, error) {
+	return c.listAttributeDefinitions(attributeModelContact)
+}
+
+func (c *Client) listAttributeDefinitions(model int) ([]CustomAttributeDefinition, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/custom_attribute_definitions?attribute_model=%d", c.BaseURL, c.AccountID, model)
+
+	var definitions []CustomAttributeDefinition
+	if _, err := c.doRequest("GET", endpoint, nil, &definitions); err != nil {
+		return nil, fmt.Errorf("failed to list custom attribute definitions: %w", err)
+	}
+	return definitions, nil
+}
+
+func (c *Client) createAttributeDefinition(model, displayType int, key, name, description string) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/custom_attribute_definitions", c.BaseURL, c.AccountID)
+	payload := map[string]interface{}{
+		"attribute_key":          key,
+		"attribute_display_name": name,
+		"attribute_description":  description,
+		"attribute_display_type": displayType,
+		"attribute_model":        model,
+	}
+	if _, err := c.doRequest("POST", endpoint, payload, nil); err != nil {
+		return fmt.Errorf("failed to create custom attribute %s: %w", key, err)
+	}
+	return nil
+}
+
+// EnsureCustomAttributeDefinitions creates the definitions of the contact and conversation
+// attributes the bridge writes that the account lacks, and returns their keys. Once all exist the
+// account is not checked again.
+func (c *Client) EnsureCustomAttributeDefinitions() ([]string, error) {
+	key := c.accountKey()
+	if _, ok := ensuredAttributeAccounts.Load(key); ok {
+		return nil, nil
+	}
+	ensureAttributesMu.Lock()
+	defer ensureAttributesMu.Unlock()
+	if _, ok := ensuredAttributeAccounts.Load(key); ok {
+		return nil, nil
+	}
+
+	existing, err := c.existingAttributeKeys(attributeModelContact)
+	if err != nil {
+		return nil, err
+	}
+	var created []string
+	for _, attr := range contactAttributeDefinitions {
+		if existing[attr.key] {
+			continue
+		}
+		if err := c.createAttributeDefinition(attributeModelContact, attributeDisplayTypeText, attr.key, attr.name, attr.description); err != nil {
+			return created, err
+		}
+		created = append(created, attr.key)
+	}
+
+	if existing, err = c.existingAttributeKeys(attributeModelConversation); err != nil {
+		return created, err
+	}
+	for _, attr := range conversationAttributeDefinitions {
+		if existing[attr.key] {
+			continue
+		}
+		if err := c.createAttributeDefinition(attributeModelConversation, attr.displayType, attr.key, attr.name, attr.description); err != nil {
+			return created, err
+		}
+		created = append(created, attr.key)
+	}
+	ensuredAttributeAccounts.Store(key, struct{}{})
+	if len(created) > 0 {
+		logrus.Infof("Chatwoot: Created custom attribute definitions %v", created)
+	}
+	return created, nil
+}
+
+// existingAttributeKeys returns the keys of the attribute definitions of one model the account has.
+func (c *Client) existingAttributeKeys(model int) (map[string]bool, error) {
+	definitions, err := c.listAttributeDefinitions(model)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(definitions))
+	for _, definition := range definitions {
+		existing[definition.AttributeKey] = true
+	}
+	return existing, nil
+}
+
+// ensureAttributesAfter reports whether a custom attribute write that failed with err may succeed
+// once the attribute definitions exist, creating them when so. Only the first rejection of an
+// account is followed up.
+func (c *Client) ensureAttributesAfter(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) || status.status < http.StatusBadRequest || status.status >= http.StatusInternalServerError ||
+		status.status == http.StatusNotFound || status.status == http.StatusTooManyRequests {
+		return false
+	}
+	if _, ok := ensuredAttributeAccounts.Load(c.accountKey()); ok {
+		return false
+	}
+	created, ensureErr := c.EnsureCustomAttributeDefinitions()
+	if ensureErr != nil {
+		logrus.Warnf("Chatwoot: Failed to create custom attribute definitions: %v", ensureErr)
+		return false
+	}
+	return len(created) > 0
+}
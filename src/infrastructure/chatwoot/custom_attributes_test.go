@@ -0,0 +1,183 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// attributeServer answers like a Chatwoot account that defines the attributes in existing and
+// rejects contact and conversation updates carrying other attributes.
+type attributeServer struct {
+	mu            sync.Mutex
+	existing      map[string]bool
+	lists         int
+	created       []map[string]interface{}
+	updates       int
+	conversations map[string]map[string]interface{} // attributes written per conversation path
+}
+
+// attributeModelOf returns the model the bridge defines key on.
+func attributeModelOf(key string) int {
+	for _, attr := range conversationAttributeDefinitions {
+		if attr.key == key {
+			return attributeModelConversation
+		}
+	}
+	return attributeModelContact
+}
+
+func (s *attributeServer) rejectUnknown(w http.ResponseWriter, attrs map[string]interface{}) bool {
+	for key := range attrs {
+		if !s.existing[key] {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"message":"unknown custom attribute"}`))
+			return true
+		}
+	}
+	return false
+}
+
+func (s *attributeServer) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/custom_attribute_definitions"):
+			s.lists++
+			model, err := strconv.Atoi(r.URL.Query().Get("attribute_model"))
+			if err != nil {
+				t.Errorf("expected the attributes of one model to be listed, got %s", r.URL.RawQuery)
+			}
+			definitions := []CustomAttributeDefinition{}
+			for key := range s.existing {
+				if attributeModelOf(key) == model {
+					definitions = append(definitions, CustomAttributeDefinition{ID: len(definitions) + 1, AttributeKey: key})
+				}
+			}
+			_ = json.NewEncoder(w).Encode(definitions)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/custom_attribute_definitions"):
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			s.created = append(s.created, body)
+			s.existing[body["attribute_key"].(string)] = true
+			_, _ = w.Write([]byte(`{"id":99}`))
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/contacts/"):
+			s.updates++
+			var body struct {
+				CustomAttributes map[string]interface{} `json:"custom_attributes"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if s.rejectUnknown(w, body.CustomAttributes) {
+				return
+			}
+			_, _ = w.Write([]byte(`{"payload":{}}`))
+		case r.Method == http.MethodPatch && strings.Contains(r.URL.Path, "/conversations/"):
+			s.updates++
+			var body map[string]map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if len(body) != 1 || body["custom_attributes"] == nil {
+				t.Errorf("expected only custom attributes to be patched, got %v", body)
+			}
+			if s.rejectUnknown(w, body["custom_attributes"]) {
+				return
+			}
+			s.conversations[r.URL.Path] = body["custom_attributes"]
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+		}
+	})
+}
+
+func newAttributeTestClient(t *testing.T, existing ...string) (*Client, *attributeServer) {
+	t.Helper()
+	s := &attributeServer{existing: map[string]bool{}, conversations: map[string]map[string]interface{}{}}
+	for _, key := range existing {
+		s.existing[key] = true
+	}
+	srv := httptest.NewServer(s.handler(t))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	t.Cleanup(func() { ensuredAttributeAccounts.Delete(c.accountKey()) })
+	return c, s
+}
+
+func TestEnsureCustomAttributeDefinitions_AllExist(t *testing.T) {
+	keys := make([]string, 0, len(contactAttributeDefinitions)+len(conversationAttributeDefinitions))
+	for _, attr := range contactAttributeDefinitions {
+		keys = append(keys, attr.key)
+	}
+	for _, attr := range conversationAttributeDefinitions {
+		keys = append(keys, attr.key)
+	}
+	c, srv := newAttributeTestClient(t, keys...)
+
+	created, err := c.EnsureCustomAttributeDefinitions()
+	if err != nil {
+		t.Fatalf("EnsureCustomAttributeDefinitions returned error: %v", err)
+	}
+	if len(created) != 0 || len(srv.created) != 0 {
+		t.Fatalf("expected nothing to be created, got %v", srv.created)
+	}
+
+	// The account is not checked again
+	if _, err := c.EnsureCustomAttributeDefinitions(); err != nil {
+		t.Fatalf("second call returned error: %v", err)
+	}
+	if srv.lists != 2 {
+		t.Fatalf("expected the definitions of each model to be listed once, got %d lists", srv.lists)
+	}
+}
+
+func TestEnsureCustomAttributeDefinitions_CreatesMissing(t *testing.T) {
+	c, srv := newAttributeTestClient(t, "waha_whatsapp_jid")
+
+	created, err := c.EnsureCustomAttributeDefinitions()
+	if err != nil {
+		t.Fatalf("EnsureCustomAttributeDefinitions returned error: %v", err)
+	}
+	if len(created) != len(contactAttributeDefinitions)-1+len(conversationAttributeDefinitions) {
+		t.Fatalf("expected every definition but waha_whatsapp_jid to be created, got %v", created)
+	}
+	for _, body := range srv.created {
+		key := body["attribute_key"].(string)
+		if key == "waha_whatsapp_jid" {
+			t.Fatalf("expected the existing definition to be left alone, got %v", srv.created)
+		}
+		displayType := attributeDisplayTypeText
+		if key == "waha_is_group" {
+			displayType = attributeDisplayTypeCheckbox
+		}
+		if body["attribute_display_type"] != float64(displayType) || body["attribute_model"] != float64(attributeModelOf(key)) || body["attribute_display_name"] == "" {
+			t.Fatalf("expected %s to be defined on its model with a display name, got %v", key, body)
+		}
+	}
+}
+
+func TestUpdateContactAttributes_CreatesDefinitionsOnRejection(t *testing.T) {
+	c, srv := newAttributeTestClient(t, "waha_whatsapp_jid")
+
+	attrs := map[string]interface{}{"waha_avatar_hash": "abc"}
+	if err := c.UpdateContactAttributes(5, "", attrs, false); err != nil {
+		t.Fatalf("UpdateContactAttributes returned error: %v", err)
+	}
+	if srv.updates != 2 || srv.lists != 2 {
+		t.Fatalf("expected one rejected write, the definitions and a retry, got %d updates and %d lists", srv.updates, srv.lists)
+	}
+
+	// A later rejection does not check the definitions again
+	srv.existing = map[string]bool{}
+	if err := c.UpdateContactAttributes(5, "", attrs, false); err == nil {
+		t.Fatal("expected the rejected write to fail")
+	}
+	if srv.lists != 2 {
+		t.Fatalf("expected the definitions to be listed once, got %d lists", srv.lists)
+	}
+}
@@ -0,0 +1,44 @@
+package chatwoot
+
+import (
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+// CHATWOOT_FORWARD_EPHEMERAL modes, for chats with disappearing messages turned on.
+const (
+	EphemeralExport = "export" // export the messages like any other
+	EphemeralSkip   = "skip"   // leave the chat out of Chatwoot
+	EphemeralRedact = "redact" // post EphemeralPlaceholder instead of the content and media
+)
+
+// EphemeralPlaceholder is posted for each message of a chat with disappearing messages in redact mode.
+const EphemeralPlaceholder = "(disappearing message)"
+
+// IsValidEphemeralMode reports whether mode is one of the CHATWOOT_FORWARD_EPHEMERAL modes.
+func IsValidEphemeralMode(mode string) bool {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case EphemeralExport, EphemeralSkip, EphemeralRedact:
+		return true
+	}
+	return false
+}
+
+// EphemeralMode returns the configured CHATWOOT_FORWARD_EPHEMERAL mode, falling back to export when
+// it is not a known mode.
+func EphemeralMode() string {
+	if !IsValidEphemeralMode(config.ChatwootForwardEphemeral) {
+		return EphemeralExport
+	}
+	return strings.ToLower(strings.TrimSpace(config.ChatwootForwardEphemeral))
+}
+
+// ChatEphemeralMode returns how the messages of a chat whose disappearing-message timer is
+// expiration seconds reach Chatwoot: EphemeralExport when the timer is off, else EphemeralMode.
+func ChatEphemeralMode(expiration uint32) string {
+	if expiration == 0 {
+		return EphemeralExport
+	}
+	return EphemeralMode()
+}
@@ -0,0 +1,97 @@
+package chatwoot
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"go.mau.fi/whatsmeow"
+)
+
+func useEphemeralMode(t *testing.T, mode string) {
+	t.Helper()
+	prev := config.ChatwootForwardEphemeral
+	config.ChatwootForwardEphemeral = mode
+	t.Cleanup(func() { config.ChatwootForwardEphemeral = prev })
+}
+
+func TestEphemeralMode(t *testing.T) {
+	tests := map[string]string{
+		"export":   EphemeralExport,
+		" Redact ": EphemeralRedact,
+		"skip":     EphemeralSkip,
+		"":         EphemeralExport,
+		"hide":     EphemeralExport,
+	}
+	for configured, want := range tests {
+		useEphemeralMode(t, configured)
+		if got := EphemeralMode(); got != want {
+			t.Errorf("EphemeralMode() with %q = %q, want %q", configured, got, want)
+		}
+		if got := ChatEphemeralMode(0); got != EphemeralExport {
+			t.Errorf("ChatEphemeralMode(0) with %q = %q, want export for chats without a timer", configured, got)
+		}
+	}
+}
+
+func TestSyncHistory_EphemeralChatModes(t *testing.T) {
+	// Chat 00 has disappearing messages; its two messages are chat00-0 and chat00-1
+	tests := map[string][]string{
+		EphemeralExport: {"chat00-0", "chat00-1", "chat01-0", "chat01-1"},
+		EphemeralSkip:   {"chat01-0", "chat01-1"},
+		EphemeralRedact: {EphemeralPlaceholder, EphemeralPlaceholder, "chat01-0", "chat01-1"},
+	}
+	for mode, want := range tests {
+		useEphemeralMode(t, mode)
+		downloads := fakeMediaDownload(t, "image bytes")
+		repo := &syncHistoryRepo{chats: 2, messages: 2, mediaBytes: 11, ephemeral: 604800, exported: make(map[string]int)}
+		client, created := syncHistoryServer(t, 0)
+		opts := DefaultSyncOptions()
+		opts.DelayBetweenBatches = 0
+
+		svc := NewSyncService(client, repo)
+		// The avatar sync a non-nil WhatsApp client starts after each chat must not reach WhatsApp
+		svc.syncAvatar = func(context.Context, string, string, *whatsmeow.Client, bool) error { return nil }
+		progress, err := svc.SyncHistory(context.Background(), "6280000000000@s.whatsapp.net", &whatsmeow.Client{}, opts)
+		if err != nil {
+			t.Fatalf("%s: SyncHistory: %v", mode, err)
+		}
+		got := progress.Clone()
+		if got.EphemeralMode != mode || got.FailedMessages != 0 {
+			t.Errorf("%s: progress reports mode %q with %d failed messages", mode, got.EphemeralMode, got.FailedMessages)
+		}
+
+		var contents []string
+		for _, content := range created() {
+			contents = append(contents, content[strings.Index(content, "] ")+2:])
+		}
+		slices.Sort(contents)
+		slices.Sort(want)
+		if !slices.Equal(contents, want) {
+			t.Errorf("%s: exported %q, want %q", mode, contents, want)
+		}
+		// Only the image of chat 01 is downloaded unless chat 00 is exported as is
+		if wantDownloads := map[bool]int{true: 2, false: 1}[mode == EphemeralExport]; *downloads != wantDownloads {
+			t.Errorf("%s: %d media downloads, want %d", mode, *downloads, wantDownloads)
+		}
+	}
+}
+
+func TestSyncHistory_DryRunLeavesOutRedactedMedia(t *testing.T) {
+	useEphemeralMode(t, EphemeralRedact)
+	repo := &syncHistoryRepo{chats: 2, messages: 4, mediaBytes: 1000, ephemeral: 86400, exported: make(map[string]int)}
+	client, _ := syncHistoryServer(t, 0)
+	opts := DefaultSyncOptions()
+	opts.DryRun = true
+
+	progress, err := NewSyncService(client, repo).SyncHistory(context.Background(), "6280000000000@s.whatsapp.net", nil, opts)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	// Only chat 01 has media to download: 2 of its 4 messages
+	if est := progress.Clone().Estimate; est == nil || est.Messages != 8 || est.MediaMessages != 2 || est.MediaBytes != 2000 {
+		t.Fatalf("unexpected estimate %+v", est)
+	}
+}
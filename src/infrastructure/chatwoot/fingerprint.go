@@ -0,0 +1,152 @@
+package chatwoot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+)
+
+// Fingerprint identifies the Chatwoot account and inboxes the stored Chatwoot IDs (exported messages,
+// pending surveys, cached conversations) belong to. It changes when CHATWOOT_URL, CHATWOOT_ACCOUNT_ID,
+// CHATWOOT_INBOX_ID or CHATWOOT_INBOX_DEVICE_MAP point somewhere else.
+func Fingerprint() string {
+	inboxMap := configuredInboxDeviceMap()
+	entries := make([]string, 0, len(inboxMap))
+	for inboxID, deviceID := range inboxMap {
+		entries = append(entries, fmt.Sprintf("%d:%s", inboxID, deviceID))
+	}
+	sort.Strings(entries)
+
+	cfg := CurrentConfig()
+	url := strings.ToLower(strings.TrimRight(strings.TrimSpace(cfg.URL), "/"))
+	return fmt.Sprintf("%s|account=%d|inbox=%d|inbox_map=%s",
+		url, cfg.AccountID, cfg.InboxID, strings.Join(entries, ","))
+}
+
+// CheckFingerprint compares the configured Chatwoot account/inbox with the one the stored IDs were
+// recorded for. When they differ the stored IDs point at conversations and messages that no longer
+// exist, so they are dropped and the bridge starts over. It returns whether anything was invalidated.
+func CheckFingerprint(repo domainChatStorage.IChatStorageRepository) (bool, error) {
+	if cfg := CurrentConfig(); !config.ChatwootEnabled || cfg.AccountID == 0 || cfg.InboxID == 0 {
+		// Keep the stored IDs while Chatwoot is switched off or half configured.
+		return false, nil
+	}
+
+	current := Fingerprint()
+	stored, err := repo.GetChatwootFingerprint()
+	if err != nil {
+		return false, fmt.Errorf("failed to load Chatwoot fingerprint: %w", err)
+	}
+	if stored == current {
+		return false, nil
+	}
+	if stored == "" {
+		// First start with this feature: the stored IDs are assumed to match the configuration.
+		return false, repo.SaveChatwootFingerprint(current)
+	}
+
+	removed, err := repo.ClearChatwootState()
+	if err != nil {
+		return false, fmt.Errorf("failed to clear Chatwoot state: %w", err)
+	}
+	ResetConversationDestinations()
+	if err := repo.SaveChatwootFingerprint(current); err != nil {
+		return true, fmt.Errorf("failed to save Chatwoot fingerprint: %w", err)
+	}
+
+	logrus.Warnf("Chatwoot: account/inbox configuration changed (%s -> %s); dropped %d exported message IDs, export progress and pending surveys. "+
+		"Call POST /chatwoot/cache/rebuild to re-resolve recent conversations and POST /chatwoot/sync to re-import history.", stored, current, removed)
+	return true, nil
+}
+
+// ConversationRebuildResult counts the chats RebuildConversations went through.
+type ConversationRebuildResult struct {
+	DeviceID string   `json:"device_id"`
+	Chats    int      `json:"chats"`
+	Resolved int      `json:"resolved"`
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// RebuildConversations re-resolves the Chatwoot contact and conversation of the limit most recently
+// active chats of a device, creating them in the configured inbox when missing, and caches each
+// conversation's destination so agent replies are routed without further lookups.
+func (s *SyncService) RebuildConversations(ctx context.Context, deviceID string, waClient *whatsmeow.Client, limit int) (*ConversationRebuildResult, error) {
+	chats, err := s.chatStorageRepo.GetChats(&domainChatStorage.ChatFilter{
+		DeviceID: deviceID,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chats: %w", err)
+	}
+
+	cw := s.clientFor(waClient)
+	result := &ConversationRebuildResult{DeviceID: deviceID}
+	for _, chat := range chats {
+		if chat == nil || isStatusBroadcastChatJID(chat.JID) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.Chats++
+
+		conversationID, err := s.resolveConversation(chat, waClient)
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", chat.JID, err))
+			continue
+		}
+		result.Resolved++
+		if destination := chatDestination(chat.JID); destination != "" {
+			cw.RememberConversationDestination(conversationID, destination)
+		}
+	}
+
+	logrus.Infof("Chatwoot: Rebuilt conversations for device %s: %d chats, %d resolved, %d failed",
+		deviceID, result.Chats, result.Resolved, result.Failed)
+	return result, nil
+}
+
+func (s *SyncService) resolveConversation(chat *domainChatStorage.Chat, waClient *whatsmeow.Client) (int, error) {
+	isGroup := strings.HasSuffix(chat.JID, "@g.us")
+	contactName := chat.Name
+	if isGroup {
+		if name := resolveGroupName(waClient, chat.JID); name != "" {
+			contactName = name
+		}
+	}
+	if contactName == "" {
+		contactName = fallbackContactName(chat.JID)
+	}
+
+	contact, err := s.chatContact(context.Background(), contactName, chat.JID, isGroup, waClient)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find/create contact: %w", err)
+	}
+	conversation, err := s.clientFor(waClient).FindOrCreateConversation(contact.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find/create conversation: %w", err)
+	}
+	return conversation.ID, nil
+}
+
+// chatDestination is what the webhook sends agent replies to: the group JID or the phone number.
+// LID chats have no phone number to send to, so they are left to the contact attributes.
+func chatDestination(chatJID string) string {
+	switch {
+	case strings.HasSuffix(chatJID, "@g.us"):
+		return chatJID
+	case strings.HasSuffix(chatJID, "@s.whatsapp.net"):
+		return utils.ExtractPhoneFromJID(chatJID)
+	default:
+		return ""
+	}
+}
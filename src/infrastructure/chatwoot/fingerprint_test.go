@@ -0,0 +1,101 @@
+package chatwoot
+
+import (
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+type memoryFingerprintRepo struct {
+	domainChatStorage.IChatStorageRepository
+	fingerprint string
+	clears      int
+}
+
+func (m *memoryFingerprintRepo) GetChatwootFingerprint() (string, error) {
+	return m.fingerprint, nil
+}
+
+func (m *memoryFingerprintRepo) SaveChatwootFingerprint(fingerprint string) error {
+	m.fingerprint = fingerprint
+	return nil
+}
+
+func (m *memoryFingerprintRepo) ClearChatwootState() (int64, error) {
+	m.clears++
+	return 3, nil
+}
+
+func useChatwootConfig(t *testing.T, url string, accountID, inboxID int, inboxMap []string) {
+	t.Helper()
+	prevEnabled, prevURL, prevAccount, prevInbox, prevMap := config.ChatwootEnabled, config.ChatwootURL, config.ChatwootAccountID, config.ChatwootInboxID, config.ChatwootInboxDeviceMap
+	config.ChatwootEnabled, config.ChatwootURL, config.ChatwootAccountID, config.ChatwootInboxID, config.ChatwootInboxDeviceMap = true, url, accountID, inboxID, inboxMap
+	t.Cleanup(func() {
+		config.ChatwootEnabled, config.ChatwootURL, config.ChatwootAccountID, config.ChatwootInboxID, config.ChatwootInboxDeviceMap = prevEnabled, prevURL, prevAccount, prevInbox, prevMap
+	})
+}
+
+func TestFingerprint_IgnoresCosmeticDifferences(t *testing.T) {
+	useChatwootConfig(t, "https://chat.example.com/", 1, 2, []string{"34:support", "12:sales"})
+	a := Fingerprint()
+
+	useChatwootConfig(t, "HTTPS://chat.example.com", 1, 2, []string{"12:sales", " 34:support"})
+	if b := Fingerprint(); a != b {
+		t.Errorf("expected equal fingerprints, got %q and %q", a, b)
+	}
+
+	useChatwootConfig(t, "https://chat.example.com", 1, 3, []string{"12:sales", "34:support"})
+	if c := Fingerprint(); a == c {
+		t.Errorf("expected the inbox change to alter the fingerprint %q", a)
+	}
+}
+
+func TestCheckFingerprint(t *testing.T) {
+	useChatwootConfig(t, "https://chat.example.com", 1, 2, nil)
+	repo := &memoryFingerprintRepo{}
+
+	// The first start records the fingerprint without dropping anything.
+	if changed, err := CheckFingerprint(repo); err != nil || changed {
+		t.Fatalf("first check: changed=%v err=%v", changed, err)
+	}
+	if repo.fingerprint != Fingerprint() || repo.clears != 0 {
+		t.Fatalf("expected the fingerprint saved and nothing cleared, got %q (%d clears)", repo.fingerprint, repo.clears)
+	}
+
+	if changed, err := CheckFingerprint(repo); err != nil || changed {
+		t.Fatalf("unchanged check: changed=%v err=%v", changed, err)
+	}
+
+	cw := NewClient()
+	cw.RememberConversationDestination(42, "628123")
+	useChatwootConfig(t, "https://chat.example.com", 1, 9, nil)
+	if changed, err := CheckFingerprint(repo); err != nil || !changed {
+		t.Fatalf("inbox change: changed=%v err=%v", changed, err)
+	}
+	if repo.clears != 1 || repo.fingerprint != Fingerprint() {
+		t.Errorf("expected one clear and the new fingerprint, got %d clears and %q", repo.clears, repo.fingerprint)
+	}
+	if _, ok := cw.ConversationDestination(42); ok {
+		t.Error("cached conversation destination of the old inbox survived")
+	}
+
+	// A disabled or half configured Chatwoot keeps the stored IDs.
+	config.ChatwootInboxID = 0
+	if changed, _ := CheckFingerprint(repo); changed || repo.clears != 1 {
+		t.Errorf("expected no clear without an inbox, got changed=%v clears=%d", changed, repo.clears)
+	}
+}
+
+func TestChatDestination(t *testing.T) {
+	tests := map[string]string{
+		"628123@s.whatsapp.net": "628123",
+		"120363@g.us":           "120363@g.us",
+		"99887766@lid":          "",
+	}
+	for jid, want := range tests {
+		if got := chatDestination(jid); got != want {
+			t.Errorf("chatDestination(%q) = %q, want %q", jid, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package chatwoot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+// webhookProbeClient sends the probe of ProbeWebhook; it is not rate limited like the API client.
+var webhookProbeClient = &http.Client{Timeout: 10 * time.Second}
+
+// Ping checks that the API token can read the configured account.
+func (c *Client) Ping() error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d", c.BaseURL, c.AccountID)
+	if _, err := c.doRequest("GET", endpoint, nil, nil); err != nil {
+		return fmt.Errorf("failed to read account %d: %w", c.AccountID, err)
+	}
+	return nil
+}
+
+// GetInbox returns the inbox with the given ID.
+func (c *Client) GetInbox(inboxID int) (*Inbox, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/inboxes/%d", c.BaseURL, c.AccountID, inboxID)
+
+	var inbox Inbox
+	if _, err := c.doRequest("GET", endpoint, nil, &inbox); err != nil {
+		return nil, fmt.Errorf("failed to get inbox %d: %w", inboxID, err)
+	}
+	if inbox.ID == 0 {
+		return nil, fmt.Errorf("inbox %d not found", inboxID)
+	}
+	return &inbox, nil
+}
+
+// ProbeWebhook posts an event the webhook ignores to webhookURL, the way Chatwoot would: signed with
+// CHATWOOT_WEBHOOK_SECRET when set. It fails unless the webhook answers 200, so a wrong public URL, a
+// proxy in the way or a token mismatch all show up.
+func ProbeWebhook(webhookURL string) error {
+	body := []byte(`{"event":"health_check"}`)
+	req, err := http.NewRequest("POST", webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := strings.TrimSpace(config.ChatwootWebhookSecret); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Chatwoot-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookProbeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return responseError("webhook probe failed", resp, respBody)
+	}
+	return nil
+}
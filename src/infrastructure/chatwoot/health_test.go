@@ -0,0 +1,75 @@
+package chatwoot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func TestPingAndGetInbox(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api_access_token") != "t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/accounts/1":
+			_, _ = w.Write([]byte(`{"id":1,"name":"Acme"}`))
+		case "/api/v1/accounts/1/inboxes/4":
+			_, _ = w.Write([]byte(`{"id":4,"name":"WhatsApp","channel_type":"Channel::Api"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"Resource could not be found"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 4, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Ping returned error: %v", err)
+	}
+	if inbox, err := c.GetInbox(4); err != nil || inbox.Name != "WhatsApp" {
+		t.Fatalf("expected inbox 4, got %+v (err %v)", inbox, err)
+	}
+	if _, err := c.GetInbox(5); err == nil {
+		t.Fatal("expected an error for a missing inbox")
+	}
+
+	c.APIToken = "wrong"
+	if err := c.Ping(); err == nil {
+		t.Fatal("expected Ping to fail with a rejected token")
+	}
+}
+
+func TestProbeWebhook_SignsWithSecret(t *testing.T) {
+	origSecret := config.ChatwootWebhookSecret
+	t.Cleanup(func() { config.ChatwootWebhookSecret = origSecret })
+	config.ChatwootWebhookSecret = "hush"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("hush"))
+		mac.Write(body)
+		if r.Header.Get("X-Chatwoot-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) || r.URL.Query().Get("token") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	if err := ProbeWebhook(srv.URL + "/chatwoot/webhook?token=s3cret"); err != nil {
+		t.Fatalf("ProbeWebhook returned error: %v", err)
+	}
+	if err := ProbeWebhook(srv.URL + "/chatwoot/webhook?token=other"); err == nil {
+		t.Fatal("expected a rejected probe to fail")
+	}
+}
@@ -0,0 +1,65 @@
+package chatwoot
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/sirupsen/logrus"
+)
+
+// ParseInboxDeviceMap turns "inboxID:deviceID" entries into a lookup table.
+// Malformed entries are skipped with a warning so one typo does not disable routing.
+func ParseInboxDeviceMap(entries []string) map[int]string {
+	result := make(map[int]string, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		rawInbox, deviceID, ok := strings.Cut(entry, ":")
+		inboxID, err := strconv.Atoi(strings.TrimSpace(rawInbox))
+		deviceID = strings.TrimSpace(deviceID)
+		if !ok || err != nil || inboxID <= 0 || deviceID == "" {
+			logrus.Warnf("Chatwoot: ignoring invalid inbox device mapping %q (expected inboxID:deviceID)", entry)
+			continue
+		}
+		result[inboxID] = deviceID
+	}
+	return result
+}
+
+// inboxDeviceMap caches CHATWOOT_INBOX_DEVICE_MAP parsed, so webhooks do not parse it and warn about
+// its malformed entries on every event. It is parsed again when the setting changes.
+var inboxDeviceMap struct {
+	mu     sync.Mutex
+	source string
+	parsed map[int]string
+}
+
+// configuredInboxDeviceMap returns CHATWOOT_INBOX_DEVICE_MAP parsed.
+func configuredInboxDeviceMap() map[int]string {
+	source := strings.Join(config.ChatwootInboxDeviceMap, ",")
+
+	inboxDeviceMap.mu.Lock()
+	defer inboxDeviceMap.mu.Unlock()
+	if inboxDeviceMap.parsed == nil || inboxDeviceMap.source != source {
+		inboxDeviceMap.parsed = ParseInboxDeviceMap(config.ChatwootInboxDeviceMap)
+		inboxDeviceMap.source = source
+	}
+	return inboxDeviceMap.parsed
+}
+
+// DeviceForInbox returns the device configured for a Chatwoot inbox in CHATWOOT_INBOX_DEVICE_MAP,
+// else the device the inbox was provisioned for by POST /chatwoot/setup.
+func DeviceForInbox(inboxID int) (string, bool) {
+	if inboxID <= 0 {
+		return "", false
+	}
+	if deviceID, ok := configuredInboxDeviceMap()[inboxID]; ok {
+		return deviceID, true
+	}
+	return provisionedInboxDevice(inboxID)
+}
@@ -0,0 +1,50 @@
+package chatwoot
+
+import (
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func TestParseInboxDeviceMap(t *testing.T) {
+	got := ParseInboxDeviceMap([]string{" 12:sales ", "34:628123@s.whatsapp.net", "bad", "0:zero", "56:", ""})
+
+	want := map[int]string{
+		12: "sales",
+		34: "628123@s.whatsapp.net",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ParseInboxDeviceMap returned %v want=%v", got, want)
+	}
+	for inbox, device := range want {
+		if got[inbox] != device {
+			t.Fatalf("inbox %d mapped to %q want=%q", inbox, got[inbox], device)
+		}
+	}
+}
+
+func TestDeviceForInbox(t *testing.T) {
+	original := config.ChatwootInboxDeviceMap
+	t.Cleanup(func() { config.ChatwootInboxDeviceMap = original })
+
+	config.ChatwootInboxDeviceMap = []string{"12:sales"}
+
+	if device, ok := DeviceForInbox(12); !ok || device != "sales" {
+		t.Fatalf("DeviceForInbox(12)=%q,%v want=sales,true", device, ok)
+	}
+	if _, ok := DeviceForInbox(99); ok {
+		t.Fatal("expected unmapped inbox to report false")
+	}
+	if _, ok := DeviceForInbox(0); ok {
+		t.Fatal("expected missing inbox id to report false")
+	}
+
+	// A changed mapping is parsed again
+	config.ChatwootInboxDeviceMap = []string{"13:support"}
+	if _, ok := DeviceForInbox(12); ok {
+		t.Fatal("expected inbox 12 to be unmapped after the mapping changed")
+	}
+	if device, ok := DeviceForInbox(13); !ok || device != "support" {
+		t.Fatalf("DeviceForInbox(13)=%q,%v want=support,true", device, ok)
+	}
+}
@@ -0,0 +1,322 @@
+package chatwoot
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+// Inbox is a Chatwoot inbox as listed by the account API.
+type Inbox struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	ChannelType string `json:"channel_type"`
+	WebhookURL  string `json:"webhook_url"`
+}
+
+// InboxSetupRequest names the device POST /chatwoot/setup provisions an inbox for. Name defaults to
+// "WhatsApp <device ID>".
+type InboxSetupRequest struct {
+	DeviceID string `json:"device_id"`
+	Name     string `json:"name"`
+}
+
+// InboxSetupResult reports what SetupInbox found and changed.
+type InboxSetupResult struct {
+	DeviceID       string   `json:"device_id"`
+	InboxID        int      `json:"inbox_id"`
+	InboxName      string   `json:"inbox_name"`
+	WebhookURL     string   `json:"webhook_url"`
+	Created        bool     `json:"created"`
+	WebhookUpdated bool     `json:"webhook_updated"`
+	Actions        []string `json:"actions"`
+}
+
+// inboxSetupMu keeps concurrent setups from creating the same inbox twice.
+var inboxSetupMu sync.Mutex
+
+// provisionedInboxes holds the inboxes made by SetupInbox and their devices. DeviceForInbox consults
+// it after CHATWOOT_INBOX_DEVICE_MAP, and ClientForDevice posts the forwards of a device to its inbox.
+var provisionedInboxes = struct {
+	mu      sync.RWMutex
+	devices map[int]string
+	inboxes map[string]int
+}{devices: make(map[int]string), inboxes: make(map[string]int)}
+
+func registerProvisionedInbox(inboxID int, deviceID string) {
+	provisionedInboxes.mu.Lock()
+	defer provisionedInboxes.mu.Unlock()
+	if previous, ok := provisionedInboxes.inboxes[deviceID]; ok && previous != inboxID {
+		delete(provisionedInboxes.devices, previous)
+	}
+	provisionedInboxes.devices[inboxID] = deviceID
+	provisionedInboxes.inboxes[deviceID] = inboxID
+}
+
+func provisionedInboxDevice(inboxID int) (string, bool) {
+	provisionedInboxes.mu.RLock()
+	defer provisionedInboxes.mu.RUnlock()
+	deviceID, ok := provisionedInboxes.devices[inboxID]
+	return deviceID, ok
+}
+
+// provisionedInboxForDevice returns the inbox provisioned for a device. Each ID is tried in turn, a
+// JID also through the alias of its device, as in ClientRegistry.TargetForDevice.
+func provisionedInboxForDevice(deviceIDs ...string) (int, bool) {
+	provisionedInboxes.mu.RLock()
+	defer provisionedInboxes.mu.RUnlock()
+	if len(provisionedInboxes.inboxes) == 0 {
+		return 0, false
+	}
+	for _, deviceID := range deviceIDs {
+		deviceID = strings.TrimSpace(deviceID)
+		if deviceID == "" {
+			continue
+		}
+		if inboxID, ok := provisionedInboxes.inboxes[deviceID]; ok {
+			return inboxID, true
+		}
+		if alias := resolveDeviceAlias(deviceID); alias != "" && alias != deviceID {
+			if inboxID, ok := provisionedInboxes.inboxes[alias]; ok {
+				return inboxID, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// adoptInbox makes inboxID the inbox of the Chatwoot config in use when it has none, publishing a new
+// config snapshot and default client. It reports whether the inbox was adopted.
+func adoptInbox(inboxID int) bool {
+	configMu.Lock()
+	defer configMu.Unlock()
+	cfg := CurrentConfig()
+	if cfg.InboxID != 0 {
+		return false
+	}
+	cfg.InboxID = inboxID
+	applyConfig(cfg)
+	return true
+}
+
+// IsAccountConfigured reports whether the client can call the account API, which inbox setup needs
+// before an inbox is known.
+func (c *Client) IsAccountConfigured() bool {
+	return c.BaseURL != "" && c.APIToken != "" && c.AccountID != 0
+}
+
+// ListInboxes returns the inboxes of the account.
+func (c *Client) ListInboxes() ([]Inbox, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/inboxes", c.BaseURL, c.AccountID)
+
+	var result struct {
+		Payload []Inbox `json:"payload"`
+	}
+	if _, err := c.doRequest("GET", endpoint, nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to list inboxes: %w", err)
+	}
+	return result.Payload, nil
+}
+
+// CreateInbox creates an API channel inbox whose events are posted to webhookURL.
+func (c *Client) CreateInbox(name, webhookURL string) (*Inbox, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/inboxes", c.BaseURL, c.AccountID)
+	payload := map[string]interface{}{
+		"name": name,
+		"channel": map[string]string{
+			"type":        "api",
+			"webhook_url": webhookURL,
+		},
+	}
+
+	var inbox Inbox
+	if _, err := c.doRequest("POST", endpoint, payload, &inbox); err != nil {
+		return nil, fmt.Errorf("failed to create inbox: %w", err)
+	}
+	if inbox.ID == 0 {
+		return nil, fmt.Errorf("failed to create inbox: response has no inbox ID")
+	}
+	return &inbox, nil
+}
+
+// UpdateInboxWebhook points the API channel of an inbox at webhookURL.
+func (c *Client) UpdateInboxWebhook(inboxID int, webhookURL string) error {
+	endpoint := fmt.Sprintf("%s/api/v1/accounts/%d/inboxes/%d", c.BaseURL, c.AccountID, inboxID)
+	payload := map[string]interface{}{
+		"channel": map[string]string{"webhook_url": webhookURL},
+	}
+	if _, err := c.doRequest("PATCH", endpoint, payload, nil); err != nil {
+		return fmt.Errorf("failed to update inbox webhook: %w", err)
+	}
+	return nil
+}
+
+// InboxWebhookURL returns the URL Chatwoot should post webhooks to: /chatwoot/webhook under
+// CHATWOOT_PUBLIC_URL, or CHATWOOT_MEDIA_LINK_BASE_URL when unset, carrying CHATWOOT_WEBHOOK_TOKEN
+// as the token query parameter when set.
+func InboxWebhookURL() (string, error) {
+	return publicWebhookURL("/chatwoot/webhook")
+}
+
+// publicWebhookURL returns the URL of the webhook route path of this server as Chatwoot reaches it.
+func publicWebhookURL(path string) (string, error) {
+	base := strings.TrimSpace(config.ChatwootPublicURL)
+	if base == "" {
+		base = strings.TrimSpace(config.ChatwootMediaLinkBaseURL)
+	}
+	if base == "" {
+		return "", fmt.Errorf("CHATWOOT_PUBLIC_URL is not set")
+	}
+	parsed, err := url.Parse(base)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", fmt.Errorf("CHATWOOT_PUBLIC_URL %q is not an http(s) URL", base)
+	}
+
+	webhookURL := strings.TrimRight(base, "/") + config.AppBasePath + path
+	if config.ChatwootWebhookToken != "" {
+		webhookURL += "?token=" + url.QueryEscape(config.ChatwootWebhookToken)
+	}
+	return webhookURL, nil
+}
+
+// SetupInbox provisions the API channel inbox of a device, idempotently: the inbox stored for the
+// device is kept while it exists, else an inbox with the requested name is reused, else one is
+// created. Its webhook is pointed at this server and the inbox is stored as the device's, so the
+// device's forwards go to it and replies in it go out from the device. The config in use takes the
+// inbox when it had none.
+func (c *Client) SetupInbox(repo domainChatStorage.IChatStorageRepository, req InboxSetupRequest) (*InboxSetupResult, error) {
+	req.DeviceID = strings.TrimSpace(req.DeviceID)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.DeviceID == "" {
+		return nil, fmt.Errorf("device ID is required")
+	}
+	if req.Name == "" {
+		req.Name = "WhatsApp " + req.DeviceID
+	}
+	webhookURL, err := InboxWebhookURL()
+	if err != nil {
+		return nil, err
+	}
+
+	inboxSetupMu.Lock()
+	defer inboxSetupMu.Unlock()
+
+	inboxes, err := c.ListInboxes()
+	if err != nil {
+		return nil, err
+	}
+	result := &InboxSetupResult{DeviceID: req.DeviceID, WebhookURL: webhookURL}
+
+	inbox := c.storedInbox(repo, req.DeviceID, inboxes)
+	if inbox != nil {
+		result.Actions = append(result.Actions, fmt.Sprintf("found inbox %d stored for device %s", inbox.ID, req.DeviceID))
+	} else {
+		for i := range inboxes {
+			if strings.EqualFold(strings.TrimSpace(inboxes[i].Name), req.Name) {
+				inbox = &inboxes[i]
+				result.Actions = append(result.Actions, fmt.Sprintf("found inbox %d named %q", inbox.ID, inbox.Name))
+				break
+			}
+		}
+	}
+	if inbox == nil {
+		inbox, err = c.CreateInbox(req.Name, webhookURL)
+		if err != nil {
+			return nil, err
+		}
+		inbox.WebhookURL = webhookURL
+		result.Created = true
+		result.Actions = append(result.Actions, fmt.Sprintf("created inbox %d named %q", inbox.ID, req.Name))
+	}
+
+	if inbox.WebhookURL != webhookURL {
+		if err := c.UpdateInboxWebhook(inbox.ID, webhookURL); err != nil {
+			return nil, err
+		}
+		result.WebhookUpdated = true
+		result.Actions = append(result.Actions, fmt.Sprintf("pointed the webhook of inbox %d at this server", inbox.ID))
+	}
+	result.InboxID = inbox.ID
+	result.InboxName = inbox.Name
+	if result.InboxName == "" {
+		result.InboxName = req.Name
+	}
+
+	if repo != nil {
+		if err := repo.SaveChatwootInbox(&domainChatStorage.ChatwootInbox{DeviceID: req.DeviceID, InboxID: inbox.ID, Name: result.InboxName}); err != nil {
+			return nil, fmt.Errorf("failed to store inbox %d of device %s: %w", inbox.ID, req.DeviceID, err)
+		}
+	}
+	registerProvisionedInbox(inbox.ID, req.DeviceID)
+	result.Actions = append(result.Actions, fmt.Sprintf("mapped inbox %d to device %s", inbox.ID, req.DeviceID))
+
+	if created, err := c.EnsureCustomAttributeDefinitions(); err != nil {
+		logrus.Warnf("Chatwoot: Failed to create custom attribute definitions: %v", err)
+		result.Actions = append(result.Actions, fmt.Sprintf("failed to create custom attribute definitions: %v", err))
+	} else if len(created) > 0 {
+		result.Actions = append(result.Actions, fmt.Sprintf("created custom attribute definitions %s", strings.Join(created, ", ")))
+	}
+
+	if adoptInbox(inbox.ID) {
+		result.Actions = append(result.Actions, fmt.Sprintf("using inbox %d as the Chatwoot inbox", inbox.ID))
+	}
+	logrus.Infof("Chatwoot: Inbox setup for device %s: %s", req.DeviceID, strings.Join(result.Actions, "; "))
+	return result, nil
+}
+
+// storedInbox returns the inbox stored for deviceID when it is still among inboxes.
+func (c *Client) storedInbox(repo domainChatStorage.IChatStorageRepository, deviceID string, inboxes []Inbox) *Inbox {
+	if repo == nil {
+		return nil
+	}
+	stored, err := repo.ListChatwootInboxes()
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to read stored inboxes: %v", err)
+		return nil
+	}
+	for _, s := range stored {
+		if s.DeviceID != deviceID {
+			continue
+		}
+		for i := range inboxes {
+			if inboxes[i].ID == s.InboxID {
+				return &inboxes[i]
+			}
+		}
+		logrus.Warnf("Chatwoot: Inbox %d stored for device %s no longer exists", s.InboxID, deviceID)
+	}
+	return nil
+}
+
+// ApplyStoredInboxes maps the inboxes provisioned earlier to their devices. When the config in use
+// has no inbox, the inbox of CHATWOOT_DEVICE_ID, or the only one stored, becomes the Chatwoot inbox.
+// It runs at startup, after the stored config is loaded.
+func ApplyStoredInboxes(repo domainChatStorage.IChatStorageRepository) {
+	if repo == nil {
+		return
+	}
+	inboxes, err := repo.ListChatwootInboxes()
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to read stored inboxes: %v", err)
+		return
+	}
+	for _, inbox := range inboxes {
+		registerProvisionedInbox(inbox.InboxID, inbox.DeviceID)
+	}
+	if CurrentConfig().InboxID != 0 || len(inboxes) == 0 {
+		return
+	}
+	for _, inbox := range inboxes {
+		if inbox.DeviceID == config.ChatwootDeviceID || len(inboxes) == 1 {
+			if adoptInbox(inbox.InboxID) {
+				logrus.Infof("Chatwoot: Using inbox %d provisioned for device %s", inbox.InboxID, inbox.DeviceID)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+// inboxServer answers the inbox endpoints of the Chatwoot account API from an in-memory list.
+type inboxServer struct {
+	mu      sync.Mutex
+	inboxes []Inbox
+	created []map[string]interface{}
+	patched map[int]string
+
+	attributeRequests int
+}
+
+func (s *inboxServer) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/accounts/1/inboxes"):
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"payload": s.inboxes})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/accounts/1/inboxes"):
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			s.created = append(s.created, body)
+			channel, _ := body["channel"].(map[string]interface{})
+			webhookURL, _ := channel["webhook_url"].(string)
+			name, _ := body["name"].(string)
+			inbox := Inbox{ID: 40 + len(s.inboxes), Name: name, ChannelType: "Channel::Api", WebhookURL: webhookURL}
+			s.inboxes = append(s.inboxes, inbox)
+			_ = json.NewEncoder(w).Encode(inbox)
+		case r.Method == http.MethodPatch && strings.Contains(r.URL.Path, "/accounts/1/inboxes/"):
+			id, _ := strconv.Atoi(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+			var body struct {
+				Channel struct {
+					WebhookURL string `json:"webhook_url"`
+				} `json:"channel"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			s.patched[id] = body.Channel.WebhookURL
+			for i := range s.inboxes {
+				if s.inboxes[i].ID == id {
+					s.inboxes[i].WebhookURL = body.Channel.WebhookURL
+				}
+			}
+			_, _ = w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, "/custom_attribute_definitions"):
+			s.attributeRequests++
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+		}
+	})
+}
+
+type inboxRepo struct {
+	domainChatStorage.IChatStorageRepository
+	inboxes map[string]*domainChatStorage.ChatwootInbox
+}
+
+func (r *inboxRepo) SaveChatwootInbox(inbox *domainChatStorage.ChatwootInbox) error {
+	r.inboxes[inbox.DeviceID] = inbox
+	return nil
+}
+
+func (r *inboxRepo) ListChatwootInboxes() ([]*domainChatStorage.ChatwootInbox, error) {
+	var inboxes []*domainChatStorage.ChatwootInbox
+	for _, inbox := range r.inboxes {
+		inboxes = append(inboxes, inbox)
+	}
+	return inboxes, nil
+}
+
+func newInboxTestClient(t *testing.T, inboxes ...Inbox) (*Client, *inboxServer) {
+	t.Helper()
+	s := &inboxServer{inboxes: inboxes, patched: map[int]string{}}
+	srv := httptest.NewServer(s.handler(t))
+	t.Cleanup(srv.Close)
+
+	keepChatwootConfig(t)
+	origPublicURL, origBasePath, origToken := config.ChatwootPublicURL, config.AppBasePath, config.ChatwootWebhookToken
+	t.Cleanup(func() {
+		config.ChatwootPublicURL, config.AppBasePath, config.ChatwootWebhookToken = origPublicURL, origBasePath, origToken
+		provisionedInboxes.mu.Lock()
+		provisionedInboxes.devices = make(map[int]string)
+		provisionedInboxes.inboxes = make(map[string]int)
+		provisionedInboxes.mu.Unlock()
+	})
+	config.ChatwootPublicURL = "https://wa.example.com/"
+	config.AppBasePath = "/wa"
+	config.ChatwootWebhookToken = "s3cret"
+	applyConfig(domainChatStorage.ChatwootConfig{URL: srv.URL, APIToken: "t", AccountID: 1})
+
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}, s
+}
+
+func TestListInboxesAndCreateInbox(t *testing.T) {
+	c, srv := newInboxTestClient(t, Inbox{ID: 3, Name: "Website", ChannelType: "Channel::WebWidget"})
+
+	created, err := c.CreateInbox("WhatsApp sales", "https://wa.example.com/chatwoot/webhook")
+	if err != nil {
+		t.Fatalf("CreateInbox returned error: %v", err)
+	}
+	channel, _ := srv.created[0]["channel"].(map[string]interface{})
+	if channel["type"] != "api" || channel["webhook_url"] != "https://wa.example.com/chatwoot/webhook" {
+		t.Fatalf("expected an API channel posting to the webhook, got %v", srv.created[0])
+	}
+
+	inboxes, err := c.ListInboxes()
+	if err != nil {
+		t.Fatalf("ListInboxes returned error: %v", err)
+	}
+	if len(inboxes) != 2 || inboxes[0].ID != 3 || inboxes[1].ID != created.ID || inboxes[1].Name != "WhatsApp sales" {
+		t.Fatalf("expected both inboxes, got %+v", inboxes)
+	}
+}
+
+func TestSetupInbox_IsIdempotent(t *testing.T) {
+	c, srv := newInboxTestClient(t)
+	repo := &inboxRepo{inboxes: map[string]*domainChatStorage.ChatwootInbox{}}
+
+	first, err := c.SetupInbox(repo, InboxSetupRequest{DeviceID: "sales"})
+	if err != nil {
+		t.Fatalf("SetupInbox returned error: %v", err)
+	}
+	wantURL := "https://wa.example.com/wa/chatwoot/webhook?token=s3cret"
+	if !first.Created || first.WebhookURL != wantURL || first.InboxName != "WhatsApp sales" {
+		t.Fatalf("expected a new inbox posting to %s, got %+v", wantURL, first)
+	}
+	if repo.inboxes["sales"] == nil || repo.inboxes["sales"].InboxID != first.InboxID {
+		t.Fatalf("expected the inbox to be stored for the device, got %+v", repo.inboxes)
+	}
+	if deviceID, ok := DeviceForInbox(first.InboxID); !ok || deviceID != "sales" {
+		t.Fatalf("expected inbox %d to route to the device, got %q", first.InboxID, deviceID)
+	}
+	if got := CurrentConfig().InboxID; got != first.InboxID || GetDefaultClient().InboxID != first.InboxID {
+		t.Fatalf("expected the config in use to take inbox %d, got %d", first.InboxID, got)
+	}
+
+	second, err := c.SetupInbox(repo, InboxSetupRequest{DeviceID: "sales"})
+	if err != nil {
+		t.Fatalf("second SetupInbox returned error: %v", err)
+	}
+	if second.Created || second.WebhookUpdated || second.InboxID != first.InboxID {
+		t.Fatalf("expected the stored inbox to be reused unchanged, got %+v", second)
+	}
+	if len(srv.created) != 1 {
+		t.Fatalf("expected one inbox to be created, got %d", len(srv.created))
+	}
+	// One list and the creations per attribute model
+	if srv.attributeRequests != 2+len(contactAttributeDefinitions)+len(conversationAttributeDefinitions) {
+		t.Fatalf("expected the attribute definitions to be created once, got %d requests", srv.attributeRequests)
+	}
+}
+
+func TestSetupInbox_ReusesInboxByNameAndFixesWebhook(t *testing.T) {
+	c, srv := newInboxTestClient(t, Inbox{ID: 12, Name: "Support line", ChannelType: "Channel::Api", WebhookURL: "https://old.example.com/chatwoot/webhook"})
+	applyConfig(domainChatStorage.ChatwootConfig{URL: c.BaseURL, APIToken: "t", AccountID: 1, InboxID: 7})
+	repo := &inboxRepo{inboxes: map[string]*domainChatStorage.ChatwootInbox{}}
+
+	result, err := c.SetupInbox(repo, InboxSetupRequest{DeviceID: "support", Name: "support line"})
+	if err != nil {
+		t.Fatalf("SetupInbox returned error: %v", err)
+	}
+	if result.Created || !result.WebhookUpdated || result.InboxID != 12 {
+		t.Fatalf("expected inbox 12 to be reused with a new webhook, got %+v", result)
+	}
+	if srv.patched[12] != "https://wa.example.com/wa/chatwoot/webhook?token=s3cret" {
+		t.Fatalf("expected the webhook of inbox 12 to be updated, got %v", srv.patched)
+	}
+	if got := CurrentConfig().InboxID; got != 7 {
+		t.Fatalf("expected the configured inbox to be kept, got %d", got)
+	}
+
+	// The device's forwards go to its inbox; other devices keep the configured one
+	registry := NewClientRegistry(nil, nil)
+	if got := registry.ClientForDevice("support").InboxID; got != 12 {
+		t.Errorf("expected the forwards of the device to go to inbox 12, got %d", got)
+	}
+	if registry.ClientForDevice("support") != registry.ClientForDevice("support") {
+		t.Error("expected the device to reuse one client for its inbox")
+	}
+	if got := registry.ClientForDevice("other").InboxID; got != 7 {
+		t.Errorf("expected other devices to keep inbox 7, got %d", got)
+	}
+}
+
+func TestInboxWebhookURL_RequiresPublicURL(t *testing.T) {
+	origPublicURL, origMediaURL := config.ChatwootPublicURL, config.ChatwootMediaLinkBaseURL
+	t.Cleanup(func() { config.ChatwootPublicURL, config.ChatwootMediaLinkBaseURL = origPublicURL, origMediaURL })
+
+	config.ChatwootPublicURL, config.ChatwootMediaLinkBaseURL = "", ""
+	if _, err := InboxWebhookURL(); err == nil {
+		t.Fatal("expected an error without a public URL")
+	}
+	config.ChatwootPublicURL = "wa.example.com"
+	if _, err := InboxWebhookURL(); err == nil {
+		t.Fatal("expected an error for a URL without scheme")
+	}
+}
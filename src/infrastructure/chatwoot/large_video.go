@@ -0,0 +1,250 @@
+package chatwoot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+)
+
+// CHATWOOT_LARGE_VIDEO_MODE modes, for videos above CHATWOOT_LARGE_VIDEO_THRESHOLD.
+const (
+	LargeVideoFull      = "full"      // upload the video as it is
+	LargeVideoThumbnail = "thumbnail" // attach a JPEG frame and link to the saved video
+	LargeVideoLink      = "link"      // only link to the saved video
+)
+
+// staticsDir is served at /statics, so saved media below it can be linked.
+const staticsDir = "statics"
+
+var videoExtensions = map[string]struct{}{
+	".3gp":  {},
+	".avi":  {},
+	".m4v":  {},
+	".mkv":  {},
+	".mov":  {},
+	".mp4":  {},
+	".mpeg": {},
+	".webm": {},
+}
+
+// IsValidLargeVideoMode reports whether mode is one of the CHATWOOT_LARGE_VIDEO_MODE modes.
+func IsValidLargeVideoMode(mode string) bool {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case LargeVideoFull, LargeVideoThumbnail, LargeVideoLink:
+		return true
+	}
+	return false
+}
+
+// LargeVideoMode returns the configured CHATWOOT_LARGE_VIDEO_MODE, falling back to full when it is
+// not a known mode.
+func LargeVideoMode() string {
+	if !IsValidLargeVideoMode(config.ChatwootLargeVideoMode) {
+		return LargeVideoFull
+	}
+	return strings.ToLower(strings.TrimSpace(config.ChatwootLargeVideoMode))
+}
+
+// isLargeVideo reports whether a video of size bytes is held back from upload by the configured mode.
+func isLargeVideo(filePath string, size int64) bool {
+	if LargeVideoMode() == LargeVideoFull || config.ChatwootLargeVideoThreshold <= 0 || size <= config.ChatwootLargeVideoThreshold {
+		return false
+	}
+	_, ok := videoExtensions[strings.ToLower(filepath.Ext(filePath))]
+	return ok
+}
+
+// MediaLink returns the public URL of a media file saved below the statics directory, which is served
+// below APP_BASE_PATH, or "" when CHATWOOT_MEDIA_LINK_BASE_URL is not set or the file is not served.
+func MediaLink(filePath string) string {
+	base := strings.TrimRight(strings.TrimSpace(config.ChatwootMediaLinkBaseURL), "/")
+	if base == "" {
+		return ""
+	}
+	absStatics, err := filepath.Abs(staticsDir)
+	if err != nil {
+		return ""
+	}
+	absFile, err := filepath.Abs(filePath)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(absStatics, absFile)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return base + config.AppBasePath + "/statics/" + strings.Join(segments, "/")
+}
+
+// LargeVideoContent is the line posted instead of a large video.
+func LargeVideoContent(duration time.Duration, size int64, link string) string {
+	details := humanize.Bytes(uint64(size))
+	if duration > 0 {
+		details = formatVideoDuration(duration) + ", " + details
+	}
+	return fmt.Sprintf("🎬 Video (%s): %s", details, link)
+}
+
+func formatVideoDuration(d time.Duration) string {
+	total := int(d.Round(time.Second) / time.Second)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// ApplyLargeVideoPolicy replaces videos above CHATWOOT_LARGE_VIDEO_THRESHOLD with a line giving their
+// duration, size and link, plus a JPEG frame in thumbnail mode. A video that cannot be linked is left
+// for full upload. generated lists the frames written; the caller removes them once posted.
+func ApplyLargeVideoPolicy(content string, attachments []string) (string, []string, []string) {
+	var (
+		kept      = make([]string, 0, len(attachments))
+		generated []string
+		lines     []string
+	)
+	for _, path := range attachments {
+		info, err := os.Stat(path)
+		if err != nil || !isLargeVideo(path, info.Size()) {
+			kept = append(kept, path)
+			continue
+		}
+		link := MediaLink(path)
+		if link == "" {
+			logrus.Debugf("Chatwoot: %s is above CHATWOOT_LARGE_VIDEO_THRESHOLD but cannot be linked, uploading it in full", path)
+			kept = append(kept, path)
+			continue
+		}
+
+		lines = append(lines, LargeVideoContent(videoDuration(path), info.Size(), link))
+		if LargeVideoMode() == LargeVideoThumbnail {
+			frame, err := extractVideoFrameAt(path, time.Second)
+			if err != nil {
+				logrus.Warnf("Chatwoot: Failed to extract a thumbnail of %s: %v", path, err)
+				continue
+			}
+			kept = append(kept, frame)
+			generated = append(generated, frame)
+		}
+	}
+
+	if len(lines) == 0 {
+		return content, attachments, nil
+	}
+	if content != "" {
+		lines = append([]string{content}, lines...)
+	}
+	return strings.Join(lines, "\n"), kept, generated
+}
+
+// keepLargeVideoForLink moves a downloaded video that ApplyLargeVideoPolicy would hold back into the
+// media directory, where its link is served, and returns the new path. Other files are returned as
+// they are. The moved video keeps its tempFilePrefix name, so SweepLinkedVideos can find it.
+func keepLargeVideoForLink(tmpPath string) string {
+	info, err := os.Stat(tmpPath)
+	if err != nil || !isLargeVideo(tmpPath, info.Size()) || strings.TrimSpace(config.ChatwootMediaLinkBaseURL) == "" {
+		return tmpPath
+	}
+
+	target := filepath.Join(config.PathMedia, filepath.Base(tmpPath))
+	if err := moveFile(tmpPath, target); err != nil {
+		logrus.Warnf("Chatwoot: Failed to keep %s for linking: %v", tmpPath, err)
+		return tmpPath
+	}
+	removeTempFile(tmpPath)
+	return target
+}
+
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// SweepLinkedVideos deletes the videos keepLargeVideoForLink moved into the media directory that were
+// last modified before maxAge ago. It returns how many videos it deleted.
+func SweepLinkedVideos(maxAge time.Duration) int {
+	entries, err := os.ReadDir(config.PathMedia)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Warnf("Chatwoot: failed to list media dir %s: %v", config.PathMedia, err)
+		}
+		return 0
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			continue
+		}
+		if _, ok := videoExtensions[strings.ToLower(filepath.Ext(entry.Name()))]; !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		path := filepath.Join(config.PathMedia, entry.Name())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logrus.Debugf("Chatwoot: failed to sweep linked video %s: %v", path, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// videoDuration asks ffprobe for the length of a video; it returns 0 when that is not possible.
+func videoDuration(videoPath string) time.Duration {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", videoPath).Output()
+	if err != nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,178 @@
+package chatwoot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func useLargeVideoConfig(t *testing.T, mode string, threshold int64, baseURL string) {
+	t.Helper()
+	prevMode, prevThreshold, prevBase := config.ChatwootLargeVideoMode, config.ChatwootLargeVideoThreshold, config.ChatwootMediaLinkBaseURL
+	config.ChatwootLargeVideoMode, config.ChatwootLargeVideoThreshold, config.ChatwootMediaLinkBaseURL = mode, threshold, baseURL
+	t.Cleanup(func() {
+		config.ChatwootLargeVideoMode, config.ChatwootLargeVideoThreshold, config.ChatwootMediaLinkBaseURL = prevMode, prevThreshold, prevBase
+	})
+}
+
+// writeStaticsFile creates a file of size bytes below statics/media of a fresh working directory.
+func writeStaticsFile(t *testing.T, name string, size int) string {
+	t.Helper()
+	path := filepath.Join(staticsDir, "media", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLargeVideoMode(t *testing.T) {
+	for mode, want := range map[string]string{
+		"thumbnail": LargeVideoThumbnail,
+		" LINK ":    LargeVideoLink,
+		"full":      LargeVideoFull,
+		"preview":   LargeVideoFull,
+		"":          LargeVideoFull,
+	} {
+		useLargeVideoConfig(t, mode, 1000, "")
+		if got := LargeVideoMode(); got != want {
+			t.Errorf("LargeVideoMode() with %q = %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestMediaLink(t *testing.T) {
+	t.Chdir(t.TempDir())
+	served := writeStaticsFile(t, "clip 1.mp4", 10)
+	outside := filepath.Join(t.TempDir(), "clip.mp4")
+
+	useLargeVideoConfig(t, LargeVideoLink, 1000, "")
+	if got := MediaLink(served); got != "" {
+		t.Errorf("MediaLink without a base URL = %q, want none", got)
+	}
+
+	useLargeVideoConfig(t, LargeVideoLink, 1000, "https://wa.example.com/app/")
+	if got, want := MediaLink(served), "https://wa.example.com/app/statics/media/clip%201.mp4"; got != want {
+		t.Errorf("MediaLink = %q, want %q", got, want)
+	}
+	if got := MediaLink(outside); got != "" {
+		t.Errorf("MediaLink of a file outside statics = %q, want none", got)
+	}
+
+	prevBasePath := config.AppBasePath
+	config.AppBasePath = "/gowa"
+	t.Cleanup(func() { config.AppBasePath = prevBasePath })
+	useLargeVideoConfig(t, LargeVideoLink, 1000, "https://wa.example.com/")
+	if got, want := MediaLink(served), "https://wa.example.com/gowa/statics/media/clip%201.mp4"; got != want {
+		t.Errorf("MediaLink below APP_BASE_PATH = %q, want %q", got, want)
+	}
+}
+
+func TestSweepLinkedVideos_RemovesOldMovedVideosOnly(t *testing.T) {
+	dir := t.TempDir()
+	prevMedia := config.PathMedia
+	config.PathMedia = dir
+	t.Cleanup(func() { config.PathMedia = prevMedia })
+
+	old := time.Now().Add(-48 * time.Hour)
+	for name, modTime := range map[string]time.Time{
+		"chatwoot-sync-old.mp4":    old,
+		"chatwoot-sync-recent.mp4": time.Now(),
+		"chatwoot-sync-old.jpg":    old,
+		"1714560000-received.mp4":  old,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if removed := SweepLinkedVideos(24 * time.Hour); removed != 1 {
+		t.Errorf("expected one video removed, got %d", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "chatwoot-sync-old.mp4")); !os.IsNotExist(err) {
+		t.Error("expected the old moved video to be swept")
+	}
+	for _, name := range []string{"chatwoot-sync-recent.mp4", "chatwoot-sync-old.jpg", "1714560000-received.mp4"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to stay: %v", name, err)
+		}
+	}
+}
+
+func TestLargeVideoContent(t *testing.T) {
+	link := "https://wa.example.com/statics/media/a.mp4"
+	if got, want := LargeVideoContent(83*time.Second, 45_600_000, link), "🎬 Video (1:23, 46 MB): "+link; got != want {
+		t.Errorf("LargeVideoContent = %q, want %q", got, want)
+	}
+	if got, want := LargeVideoContent(3725*time.Second, 2_000_000, link), "🎬 Video (1:02:05, 2.0 MB): "+link; got != want {
+		t.Errorf("LargeVideoContent = %q, want %q", got, want)
+	}
+	if got, want := LargeVideoContent(0, 2_000_000, link), "🎬 Video (2.0 MB): "+link; got != want {
+		t.Errorf("LargeVideoContent without duration = %q, want %q", got, want)
+	}
+}
+
+func TestApplyLargeVideoPolicy(t *testing.T) {
+	t.Chdir(t.TempDir())
+	large := writeStaticsFile(t, "large.mp4", 2000)
+	small := writeStaticsFile(t, "small.mp4", 500)
+	photo := writeStaticsFile(t, "photo.jpg", 2000)
+	attachments := []string{large, small, photo}
+
+	t.Run("FullKeepsEverything", func(t *testing.T) {
+		useLargeVideoConfig(t, LargeVideoFull, 1000, "https://wa.example.com")
+		content, kept, generated := ApplyLargeVideoPolicy("caption", attachments)
+		if content != "caption" || len(kept) != 3 || len(generated) != 0 {
+			t.Fatalf("got %q, %v, %v; want the message unchanged", content, kept, generated)
+		}
+	})
+
+	t.Run("LinkReplacesLargeVideos", func(t *testing.T) {
+		useLargeVideoConfig(t, LargeVideoLink, 1000, "https://wa.example.com")
+		content, kept, generated := ApplyLargeVideoPolicy("caption", attachments)
+		if want := "caption\n🎬 Video (2.0 kB): https://wa.example.com/statics/media/large.mp4"; content != want {
+			t.Fatalf("content = %q, want %q", content, want)
+		}
+		if strings.Join(kept, ",") != small+","+photo || len(generated) != 0 {
+			t.Fatalf("kept %v, generated %v; want the small video and the photo only", kept, generated)
+		}
+	})
+
+	t.Run("UnlinkableVideoIsUploaded", func(t *testing.T) {
+		useLargeVideoConfig(t, LargeVideoThumbnail, 1000, "")
+		content, kept, _ := ApplyLargeVideoPolicy("", attachments)
+		if content != "" || len(kept) != 3 {
+			t.Fatalf("got %q, %v; want the video uploaded in full without a base URL", content, kept)
+		}
+	})
+
+	t.Run("ThumbnailCleansUpItsFrames", func(t *testing.T) {
+		useLargeVideoConfig(t, LargeVideoThumbnail, 1000, "https://wa.example.com")
+		content, kept, generated := ApplyLargeVideoPolicy("", attachments)
+		if !strings.HasPrefix(content, "🎬 Video (") {
+			t.Fatalf("content = %q, want the video line", content)
+		}
+		for _, path := range kept {
+			if path == large {
+				t.Fatal("large video was still attached")
+			}
+		}
+		// The test video is not decodable, so no frame is expected; any frame written must be listed.
+		for _, frame := range generated {
+			if _, err := os.Stat(frame); err != nil {
+				t.Fatalf("generated frame %s does not exist: %v", frame, err)
+			}
+			_ = os.Remove(frame)
+		}
+	})
+}
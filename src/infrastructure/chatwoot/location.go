@@ -0,0 +1,72 @@
+package chatwoot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+)
+
+// DefaultLocationMapURL is used when ChatwootLocationMapURL is empty.
+const DefaultLocationMapURL = "https://www.google.com/maps/search/?api=1&query={lat},{lon}"
+
+// Location is a WhatsApp static or live location shown to agents.
+type Location struct {
+	Latitude       float64
+	Longitude      float64
+	Name           string
+	Address        string
+	Caption        string
+	AccuracyMeters uint32
+	Live           bool
+}
+
+// LocationMapURL fills the {lat} and {lon} placeholders of the configured map URL template.
+func LocationMapURL(latitude, longitude float64) string {
+	template := strings.TrimSpace(config.ChatwootLocationMapURL)
+	if template == "" {
+		template = DefaultLocationMapURL
+	}
+	return strings.NewReplacer(
+		"{lat}", strconv.FormatFloat(latitude, 'f', 6, 64),
+		"{lon}", strconv.FormatFloat(longitude, 'f', 6, 64),
+	).Replace(template)
+}
+
+// FormatLocation renders a location as message content with one detail per line and the map
+// link last, so Chatwoot shows a clickable pin.
+func FormatLocation(loc Location) string {
+	title := "📍 Location"
+	if loc.Live {
+		title = "📍 Live location"
+	}
+	if loc.Name != "" {
+		title += ": " + loc.Name
+	}
+
+	lines := []string{title}
+	for _, detail := range []string{loc.Address, loc.Caption} {
+		if detail = strings.TrimSpace(detail); detail != "" {
+			lines = append(lines, detail)
+		}
+	}
+	if loc.AccuracyMeters > 0 {
+		lines = append(lines, fmt.Sprintf("Accuracy: ±%d m", loc.AccuracyMeters))
+	}
+	lines = append(lines, LocationMapURL(loc.Latitude, loc.Longitude))
+	return strings.Join(lines, "\n")
+}
+
+// ReplaceGeoURIs swaps geo URIs kept in stored message content for map links.
+func ReplaceGeoURIs(content string) string {
+	for _, match := range utils.FindGeoURIs(content) {
+		link := LocationMapURL(match.Latitude, match.Longitude)
+		if match.AccuracyMeters > 0 {
+			link += fmt.Sprintf(" (±%d m)", match.AccuracyMeters)
+		}
+		content = strings.Replace(content, match.URI, link, 1)
+	}
+	return content
+}
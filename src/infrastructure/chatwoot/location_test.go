@@ -0,0 +1,57 @@
+package chatwoot
+
+import (
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func withLocationMapURL(t *testing.T, template string) {
+	t.Helper()
+	original := config.ChatwootLocationMapURL
+	config.ChatwootLocationMapURL = template
+	t.Cleanup(func() { config.ChatwootLocationMapURL = original })
+}
+
+func TestFormatLocation(t *testing.T) {
+	withLocationMapURL(t, DefaultLocationMapURL)
+
+	got := FormatLocation(Location{
+		Latitude:       -23.561414,
+		Longitude:      -46.655881,
+		Name:           "MASP",
+		Address:        "Av. Paulista, 1578",
+		AccuracyMeters: 20,
+	})
+	want := "📍 Location: MASP\nAv. Paulista, 1578\nAccuracy: ±20 m\nhttps://www.google.com/maps/search/?api=1&query=-23.561414,-46.655881"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	live := FormatLocation(Location{Latitude: 1, Longitude: 2, Caption: "On my way", Live: true})
+	if live != "📍 Live location\nOn my way\nhttps://www.google.com/maps/search/?api=1&query=1.000000,2.000000" {
+		t.Fatalf("unexpected live location content %q", live)
+	}
+}
+
+func TestLocationMapURL_CustomTemplate(t *testing.T) {
+	withLocationMapURL(t, "https://www.openstreetmap.org/?mlat={lat}&mlon={lon}#map=17/{lat}/{lon}")
+
+	got := LocationMapURL(48.8584, 2.2945)
+	want := "https://www.openstreetmap.org/?mlat=48.858400&mlon=2.294500#map=17/48.858400/2.294500"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestReplaceGeoURIs(t *testing.T) {
+	withLocationMapURL(t, "https://maps.example/{lat},{lon}")
+
+	got := ReplaceGeoURIs("📍 Live location: geo:51.500000,-0.120000;u=15")
+	if got != "📍 Live location: https://maps.example/51.500000,-0.120000 (±15 m)" {
+		t.Fatalf("unexpected content %q", got)
+	}
+	if plain := ReplaceGeoURIs("no location here"); plain != "no location here" {
+		t.Fatalf("plain text changed to %q", plain)
+	}
+}
@@ -0,0 +1,414 @@
+package chatwoot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+)
+
+// Per-message outcomes reported by the media backfill
+const (
+	MediaBackfillAttached = "attached"
+	MediaBackfillExpired  = "expired"
+	MediaBackfillSkipped  = "skipped"
+	MediaBackfillFailed   = "failed"
+)
+
+// mediaBackfillSourceSuffix marks follow-up messages created by the backfill so reruns skip them
+const mediaBackfillSourceSuffix = "-media"
+
+// maxMediaBackfillResults caps the per-message outcomes kept in memory for the status endpoint
+const maxMediaBackfillResults = 1000
+
+// MediaBackfillResult describes what happened to a single message during the backfill
+type MediaBackfillResult struct {
+	ChatJID   string    `json:"chat_jid"`
+	MessageID string    `json:"message_id"`
+	MediaType string    `json:"media_type"`
+	Timestamp time.Time `json:"timestamp"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// MediaBackfillProgress tracks a media backfill run
+type MediaBackfillProgress struct {
+	DeviceID      string                `json:"device_id"`
+	Status        string                `json:"status"` // idle, running, completed, failed
+	TotalChats    int                   `json:"total_chats"`
+	ScannedChats  int                   `json:"scanned_chats"`
+	Candidates    int                   `json:"candidates"`
+	Attached      int                   `json:"attached"`
+	Expired       int                   `json:"expired"`
+	Skipped       int                   `json:"skipped"`
+	Failed        int                   `json:"failed"`
+	CurrentChat   string                `json:"current_chat,omitempty"`
+	Results       []MediaBackfillResult `json:"results,omitempty"`
+	StartedAt     *time.Time            `json:"started_at,omitempty"`
+	CompletedAt   *time.Time            `json:"completed_at,omitempty"`
+	Error         string                `json:"error,omitempty"`
+	mu            sync.RWMutex
+	resultsCapped bool
+}
+
+func newMediaBackfillProgress(deviceID string) *MediaBackfillProgress {
+	now := time.Now()
+	return &MediaBackfillProgress{
+		DeviceID:  deviceID,
+		Status:    "running",
+		StartedAt: &now,
+	}
+}
+
+func (p *MediaBackfillProgress) setTotalChats(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.TotalChats = total
+}
+
+func (p *MediaBackfillProgress) startChat(chatJID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.CurrentChat = chatJID
+	p.ScannedChats++
+}
+
+func (p *MediaBackfillProgress) record(result MediaBackfillResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Candidates++
+	switch result.Outcome {
+	case MediaBackfillAttached:
+		p.Attached++
+	case MediaBackfillExpired:
+		p.Expired++
+	case MediaBackfillSkipped:
+		p.Skipped++
+	default:
+		p.Failed++
+	}
+
+	if len(p.Results) >= maxMediaBackfillResults {
+		p.resultsCapped = true
+		return
+	}
+	p.Results = append(p.Results, result)
+}
+
+func (p *MediaBackfillProgress) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.CompletedAt = &now
+	p.CurrentChat = ""
+	if err != nil {
+		p.Status = "failed"
+		p.Error = err.Error()
+		return
+	}
+	p.Status = "completed"
+	if p.resultsCapped {
+		p.Error = fmt.Sprintf("only the first %d results are listed", maxMediaBackfillResults)
+	}
+}
+
+// Clone returns a thread-safe copy of the progress
+func (p *MediaBackfillProgress) Clone() MediaBackfillProgress {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return MediaBackfillProgress{
+		DeviceID:     p.DeviceID,
+		Status:       p.Status,
+		TotalChats:   p.TotalChats,
+		ScannedChats: p.ScannedChats,
+		Candidates:   p.Candidates,
+		Attached:     p.Attached,
+		Expired:      p.Expired,
+		Skipped:      p.Skipped,
+		Failed:       p.Failed,
+		CurrentChat:  p.CurrentChat,
+		Results:      append([]MediaBackfillResult(nil), p.Results...),
+		StartedAt:    p.StartedAt,
+		CompletedAt:  p.CompletedAt,
+		Error:        p.Error,
+	}
+}
+
+// IsRunning returns true if the backfill is currently running
+func (p *MediaBackfillProgress) IsRunning() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Status == "running"
+}
+
+// GetMediaBackfillProgress returns the latest media backfill progress for a device
+func (s *SyncService) GetMediaBackfillProgress(deviceID string) *MediaBackfillProgress {
+	s.progressMu.RLock()
+	defer s.progressMu.RUnlock()
+
+	if progress, ok := s.backfillMap[deviceID]; ok {
+		cloned := progress.Clone()
+		return &cloned
+	}
+	return nil
+}
+
+// IsMediaBackfillRunning returns true if a media backfill is currently running for the device
+func (s *SyncService) IsMediaBackfillRunning(deviceID string) bool {
+	s.progressMu.RLock()
+	defer s.progressMu.RUnlock()
+
+	if progress, ok := s.backfillMap[deviceID]; ok {
+		return progress.IsRunning()
+	}
+	return false
+}
+
+// BackfillMedia attaches media to messages that were imported without it (e.g. a history sync with
+// IncludeMedia=false). Text is never re-imported: each attachment is posted as a follow-up message
+// that references the original timestamp.
+func (s *SyncService) BackfillMedia(ctx context.Context, deviceID string, waClient *whatsmeow.Client, opts SyncOptions) (*MediaBackfillProgress, error) {
+	if waClient == nil {
+		return nil, fmt.Errorf("WhatsApp client not available")
+	}
+	if opts.MaxMessagesPerChat <= 0 {
+		opts.MaxMessagesPerChat = DefaultSyncOptions().MaxMessagesPerChat
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultSyncOptions().BatchSize
+	}
+	if opts.DelayBetweenBatches < 0 {
+		opts.DelayBetweenBatches = 0
+	}
+
+	progress := newMediaBackfillProgress(deviceID)
+	s.progressMu.Lock()
+	if existing, ok := s.backfillMap[deviceID]; ok && existing.IsRunning() {
+		s.progressMu.Unlock()
+		cloned := existing.Clone()
+		return &cloned, fmt.Errorf("media backfill already in progress for device %s", deviceID)
+	}
+	s.backfillMap[deviceID] = progress
+	s.progressMu.Unlock()
+
+	logrus.Infof("Chatwoot Backfill: Starting media backfill for device %s (days: %d, groups: %v, status: %v, max_media_bytes: %d)",
+		deviceID, opts.DaysLimit, opts.IncludeGroups, opts.IncludeStatus, opts.MaxMediaFileSize)
+
+	chats, err := s.chatStorageRepo.GetChats(&domainChatStorage.ChatFilter{DeviceID: deviceID})
+	if err != nil {
+		progress.finish(err)
+		return progress, fmt.Errorf("failed to get chats: %w", err)
+	}
+
+	filtered := make([]*domainChatStorage.Chat, 0, len(chats))
+	for _, chat := range chats {
+		if chat == nil {
+			continue
+		}
+		if !opts.IncludeStatus && isStatusBroadcastChatJID(chat.JID) {
+			continue
+		}
+		if strings.HasSuffix(chat.JID, "@g.us") && !opts.IncludeGroups {
+			continue
+		}
+		filtered = append(filtered, chat)
+	}
+	progress.setTotalChats(len(filtered))
+
+	sinceTime := time.Now().AddDate(0, 0, -opts.DaysLimit)
+	processed := 0
+
+	for _, chat := range filtered {
+		if err := ctx.Err(); err != nil {
+			progress.finish(err)
+			return progress, err
+		}
+
+		progress.startChat(chat.JID)
+		if err := s.backfillChatMedia(ctx, deviceID, chat, sinceTime, waClient, opts, progress, &processed); err != nil {
+			logrus.Errorf("Chatwoot Backfill: Failed to scan chat %s: %v", chat.JID, err)
+		}
+	}
+
+	progress.finish(nil)
+	snapshot := progress.Clone()
+	logrus.Infof("Chatwoot Backfill: Completed for device %s. Attached: %d, expired: %d, skipped: %d, failed: %d",
+		deviceID, snapshot.Attached, snapshot.Expired, snapshot.Skipped, snapshot.Failed)
+
+	return progress, nil
+}
+
+// backfillChatMedia finds exported media messages of one chat whose Chatwoot copy has no attachment
+func (s *SyncService) backfillChatMedia(
+	ctx context.Context,
+	deviceID string,
+	chat *domainChatStorage.Chat,
+	sinceTime time.Time,
+	waClient *whatsmeow.Client,
+	opts SyncOptions,
+	progress *MediaBackfillProgress,
+	processed *int,
+) error {
+	// Media of disappearing messages is only attached when CHATWOOT_FORWARD_EPHEMERAL exports them as is
+	if ChatEphemeralMode(chat.EphemeralExpiration) != EphemeralExport {
+		return nil
+	}
+
+	messages, err := s.chatStorageRepo.GetMessages(&domainChatStorage.MessageFilter{
+		DeviceID:  deviceID,
+		ChatJID:   chat.JID,
+		StartTime: &sinceTime,
+		Limit:     opts.MaxMessagesPerChat,
+		MediaOnly: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get messages: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	isGroup := strings.HasSuffix(chat.JID, "@g.us")
+	cw := s.clientFor(waClient)
+	contact, err := cw.FindContactByIdentifier(chat.JID, isGroup)
+	if err != nil {
+		return fmt.Errorf("failed to find contact: %w", err)
+	}
+	if contact == nil {
+		return nil
+	}
+	conversation, err := cw.FindConversation(contact.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find conversation: %w", err)
+	}
+	if conversation == nil {
+		return nil
+	}
+
+	cwMsgs, err := cw.GetConversationMessages(conversation.ID, sinceTime)
+	if err != nil {
+		return err
+	}
+	bySource := make(map[string]ChatwootMessage, len(cwMsgs))
+	for _, m := range cwMsgs {
+		if m.SourceID != "" {
+			bySource[m.SourceID] = m
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+
+	for _, msg := range messages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if msg.MediaType == "" {
+			continue
+		}
+		// View-once media left out on purpose (placeholder or blur) must not be attached afterwards.
+		if msg.IsViewOnce && ViewOnceMode() != ViewOnceFull {
+			continue
+		}
+
+		// Messages synced before they were created with their WhatsApp ID carry a content hash
+		key := ForwardedMessageKey(msg.ID)
+		original, ok := bySource[key]
+		if !ok {
+			key = messageKey(deviceID, chat.JID, msg)
+			original, ok = bySource[key]
+		}
+		if !ok || len(original.Attachments) > 0 {
+			continue
+		}
+		if _, done := bySource[key+mediaBackfillSourceSuffix]; done {
+			continue
+		}
+
+		progress.record(s.backfillMessageMedia(ctx, conversation.ID, chat.JID, msg, waClient, opts, isGroup, key))
+
+		*processed++
+		if *processed%opts.BatchSize == 0 {
+			time.Sleep(opts.DelayBetweenBatches)
+		}
+	}
+
+	return nil
+}
+
+func (s *SyncService) backfillMessageMedia(
+	ctx context.Context,
+	conversationID int,
+	chatJID string,
+	msg *domainChatStorage.Message,
+	waClient *whatsmeow.Client,
+	opts SyncOptions,
+	isGroup bool,
+	sourceID string,
+) MediaBackfillResult {
+	result := MediaBackfillResult{
+		ChatJID:   chatJID,
+		MessageID: msg.ID,
+		MediaType: msg.MediaType,
+		Timestamp: msg.Timestamp,
+	}
+
+	if opts.MaxMediaFileSize > 0 && msg.FileLength > uint64(opts.MaxMediaFileSize) {
+		result.Outcome = MediaBackfillSkipped
+		result.Error = fmt.Sprintf("file too large (%d bytes)", msg.FileLength)
+		return result
+	}
+
+	fp, err := s.downloadMedia(ctx, msg, waClient)
+	if err != nil {
+		result.Outcome = mediaDownloadOutcome(err)
+		result.Error = err.Error()
+		return result
+	}
+	defer removeTempFile(fp)
+
+	messageType := "incoming"
+	if msg.IsFromMe {
+		messageType = "outgoing"
+	}
+
+	sender := ""
+	if isGroup && !msg.IsFromMe && msg.Sender != "" {
+		sender = resolveSenderName(waClient, msg.Sender)
+	}
+	request := historyMessage(msg.Timestamp, sender, RenderMessage(MessageMediaPlaceholder, MessageData{MediaType: msg.MediaType, Timestamp: MessageTime(msg.Timestamp)}), messageType)
+
+	cw := s.clientFor(waClient)
+	chatwootMsgID, err := cw.CreateMessageFromRequest(conversationID, request, []string{fp}, sourceID+mediaBackfillSourceSuffix)
+	if err != nil {
+		result.Outcome = MediaBackfillFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	cw.MarkMessageAsSent(chatwootMsgID)
+	result.Outcome = MediaBackfillAttached
+	return result
+}
+
+// mediaDownloadOutcome tells expired media (gone from WhatsApp servers) apart from other download failures
+func mediaDownloadOutcome(err error) string {
+	if errors.Is(err, errMediaTooLarge) {
+		return MediaBackfillSkipped
+	}
+	if errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404) ||
+		errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410) ||
+		errors.Is(err, whatsmeow.ErrMediaNotAvailableOnPhone) {
+		return MediaBackfillExpired
+	}
+	return MediaBackfillFailed
+}
@@ -0,0 +1,96 @@
+package chatwoot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+func TestMediaDownloadOutcome(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "404", err: fmt.Errorf("download failed: %w", whatsmeow.ErrMediaDownloadFailedWith404), want: MediaBackfillExpired},
+		{name: "410", err: fmt.Errorf("download failed: %w", whatsmeow.ErrMediaDownloadFailedWith410), want: MediaBackfillExpired},
+		{name: "403", err: fmt.Errorf("download failed: %w", whatsmeow.ErrMediaDownloadFailedWith403), want: MediaBackfillFailed},
+		{name: "other", err: errors.New("timeout"), want: MediaBackfillFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mediaDownloadOutcome(tt.err); got != tt.want {
+				t.Fatalf("mediaDownloadOutcome(%v)=%q want=%q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaBackfillProgress_Record(t *testing.T) {
+	p := newMediaBackfillProgress("test-device")
+
+	p.record(MediaBackfillResult{Outcome: MediaBackfillAttached})
+	p.record(MediaBackfillResult{Outcome: MediaBackfillExpired})
+	p.record(MediaBackfillResult{Outcome: MediaBackfillSkipped})
+	p.record(MediaBackfillResult{Outcome: MediaBackfillFailed})
+	p.finish(nil)
+
+	got := p.Clone()
+	if got.Status != "completed" {
+		t.Fatalf("expected status completed, got %s", got.Status)
+	}
+	if got.Candidates != 4 || got.Attached != 1 || got.Expired != 1 || got.Skipped != 1 || got.Failed != 1 {
+		t.Fatalf("unexpected counters: attached=%d expired=%d skipped=%d failed=%d", got.Attached, got.Expired, got.Skipped, got.Failed)
+	}
+	if len(got.Results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(got.Results))
+	}
+}
+
+func TestGetConversationMessages_PagesUntilSince(t *testing.T) {
+	now := time.Now()
+	old := now.AddDate(0, 0, -10).Unix()
+	recent := now.Add(-time.Hour).Unix()
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("before") {
+		case "":
+			fmt.Fprintf(w, `{"payload":[{"id":30,"source_id":"a","created_at":%d},{"id":31,"source_id":"b","created_at":%d}]}`, recent, recent)
+		case "30":
+			fmt.Fprintf(w, `{"payload":[{"id":10,"source_id":"old","created_at":%d},{"id":20,"source_id":"c","created_at":%d,"attachments":[{"id":1}]}]}`, old, recent)
+		default:
+			t.Fatalf("unexpected page request: %s", r.URL.RawQuery)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	msgs, err := c.GetConversationMessages(5, now.AddDate(0, 0, -3))
+	if err != nil {
+		t.Fatalf("GetConversationMessages returned error: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 page requests, got %d (%v)", len(requests), requests)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages inside the window, got %d", len(msgs))
+	}
+	for _, m := range msgs {
+		if m.SourceID == "old" {
+			t.Fatal("message older than since should be dropped")
+		}
+		if m.SourceID == "c" && len(m.Attachments) != 1 {
+			t.Fatal("expected attachments to be decoded")
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package chatwoot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/disintegration/imaging"
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+)
+
+// mediaLinkThumbnailSize bounds the preview attached to a linked image.
+const mediaLinkThumbnailSize = 320
+
+var mediaLinkExtensions = map[string]string{
+	".jpg":  "image",
+	".jpeg": "image",
+	".png":  "image",
+	".gif":  "image",
+	".webp": "image",
+	".ogg":  "audio",
+	".oga":  "audio",
+	".opus": "audio",
+	".mp3":  "audio",
+	".m4a":  "audio",
+	".aac":  "audio",
+	".amr":  "audio",
+	".wav":  "audio",
+}
+
+var mediaLinkLabels = map[string]string{
+	"image":    "🖼️ Image",
+	"audio":    "🎵 Audio",
+	"video":    "🎬 Video",
+	"document": "📄 Document",
+}
+
+// mediaLinkType classifies a saved media file by its extension as image, audio, video or document.
+func mediaLinkType(filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if _, ok := videoExtensions[ext]; ok {
+		return "video"
+	}
+	if mediaType, ok := mediaLinkExtensions[ext]; ok {
+		return mediaType
+	}
+	return "document"
+}
+
+// linksMedia reports whether a file of mediaType and size bytes is linked instead of uploaded under
+// CHATWOOT_MEDIA_LINK_TYPES and CHATWOOT_MEDIA_LINK_THRESHOLD.
+func linksMedia(mediaType string, size int64) bool {
+	if config.ChatwootMediaLinkThreshold > 0 && size <= config.ChatwootMediaLinkThreshold {
+		return false
+	}
+	for _, linked := range config.ChatwootMediaLinkTypes {
+		if strings.EqualFold(strings.TrimSpace(linked), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaLinkContent is the line posted instead of a linked media file.
+func MediaLinkContent(mediaType string, size int64, link string) string {
+	return fmt.Sprintf("%s (%s): %s", mediaLinkLabels[mediaType], humanize.Bytes(uint64(size)), link)
+}
+
+// ApplyMediaLinkPolicy replaces saved media of the CHATWOOT_MEDIA_LINK_TYPES above
+// CHATWOOT_MEDIA_LINK_THRESHOLD with a line linking to the copy this server serves, so the file is not
+// uploaded to Chatwoot again. Linked images get a small preview attached when
+// CHATWOOT_MEDIA_LINK_THUMBNAIL is on. Files that are missing or cannot be linked are left for upload.
+// generated lists the previews written; the caller removes them once posted.
+func ApplyMediaLinkPolicy(content string, attachments []string) (string, []string, []string) {
+	if len(config.ChatwootMediaLinkTypes) == 0 {
+		return content, attachments, nil
+	}
+
+	var (
+		kept      = make([]string, 0, len(attachments))
+		generated []string
+		lines     []string
+	)
+	for _, path := range attachments {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			kept = append(kept, path)
+			continue
+		}
+		mediaType := mediaLinkType(path)
+		if !linksMedia(mediaType, info.Size()) {
+			kept = append(kept, path)
+			continue
+		}
+		link := MediaLink(path)
+		if link == "" {
+			logrus.Debugf("Chatwoot: %s cannot be linked, uploading it", path)
+			kept = append(kept, path)
+			continue
+		}
+
+		lines = append(lines, MediaLinkContent(mediaType, info.Size(), link))
+		if mediaType == "image" && config.ChatwootMediaLinkThumbnail {
+			preview, err := writeImageThumbnail(path)
+			if err != nil {
+				logrus.Warnf("Chatwoot: Failed to make a preview of %s: %v", path, err)
+				continue
+			}
+			kept = append(kept, preview)
+			generated = append(generated, preview)
+		}
+	}
+
+	if len(lines) == 0 {
+		return content, attachments, nil
+	}
+	if content != "" {
+		lines = append([]string{content}, lines...)
+	}
+	return strings.Join(lines, "\n"), kept, generated
+}
+
+// writeImageThumbnail writes a small JPEG of an image and returns its path; the caller removes it.
+func writeImageThumbnail(imagePath string) (string, error) {
+	img, err := imaging.Open(imagePath, imaging.AutoOrientation(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %w", err)
+	}
+	img = imaging.Fit(img, mediaLinkThumbnailSize, mediaLinkThumbnailSize, imaging.Lanczos)
+
+	tmpFile, err := createTempFile("media-preview-*.jpg")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for preview: %w", err)
+	}
+	defer tmpFile.Close()
+
+	if err := imaging.Encode(tmpFile, img, imaging.JPEG, imaging.JPEGQuality(70)); err != nil {
+		removeTempFile(tmpFile.Name())
+		return "", fmt.Errorf("failed to encode preview: %w", err)
+	}
+	return tmpFile.Name(), nil
+}
@@ -0,0 +1,101 @@
+package chatwoot
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func useMediaLinkConfig(t *testing.T, types []string, threshold int64, thumbnail bool, baseURL string) {
+	t.Helper()
+	prevTypes, prevThreshold, prevThumbnail := config.ChatwootMediaLinkTypes, config.ChatwootMediaLinkThreshold, config.ChatwootMediaLinkThumbnail
+	config.ChatwootMediaLinkTypes, config.ChatwootMediaLinkThreshold, config.ChatwootMediaLinkThumbnail = types, threshold, thumbnail
+	t.Cleanup(func() {
+		config.ChatwootMediaLinkTypes, config.ChatwootMediaLinkThreshold, config.ChatwootMediaLinkThumbnail = prevTypes, prevThreshold, prevThumbnail
+	})
+	useLargeVideoConfig(t, LargeVideoFull, 0, baseURL)
+}
+
+// writeStaticsImage saves a width x height PNG below statics/media.
+func writeStaticsImage(t *testing.T, name string, width, height int) string {
+	t.Helper()
+	path := writeStaticsFile(t, name, 0)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMediaLinkType(t *testing.T) {
+	for path, want := range map[string]string{
+		"a.JPG": "image", "b.webp": "image", "c.oga": "audio", "d.mp4": "video", "e.pdf": "document", "f": "document",
+	} {
+		if got := mediaLinkType(path); got != want {
+			t.Errorf("mediaLinkType(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestApplyMediaLinkPolicy(t *testing.T) {
+	t.Chdir(t.TempDir())
+	report := writeStaticsFile(t, "report Q3#1.pdf", 2000)
+	note := writeStaticsFile(t, "note.pdf", 500)
+	voice := writeStaticsFile(t, "voice.ogg", 2000)
+	photo := writeStaticsImage(t, "photo.png", 800, 600)
+	missing := filepath.Join(staticsDir, "media", "gone.pdf")
+	attachments := []string{report, note, voice, photo, missing}
+
+	t.Run("DisabledKeepsEverything", func(t *testing.T) {
+		useMediaLinkConfig(t, nil, 0, true, "https://wa.example.com")
+		content, kept, generated := ApplyMediaLinkPolicy("caption", attachments)
+		if content != "caption" || len(kept) != len(attachments) || len(generated) != 0 {
+			t.Fatalf("got %q, %v, %v; want the message unchanged", content, kept, generated)
+		}
+	})
+
+	t.Run("LinksListedTypesAboveThreshold", func(t *testing.T) {
+		useMediaLinkConfig(t, []string{"document", " Image "}, 1000, true, "https://wa.example.com")
+		content, kept, generated := ApplyMediaLinkPolicy("caption", attachments)
+		t.Cleanup(func() { removeFiles(generated) })
+
+		lines := strings.Split(content, "\n")
+		if len(lines) != 3 || lines[0] != "caption" || lines[1] != "📄 Document (2.0 kB): https://wa.example.com/statics/media/report%20Q3%231.pdf" ||
+			!strings.HasPrefix(lines[2], "🖼️ Image (") || !strings.HasSuffix(lines[2], "/statics/media/photo.png") {
+			t.Fatalf("content = %q, want the caption and links to the report and photo", content)
+		}
+		if len(generated) != 1 {
+			t.Fatalf("generated %v, want one image preview", generated)
+		}
+		if strings.Join(kept, ",") != strings.Join([]string{note, voice, generated[0], missing}, ",") {
+			t.Fatalf("kept %v; want the small document, the audio, the preview and the missing file", kept)
+		}
+		preview, err := os.Stat(generated[0])
+		if err != nil || preview.Size() == 0 {
+			t.Fatalf("preview %s was not written: %v", generated[0], err)
+		}
+	})
+
+	t.Run("WithoutBaseURLUploads", func(t *testing.T) {
+		useMediaLinkConfig(t, []string{"document", "image"}, 0, true, "")
+		content, kept, generated := ApplyMediaLinkPolicy("", attachments)
+		if content != "" || len(kept) != len(attachments) || len(generated) != 0 {
+			t.Fatalf("got %q, %v, %v; want everything uploaded without a base URL", content, kept, generated)
+		}
+	})
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		_ = os.Remove(path)
+	}
+}
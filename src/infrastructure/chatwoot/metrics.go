@@ -0,0 +1,25 @@
+package chatwoot
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/metrics"
+)
+
+// metricsTransport records the method, endpoint, status class and latency of every Chatwoot API
+// round trip. It sits below the rate limiter and circuit breaker, so it times Chatwoot itself.
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	status := 0
+	if err == nil {
+		status = resp.StatusCode
+	}
+	metrics.ObserveChatwootRequest(req.Method, req.URL.Path, status, time.Since(start))
+	return resp, err
+}
@@ -0,0 +1,107 @@
+package chatwoot
+
+import (
+	"fmt"
+	"strings"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+// MaxPollOptions is the most options WhatsApp allows in a poll.
+const MaxPollOptions = 12
+
+// PollCommand is a poll typed by an agent as "<prefix> Question | Option 1 | Option 2".
+type PollCommand struct {
+	Question string
+	Options  []string
+}
+
+// ParsePollCommand parses an agent message. ok is false when the message does not start with
+// prefix; err is set when it does but the poll cannot be sent.
+func ParsePollCommand(content, prefix string) (cmd PollCommand, ok bool, err error) {
+	rest, ok := cutCommandPrefix(content, prefix)
+	if !ok {
+		return cmd, false, nil
+	}
+
+	parts := strings.Split(rest, "|")
+	cmd.Question = strings.TrimSpace(parts[0])
+	if cmd.Question == "" {
+		return cmd, true, fmt.Errorf("the question is empty")
+	}
+
+	seen := make(map[string]struct{}, len(parts)-1)
+	for i, part := range parts[1:] {
+		option := strings.TrimSpace(part)
+		if option == "" {
+			return cmd, true, fmt.Errorf("option %d is empty", i+1)
+		}
+		if _, dup := seen[option]; dup {
+			return cmd, true, fmt.Errorf("option %q is listed twice", option)
+		}
+		seen[option] = struct{}{}
+		cmd.Options = append(cmd.Options, option)
+	}
+
+	switch {
+	case len(cmd.Options) < 2:
+		return cmd, true, fmt.Errorf("a poll needs at least 2 options, got %d", len(cmd.Options))
+	case len(cmd.Options) > MaxPollOptions:
+		return cmd, true, fmt.Errorf("WhatsApp allows at most %d options, got %d", MaxPollOptions, len(cmd.Options))
+	}
+	return cmd, true, nil
+}
+
+// FormatPoll renders a new poll for agents: the question, how many options may be picked and the
+// options themselves.
+func FormatPoll(question string, options []string, selectableCount int) string {
+	var b strings.Builder
+	b.WriteString("📊 Poll: ")
+	if question == "" {
+		question = "(no question)"
+	}
+	b.WriteString(question)
+	b.WriteString("\n")
+	b.WriteString(pollSelectionHint(selectableCount, len(options)))
+	for _, option := range options {
+		b.WriteString("\n- ")
+		b.WriteString(option)
+	}
+	return b.String()
+}
+
+// FormatPollTally renders the vote that was just cast followed by the current results. An empty
+// selected means the voter retracted their vote.
+func FormatPollTally(results *domainChatStorage.PollResults, voter string, selected []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Poll update: %s\n", results.Poll.Question)
+	if voter == "" {
+		voter = "Someone"
+	}
+	if len(selected) == 0 {
+		fmt.Fprintf(&b, "%s retracted their vote", voter)
+	} else {
+		fmt.Fprintf(&b, "%s voted: %s", voter, strings.Join(selected, ", "))
+	}
+
+	voters := "voters"
+	if results.Voters == 1 {
+		voters = "voter"
+	}
+	fmt.Fprintf(&b, "\n\nResults (%d %s):", results.Voters, voters)
+	for _, tally := range results.Tally {
+		fmt.Fprintf(&b, "\n- %s: %d", tally.Option, tally.Votes)
+	}
+	return b.String()
+}
+
+func pollSelectionHint(selectableCount, optionCount int) string {
+	switch {
+	case selectableCount == 1:
+		return "(choose one)"
+	case selectableCount > 1 && selectableCount < optionCount:
+		return fmt.Sprintf("(choose up to %d)", selectableCount)
+	default:
+		return "(choose any)"
+	}
+}
@@ -0,0 +1,88 @@
+package chatwoot
+
+import (
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func TestFormatPoll(t *testing.T) {
+	tests := []struct {
+		name       string
+		selectable int
+		want       string
+	}{
+		{"single choice", 1, "📊 Poll: Lunch?\n(choose one)\n- Pizza\n- Sushi\n- Salad"},
+		{"limited", 2, "📊 Poll: Lunch?\n(choose up to 2)\n- Pizza\n- Sushi\n- Salad"},
+		{"any", 0, "📊 Poll: Lunch?\n(choose any)\n- Pizza\n- Sushi\n- Salad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatPoll("Lunch?", []string{"Pizza", "Sushi", "Salad"}, tt.selectable); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPollTally(t *testing.T) {
+	results := &domainChatStorage.PollResults{
+		Poll:   domainChatStorage.Poll{Question: "Lunch?"},
+		Tally:  []domainChatStorage.PollOptionTally{{Option: "Pizza", Votes: 1}, {Option: "Sushi", Votes: 0}},
+		Voters: 1,
+	}
+
+	got := FormatPollTally(results, "Ana", []string{"Pizza"})
+	want := "📊 Poll update: Lunch?\nAna voted: Pizza\n\nResults (1 voter):\n- Pizza: 1\n- Sushi: 0"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	results.Tally[0].Votes, results.Voters = 0, 0
+	got = FormatPollTally(results, "Ana", nil)
+	want = "📊 Poll update: Lunch?\nAna retracted their vote\n\nResults (0 voters):\n- Pizza: 0\n- Sushi: 0"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestParsePollCommand(t *testing.T) {
+	thirteen := "/poll Pick one | 1 | 2 | 3 | 4 | 5 | 6 | 7 | 8 | 9 | 10 | 11 | 12 | 13"
+	tests := []struct {
+		name        string
+		content     string
+		wantOK      bool
+		wantErr     bool
+		wantOptions int
+	}{
+		{name: "poll", content: "/poll Which time works? | 9:00 | 14:00 | 17:00", wantOK: true, wantOptions: 3},
+		{name: "leading whitespace and case", content: "  /POLL Lunch?|Pizza|Sushi", wantOK: true, wantOptions: 2},
+		{name: "twelve options", content: "/poll Pick one | 1 | 2 | 3 | 4 | 5 | 6 | 7 | 8 | 9 | 10 | 11 | 12", wantOK: true, wantOptions: 12},
+		{name: "one option", content: "/poll Lunch? | Pizza", wantOK: true, wantErr: true},
+		{name: "no options", content: "/poll Lunch?", wantOK: true, wantErr: true},
+		{name: "thirteen options", content: thirteen, wantOK: true, wantErr: true},
+		{name: "empty option", content: "/poll Lunch? | Pizza | | Sushi", wantOK: true, wantErr: true},
+		{name: "duplicate option", content: "/poll Lunch? | Pizza | Pizza", wantOK: true, wantErr: true},
+		{name: "no question", content: "/poll | Pizza | Sushi", wantOK: true, wantErr: true},
+		{name: "prefix mid-message", content: "Reply with /poll Lunch? | Pizza | Sushi"},
+		{name: "longer word", content: "/polling Lunch? | Pizza | Sushi"},
+		{name: "plain text", content: "Pizza or sushi?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok, err := ParsePollCommand(tt.content, "/poll")
+			if ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Fatalf("got ok=%v err=%v, want ok=%v err=%v", ok, err, tt.wantOK, tt.wantErr)
+			}
+			if !tt.wantErr && len(cmd.Options) != tt.wantOptions {
+				t.Fatalf("expected %d options, got %v", tt.wantOptions, cmd.Options)
+			}
+		})
+	}
+
+	cmd, _, _ := ParsePollCommand("/poll Which time works? | 9:00 | 14:00", "/poll")
+	if cmd.Question != "Which time works?" || cmd.Options[0] != "9:00" || cmd.Options[1] != "14:00" {
+		t.Fatalf("unexpected poll %+v", cmd)
+	}
+}
@@ -0,0 +1,105 @@
+package chatwoot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+)
+
+// ForwardedMessageKey is the export record key of a message forwarded live as it arrived. History
+// sync and pushes key their records by a content hash instead, see messageKey.
+func ForwardedMessageKey(messageID string) string {
+	return forwardedKeyPrefix + messageID
+}
+
+const forwardedKeyPrefix = "wa:"
+
+// IsForwardedSourceID reports whether a Chatwoot message's source_id was set by the forwarder, i.e.
+// the message came from WhatsApp and must not be sent back.
+func IsForwardedSourceID(sourceID string) bool {
+	return strings.HasPrefix(sourceID, forwardedKeyPrefix)
+}
+
+var (
+	// ErrPushMessageNotFound is returned when the device has no stored message with the given ID.
+	ErrPushMessageNotFound = errors.New("message not found")
+	// ErrPushMessageAlreadyExported is returned when the message is already in Chatwoot and the
+	// push was not forced.
+	ErrPushMessageAlreadyExported = errors.New("message is already in Chatwoot")
+)
+
+// PushResult describes a message pushed to Chatwoot by PushMessage.
+type PushResult struct {
+	MessageID         string `json:"message_id"`
+	ChatJID           string `json:"chat_jid"`
+	ConversationID    int    `json:"conversation_id,omitempty"`
+	ChatwootMessageID int    `json:"chatwoot_message_id,omitempty"`
+	// PreviousChatwootMessageID is the Chatwoot message the stored message was mapped to before a
+	// forced push, or the one that blocked an unforced push.
+	PreviousChatwootMessageID int `json:"previous_chatwoot_message_id,omitempty"`
+}
+
+// PushMessage creates one stored WhatsApp message in its Chatwoot conversation the way history sync
+// does: timestamp prefix, media downloaded from the stored keys, and an export record. A message
+// that is already in Chatwoot is refused with ErrPushMessageAlreadyExported unless force is set.
+func (s *SyncService) PushMessage(ctx context.Context, deviceID, messageID string, waClient *whatsmeow.Client, opts SyncOptions, force bool) (*PushResult, error) {
+	msg, err := s.chatStorageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if msg == nil || msg.DeviceID != deviceID {
+		return nil, ErrPushMessageNotFound
+	}
+
+	result := &PushResult{MessageID: msg.ID, ChatJID: msg.ChatJID}
+	key := messageKey(deviceID, msg.ChatJID, msg)
+	for _, k := range []string{key, ForwardedMessageKey(msg.ID)} {
+		existing, err := s.chatStorageRepo.GetExportedChatwootMessageID(deviceID, msg.ChatJID, k)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check export records: %w", err)
+		}
+		if existing != 0 {
+			result.PreviousChatwootMessageID = existing
+			break
+		}
+	}
+	if result.PreviousChatwootMessageID != 0 && !force {
+		return result, ErrPushMessageAlreadyExported
+	}
+
+	chat, err := s.chatStorageRepo.GetChat(msg.ChatJID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat == nil {
+		chat = &domainChatStorage.Chat{DeviceID: deviceID, JID: msg.ChatJID}
+	}
+	conversationID, err := s.resolveConversation(chat, waClient)
+	if err != nil {
+		return nil, err
+	}
+	result.ConversationID = conversationID
+
+	isGroup := strings.HasSuffix(msg.ChatJID, "@g.us")
+	chatwootMsgID, err := s.syncMessageReturnID(ctx, conversationID, msg, waClient, opts, isGroup)
+	if err != nil {
+		return result, fmt.Errorf("failed to create Chatwoot message: %w", err)
+	}
+	result.ChatwootMessageID = chatwootMsgID
+
+	if err := s.chatStorageRepo.MarkMessageExported(deviceID, msg.ChatJID, key, chatwootMsgID); err != nil {
+		logrus.Warnf("Chatwoot Push: Failed to record export of message %s: %v", msg.ID, err)
+	}
+	if destination := chatDestination(msg.ChatJID); destination != "" {
+		s.clientFor(waClient).RememberConversationDestination(conversationID, destination)
+	}
+
+	logrus.Infof("Chatwoot Push: Pushed message %s of %s to conversation %d as message %d (previous: %d)",
+		msg.ID, msg.ChatJID, conversationID, chatwootMsgID, result.PreviousChatwootMessageID)
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package chatwoot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+type memoryPushRepo struct {
+	domainChatStorage.IChatStorageRepository
+	messages map[string]*domainChatStorage.Message
+	exported map[string]int
+}
+
+func (m *memoryPushRepo) GetMessageByID(id string) (*domainChatStorage.Message, error) {
+	return m.messages[id], nil
+}
+
+func (m *memoryPushRepo) GetChat(jid string) (*domainChatStorage.Chat, error) {
+	return &domainChatStorage.Chat{JID: jid, Name: "Customer"}, nil
+}
+
+func (m *memoryPushRepo) GetExportedChatwootMessageID(deviceID, chatJID, messageKey string) (int, error) {
+	return m.exported[deviceID+"|"+chatJID+"|"+messageKey], nil
+}
+
+func (m *memoryPushRepo) MarkMessageExported(deviceID, chatJID, messageKey string, chatwootMessageID int) error {
+	m.exported[deviceID+"|"+chatJID+"|"+messageKey] = chatwootMessageID
+	return nil
+}
+
+// pushTestServer answers with one existing contact and conversation and records created messages.
+func pushTestServer(t *testing.T) (*Client, func() []string) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		contents []string
+		nextID   = 100
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/1/")
+		switch {
+		case path == "contacts/search":
+			_, _ = w.Write([]byte(`{"payload":[{"id":5,"name":"Customer","phone_number":"+6281234567890","identifier":"6281234567890@s.whatsapp.net"}]}`))
+		case path == "contacts/5/conversations":
+			_, _ = w.Write([]byte(`{"payload":[{"id":9,"inbox_id":1,"status":"open"}]}`))
+		case r.Method == http.MethodPost && path == "conversations/9/messages":
+			var req struct {
+				Content string `json:"content"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			contents = append(contents, req.Content)
+			nextID++
+			id := nextID
+			mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	return c, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), contents...)
+	}
+}
+
+func TestPushMessage(t *testing.T) {
+	const device = "6280000000000@s.whatsapp.net"
+	const chat = "6281234567890@s.whatsapp.net"
+	msg := &domainChatStorage.Message{
+		ID:        "WAMSG1",
+		ChatJID:   chat,
+		DeviceID:  device,
+		Sender:    chat,
+		Content:   "where is my order?",
+		Timestamp: time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local),
+	}
+	repo := &memoryPushRepo{
+		messages: map[string]*domainChatStorage.Message{msg.ID: msg},
+		exported: make(map[string]int),
+	}
+	client, created := pushTestServer(t)
+	s := NewSyncService(client, repo)
+	ctx := context.Background()
+
+	if _, err := s.PushMessage(ctx, device, "MISSING", nil, DefaultSyncOptions(), false); !errors.Is(err, ErrPushMessageNotFound) {
+		t.Fatalf("unknown message: got %v, want ErrPushMessageNotFound", err)
+	}
+	if _, err := s.PushMessage(ctx, "other@s.whatsapp.net", msg.ID, nil, DefaultSyncOptions(), false); !errors.Is(err, ErrPushMessageNotFound) {
+		t.Fatalf("message of another device: got %v, want ErrPushMessageNotFound", err)
+	}
+
+	first, err := s.PushMessage(ctx, device, msg.ID, nil, DefaultSyncOptions(), false)
+	if err != nil {
+		t.Fatalf("first push failed: %v", err)
+	}
+	if first.ConversationID != 9 || first.ChatwootMessageID == 0 || first.PreviousChatwootMessageID != 0 {
+		t.Fatalf("unexpected first push result: %+v", first)
+	}
+	if got := created(); len(got) != 1 || got[0] != "[2024-05-01 09:30] where is my order?" {
+		t.Fatalf("created %q, want the message with its timestamp prefix", got)
+	}
+	if id := repo.exported[device+"|"+chat+"|"+messageKey(device, chat, msg)]; id != first.ChatwootMessageID {
+		t.Fatalf("export record has Chatwoot ID %d, want %d", id, first.ChatwootMessageID)
+	}
+
+	again, err := s.PushMessage(ctx, device, msg.ID, nil, DefaultSyncOptions(), false)
+	if !errors.Is(err, ErrPushMessageAlreadyExported) || again.PreviousChatwootMessageID != first.ChatwootMessageID {
+		t.Fatalf("second push: got %+v, %v; want ErrPushMessageAlreadyExported with the first ID", again, err)
+	}
+
+	forced, err := s.PushMessage(ctx, device, msg.ID, nil, DefaultSyncOptions(), true)
+	if err != nil {
+		t.Fatalf("forced push failed: %v", err)
+	}
+	if forced.PreviousChatwootMessageID != first.ChatwootMessageID || forced.ChatwootMessageID == first.ChatwootMessageID {
+		t.Fatalf("forced push: got %+v, want a new ID next to %d", forced, first.ChatwootMessageID)
+	}
+	if id := repo.exported[device+"|"+chat+"|"+messageKey(device, chat, msg)]; id != forced.ChatwootMessageID {
+		t.Fatalf("export record has Chatwoot ID %d after the forced push, want %d", id, forced.ChatwootMessageID)
+	}
+	if got := created(); len(got) != 2 {
+		t.Fatalf("created %d messages, want 2", len(got))
+	}
+}
+
+func TestPushMessage_RefusesLiveForwardedMessage(t *testing.T) {
+	const device = "6280000000000@s.whatsapp.net"
+	const chat = "6281234567890@s.whatsapp.net"
+	repo := &memoryPushRepo{
+		messages: map[string]*domainChatStorage.Message{
+			"WAMSG2": {ID: "WAMSG2", ChatJID: chat, DeviceID: device, Content: "hi", Timestamp: time.Now()},
+		},
+		exported: map[string]int{device + "|" + chat + "|" + ForwardedMessageKey("WAMSG2"): 77},
+	}
+	client, created := pushTestServer(t)
+
+	result, err := NewSyncService(client, repo).PushMessage(context.Background(), device, "WAMSG2", nil, DefaultSyncOptions(), false)
+	if !errors.Is(err, ErrPushMessageAlreadyExported) || result.PreviousChatwootMessageID != 77 {
+		t.Fatalf("got %+v, %v; want ErrPushMessageAlreadyExported with Chatwoot ID 77", result, err)
+	}
+	if len(created()) != 0 {
+		t.Fatal("refused push created a Chatwoot message")
+	}
+}
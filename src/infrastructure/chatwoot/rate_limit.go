@@ -0,0 +1,174 @@
+package chatwoot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// rateLimitMaxRetries is how often a request answered with 429 is sent again before the 429
+	// is returned to the caller.
+	rateLimitMaxRetries = 3
+	// rateLimitDefaultPause is the pause after a 429 without a usable Retry-After header.
+	rateLimitDefaultPause = 5 * time.Second
+	// rateLimitMaxPause caps the pause taken from a Retry-After header. Requests wait for the pause
+	// within their clientTimeout, so it stays well below it.
+	rateLimitMaxPause = clientTimeout / 2
+)
+
+// limiterClock is the time source of a rateLimiter, replaced by a fake clock in tests.
+type limiterClock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// rateLimiter is a token bucket shared by every request of a Client: it refills at rate tokens per
+// second up to burst, and a 429 from Chatwoot pauses it for everyone until the Retry-After time.
+type rateLimiter struct {
+	mu          sync.Mutex
+	clock       limiterClock
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	throttled   time.Duration // total time requests waited
+}
+
+// newRateLimiter returns a limiter allowing rate requests per second with bursts of burst. A rate
+// of 0 or less only applies the 429 pauses.
+func newRateLimiter(rate float64, burst int, clock limiterClock) *rateLimiter {
+	if clock == nil {
+		clock = realClock{}
+	}
+	burst = max(burst, 1)
+	return &rateLimiter{clock: clock, rate: rate, burst: float64(burst), tokens: float64(burst), last: clock.Now()}
+}
+
+// wait blocks until a request may be sent. It fails at once when the wait would outlast the deadline
+// of ctx, instead of letting the request time out while paused.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay <= 0 {
+			return nil
+		}
+		if !fitsDeadline(ctx, delay) {
+			return fmt.Errorf("chatwoot requests are rate limited for another %s", delay.Round(time.Second))
+		}
+		l.mu.Lock()
+		l.throttled += delay
+		l.mu.Unlock()
+		select {
+		case <-l.clock.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes a token and returns 0, or returns how long to wait before trying again.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.rate <= 0 {
+		return 0
+	}
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// pause holds every request for d, e.g. after a 429.
+func (l *rateLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := l.clock.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// throttledTime returns how long requests have waited on the limiter in total.
+func (l *rateLimiter) throttledTime() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.throttled
+}
+
+// retryAfter reads the Retry-After header of a 429, in seconds or as an HTTP date.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	d := rateLimitDefaultPause
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		d = time.Duration(secs) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		d = at.Sub(now)
+	}
+	return min(max(d, time.Second), rateLimitMaxPause)
+}
+
+// fitsDeadline reports whether waiting d leaves time before the deadline of ctx, if it has one.
+func fitsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > d
+}
+
+// rateLimitTransport sends every Chatwoot request through the limiter. A 429 pauses the limiter
+// for the Retry-After time and the request is sent again when its body can be replayed and the
+// pause ends before the request times out; otherwise the 429 is returned.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		pause := retryAfter(resp, t.limiter.clock.Now())
+		t.limiter.pause(pause)
+		if attempt >= rateLimitMaxRetries || (req.Body != nil && req.GetBody == nil) || !fitsDeadline(req.Context(), pause) {
+			return resp, nil
+		}
+		logrus.Warnf("Chatwoot: Rate limited on %s %s, pausing requests for %s", req.Method, req.URL.Path, pause)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		next := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			next.Body = body
+		}
+		req = next
+	}
+}
@@ -0,0 +1,191 @@
+package chatwoot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock moves forward by the requested duration instead of sleeping.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	l := newRateLimiter(2, 2, clock)
+	start := clock.Now()
+
+	for i := range 4 {
+		if err := l.wait(t.Context()); err != nil {
+			t.Fatalf("wait %d: %v", i, err)
+		}
+	}
+	// The burst of 2 goes out at once, the next two wait half a second each
+	if elapsed := clock.Now().Sub(start); elapsed != time.Second {
+		t.Errorf("4 requests at 2/s with a burst of 2 took %s, want 1s", elapsed)
+	}
+	if got := l.throttledTime(); got != time.Second {
+		t.Errorf("throttled %s, want 1s", got)
+	}
+}
+
+func TestRateLimitTransport_PausesAndRetriesOn429(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		first := len(bodies) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	limiter := newRateLimiter(0, 1, clock)
+	client := &http.Client{Transport: &rateLimitTransport{base: http.DefaultTransport, limiter: limiter}}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, bytes.NewBufferString(`{"content":"hi"}`))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want the retried request's 200", resp.StatusCode)
+	}
+	if len(bodies) != 2 || bodies[1] != `{"content":"hi"}` {
+		t.Fatalf("expected the body to be sent twice, got %q", bodies)
+	}
+	if got := limiter.throttledTime(); got != 7*time.Second {
+		t.Errorf("throttled %s, want the 7s from Retry-After", got)
+	}
+}
+
+func TestRateLimitTransport_GivesUpAfterRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	client := &http.Client{Transport: &rateLimitTransport{base: http.DefaultTransport, limiter: newRateLimiter(0, 1, clock)}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || calls != rateLimitMaxRetries+1 {
+		t.Errorf("got status %d after %d calls, want 429 after %d", resp.StatusCode, calls, rateLimitMaxRetries+1)
+	}
+}
+
+func TestNewTargetClient_PauseEndsBeforeTimeout(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := NewTargetClient(Target{URL: srv.URL, APIToken: "token", AccountID: 1})
+	if rateLimitMaxPause >= c.HTTPClient.Timeout {
+		t.Fatalf("pause cap %s does not end before the client timeout %s", rateLimitMaxPause, c.HTTPClient.Timeout)
+	}
+
+	// A pause that would outlast the request returns the 429 instead of a timeout
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	start := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected the 429, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || calls != 1 || time.Since(start) > time.Second {
+		t.Fatalf("got status %d after %d calls in %s, want one quick 429", resp.StatusCode, calls, time.Since(start))
+	}
+
+	// The next request fails at once while the pause lasts longer than its deadline
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := c.HTTPClient.Do(req); err == nil || calls != 1 || time.Since(start) > time.Second {
+		t.Fatalf("expected the paused request to fail without being sent, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"10", 10 * time.Second},
+		{now.Add(12 * time.Second).Format(http.TimeFormat), 12 * time.Second},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), rateLimitMaxPause},
+		{"", rateLimitDefaultPause},
+		{"soon", rateLimitDefaultPause},
+		{"0", time.Second},
+		{"86400", rateLimitMaxPause},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", tt.header)
+		if got := retryAfter(resp, now); got != tt.want {
+			t.Errorf("Retry-After %q: got %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestSyncProgress_ThrottledSinceStart(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	limiter := newRateLimiter(0, 1, clock)
+	s := NewSyncService(&Client{limiter: limiter}, nil)
+
+	limiter.pause(2 * time.Second)
+	_ = limiter.wait(t.Context())
+	progress := NewSyncProgress("device")
+	progress.throttleBase = s.client.ThrottledTime()
+
+	limiter.pause(1500 * time.Millisecond)
+	_ = limiter.wait(t.Context())
+	s.noteThrottle(progress)
+	if got := progress.Clone().ThrottledSeconds; got != 1.5 {
+		t.Errorf("throttled %v seconds, want 1.5 (waits before the sync excluded)", got)
+	}
+}
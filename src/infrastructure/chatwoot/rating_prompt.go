@@ -0,0 +1,69 @@
+package chatwoot
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+// RatingPromptEnabled reports whether conversations resolved in inboxID get the rating prompt of
+// CHATWOOT_RATING_PROMPT_INBOXES. Malformed entries are skipped with a warning.
+func RatingPromptEnabled(inboxID int) bool {
+	if inboxID <= 0 {
+		return false
+	}
+	var inboxes []int
+	for _, entry := range config.ChatwootRatingPromptInboxes {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, err := strconv.Atoi(entry)
+		if err != nil || id <= 0 {
+			logrus.Warnf("Chatwoot: ignoring invalid rating prompt inbox %q (expected an inbox ID)", entry)
+			continue
+		}
+		inboxes = append(inboxes, id)
+	}
+	return slices.Contains(inboxes, inboxID)
+}
+
+// RatingPromptMessage fills the {agent} and {link} placeholders of template. Lines left empty by a
+// missing value are dropped.
+func RatingPromptMessage(template, agent, link string) string {
+	text := strings.NewReplacer("{agent}", agent, "{link}", link).Replace(template)
+	var lines []string
+	for line := range strings.SplitSeq(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimRight(line, " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TrackRatingPrompt remembers that identifier was asked to rate a conversation, so a bare rating
+// received within ChatwootCSATReplyWindowHours is posted to it. It shares the pending survey of the
+// contact, without a survey UUID.
+func TrackRatingPrompt(identifier string, conversationID int) error {
+	repo := getCSATSurveyRepository()
+	if repo == nil || config.ChatwootCSATReplyWindowHours <= 0 || identifier == "" {
+		return nil
+	}
+
+	return repo.SavePendingCSATSurvey(&domainChatStorage.PendingCSATSurvey{
+		Identifier:     identifier,
+		ConversationID: conversationID,
+		ExpiresAt:      time.Now().Add(time.Duration(config.ChatwootCSATReplyWindowHours) * time.Hour),
+	})
+}
+
+// RatingNote is the private note posting a customer's answer to the rating prompt.
+func RatingNote(rating int) string {
+	return fmt.Sprintf("Customer rated this conversation %d/5 %s%s", rating, strings.Repeat("★", rating), strings.Repeat("☆", 5-rating))
+}
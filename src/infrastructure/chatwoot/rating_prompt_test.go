@@ -0,0 +1,68 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func TestRatingPromptEnabled(t *testing.T) {
+	prev := config.ChatwootRatingPromptInboxes
+	defer func() { config.ChatwootRatingPromptInboxes = prev }()
+
+	config.ChatwootRatingPromptInboxes = nil
+	if RatingPromptEnabled(12) {
+		t.Fatal("expected rating prompts to be disabled by default")
+	}
+	config.ChatwootRatingPromptInboxes = []string{"12", " 34 ", "sales"}
+	for inbox, want := range map[int]bool{12: true, 34: true, 56: false, 0: false} {
+		if got := RatingPromptEnabled(inbox); got != want {
+			t.Errorf("inbox %d: got %v, want %v", inbox, got, want)
+		}
+	}
+}
+
+func TestRatingPromptMessage(t *testing.T) {
+	template := "How would you rate your conversation with {agent}?\n{link}"
+	if got := RatingPromptMessage(template, "Ana", "https://cw.example.com/survey/responses/abc"); got != "How would you rate your conversation with Ana?\nhttps://cw.example.com/survey/responses/abc" {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := RatingPromptMessage(template, "us", ""); got != "How would you rate your conversation with us?" {
+		t.Errorf("expected the empty link line to be dropped, got %q", got)
+	}
+}
+
+func TestHandleCSATReply_PostsRatingPromptAnswerAsNote(t *testing.T) {
+	repo := useSurveyRepo(t)
+
+	var gotPath string
+	var gotNote struct {
+		Content string `json:"content"`
+		Private bool   `json:"private"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.Method + " " + r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotNote)
+		_, _ = w.Write([]byte(`{"id": 7}`))
+	}))
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+
+	if err := TrackRatingPrompt("5511999", 42); err != nil {
+		t.Fatalf("TrackRatingPrompt returned error: %v", err)
+	}
+	handled, err := c.HandleCSATReply("5511999", "4")
+	if !handled || err != nil {
+		t.Fatalf("expected the rating to be consumed, got handled=%v err=%v", handled, err)
+	}
+	if gotPath != "POST /api/v1/accounts/1/conversations/42/messages" || !gotNote.Private || gotNote.Content != RatingNote(4) {
+		t.Fatalf("expected a private note with the rating, got %s %+v", gotPath, gotNote)
+	}
+	if len(repo.surveys) != 0 {
+		t.Fatal("expected the rating prompt to be cleared")
+	}
+}
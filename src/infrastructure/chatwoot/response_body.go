@@ -0,0 +1,95 @@
+package chatwoot
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/sirupsen/logrus"
+)
+
+var reHTMLTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// ErrRateLimited matches the error of a Chatwoot request still answered with 429 after the
+// rate-limit retries.
+var ErrRateLimited = errors.New("chatwoot rate limit exceeded")
+
+// responseError builds the error for an unexpected Chatwoot response. The body is summarised by
+// describeResponseBody so proxy error pages do not end up in logs whole.
+func responseError(operation string, resp *http.Response, body []byte) error {
+	return &statusError{
+		msg:    fmt.Sprintf("%s: status %d body %s", operation, resp.StatusCode, describeResponseBody(resp, body)),
+		status: resp.StatusCode,
+	}
+}
+
+// statusError is an unexpected Chatwoot response; a 429 matches ErrRateLimited.
+type statusError struct {
+	msg    string
+	status int
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func (e *statusError) Is(target error) bool {
+	return target == ErrRateLimited && e.status == http.StatusTooManyRequests
+}
+
+// describeResponseBody returns a log-safe form of a response body. JSON and plain text are cut to
+// config.ChatwootErrorBodyLimit bytes; other content types (typically HTML pages from Cloudflare or a
+// reverse proxy) are reduced to their type, size and title. The full body is logged at Trace level.
+func describeResponseBody(resp *http.Response, body []byte) string {
+	if len(body) == 0 {
+		return "(empty)"
+	}
+	if logrus.IsLevelEnabled(logrus.TraceLevel) {
+		logrus.Tracef("Chatwoot: full response body (status %d, %d bytes): %s", resp.StatusCode, len(body), body)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(body)
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "text/plain":
+		return truncateBody(body, config.ChatwootErrorBodyLimit)
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		summary := fmt.Sprintf("HTML error page, %s", formatBodySize(len(body)))
+		if m := reHTMLTitle.FindSubmatch(body); m != nil {
+			if title := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " "); title != "" {
+				summary += ", title: " + truncateBody([]byte(title), 200)
+			}
+		}
+		return summary
+	default:
+		return fmt.Sprintf("%s response, %s", mediaType, formatBodySize(len(body)))
+	}
+}
+
+// truncateBody cuts body to limit bytes on a rune boundary and notes the original size.
+// limit <= 0 keeps the whole body.
+func truncateBody(body []byte, limit int) string {
+	if limit <= 0 || len(body) <= limit {
+		return string(body)
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (truncated, %s total)", body[:cut], formatBodySize(len(body)))
+}
+
+func formatBodySize(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%dKB", (n+512)/1024)
+}
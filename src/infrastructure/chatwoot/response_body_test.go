@@ -0,0 +1,92 @@
+package chatwoot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func cloudflarePage(size int) string {
+	head := "<!DOCTYPE html><html><head><title>Attention Required! | Cloudflare</title></head><body>"
+	return head + strings.Repeat("<div>blocked</div>", (size-len(head))/18) + "</body></html>"
+}
+
+func TestDescribeResponseBody(t *testing.T) {
+	original := config.ChatwootErrorBodyLimit
+	config.ChatwootErrorBodyLimit = 1024
+	t.Cleanup(func() { config.ChatwootErrorBodyLimit = original })
+
+	htmlResp := &http.Response{StatusCode: 403, Header: http.Header{"Content-Type": {"text/html; charset=UTF-8"}}}
+	if got := describeResponseBody(htmlResp, []byte(cloudflarePage(512*1024))); got != "HTML error page, 512KB, title: Attention Required! | Cloudflare" {
+		t.Fatalf("unexpected HTML summary %q", got)
+	}
+
+	jsonResp := &http.Response{StatusCode: 422, Header: http.Header{"Content-Type": {"application/json"}}}
+	got := describeResponseBody(jsonResp, []byte(`{"error":"`+strings.Repeat("é", 200*1024)+`"}`))
+	if len(got) > 1100 || !strings.HasPrefix(got, `{"error":"é`) || !strings.HasSuffix(got, "(truncated, 400KB total)") {
+		t.Fatalf("unexpected JSON summary (%d bytes): %.80q...%q", len(got), got, got[len(got)-30:])
+	}
+	if !strings.HasSuffix(strings.Split(got, "...")[0], "é") {
+		t.Fatal("expected truncation on a rune boundary")
+	}
+
+	small := `{"message":"not found"}`
+	if got := describeResponseBody(jsonResp, []byte(small)); got != small {
+		t.Fatalf("expected a small body to be kept, got %q", got)
+	}
+
+	sniffed := &http.Response{StatusCode: 502, Header: http.Header{}}
+	if got := describeResponseBody(sniffed, []byte(cloudflarePage(4096))); !strings.HasPrefix(got, "HTML error page, 4KB") {
+		t.Fatalf("expected HTML to be detected without a content type, got %q", got)
+	}
+
+	binary := &http.Response{StatusCode: 500, Header: http.Header{"Content-Type": {"application/octet-stream"}}}
+	if got := describeResponseBody(binary, make([]byte, 2048)); got != "application/octet-stream response, 2KB" {
+		t.Fatalf("unexpected binary summary %q", got)
+	}
+}
+
+func TestClientErrors_SummariseGiantBodies(t *testing.T) {
+	page := cloudflarePage(300 * 1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	attachment := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(attachment, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := map[string]func() error{
+		"doRequest": func() error { _, err := c.doRequest(http.MethodGet, srv.URL, nil, nil); return err },
+		"CreateContact": func() error {
+			_, err := c.CreateContact("Ana", "5511999999999", false)
+			return err
+		},
+		"CreateConversation": func() error { _, err := c.CreateConversation(1); return err },
+		"CreateMessage":      func() error { _, err := c.CreateMessage(1, "hi", "incoming", nil, "", "", time.Time{}); return err },
+		"multipart": func() error {
+			_, err := c.CreateMessage(1, "hi", "incoming", []string{attachment}, "", "", time.Time{})
+			return err
+		},
+	}
+	for name, call := range calls {
+		err := call()
+		if err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+		if len(err.Error()) > 300 || !strings.Contains(err.Error(), "HTML error page, 300KB, title: Attention Required! | Cloudflare") {
+			t.Fatalf("%s: error not summarised (%d bytes): %.200s", name, len(err.Error()), err.Error())
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package chatwoot
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrConfigLocked is returned by UpdateConfig when CHATWOOT_CONFIG_LOCKED keeps the env settings.
+	ErrConfigLocked = errors.New("the Chatwoot config is locked to the environment (CHATWOOT_CONFIG_LOCKED)")
+	// ErrInvalidConfig wraps the reason UpdateConfig refused a config.
+	ErrInvalidConfig = errors.New("invalid Chatwoot config")
+)
+
+// configMu serializes changes of the Chatwoot config, so the settings and the default client built
+// from them always match.
+var configMu sync.Mutex
+
+// runtimeConfig is the config loaded from storage or set with PUT /chatwoot/config, nil while the env
+// settings apply. A change publishes a new copy and never writes the config.Chatwoot* globals, so
+// requests read a consistent snapshot without locking.
+var runtimeConfig atomic.Pointer[domainChatStorage.ChatwootConfig]
+
+// CurrentConfig returns the Chatwoot settings in use, from the env or the last PUT /chatwoot/config.
+func CurrentConfig() domainChatStorage.ChatwootConfig {
+	if cfg := runtimeConfig.Load(); cfg != nil {
+		return *cfg
+	}
+	return domainChatStorage.ChatwootConfig{
+		URL:                     config.ChatwootURL,
+		APIToken:                config.ChatwootAPIToken,
+		AccountID:               config.ChatwootAccountID,
+		InboxID:                 config.ChatwootInboxID,
+		ImportMessages:          config.ChatwootImportMessages,
+		DaysLimitImportMessages: config.ChatwootDaysLimitImportMessages,
+		SyncIncludeMedia:        config.ChatwootSyncIncludeMedia,
+		SyncIncludeGroups:       config.ChatwootSyncIncludeGroups,
+		SyncAvatar:              config.ChatWootSyncAvatar,
+		TypingIndicator:         config.ChatWootEnableTypingIndicator,
+	}
+}
+
+// MaskToken hides an API token but for its last four characters.
+func MaskToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	if len(token) <= 8 {
+		return "****"
+	}
+	return "****" + token[len(token)-4:]
+}
+
+// ValidateConfig checks the fields of cfg, then that its token can read the account and, when set,
+// the inbox. Nothing is changed.
+func ValidateConfig(cfg domainChatStorage.ChatwootConfig) error {
+	parsed, err := url.Parse(strings.TrimSpace(cfg.URL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("url must be an http or https URL, got %q", cfg.URL)
+	}
+	if strings.TrimSpace(cfg.APIToken) == "" {
+		return fmt.Errorf("api_token is required")
+	}
+	if cfg.AccountID <= 0 {
+		return fmt.Errorf("account_id must be positive")
+	}
+	if cfg.InboxID < 0 {
+		return fmt.Errorf("inbox_id must not be negative")
+	}
+	if cfg.DaysLimitImportMessages < 0 {
+		return fmt.Errorf("days_limit_import_messages must not be negative")
+	}
+
+	client := NewTargetClient(Target{URL: strings.TrimSpace(cfg.URL), APIToken: strings.TrimSpace(cfg.APIToken), AccountID: cfg.AccountID, InboxID: cfg.InboxID})
+	if err := client.Ping(); err != nil {
+		return fmt.Errorf("chatwoot rejected the config: %w", err)
+	}
+	if cfg.InboxID > 0 {
+		if _, err := client.GetInbox(cfg.InboxID); err != nil {
+			return fmt.Errorf("chatwoot rejected the config: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyConfig makes cfg the settings in use and swaps in a default client built from them. Requests
+// already running finish on the old client. The caller holds configMu.
+func applyConfig(cfg domainChatStorage.ChatwootConfig) {
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	cfg.APIToken = strings.TrimSpace(cfg.APIToken)
+	runtimeConfig.Store(&cfg)
+
+	client := NewClient()
+	defaultClientMu.Lock()
+	defaultClient = client
+	defaultClientMu.Unlock()
+	if svc := GetDefaultSyncService(); svc != nil {
+		svc.setClient(client)
+	}
+}
+
+// LoadStoredConfig applies the config saved with PUT /chatwoot/config over the env settings, unless
+// CHATWOOT_CONFIG_LOCKED is set. It runs at startup, before the stored Chatwoot IDs are checked
+// against the account and inbox.
+func LoadStoredConfig(repo domainChatStorage.IChatStorageRepository) {
+	if repo == nil || config.ChatwootConfigLocked {
+		return
+	}
+	stored, err := repo.GetChatwootConfig()
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to read the stored config, using the env settings: %v", err)
+		return
+	}
+	if stored == nil {
+		return
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	applyConfig(*stored)
+	logrus.Infof("Chatwoot: Using the config saved at %s (account %d, inbox %d)", stored.UpdatedAt.Format("2006-01-02 15:04:05"), stored.AccountID, stored.InboxID)
+}
+
+// UpdateConfig validates cfg against Chatwoot, saves it and makes it the config in use. When the
+// account or inbox changed the stored Chatwoot IDs are dropped, as on a restart with new env
+// settings; the result reports whether that happened.
+func UpdateConfig(repo domainChatStorage.IChatStorageRepository, cfg domainChatStorage.ChatwootConfig) (bool, error) {
+	if config.ChatwootConfigLocked {
+		return false, ErrConfigLocked
+	}
+	cfg.URL = strings.TrimRight(strings.TrimSpace(cfg.URL), "/")
+	cfg.APIToken = strings.TrimSpace(cfg.APIToken)
+	if err := ValidateConfig(cfg); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if repo == nil {
+		return false, fmt.Errorf("chat storage is not available")
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	if err := repo.SaveChatwootConfig(&cfg); err != nil {
+		return false, fmt.Errorf("failed to save the Chatwoot config: %w", err)
+	}
+	applyConfig(cfg)
+	logrus.Infof("Chatwoot: Config updated (account %d, inbox %d)", cfg.AccountID, cfg.InboxID)
+
+	return CheckFingerprint(repo)
+}
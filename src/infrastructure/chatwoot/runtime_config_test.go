@@ -0,0 +1,141 @@
+package chatwoot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+type memoryConfigRepo struct {
+	memoryFingerprintRepo
+	stored *domainChatStorage.ChatwootConfig
+}
+
+func (m *memoryConfigRepo) GetChatwootConfig() (*domainChatStorage.ChatwootConfig, error) {
+	return m.stored, nil
+}
+
+func (m *memoryConfigRepo) SaveChatwootConfig(cfg *domainChatStorage.ChatwootConfig) error {
+	saved := *cfg
+	m.stored = &saved
+	return nil
+}
+
+// keepChatwootConfig restores the Chatwoot settings and the default client when the test ends.
+func keepChatwootConfig(t *testing.T) {
+	t.Helper()
+	prev, prevEnabled, prevLocked := runtimeConfig.Load(), config.ChatwootEnabled, config.ChatwootConfigLocked
+	t.Cleanup(func() {
+		config.ChatwootEnabled, config.ChatwootConfigLocked = prevEnabled, prevLocked
+		configMu.Lock()
+		runtimeConfig.Store(prev)
+		client := NewClient()
+		defaultClientMu.Lock()
+		defaultClient = client
+		defaultClientMu.Unlock()
+		configMu.Unlock()
+	})
+}
+
+// configServer is a Chatwoot that knows account 3 with inbox 7 and accepts only the token "good".
+func configServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("api_access_token") != "good":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/api/v1/accounts/3":
+			_, _ = w.Write([]byte(`{"id": 3}`))
+		case r.URL.Path == "/api/v1/accounts/3/inboxes/7":
+			_, _ = w.Write([]byte(`{"id": 7, "name": "WhatsApp"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestUpdateConfig_SwapsDefaultClient(t *testing.T) {
+	keepChatwootConfig(t)
+	url := configServer(t)
+	useChatwootConfig(t, "https://old.example.com", 1, 2, nil)
+	repo := &memoryConfigRepo{memoryFingerprintRepo: memoryFingerprintRepo{fingerprint: Fingerprint()}}
+	old := GetDefaultClient()
+
+	invalidated, err := UpdateConfig(repo, domainChatStorage.ChatwootConfig{URL: url + "/", APIToken: " good ", AccountID: 3, InboxID: 7, ImportMessages: true})
+	if err != nil {
+		t.Fatalf("UpdateConfig returned error: %v", err)
+	}
+	if !invalidated || repo.clears != 1 {
+		t.Errorf("expected the IDs of the old account dropped, got invalidated=%v clears=%d", invalidated, repo.clears)
+	}
+	if repo.stored == nil || repo.stored.URL != url || repo.stored.APIToken != "good" {
+		t.Errorf("expected the trimmed config saved, got %+v", repo.stored)
+	}
+	client := GetDefaultClient()
+	if client == old || client.BaseURL != url || client.AccountID != 3 || client.InboxID != 7 {
+		t.Errorf("expected a new default client for account 3, got %+v", client)
+	}
+	if !CurrentConfig().ImportMessages || config.ChatwootImportMessages {
+		t.Error("expected import_messages to be switched on, leaving the env setting alone")
+	}
+}
+
+func TestUpdateConfig_Rejected(t *testing.T) {
+	keepChatwootConfig(t)
+	url := configServer(t)
+	useChatwootConfig(t, "https://old.example.com", 1, 2, nil)
+	repo := &memoryConfigRepo{}
+	old := GetDefaultClient()
+
+	for name, cfg := range map[string]domainChatStorage.ChatwootConfig{
+		"bad url":     {URL: "chat.example.com", APIToken: "good", AccountID: 3},
+		"no account":  {URL: url, APIToken: "good"},
+		"bad token":   {URL: url, APIToken: "bad", AccountID: 3},
+		"wrong inbox": {URL: url, APIToken: "good", AccountID: 3, InboxID: 8},
+	} {
+		if _, err := UpdateConfig(repo, cfg); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", name, err)
+		}
+	}
+	if repo.stored != nil || GetDefaultClient() != old || CurrentConfig().URL != "https://old.example.com" {
+		t.Errorf("a rejected config must change nothing, stored %+v", repo.stored)
+	}
+
+	config.ChatwootConfigLocked = true
+	if _, err := UpdateConfig(repo, domainChatStorage.ChatwootConfig{URL: url, APIToken: "good", AccountID: 3}); !errors.Is(err, ErrConfigLocked) {
+		t.Errorf("expected ErrConfigLocked, got %v", err)
+	}
+}
+
+func TestLoadStoredConfig(t *testing.T) {
+	keepChatwootConfig(t)
+	useChatwootConfig(t, "https://env.example.com", 1, 2, nil)
+	repo := &memoryConfigRepo{stored: &domainChatStorage.ChatwootConfig{URL: "https://db.example.com", APIToken: "t", AccountID: 4, InboxID: 5}}
+
+	config.ChatwootConfigLocked = true
+	LoadStoredConfig(repo)
+	if got := CurrentConfig().URL; got != "https://env.example.com" {
+		t.Fatalf("a locked config must keep the env settings, got %s", got)
+	}
+
+	config.ChatwootConfigLocked = false
+	LoadStoredConfig(repo)
+	if cfg := CurrentConfig(); cfg.URL != "https://db.example.com" || cfg.InboxID != 5 || GetDefaultClient().AccountID != 4 {
+		t.Errorf("expected the stored config to win, got %s account %d inbox %d", cfg.URL, cfg.AccountID, cfg.InboxID)
+	}
+}
+
+func TestMaskToken(t *testing.T) {
+	for token, want := range map[string]string{"": "", "short": "****", "abcdefghijkl": "****ijkl"} {
+		if got := MaskToken(token); got != want || (token != "" && strings.Contains(got, token)) {
+			t.Errorf("MaskToken(%q) = %q, want %q", token, got, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package chatwoot
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StatusConversationLabel marks the conversation that collects the WhatsApp statuses (stories) of a
+// contact, apart from their chat.
+const StatusConversationLabel = "whatsapp-status"
+
+func isStatusConversation(conv Conversation) bool {
+	return slices.Contains(conv.Labels, StatusConversationLabel)
+}
+
+// FindOrCreateStatusConversation returns the open conversation labeled StatusConversationLabel of the
+// contact, creating and labeling one when there is none. It holds the lock FindOrCreateConversation
+// takes for the contact, so a chat message never picks up the new conversation before it is labeled.
+func (c *Client) FindOrCreateStatusConversation(contactID int) (*Conversation, error) {
+	unlock := getContactLocks().Lock(fmt.Sprintf("conversation:%d", contactID), "FindOrCreateStatusConversation")
+	defer unlock()
+
+	conversations, err := c.listOpenConversations(contactID)
+	if err != nil {
+		return nil, err
+	}
+	for _, conv := range conversations {
+		if isStatusConversation(conv) {
+			return &conv, nil
+		}
+	}
+
+	conv, err := c.CreateConversation(contactID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.AddConversationLabel(conv.ID, StatusConversationLabel); err != nil {
+		// Unlabeled, the conversation would take the contact's chat messages as well
+		if resolveErr := c.ResolveConversation(conv.ID); resolveErr != nil {
+			logrus.Warnf("Chatwoot: Failed to resolve unlabeled status conversation %d: %v", conv.ID, resolveErr)
+		}
+		return nil, fmt.Errorf("failed to label status conversation %d: %w", conv.ID, err)
+	}
+	conv.Labels = append(conv.Labels, StatusConversationLabel)
+	return conv, nil
+}
@@ -0,0 +1,44 @@
+package chatwoot
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindOrCreateStatusConversation_KeepsStatusesApartFromTheChat(t *testing.T) {
+	s := &conversationServer{convs: []map[string]any{{"id": 40, "inbox_id": 1, "status": "open"}}}
+	c := newConversationTestClient(t, s)
+	t.Cleanup(func() { c.ForgetContactConversation(812) })
+
+	status, err := c.FindOrCreateStatusConversation(812)
+	if err != nil {
+		t.Fatalf("FindOrCreateStatusConversation returned error: %v", err)
+	}
+	if status.ID == 40 || !slices.Contains(s.conversation("conversations/51")["labels"].([]string), StatusConversationLabel) {
+		t.Fatalf("expected a new conversation labeled %s, got %+v (server %+v)", StatusConversationLabel, status, s.convs)
+	}
+
+	again, err := c.FindOrCreateStatusConversation(812)
+	if err != nil || again.ID != status.ID || s.creates != 1 {
+		t.Fatalf("expected the status conversation reused, got %+v, err %v, %d creates", again, err, s.creates)
+	}
+
+	chat, err := c.FindOrCreateConversation(812)
+	if err != nil || chat.ID != 40 {
+		t.Fatalf("expected chat messages to stay in conversation 40, got %+v, err %v", chat, err)
+	}
+}
+
+func TestFindOrCreateConversation_IgnoresTheStatusConversation(t *testing.T) {
+	s := &conversationServer{convs: []map[string]any{{"id": 30, "inbox_id": 1, "status": "open", "labels": []string{StatusConversationLabel}}}}
+	c := newConversationTestClient(t, s)
+	t.Cleanup(func() { c.ForgetContactConversation(813) })
+
+	chat, err := c.FindOrCreateConversation(813)
+	if err != nil {
+		t.Fatalf("FindOrCreateConversation returned error: %v", err)
+	}
+	if chat.ID == 30 || s.creates != 1 || len(s.resolved) != 0 {
+		t.Fatalf("expected a chat conversation of its own, got %+v, %d creates, resolved %v", chat, s.creates, s.resolved)
+	}
+}
@@ -0,0 +1,132 @@
+package chatwoot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/image/webp"
+)
+
+func isWebPAttachment(filePath string) bool {
+	if strings.EqualFold(filepath.Ext(filePath), ".webp") {
+		return true
+	}
+	mimeType, err := detectContentType(filePath)
+	return err == nil && canonicalizeMimeType(mimeType) == "image/webp"
+}
+
+// isAnimatedWebP reads the WebP header: animated files use the extended format (VP8X chunk) with
+// the animation flag set.
+func isAnimatedWebP(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 21)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false, fmt.Errorf("file too short for a WebP header: %w", err)
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return false, fmt.Errorf("not a WebP file")
+	}
+	return string(header[12:16]) == "VP8X" && header[20]&0x02 != 0, nil
+}
+
+// convertWebPToPNG decodes a static WebP sticker and writes it as PNG, keeping transparency.
+func convertWebPToPNG(sourcePath string) (string, error) {
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read webp: %w", err)
+	}
+	img, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode webp: %w", err)
+	}
+
+	tmpFile, err := createTempFile("sticker-*.png")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for png: %w", err)
+	}
+	defer tmpFile.Close()
+
+	if err := png.Encode(tmpFile, img); err != nil {
+		removeTempFile(tmpFile.Name())
+		return "", fmt.Errorf("failed to encode png: %w", err)
+	}
+	return tmpFile.Name(), nil
+}
+
+// transcodeAnimatedWebPToGIF converts an animated sticker to a looping GIF with ffmpeg. Decoding
+// animated WebP needs a recent ffmpeg (7.1 or later).
+func transcodeAnimatedWebPToGIF(sourcePath string) (string, error) {
+	if !utils.DetectFFmpeg().Available {
+		return "", fmt.Errorf("ffmpeg not found in PATH")
+	}
+
+	targetPath, err := createTempPath("sticker-*.gif")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for gif: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(
+		ctx,
+		"ffmpeg",
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", sourcePath,
+		"-vf", "split[a][b];[a]palettegen=reserve_transparent=1[p];[b][p]paletteuse",
+		"-loop", "0",
+		targetPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		removeTempFile(targetPath)
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("ffmpeg timeout while transcoding %s", sourcePath)
+		}
+		if len(output) > 0 {
+			return "", fmt.Errorf("ffmpeg failed: %s", strings.TrimSpace(string(output)))
+		}
+		return "", fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return targetPath, nil
+}
+
+// prepareImageForUpload converts WebP stickers, which Chatwoot often shows as a broken attachment,
+// to PNG (static) or GIF (animated). The original file is uploaded when the conversion fails.
+func prepareImageForUpload(filePath string) (string, func()) {
+	animated, err := isAnimatedWebP(filePath)
+	if err != nil {
+		logrus.Warnf("Chatwoot: sticker conversion skipped for %s: %v. Uploading original file", filePath, err)
+		return filePath, func() {}
+	}
+
+	convert := convertWebPToPNG
+	if animated {
+		convert = transcodeAnimatedWebPToGIF
+	}
+	convertedPath, err := convert(filePath)
+	if err != nil {
+		logrus.Warnf("Chatwoot: sticker conversion failed for %s: %v. Uploading original file", filePath, err)
+		return filePath, func() {}
+	}
+
+	return convertedPath, tempFileCleanup(convertedPath)
+}
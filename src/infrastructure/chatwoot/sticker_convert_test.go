@@ -0,0 +1,127 @@
+package chatwoot
+
+import (
+	"encoding/base64"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// staticWebP is a 1x1 lossless WebP image.
+const staticWebP = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func webpHeader(chunk string, flags byte) []byte {
+	header := []byte("RIFF\x00\x00\x00\x00WEBP" + chunk + "\x0a\x00\x00\x00")
+	return append(header, flags, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+func TestIsWebPAttachment(t *testing.T) {
+	raw, _ := base64.StdEncoding.DecodeString(staticWebP)
+	tests := []struct {
+		name     string
+		filePath string
+		expected bool
+	}{
+		{name: "webp extension", filePath: writeTestFile(t, "sticker.webp", []byte("x")), expected: true},
+		{name: "webp content without extension", filePath: writeTestFile(t, "sticker", raw), expected: true},
+		{name: "png", filePath: writeTestFile(t, "photo.png", []byte("\x89PNG\r\n\x1a\n")), expected: false},
+	}
+
+	for _, tt := range tests {
+		got := isWebPAttachment(tt.filePath)
+		if got != tt.expected {
+			t.Errorf("%s: isWebPAttachment = %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestIsAnimatedWebP(t *testing.T) {
+	raw, _ := base64.StdEncoding.DecodeString(staticWebP)
+	tests := []struct {
+		name     string
+		data     []byte
+		expected bool
+		wantErr  bool
+	}{
+		{name: "lossless static", data: raw, expected: false},
+		{name: "extended with animation flag", data: webpHeader("VP8X", 0x02), expected: true},
+		{name: "extended with alpha only", data: webpHeader("VP8X", 0x10), expected: false},
+		{name: "not webp", data: []byte("GIF89a...................."), wantErr: true},
+		{name: "truncated", data: []byte("RIFF"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := isAnimatedWebP(writeTestFile(t, "sticker.webp", tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: isAnimatedWebP = %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestPrepareAttachmentForUpload_StaticStickerBecomesPNG(t *testing.T) {
+	raw, _ := base64.StdEncoding.DecodeString(staticWebP)
+	source := writeTestFile(t, "sticker.webp", raw)
+
+	uploadPath, cleanup := prepareAttachmentForUpload(source)
+	if filepath.Ext(uploadPath) != ".png" {
+		t.Fatalf("expected a .png upload, got %s", uploadPath)
+	}
+	if mimeType := normalizeAttachmentMimeType(uploadPath, "image/png"); mimeType != "image/png" {
+		t.Errorf("expected image/png, got %q", mimeType)
+	}
+
+	file, err := os.Open(uploadPath)
+	if err != nil {
+		t.Fatalf("converted file missing: %v", err)
+	}
+	img, err := png.Decode(file)
+	file.Close()
+	if err != nil || img.Bounds().Dx() != 1 {
+		t.Fatalf("expected a 1x1 png, got %v (err %v)", img, err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(uploadPath); !os.IsNotExist(err) {
+		t.Errorf("expected the converted file to be removed, stat err %v", err)
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Errorf("the original sticker must be kept: %v", err)
+	}
+}
+
+func TestPrepareAttachmentForUpload_FallsBackToOriginal(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "corrupt static", data: webpHeader("VP8 ", 0)},
+		// Without ffmpeg, or with one that cannot decode this stub, the original is uploaded.
+		{name: "animated", data: webpHeader("VP8X", 0x02)},
+	}
+
+	for _, tt := range tests {
+		source := writeTestFile(t, "sticker.webp", tt.data)
+		uploadPath, cleanup := prepareAttachmentForUpload(source)
+		cleanup()
+		if uploadPath != source {
+			t.Errorf("%s: expected the original file, got %s", tt.name, uploadPath)
+		}
+		if _, err := os.Stat(source); err != nil {
+			t.Errorf("%s: cleanup removed the original: %v", tt.name, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package chatwoot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/metrics"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"go.mau.fi/whatsmeow"
@@ -26,11 +28,18 @@ import (
 // SyncService handles message history synchronization to Chatwoot
 type SyncService struct {
 	client          *Client
+	clientMu        sync.RWMutex // guards client, replaced when the Chatwoot config changes
 	chatStorageRepo domainChatStorage.IChatStorageRepository
 
 	// Track sync progress per device
 	progressMap map[string]*SyncProgress
+	chatSyncMap map[string]*SyncProgress // single-chat syncs, keyed by chatSyncKey
+	backfillMap map[string]*MediaBackfillProgress
 	progressMu  sync.RWMutex
+	progressHub progressHub // streams progress of full syncs
+
+	// syncAvatar replaces SyncContactAvatarSmart for the avatar sync started after each chat; tests set it
+	syncAvatar func(ctx context.Context, contactJID, contactName string, waClient *whatsmeow.Client, force bool) error
 }
 
 // NewSyncService creates a new sync service instance
@@ -42,7 +51,133 @@ func NewSyncService(
 		client:          client,
 		chatStorageRepo: chatStorageRepo,
 		progressMap:     make(map[string]*SyncProgress),
+		chatSyncMap:     make(map[string]*SyncProgress),
+		backfillMap:     make(map[string]*MediaBackfillProgress),
+	}
+}
+
+// startAvatarSync syncs the avatar of a chat in the background, once its messages were synced.
+func (s *SyncService) startAvatarSync(chatJID, contactName string, waClient *whatsmeow.Client) {
+	syncAvatar := s.syncAvatar
+	if syncAvatar == nil {
+		syncAvatar = s.SyncContactAvatarSmart
+	}
+	go func() {
+		_ = syncAvatar(context.Background(), chatJID, contactName, waClient, false)
+	}()
+}
+
+// clientFor returns the Chatwoot client of the device behind waClient: the one of its target when the
+// client registry routes the device, else the client the service was created with.
+func (s *SyncService) clientFor(waClient *whatsmeow.Client) *Client {
+	if waClient != nil && waClient.Store != nil && waClient.Store.ID != nil {
+		if client, ok := GetClientRegistry().RoutedClient(waClient.Store.ID.ToNonAD().String()); ok {
+			return client
+		}
+	}
+	return s.baseClient()
+}
+
+// baseClient returns the client of devices the registry does not route.
+func (s *SyncService) baseClient() *Client {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	return s.client
+}
+
+// setClient replaces the client of devices the registry does not route.
+func (s *SyncService) setClient(client *Client) {
+	s.clientMu.Lock()
+	s.client = client
+	s.clientMu.Unlock()
+}
+
+// GroupNameResolver returns the current subject of a group, or "" when it is unknown.
+type GroupNameResolver func(client *whatsmeow.Client, groupJID string) string
+
+var (
+	groupNameResolverMu sync.RWMutex
+	groupNameResolver   GroupNameResolver
+)
+
+// SetGroupNameResolver sets how history sync names group contacts. It is wired to the WhatsApp
+// group info service so sync shares its cache instead of fetching group info again.
+func SetGroupNameResolver(fn GroupNameResolver) {
+	groupNameResolverMu.Lock()
+	defer groupNameResolverMu.Unlock()
+	groupNameResolver = fn
+}
+
+func resolveGroupName(client *whatsmeow.Client, groupJID string) string {
+	groupNameResolverMu.RLock()
+	fn := groupNameResolver
+	groupNameResolverMu.RUnlock()
+	if fn == nil {
+		return ""
+	}
+	return fn(client, groupJID)
+}
+
+// NewsletterNameResolver returns the name of a WhatsApp Channel, or "" when it is unknown.
+type NewsletterNameResolver func(client *whatsmeow.Client, newsletterJID string) string
+
+var (
+	newsletterNameResolverMu sync.RWMutex
+	newsletterNameResolver   NewsletterNameResolver
+)
+
+// SetNewsletterNameResolver sets how history sync names channel contacts. It is wired to the
+// WhatsApp newsletter metadata so sync shares the names live forwards use.
+func SetNewsletterNameResolver(fn NewsletterNameResolver) {
+	newsletterNameResolverMu.Lock()
+	defer newsletterNameResolverMu.Unlock()
+	newsletterNameResolver = fn
+}
+
+func resolveNewsletterName(client *whatsmeow.Client, newsletterJID string) string {
+	newsletterNameResolverMu.RLock()
+	fn := newsletterNameResolver
+	newsletterNameResolverMu.RUnlock()
+	if fn == nil {
+		return ""
+	}
+	return fn(client, newsletterJID)
+}
+
+// fallbackContactName is the contact name of a chat whose name is unknown: its phone number, or
+// the JID of chats without one, like channels.
+func fallbackContactName(jid string) string {
+	if phone := utils.ExtractPhoneFromJID(jid); phone != "" {
+		return phone
+	}
+	return jid
+}
+
+// SenderNameResolver returns the name shown for the sender of a group message, falling back to
+// pushName and then the phone number.
+type SenderNameResolver func(client *whatsmeow.Client, senderJID, pushName string) string
+
+var (
+	senderNameResolverMu sync.RWMutex
+	senderNameResolver   SenderNameResolver
+)
+
+// SetSenderNameResolver sets how history sync names the senders of group messages. It is wired to
+// the WhatsApp contact store so imported messages show the same names as live ones.
+func SetSenderNameResolver(fn SenderNameResolver) {
+	senderNameResolverMu.Lock()
+	defer senderNameResolverMu.Unlock()
+	senderNameResolver = fn
+}
+
+func resolveSenderName(client *whatsmeow.Client, senderJID string) string {
+	senderNameResolverMu.RLock()
+	fn := senderNameResolver
+	senderNameResolverMu.RUnlock()
+	if fn == nil {
+		return fallbackContactName(senderJID)
 	}
+	return fn(client, senderJID, "")
 }
 
 // GetProgress returns the current sync progress for a device
@@ -57,15 +192,39 @@ func (s *SyncService) GetProgress(deviceID string) *SyncProgress {
 	return nil
 }
 
-// IsRunning returns true if a sync is currently running for the device
+// ErrSyncNotRunning is returned by Cancel when no history sync is running for the device.
+var ErrSyncNotRunning = errors.New("no history sync is running")
+
+// syncCancelWait is how long Cancel waits for the sync to stop.
+const syncCancelWait = 15 * time.Second
+
+// Cancel stops the running history sync of a device and waits up to syncCancelWait for it to
+// stop. Messages already sent stay exported, so a later sync resumes where this one stopped.
+func (s *SyncService) Cancel(deviceID string) error {
+	s.progressMu.RLock()
+	progress, ok := s.progressMap[deviceID]
+	s.progressMu.RUnlock()
+	if !ok || !progress.requestCancel() {
+		return ErrSyncNotRunning
+	}
+
+	select {
+	case <-progress.done:
+	case <-time.After(syncCancelWait):
+		logrus.Warnf("Chatwoot Sync: Sync for device %s is still stopping after %s", deviceID, syncCancelWait)
+	}
+	return nil
+}
+
+// IsRunning returns true if a sync of the device, or of one of its chats, is currently running
 func (s *SyncService) IsRunning(deviceID string) bool {
 	s.progressMu.RLock()
 	defer s.progressMu.RUnlock()
 
-	if progress, ok := s.progressMap[deviceID]; ok {
-		return progress.IsRunning()
+	if progress, ok := s.progressMap[deviceID]; ok && progress.IsRunning() {
+		return true
 	}
-	return false
+	return s.chatSyncRunningLocked(deviceID)
 }
 
 func messageKey(deviceID, chatJID string, msg *domainChatStorage.Message) string {
@@ -91,8 +250,8 @@ func isStatusBroadcastChatJID(chatJID string) bool {
 	return normalized == "status@broadcast" || strings.HasPrefix(normalized, "status@")
 }
 
-// SyncHistory performs the initial message history sync to Chatwoot
-func (s *SyncService) SyncHistory(ctx context.Context, deviceID string, waClient *whatsmeow.Client, opts SyncOptions) (*SyncProgress, error) {
+// normalizeSyncOptions replaces out-of-range options with their defaults
+func normalizeSyncOptions(opts SyncOptions) SyncOptions {
 	if opts.MaxMessagesPerChat <= 0 {
 		opts.MaxMessagesPerChat = DefaultSyncOptions().MaxMessagesPerChat
 	}
@@ -105,22 +264,56 @@ func (s *SyncService) SyncHistory(ctx context.Context, deviceID string, waClient
 	if opts.MaxMediaFileSize < 0 {
 		opts.MaxMediaFileSize = 0
 	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultSyncOptions().Concurrency
+	}
+	opts.Concurrency = min(opts.Concurrency, MaxSyncConcurrency)
+	return opts
+}
+
+// SyncHistory performs the initial message history sync to Chatwoot
+func (s *SyncService) SyncHistory(ctx context.Context, deviceID string, waClient *whatsmeow.Client, opts SyncOptions) (*SyncProgress, error) {
+	opts = normalizeSyncOptions(opts)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// Atomic check-and-set to prevent race condition
 	progress := NewSyncProgress(deviceID)
-	s.progressMu.Lock()
-	if existing, ok := s.progressMap[deviceID]; ok && existing.IsRunning() {
+	progress.cancel = cancel
+	progress.DryRun = opts.DryRun
+	progress.throttleClient = s.clientFor(waClient)
+	progress.throttleBase = progress.throttleClient.ThrottledTime()
+	defer close(progress.done)
+	if opts.DryRun {
+		// A dry run writes nothing, so it may run next to a real sync and is neither tracked nor recorded
+		progress.SetRunning()
+	} else {
+		progress.notify = func(snapshot *SyncProgress) { s.progressHub.publish(deviceID, snapshot) }
+		s.progressMu.Lock()
+		if existing, ok := s.progressMap[deviceID]; ok && existing.IsRunning() {
+			s.progressMu.Unlock()
+			cloned := existing.Clone()
+			return &cloned, fmt.Errorf("%w for device %s", ErrSyncAlreadyRunning, deviceID)
+		}
+		if s.chatSyncRunningLocked(deviceID) {
+			s.progressMu.Unlock()
+			return nil, fmt.Errorf("%w for a chat of device %s", ErrSyncAlreadyRunning, deviceID)
+		}
+		s.progressMap[deviceID] = progress
+		progress.SetRunning()
 		s.progressMu.Unlock()
-		cloned := existing.Clone()
-		return &cloned, fmt.Errorf("sync already in progress for device %s", deviceID)
+		defer s.trackSyncRun(progress)() // Saves the final state before waiters are released
 	}
-	s.progressMap[deviceID] = progress
-	s.progressMu.Unlock()
 
-	progress.SetRunning()
+	logrus.Infof("Chatwoot Sync: Starting history sync for device %s (days: %d, media: %v, groups: %v, status: %v, max_media_bytes: %d, concurrency: %d, dry_run: %v, contacts_only: %v, include: %v, exclude: %v)",
+		deviceID, opts.DaysLimit, opts.IncludeMedia, opts.IncludeGroups, opts.IncludeStatus, opts.MaxMediaFileSize, opts.Concurrency, opts.DryRun,
+		opts.ContactsOnly, opts.IncludeJIDs, opts.ExcludeJIDs)
 
-	logrus.Infof("Chatwoot Sync: Starting history sync for device %s (days: %d, media: %v, groups: %v, status: %v, max_media_bytes: %d)",
-		deviceID, opts.DaysLimit, opts.IncludeMedia, opts.IncludeGroups, opts.IncludeStatus, opts.MaxMediaFileSize)
+	if !opts.DryRun {
+		// A Chatwoot upgraded since the last run may accept backdated messages now
+		progress.throttleClient.ProbeCreatedAt()
+	}
 
 	// 1. Get all chats for this device
 	chats, err := s.chatStorageRepo.GetChats(&domainChatStorage.ChatFilter{
@@ -132,6 +325,7 @@ func (s *SyncService) SyncHistory(ctx context.Context, deviceID string, waClient
 	}
 
 	filteredChats := make([]*domainChatStorage.Chat, 0, len(chats))
+	skipped := 0
 	for _, chat := range chats {
 		if chat == nil {
 			continue
@@ -139,41 +333,88 @@ func (s *SyncService) SyncHistory(ctx context.Context, deviceID string, waClient
 		if !opts.IncludeStatus && isStatusBroadcastChatJID(chat.JID) {
 			continue
 		}
+		if config.WhatsappSkipNewsletters && utils.IsNewsletterJID(chat.JID) {
+			continue
+		}
 		if strings.HasSuffix(chat.JID, "@g.us") && !opts.IncludeGroups {
 			continue
 		}
+		if !chatSelected(chat.JID, opts) {
+			skipped++
+			continue
+		}
 		filteredChats = append(filteredChats, chat)
 	}
 	chats = filteredChats
 
 	progress.SetTotals(len(chats), 0)
-	logrus.Infof("Chatwoot Sync: Found %d chats to sync", len(chats))
+	progress.SetSkippedChats(skipped)
+	logrus.Infof("Chatwoot Sync: Found %d chats to sync, %d skipped by the include/exclude lists", len(chats), skipped)
 
 	// 2. Calculate time boundary
 	sinceTime := time.Now().AddDate(0, 0, -opts.DaysLimit)
 
-	// 3. Process each chat
+	// 3. Process chats with a pool of workers; each chat is synced by one worker, so its messages
+	// stay in order
+	queue := make(chan *domainChatStorage.Chat)
+	var wg sync.WaitGroup
+	for range min(opts.Concurrency, len(chats)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chat := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				progress.UpdateChat(chat.JID)
+				err := s.syncChat(ctx, deviceID, chat, sinceTime, waClient, opts, progress)
+				progress.FinishChat(chat.JID)
+				s.noteThrottle(progress)
+				if ctx.Err() != nil {
+					continue // Stopped part-way, neither synced nor failed
+				}
+				if err != nil {
+					logrus.Errorf("Chatwoot Sync: Failed to sync chat %s: %v", chat.JID, err)
+					progress.IncrementFailedChats()
+					// Continue with other chats
+				} else {
+					progress.IncrementSyncedChats()
+				}
+			}
+		}()
+	}
 	for _, chat := range chats {
-		if err := ctx.Err(); err != nil {
-			progress.SetFailed(err)
-			return progress, err // Context cancelled
+		if ctx.Err() != nil {
+			break
 		}
-
-		progress.UpdateChat(chat.JID)
-
-		err := s.syncChat(ctx, deviceID, chat, sinceTime, waClient, opts, progress)
-		if err != nil {
-			logrus.Errorf("Chatwoot Sync: Failed to sync chat %s: %v", chat.JID, err)
-			progress.IncrementFailedChats()
-			// Continue with other chats
-		} else {
-			progress.IncrementSyncedChats()
+		queue <- chat
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		if progress.wasCancelled() {
+			progress.SetCancelled()
+			logrus.Infof("Chatwoot Sync: Cancelled for device %s", deviceID)
+			return progress, err
 		}
+		progress.SetFailed(err)
+		return progress, err // Context cancelled
 	}
 
 	progress.SetCompleted()
+	done := progress.Clone()
+	if done.DryRun {
+		estimate := done.Estimate
+		if estimate == nil {
+			estimate = &SyncEstimate{}
+		}
+		logrus.Infof("Chatwoot Sync: Dry run for device %s would create %d contacts and export %d messages with %d media files (%d bytes)",
+			deviceID, estimate.ContactsToCreate, estimate.Messages, estimate.MediaMessages, estimate.MediaBytes)
+		return progress, nil
+	}
 	logrus.Infof("Chatwoot Sync: Completed for device %s. Chats: %d (failed: %d), Messages: %d (failed: %d)",
-		deviceID, progress.SyncedChats, progress.FailedChats, progress.SyncedMessages, progress.FailedMessages)
+		deviceID, done.SyncedChats, done.FailedChats, done.SyncedMessages, done.FailedMessages)
 
 	return progress, nil
 }
@@ -195,55 +436,52 @@ func (s *SyncService) syncChat(
 	if isGroup && !opts.IncludeGroups {
 		return nil
 	}
+	switch ChatEphemeralMode(chat.EphemeralExpiration) {
+	case EphemeralSkip:
+		logrus.Debugf("Chatwoot Sync: Skipping chat %s, it has disappearing messages", chat.JID)
+		return nil
+	case EphemeralRedact:
+		opts.redactContent = true
+	}
 
-	contactName := chat.Name
-	if contactName == "" {
-		contactName = utils.ExtractPhoneFromJID(chat.JID)
+	contactName := chatContactName(chat, waClient)
+	if opts.DryRun {
+		return s.estimateChat(ctx, s.clientFor(waClient), deviceID, chat, contactName, sinceTime, opts, progress)
 	}
 
-	contact, err := s.client.FindOrCreateContact(contactName, chat.JID, isGroup)
+	contact, err := s.chatContact(ctx, contactName, chat.JID, isGroup, waClient)
 	if err != nil {
 		return fmt.Errorf("failed to find/create contact: %w", err)
 	}
 
-	conversation, err := s.client.FindOrCreateConversation(contact.ID)
+	cw := s.clientFor(waClient)
+	conversation, err := cw.FindOrCreateConversation(contact.ID)
 	if err != nil {
 		return fmt.Errorf("failed to find/create conversation: %w", err)
 	}
-
-	state, err := s.chatStorageRepo.GetChatExportState(deviceID, chat.JID)
-	if err != nil {
-		return fmt.Errorf("failed to get export state: %w", err)
-	}
-
-	start := sinceTime
-	if state != nil && state.LastExportedAt.After(start) {
-		start = state.LastExportedAt
+	cw.StoreConversationMetadata(conversation, NewConversationMetadata(deviceID, chat.JID, chat.EphemeralExpiration))
+	if opts.ContactsOnly {
+		s.startAvatarSync(chat.JID, contactName, waClient)
+		return nil
 	}
 
-	messages, err := s.chatStorageRepo.GetMessages(&domainChatStorage.MessageFilter{
-		DeviceID:  deviceID,
-		ChatJID:   chat.JID,
-		StartTime: &start,
-		Limit:     opts.MaxMessagesPerChat,
-	})
+	_, messages, err := s.messagesToSync(deviceID, chat.JID, sinceTime, opts)
 	if err != nil {
-		return fmt.Errorf("failed to get messages: %w", err)
+		return err
 	}
 	if len(messages) == 0 {
 		return nil
 	}
 
 	progress.AddMessages(len(messages))
-
-	sort.Slice(messages, func(i, j int) bool {
-		return messages[i].Timestamp.Before(messages[j].Timestamp)
-	})
+	// Failed messages are kept for a retry, so a failed download fails the message instead of
+	// exporting a placeholder
+	opts.failOnMediaError = true
 
 	var lastExported time.Time
 	for i, msg := range messages {
-		if err := ctx.Err(); err != nil {
-			return err
+		if ctx.Err() != nil {
+			break
 		}
 
 		key := messageKey(deviceID, chat.JID, msg)
@@ -257,20 +495,26 @@ func (s *SyncService) syncChat(
 			continue
 		}
 
-		chatwootMsgID, err := s.syncMessageReturnID(ctx, conversation.ID, msg, waClient, opts, isGroup, key)
+		chatwootMsgID, err := s.syncMessageReturnID(ctx, conversation.ID, msg, waClient, opts, isGroup)
 		if err != nil {
-			progress.IncrementFailedMessages()
+			s.recordFailedExport(ctx, deviceID, chat.JID, msg, key, err, progress)
 			continue
 		}
 
 		_ = s.chatStorageRepo.MarkMessageExported(deviceID, chat.JID, key, chatwootMsgID)
+		progress.IncrementSyncedMessages()
+		s.noteThrottle(progress)
 		lastExported = msg.Timestamp
 
 		if i > 0 && i%opts.BatchSize == 0 {
-			time.Sleep(opts.DelayBetweenBatches)
+			select {
+			case <-time.After(opts.DelayBetweenBatches):
+			case <-ctx.Done():
+			}
 		}
 	}
 
+	// Written on cancellation too, so the next sync resumes after the last exported message
 	if !lastExported.IsZero() {
 		st := &domainChatStorage.ChatExportState{
 			DeviceID:       deviceID,
@@ -279,13 +523,72 @@ func (s *SyncService) syncChat(
 		}
 		_ = s.chatStorageRepo.UpsertChatExportState(st)
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	go func() {
-		_ = s.SyncContactAvatarSmart(context.Background(), chat.JID, contactName, waClient)
-	}()
+	s.startAvatarSync(chat.JID, contactName, waClient)
 
 	return nil
 }
+
+// chatContactName returns the name of the Chatwoot contact of a chat: the group subject for groups,
+// the channel name for channels, else the stored chat name or the phone number.
+func chatContactName(chat *domainChatStorage.Chat, waClient *whatsmeow.Client) string {
+	switch {
+	case strings.HasSuffix(chat.JID, "@g.us"):
+		if name := resolveGroupName(waClient, chat.JID); name != "" {
+			return name
+		}
+	case utils.IsNewsletterJID(chat.JID):
+		if name := resolveNewsletterName(waClient, chat.JID); name != "" {
+			return name
+		}
+	}
+	if chat.Name != "" {
+		return chat.Name
+	}
+	return fallbackContactName(chat.JID)
+}
+
+// noteThrottle copies the rate-limit wait since the sync started into its progress.
+func (s *SyncService) noteThrottle(progress *SyncProgress) {
+	client := progress.throttleClient
+	if client == nil {
+		client = s.baseClient()
+	}
+	progress.SetThrottled(client.ThrottledTime() - progress.throttleBase)
+}
+
+// messagesToSync returns the chat's messages after sinceTime and after its last export, oldest
+// first, together with its export state (nil when the chat was never synced).
+func (s *SyncService) messagesToSync(deviceID, chatJID string, sinceTime time.Time, opts SyncOptions) (*domainChatStorage.ChatExportState, []*domainChatStorage.Message, error) {
+	state, err := s.chatStorageRepo.GetChatExportState(deviceID, chatJID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get export state: %w", err)
+	}
+
+	start := sinceTime
+	if state != nil && state.LastExportedAt.After(start) {
+		start = state.LastExportedAt
+	}
+
+	messages, err := s.chatStorageRepo.GetMessages(&domainChatStorage.MessageFilter{
+		DeviceID:  deviceID,
+		ChatJID:   chatJID,
+		StartTime: &start,
+		Limit:     opts.MaxMessagesPerChat,
+	})
+	if err != nil {
+		return state, nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+	return state, messages, nil
+}
+
 func (s *SyncService) syncMessageReturnID(
 	ctx context.Context,
 	conversationID int,
@@ -293,50 +596,72 @@ func (s *SyncService) syncMessageReturnID(
 	waClient *whatsmeow.Client,
 	opts SyncOptions,
 	isGroup bool,
-	sourceID string,
 ) (int, error) {
 	messageType := "incoming"
 	if msg.IsFromMe {
 		messageType = "outgoing"
 	}
 
-	content := msg.Content
-	if content == "" && msg.MediaType != "" {
-		content = fmt.Sprintf("[%s]", msg.MediaType)
+	content := ReplaceGeoURIs(msg.Content)
+	viewOnceMode := ViewOnceFull
+	if msg.IsViewOnce && msg.MediaType != "" {
+		viewOnceMode = ViewOnceMode()
+	}
+	if opts.redactContent {
+		content = EphemeralPlaceholder
+		opts.IncludeMedia = false
+	} else if viewOnceMode != ViewOnceFull {
+		content = ViewOnceContent(msg.MediaType, content)
+	} else if content == "" && msg.MediaType != "" {
+		content = RenderMessage(MessageMediaPlaceholder, MessageData{MediaType: msg.MediaType, Timestamp: MessageTime(msg.Timestamp)})
 	}
 
-	timePrefix := msg.Timestamp.Format("2006-01-02 15:04")
+	sender := ""
 	if isGroup && !msg.IsFromMe && msg.Sender != "" {
-		senderName := utils.ExtractPhoneFromJID(msg.Sender)
-		content = fmt.Sprintf("[%s] %s: %s", timePrefix, senderName, content)
-	} else {
-		content = fmt.Sprintf("[%s] %s", timePrefix, content)
+		sender = resolveSenderName(waClient, msg.Sender)
 	}
 
 	var attachments []string
-	if opts.IncludeMedia && msg.MediaType != "" && msg.URL != "" && len(msg.MediaKey) > 0 {
+	withMedia := viewOnceMode == ViewOnceFull || (viewOnceMode == ViewOnceBlur && (msg.MediaType == "image" || msg.MediaType == "video"))
+	if withMedia && opts.IncludeMedia && msg.MediaType != "" && msg.URL != "" && len(msg.MediaKey) > 0 {
 		if opts.MaxMediaFileSize > 0 && msg.FileLength > uint64(opts.MaxMediaFileSize) {
-			content += fmt.Sprintf(" [media skipped: file too large (%d bytes)]", msg.FileLength)
+			content = RenderMessage(MessageMediaTooLarge, MessageData{Content: content, MediaType: msg.MediaType, FileSize: int64(msg.FileLength), Timestamp: MessageTime(msg.Timestamp)})
 		} else {
 			fp, err := s.downloadMedia(ctx, msg, waClient)
+			if err != nil && opts.failOnMediaError && !mediaGone(err) {
+				return 0, &exportError{reason: ExportFailureDownload, err: err}
+			}
+			if err == nil && fp != "" && viewOnceMode == ViewOnceBlur {
+				preview, blurErr := BlurViewOnceMedia(fp, msg.MediaType == "video")
+				removeTempFile(fp)
+				fp, err = preview, blurErr
+			}
 			if err == nil && fp != "" {
+				if msg.MediaType == "video" && viewOnceMode == ViewOnceFull {
+					fp = keepLargeVideoForLink(fp)
+				}
 				attachments = append(attachments, fp)
-			} else {
-				content += " [media unavailable]"
+			} else if viewOnceMode == ViewOnceFull {
+				content = RenderMessage(MessageMediaUnavailable, MessageData{Content: content, MediaType: msg.MediaType, Timestamp: MessageTime(msg.Timestamp)})
 			}
 		}
 	}
-	chatwootMsgID, err := s.client.CreateMessage(conversationID, content, messageType, attachments, sourceID, "")
+	if viewOnceMode == ViewOnceFull {
+		// Frames of large videos end up in attachments and are removed below; the linked videos stay.
+		content, attachments, _ = ApplyLargeVideoPolicy(content, attachments)
+	}
+	cw := s.clientFor(waClient)
+	chatwootMsgID, err := cw.CreateMessageFromRequest(conversationID, historyMessage(msg.Timestamp, sender, content, messageType), attachments, ForwardedMessageKey(msg.ID))
 
 	for _, fp := range attachments {
-		_ = os.Remove(fp)
+		removeTempFile(fp)
 	}
 
 	if err != nil {
 		return 0, err
 	}
 
-	MarkMessageAsSent(chatwootMsgID)
+	cw.MarkMessageAsSent(chatwootMsgID)
 	return chatwootMsgID, nil
 }
 
@@ -350,6 +675,10 @@ func (s *SyncService) downloadMedia(ctx context.Context, msg *domainChatStorage.
 		return "", fmt.Errorf("WhatsApp client not available")
 	}
 
+	if limit := config.ChatwootSyncMaxMediaFileSize; limit > 0 && msg.FileLength > uint64(limit) {
+		return "", fmt.Errorf("%w: %d bytes", errMediaTooLarge, msg.FileLength)
+	}
+
 	// Create downloadable message based on type
 	var downloadable whatsmeow.DownloadableMessage
 
@@ -398,31 +727,60 @@ func (s *SyncService) downloadMedia(ctx context.Context, msg *domainChatStorage.
 		return "", fmt.Errorf("unsupported media type: %s", msg.MediaType)
 	}
 
-	downloadCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
-	defer cancel()
-
-	// Download
-	data, err := waClient.Download(downloadCtx, downloadable)
+	release, err := acquireMediaDownloadSlot(ctx)
 	if err != nil {
-		return "", fmt.Errorf("download failed: %w", err)
+		return "", err
 	}
+	defer release()
 
-	// Write to temp file
+	downloadCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	// Stream straight into the temp file so large media is never held in memory
 	ext := getExtensionForMediaType(msg.MediaType, msg.Filename)
-	tmpFile, err := os.CreateTemp("", fmt.Sprintf("chatwoot-sync-*%s", ext))
+	tmpFile, err := createTempFile("sync-*" + ext)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file: %w", err)
 	}
 	defer tmpFile.Close()
 
-	if _, err := tmpFile.Write(data); err != nil {
-		os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("failed to write media: %w", err)
+	if err := downloadMediaToFile(downloadCtx, waClient, downloadable, tmpFile); err != nil {
+		removeTempFile(tmpFile.Name())
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	if info, err := tmpFile.Stat(); err == nil {
+		metrics.SyncMediaDownloaded(info.Size())
 	}
 
 	return tmpFile.Name(), nil
 }
 
+// errMediaTooLarge is returned by downloadMedia for media over CHATWOOT_SYNC_MAX_MEDIA_FILE_SIZE.
+var errMediaTooLarge = errors.New("media exceeds the sync size limit")
+
+// downloadMediaToFile is replaced in tests so media can be "downloaded" without WhatsApp.
+var downloadMediaToFile = func(ctx context.Context, waClient *whatsmeow.Client, downloadable whatsmeow.DownloadableMessage, file *os.File) error {
+	return waClient.DownloadToFile(ctx, downloadable, file)
+}
+
+// mediaDownloadSlots bounds how many media files are downloaded at once across all syncs.
+var (
+	mediaDownloadSlots     chan struct{}
+	mediaDownloadSlotsOnce sync.Once
+)
+
+func acquireMediaDownloadSlot(ctx context.Context) (func(), error) {
+	mediaDownloadSlotsOnce.Do(func() {
+		mediaDownloadSlots = make(chan struct{}, max(config.ChatwootSyncMaxConcurrentDownloads, 1))
+	})
+	select {
+	case mediaDownloadSlots <- struct{}{}:
+		return func() { <-mediaDownloadSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // Global sync service instance for REST endpoints
 var (
 	globalSyncService     *SyncService
@@ -444,20 +802,34 @@ func GetSyncService(
 func GetDefaultSyncService() *SyncService {
 	return globalSyncService
 }
+
+// chatEphemeralExpiration returns the disappearing-message timer stored for a chat, 0 when it is off
+// or the chat is not stored.
+func (s *SyncService) chatEphemeralExpiration(deviceID, chatJID string) uint32 {
+	chat, err := s.chatStorageRepo.GetChatByDevice(deviceID, chatJID)
+	if err != nil || chat == nil {
+		return 0
+	}
+	return chat.EphemeralExpiration
+}
+
 func (s *SyncService) Reconcile(ctx context.Context, deviceID, chatID string, since time.Time, waClient *whatsmeow.Client) error {
 	isGroup := strings.HasSuffix(chatID, "@g.us")
-	contactName := utils.ExtractPhoneFromJID(chatID)
+	contactName := fallbackContactName(chatID)
+
+	cw := s.clientFor(waClient)
 
 	// 1. Acha o contato e a conversa corretamente
-	contact, err := s.client.FindOrCreateContact(contactName, chatID, isGroup)
+	contact, err := s.chatContact(ctx, contactName, chatID, isGroup, waClient)
 	if err != nil {
 		return err
 	}
 
-	conversation, err := s.client.FindOrCreateConversation(contact.ID)
+	conversation, err := cw.FindOrCreateConversation(contact.ID)
 	if err != nil {
 		return err
 	}
+	cw.StoreConversationMetadata(conversation, NewConversationMetadata(deviceID, chatID, s.chatEphemeralExpiration(deviceID, chatID)))
 
 	// 2. Pega mensagens do BD (Gowa) formatando o filtro do jeito certo
 	waMsgs, err := s.chatStorageRepo.GetMessages(&domainChatStorage.MessageFilter{
@@ -470,14 +842,18 @@ func (s *SyncService) Reconcile(ctx context.Context, deviceID, chatID string, si
 		return err
 	}
 
+	// Messages are created with the WhatsApp message ID as source_id; those synced before carry a
+	// content hash, which still counts as the same message.
 	want := make(map[string]*domainChatStorage.Message, len(waMsgs))
+	legacySources := make(map[string]string, len(waMsgs))
 	for _, m := range waMsgs {
-		id := messageKey(deviceID, chatID, m)
-		want[id] = m
+		src := ForwardedMessageKey(m.ID)
+		want[src] = m
+		legacySources[messageKey(deviceID, chatID, m)] = src
 	}
 
-	// 3. Pega mensagens do Chatwoot usando a função nova
-	cwMsgs, err := s.client.GetConversationMessages(conversation.ID)
+	// 3. Pega mensagens do Chatwoot da mesma janela
+	cwMsgs, err := cw.GetConversationMessages(conversation.ID, since)
 	if err != nil {
 		return err
 	}
@@ -485,16 +861,30 @@ func (s *SyncService) Reconcile(ctx context.Context, deviceID, chatID string, si
 	existing := make(map[string]int)
 	for _, m := range cwMsgs {
 		if m.SourceID != "" {
-			existing[m.SourceID] = m.ID
+			src := m.SourceID
+			if current, ok := legacySources[src]; ok {
+				src = current
+			}
+			existing[src] = m.ID
 		}
 	}
 
 	// 4. Deleção do que sumiu no WhatsApp
+	caps := cw.Capabilities()
+	keptOrphans := 0
 	for src, msgID := range existing {
-		if _, ok := want[src]; !ok {
-			_ = s.client.DeleteMessage(conversation.ID, msgID)
-			logrus.Infof("Chatwoot Sync: Deleted orphaned message %d", msgID)
+		if _, ok := want[src]; ok {
+			continue
+		}
+		if !caps.SupportsMessageDelete {
+			keptOrphans++
+			continue
 		}
+		_ = cw.DeleteMessage(conversation.ID, msgID)
+		logrus.Infof("Chatwoot Sync: Deleted orphaned message %d", msgID)
+	}
+	if keptOrphans > 0 {
+		logrus.Warnf("Chatwoot Sync: Kept %d orphaned message(s) in conversation %d, Chatwoot %s cannot delete messages", keptOrphans, conversation.ID, caps.Version)
 	}
 
 	// 5. Criação do que tá faltando no Chatwoot
@@ -510,15 +900,12 @@ func (s *SyncService) Reconcile(ctx context.Context, deviceID, chatID string, si
 
 		content := waMsg.Content
 		if content == "" && waMsg.MediaType != "" {
-			content = fmt.Sprintf("[%s]", waMsg.MediaType)
+			content = RenderMessage(MessageMediaPlaceholder, MessageData{MediaType: waMsg.MediaType, Timestamp: MessageTime(waMsg.Timestamp)})
 		}
 
-		timePrefix := waMsg.Timestamp.Format("2006-01-02 15:04")
+		sender := ""
 		if isGroup && !waMsg.IsFromMe && waMsg.Sender != "" {
-			senderName := utils.ExtractPhoneFromJID(waMsg.Sender)
-			content = fmt.Sprintf("[%s] %s: %s", timePrefix, senderName, content)
-		} else {
-			content = fmt.Sprintf("[%s] %s", timePrefix, content)
+			sender = resolveSenderName(waClient, waMsg.Sender)
 		}
 
 		var attachments []string
@@ -530,13 +917,13 @@ func (s *SyncService) Reconcile(ctx context.Context, deviceID, chatID string, si
 		}
 
 		// Cria a mensagem enviando o sourceID
-		_, err := s.client.CreateMessage(conversation.ID, content, messageType, attachments, src, "")
+		_, err := cw.CreateMessageFromRequest(conversation.ID, historyMessage(waMsg.Timestamp, sender, content, messageType), attachments, src)
 		if err != nil {
 			logrus.Errorf("Chatwoot Sync: Failed to create missing message: %v", err)
 		}
 
 		for _, fp := range attachments {
-			_ = os.Remove(fp)
+			removeTempFile(fp)
 		}
 	}
 
@@ -548,12 +935,13 @@ func (s *SyncService) SyncContactAvatar(ctx context.Context, contactJID string,
 	if waClient == nil {
 		return fmt.Errorf("whatsapp client is nil")
 	}
+	cw := s.clientFor(waClient)
 
 	// 1. Busca/Cria o contato no Chatwoot para garantir que temos o ID
 	// Usamos o JID como nome temporário se não tivermos outro, a função FindOrCreate lida com a busca
 	isGroup := strings.HasSuffix(contactJID, "@g.us")
-	name := utils.ExtractPhoneFromJID(contactJID) // Ou busque o nome real se tiver disponível
-	contact, err := s.client.FindOrCreateContact(name, contactJID, isGroup)
+	name := fallbackContactName(contactJID) // Ou busque o nome real se tiver disponível
+	contact, err := cw.FindOrCreateContact(name, contactJID, isGroup)
 	if err != nil {
 		return fmt.Errorf("failed to find/create contact: %w", err)
 	}
@@ -564,7 +952,7 @@ func (s *SyncService) SyncContactAvatar(ctx context.Context, contactJID string,
 		attrs := map[string]interface{}{
 			"waha_whatsapp_jid": contactJID,
 		}
-		if err := s.client.UpdateContactAttributes(contact.ID, contactJID, attrs, isGroup); err != nil {
+		if err := cw.UpdateContactAttributes(contact.ID, contactJID, attrs, isGroup); err != nil {
 			logrus.Warnf("Chatwoot Sync: Failed to update contact attributes for %s: %v", contactJID, err)
 			// Não retorna erro fatal, tenta atualizar a foto mesmo assim
 		} else {
@@ -605,7 +993,7 @@ func (s *SyncService) SyncContactAvatar(ctx context.Context, contactJID string,
 	}
 
 	// 5. Envia para o Chatwoot
-	if err := s.client.UpdateContactAvatar(contact.ID, imgData); err != nil {
+	if err := cw.UpdateContactAvatar(contact.ID, imgData); err != nil {
 		return fmt.Errorf("failed to update chatwoot avatar: %w", err)
 	}
 
@@ -615,12 +1003,12 @@ func (s *SyncService) SyncContactAvatar(ctx context.Context, contactJID string,
 
 // TriggerAutoSync is called when a device connects to optionally start auto-sync
 func TriggerAutoSync(deviceID string, chatStorageRepo domainChatStorage.IChatStorageRepository, waClient *whatsmeow.Client) {
-	if !config.ChatwootEnabled || !config.ChatwootImportMessages {
+	cfg := CurrentConfig()
+	if !config.ChatwootEnabled || !cfg.ImportMessages {
 		return
 	}
 
-	client := GetDefaultClient()
-	if !client.IsConfigured() {
+	if !ClientForDevice(deviceID).IsConfigured() {
 		logrus.Warn("Chatwoot Sync: Auto-sync skipped - Chatwoot not configured")
 		return
 	}
@@ -634,18 +1022,19 @@ func TriggerAutoSync(deviceID string, chatStorageRepo domainChatStorage.IChatSto
 		}
 	}
 
-	syncService := GetSyncService(client, chatStorageRepo)
+	syncService := GetSyncService(GetDefaultClient(), chatStorageRepo)
 
 	go func() {
 		opts := DefaultSyncOptions()
-		opts.DaysLimit = config.ChatwootDaysLimitImportMessages
-		opts.IncludeMedia = config.ChatwootSyncIncludeMedia
-		opts.IncludeGroups = config.ChatwootSyncIncludeGroups
+		opts.DaysLimit = cfg.DaysLimitImportMessages
+		opts.IncludeMedia = cfg.SyncIncludeMedia
+		opts.IncludeGroups = cfg.SyncIncludeGroups
 		opts.IncludeStatus = config.ChatwootSyncIncludeStatus
 		opts.MaxMessagesPerChat = config.ChatwootSyncMaxMessagesPerChat
 		opts.BatchSize = config.ChatwootSyncBatchSize
 		opts.DelayBetweenBatches = time.Duration(config.ChatwootSyncDelayMs) * time.Millisecond
 		opts.MaxMediaFileSize = config.ChatwootSyncMaxMediaFileSize
+		opts.Concurrency = config.ChatwootSyncConcurrency
 
 		logrus.Infof("Chatwoot Sync: Auto-sync triggered for device %s", storageDeviceID)
 
@@ -0,0 +1,122 @@
+package chatwoot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+)
+
+var (
+	// ErrSyncAlreadyRunning is returned when a sync would overlap another sync of the same device.
+	ErrSyncAlreadyRunning = errors.New("a history sync is already running")
+	// ErrSyncChatNotFound is returned by SyncSingleChat when the device has no stored chat with the JID.
+	ErrSyncChatNotFound = errors.New("chat not found")
+)
+
+func chatSyncKey(deviceID, chatJID string) string {
+	return deviceID + "|" + chatJID
+}
+
+// chatSyncRunningLocked reports whether a single-chat sync of the device is running. The caller
+// holds progressMu.
+func (s *SyncService) chatSyncRunningLocked(deviceID string) bool {
+	prefix := chatSyncKey(deviceID, "")
+	for key, progress := range s.chatSyncMap {
+		if strings.HasPrefix(key, prefix) && progress.IsRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+// RunningSyncForChat returns the progress of the sync that keeps a sync of the chat from starting:
+// a full sync of the device or another sync of the same chat. It returns nil when neither runs, so
+// chats of the same device can sync side by side.
+func (s *SyncService) RunningSyncForChat(deviceID, chatJID string) *SyncProgress {
+	s.progressMu.RLock()
+	defer s.progressMu.RUnlock()
+
+	for _, progress := range []*SyncProgress{s.progressMap[deviceID], s.chatSyncMap[chatSyncKey(deviceID, chatJID)]} {
+		if progress != nil && progress.IsRunning() {
+			cloned := progress.Clone()
+			return &cloned
+		}
+	}
+	return nil
+}
+
+// GetChatSyncProgress returns the progress of the running single-chat sync of a chat. A finished
+// sync is forgotten, its outcome is returned by SyncSingleChat and logged.
+func (s *SyncService) GetChatSyncProgress(deviceID, chatJID string) *SyncProgress {
+	s.progressMu.RLock()
+	defer s.progressMu.RUnlock()
+
+	if progress, ok := s.chatSyncMap[chatSyncKey(deviceID, chatJID)]; ok {
+		cloned := progress.Clone()
+		return &cloned
+	}
+	return nil
+}
+
+// SyncSingleChat syncs the history of one chat the way SyncHistory syncs every chat of the device.
+// Messages already exported are skipped, so it never duplicates what an earlier sync sent. It
+// refuses to run alongside a full sync of the device or another sync of the same chat.
+func (s *SyncService) SyncSingleChat(ctx context.Context, deviceID, chatJID string, waClient *whatsmeow.Client, opts SyncOptions) (*SyncProgress, error) {
+	opts = normalizeSyncOptions(opts)
+
+	chat, err := s.chatStorageRepo.GetChatByDevice(deviceID, chatJID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat == nil {
+		return nil, ErrSyncChatNotFound
+	}
+
+	key := chatSyncKey(deviceID, chatJID)
+	progress := NewSyncProgress(deviceID)
+	s.progressMu.Lock()
+	if existing, ok := s.progressMap[deviceID]; ok && existing.IsRunning() {
+		s.progressMu.Unlock()
+		return nil, fmt.Errorf("%w for device %s", ErrSyncAlreadyRunning, deviceID)
+	}
+	if existing, ok := s.chatSyncMap[key]; ok && existing.IsRunning() {
+		s.progressMu.Unlock()
+		cloned := existing.Clone()
+		return &cloned, fmt.Errorf("%w for chat %s", ErrSyncAlreadyRunning, chatJID)
+	}
+	s.chatSyncMap[key] = progress
+	progress.SetRunning()
+	s.progressMu.Unlock()
+	defer func() {
+		s.progressMu.Lock()
+		if s.chatSyncMap[key] == progress {
+			delete(s.chatSyncMap, key)
+		}
+		s.progressMu.Unlock()
+	}()
+	defer close(progress.done)
+
+	logrus.Infof("Chatwoot Sync: Starting sync of chat %s for device %s (days: %d, media: %v)", chatJID, deviceID, opts.DaysLimit, opts.IncludeMedia)
+
+	progress.SetTotals(1, 0)
+	progress.UpdateChat(chatJID)
+	err = s.syncChat(ctx, deviceID, chat, time.Now().AddDate(0, 0, -opts.DaysLimit), waClient, opts, progress)
+	progress.FinishChat(chatJID)
+	if err != nil {
+		progress.IncrementFailedChats()
+		progress.SetFailed(err)
+		return progress, fmt.Errorf("failed to sync chat %s: %w", chatJID, err)
+	}
+
+	progress.IncrementSyncedChats()
+	progress.SetCompleted()
+	done := progress.Clone()
+	logrus.Infof("Chatwoot Sync: Completed sync of chat %s for device %s. Messages: %d (failed: %d)",
+		chatJID, deviceID, done.SyncedMessages, done.FailedMessages)
+	return progress, nil
+}
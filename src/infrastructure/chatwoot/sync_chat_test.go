@@ -0,0 +1,95 @@
+package chatwoot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+func (r *syncHistoryRepo) GetChatByDevice(deviceID, jid string) (*domainChatStorage.Chat, error) {
+	chats, _ := r.GetChats(nil)
+	for _, chat := range chats {
+		if chat.JID == jid {
+			return chat, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestSyncSingleChat(t *testing.T) {
+	const device = "6280000000000@s.whatsapp.net"
+	chat := fmt.Sprintf("62812345678%02d@s.whatsapp.net", 1)
+	repo := &syncHistoryRepo{chats: 3, messages: 5, exported: make(map[string]int)}
+	client, created := syncHistoryServer(t, 0)
+	s := NewSyncService(client, repo)
+	opts := DefaultSyncOptions()
+	opts.DelayBetweenBatches = 0
+
+	if _, err := s.SyncSingleChat(context.Background(), device, "6289999999999@s.whatsapp.net", nil, opts); !errors.Is(err, ErrSyncChatNotFound) {
+		t.Fatalf("unknown chat: got %v, want ErrSyncChatNotFound", err)
+	}
+
+	progress, err := s.SyncSingleChat(context.Background(), device, chat, nil, opts)
+	if err != nil {
+		t.Fatalf("SyncSingleChat: %v", err)
+	}
+	if got := progress.Clone(); got.Status != "completed" || got.SyncedChats != 1 || got.SyncedMessages != 5 {
+		t.Fatalf("unexpected progress: status %s, chats %d, messages %d", got.Status, got.SyncedChats, got.SyncedMessages)
+	}
+	if n := len(created()); n != 5 {
+		t.Fatalf("created %d messages, want 5", n)
+	}
+	if got := s.GetChatSyncProgress(device, chat); got != nil {
+		t.Fatalf("GetChatSyncProgress = %+v, want the finished run forgotten", got)
+	}
+	if s.GetProgress(device) != nil {
+		t.Fatal("a chat sync must not replace the device sync progress")
+	}
+
+	// Running it again sends nothing new
+	if _, err := s.SyncSingleChat(context.Background(), device, chat, nil, opts); err != nil {
+		t.Fatalf("second SyncSingleChat: %v", err)
+	}
+	if n := len(created()); n != 5 {
+		t.Fatalf("created %d messages after the second run, want 5", n)
+	}
+}
+
+func TestSyncSingleChat_ExcludesFullSync(t *testing.T) {
+	const device = "6280000000000@s.whatsapp.net"
+	chat := fmt.Sprintf("62812345678%02d@s.whatsapp.net", 0)
+	repo := &syncHistoryRepo{chats: 1, messages: 1, exported: make(map[string]int)}
+	client, created := syncHistoryServer(t, 0)
+	s := NewSyncService(client, repo)
+
+	full := NewSyncProgress(device)
+	full.SetRunning()
+	s.progressMap[device] = full
+	if _, err := s.SyncSingleChat(context.Background(), device, chat, nil, DefaultSyncOptions()); !errors.Is(err, ErrSyncAlreadyRunning) {
+		t.Fatalf("chat sync during a full sync: got %v, want ErrSyncAlreadyRunning", err)
+	}
+	full.SetCompleted()
+
+	single := NewSyncProgress(device)
+	single.SetRunning()
+	s.chatSyncMap[chatSyncKey(device, chat)] = single
+	if !s.IsRunning(device) {
+		t.Fatal("IsRunning is false while a chat of the device syncs")
+	}
+	if s.RunningSyncForChat(device, chat) == nil {
+		t.Fatal("RunningSyncForChat is nil while the same chat syncs")
+	}
+	other := fmt.Sprintf("62812345678%02d@s.whatsapp.net", 1)
+	if got := s.RunningSyncForChat(device, other); got != nil {
+		t.Fatalf("RunningSyncForChat of another chat = %+v, want nil", got)
+	}
+	if _, err := s.SyncHistory(context.Background(), device, nil, DefaultSyncOptions()); !errors.Is(err, ErrSyncAlreadyRunning) {
+		t.Fatalf("full sync during a chat sync: got %v, want ErrSyncAlreadyRunning", err)
+	}
+	if n := len(created()); n != 0 {
+		t.Fatalf("created %d messages, want none", n)
+	}
+}
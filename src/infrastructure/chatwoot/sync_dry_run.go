@@ -0,0 +1,81 @@
+package chatwoot
+
+import (
+	"context"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+// estimateChat is the dry-run counterpart of syncChat: it selects and dedups the chat's messages
+// the same way but only counts them. It makes no Chatwoot call, downloads nothing and writes no
+// export state.
+func (s *SyncService) estimateChat(
+	ctx context.Context,
+	cw *Client,
+	deviceID string,
+	chat *domainChatStorage.Chat,
+	contactName string,
+	sinceTime time.Time,
+	opts SyncOptions,
+	progress *SyncProgress,
+) error {
+	state, messages, err := s.messagesToSync(deviceID, chat.JID, sinceTime, opts)
+	if err != nil {
+		return err
+	}
+
+	// A chat that was never exported and has no remembered contact, such as one made for its live
+	// messages, likely gets a new contact. One added in Chatwoot by hand is not known here, so the
+	// count is an upper bound.
+	_, knownContact := cw.rememberedContactID(chat.JID)
+	estimate := SyncChatEstimate{
+		ChatJID:    chat.JID,
+		Name:       contactName,
+		NewContact: state == nil && !knownContact,
+	}
+	if opts.ContactsOnly {
+		progress.AddChatEstimate(estimate)
+		return nil
+	}
+	progress.AddMessages(len(messages))
+	for _, msg := range messages {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		exported, err := s.chatStorageRepo.IsMessageExported(deviceID, chat.JID, messageKey(deviceID, chat.JID, msg))
+		if err != nil {
+			progress.IncrementFailedMessages()
+			continue
+		}
+		if exported {
+			continue
+		}
+		estimate.Messages++
+		if syncDownloadsMedia(msg, opts) {
+			estimate.MediaMessages++
+			estimate.MediaBytes += int64(msg.FileLength)
+		}
+	}
+	progress.AddChatEstimate(estimate)
+	return nil
+}
+
+// syncDownloadsMedia reports whether syncing msg downloads its media, following the checks of
+// syncMessageReturnID.
+func syncDownloadsMedia(msg *domainChatStorage.Message, opts SyncOptions) bool {
+	if !opts.IncludeMedia || opts.redactContent || msg.MediaType == "" || msg.URL == "" || len(msg.MediaKey) == 0 {
+		return false
+	}
+	if msg.IsViewOnce {
+		switch ViewOnceMode() {
+		case ViewOncePlaceholder:
+			return false
+		case ViewOnceBlur:
+			if msg.MediaType != "image" && msg.MediaType != "video" {
+				return false
+			}
+		}
+	}
+	return opts.MaxMediaFileSize <= 0 || msg.FileLength <= uint64(opts.MaxMediaFileSize)
+}
@@ -0,0 +1,72 @@
+package chatwoot
+
+import "sync"
+
+// progressHub fans SyncProgress snapshots of a device out to its subscribers, e.g. the
+// GET /chatwoot/sync/events stream. Publishing never blocks: a subscriber that falls behind only
+// keeps the newest snapshot.
+type progressHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *SyncProgress]struct{}
+}
+
+func (h *progressHub) subscribe(deviceID string) (<-chan *SyncProgress, func()) {
+	ch := make(chan *SyncProgress, 1)
+
+	h.mu.Lock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[string]map[chan *SyncProgress]struct{})
+	}
+	if h.subscribers[deviceID] == nil {
+		h.subscribers[deviceID] = make(map[chan *SyncProgress]struct{})
+	}
+	h.subscribers[deviceID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[deviceID], ch)
+		if len(h.subscribers[deviceID]) == 0 {
+			delete(h.subscribers, deviceID)
+		}
+	}
+}
+
+func (h *progressHub) count(deviceID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers[deviceID])
+}
+
+func (h *progressHub) publish(deviceID string, snapshot *SyncProgress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[deviceID] {
+		select {
+		case ch <- snapshot:
+			continue
+		default:
+		}
+		// Replace the unread snapshot with the newer one
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- snapshot:
+		default:
+		}
+	}
+}
+
+// SubscribeProgress returns a channel receiving a snapshot of the device's history sync after every
+// change, and a function that ends the subscription. Only the newest unread snapshot is kept.
+func (s *SyncService) SubscribeProgress(deviceID string) (<-chan *SyncProgress, func()) {
+	return s.progressHub.subscribe(deviceID)
+}
+
+// ProgressSubscribers returns how many subscribers follow the device's history sync
+func (s *SyncService) ProgressSubscribers(deviceID string) int {
+	return s.progressHub.count(deviceID)
+}
@@ -0,0 +1,40 @@
+package chatwoot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressHub_SlowSubscriberKeepsNewest(t *testing.T) {
+	s := NewSyncService(nil, nil)
+	updates, unsubscribe := s.SubscribeProgress("device")
+
+	p := NewSyncProgress("device")
+	p.notify = func(snapshot *SyncProgress) { s.progressHub.publish("device", snapshot) }
+
+	// Nobody reads while the sync moves on; publishing must not block
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.SetRunning()
+		for range 100 {
+			p.IncrementSyncedMessages()
+		}
+		p.SetCompleted()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishing blocked on a subscriber that does not read")
+	}
+
+	if got := <-updates; got.Status != "completed" || got.SyncedMessages != 100 {
+		t.Fatalf("expected the final snapshot, got status %s with %d messages", got.Status, got.SyncedMessages)
+	}
+
+	unsubscribe()
+	if n := s.ProgressSubscribers("device"); n != 0 {
+		t.Fatalf("expected no subscribers after unsubscribing, got %d", n)
+	}
+	p.IncrementFailedMessages() // publishing without subscribers is a no-op
+}
@@ -0,0 +1,55 @@
+package chatwoot
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ValidateJIDPatterns checks include/exclude entries of a sync: exact JIDs or glob patterns such
+// as "*@g.us" or "62812*@s.whatsapp.net".
+func ValidateJIDPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("empty JID pattern")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid JID pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesJIDPattern reports whether jid equals or matches one of the patterns. Invalid patterns
+// never match; the REST handler rejects them up front.
+func matchesJIDPattern(jid string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == jid {
+			return true
+		}
+		if ok, _ := path.Match(pattern, jid); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// chatSelected applies the include and exclude lists of a sync to a chat. An exclude match wins;
+// an empty include list selects every chat.
+func chatSelected(jid string, opts SyncOptions) bool {
+	if matchesJIDPattern(jid, opts.ExcludeJIDs) {
+		return false
+	}
+	return len(opts.IncludeJIDs) == 0 || matchesJIDPattern(jid, opts.IncludeJIDs)
+}
+
+// SplitJIDList splits a comma-separated query value into JID patterns.
+func SplitJIDList(value string) []string {
+	var patterns []string
+	for part := range strings.SplitSeq(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			patterns = append(patterns, part)
+		}
+	}
+	return patterns
+}
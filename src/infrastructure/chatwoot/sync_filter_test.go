@@ -0,0 +1,55 @@
+package chatwoot
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChatSelected(t *testing.T) {
+	opts := SyncOptions{
+		IncludeJIDs: []string{"*@g.us", "628111@s.whatsapp.net"},
+		ExcludeJIDs: []string{"120363000000000001@g.us"},
+	}
+	tests := []struct {
+		jid  string
+		want bool
+	}{
+		{"120363000000000002@g.us", true},
+		{"120363000000000001@g.us", false}, // excluded even though *@g.us includes it
+		{"628111@s.whatsapp.net", true},
+		{"628222@s.whatsapp.net", false},
+	}
+	for _, tt := range tests {
+		if got := chatSelected(tt.jid, opts); got != tt.want {
+			t.Errorf("chatSelected(%q) = %v, want %v", tt.jid, got, tt.want)
+		}
+	}
+
+	if !chatSelected("628222@s.whatsapp.net", SyncOptions{}) {
+		t.Error("without lists every chat should be selected")
+	}
+	if chatSelected("628222@s.whatsapp.net", SyncOptions{ExcludeJIDs: []string{"6282*"}}) {
+		t.Error("prefix pattern should exclude the chat")
+	}
+}
+
+func TestValidateJIDPatterns(t *testing.T) {
+	if err := ValidateJIDPatterns([]string{"*@g.us", "628111@s.whatsapp.net", "62[89]*"}); err != nil {
+		t.Fatalf("valid patterns rejected: %v", err)
+	}
+	for _, bad := range [][]string{{"[628"}, {" "}} {
+		if err := ValidateJIDPatterns(bad); err == nil {
+			t.Errorf("pattern %q accepted", bad)
+		}
+	}
+}
+
+func TestSplitJIDList(t *testing.T) {
+	got := SplitJIDList(" *@g.us, ,628111@s.whatsapp.net,")
+	if want := []string{"*@g.us", "628111@s.whatsapp.net"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := SplitJIDList(""); got != nil {
+		t.Errorf("empty value gave %q", got)
+	}
+}
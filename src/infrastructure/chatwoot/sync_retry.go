@@ -0,0 +1,262 @@
+package chatwoot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+)
+
+// Reasons a message could not be exported, recorded in chatwoot_failed_exports
+const (
+	ExportFailureDownload    = "download_failed" // The media could not be downloaded from WhatsApp
+	ExportFailureUpload      = "upload_failed"   // Chatwoot rejected or did not answer the message
+	ExportFailureRateLimited = "rate_limited"    // Chatwoot still answered 429 after the retries
+)
+
+// failedExportRetryLimit caps the failed messages one retry loads.
+const failedExportRetryLimit = 1000
+
+// exportError is an export failure with a known reason.
+type exportError struct {
+	reason string
+	err    error
+}
+
+func (e *exportError) Error() string { return e.err.Error() }
+func (e *exportError) Unwrap() error { return e.err }
+
+// exportFailureReason classifies an error of syncMessageReturnID.
+func exportFailureReason(err error) string {
+	var exportErr *exportError
+	switch {
+	case errors.As(err, &exportErr):
+		return exportErr.reason
+	case errors.Is(err, ErrRateLimited):
+		return ExportFailureRateLimited
+	default:
+		return ExportFailureUpload
+	}
+}
+
+// mediaGone reports whether a media download failed for good, so retrying the message cannot
+// bring the media back and it is exported with a placeholder instead.
+func mediaGone(err error) bool {
+	return errors.Is(err, errMediaTooLarge) ||
+		errors.Is(err, whatsmeow.ErrNoURLPresent) ||
+		errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith403) ||
+		errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404) ||
+		errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410)
+}
+
+// recordFailedExport counts a message that could not be exported and keeps it for
+// RetryFailedExports. A message interrupted by a cancellation is only counted.
+func (s *SyncService) recordFailedExport(ctx context.Context, deviceID, chatJID string, msg *domainChatStorage.Message, key string, err error, progress *SyncProgress) {
+	if ctx.Err() != nil {
+		progress.IncrementFailedMessages()
+		return
+	}
+	reason := exportFailureReason(err)
+	progress.RecordFailedMessage(reason)
+	if saveErr := s.chatStorageRepo.SaveChatwootFailedExport(&domainChatStorage.ChatwootFailedExport{
+		DeviceID:   deviceID,
+		ChatJID:    chatJID,
+		MessageID:  msg.ID,
+		MessageKey: key,
+		Reason:     reason,
+		Error:      err.Error(),
+	}); saveErr != nil {
+		logrus.Warnf("Chatwoot Sync: Failed to record failed message %s of chat %s: %v", msg.ID, chatJID, saveErr)
+	}
+}
+
+// PurgeFailedExports drops failed messages older than CHATWOOT_FAILED_EXPORT_RETENTION_DAYS.
+func PurgeFailedExports(repo domainChatStorage.IChatStorageRepository) {
+	if config.ChatwootFailedExportRetentionDays <= 0 {
+		return
+	}
+	purged, err := repo.PurgeChatwootFailedExports(time.Now().AddDate(0, 0, -config.ChatwootFailedExportRetentionDays))
+	if err != nil {
+		logrus.Warnf("Chatwoot Sync: Failed to purge old failed exports: %v", err)
+	} else if purged > 0 {
+		logrus.Infof("Chatwoot Sync: Purged %d failed exports older than %d days", purged, config.ChatwootFailedExportRetentionDays)
+	}
+}
+
+// RetryFailedExports exports again the messages earlier syncs of the device failed to export. Old
+// rows are purged first; a message that exports now, was exported meanwhile or no longer exists
+// is cleared, and one that fails again keeps its row with the new reason. It is tracked like
+// SyncHistory, so the status, events and cancel endpoints apply to it.
+func (s *SyncService) RetryFailedExports(ctx context.Context, deviceID string, waClient *whatsmeow.Client, opts SyncOptions) (*SyncProgress, error) {
+	opts = normalizeSyncOptions(opts)
+	opts.failOnMediaError = true
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress := NewSyncProgress(deviceID)
+	progress.cancel = cancel
+	progress.throttleClient = s.clientFor(waClient)
+	progress.throttleBase = progress.throttleClient.ThrottledTime()
+	progress.notify = func(snapshot *SyncProgress) { s.progressHub.publish(deviceID, snapshot) }
+	defer close(progress.done)
+	s.progressMu.Lock()
+	if existing, ok := s.progressMap[deviceID]; ok && existing.IsRunning() {
+		s.progressMu.Unlock()
+		cloned := existing.Clone()
+		return &cloned, fmt.Errorf("%w for device %s", ErrSyncAlreadyRunning, deviceID)
+	}
+	if s.chatSyncRunningLocked(deviceID) {
+		s.progressMu.Unlock()
+		return nil, fmt.Errorf("%w for a chat of device %s", ErrSyncAlreadyRunning, deviceID)
+	}
+	s.progressMap[deviceID] = progress
+	progress.SetRunning()
+	s.progressMu.Unlock()
+	defer s.trackSyncRun(progress)()
+
+	PurgeFailedExports(s.chatStorageRepo)
+	failures, err := s.chatStorageRepo.GetChatwootFailedExports(deviceID, failedExportRetryLimit)
+	if err != nil {
+		progress.SetFailed(err)
+		return progress, fmt.Errorf("failed to get failed exports: %w", err)
+	}
+
+	// Group by chat, keeping the order of first failure
+	var chatOrder []string
+	byChat := make(map[string][]*domainChatStorage.ChatwootFailedExport)
+	for _, failure := range failures {
+		if _, ok := byChat[failure.ChatJID]; !ok {
+			chatOrder = append(chatOrder, failure.ChatJID)
+		}
+		byChat[failure.ChatJID] = append(byChat[failure.ChatJID], failure)
+	}
+	progress.SetTotals(len(chatOrder), len(failures))
+	logrus.Infof("Chatwoot Sync: Retrying %d failed messages in %d chats for device %s", len(failures), len(chatOrder), deviceID)
+
+	for _, chatJID := range chatOrder {
+		if ctx.Err() != nil {
+			break
+		}
+		progress.UpdateChat(chatJID)
+		err := s.retryChatFailures(ctx, deviceID, chatJID, byChat[chatJID], waClient, opts, progress)
+		progress.FinishChat(chatJID)
+		s.noteThrottle(progress)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			logrus.Errorf("Chatwoot Sync: Failed to retry messages of chat %s: %v", chatJID, err)
+			progress.IncrementFailedChats()
+		} else {
+			progress.IncrementSyncedChats()
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		if progress.wasCancelled() {
+			progress.SetCancelled()
+			return progress, err
+		}
+		progress.SetFailed(err)
+		return progress, err
+	}
+
+	progress.SetCompleted()
+	done := progress.Clone()
+	logrus.Infof("Chatwoot Sync: Retry for device %s exported %d of %d failed messages (failed again: %d)",
+		deviceID, done.SyncedMessages, done.TotalMessages, done.FailedMessages)
+	return progress, nil
+}
+
+// retryChatFailures retries the failed messages of one chat, oldest first.
+func (s *SyncService) retryChatFailures(
+	ctx context.Context,
+	deviceID, chatJID string,
+	failures []*domainChatStorage.ChatwootFailedExport,
+	waClient *whatsmeow.Client,
+	opts SyncOptions,
+	progress *SyncProgress,
+) error {
+	chat, err := s.chatStorageRepo.GetChatByDevice(deviceID, chatJID)
+	if err != nil {
+		return fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat == nil {
+		chat = &domainChatStorage.Chat{DeviceID: deviceID, JID: chatJID}
+	}
+	switch ChatEphemeralMode(chat.EphemeralExpiration) {
+	case EphemeralSkip:
+		// Disappearing messages were turned on since; the chat is no longer exported
+		for _, failure := range failures {
+			if err := s.chatStorageRepo.DeleteChatwootFailedExport(deviceID, chatJID, failure.MessageKey); err != nil {
+				logrus.Warnf("Chatwoot Sync: Failed to clear failed message %s of chat %s: %v", failure.MessageID, chatJID, err)
+			}
+		}
+		return nil
+	case EphemeralRedact:
+		opts.redactContent = true
+	}
+	contactName := chatContactName(chat, waClient)
+	isGroup := strings.HasSuffix(chatJID, "@g.us")
+
+	contact, err := s.chatContact(ctx, contactName, chatJID, isGroup, waClient)
+	if err != nil {
+		return fmt.Errorf("failed to find/create contact: %w", err)
+	}
+	cw := s.clientFor(waClient)
+	conversation, err := cw.FindOrCreateConversation(contact.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find/create conversation: %w", err)
+	}
+	cw.StoreConversationMetadata(conversation, NewConversationMetadata(deviceID, chatJID, chat.EphemeralExpiration))
+
+	for _, failure := range failures {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		forget := func() {
+			if err := s.chatStorageRepo.DeleteChatwootFailedExport(deviceID, chatJID, failure.MessageKey); err != nil {
+				logrus.Warnf("Chatwoot Sync: Failed to clear failed message %s of chat %s: %v", failure.MessageID, chatJID, err)
+			}
+		}
+
+		exported, err := s.chatStorageRepo.IsMessageExported(deviceID, chatJID, failure.MessageKey)
+		if err != nil {
+			progress.IncrementFailedMessages()
+			continue
+		}
+		if exported {
+			// A later sync exported it
+			forget()
+			progress.IncrementSyncedMessages()
+			continue
+		}
+		msg, err := s.chatStorageRepo.GetMessageByID(failure.MessageID)
+		if err != nil {
+			progress.IncrementFailedMessages()
+			continue
+		}
+		if msg == nil || msg.ChatJID != chatJID {
+			forget() // Deleted from local storage, nothing left to export
+			continue
+		}
+
+		chatwootMsgID, err := s.syncMessageReturnID(ctx, conversation.ID, msg, waClient, opts, isGroup)
+		if err != nil {
+			s.recordFailedExport(ctx, deviceID, chatJID, msg, failure.MessageKey, err, progress)
+			continue
+		}
+		_ = s.chatStorageRepo.MarkMessageExported(deviceID, chatJID, failure.MessageKey, chatwootMsgID)
+		forget()
+		progress.IncrementSyncedMessages()
+		s.noteThrottle(progress)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package chatwoot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"go.mau.fi/whatsmeow"
+)
+
+func (r *syncHistoryRepo) SaveChatwootFailedExport(failure *domainChatStorage.ChatwootFailedExport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures == nil {
+		r.failures = make(map[string]domainChatStorage.ChatwootFailedExport)
+	}
+	saved := *failure
+	saved.Attempts = r.failures[failure.MessageKey].Attempts + 1
+	r.failures[failure.MessageKey] = saved
+	return nil
+}
+
+func (r *syncHistoryRepo) DeleteChatwootFailedExport(deviceID, chatJID, messageKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, messageKey)
+	return nil
+}
+
+func (r *syncHistoryRepo) GetChatwootFailedExports(deviceID string, limit int) ([]*domainChatStorage.ChatwootFailedExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var failures []*domainChatStorage.ChatwootFailedExport
+	for _, f := range r.failures {
+		failures = append(failures, &f)
+	}
+	slices.SortFunc(failures, func(a, b *domainChatStorage.ChatwootFailedExport) int {
+		return strings.Compare(a.MessageID, b.MessageID)
+	})
+	return failures, nil
+}
+
+func (r *syncHistoryRepo) PurgeChatwootFailedExports(olderThan time.Time) (int64, error) {
+	return 0, nil
+}
+
+// GetMessageByID finds a message of GetMessages by its "MSG<chat><n>" ID.
+func (r *syncHistoryRepo) GetMessageByID(id string) (*domainChatStorage.Message, error) {
+	messages, _ := r.GetMessages(&domainChatStorage.MessageFilter{ChatJID: "62812345678" + id[3:5] + "@s.whatsapp.net"})
+	for _, msg := range messages {
+		if msg.ID == id {
+			return msg, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestRetryFailedExports_ExportsOnlyFailedMessages(t *testing.T) {
+	repo := &syncHistoryRepo{chats: 1, messages: 4, mediaBytes: 10, exported: make(map[string]int)}
+	client, created := syncHistoryServer(t, 0)
+	s := NewSyncService(client, repo)
+	opts := DefaultSyncOptions()
+	opts.DelayBetweenBatches = 0
+
+	// Without a WhatsApp client the images cannot be downloaded
+	progress, err := s.SyncHistory(context.Background(), "6280000000000@s.whatsapp.net", nil, opts)
+	if err != nil {
+		t.Fatalf("SyncHistory: %v", err)
+	}
+	got := progress.Clone()
+	if got.SyncedMessages != 2 || got.FailedMessages != 2 || got.FailureReasons[ExportFailureDownload] != 2 || len(repo.failures) != 2 {
+		t.Fatalf("expected the 2 images to fail with download_failed, got %d synced, %d failed %v, %d recorded",
+			got.SyncedMessages, got.FailedMessages, got.FailureReasons, len(repo.failures))
+	}
+
+	fakeMediaDownload(t, "image")
+	progress, err = s.RetryFailedExports(context.Background(), "6280000000000@s.whatsapp.net", &whatsmeow.Client{}, opts)
+	if err != nil {
+		t.Fatalf("RetryFailedExports: %v", err)
+	}
+	got = progress.Clone()
+	if got.TotalMessages != 2 || got.SyncedMessages != 2 || got.FailedMessages != 0 {
+		t.Fatalf("expected the 2 failed messages retried, got %d of %d (failed %d)", got.SyncedMessages, got.TotalMessages, got.FailedMessages)
+	}
+	if len(repo.failures) != 0 || len(repo.exported) != 4 || len(created()) != 4 {
+		t.Errorf("expected all 4 messages exported once and no failures left, got %d exported, %d created, %d failures",
+			len(repo.exported), len(created()), len(repo.failures))
+	}
+}
+
+func TestExportFailureReason(t *testing.T) {
+	rateLimited := responseError("failed to create message", &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}, nil)
+	rejected := responseError("failed to create message", &http.Response{StatusCode: http.StatusUnprocessableEntity, Header: http.Header{}}, nil)
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{rateLimited, ExportFailureRateLimited},
+		{rejected, ExportFailureUpload},
+		{&exportError{reason: ExportFailureDownload, err: errors.New("timeout")}, ExportFailureDownload},
+	}
+	for _, tt := range tests {
+		if got := exportFailureReason(tt.err); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.err, got, tt.want)
+		}
+	}
+	if !mediaGone(whatsmeow.ErrMediaDownloadFailedWith404) || mediaGone(errors.New("connection reset")) {
+		t.Error("a 404 should count as gone media and a reset connection should not")
+	}
+}
@@ -0,0 +1,109 @@
+package chatwoot
+
+import (
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+// syncRunSaveInterval is how often the counters of a running history sync are written to the
+// database, so a restart loses at most that much progress.
+var syncRunSaveInterval = 10 * time.Second
+
+// trackSyncRun records a history sync in chatwoot_sync_runs and saves its progress every
+// syncRunSaveInterval. The returned func writes the final state and must be called once the sync
+// has set its final status. Storage errors are logged; they never stop the sync.
+func (s *SyncService) trackSyncRun(progress *SyncProgress) func() {
+	snapshot := progress.Clone()
+	run := syncRunFromProgress(&snapshot)
+	if err := s.chatStorageRepo.CreateChatwootSyncRun(run); err != nil {
+		logrus.Warnf("Chatwoot Sync: Failed to record sync run of device %s: %v", snapshot.DeviceID, err)
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(syncRunSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.saveSyncRun(run.ID, progress)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		s.saveSyncRun(run.ID, progress)
+	}
+}
+
+func (s *SyncService) saveSyncRun(id int64, progress *SyncProgress) {
+	snapshot := progress.Clone()
+	run := syncRunFromProgress(&snapshot)
+	run.ID = id
+	if err := s.chatStorageRepo.UpdateChatwootSyncRun(run); err != nil {
+		logrus.Warnf("Chatwoot Sync: Failed to save sync run %d of device %s: %v", id, snapshot.DeviceID, err)
+	}
+}
+
+func syncRunFromProgress(p *SyncProgress) *domainChatStorage.ChatwootSyncRun {
+	run := &domainChatStorage.ChatwootSyncRun{
+		DeviceID:       p.DeviceID,
+		Status:         p.Status,
+		TotalChats:     p.TotalChats,
+		SyncedChats:    p.SyncedChats,
+		FailedChats:    p.FailedChats,
+		TotalMessages:  p.TotalMessages,
+		SyncedMessages: p.SyncedMessages,
+		FailedMessages: p.FailedMessages,
+		Error:          p.Error,
+		FinishedAt:     p.CompletedAt,
+	}
+	if p.StartedAt != nil {
+		run.StartedAt = *p.StartedAt
+	}
+	return run
+}
+
+// SyncProgressFromRun returns a persisted sync run in the shape of live progress, for status
+// requests made after the run's process has stopped.
+func SyncProgressFromRun(run *domainChatStorage.ChatwootSyncRun) *SyncProgress {
+	started := run.StartedAt
+	return &SyncProgress{
+		DeviceID:       run.DeviceID,
+		Status:         run.Status,
+		TotalChats:     run.TotalChats,
+		SyncedChats:    run.SyncedChats,
+		FailedChats:    run.FailedChats,
+		TotalMessages:  run.TotalMessages,
+		SyncedMessages: run.SyncedMessages,
+		FailedMessages: run.FailedMessages,
+		StartedAt:      &started,
+		CompletedAt:    run.FinishedAt,
+		Error:          run.Error,
+	}
+}
+
+// MarkInterruptedSyncRuns marks history syncs that were still running when the service last
+// stopped as interrupted. It is called once at startup, before any sync can begin.
+func MarkInterruptedSyncRuns(repo domainChatStorage.IChatStorageRepository) {
+	if repo == nil {
+		return
+	}
+	n, err := repo.MarkInterruptedChatwootSyncRuns()
+	if err != nil {
+		logrus.Warnf("Chatwoot Sync: Failed to mark interrupted sync runs: %v", err)
+		return
+	}
+	if n > 0 {
+		logrus.Infof("Chatwoot Sync: Marked %d sync run(s) left running by the previous process as interrupted", n)
+	}
+}
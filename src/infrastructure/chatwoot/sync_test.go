@@ -1,8 +1,24 @@
 package chatwoot
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"go.mau.fi/whatsmeow"
 )
 
 func TestSyncProgress_SetRunning(t *testing.T) {
@@ -108,6 +124,26 @@ func TestSyncProgress_Clone(t *testing.T) {
 	}
 }
 
+func TestSyncProgress_TracksChatsInFlight(t *testing.T) {
+	p := NewSyncProgress("test-device")
+	p.UpdateChat("a")
+	p.UpdateChat("b")
+	p.UpdateChat("c")
+
+	p.FinishChat("c")
+	if got := p.Clone(); got.CurrentChat != "b" || len(got.CurrentChats) != 2 {
+		t.Fatalf("after finishing c: current %q, in flight %v", got.CurrentChat, got.CurrentChats)
+	}
+	p.FinishChat("a")
+	if got := p.Clone(); got.CurrentChat != "b" || len(got.CurrentChats) != 1 || got.CurrentChats[0] != "b" {
+		t.Fatalf("after finishing a: current %q, in flight %v", got.CurrentChat, got.CurrentChats)
+	}
+	p.FinishChat("b")
+	if got := p.Clone(); got.CurrentChat != "" || len(got.CurrentChats) != 0 {
+		t.Fatalf("after finishing b: current %q, in flight %v", got.CurrentChat, got.CurrentChats)
+	}
+}
+
 func TestSyncProgress_IsRunning(t *testing.T) {
 	p := NewSyncProgress("test-device")
 
@@ -208,3 +244,459 @@ type testError struct {
 func (e *testError) Error() string {
 	return e.msg
 }
+
+func fakeMediaDownload(t *testing.T, data string) *int {
+	t.Helper()
+	calls := 0
+	prev := downloadMediaToFile
+	downloadMediaToFile = func(_ context.Context, _ *whatsmeow.Client, _ whatsmeow.DownloadableMessage, file *os.File) error {
+		calls++
+		_, err := file.WriteString(data)
+		return err
+	}
+	t.Cleanup(func() { downloadMediaToFile = prev })
+	return &calls
+}
+
+func TestDownloadMedia_SkipsOversizedMedia(t *testing.T) {
+	calls := fakeMediaDownload(t, "video")
+	prev := config.ChatwootSyncMaxMediaFileSize
+	config.ChatwootSyncMaxMediaFileSize = 20000000
+	t.Cleanup(func() { config.ChatwootSyncMaxMediaFileSize = prev })
+
+	msg := &domainChatStorage.Message{
+		MediaType:  "video",
+		URL:        "https://mmg.whatsapp.net/v/huge",
+		MediaKey:   []byte("key"),
+		FileLength: 4 << 30,
+	}
+	fp, err := (&SyncService{}).downloadMedia(context.Background(), msg, &whatsmeow.Client{})
+	if !errors.Is(err, errMediaTooLarge) {
+		t.Fatalf("expected errMediaTooLarge, got %v", err)
+	}
+	if fp != "" {
+		t.Errorf("expected no file, got %s", fp)
+	}
+	if *calls != 0 {
+		t.Errorf("expected no download, got %d", *calls)
+	}
+	if got := mediaDownloadOutcome(err); got != MediaBackfillSkipped {
+		t.Errorf("expected outcome %q, got %q", MediaBackfillSkipped, got)
+	}
+}
+
+func TestDownloadMedia_StreamsToTempFile(t *testing.T) {
+	calls := fakeMediaDownload(t, "image bytes")
+
+	msg := &domainChatStorage.Message{
+		MediaType:  "image",
+		URL:        "https://mmg.whatsapp.net/v/small",
+		MediaKey:   []byte("key"),
+		FileLength: 11,
+	}
+	fp, err := (&SyncService{}).downloadMedia(context.Background(), msg, &whatsmeow.Client{})
+	if err != nil {
+		t.Fatalf("downloadMedia: %v", err)
+	}
+	defer removeTempFile(fp)
+
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("read downloaded file: %v", err)
+	}
+	if string(data) != "image bytes" || *calls != 1 {
+		t.Errorf("expected one download of %q, got %d of %q", "image bytes", *calls, data)
+	}
+}
+
+var syncHistoryBase = time.Now().Add(-time.Hour)
+
+// syncHistoryRepo serves chats with a few messages each, newest first, and tracks how many chats
+// are loading their messages at the same time.
+type syncHistoryRepo struct {
+	domainChatStorage.IChatStorageRepository
+	chats      int
+	messages   int
+	mediaBytes uint64 // when set, even messages are images of this size
+	ephemeral  uint32 // disappearing-message timer of chat 00
+
+	mu          sync.Mutex
+	exported    map[string]int
+	inFlight    int
+	maxInFlight int
+	runs        []domainChatStorage.ChatwootSyncRun
+	stateWrites int
+	failures    map[string]domainChatStorage.ChatwootFailedExport // by message key
+}
+
+func (r *syncHistoryRepo) GetChats(*domainChatStorage.ChatFilter) ([]*domainChatStorage.Chat, error) {
+	chats := make([]*domainChatStorage.Chat, r.chats)
+	for i := range chats {
+		chats[i] = &domainChatStorage.Chat{JID: fmt.Sprintf("62812345678%02d@s.whatsapp.net", i), Name: fmt.Sprintf("chat%d", i)}
+	}
+	if len(chats) > 0 {
+		chats[0].EphemeralExpiration = r.ephemeral
+	}
+	return chats, nil
+}
+
+func (r *syncHistoryRepo) GetChatExportState(string, string) (*domainChatStorage.ChatExportState, error) {
+	return nil, nil
+}
+
+func (r *syncHistoryRepo) GetMessages(filter *domainChatStorage.MessageFilter) ([]*domainChatStorage.Message, error) {
+	r.mu.Lock()
+	r.inFlight++
+	r.maxInFlight = max(r.maxInFlight, r.inFlight)
+	r.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+
+	chat := strings.TrimPrefix(filter.ChatJID, "62812345678")
+	chat = strings.TrimSuffix(chat, "@s.whatsapp.net")
+	messages := make([]*domainChatStorage.Message, r.messages)
+	for i := range messages {
+		n := r.messages - 1 - i
+		messages[i] = &domainChatStorage.Message{
+			ID:        fmt.Sprintf("MSG%s%02d", chat, n),
+			ChatJID:   filter.ChatJID,
+			Sender:    filter.ChatJID,
+			Content:   fmt.Sprintf("chat%s-%d", chat, n),
+			Timestamp: syncHistoryBase.Add(time.Duration(n) * time.Minute),
+		}
+		if r.mediaBytes > 0 && n%2 == 0 {
+			messages[i].MediaType = "image"
+			messages[i].URL = "https://mmg.whatsapp.net/image"
+			messages[i].MediaKey = []byte("key")
+			messages[i].FileLength = r.mediaBytes
+		}
+	}
+	return messages, nil
+}
+
+func (r *syncHistoryRepo) IsMessageExported(deviceID, chatJID, messageKey string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.exported[chatJID+"|"+messageKey]
+	return ok, nil
+}
+
+func (r *syncHistoryRepo) MarkMessageExported(deviceID, chatJID, messageKey string, chatwootMessageID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exported[chatJID+"|"+messageKey] = chatwootMessageID
+	return nil
+}
+
+func (r *syncHistoryRepo) UpsertChatExportState(*domainChatStorage.ChatExportState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stateWrites++
+	return nil
+}
+
+func (r *syncHistoryRepo) CreateChatwootSyncRun(run *domainChatStorage.ChatwootSyncRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run.ID = int64(len(r.runs) + 1)
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+func (r *syncHistoryRepo) UpdateChatwootSyncRun(run *domainChatStorage.ChatwootSyncRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs[run.ID-1] = *run
+	return nil
+}
+
+func (r *syncHistoryRepo) syncRuns() []domainChatStorage.ChatwootSyncRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.runs)
+}
+
+// syncHistoryServer finds one contact and conversation per phone number and records the content of
+// created messages, taking delay to create each.
+func syncHistoryServer(t *testing.T, delay time.Duration) (*Client, func() []string) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		contents []string
+		nextID   = 1000
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/1/")
+		switch {
+		case path == "contacts/search":
+			phone := r.URL.Query().Get("q")
+			id, _ := strconv.Atoi(phone[len(phone)-2:])
+			_ = json.NewEncoder(w).Encode(map[string]any{"payload": []map[string]any{{
+				"id":                id + 1,
+				"phone_number":      phone,
+				"identifier":        strings.TrimPrefix(phone, "+") + "@s.whatsapp.net",
+				"custom_attributes": map[string]any{"waha_whatsapp_jid": strings.TrimPrefix(phone, "+") + "@s.whatsapp.net"},
+			}}})
+		case strings.HasPrefix(path, "contacts/") && strings.HasSuffix(path, "/conversations"):
+			id, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, "contacts/"), "/conversations"))
+			_ = json.NewEncoder(w).Encode(map[string]any{"payload": []map[string]any{{"id": id, "inbox_id": 1, "status": "open"}}})
+		case r.Method == http.MethodPost && strings.HasSuffix(path, "/messages"):
+			var req struct {
+				Content string `json:"content"`
+			}
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+				req.Content = r.FormValue("content") // Messages with attachments
+			} else {
+				_ = json.NewDecoder(r.Body).Decode(&req)
+			}
+			time.Sleep(delay)
+			mu.Lock()
+			contents = append(contents, req.Content)
+			nextID++
+			id := nextID
+			mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	return c, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), contents...)
+	}
+}
+
+func TestSyncHistory_SyncsChatsConcurrentlyInOrder(t *testing.T) {
+	repo := &syncHistoryRepo{chats: 8, messages: 5, exported: make(map[string]int)}
+	client, created := syncHistoryServer(t, 0)
+	opts := DefaultSyncOptions()
+	opts.Concurrency = 4
+	opts.DelayBetweenBatches = 0
+	opts.BatchSize = 2
+
+	progress, err := NewSyncService(client, repo).SyncHistory(context.Background(), "6280000000000@s.whatsapp.net", nil, opts)
+	if err != nil {
+		t.Fatalf("SyncHistory: %v", err)
+	}
+
+	got := progress.Clone()
+	if got.SyncedChats != 8 || got.FailedChats != 0 || got.SyncedMessages != 40 || got.FailedMessages != 0 || got.TotalMessages != 40 {
+		t.Fatalf("unexpected progress: chats %d (failed %d), messages %d/%d (failed %d)", got.SyncedChats, got.FailedChats, got.SyncedMessages, got.TotalMessages, got.FailedMessages)
+	}
+	if len(got.CurrentChats) != 0 || got.CurrentChat != "" {
+		t.Errorf("chats still in flight after the sync: %v", got.CurrentChats)
+	}
+	if repo.maxInFlight < 2 {
+		t.Errorf("chats were synced one at a time")
+	}
+	if repo.maxInFlight > opts.Concurrency {
+		t.Errorf("%d chats synced at once, want at most %d", repo.maxInFlight, opts.Concurrency)
+	}
+	if runs := repo.syncRuns(); len(runs) != 1 || runs[0].Status != "completed" || runs[0].SyncedMessages != 40 || runs[0].FinishedAt == nil {
+		t.Errorf("expected one completed run with 40 messages to be recorded, got %+v", runs)
+	}
+
+	next := make(map[string]int)
+	contents := created()
+	if len(contents) != 40 {
+		t.Fatalf("created %d messages, want 40", len(contents))
+	}
+	for _, content := range contents {
+		var chat string
+		var n int
+		if _, err := fmt.Sscanf(content[strings.Index(content, "] ")+2:], "chat%2s-%d", &chat, &n); err != nil {
+			t.Fatalf("unexpected content %q: %v", content, err)
+		}
+		if n != next[chat] {
+			t.Fatalf("chat %s: got message %d, want %d", chat, n, next[chat])
+		}
+		next[chat]++
+	}
+}
+
+func TestSyncHistory_Cancel(t *testing.T) {
+	const device = "6280000000000@s.whatsapp.net"
+	repo := &syncHistoryRepo{chats: 4, messages: 20, exported: make(map[string]int)}
+	client, created := syncHistoryServer(t, 10*time.Millisecond)
+	s := NewSyncService(client, repo)
+	opts := DefaultSyncOptions()
+	opts.DelayBetweenBatches = time.Hour // a sleeping worker must stop too
+
+	if err := s.Cancel(device); !errors.Is(err, ErrSyncNotRunning) {
+		t.Fatalf("cancel before any sync: got %v, want ErrSyncNotRunning", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = s.SyncHistory(context.Background(), device, nil, opts)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(created()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	start := time.Now()
+	if err := s.Cancel(device); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sync did not stop within a second of Cancel")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Cancel took %s", elapsed)
+	}
+
+	progress := s.GetProgress(device)
+	if progress.Status != "cancelled" {
+		t.Fatalf("status %q, want cancelled", progress.Status)
+	}
+	first := len(created())
+	if first == 0 || first >= 80 || progress.SyncedMessages != first {
+		t.Fatalf("created %d messages, progress counts %d synced; want a partial sync", first, progress.SyncedMessages)
+	}
+	if err := s.Cancel(device); !errors.Is(err, ErrSyncNotRunning) {
+		t.Fatalf("second cancel: got %v, want ErrSyncNotRunning", err)
+	}
+
+	// The next sync sends only what the cancelled one did not
+	opts.DelayBetweenBatches = 0
+	if _, err := s.SyncHistory(context.Background(), device, nil, opts); err != nil {
+		t.Fatalf("resumed sync: %v", err)
+	}
+	if total := len(created()); total != 80 {
+		t.Fatalf("created %d messages over both syncs, want 80", total)
+	}
+
+	runs := repo.syncRuns()
+	if len(runs) != 2 || runs[0].Status != "cancelled" || runs[1].Status != "completed" {
+		t.Fatalf("expected a cancelled and a completed run to be recorded, got %+v", runs)
+	}
+	if runs[0].SyncedMessages != first || runs[0].SyncedMessages+runs[1].SyncedMessages != 80 {
+		t.Errorf("recorded %d and %d synced messages, want %d and %d", runs[0].SyncedMessages, runs[1].SyncedMessages, first, 80-first)
+	}
+}
+
+func TestSyncHistory_SavesRunWhileRunning(t *testing.T) {
+	saveInterval := syncRunSaveInterval
+	syncRunSaveInterval = 10 * time.Millisecond
+	t.Cleanup(func() { syncRunSaveInterval = saveInterval })
+
+	const device = "6280000000000@s.whatsapp.net"
+	repo := &syncHistoryRepo{chats: 1, messages: 20, exported: make(map[string]int)}
+	client, _ := syncHistoryServer(t, 10*time.Millisecond)
+	opts := DefaultSyncOptions()
+	opts.DelayBetweenBatches = 0
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = NewSyncService(client, repo).SyncHistory(context.Background(), device, nil, opts)
+	}()
+
+	saved := false
+	for !saved {
+		select {
+		case <-done:
+			t.Fatal("sync finished before its progress was saved")
+		case <-time.After(5 * time.Millisecond):
+		}
+		runs := repo.syncRuns()
+		saved = len(runs) == 1 && runs[0].Status == "running" && runs[0].SyncedMessages > 0
+	}
+	<-done
+
+	if runs := repo.syncRuns(); runs[0].Status != "completed" || runs[0].SyncedMessages != 20 {
+		t.Errorf("final run: status %q with %d messages, want completed with 20", runs[0].Status, runs[0].SyncedMessages)
+	}
+}
+
+func TestSyncHistory_DryRunOnlyEstimates(t *testing.T) {
+	const device = "6280000000000@s.whatsapp.net"
+	repo := &syncHistoryRepo{chats: 3, messages: 4, mediaBytes: 1000, exported: make(map[string]int)}
+	// The first two messages of chat 00 went out in an earlier sync
+	msgs, _ := repo.GetMessages(&domainChatStorage.MessageFilter{ChatJID: "6281234567800@s.whatsapp.net"})
+	for _, msg := range msgs[2:] {
+		repo.exported["6281234567800@s.whatsapp.net|"+messageKey(device, msg.ChatJID, msg)] = 1
+	}
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	t.Cleanup(srv.Close)
+	client := &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: srv.Client()}
+	// Chat 01 already has a contact from its live messages
+	client.rememberContactID("6281234567801@s.whatsapp.net", 77)
+	t.Cleanup(func() { contactIDs.Delete(client.contactIDCacheKey("6281234567801@s.whatsapp.net")) })
+
+	opts := DefaultSyncOptions()
+	opts.DryRun = true
+	opts.DelayBetweenBatches = 0
+	progress, err := NewSyncService(client, repo).SyncHistory(context.Background(), device, nil, opts)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("dry run made %d Chatwoot requests", n)
+	}
+	if len(repo.exported) != 2 || repo.stateWrites != 0 || len(repo.syncRuns()) != 0 {
+		t.Errorf("dry run wrote state: %d exported, %d export states, %d runs", len(repo.exported), repo.stateWrites, len(repo.syncRuns()))
+	}
+
+	got := progress.Clone()
+	if !got.DryRun || got.Status != "completed" || got.Estimate == nil {
+		t.Fatalf("expected a completed dry run with an estimate, got status %q, dry run %v", got.Status, got.DryRun)
+	}
+	est := got.Estimate
+	// 3 chats of 4 messages, 2 already exported; half the messages carry 1000 bytes of media
+	if est.Messages != 10 || est.MediaMessages != 5 || est.MediaBytes != 5000 || est.ContactsToCreate != 2 || len(est.Chats) != 3 {
+		t.Fatalf("unexpected estimate: %d messages, %d media (%d bytes), %d contacts, %d chats",
+			est.Messages, est.MediaMessages, est.MediaBytes, est.ContactsToCreate, len(est.Chats))
+	}
+	if last := est.Chats[2]; last.ChatJID != "6281234567800@s.whatsapp.net" || last.Messages != 2 {
+		t.Errorf("chat with the fewest messages should come last, got %s with %d", last.ChatJID, last.Messages)
+	}
+}
+
+func TestSyncHistory_ExcludedChatsAndContactsOnly(t *testing.T) {
+	repo := &syncHistoryRepo{chats: 4, messages: 3, exported: make(map[string]int)}
+	client, created := syncHistoryServer(t, 0)
+	opts := DefaultSyncOptions()
+	opts.DelayBetweenBatches = 0
+	opts.ExcludeJIDs = []string{"6281234567800@s.whatsapp.net", "628123456780[12]@s.whatsapp.net"}
+
+	progress, err := NewSyncService(client, repo).SyncHistory(context.Background(), "6280000000000@s.whatsapp.net", nil, opts)
+	if err != nil {
+		t.Fatalf("SyncHistory: %v", err)
+	}
+	got := progress.Clone()
+	if got.TotalChats != 1 || got.SkippedChats != 3 || got.SyncedMessages != 3 || len(created()) != 3 {
+		t.Fatalf("expected 1 chat synced and 3 skipped, got %d chats (%d skipped), %d messages, %d created",
+			got.TotalChats, got.SkippedChats, got.SyncedMessages, len(created()))
+	}
+
+	repo = &syncHistoryRepo{chats: 2, messages: 3, exported: make(map[string]int)}
+	client, created = syncHistoryServer(t, 0)
+	opts = DefaultSyncOptions()
+	opts.ContactsOnly = true
+	progress, err = NewSyncService(client, repo).SyncHistory(context.Background(), "6280000000000@s.whatsapp.net", nil, opts)
+	if err != nil {
+		t.Fatalf("contacts-only SyncHistory: %v", err)
+	}
+	got = progress.Clone()
+	if got.SyncedChats != 2 || got.TotalMessages != 0 || len(created()) != 0 || len(repo.exported) != 0 {
+		t.Fatalf("contacts-only sync exported messages: %d chats, %d messages, %d created, %d exported",
+			got.SyncedChats, got.TotalMessages, len(created()), len(repo.exported))
+	}
+}
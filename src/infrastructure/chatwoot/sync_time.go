@@ -0,0 +1,119 @@
+package chatwoot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+// CHATWOOT_SYNC_TIMESTAMP modes, for messages imported from the WhatsApp history.
+const (
+	SyncTimestampPrefix    = "prefix"     // start the content with the time the message was sent
+	SyncTimestampCreatedAt = "created_at" // only backdate the message to that time
+)
+
+// DefaultSyncTimeFormat is the layout of the time prefix when CHATWOOT_SYNC_TIME_FORMAT is not usable.
+const DefaultSyncTimeFormat = "2006-01-02 15:04"
+
+// IsValidSyncTimestampMode reports whether mode is one of the CHATWOOT_SYNC_TIMESTAMP modes.
+func IsValidSyncTimestampMode(mode string) bool {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case SyncTimestampPrefix, SyncTimestampCreatedAt:
+		return true
+	}
+	return false
+}
+
+// SyncTimestampMode returns the configured CHATWOOT_SYNC_TIMESTAMP mode, falling back to prefix when
+// it is not a known mode.
+func SyncTimestampMode() string {
+	if !IsValidSyncTimestampMode(config.ChatwootSyncTimestamp) {
+		return SyncTimestampPrefix
+	}
+	return strings.ToLower(strings.TrimSpace(config.ChatwootSyncTimestamp))
+}
+
+// IsValidSyncTimeFormat reports whether layout is a Go time layout with at least one date or time
+// element; "YYYY-MM-DD" for instance would print as is for every message.
+func IsValidSyncTimeFormat(layout string) bool {
+	if strings.TrimSpace(layout) == "" {
+		return false
+	}
+	a := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	b := time.Date(2017, 11, 23, 8, 39, 51, 0, time.UTC)
+	return a.Format(layout) != b.Format(layout)
+}
+
+func syncTimeFormat() string {
+	if !IsValidSyncTimeFormat(config.ChatwootSyncTimeFormat) {
+		return DefaultSyncTimeFormat
+	}
+	return config.ChatwootSyncTimeFormat
+}
+
+// loadSyncLocation returns the zone named name, the server zone when name is empty.
+func loadSyncLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// syncLocation caches the zone of CHATWOOT_SYNC_TIMEZONE, so messages do not each read the zone database.
+var syncLocation struct {
+	mu   sync.Mutex
+	name string
+	loc  *time.Location
+}
+
+// SyncLocation returns the zone of CHATWOOT_SYNC_TIMEZONE. A zone that does not load stops the server
+// at startup; one set later through the config falls back to the server zone.
+func SyncLocation() *time.Location {
+	name := config.ChatwootSyncTimezone
+	syncLocation.mu.Lock()
+	defer syncLocation.mu.Unlock()
+
+	if syncLocation.loc != nil && syncLocation.name == name {
+		return syncLocation.loc
+	}
+	loc, err := loadSyncLocation(name)
+	if err != nil {
+		loc = time.Local
+	}
+	syncLocation.name, syncLocation.loc = name, loc
+	return loc
+}
+
+// CheckSyncTimezone reports an error when CHATWOOT_SYNC_TIMEZONE is not a zone the server knows.
+func CheckSyncTimezone() error {
+	if _, err := loadSyncLocation(config.ChatwootSyncTimezone); err != nil {
+		return fmt.Errorf("invalid CHATWOOT_SYNC_TIMEZONE %q (expected an IANA zone like America/Sao_Paulo): %w", config.ChatwootSyncTimezone, err)
+	}
+	return nil
+}
+
+// FormatSyncTime formats t the way messages imported from the history show it, in
+// CHATWOOT_SYNC_TIMEZONE and CHATWOOT_SYNC_TIME_FORMAT.
+func FormatSyncTime(t time.Time) string {
+	return t.In(SyncLocation()).Format(syncTimeFormat())
+}
+
+// historyMessage builds the Chatwoot message of a message imported from the WhatsApp history, sent
+// at ts, and backdated to it. sender names the group participant who wrote it, "" otherwise. In
+// prefix mode the content also starts with ts; in created_at mode it only does when Chatwoot rejects
+// the backdating.
+func historyMessage(ts time.Time, sender, content, messageType string) CreateMessageRequest {
+	if sender != "" {
+		content = RenderMessage(MessageGroupSender, MessageData{Content: content, SenderName: sender, Timestamp: MessageTime(ts)})
+	}
+	prefixed := RenderMessage(MessageHistory, MessageData{Content: content, SenderName: sender, Timestamp: FormatSyncTime(ts)})
+	msg := CreateMessageRequest{Content: prefixed, MessageType: messageType, CreatedAt: ts}
+	if SyncTimestampMode() == SyncTimestampCreatedAt {
+		msg.Content, msg.UndatedContent = content, prefixed
+	}
+	return msg
+}
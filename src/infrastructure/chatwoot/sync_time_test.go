@@ -0,0 +1,128 @@
+package chatwoot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func useSyncTimeSettings(t *testing.T, zone, layout, mode string) {
+	t.Helper()
+	origZone, origLayout, origMode := config.ChatwootSyncTimezone, config.ChatwootSyncTimeFormat, config.ChatwootSyncTimestamp
+	config.ChatwootSyncTimezone, config.ChatwootSyncTimeFormat, config.ChatwootSyncTimestamp = zone, layout, mode
+	t.Cleanup(func() {
+		config.ChatwootSyncTimezone, config.ChatwootSyncTimeFormat, config.ChatwootSyncTimestamp = origZone, origLayout, origMode
+	})
+}
+
+func TestFormatSyncTime_DSTBoundaries(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+		at   time.Time
+		want string
+	}{
+		{"new york before spring forward", "America/New_York", time.Date(2024, 3, 10, 6, 59, 0, 0, time.UTC), "2024-03-10 01:59 EST"},
+		{"new york after spring forward", "America/New_York", time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), "2024-03-10 03:00 EDT"},
+		{"new york first 1:30 of fall back", "America/New_York", time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), "2024-11-03 01:30 EDT"},
+		{"new york second 1:30 of fall back", "America/New_York", time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC), "2024-11-03 01:30 EST"},
+		{"sao paulo before its last spring forward", "America/Sao_Paulo", time.Date(2018, 11, 4, 2, 59, 0, 0, time.UTC), "2018-11-03 23:59 -03"},
+		{"sao paulo after its last spring forward", "America/Sao_Paulo", time.Date(2018, 11, 4, 3, 0, 0, 0, time.UTC), "2018-11-04 01:00 -02"},
+		{"sao paulo summer without DST", "America/Sao_Paulo", time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), "2024-01-15 09:00 -03"},
+		{"utc", "UTC", time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), "2024-03-10 07:00 UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSyncTimeSettings(t, tt.zone, "2006-01-02 15:04 MST", SyncTimestampPrefix)
+			if got := FormatSyncTime(tt.at); got != tt.want {
+				t.Errorf("FormatSyncTime(%s) in %s = %q, want %q", tt.at, tt.zone, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSyncTime_EmptyZoneUsesServerZone(t *testing.T) {
+	useSyncTimeSettings(t, "", DefaultSyncTimeFormat, SyncTimestampPrefix)
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+	if got := FormatSyncTime(at); got != "2024-05-01 09:30" {
+		t.Errorf("expected the server zone, got %q", got)
+	}
+}
+
+func TestFormatSyncTime_FollowsZoneChanges(t *testing.T) {
+	at := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	useSyncTimeSettings(t, "America/New_York", "15:04", SyncTimestampPrefix)
+	if got := FormatSyncTime(at); got != "08:00" {
+		t.Fatalf("expected New York time, got %q", got)
+	}
+	config.ChatwootSyncTimezone = "Asia/Jakarta"
+	if got := FormatSyncTime(at); got != "19:00" {
+		t.Errorf("expected the cached zone replaced, got %q", got)
+	}
+}
+
+func TestFormatSyncTime_FallsBackOnUnusableLayout(t *testing.T) {
+	useSyncTimeSettings(t, "UTC", "YYYY-MM-DD hh:mm", SyncTimestampPrefix)
+	if got := FormatSyncTime(time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)); got != "2024-05-01 09:30" {
+		t.Errorf("expected the default layout, got %q", got)
+	}
+}
+
+func TestIsValidSyncTimeFormat(t *testing.T) {
+	for layout, want := range map[string]bool{
+		DefaultSyncTimeFormat: true,
+		"02/01/2006 15:04":    true,
+		"Jan 2, 3:04 PM":      true,
+		"15:04":               true,
+		"YYYY-MM-DD":          false,
+		"":                    false,
+		"   ":                 false,
+	} {
+		if got := IsValidSyncTimeFormat(layout); got != want {
+			t.Errorf("IsValidSyncTimeFormat(%q) = %v, want %v", layout, got, want)
+		}
+	}
+}
+
+func TestCheckSyncTimezone(t *testing.T) {
+	for zone, valid := range map[string]bool{
+		"":                  true,
+		"UTC":               true,
+		"America/Sao_Paulo": true,
+		"Mars/Olympus_Mons": false,
+		"GMT+3 ":            false,
+	} {
+		useSyncTimeSettings(t, zone, DefaultSyncTimeFormat, SyncTimestampPrefix)
+		if err := CheckSyncTimezone(); (err == nil) != valid {
+			t.Errorf("CheckSyncTimezone with %q: err = %v, want valid %v", zone, err, valid)
+		}
+	}
+}
+
+func TestHistoryMessage(t *testing.T) {
+	sent := time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC)
+
+	useSyncTimeSettings(t, "America/New_York", "02/01 15:04", SyncTimestampPrefix)
+	msg := historyMessage(sent, "Alice", "see you", "incoming")
+	if msg.Content != "[03/11 01:30] Alice: see you" || !msg.CreatedAt.Equal(sent) || msg.UndatedContent != "" || msg.MessageType != "incoming" {
+		t.Errorf("expected a prefixed backdated message, got %+v", msg)
+	}
+
+	config.ChatwootSyncTimestamp = "CREATED_AT"
+	msg = historyMessage(sent, "Alice", "see you", "incoming")
+	if msg.Content != "Alice: see you" || !msg.CreatedAt.Equal(sent) || msg.UndatedContent != "[03/11 01:30] Alice: see you" {
+		t.Errorf("expected the bare content backdated, got %+v", msg)
+	}
+	if undated := msg.undated(); undated.Content != "[03/11 01:30] Alice: see you" || !undated.CreatedAt.IsZero() {
+		t.Errorf("expected the prefix once created_at is dropped, got %+v", undated)
+	}
+	if msg = historyMessage(time.Time{}, "", "hi", "outgoing"); msg.Content != "hi" || !msg.CreatedAt.IsZero() {
+		t.Errorf("expected no created_at without a time, got %+v", msg)
+	}
+
+	config.ChatwootSyncTimestamp = "backdate"
+	if msg = historyMessage(sent, "", "hi", "outgoing"); msg.Content != "[03/11 01:30] hi" {
+		t.Errorf("expected an unknown mode to prefix, got %+v", msg)
+	}
+}
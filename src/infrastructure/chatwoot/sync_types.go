@@ -1,8 +1,14 @@
 package chatwoot
 
 import (
+	"context"
+	"maps"
+	"math"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/metrics"
 )
 
 // SyncState tracks what has been synced to avoid duplicates
@@ -21,18 +27,36 @@ type SyncState struct {
 // SyncProgress tracks overall sync progress
 type SyncProgress struct {
 	DeviceID       string     `json:"device_id"`
-	Status         string     `json:"status"` // idle, running, completed, failed
+	Status         string     `json:"status"` // idle, running, completed, failed, cancelled, interrupted
 	TotalChats     int        `json:"total_chats"`
 	SyncedChats    int        `json:"synced_chats"`
 	FailedChats    int        `json:"failed_chats"`
+	SkippedChats   int        `json:"skipped_chats"` // Left out by the include/exclude lists
 	TotalMessages  int        `json:"total_messages"`
 	SyncedMessages int        `json:"synced_messages"`
 	FailedMessages int        `json:"failed_messages"`
-	CurrentChat    string     `json:"current_chat,omitempty"`
+	CurrentChat    string     `json:"current_chat,omitempty"`  // Chat most recently started
+	CurrentChats   []string   `json:"current_chats,omitempty"` // Chats being synced right now
 	StartedAt      *time.Time `json:"started_at,omitempty"`
 	CompletedAt    *time.Time `json:"completed_at,omitempty"`
 	Error          string     `json:"error,omitempty"`
-	mu             sync.RWMutex
+	// Failed messages by reason (download_failed, upload_failed, rate_limited); they are kept for
+	// POST /chatwoot/sync/retry-failed
+	FailureReasons map[string]int `json:"failure_reasons,omitempty"`
+	// Time spent waiting on the Chatwoot API rate limit since the sync started; the limit is
+	// shared, so waits caused by other syncs and live traffic count too
+	ThrottledSeconds float64       `json:"throttled_seconds"`
+	DryRun           bool          `json:"dry_run,omitempty"`
+	Estimate         *SyncEstimate `json:"estimate,omitempty"`       // What a dry run would export
+	EphemeralMode    string        `json:"ephemeral_mode,omitempty"` // CHATWOOT_FORWARD_EPHEMERAL mode applied to chats with disappearing messages
+	mu               sync.RWMutex
+
+	cancel          context.CancelFunc // stops the running sync
+	cancelRequested bool
+	done            chan struct{}       // closed when the sync returns
+	notify          func(*SyncProgress) // receives a snapshot after every change; set before the sync starts
+	throttleClient  *Client             // client whose rate-limit waits are reported, the service's when nil
+	throttleBase    time.Duration       // Client.ThrottledTime when the sync started
 }
 
 // SyncOptions configures the sync behavior
@@ -45,15 +69,62 @@ type SyncOptions struct {
 	BatchSize           int           // Messages per batch (for rate limiting)
 	DelayBetweenBatches time.Duration // Delay between batches
 	MaxMediaFileSize    int64         // Maximum media size to download in bytes (0 = unlimited)
+	Concurrency         int           // Chats synced at the same time
+	DryRun              bool          // Only estimate what would be exported; nothing is sent or written
+	IncludeJIDs         []string      // Only sync chats matching one of these JIDs or glob patterns (empty = all)
+	ExcludeJIDs         []string      // Never sync chats matching one of these; wins over IncludeJIDs
+	ContactsOnly        bool          // Create contacts and conversations without exporting messages
+
+	// failOnMediaError fails a message whose media download failed for a reason that may pass,
+	// instead of exporting it with a placeholder, so it can be retried
+	failOnMediaError bool
+	// redactContent exports EphemeralPlaceholder instead of the content and media of each message,
+	// for chats with disappearing messages in redact mode
+	redactContent bool
+}
+
+// SyncEstimate is what a dry-run sync found it would export
+type SyncEstimate struct {
+	ContactsToCreate int                `json:"contacts_to_create"` // At most: chats never synced and with no known contact
+	Messages         int                `json:"messages"`
+	MediaMessages    int                `json:"media_messages"`
+	MediaBytes       int64              `json:"media_bytes"` // From the stored file lengths
+	Chats            []SyncChatEstimate `json:"chats"`       // Most messages first
+}
+
+// SyncChatEstimate is the dry-run estimate of one chat
+type SyncChatEstimate struct {
+	ChatJID       string `json:"chat_jid"`
+	Name          string `json:"name,omitempty"`
+	NewContact    bool   `json:"new_contact"`
+	Messages      int    `json:"messages"`
+	MediaMessages int    `json:"media_messages"`
+	MediaBytes    int64  `json:"media_bytes"`
 }
 
+// MaxSyncConcurrency caps SyncOptions.Concurrency so one sync cannot flood Chatwoot.
+const MaxSyncConcurrency = 16
+
 // SyncRequest is the API request for triggering a sync
 type SyncRequest struct {
-	DeviceID      string `json:"device_id,omitempty"`
-	DaysLimit     int    `json:"days_limit,omitempty"`
-	IncludeMedia  bool   `json:"include_media"`
-	IncludeGroups bool   `json:"include_groups"`
-	IncludeStatus bool   `json:"include_status"`
+	DeviceID      string   `json:"device_id,omitempty"`
+	DaysLimit     int      `json:"days_limit,omitempty"`
+	IncludeMedia  bool     `json:"include_media"`
+	IncludeGroups bool     `json:"include_groups"`
+	IncludeStatus bool     `json:"include_status"`
+	Concurrency   int      `json:"concurrency,omitempty"`
+	DryRun        bool     `json:"dry_run,omitempty"`
+	IncludeJIDs   []string `json:"include_jids,omitempty"`
+	ExcludeJIDs   []string `json:"exclude_jids,omitempty"`
+	ContactsOnly  bool     `json:"contacts_only,omitempty"`
+}
+
+// SyncChatRequest is the API request for syncing one chat
+type SyncChatRequest struct {
+	DeviceID     string `json:"device_id,omitempty"`
+	ChatJID      string `json:"chat_jid"`
+	Days         int    `json:"days,omitempty"`
+	IncludeMedia bool   `json:"include_media"`
 }
 
 // SyncResponse is the API response for sync operations
@@ -74,19 +145,23 @@ func DefaultSyncOptions() SyncOptions {
 		BatchSize:           10,
 		DelayBetweenBatches: 500 * time.Millisecond,
 		MaxMediaFileSize:    20_000_000,
+		Concurrency:         4,
 	}
 }
 
 // NewSyncProgress creates a new sync progress tracker
 func NewSyncProgress(deviceID string) *SyncProgress {
 	return &SyncProgress{
-		DeviceID: deviceID,
-		Status:   "idle",
+		DeviceID:      deviceID,
+		Status:        "idle",
+		EphemeralMode: EphemeralMode(),
+		done:          make(chan struct{}),
 	}
 }
 
 // SetRunning marks the sync as running
 func (p *SyncProgress) SetRunning() {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.Status = "running"
@@ -96,6 +171,7 @@ func (p *SyncProgress) SetRunning() {
 
 // SetCompleted marks the sync as completed
 func (p *SyncProgress) SetCompleted() {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.Status = "completed"
@@ -105,6 +181,7 @@ func (p *SyncProgress) SetCompleted() {
 
 // SetFailed marks the sync as failed
 func (p *SyncProgress) SetFailed(err error) {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.Status = "failed"
@@ -115,15 +192,62 @@ func (p *SyncProgress) SetFailed(err error) {
 	}
 }
 
-// UpdateChat updates the current chat being synced
+// SetCancelled marks the sync as stopped on request
+func (p *SyncProgress) SetCancelled() {
+	defer p.changed()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Status = "cancelled"
+	now := time.Now()
+	p.CompletedAt = &now
+}
+
+// requestCancel cancels a running sync. It returns false when the sync is not running.
+func (p *SyncProgress) requestCancel() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Status != "running" || p.cancel == nil {
+		return false
+	}
+	p.cancelRequested = true
+	p.cancel()
+	return true
+}
+
+func (p *SyncProgress) wasCancelled() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cancelRequested
+}
+
+// UpdateChat records that a chat started syncing
 func (p *SyncProgress) UpdateChat(chatJID string) {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.CurrentChat = chatJID
+	if !slices.Contains(p.CurrentChats, chatJID) {
+		p.CurrentChats = append(p.CurrentChats, chatJID)
+	}
+}
+
+// FinishChat records that a chat stopped syncing
+func (p *SyncProgress) FinishChat(chatJID string) {
+	defer p.changed()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.CurrentChats = slices.DeleteFunc(p.CurrentChats, func(jid string) bool { return jid == chatJID })
+	if p.CurrentChat == chatJID {
+		p.CurrentChat = ""
+		if n := len(p.CurrentChats); n > 0 {
+			p.CurrentChat = p.CurrentChats[n-1]
+		}
+	}
 }
 
 // IncrementSyncedChats increments the synced chats counter
 func (p *SyncProgress) IncrementSyncedChats() {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.SyncedChats++
@@ -131,27 +255,73 @@ func (p *SyncProgress) IncrementSyncedChats() {
 
 // IncrementFailedChats increments the failed chats counter
 func (p *SyncProgress) IncrementFailedChats() {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.FailedChats++
 }
 
+// SetSkippedChats sets the number of chats left out by the include/exclude lists
+func (p *SyncProgress) SetSkippedChats(count int) {
+	defer p.changed()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.SkippedChats = count
+}
+
+// SetThrottled records the rate-limit wait of the sync so far
+func (p *SyncProgress) SetThrottled(d time.Duration) {
+	seconds := math.Round(d.Seconds()*10) / 10
+	p.mu.Lock()
+	if p.ThrottledSeconds == seconds {
+		p.mu.Unlock()
+		return
+	}
+	p.ThrottledSeconds = seconds
+	p.mu.Unlock()
+	p.changed()
+}
+
 // IncrementSyncedMessages increments the synced messages counter
 func (p *SyncProgress) IncrementSyncedMessages() {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.SyncedMessages++
+	if !p.DryRun {
+		metrics.SyncedMessage()
+	}
 }
 
 // IncrementFailedMessages increments the failed messages counter
 func (p *SyncProgress) IncrementFailedMessages() {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.FailedMessages++
+	if !p.DryRun {
+		metrics.SyncFailedMessage("")
+	}
+}
+
+// RecordFailedMessage counts a message that failed for reason
+func (p *SyncProgress) RecordFailedMessage(reason string) {
+	defer p.changed()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.FailedMessages++
+	if p.FailureReasons == nil {
+		p.FailureReasons = make(map[string]int)
+	}
+	p.FailureReasons[reason]++
+	if !p.DryRun {
+		metrics.SyncFailedMessage(reason)
+	}
 }
 
 // SetTotals sets the total counts
 func (p *SyncProgress) SetTotals(chats, messages int) {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.TotalChats = chats
@@ -160,29 +330,82 @@ func (p *SyncProgress) SetTotals(chats, messages int) {
 
 // AddMessages adds to total messages count
 func (p *SyncProgress) AddMessages(count int) {
+	defer p.changed()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.TotalMessages += count
 }
 
+// AddChatEstimate adds the estimate of one chat to a dry run
+func (p *SyncProgress) AddChatEstimate(chat SyncChatEstimate) {
+	defer p.changed()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Estimate == nil {
+		p.Estimate = &SyncEstimate{}
+	}
+	e := p.Estimate
+	if chat.NewContact {
+		e.ContactsToCreate++
+	}
+	e.Messages += chat.Messages
+	e.MediaMessages += chat.MediaMessages
+	e.MediaBytes += chat.MediaBytes
+	i, _ := slices.BinarySearchFunc(e.Chats, chat, func(a, b SyncChatEstimate) int { return b.Messages - a.Messages })
+	e.Chats = slices.Insert(e.Chats, i, chat)
+}
+
 // Clone returns a thread-safe copy of the progress
 func (p *SyncProgress) Clone() SyncProgress {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	return SyncProgress{
-		DeviceID:       p.DeviceID,
-		Status:         p.Status,
-		TotalChats:     p.TotalChats,
-		SyncedChats:    p.SyncedChats,
-		FailedChats:    p.FailedChats,
-		TotalMessages:  p.TotalMessages,
-		SyncedMessages: p.SyncedMessages,
-		FailedMessages: p.FailedMessages,
-		CurrentChat:    p.CurrentChat,
-		StartedAt:      p.StartedAt,
-		CompletedAt:    p.CompletedAt,
-		Error:          p.Error,
+		DeviceID:         p.DeviceID,
+		Status:           p.Status,
+		TotalChats:       p.TotalChats,
+		SyncedChats:      p.SyncedChats,
+		FailedChats:      p.FailedChats,
+		SkippedChats:     p.SkippedChats,
+		TotalMessages:    p.TotalMessages,
+		SyncedMessages:   p.SyncedMessages,
+		FailedMessages:   p.FailedMessages,
+		FailureReasons:   maps.Clone(p.FailureReasons),
+		CurrentChat:      p.CurrentChat,
+		CurrentChats:     slices.Clone(p.CurrentChats),
+		StartedAt:        p.StartedAt,
+		CompletedAt:      p.CompletedAt,
+		Error:            p.Error,
+		ThrottledSeconds: p.ThrottledSeconds,
+		DryRun:           p.DryRun,
+		Estimate:         p.Estimate.clone(),
+		EphemeralMode:    p.EphemeralMode,
+	}
+}
+
+func (e *SyncEstimate) clone() *SyncEstimate {
+	if e == nil {
+		return nil
+	}
+	cloned := *e
+	cloned.Chats = slices.Clone(e.Chats)
+	return &cloned
+}
+
+// changed passes a snapshot to the notify hook. Mutators defer it before locking, so it runs after
+// the lock is released.
+func (p *SyncProgress) changed() {
+	if p.notify == nil {
+		return
 	}
+	snapshot := p.Clone()
+	p.notify(&snapshot)
+}
+
+// IsFinished returns true once the sync completed, failed or was cancelled
+func (p *SyncProgress) IsFinished() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Status == "completed" || p.Status == "failed" || p.Status == "cancelled"
 }
 
 // IsRunning returns true if sync is currently running
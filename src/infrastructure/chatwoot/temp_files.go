@@ -0,0 +1,168 @@
+package chatwoot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/sirupsen/logrus"
+)
+
+// tempFilePrefix starts the name of every temp file the Chatwoot integration creates, so files
+// orphaned by a crash or a killed process can be found and swept later.
+const tempFilePrefix = "chatwoot-"
+
+// tempFileSweepInterval is how often StartTempFileSweeper looks for orphaned temp files.
+const tempFileSweepInterval = 15 * time.Minute
+
+var tempFileSweeperOnce sync.Once
+
+// tempFiles tracks the temp files this process created and has not removed yet. The sweeper never
+// deletes a tracked file, however old, since an upload may still be reading it.
+var tempFiles = struct {
+	sync.Mutex
+	paths map[string]struct{}
+}{paths: make(map[string]struct{})}
+
+// createTempFile creates a tracked file in the OS temp dir named tempFilePrefix+pattern, where the
+// last "*" of pattern is replaced by a random string like os.CreateTemp. Release it with
+// removeTempFile, usually deferred right after the file is handed to the upload.
+func createTempFile(pattern string) (*os.File, error) {
+	file, err := os.CreateTemp("", tempFilePrefix+pattern)
+	if err != nil {
+		return nil, err
+	}
+	tempFiles.Lock()
+	tempFiles.paths[file.Name()] = struct{}{}
+	tempFiles.Unlock()
+	return file, nil
+}
+
+// createTempPath is createTempFile for files written by an external tool such as ffmpeg: it returns
+// the path of a closed, empty file.
+func createTempPath(pattern string) (string, error) {
+	file, err := createTempFile(pattern)
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	if err := file.Close(); err != nil {
+		removeTempFile(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// removeTempFile deletes path and stops tracking it. Missing files are fine, so it is safe to call
+// from deferred cleanups that may run after the file was moved or removed elsewhere.
+func removeTempFile(path string) {
+	if path == "" {
+		return
+	}
+	tempFiles.Lock()
+	delete(tempFiles.paths, path)
+	tempFiles.Unlock()
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logrus.Debugf("Chatwoot: failed to cleanup temp file %s: %v", path, err)
+	}
+}
+
+// tempFileCleanup returns a finalizer removing path, for functions returning a file with its cleanup.
+func tempFileCleanup(path string) func() {
+	return func() { removeTempFile(path) }
+}
+
+// isTrackedTempFile reports whether path was created by this process and is still in use.
+func isTrackedTempFile(path string) bool {
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	_, ok := tempFiles.paths[path]
+	return ok
+}
+
+// SweepTempFiles deletes files in the OS temp dir named with tempFilePrefix that were last modified
+// before maxAge ago and are not in use by this process. It returns how many files it deleted.
+func SweepTempFiles(maxAge time.Duration) int {
+	dir := os.TempDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logrus.Warnf("Chatwoot: failed to list temp dir %s: %v", dir, err)
+		return 0
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if isTrackedTempFile(path) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logrus.Debugf("Chatwoot: failed to sweep temp file %s: %v", path, err)
+			continue
+		}
+		removed++
+	}
+
+	// Files removed without removeTempFile, such as attachments cleaned up by the webhook forwarder,
+	// would otherwise stay tracked forever.
+	tempFiles.Lock()
+	for path := range tempFiles.paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(tempFiles.paths, path)
+		}
+	}
+	tempFiles.Unlock()
+	return removed
+}
+
+// StartTempFileSweeper sweeps orphaned temp files at startup and every tempFileSweepInterval,
+// removing those older than CHATWOOT_TEMP_FILE_MAX_AGE_MINUTES, along with linked large videos older
+// than CHATWOOT_LARGE_VIDEO_RETENTION_DAYS.
+func StartTempFileSweeper(ctx context.Context) {
+	if config.ChatwootTempFileMaxAgeMinutes <= 0 && config.ChatwootLargeVideoRetentionDays <= 0 {
+		return
+	}
+	maxAge := time.Duration(config.ChatwootTempFileMaxAgeMinutes) * time.Minute
+	videoMaxAge := time.Duration(config.ChatwootLargeVideoRetentionDays) * 24 * time.Hour
+	tempFileSweeperOnce.Do(func() {
+		go func() {
+			sweepTempFilesAndLog(maxAge, videoMaxAge)
+			ticker := time.NewTicker(tempFileSweepInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					sweepTempFilesAndLog(maxAge, videoMaxAge)
+				}
+			}
+		}()
+	})
+}
+
+// sweepTempFilesAndLog runs the sweeps enabled by a positive max age.
+func sweepTempFilesAndLog(maxAge, videoMaxAge time.Duration) {
+	if maxAge > 0 {
+		if removed := SweepTempFiles(maxAge); removed > 0 {
+			logrus.Infof("Chatwoot: removed %d orphaned temp files older than %s", removed, maxAge)
+		}
+	}
+	if videoMaxAge > 0 {
+		if removed := SweepLinkedVideos(videoMaxAge); removed > 0 {
+			logrus.Infof("Chatwoot: removed %d linked large videos older than %d days", removed, config.ChatwootLargeVideoRetentionDays)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package chatwoot
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"go.mau.fi/whatsmeow"
+)
+
+// useTempDir points the OS temp dir at a fresh directory and returns it.
+func useTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	return dir
+}
+
+func tempDirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read temp dir: %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestSyncMessage_UploadErrorRemovesTempFiles(t *testing.T) {
+	dir := useTempDir(t)
+	raw, _ := base64.StdEncoding.DecodeString(staticWebP)
+	calls := fakeMediaDownload(t, string(raw))
+
+	uploads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err == nil && len(r.MultipartForm.File["attachments[]"]) > 0 {
+			uploads++
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"storage unavailable"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	s := &SyncService{client: &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}}
+	msg := &domainChatStorage.Message{
+		ID:         "STICKER",
+		MediaType:  "sticker",
+		URL:        "https://mmg.whatsapp.net/v/sticker",
+		MediaKey:   []byte("key"),
+		FileLength: uint64(len(raw)),
+		Timestamp:  time.Now(),
+	}
+	opts := SyncOptions{IncludeMedia: true}
+	if _, err := s.syncMessageReturnID(t.Context(), 10, msg, &whatsmeow.Client{}, opts, false); err == nil {
+		t.Fatal("expected the upload error to be returned")
+	}
+
+	if *calls != 1 || uploads == 0 {
+		t.Fatalf("expected the sticker to be downloaded and uploaded, got %d downloads and %d uploads", *calls, uploads)
+	}
+	if names := tempDirNames(t, dir); len(names) != 0 {
+		t.Errorf("expected the downloaded and converted files to be removed, found %v", names)
+	}
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	for path := range tempFiles.paths {
+		if filepath.Dir(path) == dir {
+			t.Errorf("expected %s to be untracked", path)
+		}
+	}
+}
+
+func TestPrepareAttachmentForUpload_CleanupRemovesConvertedFile(t *testing.T) {
+	dir := useTempDir(t)
+	raw, _ := base64.StdEncoding.DecodeString(staticWebP)
+	source := writeTestFile(t, "sticker.webp", raw)
+
+	uploadPath, cleanup := prepareAttachmentForUpload(source)
+	if filepath.Dir(uploadPath) != dir || !isTrackedTempFile(uploadPath) {
+		t.Fatalf("expected a tracked temp file in %s, got %s", dir, uploadPath)
+	}
+	cleanup()
+	if _, err := os.Stat(uploadPath); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", uploadPath, err)
+	}
+	if isTrackedTempFile(uploadPath) {
+		t.Error("expected the removed file to be untracked")
+	}
+}
+
+func TestSweepTempFiles_RemovesOldOrphansOnly(t *testing.T) {
+	dir := useTempDir(t)
+	old := time.Now().Add(-2 * time.Hour)
+	write := func(name string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("chtimes %s: %v", name, err)
+		}
+	}
+	write("chatwoot-sync-orphan.jpg", old)
+	write("chatwoot-audio-recent.mp3", time.Now())
+	write("other-app-file.tmp", old)
+
+	inUse, err := createTempFile("sync-*.mp4")
+	if err != nil {
+		t.Fatalf("createTempFile: %v", err)
+	}
+	_ = inUse.Close()
+	t.Cleanup(func() { removeTempFile(inUse.Name()) })
+	if err := os.Chtimes(inUse.Name(), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	if removed := SweepTempFiles(time.Hour); removed != 1 {
+		t.Errorf("expected one orphan removed, got %d", removed)
+	}
+	remaining := tempDirNames(t, dir)
+	if len(remaining) != 3 {
+		t.Fatalf("expected the recent, foreign and in-use files to stay, got %v", remaining)
+	}
+	for _, name := range remaining {
+		if name == "chatwoot-sync-orphan.jpg" {
+			t.Errorf("expected the old orphan to be swept, got %v", remaining)
+		}
+	}
+}
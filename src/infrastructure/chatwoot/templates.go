@@ -0,0 +1,223 @@
+package chatwoot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Keys of the text the bridge writes into Chatwoot itself, around or instead of what was sent on
+// WhatsApp. Each one is a Go text template that CHATWOOT_MESSAGE_TEMPLATES or
+// CHATWOOT_MESSAGE_TEMPLATES_FILE can replace.
+const (
+	MessageEdited           = "edited"            // an edited message, with the new text as Content
+	MessageSticker          = "sticker"           // a sticker without a file to attach
+	MessageUnsupported      = "unsupported"       // a message type the bridge cannot show, as MediaType
+	MessageGroupSender      = "group_sender"      // a group message, prefixed with its sender
+	MessageGroupMedia       = "group_media"       // a group message with only attachments
+	MessageMediaPlaceholder = "media_placeholder" // an imported media message without a caption
+	MessageMediaUnavailable = "media_unavailable" // an imported message whose media could not be downloaded
+	MessageMediaTooLarge    = "media_too_large"   // an imported message whose media exceeds the size limit
+	MessageHistory          = "history"           // an imported message, prefixed with its time
+	MessagePoll             = "poll"              // a poll that could not be read
+	MessageLocation         = "location"          // a location that could not be read
+	MessageLiveLocation     = "live_location"     // a live location that could not be read
+	MessageList             = "list"              // a list message, with its title as Title
+	MessageOrder            = "order"             // an order, with its title as Title
+	MessageContact          = "contact"           // one shared contact, as Content
+	MessageContacts         = "contacts"          // several shared contacts, as Items
+	MessageContactUnknown   = "contact_unknown"   // a shared contact without a name or number
+)
+
+// MessageData holds the values a message template can use. Fields that do not apply to a key are
+// empty, e.g. GroupName outside groups.
+type MessageData struct {
+	Content    string   // the text the template wraps
+	SenderName string   // the WhatsApp name of the sender
+	GroupName  string   // the subject of the group the message was sent to
+	Timestamp  string   // when the message was sent, in CHATWOOT_SYNC_TIMEZONE and CHATWOOT_SYNC_TIME_FORMAT
+	MediaType  string   // image, video, audio, document, sticker, or the unsupported message type
+	Title      string   // the title of a list or order
+	FileSize   int64    // the size of a media file in bytes
+	Items      []string // the lines of a message listing several things
+}
+
+// defaultMessageTemplates are the templates used for the keys that are not configured, and when a
+// configured template fails on a message.
+var defaultMessageTemplates = map[string]string{
+	MessageEdited:           "✏️ Editado: {{.Content}}",
+	MessageSticker:          "(Sticker)",
+	MessageUnsupported:      "(Unsupported: {{.MediaType}})",
+	MessageGroupSender:      "{{.SenderName}}: {{.Content}}",
+	MessageGroupMedia:       "{{.SenderName}}: (media)",
+	MessageMediaPlaceholder: "[{{.MediaType}}]",
+	MessageMediaUnavailable: "{{.Content}} [media unavailable]",
+	MessageMediaTooLarge:    "{{.Content}} [media skipped: file too large ({{.FileSize}} bytes)]",
+	MessageHistory:          "[{{.Timestamp}}] {{.Content}}",
+	MessagePoll:             "Poll",
+	MessageLocation:         "Location shared",
+	MessageLiveLocation:     "Live location shared",
+	MessageList:             "{{if .Title}}List: {{.Title}}{{else}}List message{{end}}",
+	MessageOrder:            "{{if .Title}}Order: {{.Title}}{{else}}Order message{{end}}",
+	MessageContact:          "Contact: {{.Content}}",
+	MessageContacts:         "Contacts ({{len .Items}}):{{range .Items}}\n- {{.}}{{end}}",
+	MessageContactUnknown:   "Contact shared",
+}
+
+// sampleMessageData fills every field, so validation runs each branch a template may take.
+var sampleMessageData = MessageData{
+	Content:    "Hello",
+	SenderName: "Alice",
+	GroupName:  "Family",
+	Timestamp:  "2024-05-01 09:30",
+	MediaType:  "image",
+	Title:      "Menu",
+	FileSize:   1024,
+	Items:      []string{"Alice (+5511999999999)", "Bob"},
+}
+
+var parsedDefaultTemplates = func() map[string]*template.Template {
+	parsed := make(map[string]*template.Template, len(defaultMessageTemplates))
+	for key, text := range defaultMessageTemplates {
+		parsed[key] = template.Must(parseMessageTemplate(key, text))
+	}
+	return parsed
+}()
+
+// messageTemplates caches the templates configured through CHATWOOT_MESSAGE_TEMPLATES and
+// CHATWOOT_MESSAGE_TEMPLATES_FILE, loaded again when either setting changes.
+var messageTemplates struct {
+	mu        sync.Mutex
+	inline    string
+	file      string
+	loaded    bool
+	overrides map[string]*template.Template
+}
+
+func parseMessageTemplate(key, text string) (*template.Template, error) {
+	return template.New(key).Option("missingkey=error").Parse(text)
+}
+
+// loadMessageTemplates reads the overrides of the JSON file, then those of the inline JSON, which win
+// on a key set in both. Unknown keys, templates that do not parse and templates that fail on sample
+// data are errors.
+func loadMessageTemplates(inline, file string) (map[string]*template.Template, error) {
+	texts := map[string]string{}
+	if path := strings.TrimSpace(file); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CHATWOOT_MESSAGE_TEMPLATES_FILE %s: %w", path, err)
+		}
+		if err := json.Unmarshal(raw, &texts); err != nil {
+			return nil, fmt.Errorf("invalid CHATWOOT_MESSAGE_TEMPLATES_FILE %s (expected a JSON object of strings): %w", path, err)
+		}
+	}
+	if strings.TrimSpace(inline) != "" {
+		var inlineTexts map[string]string
+		if err := json.Unmarshal([]byte(inline), &inlineTexts); err != nil {
+			return nil, fmt.Errorf("invalid CHATWOOT_MESSAGE_TEMPLATES (expected a JSON object of strings): %w", err)
+		}
+		for key, text := range inlineTexts {
+			texts[key] = text
+		}
+	}
+
+	keys := make([]string, 0, len(texts))
+	for key := range texts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	overrides := make(map[string]*template.Template, len(texts))
+	for _, key := range keys {
+		if _, ok := defaultMessageTemplates[key]; !ok {
+			return nil, fmt.Errorf("unknown Chatwoot message template %q (expected one of %s)", key, strings.Join(MessageTemplateKeys(), ", "))
+		}
+		tmpl, err := parseMessageTemplate(key, texts[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Chatwoot message template %q: %w", key, err)
+		}
+		if err := tmpl.Execute(&bytes.Buffer{}, sampleMessageData); err != nil {
+			return nil, fmt.Errorf("invalid Chatwoot message template %q: %w", key, err)
+		}
+		overrides[key] = tmpl
+	}
+	return overrides, nil
+}
+
+// MessageTemplateKeys returns the keys a message template can be configured for, sorted.
+func MessageTemplateKeys() []string {
+	keys := make([]string, 0, len(defaultMessageTemplates))
+	for key := range defaultMessageTemplates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CheckMessageTemplates reports an error when CHATWOOT_MESSAGE_TEMPLATES or
+// CHATWOOT_MESSAGE_TEMPLATES_FILE cannot be used, so a bad template stops the server at startup.
+func CheckMessageTemplates() error {
+	_, err := loadMessageTemplates(config.ChatwootMessageTemplates, config.ChatwootMessageTemplatesFile)
+	return err
+}
+
+// configuredMessageTemplates returns the configured overrides. Templates set later through the config
+// that cannot be used are logged once and left out, so the defaults apply.
+func configuredMessageTemplates() map[string]*template.Template {
+	inline, file := config.ChatwootMessageTemplates, config.ChatwootMessageTemplatesFile
+	messageTemplates.mu.Lock()
+	defer messageTemplates.mu.Unlock()
+
+	if messageTemplates.loaded && messageTemplates.inline == inline && messageTemplates.file == file {
+		return messageTemplates.overrides
+	}
+	overrides, err := loadMessageTemplates(inline, file)
+	if err != nil {
+		logrus.Warnf("Chatwoot: %v; using the default message templates", err)
+		overrides = nil
+	}
+	messageTemplates.inline, messageTemplates.file = inline, file
+	messageTemplates.loaded, messageTemplates.overrides = true, overrides
+	return overrides
+}
+
+// RenderMessage renders the template of key with data: the configured one, or the default when none
+// is configured or the configured one fails on this message.
+func RenderMessage(key string, data MessageData) string {
+	if tmpl, ok := configuredMessageTemplates()[key]; ok {
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, data)
+		if err == nil {
+			return buf.String()
+		}
+		logrus.Warnf("Chatwoot: Message template %q failed, using the default: %v", key, err)
+	}
+	tmpl, ok := parsedDefaultTemplates[key]
+	if !ok {
+		return data.Content
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		logrus.Warnf("Chatwoot: Default message template %q failed: %v", key, err)
+		return data.Content
+	}
+	return buf.String()
+}
+
+// MessageTime formats the time a message was sent for the Timestamp of MessageData; "" for a zero time.
+func MessageTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return FormatSyncTime(t)
+}
@@ -0,0 +1,109 @@
+package chatwoot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func useMessageTemplates(t *testing.T, inline, file string) {
+	t.Helper()
+	prevInline, prevFile := config.ChatwootMessageTemplates, config.ChatwootMessageTemplatesFile
+	config.ChatwootMessageTemplates, config.ChatwootMessageTemplatesFile = inline, file
+	t.Cleanup(func() {
+		config.ChatwootMessageTemplates, config.ChatwootMessageTemplatesFile = prevInline, prevFile
+	})
+}
+
+func TestRenderMessage_Defaults(t *testing.T) {
+	useMessageTemplates(t, "", "")
+
+	tests := []struct {
+		key  string
+		data MessageData
+		want string
+	}{
+		{MessageEdited, MessageData{Content: "hi"}, "✏️ Editado: hi"},
+		{MessageGroupSender, MessageData{SenderName: "Ana", Content: "hi"}, "Ana: hi"},
+		{MessageMediaTooLarge, MessageData{Content: "[video]", FileSize: 42}, "[video] [media skipped: file too large (42 bytes)]"},
+		{MessageList, MessageData{}, "List message"},
+		{MessageList, MessageData{Title: "Menu"}, "List: Menu"},
+		{MessageContacts, MessageData{Items: []string{"Ana", "Bob"}}, "Contacts (2):\n- Ana\n- Bob"},
+		{"no_such_key", MessageData{Content: "as is"}, "as is"},
+	}
+	for _, tt := range tests {
+		if got := RenderMessage(tt.key, tt.data); got != tt.want {
+			t.Errorf("RenderMessage(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMessage_Overrides(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "templates.json")
+	if err := os.WriteFile(file, []byte(`{"edited":"Edited: {{.Content}}","sticker":"[sticker]"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	useMessageTemplates(t, `{"sticker":"🖼️ {{.MediaType}}","group_sender":"{{.SenderName}} @ {{.GroupName}} ({{.Timestamp}}): {{.Content}}"}`, file)
+
+	if err := CheckMessageTemplates(); err != nil {
+		t.Fatalf("CheckMessageTemplates returned error: %v", err)
+	}
+	if got := RenderMessage(MessageEdited, MessageData{Content: "hi"}); got != "Edited: hi" {
+		t.Errorf("expected the file template, got %q", got)
+	}
+	if got := RenderMessage(MessageSticker, MessageData{MediaType: "sticker"}); got != "🖼️ sticker" {
+		t.Errorf("expected the inline template to win over the file, got %q", got)
+	}
+	got := RenderMessage(MessageGroupSender, MessageData{SenderName: "Ana", GroupName: "Family", Timestamp: "09:30", Content: "hi"})
+	if got != "Ana @ Family (09:30): hi" {
+		t.Errorf("unexpected group sender %q", got)
+	}
+	if got := RenderMessage(MessagePoll, MessageData{}); got != "Poll" {
+		t.Errorf("expected the default for a key not configured, got %q", got)
+	}
+}
+
+func TestRenderMessage_FallsBackOnBadTemplates(t *testing.T) {
+	// Set after startup, a template that does not parse leaves every key on its default
+	useMessageTemplates(t, `{"edited":"Edited: {{.Content","sticker":"[sticker]"}`, "")
+	if got := RenderMessage(MessageEdited, MessageData{Content: "hi"}); got != "✏️ Editado: hi" {
+		t.Errorf("expected the default for an unparsable template, got %q", got)
+	}
+	if got := RenderMessage(MessageSticker, MessageData{}); got != "(Sticker)" {
+		t.Errorf("expected the defaults while the configuration is invalid, got %q", got)
+	}
+
+	useMessageTemplates(t, "", filepath.Join(t.TempDir(), "missing.json"))
+	if got := RenderMessage(MessageSticker, MessageData{}); got != "(Sticker)" {
+		t.Errorf("expected the default without the file, got %q", got)
+	}
+}
+
+func TestCheckMessageTemplates(t *testing.T) {
+	for inline, wantErr := range map[string]string{
+		``:                                       "",
+		`{"edited":"{{.Content}} (edited)"}`:     "",
+		`{"edited":"{{if .Content}}"}`:           `template "edited"`,
+		`{"edited":"{{.Author}}"}`:               `template "edited"`,
+		`{"editado":"{{.Content}}"}`:             `unknown Chatwoot message template "editado"`,
+		`["edited"]`:                             "CHATWOOT_MESSAGE_TEMPLATES",
+		`{"contacts":"{{range .Items}}{{end}}"}`: "",
+	} {
+		useMessageTemplates(t, inline, "")
+		err := CheckMessageTemplates()
+		if wantErr == "" && err != nil {
+			t.Errorf("CheckMessageTemplates(%s) returned error: %v", inline, err)
+		}
+		if wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)) {
+			t.Errorf("CheckMessageTemplates(%s) = %v, want an error with %q", inline, err, wantErr)
+		}
+	}
+
+	useMessageTemplates(t, "", filepath.Join(t.TempDir(), "missing.json"))
+	if err := CheckMessageTemplates(); err == nil || !strings.Contains(err.Error(), "CHATWOOT_MESSAGE_TEMPLATES_FILE") {
+		t.Errorf("expected a missing file to fail, got %v", err)
+	}
+}
@@ -1,5 +1,11 @@
 package chatwoot
 
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
 type Contact struct {
 	ID               int                    `json:"id"`
 	Name             string                 `json:"name"`
@@ -10,10 +16,13 @@ type Contact struct {
 }
 
 type Conversation struct {
-	ID        int    `json:"id"`
-	ContactID int    `json:"contact_id"`
-	InboxID   int    `json:"inbox_id"`
-	Status    string `json:"status"`
+	ID        int      `json:"id"`
+	ContactID int      `json:"contact_id"`
+	InboxID   int      `json:"inbox_id"`
+	Status    string   `json:"status"`
+	Labels    []string `json:"labels,omitempty"`
+
+	CustomAttributes map[string]interface{} `json:"custom_attributes,omitempty"`
 }
 
 type Message struct {
@@ -43,18 +52,65 @@ type CreateMessageRequest struct {
 	MessageType string `json:"message_type"`
 	Private     bool   `json:"private"`
 	ContentType string `json:"content_type,omitempty"`
+	// Stored on the message for automations and agents' tools, e.g. the group participant who wrote it
+	ContentAttributes map[string]interface{} `json:"content_attributes,omitempty"`
+	// Backdates a message imported from the history to the time it was sent; zero lets Chatwoot use now
+	CreatedAt time.Time `json:"-"`
+	// Replaces Content when CreatedAt has to be dropped because the server rejects it
+	UndatedContent string `json:"-"`
 }
 
 type WebhookPayload struct {
 	ID           int                 `json:"id"`
 	Event        string              `json:"event"`
 	MessageType  string              `json:"message_type"`
+	ContentType  string              `json:"content_type"`
 	Content      string              `json:"content"`
 	Private      bool                `json:"private"`
 	Account      Account             `json:"account"`
 	Conversation ConversationWebhook `json:"conversation"`
 	Sender       Contact             `json:"sender"`
 	Attachments  []Attachment        `json:"attachments"`
+	SourceID     string              `json:"source_id"` // Set on messages created through the API, e.g. ForwardedMessageKey
+	// Kept raw since its fields vary by message type
+	ContentAttributes json.RawMessage `json:"content_attributes"`
+
+	// Conversation events (conversation_created, ...) carry the conversation at the top level
+	InboxID int              `json:"inbox_id"`
+	Meta    ConversationMeta `json:"meta"`
+	// The conversation's status and UUID; on message events status is the message's delivery status
+	Status string `json:"status"`
+	UUID   string `json:"uuid"`
+
+	// Contact events (contact_updated, ...) carry the contact at the top level
+	Name             string                 `json:"name"`
+	PhoneNumber      string                 `json:"phone_number"`
+	Identifier       string                 `json:"identifier"`
+	CustomAttributes map[string]interface{} `json:"custom_attributes"`
+}
+
+// EventConversation returns the conversation an event refers to. Message events nest it under
+// "conversation" while conversation events are the conversation itself.
+func (p WebhookPayload) EventConversation() ConversationWebhook {
+	if strings.HasPrefix(p.Event, "conversation_") {
+		return ConversationWebhook{ID: p.ID, UUID: p.UUID, InboxID: p.InboxID, Status: p.Status, Meta: p.Meta}
+	}
+	return p.Conversation
+}
+
+// EventContact returns the contact of a contact event.
+func (p WebhookPayload) EventContact() Contact {
+	return Contact{ID: p.ID, Name: p.Name, PhoneNumber: p.PhoneNumber, Identifier: p.Identifier, CustomAttributes: p.CustomAttributes}
+}
+
+// IsDeleted reports whether the event is about a message an agent deleted. Chatwoot sends deletions as
+// message_updated with the content replaced by a placeholder.
+func (p WebhookPayload) IsDeleted() bool {
+	var attrs struct {
+		Deleted bool `json:"deleted"`
+	}
+	_ = json.Unmarshal(p.ContentAttributes, &attrs)
+	return attrs.Deleted
 }
 
 type Attachment struct {
@@ -62,16 +118,40 @@ type Attachment struct {
 	FileType  string `json:"file_type"`
 	DataURL   string `json:"data_url"`
 	ThumbURL  string `json:"thumb_url"`
+	FileName  string `json:"file_name"`
 	Extension string `json:"extension"`
+	FileSize  int64  `json:"file_size"`
 }
 
 type ConversationWebhook struct {
-	ID   int              `json:"id"`
-	Meta ConversationMeta `json:"meta"`
+	ID      int              `json:"id"`
+	UUID    string           `json:"uuid"`
+	InboxID int              `json:"inbox_id"`
+	Status  string           `json:"status"` // open, pending, snoozed or resolved
+	Meta    ConversationMeta `json:"meta"`
 }
 
 type ConversationMeta struct {
-	Sender Contact `json:"sender"`
+	Sender   Contact `json:"sender"`
+	Assignee *Agent  `json:"assignee"`
+}
+
+// Agent is the Chatwoot user a conversation is assigned to.
+type Agent struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	AvailableName string `json:"available_name"`
+}
+
+// DisplayName returns the name the agent shows to customers.
+func (a *Agent) DisplayName() string {
+	if a == nil {
+		return ""
+	}
+	if a.AvailableName != "" {
+		return a.AvailableName
+	}
+	return a.Name
 }
 
 type Account struct {
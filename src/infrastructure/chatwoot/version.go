@@ -0,0 +1,183 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Capabilities are the Chatwoot API features the bridge relies on that depend on the server version.
+// When the version is unknown every feature is assumed, and the code paths fall back at runtime as
+// they did before the version was detected.
+type Capabilities struct {
+	Version               string `json:"version,omitempty"`
+	Known                 bool   `json:"known"`
+	SupportsBackdate      bool   `json:"supports_backdate"`
+	SupportsRecordedAudio bool   `json:"supports_recorded_audio"`
+	SupportsFilterAPI     bool   `json:"supports_filter_api"`
+	SupportsMessageDelete bool   `json:"supports_message_delete"`
+}
+
+// ErrMessageDeleteUnsupported is returned by DeleteMessage on Chatwoot versions that cannot delete messages.
+var ErrMessageDeleteUnsupported = errors.New("the Chatwoot version does not support deleting messages")
+
+// Minimum Chatwoot versions of each capability, as major and minor. The backdate and recorded-audio
+// minimums are not pinned to a Chatwoot release, so they are only reported: older versions are still
+// sent created_at, which falls back once Chatwoot rejects it, and is_recorded_audio, which they ignore.
+var (
+	minVersionBackdate      = [2]int{4, 0}
+	minVersionRecordedAudio = [2]int{4, 1}
+	minVersionFilterAPI     = [2]int{2, 1}
+	minVersionMessageDelete = [2]int{2, 0}
+)
+
+// versionRetryInterval is how long a failed detection is remembered before the next use tries again.
+const versionRetryInterval = 10 * time.Minute
+
+// versionHeader is read when the /api manifest has no version, e.g. behind a proxy that rewrites it.
+const versionHeader = "X-Chatwoot-Version"
+
+type detectedVersion struct {
+	caps      Capabilities
+	err       error
+	checkedAt time.Time
+}
+
+var (
+	// serverVersions holds the detectedVersion of each Chatwoot base URL.
+	serverVersions sync.Map
+	// versionMu keeps clients of one server from detecting its version at the same time.
+	versionMu sync.Mutex
+)
+
+// unknownCapabilities assumes every feature, for a server whose version could not be read.
+func unknownCapabilities() Capabilities {
+	return Capabilities{SupportsBackdate: true, SupportsRecordedAudio: true, SupportsFilterAPI: true, SupportsMessageDelete: true}
+}
+
+// capabilitiesFor returns the capabilities of a Chatwoot version such as "3.13.0" or "v4.1.2-ce".
+func capabilitiesFor(version string) (Capabilities, error) {
+	major, minor, err := parseVersion(version)
+	if err != nil {
+		return unknownCapabilities(), err
+	}
+	atLeast := func(min [2]int) bool {
+		return major > min[0] || (major == min[0] && minor >= min[1])
+	}
+	return Capabilities{
+		Version:               version,
+		Known:                 true,
+		SupportsBackdate:      atLeast(minVersionBackdate),
+		SupportsRecordedAudio: atLeast(minVersionRecordedAudio),
+		SupportsFilterAPI:     atLeast(minVersionFilterAPI),
+		SupportsMessageDelete: atLeast(minVersionMessageDelete),
+	}, nil
+}
+
+// parseVersion reads the major and minor number of a version; a missing minor is 0.
+func parseVersion(version string) (int, int, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(v, "-+ "); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Chatwoot version %q", version)
+	}
+	minor := 0
+	if len(parts) > 1 {
+		if minor, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("invalid Chatwoot version %q", version)
+		}
+	}
+	return major, minor, nil
+}
+
+// DetectVersion reads the version of the Chatwoot server from its /api manifest, or the
+// X-Chatwoot-Version header when the manifest has none, and caches the capabilities of the server
+// for Capabilities. On an error the returned capabilities are the unknown ones.
+func (c *Client) DetectVersion() (Capabilities, error) {
+	caps, err := c.fetchVersion()
+	if err != nil {
+		caps = unknownCapabilities()
+	}
+	serverVersions.Store(c.BaseURL, &detectedVersion{caps: caps, err: err, checkedAt: time.Now()})
+	return caps, err
+}
+
+func (c *Client) fetchVersion() (Capabilities, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/api", nil)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("failed to read the Chatwoot version: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var manifest struct {
+		Version string `json:"version"`
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_ = json.NewDecoder(resp.Body).Decode(&manifest)
+	}
+	version := strings.TrimSpace(manifest.Version)
+	if version == "" {
+		version = strings.TrimSpace(resp.Header.Get(versionHeader))
+	}
+	if version == "" {
+		return Capabilities{}, fmt.Errorf("chatwoot did not report its version (GET /api status %d)", resp.StatusCode)
+	}
+	return capabilitiesFor(version)
+}
+
+// Capabilities returns the cached capabilities of the Chatwoot server. Clients of NewTargetClient
+// detect the version on the first use; a failed detection is tried again after versionRetryInterval.
+func (c *Client) Capabilities() Capabilities {
+	if c == nil || c.BaseURL == "" || c.HTTPClient == nil {
+		return unknownCapabilities()
+	}
+	if caps, ok := cachedCapabilities(c.BaseURL); ok {
+		return caps
+	}
+	if !c.detectVersion {
+		return unknownCapabilities()
+	}
+
+	versionMu.Lock()
+	defer versionMu.Unlock()
+	if caps, ok := cachedCapabilities(c.BaseURL); ok {
+		return caps
+	}
+	caps, err := c.DetectVersion()
+	if err != nil {
+		logrus.Warnf("Chatwoot: Could not detect the version of %s, assuming every feature: %v", c.BaseURL, err)
+	} else {
+		logrus.Infof("Chatwoot: %s runs version %s (backdate=%v recorded_audio=%v filter_api=%v message_delete=%v)",
+			c.BaseURL, caps.Version, caps.SupportsBackdate, caps.SupportsRecordedAudio, caps.SupportsFilterAPI, caps.SupportsMessageDelete)
+	}
+	return caps
+}
+
+// cachedCapabilities returns the detected capabilities of baseURL unless a failed detection is due
+// for another try.
+func cachedCapabilities(baseURL string) (Capabilities, bool) {
+	val, ok := serverVersions.Load(baseURL)
+	if !ok {
+		return Capabilities{}, false
+	}
+	detected := val.(*detectedVersion)
+	if detected.err != nil && time.Since(detected.checkedAt) > versionRetryInterval {
+		return Capabilities{}, false
+	}
+	return detected.caps, true
+}
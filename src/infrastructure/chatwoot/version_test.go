@@ -0,0 +1,212 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// versionServer fakes a Chatwoot of the given version: /api reports it, the contact filter exists
+// from 2.1 and messages are created or deleted. Every other request is recorded.
+type versionServer struct {
+	version  string
+	requests []string
+	posts    []map[string]string
+}
+
+func (s *versionServer) client(t *testing.T) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/1/")
+		if r.URL.Path == "/api" {
+			_, _ = fmt.Fprintf(w, `{"version":%q,"timestamp":"2024-05-01 09:30:00","queue_services":"ok","data_services":"ok"}`, s.version)
+			return
+		}
+		s.requests = append(s.requests, r.Method+" "+path)
+		switch {
+		case path == "contacts/filter":
+			_, _ = w.Write([]byte(`{"meta":{"count":0},"payload":[]}`))
+		case path == "contacts/search":
+			_, _ = w.Write([]byte(`{"meta":{"count":0},"payload":[]}`))
+		case strings.HasSuffix(path, "/messages") && r.Method == http.MethodPost:
+			post := map[string]string{}
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("failed to parse multipart form: %v", err)
+				}
+				for key, values := range r.MultipartForm.Value {
+					post[key] = values[0]
+				}
+			} else {
+				var body map[string]interface{}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				for key, value := range body {
+					post[key] = fmt.Sprint(value)
+				}
+			}
+			s.posts = append(s.posts, post)
+			_, _ = w.Write([]byte(`{"id":7}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		serverVersions.Delete(srv.URL)
+	})
+	return &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}, detectVersion: true}
+}
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		version string
+		want    Capabilities
+	}{
+		{"1.22.1", Capabilities{}},
+		{"2.0.0", Capabilities{SupportsMessageDelete: true}},
+		{"v2.18.0", Capabilities{SupportsFilterAPI: true, SupportsMessageDelete: true}},
+		{"3.13.2", Capabilities{SupportsFilterAPI: true, SupportsMessageDelete: true}},
+		{"4.0.0-ce", Capabilities{SupportsBackdate: true, SupportsFilterAPI: true, SupportsMessageDelete: true}},
+		{"4.1", Capabilities{SupportsBackdate: true, SupportsRecordedAudio: true, SupportsFilterAPI: true, SupportsMessageDelete: true}},
+	}
+	for _, tt := range tests {
+		got, err := capabilitiesFor(tt.version)
+		if err != nil {
+			t.Fatalf("capabilitiesFor(%q) returned error: %v", tt.version, err)
+		}
+		tt.want.Version, tt.want.Known = tt.version, true
+		if got != tt.want {
+			t.Errorf("capabilitiesFor(%q) = %+v, want %+v", tt.version, got, tt.want)
+		}
+	}
+
+	if got, err := capabilitiesFor("develop"); err == nil || got.Known || !got.SupportsBackdate {
+		t.Errorf("expected an error and every feature for an unparsable version, got %+v (err %v)", got, err)
+	}
+}
+
+func TestDetectVersion_ReadsManifestThenHeader(t *testing.T) {
+	manifest := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			return
+		}
+		if manifest {
+			_, _ = w.Write([]byte(`{"version":"3.13.0"}`))
+			return
+		}
+		w.Header().Set(versionHeader, "4.1.0")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		serverVersions.Delete(srv.URL)
+	})
+	c := &Client{BaseURL: srv.URL, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+
+	if caps, err := c.DetectVersion(); err != nil || caps.Version != "3.13.0" || caps.SupportsBackdate {
+		t.Fatalf("expected 3.13.0 without backdating, got %+v (err %v)", caps, err)
+	}
+	if caps := c.Capabilities(); caps.Version != "3.13.0" {
+		t.Fatalf("expected the detected version to be cached, got %+v", caps)
+	}
+
+	manifest = false
+	if caps, err := c.DetectVersion(); err != nil || caps.Version != "4.1.0" || !caps.SupportsRecordedAudio {
+		t.Fatalf("expected 4.1.0 from the header, got %+v (err %v)", caps, err)
+	}
+}
+
+func TestCapabilities_UnknownVersionAssumesEverything(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		serverVersions.Delete(srv.URL)
+	})
+	c := &Client{BaseURL: srv.URL, HTTPClient: &http.Client{Timeout: 5 * time.Second}, detectVersion: true}
+
+	for i := 0; i < 2; i++ {
+		if caps := c.Capabilities(); caps != unknownCapabilities() {
+			t.Fatalf("expected every feature for an unknown version, got %+v", caps)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the failed detection to be cached, got %d requests", calls)
+	}
+}
+
+func TestCapabilities_BranchByVersion(t *testing.T) {
+	audio := filepath.Join(t.TempDir(), "voice.ogg")
+	if err := os.WriteFile(audio, []byte("OggS"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sent := time.Date(2024, 5, 1, 2, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		version string
+		filter  bool
+		delete  bool
+	}{
+		{"1.22.1", false, false},
+		{"2.18.0", true, true},
+		{"3.13.0", true, true},
+		{"4.0.1", true, true},
+		{"4.1.0", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			srv := &versionServer{version: tt.version}
+			c := srv.client(t)
+
+			msg := CreateMessageRequest{Content: "hi", UndatedContent: "[2024-05-01 02:30] hi", MessageType: "incoming", CreatedAt: sent}
+			if _, err := c.CreateMessageFromRequest(10, msg, nil, "src"); err != nil {
+				t.Fatalf("CreateMessageFromRequest returned error: %v", err)
+			}
+			if _, err := c.CreateMessage(10, "voice", "incoming", []string{audio}, "src2", "", time.Time{}); err != nil {
+				t.Fatalf("CreateMessage returned error: %v", err)
+			}
+			if len(srv.posts) != 2 {
+				t.Fatalf("expected each message posted once, got %v", srv.posts)
+			}
+			// Backdating and recorded audio are tried whatever the version, see minVersionBackdate
+			if _, got := srv.posts[0]["created_at"]; !got {
+				t.Errorf("expected created_at sent, got post %v", srv.posts[0])
+			}
+			if _, got := srv.posts[1]["is_recorded_audio"]; !got {
+				t.Errorf("expected is_recorded_audio sent, got post %v", srv.posts[1])
+			}
+
+			srv.requests = nil
+			if _, err := c.FindContactByIdentifier("120363000000000000@g.us", true); err != nil {
+				t.Fatalf("FindContactByIdentifier returned error: %v", err)
+			}
+			if got := len(srv.requests) > 0 && srv.requests[0] == "POST contacts/filter"; got != tt.filter {
+				t.Errorf("contact filter used = %v, want %v (requests %v)", got, tt.filter, srv.requests)
+			}
+
+			srv.requests = nil
+			err := c.DeleteMessage(10, 7)
+			if tt.delete && (err != nil || len(srv.requests) != 1) {
+				t.Errorf("expected the message deleted, got %v (requests %v)", err, srv.requests)
+			}
+			if !tt.delete && (!errors.Is(err, ErrMessageDeleteUnsupported) || len(srv.requests) != 0) {
+				t.Errorf("expected ErrMessageDeleteUnsupported without a request, got %v (requests %v)", err, srv.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package chatwoot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/disintegration/imaging"
+)
+
+// CHATWOOT_FORWARD_VIEW_ONCE modes.
+const (
+	ViewOnceFull        = "full"        // attach the media as sent
+	ViewOncePlaceholder = "placeholder" // post a note that view-once media was received, without the media
+	ViewOnceBlur        = "blur"        // attach a small blurred preview
+)
+
+// viewOncePreviewSize is the longest side of the blurred preview; the image is first shrunk to
+// viewOnceDetailSize so no detail survives the blur.
+const (
+	viewOncePreviewSize = 320
+	viewOnceDetailSize  = 24
+)
+
+// IsValidViewOnceMode reports whether mode is one of the CHATWOOT_FORWARD_VIEW_ONCE modes.
+func IsValidViewOnceMode(mode string) bool {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case ViewOnceFull, ViewOncePlaceholder, ViewOnceBlur:
+		return true
+	}
+	return false
+}
+
+// ViewOnceMode returns the configured CHATWOOT_FORWARD_VIEW_ONCE mode, falling back to placeholder
+// when it is not a known mode.
+func ViewOnceMode() string {
+	if !IsValidViewOnceMode(config.ChatwootForwardViewOnce) {
+		return ViewOncePlaceholder
+	}
+	return strings.ToLower(strings.TrimSpace(config.ChatwootForwardViewOnce))
+}
+
+// ViewOnceContent is the message posted for view-once media instead of (or above) the media itself.
+// caption is the text sent with the media, if any.
+func ViewOnceContent(mediaType, caption string) string {
+	var label string
+	switch mediaType {
+	case "video", "video_note":
+		label = "🎥 View-once video received"
+	case "audio", "ptt":
+		label = "🎤 View-once voice message received"
+	default:
+		label = "📷 View-once photo received"
+	}
+	if caption = strings.TrimSpace(caption); caption != "" {
+		return label + "\n" + caption
+	}
+	return label
+}
+
+// BlurViewOnceMedia writes a small, heavily blurred JPEG of a view-once photo or video and returns its
+// path; the caller removes it once posted. A video preview needs ffmpeg to grab a frame.
+func BlurViewOnceMedia(mediaPath string, isVideo bool) (string, error) {
+	source := mediaPath
+	if isVideo {
+		frame, err := extractVideoFrame(mediaPath)
+		if err != nil {
+			return "", err
+		}
+		defer removeTempFile(frame)
+		source = frame
+	}
+
+	img, err := imaging.Open(source, imaging.AutoOrientation(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to open view-once media: %w", err)
+	}
+	img = imaging.Fit(img, viewOnceDetailSize, viewOnceDetailSize, imaging.Box)
+	img = imaging.Fit(img, viewOncePreviewSize, viewOncePreviewSize, imaging.Linear)
+	img = imaging.Blur(img, 6)
+
+	tmpFile, err := createTempFile("view-once-*.jpg")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for preview: %w", err)
+	}
+	defer tmpFile.Close()
+
+	if err := imaging.Encode(tmpFile, img, imaging.JPEG, imaging.JPEGQuality(60)); err != nil {
+		removeTempFile(tmpFile.Name())
+		return "", fmt.Errorf("failed to encode preview: %w", err)
+	}
+	return tmpFile.Name(), nil
+}
+
+func extractVideoFrame(videoPath string) (string, error) {
+	return extractVideoFrameAt(videoPath, 0)
+}
+
+// extractVideoFrameAt writes the frame at offset as a JPEG and returns its path; the caller removes
+// it. Videos shorter than offset give their first frame.
+func extractVideoFrameAt(videoPath string, offset time.Duration) (string, error) {
+	if !utils.DetectFFmpeg().Available {
+		return "", fmt.Errorf("ffmpeg not found in PATH")
+	}
+
+	framePath, err := createTempPath("video-frame-*.jpg")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for frame: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "error"}
+	if offset > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64))
+	}
+	args = append(args, "-i", videoPath, "-vframes", "1", framePath)
+	output, err := exec.CommandContext(ctx, "ffmpeg", args...).CombinedOutput()
+	if err == nil {
+		if info, statErr := os.Stat(framePath); statErr == nil && info.Size() > 0 {
+			return framePath, nil
+		}
+	}
+	removeTempFile(framePath)
+	if offset > 0 {
+		return extractVideoFrameAt(videoPath, 0)
+	}
+	if err == nil {
+		return "", fmt.Errorf("failed to grab a video frame: ffmpeg wrote no image")
+	}
+	return "", fmt.Errorf("failed to grab a video frame: %v (%s)", err, strings.TrimSpace(string(output)))
+}
@@ -0,0 +1,139 @@
+package chatwoot
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/disintegration/imaging"
+)
+
+func useViewOnceMode(t *testing.T, mode string) {
+	t.Helper()
+	prev := config.ChatwootForwardViewOnce
+	config.ChatwootForwardViewOnce = mode
+	t.Cleanup(func() { config.ChatwootForwardViewOnce = prev })
+}
+
+func TestViewOnceMode(t *testing.T) {
+	tests := map[string]string{
+		"full":        ViewOnceFull,
+		" Blur ":      ViewOnceBlur,
+		"placeholder": ViewOncePlaceholder,
+		"":            ViewOncePlaceholder,
+		"hide":        ViewOncePlaceholder,
+	}
+	for configured, want := range tests {
+		useViewOnceMode(t, configured)
+		if got := ViewOnceMode(); got != want {
+			t.Errorf("ViewOnceMode() with %q = %q, want %q", configured, got, want)
+		}
+	}
+}
+
+func TestViewOnceContent(t *testing.T) {
+	if got := ViewOnceContent("image", ""); got != "📷 View-once photo received" {
+		t.Errorf("unexpected photo content %q", got)
+	}
+	if got := ViewOnceContent("video", " look "); got != "🎥 View-once video received\nlook" {
+		t.Errorf("unexpected video content %q", got)
+	}
+	if got := ViewOnceContent("ptt", ""); got != "🎤 View-once voice message received" {
+		t.Errorf("unexpected voice content %q", got)
+	}
+}
+
+func writeTestPhoto(t *testing.T) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 1200, 800))
+	for x := 0; x < 1200; x++ {
+		for y := 0; y < 800; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "photo.png")
+	if err := imaging.Save(img, path); err != nil {
+		t.Fatalf("failed to write photo: %v", err)
+	}
+	return path
+}
+
+func TestBlurViewOnceMedia_WritesSmallJPEG(t *testing.T) {
+	preview, err := BlurViewOnceMedia(writeTestPhoto(t), false)
+	if err != nil {
+		t.Fatalf("BlurViewOnceMedia returned error: %v", err)
+	}
+	defer os.Remove(preview)
+
+	if !strings.HasSuffix(preview, ".jpg") {
+		t.Errorf("expected a .jpg preview, got %s", preview)
+	}
+	img, err := imaging.Open(preview)
+	if err != nil {
+		t.Fatalf("preview is not an image: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() > viewOncePreviewSize || b.Dy() > viewOncePreviewSize {
+		t.Errorf("expected the preview to fit %dpx, got %v", viewOncePreviewSize, b)
+	}
+}
+
+func TestBlurViewOnceMedia_RejectsNonImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.txt")
+	if err := os.WriteFile(path, []byte("not an image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if preview, err := BlurViewOnceMedia(path, false); err == nil {
+		os.Remove(preview)
+		t.Fatal("expected an error for a non-image file")
+	}
+}
+
+func TestSyncMessage_ViewOnceModes(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+
+	s := &SyncService{client: &Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}}
+	msg := &domainChatStorage.Message{
+		ID:         "M1",
+		Content:    "secret",
+		Timestamp:  time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+		MediaType:  "image",
+		URL:        "https://mmg.whatsapp.net/x",
+		MediaKey:   []byte{1},
+		IsViewOnce: true,
+	}
+	opts := DefaultSyncOptions()
+
+	// Without a WhatsApp client the download always fails, which shows whether it was attempted.
+	tests := map[string]string{
+		ViewOnceFull:        "[2026-05-01 12:00] secret [media unavailable]",
+		ViewOncePlaceholder: "[2026-05-01 12:00] 📷 View-once photo received\nsecret",
+		ViewOnceBlur:        "[2026-05-01 12:00] 📷 View-once photo received\nsecret",
+	}
+	for mode, want := range tests {
+		useViewOnceMode(t, mode)
+		if _, err := s.syncMessageReturnID(t.Context(), 10, msg, nil, opts, false); err != nil {
+			t.Fatalf("%s: syncMessageReturnID returned error: %v", mode, err)
+		}
+		if body["content"] != want {
+			t.Errorf("%s: content = %q, want %q", mode, body["content"], want)
+		}
+		if body["source_id"] != "wa:M1" {
+			t.Errorf("%s: source_id = %v, want the WhatsApp message ID", mode, body["source_id"])
+		}
+	}
+}
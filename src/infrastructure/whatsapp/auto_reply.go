@@ -3,6 +3,8 @@ package whatsapp
 import (
 	"context"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
@@ -14,11 +16,45 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-func handleAutoReply(ctx context.Context, evt *events.Message, chatStorageRepo domainChatStorage.IChatStorageRepository, client *whatsmeow.Client) {
-	if config.WhatsappAutoReplyMessage == "" {
-		return
+var (
+	autoReplyCooldownOnce sync.Once
+	autoReplyCooldowns    *utils.TTLCache[string, struct{}]
+)
+
+// autoReplyCoolingDown reports whether chatJID got an auto-reply from the device within
+// WhatsappAutoReplyCooldownSec, and starts a new cooldown when it did not.
+func autoReplyCoolingDown(deviceID, chatJID string) bool {
+	cooldown := time.Duration(config.WhatsappAutoReplyCooldownSec) * time.Second
+	if cooldown <= 0 {
+		return false
+	}
+	autoReplyCooldownOnce.Do(func() {
+		autoReplyCooldowns = utils.NewTTLCache[string, struct{}](10000, cooldown)
+		autoReplyCooldowns.StartSweeping(cooldown)
+	})
+	key := deviceID + "|" + chatJID
+	if _, ok := autoReplyCooldowns.Get(key); ok {
+		return true
 	}
+	autoReplyCooldowns.Set(key, struct{}{})
+	return false
+}
 
+// autoReplyMessage returns the auto-reply set for the chat of the device from Chatwoot, falling back
+// to WHATSAPP_AUTO_REPLY.
+func autoReplyMessage(chatStorageRepo domainChatStorage.IChatStorageRepository, deviceID, chatJID string) string {
+	if chatStorageRepo != nil {
+		reply, err := chatStorageRepo.GetChatAutoReply(deviceID, chatJID, time.Now())
+		if err != nil {
+			log.Warnf("Failed to load auto-reply for %s: %v", chatJID, err)
+		} else if reply != nil {
+			return reply.Message
+		}
+	}
+	return config.WhatsappAutoReplyMessage
+}
+
+func handleAutoReply(ctx context.Context, evt *events.Message, chatStorageRepo domainChatStorage.IChatStorageRepository, client *whatsmeow.Client) {
 	if client == nil {
 		return
 	}
@@ -64,6 +100,23 @@ func handleAutoReply(ctx context.Context, evt *events.Message, chatStorageRepo d
 		return
 	}
 
+	deviceID := ""
+	if inst, ok := DeviceFromContext(ctx); ok && inst != nil {
+		deviceID = inst.JID()
+		if deviceID == "" {
+			deviceID = inst.ID()
+		}
+	}
+	if deviceID == "" && client.Store != nil && client.Store.ID != nil {
+		deviceID = client.Store.ID.ToNonAD().String()
+	}
+
+	chatJID := evt.Info.Chat.ToNonAD().String()
+	message := autoReplyMessage(chatStorageRepo, deviceID, chatJID)
+	if message == "" || autoReplyCoolingDown(deviceID, chatJID) {
+		return
+	}
+
 	// Format recipient JID
 	recipientJID := utils.FormatJID(evt.Info.Sender.String())
 
@@ -71,7 +124,7 @@ func handleAutoReply(ctx context.Context, evt *events.Message, chatStorageRepo d
 	response, err := client.SendMessage(
 		ctx,
 		recipientJID,
-		&waE2E.Message{Conversation: proto.String(config.WhatsappAutoReplyMessage)},
+		&waE2E.Message{Conversation: proto.String(message)},
 	)
 
 	if err != nil {
@@ -90,11 +143,11 @@ func handleAutoReply(ctx context.Context, evt *events.Message, chatStorageRepo d
 		// Store the sent auto-reply message
 		if err := chatStorageRepo.StoreSentMessageWithContext(
 			ctx,
-			response.ID,                     // Message ID from WhatsApp response
-			senderJID,                       // Our JID as sender
-			recipientJID.String(),           // Recipient JID
-			config.WhatsappAutoReplyMessage, // Auto-reply content
-			response.Timestamp,              // Timestamp from response
+			response.ID,           // Message ID from WhatsApp response
+			senderJID,             // Our JID as sender
+			recipientJID.String(), // Recipient JID
+			message,               // Auto-reply content
+			response.Timestamp,    // Timestamp from response
 		); err != nil {
 			// Log storage error but don't fail the auto-reply
 			log.Errorf("Failed to store auto-reply message in chat storage: %v", err)
@@ -0,0 +1,62 @@
+package whatsapp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+type chatAutoReplyRepo struct {
+	domainChatStorage.IChatStorageRepository
+	replies map[string]string
+}
+
+func (r *chatAutoReplyRepo) GetChatAutoReply(_, chatJID string, _ time.Time) (*domainChatStorage.ChatAutoReply, error) {
+	if msg, ok := r.replies[chatJID]; ok {
+		return &domainChatStorage.ChatAutoReply{ChatJID: chatJID, Message: msg}, nil
+	}
+	return nil, nil
+}
+
+func TestAutoReplyMessage_PrefersChatEntry(t *testing.T) {
+	original := config.WhatsappAutoReplyMessage
+	config.WhatsappAutoReplyMessage = "We reply within a day"
+	t.Cleanup(func() { config.WhatsappAutoReplyMessage = original })
+
+	repo := &chatAutoReplyRepo{replies: map[string]string{"5511999@s.whatsapp.net": "Away until Monday"}}
+	if got := autoReplyMessage(repo, "device-1", "5511999@s.whatsapp.net"); got != "Away until Monday" {
+		t.Fatalf("expected the per-chat auto-reply, got %q", got)
+	}
+	if got := autoReplyMessage(repo, "device-1", "5511888@s.whatsapp.net"); got != "We reply within a day" {
+		t.Fatalf("expected the global auto-reply, got %q", got)
+	}
+	if got := autoReplyMessage(nil, "device-1", "5511999@s.whatsapp.net"); got != "We reply within a day" {
+		t.Fatalf("expected the global auto-reply without storage, got %q", got)
+	}
+}
+
+func TestAutoReplyCoolingDown(t *testing.T) {
+	original := config.WhatsappAutoReplyCooldownSec
+	t.Cleanup(func() { config.WhatsappAutoReplyCooldownSec = original })
+
+	config.WhatsappAutoReplyCooldownSec = 0
+	if autoReplyCoolingDown("device-1", "5511777@s.whatsapp.net") || autoReplyCoolingDown("device-1", "5511777@s.whatsapp.net") {
+		t.Fatal("expected no cooldown when disabled")
+	}
+
+	config.WhatsappAutoReplyCooldownSec = 3600
+	if autoReplyCoolingDown("device-1", "5511666@s.whatsapp.net") {
+		t.Fatal("expected the first auto-reply to go out")
+	}
+	if !autoReplyCoolingDown("device-1", "5511666@s.whatsapp.net") {
+		t.Fatal("expected the second auto-reply to be held back")
+	}
+	if autoReplyCoolingDown("device-1", "5511555@s.whatsapp.net") {
+		t.Fatal("expected other chats to be unaffected")
+	}
+	if autoReplyCoolingDown("device-2", "5511666@s.whatsapp.net") {
+		t.Fatal("expected other devices to be unaffected")
+	}
+}
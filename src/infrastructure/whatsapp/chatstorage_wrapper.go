@@ -53,6 +53,10 @@ func (r *deviceChatStorage) GetChatByDevice(deviceID, jid string) (*domainChatSt
 	return r.base.GetChatByDevice(deviceID, jid)
 }
 
+func (r *deviceChatStorage) UpdateChatName(jid, name string) error {
+	return r.base.UpdateChatName(jid, name)
+}
+
 func (r *deviceChatStorage) GetChats(filter *domainChatStorage.ChatFilter) ([]*domainChatStorage.Chat, error) {
 	if filter != nil && filter.DeviceID == "" {
 		filter.DeviceID = r.deviceID
@@ -76,6 +80,10 @@ func (r *deviceChatStorage) StoreMessagesBatch(messages []*domainChatStorage.Mes
 	return r.base.StoreMessagesBatch(messages)
 }
 
+func (r *deviceChatStorage) CreateMessagesBatch(ctx context.Context, messages []*domainChatStorage.Message) error {
+	return r.base.CreateMessagesBatch(ctx, messages)
+}
+
 func (r *deviceChatStorage) GetMessageByID(id string) (*domainChatStorage.Message, error) {
 	return r.base.GetMessageByID(id)
 }
@@ -95,6 +103,15 @@ func (r *deviceChatStorage) SearchMessages(deviceID, chatJID, searchText string,
 	return r.base.SearchMessages(targetDeviceID, chatJID, searchText, limit)
 }
 
+func (r *deviceChatStorage) SearchMessagesFullText(filter *domainChatStorage.MessageSearchFilter) (*domainChatStorage.MessageSearchPage, error) {
+	if filter != nil && filter.DeviceID == "" {
+		scoped := *filter
+		scoped.DeviceID = r.deviceID
+		filter = &scoped
+	}
+	return r.base.SearchMessagesFullText(filter)
+}
+
 func (r *deviceChatStorage) DeleteMessage(id, chatJID string) error {
 	return r.base.DeleteMessageByDevice(r.deviceID, id, chatJID)
 }
@@ -147,6 +164,29 @@ func (r *deviceChatStorage) InitializeSchema() error {
 	return r.base.InitializeSchema()
 }
 
+func (r *deviceChatStorage) UpdateMessageReceipt(receipts ...*domainChatStorage.MessageReceipt) error {
+	return r.base.UpdateMessageReceipt(receipts...)
+}
+
+func (r *deviceChatStorage) GetMessageReceipts(deviceID string, messageIDs []string) (map[string][]*domainChatStorage.MessageReceipt, error) {
+	if deviceID == "" {
+		deviceID = r.deviceID
+	}
+	return r.base.GetMessageReceipts(deviceID, messageIDs)
+}
+
+func (r *deviceChatStorage) PruneStorage(ctx context.Context, opts domainChatStorage.StoragePruneOptions) (*domainChatStorage.StoragePruneResult, error) {
+	return r.base.PruneStorage(ctx, opts)
+}
+
+func (r *deviceChatStorage) GetSchemaVersion() (*domainChatStorage.SchemaVersion, error) {
+	return r.base.GetSchemaVersion()
+}
+
+func (r *deviceChatStorage) GetDBStats() domainChatStorage.DBStats {
+	return r.base.GetDBStats()
+}
+
 func (r *deviceChatStorage) DeleteDeviceData(deviceID string) error {
 	if r.base == nil {
 		return nil
@@ -186,10 +226,221 @@ func (d *deviceChatStorage) IsMessageExported(deviceID, chatJID, messageKey stri
 	return d.base.IsMessageExported(deviceID, chatJID, messageKey)
 }
 
+func (d *deviceChatStorage) GetExportedChatwootMessageID(deviceID, chatJID, messageKey string) (int, error) {
+	return d.base.GetExportedChatwootMessageID(deviceID, chatJID, messageKey)
+}
+
 func (d *deviceChatStorage) MarkMessageExported(deviceID, chatJID, messageKey string, chatwootMessageID int) error {
 	return d.base.MarkMessageExported(deviceID, chatJID, messageKey, chatwootMessageID)
 }
 
+func (d *deviceChatStorage) GetChatwootFingerprint() (string, error) {
+	return d.base.GetChatwootFingerprint()
+}
+
+func (d *deviceChatStorage) SaveChatwootFingerprint(fingerprint string) error {
+	return d.base.SaveChatwootFingerprint(fingerprint)
+}
+
+func (d *deviceChatStorage) GetChatwootConfig() (*domainChatStorage.ChatwootConfig, error) {
+	return d.base.GetChatwootConfig()
+}
+
+func (d *deviceChatStorage) SaveChatwootConfig(cfg *domainChatStorage.ChatwootConfig) error {
+	return d.base.SaveChatwootConfig(cfg)
+}
+
+func (d *deviceChatStorage) ClearChatwootState() (int64, error) {
+	return d.base.ClearChatwootState()
+}
+
+func (d *deviceChatStorage) SaveChatwootSentMessage(sent *domainChatStorage.ChatwootSentMessage) error {
+	return d.base.SaveChatwootSentMessage(sent)
+}
+
+func (d *deviceChatStorage) GetChatwootSentMessageIDs(chatwootMessageID int) ([]string, error) {
+	return d.base.GetChatwootSentMessageIDs(chatwootMessageID)
+}
+
+func (d *deviceChatStorage) GetChatwootSentMessages(chatwootMessageID int) ([]*domainChatStorage.ChatwootSentMessage, error) {
+	return d.base.GetChatwootSentMessages(chatwootMessageID)
+}
+
+func (d *deviceChatStorage) GetChatwootContactID(account, identifier string) (int, error) {
+	return d.base.GetChatwootContactID(account, identifier)
+}
+
+func (d *deviceChatStorage) SaveChatwootContactID(account, identifier string, contactID int) error {
+	return d.base.SaveChatwootContactID(account, identifier, contactID)
+}
+
+func (d *deviceChatStorage) DeleteChatwootContactID(account, identifier string) error {
+	return d.base.DeleteChatwootContactID(account, identifier)
+}
+
+func (d *deviceChatStorage) GetChatwootSentMessageConversation(messageID string) (int, error) {
+	return d.base.GetChatwootSentMessageConversation(messageID)
+}
+
+func (d *deviceChatStorage) CreateChatwootSyncRun(run *domainChatStorage.ChatwootSyncRun) error {
+	return d.base.CreateChatwootSyncRun(run)
+}
+
+func (d *deviceChatStorage) UpdateChatwootSyncRun(run *domainChatStorage.ChatwootSyncRun) error {
+	return d.base.UpdateChatwootSyncRun(run)
+}
+
+func (d *deviceChatStorage) GetChatwootSyncRuns(deviceID string, limit int) ([]*domainChatStorage.ChatwootSyncRun, error) {
+	return d.base.GetChatwootSyncRuns(deviceID, limit)
+}
+
+func (d *deviceChatStorage) MarkInterruptedChatwootSyncRuns() (int64, error) {
+	return d.base.MarkInterruptedChatwootSyncRuns()
+}
+
+func (d *deviceChatStorage) SaveChatwootFailedExport(failure *domainChatStorage.ChatwootFailedExport) error {
+	return d.base.SaveChatwootFailedExport(failure)
+}
+
+func (d *deviceChatStorage) DeleteChatwootFailedExport(deviceID, chatJID, messageKey string) error {
+	return d.base.DeleteChatwootFailedExport(deviceID, chatJID, messageKey)
+}
+
+func (d *deviceChatStorage) GetChatwootFailedExports(deviceID string, limit int) ([]*domainChatStorage.ChatwootFailedExport, error) {
+	return d.base.GetChatwootFailedExports(deviceID, limit)
+}
+
+func (d *deviceChatStorage) CountChatwootFailedExports(deviceID string) (map[string]int, error) {
+	return d.base.CountChatwootFailedExports(deviceID)
+}
+
+func (d *deviceChatStorage) PurgeChatwootFailedExports(olderThan time.Time) (int64, error) {
+	return d.base.PurgeChatwootFailedExports(olderThan)
+}
+
 func (d *deviceChatStorage) IsChatwootMessageFromUs(chatwootMessageID int) (bool, error) {
 	return d.base.IsChatwootMessageFromUs(chatwootMessageID)
 }
+
+func (d *deviceChatStorage) EnqueueWebhookOutbox(entry *chatstorage.WebhookOutboxEntry) error {
+	return d.base.EnqueueWebhookOutbox(entry)
+}
+
+func (d *deviceChatStorage) GetDueWebhookOutbox(now time.Time, limit int) ([]*chatstorage.WebhookOutboxEntry, error) {
+	return d.base.GetDueWebhookOutbox(now, limit)
+}
+
+func (d *deviceChatStorage) GetWebhookOutboxEntry(id int64) (*chatstorage.WebhookOutboxEntry, error) {
+	return d.base.GetWebhookOutboxEntry(id)
+}
+
+func (d *deviceChatStorage) ListWebhookOutbox(status string, limit int) ([]*chatstorage.WebhookOutboxEntry, error) {
+	return d.base.ListWebhookOutbox(status, limit)
+}
+
+func (d *deviceChatStorage) UpdateWebhookOutbox(entry *chatstorage.WebhookOutboxEntry) error {
+	return d.base.UpdateWebhookOutbox(entry)
+}
+
+func (d *deviceChatStorage) DeleteWebhookOutbox(id int64) error {
+	return d.base.DeleteWebhookOutbox(id)
+}
+
+func (d *deviceChatStorage) CountWebhookOutbox() (map[string]int64, error) {
+	return d.base.CountWebhookOutbox()
+}
+
+func (d *deviceChatStorage) SavePendingCSATSurvey(survey *domainChatStorage.PendingCSATSurvey) error {
+	return d.base.SavePendingCSATSurvey(survey)
+}
+
+func (d *deviceChatStorage) GetPendingCSATSurvey(identifier string, now time.Time) (*domainChatStorage.PendingCSATSurvey, error) {
+	return d.base.GetPendingCSATSurvey(identifier, now)
+}
+
+func (d *deviceChatStorage) DeletePendingCSATSurvey(identifier string) error {
+	return d.base.DeletePendingCSATSurvey(identifier)
+}
+
+func (d *deviceChatStorage) SaveLIDMapping(lid, phoneJID string) error {
+	return d.base.SaveLIDMapping(lid, phoneJID)
+}
+
+func (d *deviceChatStorage) GetPhoneForLID(lid string) (string, error) {
+	return d.base.GetPhoneForLID(lid)
+}
+
+func (d *deviceChatStorage) GetUnlinkedLIDMappings(limit int) ([]*domainChatStorage.LIDMapping, error) {
+	return d.base.GetUnlinkedLIDMappings(limit)
+}
+
+func (d *deviceChatStorage) MarkLIDMappingLinked(lid string) error {
+	return d.base.MarkLIDMappingLinked(lid)
+}
+
+func (d *deviceChatStorage) SaveChatwootInbox(inbox *domainChatStorage.ChatwootInbox) error {
+	return d.base.SaveChatwootInbox(inbox)
+}
+
+func (d *deviceChatStorage) ListChatwootInboxes() ([]*domainChatStorage.ChatwootInbox, error) {
+	return d.base.ListChatwootInboxes()
+}
+
+func (d *deviceChatStorage) AddChatwootAuditEntry(entry *domainChatStorage.ChatwootAuditEntry) error {
+	return d.base.AddChatwootAuditEntry(entry)
+}
+
+func (d *deviceChatStorage) GetChatwootAuditEntries(chatJID string, limit int) ([]*domainChatStorage.ChatwootAuditEntry, error) {
+	return d.base.GetChatwootAuditEntries(chatJID, limit)
+}
+
+func (d *deviceChatStorage) PurgeChatwootAuditEntries(olderThan time.Time) (int64, error) {
+	return d.base.PurgeChatwootAuditEntries(olderThan)
+}
+
+func (d *deviceChatStorage) EnqueueMaintenanceEvent(entry *domainChatStorage.MaintenanceBufferEntry) error {
+	return d.base.EnqueueMaintenanceEvent(entry)
+}
+
+func (d *deviceChatStorage) ListMaintenanceEvents(limit int) ([]*domainChatStorage.MaintenanceBufferEntry, error) {
+	return d.base.ListMaintenanceEvents(limit)
+}
+
+func (d *deviceChatStorage) SaveChatAutoReply(reply *domainChatStorage.ChatAutoReply) error {
+	if reply != nil && reply.DeviceID == "" {
+		reply.DeviceID = d.deviceID
+	}
+	return d.base.SaveChatAutoReply(reply)
+}
+
+func (d *deviceChatStorage) GetChatAutoReply(deviceID, chatJID string, now time.Time) (*domainChatStorage.ChatAutoReply, error) {
+	return d.base.GetChatAutoReply(deviceID, chatJID, now)
+}
+
+func (d *deviceChatStorage) ListChatAutoReplies(now time.Time) ([]*domainChatStorage.ChatAutoReply, error) {
+	return d.base.ListChatAutoReplies(now)
+}
+
+func (d *deviceChatStorage) DeleteChatAutoReply(deviceID, chatJID string) (bool, error) {
+	return d.base.DeleteChatAutoReply(deviceID, chatJID)
+}
+
+func (d *deviceChatStorage) SavePoll(poll *domainChatStorage.Poll) error {
+	return d.base.SavePoll(poll)
+}
+
+func (d *deviceChatStorage) SavePollVote(vote *domainChatStorage.PollVote) error {
+	return d.base.SavePollVote(vote)
+}
+
+func (d *deviceChatStorage) GetPollResults(pollMessageID string) (*domainChatStorage.PollResults, error) {
+	return d.base.GetPollResults(pollMessageID)
+}
+
+func (d *deviceChatStorage) DeleteMaintenanceEvent(id int64) error {
+	return d.base.DeleteMaintenanceEvent(id)
+}
+
+func (d *deviceChatStorage) CountMaintenanceEvents() (int64, error) {
+	return d.base.CountMaintenanceEvents()
+}
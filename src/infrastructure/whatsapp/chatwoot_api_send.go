@@ -0,0 +1,124 @@
+package whatsapp
+
+import (
+	"context"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+type chatwootSendContextKey struct{}
+
+// ContextSentFromChatwoot marks ctx as the context of a send an agent made in Chatwoot, so
+// ForwardSentMessageToChatwoot does not post the message back to its conversation.
+func ContextSentFromChatwoot(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, chatwootSendContextKey{}, true)
+}
+
+// isSentFromChatwoot reports whether ctx was marked by ContextSentFromChatwoot.
+func isSentFromChatwoot(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	marked, _ := ctx.Value(chatwootSendContextKey{}).(bool)
+	return marked
+}
+
+// forwardsAPISendsToChatwoot reports whether a message sent through the API with ctx is posted to
+// Chatwoot. whatsmeow does not deliver the messages a device sends itself as events, so without
+// CHATWOOT_FORWARD_API_SENDS agents never see them.
+func forwardsAPISendsToChatwoot(ctx context.Context) bool {
+	return config.ChatwootEnabled && config.ChatwootForwardAPISends && !isSentFromChatwoot(ctx)
+}
+
+// ForwardSentMessageToChatwoot posts a message client sent to recipient through the API to the
+// Chatwoot conversation of the chat, as an outgoing message, the way a message sent from the phone
+// is. Its source_id is the WhatsApp message ID like any forwarded message, so the Chatwoot webhook
+// ignores it. Sends made for a Chatwoot agent are left out. The message is posted in the background,
+// on the forward worker of its chat like a received message, so it keeps its place among the other
+// messages of the chat and is saved for replay when the queue is full or the shutdown cuts it off.
+func ForwardSentMessageToChatwoot(ctx context.Context, client *whatsmeow.Client, recipient types.JID, resp whatsmeow.SendResponse, msg *waE2E.Message) {
+	if !forwardsAPISendsToChatwoot(ctx) || client == nil || client.Store == nil || client.Store.ID == nil || resp.ID == "" || msg == nil {
+		return
+	}
+
+	// The request context ends with the response; keep only the device.
+	forwardCtx := context.Background()
+	deviceID := ""
+	if inst, found := DeviceFromContext(ctx); found {
+		forwardCtx = ContextWithDevice(forwardCtx, inst)
+		if inst != nil {
+			deviceID = inst.ID()
+		}
+	}
+	evt := sentMessageEvent(client.Store.ID.ToNonAD(), client.Store.PushName, recipient, resp, msg)
+
+	work := trackInflight("Chatwoot API send "+resp.ID, func(persistCtx context.Context, cause error) {
+		if payload := sentMessagePayload(persistCtx, client, evt); payload != nil {
+			newInflightForward(nil, true).persist(persistCtx, payload, EventTypeMessage, cause)
+		}
+	})
+	dispatchOrderedForward(deviceID, forwardOrderKey(forwardCtx, recipient, client), evt.Info.Timestamp, func() {
+		defer work.done()
+		sendCtx, cancel := context.WithTimeout(forwardCtx, 30*time.Second)
+		defer cancel()
+		payload := sentMessagePayload(sendCtx, client, evt)
+		if payload == nil {
+			return
+		}
+		fwd := beginForward(forwardCtx, payload, EventTypeMessage, nil, true)
+		defer fwd.done()
+		work.handOver()
+		forwardChatwootMessage(contextWithForward(sendCtx, fwd), EventTypeMessage, payload)
+	}, func() {
+		defer work.done()
+		spillCtx, cancel := context.WithTimeout(forwardCtx, 30*time.Second)
+		defer cancel()
+		work.save(spillCtx, errForwardQueueFull)
+	})
+}
+
+// sentMessagePayload builds the forwarded payload of a message sent through the API, or returns nil
+// when it is not a message event.
+func sentMessagePayload(ctx context.Context, client *whatsmeow.Client, evt *events.Message) map[string]any {
+	webhookEvent, err := createWebhookEvent(ctx, client, evt)
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to build message %s sent through the API: %v", evt.Info.ID, err)
+		return nil
+	}
+	if webhookEvent.Event != EventTypeMessage {
+		return nil
+	}
+	return map[string]any{
+		"event":     webhookEvent.Event,
+		"device_id": webhookEvent.DeviceID,
+		"payload":   webhookEvent.Payload,
+	}
+}
+
+// sentMessageEvent builds the event WhatsApp would deliver to another device of sender for a message
+// sent to recipient.
+func sentMessageEvent(sender types.JID, pushName string, recipient types.JID, resp whatsmeow.SendResponse, msg *waE2E.Message) *events.Message {
+	return &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{
+				Chat:     recipient.ToNonAD(),
+				Sender:   sender,
+				IsFromMe: true,
+				IsGroup:  recipient.Server == types.GroupServer,
+			},
+			ID:        resp.ID,
+			Timestamp: resp.Timestamp,
+			PushName:  pushName,
+		},
+		Message: msg,
+	}
+}
@@ -0,0 +1,108 @@
+package whatsapp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestForwardsAPISendsToChatwoot(t *testing.T) {
+	origEnabled, origForward := config.ChatwootEnabled, config.ChatwootForwardAPISends
+	t.Cleanup(func() {
+		config.ChatwootEnabled, config.ChatwootForwardAPISends = origEnabled, origForward
+	})
+
+	tests := []struct {
+		enabled, forward, fromChatwoot bool
+		want                           bool
+	}{
+		{true, true, false, true},
+		{true, true, true, false},
+		{true, false, false, false},
+		{false, true, false, false},
+	}
+	for _, tt := range tests {
+		config.ChatwootEnabled, config.ChatwootForwardAPISends = tt.enabled, tt.forward
+		ctx := context.Background()
+		if tt.fromChatwoot {
+			ctx = ContextSentFromChatwoot(ctx)
+		}
+		if got := forwardsAPISendsToChatwoot(ctx); got != tt.want {
+			t.Errorf("forwardsAPISendsToChatwoot(enabled %v, forward %v, from Chatwoot %v) = %v, want %v",
+				tt.enabled, tt.forward, tt.fromChatwoot, got, tt.want)
+		}
+	}
+}
+
+func TestSentMessageEvent_PayloadIsOutgoing(t *testing.T) {
+	sender := types.NewJID("628111", types.DefaultUserServer)
+	recipient := types.NewJID("628222", types.DefaultUserServer)
+	sent := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	msg := &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{Text: proto.String("your order shipped")}}
+
+	evt := sentMessageEvent(sender, "Shop", recipient, whatsmeow.SendResponse{ID: "3EB0ABC", Timestamp: sent}, msg)
+	event, payload, err := buildEventPayload(context.Background(), nil, evt)
+	if err != nil {
+		t.Fatalf("buildEventPayload returned error: %v", err)
+	}
+	if event != EventTypeMessage {
+		t.Fatalf("expected a message event, got %s", event)
+	}
+	if payload["id"] != "3EB0ABC" || payload["is_from_me"] != true || payload["chat_id"] != recipient.String() ||
+		payload["from"] != sender.String() || payload["body"] != "your order shipped" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+	if got := chatwootMessageTypeFromPayload(payload); got != "outgoing" {
+		t.Errorf("expected an outgoing Chatwoot message, got %s", got)
+	}
+
+	group := types.NewJID("120363000000000000", types.GroupServer)
+	if evt := sentMessageEvent(sender, "", group, whatsmeow.SendResponse{ID: "3EB0DEF"}, msg); !evt.Info.IsGroup {
+		t.Error("expected a message to a group to be a group message")
+	}
+}
+
+// An API send takes the forward queue of its chat: behind a busy worker it is spilled to the outbox
+// like a received message, not posted from a goroutine of its own.
+func TestForwardSentMessageToChatwoot_UsesForwardQueue(t *testing.T) {
+	origEnabled, origForward := config.ChatwootEnabled, config.ChatwootForwardAPISends
+	config.ChatwootEnabled, config.ChatwootForwardAPISends = true, true
+	t.Cleanup(func() { config.ChatwootEnabled, config.ChatwootForwardAPISends = origEnabled, origForward })
+	useForwardPool(t, 1, 1, 0)
+	repo := newMemoryOutboxRepo()
+	withOutboxRepo(t, repo, 3)
+
+	recipient := types.NewJID("628222", types.DefaultUserServer)
+	rec := &forwardRecorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+	rec.dispatch(recipient.String(), "A", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	rec.dispatch(recipient.String(), "B", nil) // Fills the queue of the only worker
+
+	sender := types.NewJID("628111", types.DefaultUserServer)
+	client := &whatsmeow.Client{Store: &store.Device{ID: &sender, PushName: "Shop"}}
+	msg := &waE2E.Message{Conversation: proto.String("your order shipped")}
+	ForwardSentMessageToChatwoot(context.Background(), client, recipient, whatsmeow.SendResponse{ID: "3EB0API", Timestamp: time.Now()}, msg)
+	close(release)
+	waitForwardsIdle(t)
+
+	if len(repo.entries) != 1 {
+		t.Fatalf("expected the API send spilled to the outbox, got %d entries", len(repo.entries))
+	}
+	for _, e := range repo.entries {
+		if e.URL != chatwootOutboxURL || e.LastError != errForwardQueueFull.Error() || !strings.Contains(e.Payload, "3EB0API") {
+			t.Errorf("unexpected queued entry: %+v", e)
+		}
+	}
+}
@@ -1,6 +1,26 @@
 package whatsapp
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/disintegration/imaging"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
 
 func TestChatwootMessageTypeFromPayload(t *testing.T) {
 	tests := []struct {
@@ -43,3 +63,372 @@ func TestChatwootMessageTypeFromPayload(t *testing.T) {
 		})
 	}
 }
+
+func TestChatwootGroupSenderName(t *testing.T) {
+	if got := chatwootGroupSenderName("John", ""); got != "John" {
+		t.Fatalf("expected plain sender without alias, got %q", got)
+	}
+	if got := chatwootGroupSenderName("John", "sales"); got != "[sales] John" {
+		t.Fatalf("expected alias prefix, got %q", got)
+	}
+	if got := chatwootGroupSenderName("", "sales"); got != "" {
+		t.Fatalf("expected empty sender to stay empty, got %q", got)
+	}
+}
+
+func TestBuildChatwootMessageContent_LocationWithThumbnail(t *testing.T) {
+	originalMedia, originalMapURL := config.PathMedia, config.ChatwootLocationMapURL
+	config.PathMedia = t.TempDir()
+	config.ChatwootLocationMapURL = "https://maps.example/{lat},{lon}"
+	defer func() { config.PathMedia, config.ChatwootLocationMapURL = originalMedia, originalMapURL }()
+
+	data := map[string]interface{}{
+		"location": &waE2E.LocationMessage{
+			DegreesLatitude:  proto.Float64(-23.5),
+			DegreesLongitude: proto.Float64(-46.6),
+			Name:             proto.String("Office"),
+			JPEGThumbnail:    []byte{0xff, 0xd8, 0xff, 0xd9},
+		},
+	}
+
+	content, attachments, generated, supported := buildChatwootMessageContent(data, "", "")
+	if !supported {
+		t.Fatal("expected location to be supported")
+	}
+	if content != "📍 Location: Office\nhttps://maps.example/-23.500000,-46.600000" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if len(attachments) != 1 || filepath.Dir(attachments[0]) != config.PathMedia {
+		t.Fatalf("expected the thumbnail to be attached from the media folder, got %v", attachments)
+	}
+	if body, err := os.ReadFile(attachments[0]); err != nil || len(body) != 4 {
+		t.Fatalf("thumbnail not written: %v", err)
+	}
+	if len(generated) != 1 || generated[0] != attachments[0] {
+		t.Fatalf("expected the thumbnail to be reported for cleanup, got %v", generated)
+	}
+}
+
+func TestBuildChatwootMessageContent_ContactsArrayAttachesVCards(t *testing.T) {
+	originalMedia := config.PathMedia
+	config.PathMedia = t.TempDir()
+	defer func() { config.PathMedia = originalMedia }()
+
+	ana := "BEGIN:VCARD\nVERSION:3.0\nFN:Ana Souza\nTEL;type=CELL:+55 11 91234-5678\nTEL;type=WORK:+55 11 3333-4444\nEND:VCARD"
+	bob := "BEGIN:VCARD\nVERSION:3.0\nTEL;waid=14155550100:+1 415 555 0100\nEND:VCARD"
+	data := map[string]interface{}{
+		"contacts_array": &waE2E.ContactsArrayMessage{
+			DisplayName: proto.String("2 contacts"),
+			Contacts: []*waE2E.ContactMessage{
+				{DisplayName: proto.String("Ana"), Vcard: proto.String(ana)},
+				{DisplayName: proto.String("Bob"), Vcard: proto.String(bob)},
+			},
+		},
+	}
+
+	content, attachments, generated, supported := buildChatwootMessageContent(data, "", "")
+	if !supported {
+		t.Fatal("expected contacts to be supported")
+	}
+	want := "Contacts (2):\n- Ana Souza (+55 11 91234-5678, +55 11 3333-4444)\n- Bob (+1 415 555 0100)"
+	if content != want {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if len(attachments) != 1 || len(generated) != 1 || filepath.Ext(attachments[0]) != ".vcf" {
+		t.Fatalf("expected one generated .vcf attachment, got %v (generated %v)", attachments, generated)
+	}
+	body, err := os.ReadFile(attachments[0])
+	if err != nil || !strings.Contains(string(body), "FN:Ana Souza") || !strings.Contains(string(body), "waid=14155550100") {
+		t.Fatalf("expected both vCards in the file, got %q (err %v)", body, err)
+	}
+
+	removeGeneratedAttachments(generated)
+	if _, err := os.Stat(attachments[0]); !os.IsNotExist(err) {
+		t.Fatalf("expected the .vcf file to be removed, got %v", err)
+	}
+}
+
+func TestBuildChatwootMessageContent_ContactWithoutVCard(t *testing.T) {
+	data := map[string]interface{}{"contact": &waE2E.ContactMessage{DisplayName: proto.String("Ana")}}
+
+	content, attachments, _, supported := buildChatwootMessageContent(data, "", "")
+	if !supported || content != "Contact: Ana" || len(attachments) != 0 {
+		t.Fatalf("expected a name-only summary without attachment, got %q %v", content, attachments)
+	}
+}
+
+func TestBuildChatwootMessageContent_Poll(t *testing.T) {
+	data := map[string]interface{}{
+		"poll": &waE2E.PollCreationMessage{
+			Name: proto.String("Lunch?"),
+			Options: []*waE2E.PollCreationMessage_Option{
+				{OptionName: proto.String("Pizza")},
+				{OptionName: proto.String("Sushi")},
+			},
+			SelectableOptionsCount: proto.Uint32(1),
+		},
+	}
+
+	content, _, _, supported := buildChatwootMessageContent(data, "Family", "Ana")
+	if !supported || content != "Ana: 📊 Poll: Lunch?\n(choose one)\n- Pizza\n- Sushi" {
+		t.Fatalf("unexpected poll content %q (supported %v)", content, supported)
+	}
+}
+
+func TestResolvePollOptions(t *testing.T) {
+	options := []string{"Pizza", "Sushi", "Salad"}
+	hashes := whatsmeow.HashPollOptions([]string{"Salad", "Pizza", "Unknown"})
+
+	got := resolvePollOptions(options, hashes)
+	if len(got) != 2 || got[0] != "Pizza" || got[1] != "Salad" {
+		t.Fatalf("expected Pizza and Salad in poll order, got %v", got)
+	}
+	if got := resolvePollOptions(options, nil); len(got) != 0 {
+		t.Fatalf("expected a retraction to resolve to nothing, got %v", got)
+	}
+}
+
+func TestBuildChatwootMessageContent_ViewOnceModes(t *testing.T) {
+	prev := config.ChatwootForwardViewOnce
+	defer func() { config.ChatwootForwardViewOnce = prev }()
+
+	photo := filepath.Join(t.TempDir(), "photo.png")
+	if err := imaging.Save(image.NewNRGBA(image.Rect(0, 0, 64, 48)), photo); err != nil {
+		t.Fatalf("failed to write photo: %v", err)
+	}
+	data := map[string]interface{}{"body": "just for you", "image": photo, "view_once": true}
+
+	config.ChatwootForwardViewOnce = "full"
+	content, attachments, generated, _ := buildChatwootMessageContent(data, "", "")
+	if content != "just for you" || len(attachments) != 1 || attachments[0] != photo || len(generated) != 0 {
+		t.Fatalf("full: expected the photo as sent, got %q %v %v", content, attachments, generated)
+	}
+
+	config.ChatwootForwardViewOnce = "placeholder"
+	content, attachments, generated, _ = buildChatwootMessageContent(data, "", "")
+	if content != "📷 View-once photo received\njust for you" || len(attachments) != 0 || len(generated) != 0 {
+		t.Fatalf("placeholder: expected text only, got %q %v %v", content, attachments, generated)
+	}
+
+	config.ChatwootForwardViewOnce = "blur"
+	content, attachments, generated, _ = buildChatwootMessageContent(data, "", "")
+	removeGeneratedAttachments(generated)
+	if content != "📷 View-once photo received\njust for you" {
+		t.Fatalf("blur: unexpected content %q", content)
+	}
+	if len(attachments) != 1 || attachments[0] == photo || len(generated) != 1 || generated[0] != attachments[0] {
+		t.Fatalf("blur: expected a generated preview instead of the photo, got %v %v", attachments, generated)
+	}
+
+	// A photo that was not downloaded cannot be blurred and is left out.
+	remote := map[string]interface{}{"image": map[string]interface{}{"url": "https://mmg.whatsapp.net/x"}, "view_once": true}
+	content, attachments, _, _ = buildChatwootMessageContent(remote, "", "")
+	if content != "📷 View-once photo received" || len(attachments) != 0 {
+		t.Fatalf("blur without file: expected placeholder, got %q %v", content, attachments)
+	}
+}
+
+func TestForwardToChatwoot_EphemeralChatModes(t *testing.T) {
+	prev := config.ChatwootForwardEphemeral
+	t.Cleanup(func() { config.ChatwootForwardEphemeral = prev })
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	photo := filepath.Join(t.TempDir(), "photo.png")
+	if err := imaging.Save(image.NewNRGBA(image.Rect(0, 0, 64, 48)), photo); err != nil {
+		t.Fatalf("failed to write photo: %v", err)
+	}
+	const chat, plain = "628111000010@s.whatsapp.net", "628111000011@s.whatsapp.net"
+	tests := map[string]string{
+		chatwoot.EphemeralExport: "the door code is 4821 [attachment]",
+		chatwoot.EphemeralSkip:   "",
+		chatwoot.EphemeralRedact: "(disappearing message)",
+	}
+	for mode, want := range tests {
+		config.ChatwootForwardEphemeral = mode
+		fake := newFakeChatwoot(t)
+		repo := &exportRecordingRepo{
+			exported: make(map[string]int),
+			chats: map[string]*domainChatStorage.Chat{
+				chat:  {JID: chat, EphemeralExpiration: 86400},
+				plain: {JID: plain},
+			},
+		}
+		ctx := ContextWithDevice(context.Background(), NewDeviceInstance("test-device", nil, repo))
+		for _, jid := range []string{chat, plain} {
+			forwardToChatwoot(ctx, map[string]any{"event": "message", "payload": map[string]any{
+				"id": "EPH-" + mode + "-" + jid[9:12], "body": "the door code is 4821", "image": photo,
+				"from": jid, "from_name": "Customer", "chat_id": jid,
+			}})
+		}
+
+		var got []string
+		for _, msgs := range fake.snapshot() {
+			got = append(got, msgs...)
+		}
+		wantAll := []string{"the door code is 4821 [attachment]"} // The chat without a timer is always exported
+		if want != "" {
+			wantAll = append(wantAll, want)
+		}
+		sort.Strings(got)
+		sort.Strings(wantAll)
+		if strings.Join(got, "|") != strings.Join(wantAll, "|") {
+			t.Errorf("%s: expected %q in Chatwoot, got %q", mode, wantAll, got)
+		}
+
+		skipped := false
+		for _, entry := range repo.audit {
+			skipped = skipped || entry.Reason == chatwoot.AuditReasonEphemeralChat
+		}
+		if skipped != (mode == chatwoot.EphemeralSkip) {
+			t.Errorf("%s: ephemeral_chat skip audited = %v", mode, skipped)
+		}
+	}
+}
+
+// chatLookupFailingRepo fails every chat lookup.
+type chatLookupFailingRepo struct {
+	*exportRecordingRepo
+}
+
+func (r chatLookupFailingRepo) GetChatByDevice(string, string) (*domainChatStorage.Chat, error) {
+	return nil, errors.New("database is locked")
+}
+
+func TestForwardToChatwoot_EphemeralLookupFailureFailsClosed(t *testing.T) {
+	prev := config.ChatwootForwardEphemeral
+	t.Cleanup(func() { config.ChatwootForwardEphemeral = prev })
+	config.ChatwootForwardEphemeral = chatwoot.EphemeralRedact
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	fake := newFakeChatwoot(t)
+	repo := chatLookupFailingRepo{&exportRecordingRepo{exported: make(map[string]int)}}
+	ctx := ContextWithDevice(context.Background(), NewDeviceInstance("test-device", nil, repo))
+	const chat = "628111000012@s.whatsapp.net"
+	forwardToChatwoot(ctx, map[string]any{"event": "message", "payload": map[string]any{
+		"id": "EPH-LOOKUP", "body": "the door code is 4821", "from": chat, "from_name": "Customer", "chat_id": chat,
+	}})
+
+	var got []string
+	for _, msgs := range fake.snapshot() {
+		got = append(got, msgs...)
+	}
+	if len(got) != 1 || got[0] != chatwoot.EphemeralPlaceholder {
+		t.Errorf("expected the message redacted while its timer is unknown, got %q", got)
+	}
+}
+
+func TestForwardToChatwoot_GroupParticipantContacts(t *testing.T) {
+	prev := config.ChatwootGroupParticipantContacts
+	t.Cleanup(func() { config.ChatwootGroupParticipantContacts = prev })
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	const group, maria, joao = "120363000000000044@g.us", "628111000020@s.whatsapp.net", "628111000021@s.whatsapp.net"
+	photo := filepath.Join(t.TempDir(), "photo.png")
+	if err := imaging.Save(image.NewNRGBA(image.Rect(0, 0, 64, 48)), photo); err != nil {
+		t.Fatalf("failed to write photo: %v", err)
+	}
+	for _, enabled := range []bool{false, true} {
+		config.ChatwootGroupParticipantContacts = enabled
+		fake := newFakeChatwoot(t)
+		ctx := ContextWithDevice(context.Background(), NewDeviceInstance("test-device", nil, nil))
+		forward := func(id, from, name, chat, body string, extra map[string]any) {
+			data := map[string]any{"id": fmt.Sprintf("%s-%v", id, enabled), "body": body, "from": from, "from_name": name, "chat_id": chat}
+			for k, v := range extra {
+				data[k] = v
+			}
+			forwardToChatwoot(ctx, map[string]any{"event": "message", "payload": data})
+		}
+		forward("P1", maria, "Maria", maria, "hi, it's me in private", nil)
+		forward("G1", maria, "Maria", group, "hello", nil)
+		forward("G2", joao, "João", group, "good morning", map[string]any{"image": photo}) // Tagged through the multipart upload
+		forward("G3", maria, "Maria", group, "anyone?", nil)
+
+		fake.mu.Lock()
+		contacts, conversations, attributes := len(fake.contacts), len(fake.conversations), fake.attributes
+		mariaID := fake.contacts[0].ID
+		fake.mu.Unlock()
+
+		// The group and Maria's private chat are the only conversations either way
+		if conversations != 2 {
+			t.Errorf("enabled=%v: expected 2 conversations, got %d", enabled, conversations)
+		}
+		if len(attributes) != 4 {
+			t.Fatalf("enabled=%v: expected 4 messages, got %d", enabled, len(attributes))
+		}
+		if !enabled {
+			if contacts != 2 || attributes[1] != nil || attributes[2] != nil {
+				t.Errorf("disabled: expected 2 contacts and untagged messages, got %d contacts, %v", contacts, attributes)
+			}
+			continue
+		}
+
+		if contacts != 3 {
+			t.Errorf("enabled: expected Maria, the group and João as contacts, got %d", contacts)
+		}
+		if attributes[0] != nil {
+			t.Errorf("enabled: private message tagged with %v", attributes[0])
+		}
+		for i, wantName := range map[int]string{1: "Maria", 2: "João", 3: "Maria"} {
+			attrs := attributes[i]
+			if attrs["participant_name"] != wantName || attrs["participant_contact_id"] == nil {
+				t.Errorf("enabled: message %d tagged with %v, want %s", i, attrs, wantName)
+			}
+		}
+		if id := attributes[1]["participant_contact_id"]; id != float64(mariaID) || attributes[3]["participant_contact_id"] != id {
+			t.Errorf("enabled: Maria's group messages should reuse her private contact %d, got %v and %v", mariaID, id, attributes[3]["participant_contact_id"])
+		}
+	}
+}
+
+// newFailingChatwoot points the default Chatwoot client at a server that fails every request.
+func newFailingChatwoot(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+
+	cw := chatwoot.GetDefaultClient()
+	origURL, origToken, origAccount, origInbox, origHTTP := cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient
+	cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient = srv.URL, "token", 1, 1, srv.Client()
+	t.Cleanup(func() {
+		cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient = origURL, origToken, origAccount, origInbox, origHTTP
+	})
+}
+
+func TestForwardPayload_QueuesFailedChatwootForward(t *testing.T) {
+	newFailingChatwoot(t)
+	repo := newMemoryOutboxRepo()
+	withOutboxRepo(t, repo, 3)
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	origWebhooks, origEvents, origEnabled := config.WhatsappWebhook, config.WhatsappWebhookEvents, config.ChatwootEnabled
+	config.WhatsappWebhook, config.WhatsappWebhookEvents, config.ChatwootEnabled = nil, nil, true
+	t.Cleanup(func() {
+		config.WhatsappWebhook, config.WhatsappWebhookEvents, config.ChatwootEnabled = origWebhooks, origEvents, origEnabled
+	})
+
+	const chat = "628111000020@s.whatsapp.net"
+	payload := map[string]any{"event": "message", "payload": map[string]any{
+		"id": "CW-DOWN", "body": "hello", "from": chat, "from_name": "Customer", "chat_id": chat,
+	}}
+	if err := forwardPayloadToConfiguredWebhooks(context.Background(), payload, "message"); err != nil {
+		t.Fatalf("forward returned error: %v", err)
+	}
+
+	if len(repo.entries) != 1 {
+		t.Fatalf("expected the failed Chatwoot forward queued, got %d entries", len(repo.entries))
+	}
+	for _, e := range repo.entries {
+		if e.URL != chatwootOutboxURL || e.Event != "message" || e.LastError == "" {
+			t.Errorf("unexpected queued entry: %+v", e)
+		}
+		if !strings.Contains(e.Payload, "CW-DOWN") {
+			t.Errorf("expected the message in the queued payload, got %s", e.Payload)
+		}
+	}
+}
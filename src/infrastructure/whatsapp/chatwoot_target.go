@@ -0,0 +1,36 @@
+package whatsapp
+
+import (
+	"context"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"go.mau.fi/whatsmeow"
+)
+
+// DeviceAliasForJID returns the ID of the registered device with a WhatsApp JID, or "" when none
+// matches. It matches the resolver signature expected by chatwoot.SetDeviceAliasResolver.
+func DeviceAliasForJID(deviceJID string) string {
+	inst, ok := GetDeviceManager().FindDeviceByJID(deviceJID)
+	if !ok || inst == nil {
+		return ""
+	}
+	return inst.ID()
+}
+
+// chatwootClientFor returns the Chatwoot client of the device a WhatsApp client belongs to, as routed
+// by CHATWOOT_DEVICE_TARGETS.
+func chatwootClientFor(client *whatsmeow.Client) *chatwoot.Client {
+	if client == nil || client.Store == nil || client.Store.ID == nil {
+		return chatwoot.GetDefaultClient()
+	}
+	return chatwoot.ClientForDevice(client.Store.ID.ToNonAD().String())
+}
+
+// chatwootClientForContext returns the Chatwoot client of the device in ctx, else of the device with
+// deviceJID.
+func chatwootClientForContext(ctx context.Context, deviceJID string) *chatwoot.Client {
+	if inst, ok := DeviceFromContext(ctx); ok && inst != nil {
+		return chatwoot.ClientForDevice(inst.ID(), inst.JID())
+	}
+	return chatwoot.ClientForDevice(deviceJID)
+}
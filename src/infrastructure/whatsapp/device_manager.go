@@ -69,6 +69,29 @@ func (m *DeviceManager) GetDevice(id string) (*DeviceInstance, bool) {
 	return instance, ok
 }
 
+// FindDeviceByJID returns the registered device whose WhatsApp JID matches the given JID.
+func (m *DeviceManager) FindDeviceByJID(jid string) (*DeviceInstance, bool) {
+	if m == nil {
+		return nil, false
+	}
+
+	trimmed := strings.TrimSpace(jid)
+	if trimmed == "" {
+		return nil, false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, inst := range m.devices {
+		if inst != nil && inst.JID() == trimmed {
+			return inst, true
+		}
+	}
+
+	return nil, false
+}
+
 // DefaultDevice returns the only registered device when running in single-device mode.
 func (m *DeviceManager) DefaultDevice() *DeviceInstance {
 	if m == nil {
@@ -121,6 +144,16 @@ func (m *DeviceManager) RemoveDevice(id string) {
 	}
 }
 
+// DisconnectAll closes the WhatsApp connection of every device, as on shutdown. Sessions stay
+// logged in.
+func (m *DeviceManager) DisconnectAll() {
+	for _, inst := range m.ListDevices() {
+		if cli := inst.GetClient(); cli != nil {
+			cli.Disconnect()
+		}
+	}
+}
+
 // PurgeDevice cleanly logs out a device, removes its persisted records (store/keys),
 // deletes its chatstorage data, and removes it from the in-memory registry.
 func (m *DeviceManager) PurgeDevice(ctx context.Context, deviceID string) error {
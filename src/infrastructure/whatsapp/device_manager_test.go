@@ -109,3 +109,24 @@ func TestListDevices_SameCreatedAt(t *testing.T) {
 		}
 	}
 }
+
+func TestFindDeviceByJID(t *testing.T) {
+	manager := &DeviceManager{
+		devices: map[string]*DeviceInstance{
+			"sales":   {id: "sales", jid: "6281111@s.whatsapp.net"},
+			"support": {id: "support", jid: "6282222@s.whatsapp.net"},
+		},
+	}
+
+	inst, ok := manager.FindDeviceByJID("6282222@s.whatsapp.net")
+	if !ok || inst.ID() != "support" {
+		t.Fatalf("expected support device, got %v (found=%v)", inst, ok)
+	}
+
+	if _, ok := manager.FindDeviceByJID("6289999@s.whatsapp.net"); ok {
+		t.Fatal("expected unknown JID not to match any device")
+	}
+	if _, ok := manager.FindDeviceByJID(""); ok {
+		t.Fatal("expected empty JID not to match any device")
+	}
+}
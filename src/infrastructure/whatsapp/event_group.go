@@ -3,9 +3,11 @@ package whatsapp
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
 	"github.com/sirupsen/logrus"
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/types"
@@ -25,6 +27,12 @@ func createGroupInfoPayload(ctx context.Context, evt *events.GroupInfo, actionTy
 	// Add action type and affected users (with LID resolution)
 	payload["type"] = actionType
 	payload["jids"] = jidsToStrings(ctx, jids, client)
+	if actor := groupInfoActor(ctx, evt, client); !actor.IsEmpty() {
+		payload["actor"] = actor.ToNonAD().String()
+	}
+	if actionType == "join" && evt.JoinReason != "" {
+		payload["reason"] = evt.JoinReason
+	}
 
 	// Wrap in payload structure
 	body["payload"] = payload
@@ -54,32 +62,147 @@ func jidsToStrings(ctx context.Context, jids []types.JID, client *whatsmeow.Clie
 	return result
 }
 
-// forwardGroupInfoToWebhook forwards group information events to the configured webhook URLs
-func forwardGroupInfoToWebhook(ctx context.Context, evt *events.GroupInfo, deviceID string, client *whatsmeow.Client) error {
-	// Send separate webhook events for each action type
-	actions := []struct {
-		actionType string
-		jids       []types.JID
-	}{
+// groupInfoActor returns the user who made the change, preferring the phone number JID over a LID.
+// It is empty when WhatsApp did not include the sender (e.g. joins via invite link).
+func groupInfoActor(ctx context.Context, evt *events.GroupInfo, client *whatsmeow.Client) types.JID {
+	if evt.SenderPN != nil && !evt.SenderPN.IsEmpty() {
+		return *evt.SenderPN
+	}
+	if evt.Sender == nil {
+		return types.EmptyJID
+	}
+	return NormalizeJIDFromLID(ctx, *evt.Sender, client)
+}
+
+type groupParticipantAction struct {
+	actionType string
+	jids       []types.JID
+}
+
+// groupParticipantActions splits a group event into its participant changes, skipping empty ones.
+func groupParticipantActions(evt *events.GroupInfo) []groupParticipantAction {
+	all := []groupParticipantAction{
 		{"join", evt.Join},
 		{"leave", evt.Leave},
 		{"promote", evt.Promote},
 		{"demote", evt.Demote},
 	}
 
-	for _, action := range actions {
+	actions := make([]groupParticipantAction, 0, len(all))
+	for _, action := range all {
 		if len(action.jids) > 0 {
-			payload := createGroupInfoPayload(ctx, evt, action.actionType, action.jids, deviceID, client)
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
 
-			if err := forwardPayloadToConfiguredWebhooks(ctx, payload, "group.participants"); err != nil {
-				logrus.Warnf("Failed to forward group %s event to webhook: %v", action.actionType, err)
-			}
+// forwardGroupInfoToWebhook forwards group information events to the configured webhook URLs
+func forwardGroupInfoToWebhook(ctx context.Context, evt *events.GroupInfo, deviceID string, client *whatsmeow.Client) error {
+	// Send separate webhook events for each action type
+	for _, action := range groupParticipantActions(evt) {
+		payload := createGroupInfoPayload(ctx, evt, action.actionType, action.jids, deviceID, client)
+
+		if err := forwardPayloadToConfiguredWebhooks(ctx, payload, "group.participants"); err != nil {
+			logrus.Warnf("Failed to forward group %s event to webhook: %v", action.actionType, err)
 		}
 	}
 
 	return nil
 }
 
+// formatGroupParticipant renders a participant as "+<phone>", or as the raw JID when it is not a phone number.
+func formatGroupParticipant(jid types.JID) string {
+	if jid.Server == types.DefaultUserServer {
+		return "+" + jid.User
+	}
+	return jid.ToNonAD().String()
+}
+
+// groupParticipantNamer names participants by their contact store name, falling back to
+// formatGroupParticipant.
+func groupParticipantNamer(ctx context.Context, client *whatsmeow.Client) func(types.JID) string {
+	return func(jid types.JID) string {
+		if name := contactStoreName(ctx, client, jid); name != "" {
+			return name
+		}
+		return formatGroupParticipant(jid)
+	}
+}
+
+// groupParticipantNote builds the Chatwoot private note text for one participant change, naming
+// participants with name.
+func groupParticipantNote(actionType string, participants []types.JID, actor types.JID, joinReason string, name func(types.JID) string) string {
+	names := make([]string, 0, len(participants))
+	selfAction := !actor.IsEmpty() && len(participants) == 1 && participants[0].User == actor.User
+	for _, jid := range participants {
+		names = append(names, name(jid))
+	}
+	who := strings.Join(names, ", ")
+	by := ""
+	if !actor.IsEmpty() && !selfAction {
+		by = " by " + name(actor)
+	}
+
+	switch actionType {
+	case "join":
+		if joinReason == "invite" {
+			return fmt.Sprintf("➕ %s joined via invite link", who)
+		}
+		if by == "" {
+			return fmt.Sprintf("➕ %s joined", who)
+		}
+		return fmt.Sprintf("➕ %s added%s", who, by)
+	case "leave":
+		if by == "" {
+			return fmt.Sprintf("➖ %s left", who)
+		}
+		return fmt.Sprintf("➖ %s removed%s", who, by)
+	case "promote":
+		return fmt.Sprintf("⬆️ %s promoted to admin%s", who, by)
+	case "demote":
+		return fmt.Sprintf("⬇️ %s demoted from admin%s", who, by)
+	}
+	return ""
+}
+
+// forwardGroupInfoToChatwoot posts participant changes as private notes on the group's conversation.
+// Groups that were never bridged to Chatwoot are skipped rather than created.
+func forwardGroupInfoToChatwoot(ctx context.Context, evt *events.GroupInfo, client *whatsmeow.Client) {
+	actions := groupParticipantActions(evt)
+	if len(actions) == 0 {
+		return
+	}
+	cw := chatwootClientFor(client)
+	if !cw.IsConfigured() {
+		return
+	}
+
+	groupJID := evt.JID.ToNonAD().String()
+	contact, err := cw.FindContactByIdentifier(groupJID, true)
+	if err != nil || contact == nil {
+		return
+	}
+	conv, err := cw.FindConversation(contact.ID)
+	if err != nil || conv == nil {
+		return
+	}
+
+	actor := groupInfoActor(ctx, evt, client)
+	name := groupParticipantNamer(ctx, client)
+	for _, action := range actions {
+		participants := make([]types.JID, 0, len(action.jids))
+		for _, jid := range action.jids {
+			participants = append(participants, NormalizeJIDFromLID(ctx, jid, client))
+		}
+
+		note := groupParticipantNote(action.actionType, participants, actor, evt.JoinReason, name)
+		if _, err := cw.CreatePrivateNote(conv.ID, note); err != nil {
+			logrus.Warnf("Chatwoot: Failed to post group %s note for %s: %v", action.actionType, groupJID, err)
+		}
+	}
+}
+
 // handleJoinedGroup handles the event when the connected device is added to a new group
 func handleJoinedGroup(ctx context.Context, evt *events.JoinedGroup, deviceID string, client *whatsmeow.Client) {
 	log.Infof("Joined group %s (reason: %s, type: %s)", evt.JID, evt.Reason, evt.Type)
@@ -126,3 +249,80 @@ func forwardJoinedGroupToWebhook(ctx context.Context, evt *events.JoinedGroup, d
 
 	return forwardPayloadToConfiguredWebhooks(ctx, body, "group.joined")
 }
+
+// refreshChatwootGroupContact renames the Chatwoot contact of a renamed group, so agents see the new
+// name without waiting for the next message. hint is the name from the event; WhatsApp is only asked
+// when the event did not carry one. Groups that were never bridged are left alone.
+func refreshChatwootGroupContact(fetcher groupInfoFetcher, cw *chatwoot.Client, groupJID, hint string) error {
+	groupInfo := GetGroupInfoService()
+	name := hint
+	if name != "" {
+		groupInfo.Set(groupJID, name)
+	} else {
+		name = groupInfo.Refresh(fetcher, groupJID)
+	}
+	if name == "" {
+		return nil
+	}
+
+	unlock := lockContact(groupJID, "refreshChatwootGroupContact")
+	defer unlock()
+
+	contact, err := cw.FindContactByIdentifier(groupJID, true)
+	if err != nil {
+		return fmt.Errorf("failed to find contact for %s: %w", groupJID, err)
+	}
+	if contact == nil || contact.Name == name {
+		return nil
+	}
+
+	if err := cw.UpdateContactName(contact.ID, name); err != nil {
+		return fmt.Errorf("failed to rename contact %d: %w", contact.ID, err)
+	}
+	logrus.Infof("Chatwoot: Renamed group contact %d from '%s' to '%s'", contact.ID, contact.Name, name)
+	return nil
+}
+
+// handleGroupRename updates the Chatwoot contact of a group after its subject changed.
+func handleGroupRename(groupJID, name string, client *whatsmeow.Client) {
+	cw := chatwootClientFor(client)
+	if !cw.IsConfigured() {
+		return
+	}
+	var fetcher groupInfoFetcher
+	if client != nil {
+		fetcher = client
+	}
+	if err := refreshChatwootGroupContact(fetcher, cw, groupJID, name); err != nil {
+		logrus.Warnf("Chatwoot: Failed to sync renamed group %s: %v", groupJID, err)
+	}
+}
+
+// handleGroupPicture refreshes the Chatwoot avatar of a bridged group when its icon changes.
+func handleGroupPicture(evt *events.Picture, client *whatsmeow.Client) {
+	if !config.ChatwootEnabled || client == nil || evt.JID.Server != types.GroupServer {
+		return
+	}
+
+	work := trackInflight("avatar sync of "+evt.JID.String(), nil)
+	go func() {
+		defer work.done()
+		cw := chatwootClientFor(client)
+		syncSvc := chatwoot.GetDefaultSyncService()
+		if !cw.IsConfigured() || syncSvc == nil {
+			return
+		}
+
+		groupJID := evt.JID.ToNonAD().String()
+		contact, err := cw.FindContactByIdentifier(groupJID, true)
+		if err != nil || contact == nil {
+			return
+		}
+
+		syncCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := syncSvc.SyncContactAvatarSmart(syncCtx, groupJID, contact.Name, client, true); err != nil {
+			logrus.Warnf("Chatwoot: Failed to sync avatar for group %s: %v", groupJID, err)
+		}
+	}()
+}
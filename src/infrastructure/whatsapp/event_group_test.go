@@ -0,0 +1,178 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestCreateGroupInfoPayload_IncludesActor(t *testing.T) {
+	group := types.NewJID("120363000000000000", types.GroupServer)
+	sender := types.NewJID("5511888", types.DefaultUserServer)
+	added := types.NewJID("5511999", types.DefaultUserServer)
+	evt := &events.GroupInfo{JID: group, Sender: &sender, Timestamp: time.Now(), Join: []types.JID{added}}
+
+	body := createGroupInfoPayload(context.Background(), evt, "join", evt.Join, "dev@s.whatsapp.net", nil)
+	payload := body["payload"].(map[string]any)
+
+	if payload["actor"] != "5511888@s.whatsapp.net" {
+		t.Fatalf("expected actor to be set, got %v", payload["actor"])
+	}
+	if jids := payload["jids"].([]string); len(jids) != 1 || jids[0] != "5511999@s.whatsapp.net" {
+		t.Fatalf("unexpected jids %v", jids)
+	}
+	if body["event"] != "group.participants" {
+		t.Fatalf("unexpected event %v", body["event"])
+	}
+}
+
+func TestGroupParticipantNote(t *testing.T) {
+	admin := types.NewJID("5511888", types.DefaultUserServer)
+	member := types.NewJID("5511999", types.DefaultUserServer)
+	other := types.NewJID("5511777", types.DefaultUserServer)
+
+	tests := []struct {
+		name   string
+		action string
+		jids   []types.JID
+		actor  types.JID
+		reason string
+		want   string
+	}{
+		{name: "added", action: "join", jids: []types.JID{member}, actor: admin, want: "➕ +5511999 added by +5511888"},
+		{name: "invite", action: "join", jids: []types.JID{member}, reason: "invite", want: "➕ +5511999 joined via invite link"},
+		{name: "left", action: "leave", jids: []types.JID{member}, actor: member, want: "➖ +5511999 left"},
+		{name: "removed", action: "leave", jids: []types.JID{member, other}, actor: admin, want: "➖ +5511999, +5511777 removed by +5511888"},
+		{name: "promoted", action: "promote", jids: []types.JID{member}, actor: admin, want: "⬆️ +5511999 promoted to admin by +5511888"},
+		{name: "demoted", action: "demote", jids: []types.JID{member}, want: "⬇️ +5511999 demoted from admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupParticipantNote(tt.action, tt.jids, tt.actor, tt.reason, formatGroupParticipant); got != tt.want {
+				t.Fatalf("groupParticipantNote()=%q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupParticipantNote_UsesContactNames(t *testing.T) {
+	admin := types.NewJID("5511888", types.DefaultUserServer)
+	member := types.NewJID("5511999", types.DefaultUserServer)
+	names := map[types.JID]string{admin: "Maria Silva"}
+	name := func(jid types.JID) string {
+		if n, ok := names[jid]; ok {
+			return n
+		}
+		return formatGroupParticipant(jid)
+	}
+
+	want := "➕ +5511999 added by Maria Silva"
+	if got := groupParticipantNote("join", []types.JID{member}, admin, "", name); got != want {
+		t.Fatalf("groupParticipantNote()=%q want %q", got, want)
+	}
+}
+
+func TestGroupParticipantActions_SkipsEmpty(t *testing.T) {
+	member := types.NewJID("5511999", types.DefaultUserServer)
+	evt := &events.GroupInfo{Leave: []types.JID{member}, Demote: []types.JID{member}}
+
+	actions := groupParticipantActions(evt)
+	if len(actions) != 2 || actions[0].actionType != "leave" || actions[1].actionType != "demote" {
+		t.Fatalf("unexpected actions %+v", actions)
+	}
+}
+
+type fakeGroupInfoClient struct {
+	name string
+	err  error
+}
+
+func (f *fakeGroupInfoClient) GetGroupInfo(_ context.Context, jid types.JID) (*types.GroupInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &types.GroupInfo{JID: jid, GroupName: types.GroupName{Name: f.name}}, nil
+}
+
+// newGroupContactServer fakes the Chatwoot contact search/update endpoints for one group contact.
+func newGroupContactServer(t *testing.T, groupJID, currentName string) (*chatwoot.Client, *[]string) {
+	t.Helper()
+	var renamed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			if currentName == "" {
+				_, _ = w.Write([]byte(`{"payload":[]}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"payload": []map[string]any{{"id": 7, "name": currentName, "identifier": groupJID}},
+			})
+		case http.MethodPut:
+			var body struct {
+				Name string `json:"name"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			renamed = append(renamed, body.Name)
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return &chatwoot.Client{BaseURL: srv.URL, APIToken: "t", AccountID: 1, InboxID: 1, HTTPClient: &http.Client{Timeout: 5 * time.Second}}, &renamed
+}
+
+func TestRefreshChatwootGroupContact_RenamesFromFreshGroupInfo(t *testing.T) {
+	groupJID := "120363000000000001@g.us"
+	setCachedGroupName(groupJID, "Old Name")
+	t.Cleanup(func() { InvalidateGroupName(groupJID) })
+
+	cw, renamed := newGroupContactServer(t, groupJID, "Old Name")
+	if err := refreshChatwootGroupContact(&fakeGroupInfoClient{name: "New Name"}, cw, groupJID, ""); err != nil {
+		t.Fatalf("refreshChatwootGroupContact returned error: %v", err)
+	}
+
+	if len(*renamed) != 1 || (*renamed)[0] != "New Name" {
+		t.Fatalf("expected contact to be renamed to New Name, got %v", *renamed)
+	}
+	if name, ok := getCachedGroupName(groupJID); !ok || name != "New Name" {
+		t.Fatalf("expected cache to hold the new name, got %q (%v)", name, ok)
+	}
+}
+
+func TestRefreshChatwootGroupContact_FallsBackToEventName(t *testing.T) {
+	groupJID := "120363000000000002@g.us"
+	t.Cleanup(func() { InvalidateGroupName(groupJID) })
+
+	cw, renamed := newGroupContactServer(t, groupJID, "Old Name")
+	fetcher := &fakeGroupInfoClient{err: errors.New("offline")}
+	if err := refreshChatwootGroupContact(fetcher, cw, groupJID, "Event Name"); err != nil {
+		t.Fatalf("refreshChatwootGroupContact returned error: %v", err)
+	}
+	if len(*renamed) != 1 || (*renamed)[0] != "Event Name" {
+		t.Fatalf("expected contact to be renamed to Event Name, got %v", *renamed)
+	}
+}
+
+func TestRefreshChatwootGroupContact_SkipsUnbridgedGroup(t *testing.T) {
+	groupJID := "120363000000000003@g.us"
+	t.Cleanup(func() { InvalidateGroupName(groupJID) })
+
+	cw, renamed := newGroupContactServer(t, groupJID, "")
+	if err := refreshChatwootGroupContact(&fakeGroupInfoClient{name: "New Name"}, cw, groupJID, ""); err != nil {
+		t.Fatalf("refreshChatwootGroupContact returned error: %v", err)
+	}
+	if len(*renamed) != 0 {
+		t.Fatalf("expected no rename for a group without a Chatwoot contact, got %v", *renamed)
+	}
+}
@@ -39,19 +39,27 @@ func handler(ctx context.Context, instance *DeviceInstance, rawEvt any) {
 		handlePairSuccess(ctx, evt)
 	case *events.LoggedOut:
 		handleLoggedOut(ctx, instance, chatStorageRepo)
-	case *events.Connected, *events.PushNameSetting:
+	case *events.Connected:
+		beginReconnectBurst(instance.ID())
 		handleConnectionEvents(ctx, client, instance)
+	case *events.PushNameSetting:
+		handleConnectionEvents(ctx, client, instance)
+	case *events.OfflineSyncCompleted:
+		endReconnectBurst(instance.ID())
+		if config.ChatwootEnabled {
+			go linkChatwootLIDContacts(instance, client)
+		}
 	case *events.StreamReplaced:
 		handleStreamReplaced(ctx)
 	case *events.Message:
 		handleMessage(ctx, evt, chatStorageRepo, client)
 	case *events.Receipt:
-		handleReceipt(ctx, evt, instance.JID(), client)
+		handleReceipt(ctx, evt, chatStorageRepo, instance.JID(), client)
 	case *events.Presence:
 		handlePresence(ctx, evt)
 	case *events.ChatPresence:
 		if config.ChatwootEnabled {
-			go forwardTypingToChatwoot(evt)
+			go forwardTypingToChatwoot(evt, client)
 		}
 	case *events.HistorySync:
 		handleHistorySync(ctx, evt, chatStorageRepo, client)
@@ -61,6 +69,12 @@ func handler(ctx context.Context, instance *DeviceInstance, rawEvt any) {
 		handleGroupInfo(ctx, evt, instance.JID(), client)
 	case *events.JoinedGroup:
 		handleJoinedGroup(ctx, evt, instance.JID(), client)
+	case *events.Picture:
+		handleGroupPicture(evt, client)
+	case *events.PushName:
+		forgetSenderName(ctx, client, evt.JID)
+	case *events.Contact:
+		forgetSenderName(ctx, client, evt.JID)
 	case *events.NewsletterJoin:
 		handleNewsletterJoin(ctx, evt, instance.JID(), client)
 	case *events.NewsletterLeave:
@@ -214,7 +228,7 @@ func handleStreamReplaced(_ context.Context) {
 	os.Exit(0)
 }
 
-func handleReceipt(ctx context.Context, evt *events.Receipt, deviceID string, client *whatsmeow.Client) {
+func handleReceipt(ctx context.Context, evt *events.Receipt, chatStorageRepo domainChatStorage.IChatStorageRepository, deviceID string, client *whatsmeow.Client) {
 	sendReceipt := false
 	switch evt.Type {
 	case types.ReceiptTypeRead, types.ReceiptTypeReadSelf:
@@ -223,7 +237,22 @@ func handleReceipt(ctx context.Context, evt *events.Receipt, deviceID string, cl
 	case types.ReceiptTypeDelivered:
 		sendReceipt = true
 		log.Infof("%s was delivered to %s at %s: %+v", evt.MessageIDs[0], evt.SourceString(), evt.Timestamp, evt)
+	case types.ReceiptTypePlayed:
+		sendReceipt = true
+		log.Infof("%v was played by %s at %s", evt.MessageIDs, evt.SourceString(), evt.Timestamp)
+	case types.ReceiptTypeServerError:
+		log.Warnf("Server could not deliver %v to %s", evt.MessageIDs, evt.SourceString())
+		failureCtx := context.Background()
+		if inst, ok := DeviceFromContext(ctx); ok {
+			failureCtx = ContextWithDevice(failureCtx, inst)
+		}
+		go func(e *events.Receipt, c *whatsmeow.Client) {
+			reportCtx, cancel := context.WithTimeout(failureCtx, 30*time.Second)
+			defer cancel()
+			handleServerErrorReceipt(reportCtx, e, chatStorageRepo, c)
+		}(evt, client)
 	}
+	queueMessageReceipts(evt, deviceID)
 
 	// Forward receipt (ack) event to webhook if configured
 	// Note: Receipt events are not rate limited as they are critical for message delivery status
@@ -287,4 +316,23 @@ func handleGroupInfo(ctx context.Context, evt *events.GroupInfo, deviceID string
 			}
 		}(evt, client)
 	}
+
+	if evt.Name != nil {
+		if evt.Name.Name != "" {
+			GetGroupInfoService().Set(evt.JID.ToNonAD().String(), evt.Name.Name)
+		} else {
+			InvalidateGroupName(evt.JID.ToNonAD().String())
+		}
+		if config.ChatwootEnabled {
+			go handleGroupRename(evt.JID.ToNonAD().String(), evt.Name.Name, client)
+		}
+	}
+
+	if config.ChatwootEnabled && config.ChatwootGroupParticipantNotes {
+		go func(e *events.GroupInfo, c *whatsmeow.Client) {
+			chatwootCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			forwardGroupInfoToChatwoot(chatwootCtx, e, c)
+		}(evt, client)
+	}
 }
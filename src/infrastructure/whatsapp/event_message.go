@@ -201,7 +201,7 @@ func buildMessageBody(ctx context.Context, client *whatsmeow.Client, evt *events
 }
 
 func buildOptionalFields(ctx context.Context, client *whatsmeow.Client, evt *events.Message, msg *waE2E.Message, payload map[string]any) error {
-	if evt.IsViewOnce {
+	if evt.IsViewOnce || utils.IsViewOnceMessage(msg) {
 		payload["view_once"] = true
 	}
 
@@ -321,6 +321,10 @@ func buildOtherMessageTypes(msg *waE2E.Message, payload map[string]any) {
 		payload["contact"] = contactMessage
 	}
 
+	if contactsArrayMessage := msg.GetContactsArrayMessage(); contactsArrayMessage != nil {
+		payload["contacts_array"] = contactsArrayMessage
+	}
+
 	if listMessage := msg.GetListMessage(); listMessage != nil {
 		payload["list"] = listMessage
 	}
@@ -336,4 +340,14 @@ func buildOtherMessageTypes(msg *waE2E.Message, payload map[string]any) {
 	if orderMessage := msg.GetOrderMessage(); orderMessage != nil {
 		payload["order"] = orderMessage
 	}
+
+	if pollMessage := pollCreationMessage(msg); pollMessage != nil {
+		payload["poll"] = pollMessage
+	}
+
+	if pollUpdate := msg.GetPollUpdateMessage(); pollUpdate != nil {
+		payload["poll_update"] = map[string]any{
+			"poll_id": pollUpdate.GetPollCreationMessageKey().GetID(),
+		}
+	}
 }
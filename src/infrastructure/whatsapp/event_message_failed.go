@@ -0,0 +1,184 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// EventTypeMessageFailed is sent when WhatsApp refuses or cannot deliver a message we sent.
+const EventTypeMessageFailed = "message.failed"
+
+// Categories of message delivery failures, as sent in the message.failed webhook.
+const (
+	MessageFailureNotAllowed        = "not_allowed"         // 401/403: the recipient blocked us or does not accept our messages
+	MessageFailureRecipientNotFound = "recipient_not_found" // 404: the number is not on WhatsApp
+	MessageFailurePrivacyRestricted = "privacy_restricted"  // 463: the recipient only accepts messages from contacts or existing chats
+	MessageFailureRejected          = "rejected"            // 479 and other 4xx codes
+	MessageFailureRateLimited       = "rate_limited"        // 420/429: sending too fast
+	MessageFailureServerError       = "server_error"        // 5xx codes and server-error receipts
+	MessageFailureTimeout           = "timeout"             // no answer from the server
+)
+
+// MessageFailure is a sent message that WhatsApp refused or could not deliver.
+type MessageFailure struct {
+	MessageID string
+	ChatJID   string
+	Category  string
+	Code      int // WhatsApp error code, 0 when the server gave none
+}
+
+// ClassifySendError returns the failure category of an error from client.SendMessage. ok is false
+// for errors raised before the message reached WhatsApp, such as an invalid recipient.
+func ClassifySendError(err error) (category string, code int, ok bool) {
+	switch {
+	case errors.Is(err, whatsmeow.ErrServerReturnedError):
+		fields := strings.Fields(err.Error())
+		if len(fields) > 0 {
+			code, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+		return messageFailureCategory(code), code, true
+	case errors.Is(err, whatsmeow.ErrMessageTimedOut):
+		return MessageFailureTimeout, 0, true
+	}
+	return "", 0, false
+}
+
+func messageFailureCategory(code int) string {
+	switch {
+	case code == 401 || code == 403:
+		return MessageFailureNotAllowed
+	case code == 404:
+		return MessageFailureRecipientNotFound
+	case code == 463:
+		return MessageFailurePrivacyRestricted
+	case code == 408:
+		return MessageFailureTimeout
+	case code == 420 || code == 429:
+		return MessageFailureRateLimited
+	case code >= 500:
+		return MessageFailureServerError
+	default:
+		return MessageFailureRejected
+	}
+}
+
+// MessageFailureNote is the text shown to Chatwoot agents when their message was not delivered.
+func MessageFailureNote(category string, code int) string {
+	var reason string
+	switch category {
+	case MessageFailureNotAllowed:
+		reason = "the recipient blocked this number or does not accept its messages"
+	case MessageFailureRecipientNotFound:
+		reason = "the recipient is not on WhatsApp"
+	case MessageFailurePrivacyRestricted:
+		reason = "the recipient only accepts messages from their contacts or existing chats"
+	case MessageFailureRateLimited:
+		reason = "WhatsApp is limiting how fast this number sends, try again later"
+	case MessageFailureServerError:
+		reason = "WhatsApp had a server error, try again later"
+	case MessageFailureTimeout:
+		reason = "WhatsApp did not confirm the message in time, it may not have been sent"
+	default:
+		reason = "WhatsApp rejected the message"
+	}
+	if code != 0 {
+		return fmt.Sprintf("Message not delivered to WhatsApp: %s (error %d).", reason, code)
+	}
+	return fmt.Sprintf("Message not delivered to WhatsApp: %s.", reason)
+}
+
+// ReportMessageFailure sends the message.failed webhook and, when the message was sent from a
+// Chatwoot conversation, tells its agents with a private note.
+func ReportMessageFailure(ctx context.Context, client *whatsmeow.Client, failure MessageFailure) {
+	logrus.Warnf("Message %s to %s was not delivered: %s (code %d)", failure.MessageID, failure.ChatJID, failure.Category, failure.Code)
+
+	deviceID := ""
+	if client != nil && client.Store != nil && client.Store.ID != nil {
+		deviceID = NormalizeJIDFromLID(ctx, client.Store.ID.ToNonAD(), client).ToNonAD().String()
+	}
+	if len(config.WhatsappWebhook) > 0 {
+		if err := forwardPayloadToConfiguredWebhooks(ctx, createMessageFailedPayload(failure, deviceID, time.Now()), EventTypeMessageFailed); err != nil {
+			logrus.Errorf("Failed to forward message.failed to webhook: %v", err)
+		}
+	}
+
+	if !config.ChatwootEnabled {
+		return
+	}
+	var repo domainChatStorage.IChatStorageRepository
+	if inst, ok := DeviceFromContext(ctx); ok && inst != nil {
+		repo = inst.GetChatStorage()
+	}
+	if repo == nil {
+		return
+	}
+	conversationID, err := repo.GetChatwootSentMessageConversation(failure.MessageID)
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to look up the conversation of message %s: %v", failure.MessageID, err)
+		return
+	}
+	if conversationID == 0 {
+		return
+	}
+	cw := chatwootClientFor(client)
+	if !cw.IsConfigured() {
+		return
+	}
+	noteID, err := cw.CreatePrivateNote(conversationID, MessageFailureNote(failure.Category, failure.Code))
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to post delivery failure of message %s in conversation %d: %v", failure.MessageID, conversationID, err)
+		return
+	}
+	cw.MarkMessageAsSent(noteID)
+}
+
+func createMessageFailedPayload(failure MessageFailure, deviceID string, at time.Time) map[string]any {
+	payload := map[string]any{
+		"id":       failure.MessageID,
+		"chat_id":  failure.ChatJID,
+		"category": failure.Category,
+	}
+	if failure.Code != 0 {
+		payload["error_code"] = failure.Code
+	}
+
+	body := map[string]any{
+		"event":     EventTypeMessageFailed,
+		"timestamp": at.Format(time.RFC3339),
+		"payload":   payload,
+	}
+	if deviceID != "" {
+		body["device_id"] = deviceID
+	}
+	return body
+}
+
+// handleServerErrorReceipt reports our messages named in a server-error receipt: WhatsApp accepted
+// them first and gave up delivering them later.
+func handleServerErrorReceipt(ctx context.Context, evt *events.Receipt, chatStorageRepo domainChatStorage.IChatStorageRepository, client *whatsmeow.Client) {
+	if evt.Type != types.ReceiptTypeServerError || chatStorageRepo == nil {
+		return
+	}
+	for _, id := range evt.MessageIDs {
+		msg, err := chatStorageRepo.GetMessageByID(id)
+		if err != nil || msg == nil || !msg.IsFromMe {
+			continue
+		}
+		ReportMessageFailure(ctx, client, MessageFailure{
+			MessageID: id,
+			ChatJID:   msg.ChatJID,
+			Category:  MessageFailureServerError,
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestClassifySendError(t *testing.T) {
+	cases := []struct {
+		err      error
+		category string
+		code     int
+		ok       bool
+	}{
+		{fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 403), MessageFailureNotAllowed, 403, true},
+		{fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 404), MessageFailureRecipientNotFound, 404, true},
+		{fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 463), MessageFailurePrivacyRestricted, 463, true},
+		{fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 479), MessageFailureRejected, 479, true},
+		{fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 429), MessageFailureRateLimited, 429, true},
+		{fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 500), MessageFailureServerError, 500, true},
+		{fmt.Errorf("send: %w", whatsmeow.ErrMessageTimedOut), MessageFailureTimeout, 0, true},
+		{errors.New("invalid recipient"), "", 0, false},
+	}
+	for _, tc := range cases {
+		category, code, ok := ClassifySendError(tc.err)
+		if category != tc.category || code != tc.code || ok != tc.ok {
+			t.Errorf("ClassifySendError(%v) = %q, %d, %v; want %q, %d, %v", tc.err, category, code, ok, tc.category, tc.code, tc.ok)
+		}
+	}
+}
+
+func TestCreateMessageFailedPayload(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	body := createMessageFailedPayload(MessageFailure{MessageID: "ABC", ChatJID: "628111@s.whatsapp.net", Category: MessageFailurePrivacyRestricted, Code: 463}, "628999@s.whatsapp.net", at)
+
+	if body["event"] != EventTypeMessageFailed || body["device_id"] != "628999@s.whatsapp.net" || body["timestamp"] != "2024-05-01T09:00:00Z" {
+		t.Fatalf("unexpected envelope: %+v", body)
+	}
+	payload := body["payload"].(map[string]any)
+	if payload["id"] != "ABC" || payload["chat_id"] != "628111@s.whatsapp.net" || payload["category"] != "privacy_restricted" || payload["error_code"] != 463 {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+
+	body = createMessageFailedPayload(MessageFailure{MessageID: "ABC", Category: MessageFailureTimeout}, "", at)
+	if _, ok := body["device_id"]; ok {
+		t.Error("device_id set without a device")
+	}
+	if _, ok := body["payload"].(map[string]any)["error_code"]; ok {
+		t.Error("error_code set without a code")
+	}
+}
+
+type sentMessagesRepo struct {
+	domainChatStorage.IChatStorageRepository
+	messages      map[string]*domainChatStorage.Message
+	conversations map[string]int
+}
+
+func (r *sentMessagesRepo) GetMessageByID(id string) (*domainChatStorage.Message, error) {
+	return r.messages[id], nil
+}
+
+func (r *sentMessagesRepo) GetChatwootSentMessageConversation(messageID string) (int, error) {
+	return r.conversations[messageID], nil
+}
+
+func TestHandleServerErrorReceipt_ReportsOwnMessages(t *testing.T) {
+	origWebhooks, origEnabled := config.WhatsappWebhook, config.ChatwootEnabled
+	config.WhatsappWebhook, config.ChatwootEnabled = []string{"https://hook"}, true
+	t.Cleanup(func() { config.WhatsappWebhook, config.ChatwootEnabled = origWebhooks, origEnabled })
+
+	var (
+		mu        sync.Mutex
+		delivered []map[string]any
+	)
+	origSubmit := submitWebhookFn
+	submitWebhookFn = func(_ context.Context, payload map[string]any, _ string) error {
+		mu.Lock()
+		delivered = append(delivered, payload)
+		mu.Unlock()
+		return nil
+	}
+	t.Cleanup(func() { submitWebhookFn = origSubmit })
+
+	fake := newFakeChatwoot(t)
+	chat := "628111@s.whatsapp.net"
+	repo := &sentMessagesRepo{
+		messages: map[string]*domainChatStorage.Message{
+			"FROMCHATWOOT": {ID: "FROMCHATWOOT", ChatJID: chat, IsFromMe: true},
+			"FROMAPI":      {ID: "FROMAPI", ChatJID: chat, IsFromMe: true},
+			"INCOMING":     {ID: "INCOMING", ChatJID: chat},
+		},
+		conversations: map[string]int{"FROMCHATWOOT": 7},
+	}
+	ctx := ContextWithDevice(context.Background(), NewDeviceInstance("test-device", nil, repo))
+	evt := &events.Receipt{
+		MessageSource: types.MessageSource{Chat: types.NewJID("628111", types.DefaultUserServer)},
+		MessageIDs:    []types.MessageID{"FROMCHATWOOT", "FROMAPI", "INCOMING", "UNKNOWN"},
+		Type:          types.ReceiptTypeServerError,
+	}
+
+	handleServerErrorReceipt(ctx, evt, repo, nil)
+
+	if len(delivered) != 2 {
+		t.Fatalf("expected 2 message.failed events, got %d: %+v", len(delivered), delivered)
+	}
+	for i, id := range []string{"FROMCHATWOOT", "FROMAPI"} {
+		payload := delivered[i]["payload"].(map[string]any)
+		if delivered[i]["event"] != EventTypeMessageFailed || payload["id"] != id || payload["category"] != MessageFailureServerError {
+			t.Errorf("event %d = %+v, want %s failure of %s", i, delivered[i], MessageFailureServerError, id)
+		}
+	}
+
+	notes := fake.snapshot()
+	if len(notes) != 1 || len(notes[7]) != 1 || !strings.Contains(notes[7][0], "server error") {
+		t.Fatalf("expected one failure note in conversation 7, got %+v", notes)
+	}
+}
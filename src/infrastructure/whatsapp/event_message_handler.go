@@ -17,6 +17,11 @@ import (
 )
 
 func handleMessage(ctx context.Context, evt *events.Message, chatStorageRepo domainChatStorage.IChatStorageRepository, client *whatsmeow.Client) {
+	if skipsNewsletter(evt.Info.Chat) {
+		log.Debugf("Skipping message %s from channel %s", evt.Info.ID, evt.Info.Chat)
+		return
+	}
+
 	// Log message metadata
 	metaParts := buildMessageMetaParts(evt)
 	log.Infof("Received message %s from %s (%s): %+v",
@@ -31,6 +36,9 @@ func handleMessage(ctx context.Context, evt *events.Message, chatStorageRepo dom
 		log.Errorf("Failed to store incoming message %s: %v", evt.Info.ID, err)
 	}
 
+	// Keep polls and their votes for tallying
+	handlePollMessage(ctx, evt, chatStorageRepo, client)
+
 	// Handle image message if present
 	handleImageMessage(ctx, evt, client)
 
@@ -108,7 +116,7 @@ func handleAutoMarkRead(ctx context.Context, evt *events.Message, client *whatsm
 	}
 }
 
-func handleWebhookForward(_ctx context.Context, evt *events.Message, client *whatsmeow.Client) {
+func handleWebhookForward(ctx context.Context, evt *events.Message, client *whatsmeow.Client) {
 	// Skip webhook for protocol messages that are internal sync messages
 	if protocolMessage := evt.Message.GetProtocolMessage(); protocolMessage != nil {
 		protocolType := protocolMessage.GetType().String()
@@ -121,16 +129,55 @@ func handleWebhookForward(_ctx context.Context, evt *events.Message, client *wha
 		}
 	}
 
-	if (len(config.WhatsappWebhook) > 0 || config.ChatwootEnabled) &&
-		!strings.Contains(evt.Info.SourceString(), "broadcast") {
-		go func(e *events.Message, c *whatsmeow.Client) {
-			webhookCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-			if err := forwardMessageToWebhook(webhookCtx, c, e); err != nil {
-				logrus.Error("Failed forward to webhook: ", err)
-			}
-		}(evt, client)
+	forward := forwardMessageToWebhook
+	// Until its payload is built the forward is saved from the event, should the shutdown cut it off
+	persist := func(persistCtx context.Context, cause error) {
+		persistUnstartedMessage(persistCtx, client, evt, cause)
+	}
+	if IsStatusBroadcastJID(evt.Info.Chat.String()) {
+		if !forwardsStatuses() {
+			return
+		}
+		// Statuses are not replayed; one cut off by the shutdown is dropped
+		forward, persist = forwardStatusUpdate, nil
+	} else if strings.Contains(evt.Info.SourceString(), "broadcast") ||
+		(len(config.WhatsappWebhook) == 0 && !config.ChatwootEnabled) {
+		return
+	}
+
+	forwardCtx := context.Background()
+	deviceID := ""
+	if inst, ok := DeviceFromContext(ctx); ok {
+		forwardCtx = ContextWithDevice(forwardCtx, inst)
+		if inst != nil {
+			deviceID = inst.ID()
+		}
+	}
+	work := trackInflight("message "+evt.Info.ID+" forward", persist)
+	dispatchOrderedForward(deviceID, forwardOrderKey(ctx, evt.Info.Chat, client), evt.Info.Timestamp, func() {
+		defer work.done()
+		webhookCtx, cancel := context.WithTimeout(contextWithInflight(forwardCtx, work), 30*time.Second)
+		defer cancel()
+		if err := forward(webhookCtx, client, evt); err != nil {
+			logrus.Error("Failed forward to webhook: ", err)
+		}
+	}, func() {
+		defer work.done()
+		spillCtx, cancel := context.WithTimeout(forwardCtx, 30*time.Second)
+		defer cancel()
+		work.save(spillCtx, errForwardQueueFull)
+	})
+}
+
+// forwardOrderKey returns the key that keeps the forwards of chat in order. A private chat can be
+// addressed by LID or phone number from one message to the next; both map to the phone number, so
+// they share one queue.
+func forwardOrderKey(ctx context.Context, chat types.JID, client *whatsmeow.Client) string {
+	chat = chat.ToNonAD()
+	if chat.Server == types.HiddenUserServer {
+		chat = NormalizeJIDFromLID(ctx, chat, client).ToNonAD()
 	}
+	return chat.String()
 }
 
 func handleChatwootSync(ctx context.Context, evt *events.Message, client *whatsmeow.Client) {
@@ -157,7 +204,9 @@ func handleChatwootSync(ctx context.Context, evt *events.Message, client *whatsm
 	senderJID := realJID.String()
 
 	// Run in background to avoid blocking message processing.
+	work := trackInflight("avatar sync of "+senderJID, nil)
 	go func() {
+		defer work.done()
 		logrus.Debugf("Chatwoot Sync: Attempting to auto-sync avatar for %s", senderJID)
 		syncSvc := chatwoot.GetDefaultSyncService()
 		if syncSvc == nil {
@@ -168,7 +217,7 @@ func handleChatwootSync(ctx context.Context, evt *events.Message, client *whatsm
 		syncCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
-		if err := syncSvc.SyncContactAvatarSmart(syncCtx, senderJID, evt.Info.PushName, client); err != nil {
+		if err := syncSvc.SyncContactAvatarSmart(syncCtx, senderJID, evt.Info.PushName, client, false); err != nil {
 			logrus.Debugf("Chatwoot Sync: Failed avatar sync for %s: %v", senderJID, err)
 		}
 
@@ -13,6 +13,7 @@ import (
 // handleNewsletterJoin handles when you join/subscribe to a newsletter
 func handleNewsletterJoin(ctx context.Context, evt *events.NewsletterJoin, deviceID string, client *whatsmeow.Client) {
 	log.Infof("Joined newsletter %s", evt.ID)
+	setNewsletterName(evt.ID.String(), evt.ThreadMeta.Name.Text)
 
 	if len(config.WhatsappWebhook) > 0 {
 		go func(e *events.NewsletterJoin) {
@@ -2,14 +2,19 @@ package whatsapp
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 )
 
+// receiptDeduper remembers the receipts forwarded in the last 10 seconds.
+var receiptDeduper = utils.NewTTLCache[string, struct{}](10000, 10*time.Second)
+
 func getReceiptTypeDescription(evt types.ReceiptType) string {
 	switch evt {
 	case types.ReceiptTypeDelivered:
@@ -58,6 +63,9 @@ func createReceiptPayload(ctx context.Context, evt *events.Receipt, deviceID str
 	normalizedSenderJID := NormalizeJIDFromLID(ctx, senderJID, client)
 	payload["from"] = normalizedSenderJID.ToNonAD().String()
 
+	payload["sender"] = normalizedSenderJID.ToNonAD().String()
+	payload["timestamp"] = evt.Timestamp.Format(time.RFC3339)
+
 	// Receipt type
 	if evt.Type == types.ReceiptTypeDelivered {
 		payload["receipt_type"] = "delivered"
@@ -94,6 +102,29 @@ func forwardReceiptToWebhook(ctx context.Context, evt *events.Receipt, deviceID
 		return nil
 	}
 
+	if isDuplicateReceipt(evt, deviceID) {
+		logrus.Debugf("Skipping duplicate %s receipt for %v", evt.Type, evt.MessageIDs)
+		return nil
+	}
+
 	payload := createReceiptPayload(ctx, evt, deviceID, client)
 	return forwardPayloadToConfiguredWebhooks(ctx, payload, "message.ack")
 }
+
+// isDuplicateReceipt reports whether an identical receipt (same device, chat, sender, type and
+// message IDs) was already forwarded in the last 10 seconds. WhatsApp re-sends receipts in
+// bursts after reconnects and for batched reads.
+func isDuplicateReceipt(evt *events.Receipt, deviceID string) bool {
+	key := strings.Join([]string{
+		deviceID,
+		evt.Chat.ToNonAD().String(),
+		evt.Sender.ToNonAD().String(),
+		string(evt.Type),
+		strings.Join(evt.MessageIDs, ","),
+	}, "|")
+	if _, seen := receiptDeduper.Get(key); seen {
+		return true
+	}
+	receiptDeduper.Set(key, struct{}{})
+	return false
+}
@@ -0,0 +1,126 @@
+package whatsapp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func newTestReceipt(receiptType types.ReceiptType, ids ...string) *events.Receipt {
+	return &events.Receipt{
+		MessageSource: types.MessageSource{
+			Chat:   types.NewJID("6281234567890", types.DefaultUserServer),
+			Sender: types.NewJID("6281234567890", types.DefaultUserServer),
+		},
+		MessageIDs: ids,
+		Timestamp:  time.Unix(1700000000, 0),
+		Type:       receiptType,
+	}
+}
+
+func TestIsDuplicateReceipt(t *testing.T) {
+	first := newTestReceipt(types.ReceiptTypeRead, "dup-1", "dup-2")
+	if isDuplicateReceipt(first, "dev") {
+		t.Fatal("first receipt must not be a duplicate")
+	}
+	if !isDuplicateReceipt(newTestReceipt(types.ReceiptTypeRead, "dup-1", "dup-2"), "dev") {
+		t.Fatal("identical receipt in the burst window must be a duplicate")
+	}
+	if isDuplicateReceipt(newTestReceipt(types.ReceiptTypePlayed, "dup-1", "dup-2"), "dev") {
+		t.Fatal("different receipt type must not be a duplicate")
+	}
+	if isDuplicateReceipt(newTestReceipt(types.ReceiptTypeRead, "dup-1", "dup-2"), "other-dev") {
+		t.Fatal("receipt for another device must not be a duplicate")
+	}
+}
+
+func TestCreateReceiptPayload_Played(t *testing.T) {
+	body := createReceiptPayload(context.Background(), newTestReceipt(types.ReceiptTypePlayed, "m1"), "dev", nil)
+	payload := body["payload"].(map[string]any)
+
+	if body["event"] != "message.ack" || body["device_id"] != "dev" {
+		t.Fatalf("unexpected envelope: %v", body)
+	}
+	if payload["receipt_type"] != "played" {
+		t.Fatalf("expected receipt_type played, got %v", payload["receipt_type"])
+	}
+	if payload["chat_id"] != "6281234567890@s.whatsapp.net" || payload["sender"] != "6281234567890@s.whatsapp.net" {
+		t.Fatalf("unexpected chat/sender: %v / %v", payload["chat_id"], payload["sender"])
+	}
+	if payload["timestamp"] != time.Unix(1700000000, 0).Format(time.RFC3339) {
+		t.Fatalf("unexpected timestamp: %v", payload["timestamp"])
+	}
+}
+
+func TestForwardToWebhooks_ReceiptsFollowTheWhitelist(t *testing.T) {
+	originalWebhooks := config.WhatsappWebhook
+	originalEvents := config.WhatsappWebhookEvents
+	config.WhatsappWebhook = []string{"https://test.com"}
+	config.WhatsappWebhookEvents = nil
+	defer func() {
+		config.WhatsappWebhook = originalWebhooks
+		config.WhatsappWebhookEvents = originalEvents
+	}()
+
+	calls := 0
+	originalSubmit := submitWebhookFn
+	submitWebhookFn = func(context.Context, map[string]any, string) error {
+		calls++
+		return nil
+	}
+	defer func() { submitWebhookFn = originalSubmit }()
+
+	_ = forwardPayloadToConfiguredWebhooks(context.Background(), map[string]any{}, "message.ack")
+	if calls != 1 {
+		t.Fatalf("message.ack must be forwarded when no whitelist is configured, got %d calls", calls)
+	}
+
+	config.WhatsappWebhookEvents = []string{"message"}
+	_ = forwardPayloadToConfiguredWebhooks(context.Background(), map[string]any{}, "message.ack")
+	if calls != 1 {
+		t.Fatalf("message.ack must not be forwarded when the whitelist leaves it out, got %d calls", calls)
+	}
+}
+
+type receiptRecordingRepo struct {
+	domainChatStorage.IChatStorageRepository
+	receipts []*domainChatStorage.MessageReceipt
+}
+
+func (r *receiptRecordingRepo) UpdateMessageReceipt(receipts ...*domainChatStorage.MessageReceipt) error {
+	r.receipts = append(r.receipts, receipts...)
+	return nil
+}
+
+func TestQueueMessageReceipts_MergesUntilFlush(t *testing.T) {
+	repo := &receiptRecordingRepo{}
+	SetReceiptRepository(repo)
+	t.Cleanup(func() { SetReceiptRepository(nil) })
+	// Flush by hand instead of on the background ticker
+	receiptStore.once.Do(func() {})
+
+	queueMessageReceipts(newTestReceipt(types.ReceiptTypeDelivered, "m1", "m2"), "dev")
+	queueMessageReceipts(newTestReceipt(types.ReceiptTypeRead, "m1"), "dev")
+	queueMessageReceipts(newTestReceipt(types.ReceiptTypeRetry, "m3"), "dev")
+	own := newTestReceipt(types.ReceiptTypeRead, "m4")
+	own.IsFromMe = true
+	queueMessageReceipts(own, "dev")
+	flushMessageReceipts()
+
+	if len(repo.receipts) != 2 {
+		t.Fatalf("expected one merged receipt per message, got %d", len(repo.receipts))
+	}
+	for _, receipt := range repo.receipts {
+		if receipt.DeviceID != "dev" || receipt.Participant != "6281234567890@s.whatsapp.net" || receipt.DeliveredAt == nil {
+			t.Fatalf("unexpected receipt %+v", receipt)
+		}
+		if (receipt.MessageID == "m1") != (receipt.ReadAt != nil) {
+			t.Fatalf("expected only m1 to be read, got %+v", receipt)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package whatsapp
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/metrics"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// After a reconnect WhatsApp replays what arrived while the device was offline: hundreds of events
+// in a burst, out of timestamp order and partly delivered twice. Message forwards seen during that
+// burst are held and released in timestamp order once the offline sync of their device completes,
+// and the Chatwoot deduper keeps message IDs for longer while bursts are expected. Other devices keep
+// forwarding meanwhile.
+const (
+	reconnectBurstHoldMax   = 30 * time.Second
+	reconnectBurstDedupeTTL = 30 * time.Minute
+)
+
+type heldForward struct {
+	chat      string
+	timestamp time.Time
+	job       forwardJob
+}
+
+// forwardJob is a message forward waiting for its worker.
+type forwardJob struct {
+	run   func()
+	spill func() // Saves the forward for replay when its worker has no room for it
+}
+
+// forwardOrder runs message forwards on a fixed set of workers. Every chat hashes to one worker, as
+// keys do to the shards of a utils.ShardedLock, and the worker runs its forwards one at a time in
+// the order they were dispatched. A slow attachment upload thus holds back the later messages of
+// its chat, never those of chats on other workers.
+var forwardOrder = struct {
+	mu        sync.Mutex
+	workers   []chan forwardJob // Started by the first dispatch
+	pending   int               // Forwards queued or running
+	bursts    map[string]*time.Timer
+	held      map[string][]heldForward // By device
+	releasing map[string]bool          // Devices whose held forwards are being queued; new ones wait behind them
+}{
+	bursts:    make(map[string]*time.Timer),
+	held:      make(map[string][]heldForward),
+	releasing: make(map[string]bool),
+}
+
+// dispatchOrderedForward runs run after every forward previously dispatched for chat. While deviceID
+// is replaying its offline messages the forward is held instead, and sorted by timestamp with the
+// rest of the burst. When the queue of the worker stays full for WHATSAPP_FORWARD_QUEUE_WAIT_MS,
+// spill is called instead of run.
+func dispatchOrderedForward(deviceID, chat string, timestamp time.Time, run, spill func()) {
+	job := forwardJob{run: run, spill: spill}
+
+	forwardOrder.mu.Lock()
+	if _, bursting := forwardOrder.bursts[deviceID]; bursting || forwardOrder.releasing[deviceID] {
+		forwardOrder.held[deviceID] = append(forwardOrder.held[deviceID], heldForward{chat: chat, timestamp: timestamp, job: job})
+		forwardOrder.mu.Unlock()
+		return
+	}
+	forwardOrder.mu.Unlock()
+	queueForward(chat, job)
+}
+
+// forwardQueue returns the queue of the worker that runs the forwards of chat.
+func forwardQueue(chat string) chan forwardJob {
+	forwardOrder.mu.Lock()
+	defer forwardOrder.mu.Unlock()
+
+	if forwardOrder.workers == nil {
+		workers, size := max(config.WhatsappForwardWorkers, 1), max(config.WhatsappForwardQueueSize, 1)
+		forwardOrder.workers = make([]chan forwardJob, workers)
+		for i := range forwardOrder.workers {
+			queue := make(chan forwardJob, size)
+			forwardOrder.workers[i] = queue
+			go runForwardWorker(queue)
+		}
+	}
+	return forwardOrder.workers[utils.ShardIndex(chat, len(forwardOrder.workers))]
+}
+
+// queueForward hands job to the worker of chat, waiting up to WHATSAPP_FORWARD_QUEUE_WAIT_MS for
+// room. A forward that finds no room is spilled: its webhook posts and Chatwoot forward go to the
+// outbox, so a burst slows the event handler down for a while at most and loses nothing.
+func queueForward(chat string, job forwardJob) {
+	queue := forwardQueue(chat)
+	addPendingForwards(1)
+	metrics.ForwardQueueDepth(1)
+	if sendForward(queue, job, time.Duration(config.WhatsappForwardQueueWaitMs)*time.Millisecond) {
+		return
+	}
+
+	metrics.ForwardQueueDepth(-1)
+	metrics.ForwardSpilled()
+	logrus.Warnf("Forward queue full, saving the forward of a message in %s for replay", chat)
+	if job.spill != nil {
+		job.spill()
+	}
+	addPendingForwards(-1)
+}
+
+// runOrderedForward runs run on the worker of chat, behind the forwards already queued for it, and
+// returns its error. It returns errForwardQueueFull when the worker has no room within
+// WHATSAPP_FORWARD_QUEUE_WAIT_MS.
+func runOrderedForward(chat string, run func() error) error {
+	result := make(chan error, 1)
+	queue := forwardQueue(chat)
+	addPendingForwards(1)
+	metrics.ForwardQueueDepth(1)
+	if !sendForward(queue, forwardJob{run: func() { result <- run() }}, time.Duration(config.WhatsappForwardQueueWaitMs)*time.Millisecond) {
+		metrics.ForwardQueueDepth(-1)
+		addPendingForwards(-1)
+		return errForwardQueueFull
+	}
+	return <-result
+}
+
+func sendForward(queue chan forwardJob, job forwardJob, wait time.Duration) bool {
+	select {
+	case queue <- job:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case queue <- job:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func runForwardWorker(queue chan forwardJob) {
+	for job := range queue {
+		metrics.ForwardQueueDepth(-1)
+		job.run()
+		addPendingForwards(-1)
+	}
+}
+
+func addPendingForwards(delta int) {
+	forwardOrder.mu.Lock()
+	forwardOrder.pending += delta
+	forwardOrder.mu.Unlock()
+}
+
+// beginReconnectBurst starts holding message forwards for a device that has just connected. The
+// hold ends when the device finishes its offline sync, or after reconnectBurstHoldMax.
+func beginReconnectBurst(deviceID string) {
+	if !config.ChatwootEnabled {
+		return
+	}
+	extendChatwootForwardDedupe(time.Now().Add(reconnectBurstDedupeTTL))
+
+	forwardOrder.mu.Lock()
+	defer forwardOrder.mu.Unlock()
+	if timer, ok := forwardOrder.bursts[deviceID]; ok {
+		timer.Stop()
+	}
+	forwardOrder.bursts[deviceID] = time.AfterFunc(reconnectBurstHoldMax, func() {
+		endReconnectBurst(deviceID)
+	})
+	logrus.Debugf("Chatwoot: Holding message forwards while %s replays offline messages", deviceID)
+}
+
+// endReconnectBurst releases the forwards held for deviceID in timestamp order.
+func endReconnectBurst(deviceID string) {
+	forwardOrder.mu.Lock()
+	defer forwardOrder.mu.Unlock()
+
+	timer, ok := forwardOrder.bursts[deviceID]
+	if !ok {
+		return
+	}
+	timer.Stop()
+	delete(forwardOrder.bursts, deviceID)
+	extendChatwootForwardDedupe(time.Now().Add(reconnectBurstDedupeTTL))
+	if forwardOrder.releasing[deviceID] {
+		return
+	}
+
+	// Queueing may wait for room, so it runs unlocked; forwards dispatched meanwhile are held and
+	// released behind the burst.
+	forwardOrder.releasing[deviceID] = true
+	for len(forwardOrder.held[deviceID]) > 0 {
+		if _, bursting := forwardOrder.bursts[deviceID]; bursting {
+			break
+		}
+		held := forwardOrder.held[deviceID]
+		delete(forwardOrder.held, deviceID)
+		sort.SliceStable(held, func(i, j int) bool {
+			return held[i].timestamp.Before(held[j].timestamp)
+		})
+		logrus.Infof("Chatwoot: Releasing %d message forward(s) held during the offline sync of %s", len(held), deviceID)
+
+		forwardOrder.mu.Unlock()
+		for _, h := range held {
+			queueForward(h.chat, h.job)
+		}
+		forwardOrder.mu.Lock()
+	}
+	delete(forwardOrder.releasing, deviceID)
+}
+
+// endReconnectBursts releases the forwards held for every device, so a shutdown can drain them.
+func endReconnectBursts() {
+	forwardOrder.mu.Lock()
+	deviceIDs := make([]string, 0, len(forwardOrder.bursts))
+	for deviceID := range forwardOrder.bursts {
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+	forwardOrder.mu.Unlock()
+
+	for _, deviceID := range deviceIDs {
+		endReconnectBurst(deviceID)
+	}
+}
@@ -0,0 +1,304 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+// useForwardPool starts a fresh forward pool with the given settings for the test.
+func useForwardPool(t *testing.T, workers, size, waitMs int) {
+	t.Helper()
+	origWorkers, origSize, origWait := config.WhatsappForwardWorkers, config.WhatsappForwardQueueSize, config.WhatsappForwardQueueWaitMs
+	config.WhatsappForwardWorkers, config.WhatsappForwardQueueSize, config.WhatsappForwardQueueWaitMs = workers, size, waitMs
+
+	forwardOrder.mu.Lock()
+	prev := forwardOrder.workers
+	forwardOrder.workers = nil
+	forwardOrder.mu.Unlock()
+
+	t.Cleanup(func() {
+		waitForwardsIdle(t)
+		forwardOrder.mu.Lock()
+		for _, queue := range forwardOrder.workers {
+			close(queue)
+		}
+		forwardOrder.workers = prev
+		forwardOrder.mu.Unlock()
+		config.WhatsappForwardWorkers, config.WhatsappForwardQueueSize, config.WhatsappForwardQueueWaitMs = origWorkers, origSize, origWait
+	})
+}
+
+// forwardRecorder records the forwards that ran and the ones that were spilled.
+type forwardRecorder struct {
+	mu      sync.Mutex
+	ran     []string
+	spilled []string
+}
+
+func (r *forwardRecorder) dispatch(chat, name string, before func()) {
+	dispatchOrderedForward("device", chat, time.Now(), func() {
+		if before != nil {
+			before()
+		}
+		r.mu.Lock()
+		r.ran = append(r.ran, name)
+		r.mu.Unlock()
+	}, func() {
+		r.mu.Lock()
+		r.spilled = append(r.spilled, name)
+		r.mu.Unlock()
+	})
+}
+
+func TestQueueForward_SpillsWhenTheQueueStaysFull(t *testing.T) {
+	useForwardPool(t, 1, 1, 0)
+	rec := &forwardRecorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+
+	rec.dispatch("a@s.whatsapp.net", "A", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	rec.dispatch("a@s.whatsapp.net", "B", nil) // Fills the queue of the only worker
+	rec.dispatch("b@s.whatsapp.net", "C", nil)
+
+	rec.mu.Lock()
+	spilled := append([]string(nil), rec.spilled...)
+	rec.mu.Unlock()
+	if len(spilled) != 1 || spilled[0] != "C" {
+		t.Fatalf("expected C spilled while the queue was full, got %v", spilled)
+	}
+
+	close(release)
+	waitForwardsIdle(t)
+	if fmt.Sprint(rec.ran) != "[A B]" {
+		t.Errorf("expected A and B to run, got %v", rec.ran)
+	}
+}
+
+func TestQueueForward_SpillsChatwootForwardToOutbox(t *testing.T) {
+	useForwardPool(t, 1, 1, 0)
+	repo := newMemoryOutboxRepo()
+	withOutboxRepo(t, repo, 3)
+	rec := &forwardRecorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+
+	rec.dispatch("a@s.whatsapp.net", "A", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	rec.dispatch("a@s.whatsapp.net", "B", nil)
+
+	payload := map[string]any{"event": "message", "payload": map[string]any{"id": "MSG1", "chat_id": "b@s.whatsapp.net"}}
+	work := trackInflight("message MSG1 forward", func(ctx context.Context, cause error) {
+		newInflightForward(nil, true).persist(ctx, payload, "message", cause)
+	})
+	dispatchOrderedForward("device", "b@s.whatsapp.net", time.Now(), func() {
+		defer work.done()
+		t.Error("expected the forward spilled, not run")
+	}, func() {
+		defer work.done()
+		work.save(context.Background(), errForwardQueueFull)
+	})
+	close(release)
+
+	if len(repo.entries) != 1 {
+		t.Fatalf("expected the spilled Chatwoot forward queued, got %d entries", len(repo.entries))
+	}
+	for _, e := range repo.entries {
+		if e.URL != chatwootOutboxURL || e.LastError != errForwardQueueFull.Error() {
+			t.Errorf("unexpected queued entry: %+v", e)
+		}
+	}
+}
+
+// A spilled forward is replayed on the worker of its chat: it runs behind the forwards already queued
+// for the chat and holds back the ones dispatched after it.
+func TestReplayChatwootForward_KeepsChatOrderAfterSpill(t *testing.T) {
+	useForwardPool(t, 1, 1, 0)
+	repo := newMemoryOutboxRepo()
+	withOutboxRepo(t, repo, 3)
+	rec := &forwardRecorder{}
+	const chat = "a@s.whatsapp.net"
+
+	originalForward := forwardToChatwootFn
+	forwardToChatwootFn = func(_ context.Context, payload map[string]any) error {
+		data, _ := payload["payload"].(map[string]any)
+		rec.mu.Lock()
+		rec.ran = append(rec.ran, data["id"].(string))
+		rec.mu.Unlock()
+		return nil
+	}
+	t.Cleanup(func() { forwardToChatwootFn = originalForward })
+
+	started, release := make(chan struct{}), make(chan struct{})
+	rec.dispatch(chat, "A", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	rec.dispatch(chat, "B", nil) // Fills the queue of the only worker
+	payload := map[string]any{"event": "message", "payload": map[string]any{"id": "S", "chat_id": chat}}
+	work := trackInflight("message S forward", func(ctx context.Context, cause error) {
+		newInflightForward(nil, true).persist(ctx, payload, "message", cause)
+	})
+	dispatchOrderedForward("device", chat, time.Now(), func() {
+		defer work.done()
+		t.Error("expected S spilled, not run")
+	}, func() {
+		defer work.done()
+		work.save(context.Background(), errForwardQueueFull)
+	})
+	close(release)
+	waitForwardsIdle(t)
+	if len(repo.entries) != 1 {
+		t.Fatalf("expected S queued in the outbox, got %d entries", len(repo.entries))
+	}
+
+	// The replay waits behind D, and C, dispatched while the replay waits, runs after it
+	config.WhatsappForwardQueueWaitMs = 2000
+	started, release = make(chan struct{}), make(chan struct{})
+	rec.dispatch(chat, "D", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	replayed := make(chan int)
+	go func() { replayed <- dispatchDueWebhooks(context.Background(), time.Now()) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		forwardOrder.mu.Lock()
+		pending := forwardOrder.pending
+		forwardOrder.mu.Unlock()
+		if pending == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the replay was not queued behind D")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	rec.dispatch(chat, "C", nil) // Waits for room behind the replay
+
+	if n := <-replayed; n != 1 {
+		t.Fatalf("expected S replayed, %d delivered", n)
+	}
+	waitForwardsIdle(t)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if fmt.Sprint(rec.ran) != "[A B D S C]" {
+		t.Errorf("expected the replay in chat order, got %v", rec.ran)
+	}
+	if len(rec.spilled) != 0 || len(repo.entries) != 0 {
+		t.Errorf("expected nothing else spilled and the outbox empty, got %v and %d entries", rec.spilled, len(repo.entries))
+	}
+}
+
+func TestQueueForward_WaitsBrieflyForRoom(t *testing.T) {
+	useForwardPool(t, 1, 1, 2000)
+	rec := &forwardRecorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+
+	rec.dispatch("a@s.whatsapp.net", "A", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	rec.dispatch("a@s.whatsapp.net", "B", nil)
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	rec.dispatch("a@s.whatsapp.net", "C", nil) // Blocks until A is done
+
+	waitForwardsIdle(t)
+	if len(rec.spilled) != 0 || fmt.Sprint(rec.ran) != "[A B C]" {
+		t.Errorf("expected every forward to run in order, ran %v, spilled %v", rec.ran, rec.spilled)
+	}
+}
+
+func TestDispatchOrderedForward_KeepsChatOrderOnSharedWorkers(t *testing.T) {
+	useForwardPool(t, 3, 100, 2000)
+	var (
+		mu  sync.Mutex
+		ran = make(map[string][]int)
+	)
+	const chats, perChat = 8, 20
+	for i := 0; i < perChat; i++ {
+		for c := 0; c < chats; c++ {
+			chat, n := fmt.Sprintf("chat-%d@s.whatsapp.net", c), i
+			dispatchOrderedForward("device", chat, time.Now(), func() {
+				time.Sleep(time.Duration(n%3) * time.Millisecond)
+				mu.Lock()
+				ran[chat] = append(ran[chat], n)
+				mu.Unlock()
+			}, nil)
+		}
+	}
+
+	waitForwardsIdle(t)
+	for chat, order := range ran {
+		for i, n := range order {
+			if n != i {
+				t.Fatalf("forwards of %s ran out of order: %v", chat, order)
+			}
+		}
+	}
+	if len(ran) != chats {
+		t.Errorf("expected forwards of %d chats, got %d", chats, len(ran))
+	}
+}
+
+func TestDispatchOrderedForward_SlowAttachmentKeepsChatOrder(t *testing.T) {
+	origEnabled, origWebhooks, origEvents := config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents
+	config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents = true, nil, nil
+	t.Cleanup(func() {
+		config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents = origEnabled, origWebhooks, origEvents
+	})
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+	useForwardPool(t, 4, 10, 2000)
+
+	fake := newFakeChatwoot(t)
+	fake.mu.Lock()
+	fake.uploadDelay = 300 * time.Millisecond
+	fake.mu.Unlock()
+
+	image := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(image, []byte("\xff\xd8\xff\xe0 not really a jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chat := "628111000009@s.whatsapp.net"
+	messages := []map[string]any{
+		{"id": "ORDER1", "body": "please send the invoice"},
+		{"id": "ORDER2", "body": "this one", "image": image},
+		{"id": "ORDER3", "body": "to the new address"},
+	}
+	for _, data := range messages {
+		data["from"], data["from_name"], data["chat_id"] = chat, "Customer", chat
+		payload := map[string]any{"event": "message", "payload": data}
+		dispatchOrderedForward("device", chat, time.Now(), func() {
+			_ = forwardPayloadToConfiguredWebhooks(context.Background(), payload, "message")
+		}, nil)
+	}
+	waitForwardsIdle(t)
+
+	got := fake.snapshot()
+	if len(got) != 1 {
+		t.Fatalf("expected one conversation, got %v", got)
+	}
+	for _, msgs := range got {
+		want := "please send the invoice|this one [attachment]|to the new address"
+		if strings.Join(msgs, "|") != want {
+			t.Fatalf("messages arrived out of order:\n got %s\nwant %s", strings.Join(msgs, "|"), want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// forwardPayloadProtosKey holds, in a stored payload, the protobuf messages of its "payload" object
+// in their wire format. Encoded as JSON they lose their type, and the Chatwoot forward renders
+// locations, contacts and polls from the typed messages.
+const forwardPayloadProtosKey = "_protos"
+
+// forwardPayloadProtos are the payload fields buildOtherMessageTypes fills with protobuf messages.
+var forwardPayloadProtos = map[string]func() proto.Message{
+	"contact":        func() proto.Message { return &waE2E.ContactMessage{} },
+	"contacts_array": func() proto.Message { return &waE2E.ContactsArrayMessage{} },
+	"list":           func() proto.Message { return &waE2E.ListMessage{} },
+	"live_location":  func() proto.Message { return &waE2E.LiveLocationMessage{} },
+	"location":       func() proto.Message { return &waE2E.LocationMessage{} },
+	"order":          func() proto.Message { return &waE2E.OrderMessage{} },
+	"poll":           func() proto.Message { return &waE2E.PollCreationMessage{} },
+}
+
+// encodeForwardPayload encodes a forwarded event for storage, keeping its protobuf messages so
+// decodeForwardPayload gives back the payload the forward was built from.
+func encodeForwardPayload(payload map[string]any) ([]byte, error) {
+	data, ok := payload["payload"].(map[string]any)
+	if !ok {
+		return json.Marshal(payload)
+	}
+
+	protos := make(map[string][]byte)
+	for field := range forwardPayloadProtos {
+		msg, ok := data[field].(proto.Message)
+		if !ok {
+			continue
+		}
+		raw, err := proto.Marshal(msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", field, err)
+		}
+		protos[field] = raw
+	}
+	if len(protos) == 0 {
+		return json.Marshal(payload)
+	}
+
+	stored := make(map[string]any, len(payload)+1)
+	for key, value := range payload {
+		stored[key] = value
+	}
+	stored[forwardPayloadProtosKey] = protos
+	return json.Marshal(stored)
+}
+
+// decodeForwardPayload reads a payload stored by encodeForwardPayload.
+func decodeForwardPayload(body []byte) (map[string]any, error) {
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	protos, _ := payload[forwardPayloadProtosKey].(map[string]any)
+	delete(payload, forwardPayloadProtosKey)
+	data, ok := payload["payload"].(map[string]any)
+	if !ok {
+		return payload, nil
+	}
+
+	for field, encoded := range protos {
+		newMessage, known := forwardPayloadProtos[field]
+		text, _ := encoded.(string)
+		raw, err := base64.StdEncoding.DecodeString(text)
+		if !known || err != nil {
+			return nil, fmt.Errorf("invalid stored %s", field)
+		}
+		msg := newMessage()
+		if err := proto.Unmarshal(raw, msg); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", field, err)
+		}
+		data[field] = msg
+	}
+	return payload, nil
+}
+
+// forwardPayloadContext returns a context carrying the device of a stored payload, so a replayed
+// forward reaches the same webhooks and Chatwoot inbox as the original would have.
+func forwardPayloadContext(payload map[string]any) context.Context {
+	ctx := context.Background()
+	if deviceJID, _ := payload["device_id"].(string); deviceJID != "" {
+		if dm := GetDeviceManager(); dm != nil {
+			if inst, ok := dm.FindDeviceByJID(deviceJID); ok {
+				ctx = ContextWithDevice(ctx, inst)
+			}
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,202 @@
+package whatsapp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const groupInfoCacheTTL = 5 * time.Minute
+
+// groupInfoFetcher is the part of the WhatsApp client used to look up group subjects.
+type groupInfoFetcher interface {
+	GetGroupInfo(ctx context.Context, jid types.JID) (*types.GroupInfo, error)
+}
+
+// GroupInfoService is the single source of group subjects for webhooks, chat storage and Chatwoot.
+// Subjects are cached for groupInfoCacheTTL, concurrent lookups of the same group share one
+// GetGroupInfo call, and every subject learned is written to the stored chat so chat listings
+// show the real name instead of a placeholder.
+type GroupInfoService struct {
+	cache *utils.TTLCache[string, string]
+
+	mu       sync.Mutex
+	inflight map[string]*groupInfoCall
+	repo     domainChatStorage.IChatStorageRepository
+}
+
+type groupInfoCall struct {
+	done chan struct{}
+	name string
+}
+
+var (
+	groupInfoServiceOnce sync.Once
+	groupInfoService     *GroupInfoService
+)
+
+// NewGroupInfoService creates a service caching at most maxEntries subjects for ttl each.
+func NewGroupInfoService(maxEntries int, ttl time.Duration) *GroupInfoService {
+	return &GroupInfoService{
+		cache:    utils.NewTTLCache[string, string](maxEntries, ttl),
+		inflight: make(map[string]*groupInfoCall),
+	}
+}
+
+// GetGroupInfoService returns the shared service, sized by config.AppGroupNameCacheSize.
+func GetGroupInfoService() *GroupInfoService {
+	groupInfoServiceOnce.Do(func() {
+		groupInfoService = NewGroupInfoService(config.AppGroupNameCacheSize, groupInfoCacheTTL)
+		groupInfoService.cache.StartSweeping(groupInfoCacheTTL)
+	})
+	return groupInfoService
+}
+
+// SetGroupInfoRepository sets the chat storage that group subjects are persisted into.
+func SetGroupInfoRepository(repo domainChatStorage.IChatStorageRepository) {
+	GetGroupInfoService().SetRepository(repo)
+}
+
+// repository returns the chat storage names are persisted into, or nil.
+func (s *GroupInfoService) repository() domainChatStorage.IChatStorageRepository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.repo
+}
+
+// ResolveGroupName returns the subject of a group through the shared service. It matches the
+// resolver signature expected by chatwoot.SetGroupNameResolver.
+func ResolveGroupName(client *whatsmeow.Client, groupJID string) string {
+	var fetcher groupInfoFetcher
+	if client != nil {
+		fetcher = client
+	}
+	return GetGroupInfoService().Name(fetcher, groupJID)
+}
+
+// SetRepository sets the chat storage that subjects are persisted into; nil disables persistence.
+func (s *GroupInfoService) SetRepository(repo domainChatStorage.IChatStorageRepository) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo = repo
+}
+
+// Cached returns the subject of a group without asking WhatsApp.
+func (s *GroupInfoService) Cached(groupJID string) (string, bool) {
+	return s.cache.Get(groupJID)
+}
+
+// Name returns the subject of a group, asking WhatsApp only on a cache miss. Callers that miss at
+// the same time wait for a single fetch. It returns "" when the subject is unknown and fetcher is nil
+// or the fetch fails.
+func (s *GroupInfoService) Name(fetcher groupInfoFetcher, groupJID string) string {
+	if name, ok := s.cache.Get(groupJID); ok {
+		return name
+	}
+	if fetcher == nil {
+		return ""
+	}
+
+	s.mu.Lock()
+	if call, ok := s.inflight[groupJID]; ok {
+		s.mu.Unlock()
+		<-call.done
+		return call.name
+	}
+	call := &groupInfoCall{done: make(chan struct{})}
+	s.inflight[groupJID] = call
+	s.mu.Unlock()
+
+	call.name = s.fetch(fetcher, groupJID)
+
+	s.mu.Lock()
+	delete(s.inflight, groupJID)
+	s.mu.Unlock()
+	close(call.done)
+	return call.name
+}
+
+// Refresh asks WhatsApp for the subject even when one is cached.
+func (s *GroupInfoService) Refresh(fetcher groupInfoFetcher, groupJID string) string {
+	s.cache.Delete(groupJID)
+	if fetcher == nil {
+		return ""
+	}
+	return s.fetch(fetcher, groupJID)
+}
+
+// Set records a subject learned elsewhere, e.g. from a group info event.
+func (s *GroupInfoService) Set(groupJID, name string) {
+	if name == "" {
+		return
+	}
+	s.cache.Set(groupJID, name)
+
+	repo := s.repository()
+	if repo == nil {
+		return
+	}
+	if err := repo.UpdateChatName(groupJID, name); err != nil {
+		logrus.Warnf("Group info: failed to store name of %s: %v", groupJID, err)
+	}
+}
+
+// Invalidate drops the cached subject of a group and reports whether one was cached.
+func (s *GroupInfoService) Invalidate(groupJID string) bool {
+	return s.cache.Delete(groupJID)
+}
+
+// Purge drops every cached subject and returns how many there were.
+func (s *GroupInfoService) Purge() int {
+	return s.cache.Purge()
+}
+
+func (s *GroupInfoService) fetch(fetcher groupInfoFetcher, groupJID string) string {
+	jid, err := types.ParseJID(groupJID)
+	if err != nil {
+		logrus.Warnf("Group info: failed to parse group JID %s: %v", groupJID, err)
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	logrus.Debugf("Group info: fetching %s", groupJID)
+	groupInfo, err := fetcher.GetGroupInfo(ctx, jid)
+	if err != nil {
+		logrus.Warnf("Group info: failed to get group info for %s: %v", groupJID, err)
+		return ""
+	}
+	if groupInfo == nil || groupInfo.Name == "" {
+		logrus.Debugf("Group info: %s has no subject", groupJID)
+		return ""
+	}
+
+	s.Set(groupJID, groupInfo.Name)
+	return groupInfo.Name
+}
+
+func getCachedGroupName(groupJID string) (string, bool) {
+	return GetGroupInfoService().Cached(groupJID)
+}
+
+func setCachedGroupName(groupJID, name string) {
+	GetGroupInfoService().Set(groupJID, name)
+}
+
+// InvalidateGroupName drops the cached subject of a group so the next lookup asks WhatsApp.
+func InvalidateGroupName(groupJID string) bool {
+	return GetGroupInfoService().Invalidate(groupJID)
+}
+
+// PurgeGroupNames empties the group subject cache and returns how many entries were dropped.
+func PurgeGroupNames() int {
+	return GetGroupInfoService().Purge()
+}
@@ -0,0 +1,121 @@
+package whatsapp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// countingGroupInfoClient counts GetGroupInfo calls and holds each one until release is closed.
+type countingGroupInfoClient struct {
+	name    string
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (f *countingGroupInfoClient) GetGroupInfo(_ context.Context, jid types.JID) (*types.GroupInfo, error) {
+	f.calls.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	return &types.GroupInfo{JID: jid, GroupName: types.GroupName{Name: f.name}}, nil
+}
+
+type chatNameRecorder struct {
+	domainChatStorage.IChatStorageRepository
+	mu    sync.Mutex
+	names map[string][]string
+}
+
+func (r *chatNameRecorder) UpdateChatName(jid, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names == nil {
+		r.names = make(map[string][]string)
+	}
+	r.names[jid] = append(r.names[jid], name)
+	return nil
+}
+
+func TestGroupInfoService_ConcurrentMissesShareOneFetch(t *testing.T) {
+	svc := NewGroupInfoService(10, time.Minute)
+	repo := &chatNameRecorder{}
+	svc.SetRepository(repo)
+	groupJID := "120363000000000201@g.us"
+	fetcher := &countingGroupInfoClient{name: "Team", release: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	names := make([]string, 5)
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			names[i] = svc.Name(fetcher, groupJID)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(fetcher.release)
+	wg.Wait()
+
+	for i, name := range names {
+		if name != "Team" {
+			t.Fatalf("caller %d got %q", i, name)
+		}
+	}
+	if calls := fetcher.calls.Load(); calls != 1 {
+		t.Fatalf("expected 1 GetGroupInfo call, got %d", calls)
+	}
+	if got := repo.names[groupJID]; len(got) != 1 || got[0] != "Team" {
+		t.Fatalf("expected the name to be stored once, got %v", got)
+	}
+}
+
+func TestGroupInfoService_ConsumersShareOneFetchWithinTTL(t *testing.T) {
+	groupJID := "120363000000000202@g.us"
+	InvalidateGroupName(groupJID)
+	t.Cleanup(func() { InvalidateGroupName(groupJID) })
+	fetcher := &countingGroupInfoClient{name: "Team"}
+
+	// Webhook and Chatwoot forwarding
+	if name := GetGroupInfoService().Name(fetcher, groupJID); name != "Team" {
+		t.Fatalf("expected Team, got %q", name)
+	}
+	// Chatwoot history sync, which may run without a client
+	if name := ResolveGroupName(nil, groupJID); name != "Team" {
+		t.Fatalf("expected the sync resolver to use the cached name, got %q", name)
+	}
+	// Chatwoot contact writer on a rename event that carries the subject
+	cw, renamed := newGroupContactServer(t, groupJID, "Old Name")
+	if err := refreshChatwootGroupContact(fetcher, cw, groupJID, "Team"); err != nil {
+		t.Fatalf("refreshChatwootGroupContact returned error: %v", err)
+	}
+	if len(*renamed) != 1 || (*renamed)[0] != "Team" {
+		t.Fatalf("expected contact to be renamed to Team, got %v", *renamed)
+	}
+
+	if calls := fetcher.calls.Load(); calls != 1 {
+		t.Fatalf("expected 1 GetGroupInfo call across consumers, got %d", calls)
+	}
+}
+
+func TestGroupInfoService_RefreshBypassesCache(t *testing.T) {
+	svc := NewGroupInfoService(10, time.Minute)
+	groupJID := "120363000000000203@g.us"
+	svc.Set(groupJID, "Old Name")
+
+	fetcher := &countingGroupInfoClient{name: "New Name"}
+	if name := svc.Refresh(fetcher, groupJID); name != "New Name" {
+		t.Fatalf("expected New Name, got %q", name)
+	}
+	if name, ok := svc.Cached(groupJID); !ok || name != "New Name" {
+		t.Fatalf("expected cache to hold the new name, got %q (%v)", name, ok)
+	}
+	if name := svc.Refresh(nil, groupJID); name != "" {
+		t.Fatalf("expected no name without a fetcher, got %q", name)
+	}
+}
@@ -114,6 +114,9 @@ func processConversationMessages(ctx context.Context, data *waHistorySync.Histor
 			log.Warnf("Failed to parse JID %s: %v", rawChatJID, err)
 			continue
 		}
+		if skipsNewsletter(jid) {
+			continue
+		}
 
 		// Normalize JID (convert @lid to @s.whatsapp.net if possible)
 		jid = NormalizeJIDFromLID(ctx, jid, client)
@@ -221,6 +224,7 @@ func processConversationMessages(ctx context.Context, data *waHistorySync.Histor
 				FileSHA256:    fileSHA256,
 				FileEncSHA256: fileEncSHA256,
 				FileLength:    fileLength,
+				IsViewOnce:    utils.IsViewOnceMessage(msg.GetMessage()),
 			}
 
 			messageBatch = append(messageBatch, message)
@@ -242,8 +246,8 @@ func processConversationMessages(ctx context.Context, data *waHistorySync.Histor
 				continue
 			}
 
-			// Store messages in batch
-			if err := chatStorageRepo.StoreMessagesBatch(messageBatch); err != nil {
+			// Store messages in bulk; messages already stored, such as live ones, are kept
+			if err := chatStorageRepo.CreateMessagesBatch(ctx, messageBatch); err != nil {
 				log.Warnf("Failed to store messages batch for chat %s: %v", chatJID, err)
 			} else {
 				log.Debugf("Stored %d messages for chat %s", len(messageBatch), chatJID)
@@ -0,0 +1,268 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Webhook posts, Chatwoot forwards and avatar syncs run in goroutines of their own, after the event
+// handler has returned. They register here so a shutdown can wait for them: DrainInflight waits a
+// while, then saves what has not finished. Undelivered webhook posts and Chatwoot forwards go to the
+// outbox, which replays them after the restart; Chatwoot forwards are audited as failed as well. A
+// forward that finishes after it was saved is delivered twice.
+
+var (
+	// errShuttingDown is the error recorded for deliveries saved by DrainInflight.
+	errShuttingDown = errors.New("the server shut down before the delivery finished")
+	// errForwardQueueFull is the error recorded for forwards spilled by a full forward queue.
+	errForwardQueueFull = errors.New("the forward queue was full")
+)
+
+// persistBudget bounds the time DrainInflight spends saving what did not finish.
+const persistBudget = 5 * time.Second
+
+var inflight = struct {
+	mu      sync.Mutex
+	nextID  uint64
+	running map[uint64]*inflightWork
+	idle    chan struct{} // Closed once running is empty, while DrainInflight waits
+}{
+	running: make(map[uint64]*inflightWork),
+}
+
+// inflightWork is a background task registered with trackInflight.
+type inflightWork struct {
+	id   uint64
+	name string
+
+	mu      sync.Mutex
+	persist func(ctx context.Context, cause error) // Saves the task for replay; nil when nothing would be lost
+}
+
+// trackInflight registers a background task. persist saves it for replay when the shutdown cannot
+// wait for it; it is nil for tasks that are simply redone later, like avatar syncs. The caller
+// must call done once the task has finished.
+func trackInflight(name string, persist func(ctx context.Context, cause error)) *inflightWork {
+	inflight.mu.Lock()
+	defer inflight.mu.Unlock()
+
+	inflight.nextID++
+	work := &inflightWork{id: inflight.nextID, name: name, persist: persist}
+	inflight.running[work.id] = work
+	return work
+}
+
+// handOver drops the persist func of w, once a nested task saves the work itself.
+func (w *inflightWork) handOver() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.persist = nil
+	w.mu.Unlock()
+}
+
+// save saves w for replay, unless it was saved or handed over before. It reports whether it did.
+func (w *inflightWork) save(ctx context.Context, cause error) bool {
+	w.mu.Lock()
+	persist := w.persist
+	w.persist = nil
+	w.mu.Unlock()
+	if persist == nil {
+		return false
+	}
+	persist(ctx, cause)
+	return true
+}
+
+func (w *inflightWork) done() {
+	inflight.mu.Lock()
+	defer inflight.mu.Unlock()
+
+	delete(inflight.running, w.id)
+	if len(inflight.running) == 0 && inflight.idle != nil {
+		close(inflight.idle)
+		inflight.idle = nil
+	}
+}
+
+type inflightWorkKey struct{}
+
+func contextWithInflight(ctx context.Context, work *inflightWork) context.Context {
+	return context.WithValue(ctx, inflightWorkKey{}, work)
+}
+
+func inflightFromContext(ctx context.Context) *inflightWork {
+	work, _ := ctx.Value(inflightWorkKey{}).(*inflightWork)
+	return work
+}
+
+// DrainInflight waits up to timeout for the registered background tasks, releasing the forwards
+// held for a reconnect burst first. Tasks still running afterwards are saved for replay. It returns
+// how many tasks were saved.
+func DrainInflight(timeout time.Duration) int {
+	endReconnectBursts()
+
+	inflight.mu.Lock()
+	count := len(inflight.running)
+	if count == 0 {
+		inflight.mu.Unlock()
+		return 0
+	}
+	if inflight.idle == nil {
+		inflight.idle = make(chan struct{})
+	}
+	idle := inflight.idle
+	inflight.mu.Unlock()
+	logrus.Infof("Shutdown: Waiting up to %s for %d running forward(s)", timeout, count)
+
+	select {
+	case <-idle:
+		return 0
+	case <-time.After(timeout):
+	}
+
+	inflight.mu.Lock()
+	pending := make([]*inflightWork, 0, len(inflight.running))
+	for _, work := range inflight.running {
+		pending = append(pending, work)
+	}
+	inflight.mu.Unlock()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].id < pending[j].id })
+
+	ctx, cancel := context.WithTimeout(context.Background(), persistBudget)
+	defer cancel()
+	saved := 0
+	for _, work := range pending {
+		if !work.save(ctx, errShuttingDown) {
+			logrus.Warnf("Shutdown: Abandoning %s", work.name)
+			continue
+		}
+		saved++
+	}
+	if saved > 0 {
+		logrus.Warnf("Shutdown: Saved %d unfinished forward(s) for replay", saved)
+	}
+	return saved
+}
+
+// inflightForward tracks which deliveries of one forwarded event have not finished yet.
+type inflightForward struct {
+	work *inflightWork
+
+	mu        sync.Mutex
+	urls      map[string]bool // Webhook URLs still being posted to
+	chatwoot  bool            // The Chatwoot forward is still running
+	persisted bool
+}
+
+// beginForward registers the delivery of payload to urls and, when toChatwoot is set, to Chatwoot.
+func beginForward(ctx context.Context, payload map[string]any, eventName string, urls []string, toChatwoot bool) *inflightForward {
+	f := newInflightForward(urls, toChatwoot)
+	f.work = trackInflight(eventName+" forward", func(_ context.Context, cause error) {
+		f.persist(ctx, payload, eventName, cause)
+	})
+	return f
+}
+
+func newInflightForward(urls []string, toChatwoot bool) *inflightForward {
+	f := &inflightForward{urls: make(map[string]bool, len(urls)), chatwoot: toChatwoot}
+	for _, url := range urls {
+		f.urls[url] = true
+	}
+	return f
+}
+
+// settle marks the post to url finished. It returns false when the shutdown already queued the
+// post in the outbox.
+func (f *inflightForward) settle(url string) bool {
+	if f == nil {
+		return true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.urls, url)
+	return !f.persisted
+}
+
+// chatwootDone marks the Chatwoot forward finished. It returns false when the shutdown already
+// queued the forward in the outbox.
+func (f *inflightForward) chatwootDone() bool {
+	if f == nil {
+		return true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chatwoot = false
+	return !f.persisted
+}
+
+func (f *inflightForward) done() {
+	f.work.done()
+}
+
+// persist queues the posts and the Chatwoot forward that have not finished in the webhook outbox,
+// for cause. An unfinished Chatwoot forward is audited as failed too.
+func (f *inflightForward) persist(ctx context.Context, payload map[string]any, eventName string, cause error) {
+	f.mu.Lock()
+	f.persisted = true
+	urls := make([]string, 0, len(f.urls))
+	for url := range f.urls {
+		urls = append(urls, url)
+	}
+	toChatwoot := f.chatwoot
+	f.mu.Unlock()
+
+	sort.Strings(urls)
+	for _, url := range urls {
+		enqueueFailedWebhook(url, eventName, payload, cause)
+	}
+	if toChatwoot {
+		reason := chatwoot.AuditReasonShutdown
+		if errors.Is(cause, errForwardQueueFull) {
+			reason = chatwoot.AuditReasonQueueFull
+		}
+		detail := cause.Error()
+		if enqueueChatwootForward(eventName, payload, cause) {
+			detail += "; queued for replay"
+		}
+		newChatwootAudit(ctx, payload).record(domainChatStorage.ChatwootAuditFailed, reason, 0, detail)
+	}
+}
+
+type inflightForwardKey struct{}
+
+func contextWithForward(ctx context.Context, f *inflightForward) context.Context {
+	return context.WithValue(ctx, inflightForwardKey{}, f)
+}
+
+func forwardFromContext(ctx context.Context) *inflightForward {
+	f, _ := ctx.Value(inflightForwardKey{}).(*inflightForward)
+	return f
+}
+
+// persistUnstartedMessage saves a message forward that was still queued behind the earlier
+// messages of its chat, or whose payload was still being built.
+func persistUnstartedMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, cause error) {
+	webhookEvent, err := createWebhookEvent(ctx, client, evt)
+	if err != nil {
+		logrus.Warnf("Shutdown: Dropping the forward of message %s, its payload could not be built: %v", evt.Info.ID, err)
+		return
+	}
+	payload := map[string]any{
+		"event":     webhookEvent.Event,
+		"device_id": webhookEvent.DeviceID,
+		"payload":   webhookEvent.Payload,
+	}
+	newInflightForward(subscribedWebhookURLs(webhookEvent.Event), forwardsToChatwoot(webhookEvent.Event)).
+		persist(ctx, payload, webhookEvent.Event, cause)
+}
@@ -0,0 +1,142 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDrainInflight_PersistsUnfinishedForwards(t *testing.T) {
+	repo := newMemoryOutboxRepo()
+	withOutboxRepo(t, repo, 3)
+
+	originalWebhooks := config.WhatsappWebhook
+	config.WhatsappWebhook = []string{"https://hook"}
+	defer func() { config.WhatsappWebhook = originalWebhooks }()
+
+	// Even forwards hang until released, then fail; odd ones are delivered at once
+	const forwards = 6
+	hung, release := make(chan struct{}, forwards), make(chan struct{})
+	var delivered sync.WaitGroup
+	delivered.Add(forwards / 2)
+	originalSubmit := submitWebhookFn
+	submitWebhookFn = func(_ context.Context, payload map[string]any, _ string) error {
+		if payload["n"].(int)%2 == 0 {
+			hung <- struct{}{}
+			<-release
+			return errors.New("connection reset")
+		}
+		delivered.Done()
+		return nil
+	}
+	defer func() { submitWebhookFn = originalSubmit }()
+
+	var running sync.WaitGroup
+	for n := 0; n < forwards; n++ {
+		running.Add(1)
+		go func(n int) {
+			defer running.Done()
+			_ = forwardPayloadToConfiguredWebhooks(context.Background(), map[string]any{"n": n}, "message.deleted")
+		}(n)
+	}
+	for i := 0; i < forwards/2; i++ {
+		<-hung
+	}
+	delivered.Wait()
+
+	if saved := DrainInflight(50 * time.Millisecond); saved != forwards/2 {
+		t.Fatalf("expected %d forwards saved, got %d", forwards/2, saved)
+	}
+	if len(repo.entries) != forwards/2 {
+		t.Fatalf("expected %d queued deliveries, got %d", forwards/2, len(repo.entries))
+	}
+	for _, e := range repo.entries {
+		if e.URL != "https://hook" || e.Event != "message.deleted" || e.LastError != errShuttingDown.Error() {
+			t.Errorf("unexpected queued entry: %+v", e)
+		}
+	}
+
+	// A forward failing after it was saved must not be queued twice
+	close(release)
+	running.Wait()
+	if len(repo.entries) != forwards/2 {
+		t.Errorf("expected the saved forwards queued once, got %d entries", len(repo.entries))
+	}
+	if saved := DrainInflight(time.Second); saved != 0 {
+		t.Errorf("expected nothing left to drain, %d saved", saved)
+	}
+}
+
+func TestDrainInflight_WaitsForRunningWork(t *testing.T) {
+	persisted := false
+	work := trackInflight("slow sync", func(context.Context, error) { persisted = true })
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		work.done()
+	}()
+
+	if saved := DrainInflight(time.Second); saved != 0 || persisted {
+		t.Errorf("expected the work drained, saved %d", saved)
+	}
+}
+
+func TestDrainInflight_HandedOverWorkIsNotSavedTwice(t *testing.T) {
+	calls := 0
+	work := trackInflight("message forward", func(context.Context, error) { calls++ })
+	work.handOver()
+	defer work.done()
+
+	if saved := DrainInflight(10 * time.Millisecond); saved != 0 || calls != 0 {
+		t.Errorf("expected the handed over work left to its nested forward, saved %d, calls %d", saved, calls)
+	}
+}
+
+func TestInflightForward_ReplaysChatwootForward(t *testing.T) {
+	repo := newMemoryOutboxRepo()
+	withOutboxRepo(t, repo, 3)
+
+	payload := map[string]any{
+		"event":     "message",
+		"device_id": "628123@s.whatsapp.net",
+		"payload": map[string]any{
+			"id":       "MSG1",
+			"chat_id":  "628999@s.whatsapp.net",
+			"location": &waE2E.LocationMessage{DegreesLatitude: proto.Float64(-6.2)},
+		},
+	}
+	newInflightForward(nil, true).persist(context.Background(), payload, "message", errShuttingDown)
+
+	if len(repo.entries) != 1 {
+		t.Fatalf("expected the Chatwoot forward queued, got %d entries", len(repo.entries))
+	}
+	for _, e := range repo.entries {
+		if e.URL != chatwootOutboxURL || e.Event != "message" || e.LastError != errShuttingDown.Error() {
+			t.Errorf("unexpected queued entry: %+v", e)
+		}
+	}
+
+	var replayed map[string]any
+	originalForward := forwardToChatwootFn
+	forwardToChatwootFn = func(_ context.Context, payload map[string]any) error {
+		replayed = payload
+		return nil
+	}
+	defer func() { forwardToChatwootFn = originalForward }()
+
+	if delivered := dispatchDueWebhooks(context.Background(), time.Now()); delivered != 1 {
+		t.Fatalf("expected the forward replayed, %d delivered", delivered)
+	}
+	data, _ := replayed["payload"].(map[string]any)
+	if loc, ok := data["location"].(*waE2E.LocationMessage); !ok || loc.GetDegreesLatitude() != -6.2 {
+		t.Errorf("expected the location restored, got %#v", data["location"])
+	}
+	if len(repo.entries) != 0 {
+		t.Errorf("expected the replayed entry removed, %d left", len(repo.entries))
+	}
+}
@@ -0,0 +1,104 @@
+package whatsapp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// savedLIDMappings holds the LID mappings already written to chat storage, so messages of one
+// contact do not write the same mapping again.
+var savedLIDMappings = utils.NewTTLCache[string, string](10000, time.Hour)
+
+// lidLinkedDevices holds the devices whose Chatwoot contacts were linked by LID since start.
+var lidLinkedDevices sync.Map
+
+// resolveChatwootIdentity returns the Chatwoot identifier of a private chat partner and their LID,
+// given the JID an event payload carries for them and the LID it was resolved from, if any. A LID
+// still unresolved is tried with the device store, then with the mappings kept in chat storage, and a
+// resolved pair is recorded there. The identifier is the phone number, or the LID JID when the phone
+// number is unknown.
+func resolveChatwootIdentity(ctx context.Context, jid, lid string) (identifier, lidJID string) {
+	repo := lidMappingStorage(ctx)
+	phoneJID := jid
+	if strings.HasSuffix(jid, "@lid") {
+		lid = jid
+		phoneJID = ""
+		if parsed, err := types.ParseJID(jid); err == nil {
+			if pn := NormalizeJIDFromLID(ctx, parsed, ClientFromContext(ctx)); pn.Server == types.DefaultUserServer {
+				phoneJID = pn.ToNonAD().String()
+			}
+		}
+		if phoneJID == "" && repo != nil {
+			stored, err := repo.GetPhoneForLID(lid)
+			if err != nil {
+				logrus.Debugf("Chatwoot: Failed to look up the phone number of %s: %v", lid, err)
+			}
+			phoneJID = stored
+		}
+	}
+	if phoneJID == "" {
+		return lid, lid
+	}
+	if lid != "" {
+		saveLIDMapping(repo, lid, phoneJID)
+	}
+	return utils.ExtractPhoneFromJID(phoneJID), lid
+}
+
+// lidMappingStorage returns the chat storage of the device ctx belongs to.
+func lidMappingStorage(ctx context.Context) domainChatStorage.IChatStorageRepository {
+	inst, ok := DeviceFromContext(ctx)
+	if !ok || inst == nil {
+		return nil
+	}
+	return inst.GetChatStorage()
+}
+
+func saveLIDMapping(repo domainChatStorage.IChatStorageRepository, lid, phoneJID string) {
+	if repo == nil {
+		return
+	}
+	if saved, ok := savedLIDMappings.Get(lid); ok && saved == phoneJID {
+		return
+	}
+	if err := repo.SaveLIDMapping(lid, phoneJID); err != nil {
+		logrus.Debugf("Chatwoot: Failed to store the phone number of %s: %v", lid, err)
+		return
+	}
+	savedLIDMappings.Set(lid, phoneJID)
+}
+
+// linkChatwootLIDContacts links the Chatwoot contacts made separately for the LID and the phone
+// number of one person, once per device after the offline messages were received.
+func linkChatwootLIDContacts(instance *DeviceInstance, client *whatsmeow.Client) {
+	if instance == nil || client == nil || client.Store == nil || client.Store.ID == nil {
+		return
+	}
+	syncService := chatwoot.GetDefaultSyncService()
+	if syncService == nil || !chatwootClientFor(client).IsConfigured() {
+		return
+	}
+	deviceID := client.Store.ID.ToNonAD().String()
+	if _, done := lidLinkedDevices.LoadOrStore(deviceID, struct{}{}); done {
+		return
+	}
+
+	linked, err := syncService.LinkLIDContacts(context.Background(), deviceID, client)
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to link contacts by LID for device %s: %v", deviceID, err)
+		lidLinkedDevices.Delete(deviceID)
+		return
+	}
+	if linked > 0 {
+		logrus.Infof("Chatwoot: Linked the contacts of %d LIDs for device %s", linked, deviceID)
+	}
+}
@@ -0,0 +1,61 @@
+package whatsapp
+
+import (
+	"context"
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+type lidMappingRepo struct {
+	domainChatStorage.IChatStorageRepository
+	phones map[string]string
+	saves  int
+}
+
+func (r *lidMappingRepo) SaveLIDMapping(lid, phoneJID string) error {
+	r.phones[lid] = phoneJID
+	r.saves++
+	return nil
+}
+
+func (r *lidMappingRepo) GetPhoneForLID(lid string) (string, error) {
+	return r.phones[lid], nil
+}
+
+func TestResolveChatwootIdentity(t *testing.T) {
+	origLog := log
+	log = waLog.Noop
+	t.Cleanup(func() { log = origLog })
+	t.Cleanup(func() { savedLIDMappings.Purge() })
+
+	repo := &lidMappingRepo{phones: map[string]string{"777@lid": "5511999990007@s.whatsapp.net"}}
+	ctx := ContextWithDevice(context.Background(), NewDeviceInstance("test-device", nil, repo))
+
+	// A sender already resolved records the pair once
+	for i := 0; i < 2; i++ {
+		identifier, lid := resolveChatwootIdentity(ctx, "5511999990001@s.whatsapp.net", "123@lid")
+		if identifier != "5511999990001" || lid != "123@lid" {
+			t.Fatalf("expected phone number and LID, got %q %q", identifier, lid)
+		}
+	}
+	if repo.phones["123@lid"] != "5511999990001@s.whatsapp.net" || repo.saves != 1 {
+		t.Fatalf("expected the mapping to be stored once, got %v after %d saves", repo.phones, repo.saves)
+	}
+
+	// The device store cannot resolve the LID, chat storage can
+	if identifier, lid := resolveChatwootIdentity(ctx, "777@lid", ""); identifier != "5511999990007" || lid != "777@lid" {
+		t.Fatalf("expected the stored phone number, got %q %q", identifier, lid)
+	}
+
+	// An unknown LID stays the identifier instead of passing for a phone number
+	if identifier, lid := resolveChatwootIdentity(ctx, "999@lid", ""); identifier != "999@lid" || lid != "999@lid" {
+		t.Fatalf("expected the LID as identifier, got %q %q", identifier, lid)
+	}
+
+	// Senders without a LID are unchanged
+	if identifier, lid := resolveChatwootIdentity(ctx, "5511999990002@s.whatsapp.net", ""); identifier != "5511999990002" || lid != "" {
+		t.Fatalf("expected the phone number only, got %q %q", identifier, lid)
+	}
+}
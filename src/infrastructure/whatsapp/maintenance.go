@@ -0,0 +1,283 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	MaintenanceDefaultDuration = 30 * time.Minute
+	MaintenanceMaxDuration     = 24 * time.Hour
+
+	maintenanceDrainBatchSize = 50
+)
+
+// MaintenanceStatus describes maintenance mode for the status endpoints.
+type MaintenanceStatus struct {
+	Active      bool       `json:"active"`
+	Draining    bool       `json:"draining"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	Until       *time.Time `json:"until,omitempty"`
+	Buffered    int64      `json:"buffered"`
+	MaxBuffered int        `json:"max_buffered"`
+}
+
+// maintenanceState is guarded by mu. Buffering and the end of a drain both happen under mu, so an
+// event can never be delivered directly while older events are still waiting in the buffer.
+var maintenanceState = struct {
+	mu        sync.Mutex
+	repo      domainChatStorage.IChatStorageRepository
+	active    bool
+	draining  bool
+	startedAt time.Time
+	until     time.Time
+	timer     *time.Timer
+}{}
+
+// deliverMaintenanceEventFn hands a buffered event to its target; replaced in tests. It returns an
+// error when the event was neither delivered nor queued for a retry, so it must stay buffered.
+var deliverMaintenanceEventFn = deliverMaintenanceEvent
+
+// SetMaintenanceBufferRepository sets the storage that holds events while maintenance mode is active.
+func SetMaintenanceBufferRepository(repo domainChatStorage.IChatStorageRepository) {
+	maintenanceState.mu.Lock()
+	defer maintenanceState.mu.Unlock()
+	maintenanceState.repo = repo
+}
+
+// StartMaintenance holds webhook and Chatwoot deliveries for d, buffering them in storage. Calling it
+// again while active moves the end time. d <= 0 uses MaintenanceDefaultDuration.
+func StartMaintenance(d time.Duration) (MaintenanceStatus, error) {
+	if d <= 0 {
+		d = MaintenanceDefaultDuration
+	}
+	if d > MaintenanceMaxDuration {
+		return MaintenanceStatus{}, fmt.Errorf("duration must not exceed %s", MaintenanceMaxDuration)
+	}
+
+	maintenanceState.mu.Lock()
+	if maintenanceState.repo == nil {
+		maintenanceState.mu.Unlock()
+		return MaintenanceStatus{}, fmt.Errorf("maintenance buffer is not initialized")
+	}
+	now := time.Now()
+	if !maintenanceState.active {
+		maintenanceState.active = true
+		maintenanceState.startedAt = now
+	}
+	maintenanceState.until = now.Add(d)
+	if maintenanceState.timer != nil {
+		maintenanceState.timer.Stop()
+	}
+	maintenanceState.timer = time.AfterFunc(d, func() {
+		StopMaintenance("time box elapsed")
+	})
+	maintenanceState.mu.Unlock()
+
+	logrus.Warnf("Maintenance: started, holding webhook and Chatwoot deliveries until %s", now.Add(d).Format(time.RFC3339))
+	return GetMaintenanceStatus(), nil
+}
+
+// StopMaintenance ends maintenance mode and drains the buffer in order. It reports whether
+// maintenance was active.
+func StopMaintenance(reason string) bool {
+	maintenanceState.mu.Lock()
+	if !maintenanceState.active {
+		maintenanceState.mu.Unlock()
+		return false
+	}
+	maintenanceState.active = false
+	if maintenanceState.timer != nil {
+		maintenanceState.timer.Stop()
+		maintenanceState.timer = nil
+	}
+	startDrain := !maintenanceState.draining
+	maintenanceState.draining = true
+	maintenanceState.mu.Unlock()
+
+	logrus.Warnf("Maintenance: stopped (%s), draining buffered events", reason)
+	if startDrain {
+		go drainMaintenanceBuffer()
+	}
+	return true
+}
+
+// ResumeMaintenanceDrain delivers events left in the buffer by a previous run.
+func ResumeMaintenanceDrain() {
+	maintenanceState.mu.Lock()
+	repo := maintenanceState.repo
+	if repo == nil || maintenanceState.active || maintenanceState.draining {
+		maintenanceState.mu.Unlock()
+		return
+	}
+	count, err := repo.CountMaintenanceEvents()
+	if err != nil || count == 0 {
+		maintenanceState.mu.Unlock()
+		if err != nil {
+			logrus.Errorf("Maintenance: failed to count buffered events: %v", err)
+		}
+		return
+	}
+	maintenanceState.draining = true
+	maintenanceState.mu.Unlock()
+
+	logrus.Infof("Maintenance: delivering %d event(s) buffered before the last shutdown", count)
+	go drainMaintenanceBuffer()
+}
+
+// GetMaintenanceStatus returns the current maintenance state and buffer size.
+func GetMaintenanceStatus() MaintenanceStatus {
+	maintenanceState.mu.Lock()
+	status := MaintenanceStatus{
+		Active:      maintenanceState.active,
+		Draining:    maintenanceState.draining,
+		MaxBuffered: config.AppMaintenanceMaxBuffered,
+	}
+	if maintenanceState.active {
+		startedAt, until := maintenanceState.startedAt, maintenanceState.until
+		status.StartedAt = &startedAt
+		status.Until = &until
+	}
+	repo := maintenanceState.repo
+	maintenanceState.mu.Unlock()
+
+	if repo != nil {
+		if count, err := repo.CountMaintenanceEvents(); err == nil {
+			status.Buffered = count
+		}
+	}
+	return status
+}
+
+// IsMaintenanceActive reports whether deliveries are currently being held.
+func IsMaintenanceActive() bool {
+	maintenanceState.mu.Lock()
+	defer maintenanceState.mu.Unlock()
+	return maintenanceState.active
+}
+
+// bufferDuringMaintenance stores the event for each target while maintenance is active or its
+// buffer is still draining. It returns the targets the event was buffered for; the caller delivers
+// it to the others now. Nothing is buffered outside maintenance.
+func bufferDuringMaintenance(payload map[string]any, eventName string, targets []string) []string {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	maintenanceState.mu.Lock()
+	if !maintenanceState.active && !maintenanceState.draining {
+		maintenanceState.mu.Unlock()
+		return nil
+	}
+	repo := maintenanceState.repo
+
+	body, err := encodeForwardPayload(payload)
+	if err != nil {
+		maintenanceState.mu.Unlock()
+		logrus.Errorf("Maintenance: failed to encode %s, delivering it now: %v", eventName, err)
+		return nil
+	}
+	var buffered []string
+	for _, target := range targets {
+		entry := &domainChatStorage.MaintenanceBufferEntry{Target: target, Event: eventName, Payload: string(body)}
+		if err := repo.EnqueueMaintenanceEvent(entry); err != nil {
+			logrus.Errorf("Maintenance: failed to buffer %s for %s, delivering it now: %v", eventName, target, err)
+			continue
+		}
+		buffered = append(buffered, target)
+	}
+	maintenanceState.mu.Unlock()
+
+	if max := config.AppMaintenanceMaxBuffered; max > 0 && len(buffered) > 0 {
+		if count, err := repo.CountMaintenanceEvents(); err == nil && count >= int64(max) {
+			StopMaintenance(fmt.Sprintf("buffer reached %d events", max))
+		}
+	}
+	return buffered
+}
+
+// drainMaintenanceBuffer delivers buffered events oldest first, pausing between events. It stops
+// early when maintenance is started again, or when an event can be neither delivered nor queued in
+// the outbox; that event and the ones behind it stay buffered for the next drain.
+func drainMaintenanceBuffer() {
+	interval := time.Duration(config.AppMaintenanceDrainIntervalMs) * time.Millisecond
+	delivered := 0
+
+	for {
+		maintenanceState.mu.Lock()
+		repo := maintenanceState.repo
+		if maintenanceState.active || repo == nil {
+			maintenanceState.draining = false
+			maintenanceState.mu.Unlock()
+			logrus.Infof("Maintenance: drain paused after %d event(s)", delivered)
+			return
+		}
+		entries, err := repo.ListMaintenanceEvents(maintenanceDrainBatchSize)
+		if err != nil || len(entries) == 0 {
+			maintenanceState.draining = false
+			maintenanceState.mu.Unlock()
+			if err != nil {
+				logrus.Errorf("Maintenance: failed to load buffered events, drain stopped: %v", err)
+			} else {
+				logrus.Infof("Maintenance: drain finished, %d event(s) delivered", delivered)
+			}
+			return
+		}
+		maintenanceState.mu.Unlock()
+
+		for _, entry := range entries {
+			if IsMaintenanceActive() {
+				break
+			}
+			if err := deliverMaintenanceEventFn(entry); err != nil {
+				maintenanceState.mu.Lock()
+				maintenanceState.draining = false
+				maintenanceState.mu.Unlock()
+				logrus.Errorf("Maintenance: drain stopped after %d event(s), keeping entry %d (%s) buffered: %v", delivered, entry.ID, entry.Event, err)
+				return
+			}
+			if err := repo.DeleteMaintenanceEvent(entry.ID); err != nil {
+				logrus.Errorf("Maintenance: delivered entry %d but failed to remove it: %v", entry.ID, err)
+			}
+			delivered++
+			if interval > 0 {
+				time.Sleep(interval)
+			}
+		}
+	}
+}
+
+// deliverMaintenanceEvent delivers a buffered event. Failed webhook posts fall back to the outbox
+// in forwardToWebhooks; a Chatwoot forward that fails is queued there too.
+func deliverMaintenanceEvent(entry *domainChatStorage.MaintenanceBufferEntry) error {
+	payload, err := decodeForwardPayload([]byte(entry.Payload))
+	if err != nil {
+		logrus.Errorf("Maintenance: dropping unreadable entry %d (%s): %v", entry.ID, entry.Event, err)
+		return nil
+	}
+
+	ctx := forwardPayloadContext(payload)
+	switch entry.Target {
+	case domainChatStorage.MaintenanceTargetWebhook:
+		if err := forwardToWebhooks(ctx, payload, entry.Event); err != nil {
+			logrus.Warnf("Maintenance: buffered %s entry %d: %v", entry.Event, entry.ID, err)
+		}
+	case domainChatStorage.MaintenanceTargetChatwoot:
+		err := forwardToChatwoot(ctx, payload)
+		if err == nil || errors.Is(err, errChatwootNotConfigured) {
+			return nil
+		}
+		if !enqueueChatwootForward(entry.Event, payload, err) {
+			return err
+		}
+	default:
+		logrus.Warnf("Maintenance: dropping entry %d with unknown target %q", entry.ID, entry.Target)
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// memoryMaintenanceRepo implements only the maintenance buffer part of the storage interface.
+type memoryMaintenanceRepo struct {
+	domainChatStorage.IChatStorageRepository
+	mu      sync.Mutex
+	entries []*domainChatStorage.MaintenanceBufferEntry
+	nextID  int64
+	failFor string // Target whose entries are refused
+}
+
+func (m *memoryMaintenanceRepo) EnqueueMaintenanceEvent(entry *domainChatStorage.MaintenanceBufferEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry.Target == m.failFor {
+		return errors.New("disk full")
+	}
+	m.nextID++
+	entry.ID = m.nextID
+	copied := *entry
+	m.entries = append(m.entries, &copied)
+	return nil
+}
+
+func (m *memoryMaintenanceRepo) ListMaintenanceEvents(limit int) ([]*domainChatStorage.MaintenanceBufferEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.entries) < limit {
+		limit = len(m.entries)
+	}
+	return append([]*domainChatStorage.MaintenanceBufferEntry(nil), m.entries[:limit]...), nil
+}
+
+func (m *memoryMaintenanceRepo) DeleteMaintenanceEvent(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, e := range m.entries {
+		if e.ID == id {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *memoryMaintenanceRepo) CountMaintenanceEvents() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.entries)), nil
+}
+
+// withMaintenance installs repo, records delivered entries and restores global state afterwards.
+func withMaintenance(t *testing.T, repo *memoryMaintenanceRepo, maxBuffered int) *[]string {
+	t.Helper()
+	originalMax, originalInterval := config.AppMaintenanceMaxBuffered, config.AppMaintenanceDrainIntervalMs
+	originalDeliver := deliverMaintenanceEventFn
+	config.AppMaintenanceMaxBuffered = maxBuffered
+	config.AppMaintenanceDrainIntervalMs = 0
+	SetMaintenanceBufferRepository(repo)
+
+	var (
+		mu        sync.Mutex
+		delivered []string
+	)
+	deliverMaintenanceEventFn = func(entry *domainChatStorage.MaintenanceBufferEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, entry.Target+":"+entry.Payload)
+		return nil
+	}
+
+	t.Cleanup(func() {
+		StopMaintenance("test cleanup")
+		waitForDrain(t)
+		config.AppMaintenanceMaxBuffered, config.AppMaintenanceDrainIntervalMs = originalMax, originalInterval
+		deliverMaintenanceEventFn = originalDeliver
+		SetMaintenanceBufferRepository(nil)
+	})
+	return &delivered
+}
+
+func waitForDrain(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for GetMaintenanceStatus().Draining {
+		if time.Now().After(deadline) {
+			t.Fatal("maintenance buffer did not finish draining")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMaintenance_BuffersAndDrainsInOrder(t *testing.T) {
+	repo := &memoryMaintenanceRepo{}
+	delivered := withMaintenance(t, repo, 0)
+
+	originalWebhooks, originalChatwoot := config.WhatsappWebhook, config.ChatwootEnabled
+	config.WhatsappWebhook = []string{"https://hook"}
+	config.ChatwootEnabled = true
+	defer func() { config.WhatsappWebhook, config.ChatwootEnabled = originalWebhooks, originalChatwoot }()
+
+	originalSubmit := submitWebhookFn
+	submitWebhookFn = func(context.Context, map[string]any, string) error {
+		t.Fatal("webhook must not be called during maintenance")
+		return nil
+	}
+	defer func() { submitWebhookFn = originalSubmit }()
+
+	if _, err := StartMaintenance(time.Minute); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	_ = forwardPayloadToConfiguredWebhooks(context.Background(), map[string]any{"n": 1}, "message")
+	_ = forwardPayloadToConfiguredWebhooks(context.Background(), map[string]any{"n": 2}, "group.participants")
+
+	if status := GetMaintenanceStatus(); !status.Active || status.Buffered != 3 {
+		t.Fatalf("expected 3 buffered events while active, got %+v", status)
+	}
+
+	if !StopMaintenance("test") {
+		t.Fatal("expected maintenance to be active")
+	}
+	waitForDrain(t)
+
+	want := []string{`webhook:{"n":1}`, `chatwoot:{"n":1}`, `webhook:{"n":2}`}
+	if len(*delivered) != len(want) {
+		t.Fatalf("expected %v, got %v", want, *delivered)
+	}
+	for i := range want {
+		if (*delivered)[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, *delivered)
+		}
+	}
+	if count, _ := repo.CountMaintenanceEvents(); count != 0 {
+		t.Fatalf("expected an empty buffer after draining, %d left", count)
+	}
+}
+
+func TestMaintenance_StopsWhenBufferIsFull(t *testing.T) {
+	repo := &memoryMaintenanceRepo{}
+	delivered := withMaintenance(t, repo, 2)
+
+	if _, err := StartMaintenance(time.Minute); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	webhook := []string{domainChatStorage.MaintenanceTargetWebhook}
+	for i := 0; i < 2; i++ {
+		if len(bufferDuringMaintenance(map[string]any{"n": i}, "message", webhook)) != 1 {
+			t.Fatalf("event %d should have been buffered", i)
+		}
+	}
+
+	if IsMaintenanceActive() {
+		t.Fatal("expected maintenance to stop once the buffer cap was reached")
+	}
+	waitForDrain(t)
+	if len(*delivered) != 2 {
+		t.Fatalf("expected both buffered events to be delivered, got %v", *delivered)
+	}
+}
+
+func TestMaintenance_PartialBufferKeepsBufferedTargets(t *testing.T) {
+	repo := &memoryMaintenanceRepo{failFor: domainChatStorage.MaintenanceTargetChatwoot}
+	withMaintenance(t, repo, 0)
+
+	if _, err := StartMaintenance(time.Minute); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	targets := []string{domainChatStorage.MaintenanceTargetWebhook, domainChatStorage.MaintenanceTargetChatwoot}
+	buffered := bufferDuringMaintenance(map[string]any{"n": 1}, "message", targets)
+	if !slices.Equal(buffered, []string{domainChatStorage.MaintenanceTargetWebhook}) {
+		t.Fatalf("expected only the webhook delivery to be buffered, got %v", buffered)
+	}
+	if count, _ := repo.CountMaintenanceEvents(); count != 1 {
+		t.Fatalf("expected one buffered entry, got %d", count)
+	}
+}
+
+// Locations, contacts and polls are forwarded from their protobuf messages, which must survive the buffer.
+func TestMaintenance_ReplaysStructuredPayload(t *testing.T) {
+	repo := &memoryMaintenanceRepo{}
+	withMaintenance(t, repo, 0)
+	var replayed []map[string]any
+	deliverMaintenanceEventFn = func(entry *domainChatStorage.MaintenanceBufferEntry) error {
+		payload, err := decodeForwardPayload([]byte(entry.Payload))
+		if err != nil {
+			t.Errorf("buffered entry %d is unreadable: %v", entry.ID, err)
+			return nil
+		}
+		replayed = append(replayed, payload)
+		return nil
+	}
+
+	location := &waE2E.LocationMessage{DegreesLatitude: proto.Float64(-6.2), DegreesLongitude: proto.Float64(106.8), Name: proto.String("Monas")}
+	poll := &waE2E.PollCreationMessage{Name: proto.String("Lunch?"), Options: []*waE2E.PollCreationMessage_Option{{OptionName: proto.String("Yes")}, {OptionName: proto.String("No")}}}
+	contact := &waE2E.ContactMessage{DisplayName: proto.String("Ana"), Vcard: proto.String("BEGIN:VCARD\nFN:Ana\nTEL:+551199999999\nEND:VCARD")}
+	events := []map[string]any{
+		{"location": location},
+		{"poll": poll},
+		{"contact": contact},
+	}
+
+	if _, err := StartMaintenance(time.Minute); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	for _, data := range events {
+		payload := map[string]any{"event": "message", "device_id": "dev", "payload": data}
+		if len(bufferDuringMaintenance(payload, "message", []string{domainChatStorage.MaintenanceTargetChatwoot})) != 1 {
+			t.Fatal("expected the event to be buffered")
+		}
+	}
+	StopMaintenance("test")
+	waitForDrain(t)
+
+	if len(replayed) != len(events) {
+		t.Fatalf("expected %d replayed events, got %d", len(events), len(replayed))
+	}
+	for i, data := range events {
+		got, ok := replayed[i]["payload"].(map[string]any)
+		if !ok {
+			t.Fatalf("replayed event %d has no payload: %v", i, replayed[i])
+		}
+		if want := extractStructuredMessageContent(data); extractStructuredMessageContent(got) != want {
+			t.Fatalf("replayed event %d renders %q, want %q", i, extractStructuredMessageContent(got), want)
+		}
+	}
+	if _, ok := replayed[0]["payload"].(map[string]any)["location"].(*waE2E.LocationMessage); !ok {
+		t.Fatal("expected the location to be replayed as a LocationMessage")
+	}
+	if _, ok := replayed[0][forwardPayloadProtosKey]; ok {
+		t.Fatal("expected the stored protobuf messages to be removed from the replayed payload")
+	}
+}
+
+func TestMaintenance_FailedChatwootDeliveryIsKept(t *testing.T) {
+	newFailingChatwoot(t)
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+	const chat = "628111000030@s.whatsapp.net"
+	bufferMessage := func(id string) {
+		t.Helper()
+		if _, err := StartMaintenance(time.Minute); err != nil {
+			t.Fatalf("start failed: %v", err)
+		}
+		payload := map[string]any{"event": "message", "payload": map[string]any{
+			"id": id, "body": "hello", "from": chat, "from_name": "Customer", "chat_id": chat,
+		}}
+		if len(bufferDuringMaintenance(payload, "message", []string{domainChatStorage.MaintenanceTargetChatwoot})) != 1 {
+			t.Fatal("expected the event to be buffered")
+		}
+		StopMaintenance("test")
+		waitForDrain(t)
+	}
+
+	t.Run("QueuedInTheOutbox", func(t *testing.T) {
+		repo := &memoryMaintenanceRepo{}
+		withMaintenance(t, repo, 0)
+		deliverMaintenanceEventFn = deliverMaintenanceEvent
+		outbox := newMemoryOutboxRepo()
+		withOutboxRepo(t, outbox, 3)
+
+		bufferMessage("MAINT-1")
+		if count, _ := repo.CountMaintenanceEvents(); count != 0 {
+			t.Fatalf("expected the buffer drained, %d left", count)
+		}
+		if len(outbox.entries) != 1 {
+			t.Fatalf("expected the failed Chatwoot forward queued, got %d entries", len(outbox.entries))
+		}
+		for _, e := range outbox.entries {
+			if e.URL != chatwootOutboxURL || !strings.Contains(e.Payload, "MAINT-1") {
+				t.Errorf("unexpected queued entry: %+v", e)
+			}
+		}
+	})
+
+	t.Run("KeptWithoutAnOutbox", func(t *testing.T) {
+		repo := &memoryMaintenanceRepo{}
+		withMaintenance(t, repo, 0)
+		deliverMaintenanceEventFn = deliverMaintenanceEvent
+		withOutboxRepo(t, newMemoryOutboxRepo(), 0)
+
+		bufferMessage("MAINT-2")
+		if count, _ := repo.CountMaintenanceEvents(); count != 1 {
+			t.Fatalf("expected the undelivered event kept in the buffer, %d left", count)
+		}
+	})
+}
+
+func TestMaintenance_TimeBoxExpires(t *testing.T) {
+	withMaintenance(t, &memoryMaintenanceRepo{}, 0)
+
+	if _, err := StartMaintenance(20 * time.Millisecond); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for IsMaintenanceActive() {
+		if time.Now().After(deadline) {
+			t.Fatal("maintenance did not end after its duration")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMaintenance_RequiresRepository(t *testing.T) {
+	if _, err := StartMaintenance(time.Minute); err == nil {
+		t.Fatal("expected an error without a buffer repository")
+	}
+	if _, err := StartMaintenance(25 * time.Hour); err == nil {
+		t.Fatal("expected an error for a duration above the maximum")
+	}
+}
@@ -0,0 +1,87 @@
+package whatsapp
+
+import (
+	"context"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const newsletterNameCacheTTL = 30 * time.Minute
+
+// newsletterInfoFetcher is the part of the WhatsApp client used to look up channel names.
+type newsletterInfoFetcher interface {
+	GetNewsletterInfo(ctx context.Context, jid types.JID) (*types.NewsletterMetadata, error)
+}
+
+// newsletterNames caches the names of WhatsApp Channels. Failed lookups are not cached, so the next
+// message of the channel tries again.
+var newsletterNames = utils.NewTTLCache[string, string](config.AppGroupNameCacheSize, newsletterNameCacheTTL)
+
+// skipsNewsletter reports whether messages of chat are dropped because it is a channel and
+// WHATSAPP_SKIP_NEWSLETTERS is set.
+func skipsNewsletter(chat types.JID) bool {
+	return config.WhatsappSkipNewsletters && chat.Server == types.NewsletterServer
+}
+
+// CachedNewsletterName returns the cached name of a channel, without asking WhatsApp.
+func CachedNewsletterName(newsletterJID string) (string, bool) {
+	return newsletterNames.Get(newsletterJID)
+}
+
+// setNewsletterName records the name of a channel and writes it to the stored chat, like group
+// subjects, so chat listings show it instead of a placeholder.
+func setNewsletterName(newsletterJID, name string) {
+	if name == "" {
+		return
+	}
+	newsletterNames.Set(newsletterJID, name)
+
+	repo := GetGroupInfoService().repository()
+	if repo == nil {
+		return
+	}
+	if err := repo.UpdateChatName(newsletterJID, name); err != nil {
+		logrus.Warnf("Newsletter info: failed to store name of %s: %v", newsletterJID, err)
+	}
+}
+
+// newsletterName returns the name of a channel, asking WhatsApp on a cache miss. It returns "" when
+// the name is unknown and the lookup fails.
+func newsletterName(fetcher newsletterInfoFetcher, newsletterJID string) string {
+	if name, ok := newsletterNames.Get(newsletterJID); ok {
+		return name
+	}
+	jid, err := types.ParseJID(newsletterJID)
+	if err != nil {
+		logrus.Warnf("Newsletter info: failed to parse newsletter JID %s: %v", newsletterJID, err)
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	info, err := fetcher.GetNewsletterInfo(ctx, jid)
+	if err != nil {
+		logrus.Warnf("Newsletter info: failed to get newsletter info for %s: %v", newsletterJID, err)
+		return ""
+	}
+	if info == nil || info.ThreadMeta.Name.Text == "" {
+		return ""
+	}
+	setNewsletterName(newsletterJID, info.ThreadMeta.Name.Text)
+	return info.ThreadMeta.Name.Text
+}
+
+// ResolveNewsletterName returns the name of a channel, asking WhatsApp through client when it is not
+// cached. It matches the resolver signature expected by chatwoot.SetNewsletterNameResolver.
+func ResolveNewsletterName(client *whatsmeow.Client, newsletterJID string) string {
+	if client == nil {
+		name, _ := CachedNewsletterName(newsletterJID)
+		return name
+	}
+	return newsletterName(client, newsletterJID)
+}
@@ -0,0 +1,109 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+type fakeNewsletterFetcher struct {
+	calls int
+	err   error
+}
+
+func (f *fakeNewsletterFetcher) GetNewsletterInfo(_ context.Context, jid types.JID) (*types.NewsletterMetadata, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	meta := &types.NewsletterMetadata{ID: jid}
+	meta.ThreadMeta.Name.Text = "Tech News"
+	return meta, nil
+}
+
+func TestNewsletterName_CachesOnlyKnownNames(t *testing.T) {
+	const channel = "120363144038483540@newsletter"
+	t.Cleanup(func() { newsletterNames.Delete(channel) })
+
+	failing := &fakeNewsletterFetcher{err: errors.New("timeout")}
+	if name := newsletterName(failing, channel); name != "" {
+		t.Fatalf("expected no name when the lookup fails, got %q", name)
+	}
+
+	fetcher := &fakeNewsletterFetcher{}
+	for i := 0; i < 2; i++ {
+		if name := newsletterName(fetcher, channel); name != "Tech News" {
+			t.Fatalf("expected the channel name, got %q", name)
+		}
+	}
+	if fetcher.calls != 1 {
+		t.Errorf("expected one lookup after the failed one, got %d", fetcher.calls)
+	}
+	if name, ok := CachedNewsletterName(channel); !ok || name != "Tech News" {
+		t.Errorf("expected the name cached, got %q, %v", name, ok)
+	}
+}
+
+func TestForwardToChatwoot_ChannelGetsIdentifierContact(t *testing.T) {
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+	const channel = "120363144038483541@newsletter"
+	newsletterNames.Set(channel, "Tech News")
+	t.Cleanup(func() { newsletterNames.Delete(channel) })
+
+	fake := newFakeChatwoot(t)
+	ctx := ContextWithDevice(context.Background(), NewDeviceInstance("test-device", nil, nil))
+	for _, id := range []string{"CH1", "CH2"} {
+		data := map[string]any{"id": id, "body": "release notes " + id, "from": channel, "chat_id": channel}
+		forwardToChatwoot(ctx, map[string]any{"event": "message", "payload": data})
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.contacts) != 1 {
+		t.Fatalf("expected one contact for the channel, got %+v", fake.contacts)
+	}
+	contact := fake.contacts[0]
+	if contact.Identifier != channel || contact.PhoneNumber != "" || contact.Name != "Tech News" {
+		t.Errorf("expected a contact found by the channel JID, got %+v", contact)
+	}
+	for _, msgs := range fake.messages {
+		if len(msgs) != 2 || msgs[0] != "release notes CH1" {
+			t.Errorf("expected both posts in the channel conversation, got %q", msgs)
+		}
+	}
+}
+
+type createMessageRecorder struct {
+	domainChatStorage.IChatStorageRepository
+	stored int
+}
+
+func (r *createMessageRecorder) CreateMessage(context.Context, *events.Message) error {
+	r.stored++
+	return nil
+}
+
+func TestHandleMessage_SkipsChannelsWhenConfigured(t *testing.T) {
+	prev := config.WhatsappSkipNewsletters
+	config.WhatsappSkipNewsletters = true
+	t.Cleanup(func() { config.WhatsappSkipNewsletters = prev })
+	origLog := log
+	log = waLog.Noop
+	t.Cleanup(func() { log = origLog })
+
+	channel := types.NewJID("120363144038483542", types.NewsletterServer)
+	repo := &createMessageRecorder{}
+	evt := &events.Message{Info: types.MessageInfo{MessageSource: types.MessageSource{Chat: channel, Sender: channel}, ID: "CH3"}}
+	handleMessage(context.Background(), evt, repo, nil)
+
+	if repo.stored != 0 {
+		t.Fatalf("expected the channel message not stored, stored %d", repo.stored)
+	}
+}
@@ -0,0 +1,167 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// pollCreationMessage returns the poll in msg, whichever message version WhatsApp used for it.
+func pollCreationMessage(msg *waE2E.Message) *waE2E.PollCreationMessage {
+	for _, poll := range []*waE2E.PollCreationMessage{
+		msg.GetPollCreationMessage(),
+		msg.GetPollCreationMessageV2(),
+		msg.GetPollCreationMessageV3(),
+		msg.GetPollCreationMessageV5(),
+	} {
+		if poll != nil {
+			return poll
+		}
+	}
+	return nil
+}
+
+func pollOptionNames(poll *waE2E.PollCreationMessage) []string {
+	options := make([]string, 0, len(poll.GetOptions()))
+	for _, option := range poll.GetOptions() {
+		options = append(options, option.GetOptionName())
+	}
+	return options
+}
+
+// resolvePollOptions maps the SHA-256 option hashes of a vote back to option names, in poll order.
+// Hashes matching no option are dropped.
+func resolvePollOptions(options []string, selected [][]byte) []string {
+	var names []string
+	for i, hash := range whatsmeow.HashPollOptions(options) {
+		for _, s := range selected {
+			if bytes.Equal(hash, s) {
+				names = append(names, options[i])
+				break
+			}
+		}
+	}
+	return names
+}
+
+// handlePollMessage stores new polls and the votes cast on them. Each vote is posted to Chatwoot
+// together with the updated tally.
+func handlePollMessage(ctx context.Context, evt *events.Message, chatStorageRepo domainChatStorage.IChatStorageRepository, client *whatsmeow.Client) {
+	msg := utils.UnwrapMessage(evt.Message)
+
+	if poll := pollCreationMessage(msg); poll != nil {
+		err := chatStorageRepo.SavePoll(&domainChatStorage.Poll{
+			MessageID:       evt.Info.ID,
+			ChatJID:         evt.Info.Chat.ToNonAD().String(),
+			Question:        poll.GetName(),
+			Options:         pollOptionNames(poll),
+			SelectableCount: int(poll.GetSelectableOptionsCount()),
+			CreatedAt:       evt.Info.Timestamp,
+		})
+		if err != nil {
+			log.Errorf("Failed to store poll %s: %v", evt.Info.ID, err)
+		}
+		return
+	}
+
+	pollUpdate := msg.GetPollUpdateMessage()
+	if pollUpdate == nil || client == nil {
+		return
+	}
+	pollID := pollUpdate.GetPollCreationMessageKey().GetID()
+
+	results, err := chatStorageRepo.GetPollResults(pollID)
+	if err != nil {
+		log.Errorf("Failed to load poll %s: %v", pollID, err)
+		return
+	}
+	if results == nil {
+		log.Debugf("Ignoring vote %s on unknown poll %s", evt.Info.ID, pollID)
+		return
+	}
+
+	vote, err := client.DecryptPollVote(ctx, evt)
+	if err != nil {
+		log.Warnf("Failed to decrypt vote %s on poll %s: %v", evt.Info.ID, pollID, err)
+		return
+	}
+	selected := resolvePollOptions(results.Poll.Options, vote.GetSelectedOptions())
+
+	voterJID := NormalizeJIDFromLID(ctx, evt.Info.Sender.ToNonAD(), client).ToNonAD().String()
+	err = chatStorageRepo.SavePollVote(&domainChatStorage.PollVote{
+		PollMessageID: pollID,
+		VoterJID:      voterJID,
+		Options:       selected,
+		VotedAt:       evt.Info.Timestamp,
+	})
+	if err != nil {
+		log.Errorf("Failed to store vote %s on poll %s: %v", evt.Info.ID, pollID, err)
+		return
+	}
+
+	if !config.ChatwootEnabled || strings.Contains(evt.Info.SourceString(), "broadcast") {
+		return
+	}
+	if results, err = chatStorageRepo.GetPollResults(pollID); err != nil || results == nil {
+		log.Errorf("Failed to tally poll %s: %v", pollID, err)
+		return
+	}
+	go func() {
+		syncCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		forwardPollVoteToChatwoot(syncCtx, client, evt, results, selected)
+	}()
+}
+
+// forwardPollVoteToChatwoot posts a vote and the current tally to the poll's conversation.
+func forwardPollVoteToChatwoot(ctx context.Context, client *whatsmeow.Client, evt *events.Message, results *domainChatStorage.PollResults, selected []string) {
+	cw := chatwootClientFor(client)
+	if !cw.IsConfigured() {
+		return
+	}
+	// The vote event itself is also seen, and skipped, by forwardToChatwoot under its plain ID.
+	if isDuplicateChatwootForward("poll-vote:" + evt.Info.ID) {
+		return
+	}
+
+	data := map[string]any{
+		"id":         evt.Info.ID,
+		"is_from_me": evt.Info.IsFromMe,
+	}
+	if evt.Info.PushName != "" {
+		data["from_name"] = evt.Info.PushName
+	}
+	buildFromFields(ctx, client, evt, data)
+
+	info, err := extractChatwootContactInfo(ctx, data)
+	if err != nil {
+		logrus.Warnf("Chatwoot: Skipping poll vote: %v", err)
+		return
+	}
+	if client.Store != nil && client.Store.ID != nil {
+		deviceJID := NormalizeJIDFromLID(ctx, client.Store.ID.ToNonAD(), client)
+		info.DeviceAlias = chatwootSharedInboxDeviceAlias(deviceJID.ToNonAD().String())
+	}
+
+	voter := info.FromName
+	if voter == "" {
+		voter = utils.ExtractPhoneFromJID(data["from"].(string))
+	}
+	content := chatwoot.FormatPollTally(results, voter, selected)
+
+	// The audit trail has the vote under its own ID, recorded by forwardToChatwoot.
+	if _, err := syncMessageToChatwoot(cw, info, content, nil, chatwoot.ForwardedMessageKey("poll-vote:"+evt.Info.ID), chatwootAudit{}); err != nil {
+		logrus.Errorf("Chatwoot: Failed to post poll vote: %v", err)
+		chatwoot.RecordBridgeError(chatwoot.BridgeToChatwoot, err)
+	}
+}
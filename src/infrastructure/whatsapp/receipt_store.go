@@ -0,0 +1,124 @@
+package whatsapp
+
+import (
+	"sync"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Receipts arrive in bursts, one per recipient and often for many messages, so they are merged in
+// memory and written in one transaction per flush.
+const (
+	receiptFlushInterval = time.Second
+	receiptFlushSize     = 500
+)
+
+type receiptKey struct {
+	deviceID, messageID, participant string
+}
+
+var receiptStore = struct {
+	mu      sync.Mutex
+	repo    domainChatStorage.IChatStorageRepository
+	pending map[receiptKey]*domainChatStorage.MessageReceipt
+	flush   chan struct{}
+	once    sync.Once
+}{
+	pending: make(map[receiptKey]*domainChatStorage.MessageReceipt),
+	flush:   make(chan struct{}, 1),
+}
+
+// SetReceiptRepository sets the storage receipts of sent messages are recorded in.
+func SetReceiptRepository(repo domainChatStorage.IChatStorageRepository) {
+	receiptStore.mu.Lock()
+	defer receiptStore.mu.Unlock()
+	receiptStore.repo = repo
+}
+
+// queueMessageReceipts records the delivery or read of our messages reported by evt. Receipts of
+// other kinds, and those our own devices send, are ignored.
+func queueMessageReceipts(evt *events.Receipt, deviceID string) {
+	var delivered, read bool
+	switch evt.Type {
+	case types.ReceiptTypeDelivered:
+		delivered = true
+	case types.ReceiptTypeRead, types.ReceiptTypePlayed:
+		read = true
+	default:
+		return
+	}
+	if evt.IsFromMe || deviceID == "" || len(evt.MessageIDs) == 0 {
+		return
+	}
+
+	receiptStore.mu.Lock()
+	if receiptStore.repo == nil {
+		receiptStore.mu.Unlock()
+		return
+	}
+	receiptStore.once.Do(func() { go runReceiptFlusher() })
+
+	chatJID := evt.Chat.ToNonAD().String()
+	participant := evt.Sender.ToNonAD().String()
+	at := evt.Timestamp
+	for _, messageID := range evt.MessageIDs {
+		key := receiptKey{deviceID: deviceID, messageID: messageID, participant: participant}
+		receipt, ok := receiptStore.pending[key]
+		if !ok {
+			receipt = &domainChatStorage.MessageReceipt{DeviceID: deviceID, ChatJID: chatJID, MessageID: messageID, Participant: participant}
+			receiptStore.pending[key] = receipt
+		}
+		if delivered && receipt.DeliveredAt == nil {
+			receipt.DeliveredAt = &at
+		}
+		if read && receipt.ReadAt == nil {
+			receipt.ReadAt = &at
+		}
+	}
+	full := len(receiptStore.pending) >= receiptFlushSize
+	receiptStore.mu.Unlock()
+
+	if full {
+		select {
+		case receiptStore.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func runReceiptFlusher() {
+	ticker := time.NewTicker(receiptFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-receiptStore.flush:
+		}
+		flushMessageReceipts()
+	}
+}
+
+// flushMessageReceipts writes the queued receipts. A failed batch is logged and dropped: receipts
+// are informational and WhatsApp does not send them again.
+func flushMessageReceipts() {
+	receiptStore.mu.Lock()
+	repo := receiptStore.repo
+	if repo == nil || len(receiptStore.pending) == 0 {
+		receiptStore.mu.Unlock()
+		return
+	}
+	batch := make([]*domainChatStorage.MessageReceipt, 0, len(receiptStore.pending))
+	for _, receipt := range receiptStore.pending {
+		batch = append(batch, receipt)
+	}
+	receiptStore.pending = make(map[receiptKey]*domainChatStorage.MessageReceipt)
+	receiptStore.mu.Unlock()
+
+	if err := repo.UpdateMessageReceipt(batch...); err != nil {
+		logrus.Errorf("Failed to store %d message receipts: %v", len(batch), err)
+	}
+}
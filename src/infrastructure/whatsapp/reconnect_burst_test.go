@@ -0,0 +1,445 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// exportRecordingRepo keeps the Chatwoot export records in memory; it survives a simulated restart.
+type exportRecordingRepo struct {
+	domainChatStorage.IChatStorageRepository
+	mu       sync.Mutex
+	exported map[string]int
+	sent     map[string]int // WhatsApp message ID -> conversation, for messages sent from Chatwoot
+	chats    map[string]*domainChatStorage.Chat
+	audit    []*domainChatStorage.ChatwootAuditEntry
+}
+
+func (r *exportRecordingRepo) GetChatByDevice(_, jid string) (*domainChatStorage.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.chats[jid], nil
+}
+
+func (r *exportRecordingRepo) CreateMessage(context.Context, *events.Message) error { return nil }
+
+func (r *exportRecordingRepo) IsMessageExported(deviceID, chatJID, messageKey string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.exported[deviceID+"|"+chatJID+"|"+messageKey]
+	return ok, nil
+}
+
+func (r *exportRecordingRepo) MarkMessageExported(deviceID, chatJID, messageKey string, chatwootMessageID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exported[deviceID+"|"+chatJID+"|"+messageKey] = chatwootMessageID
+	return nil
+}
+
+func (r *exportRecordingRepo) AddChatwootAuditEntry(entry *domainChatStorage.ChatwootAuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = append(r.audit, entry)
+	return nil
+}
+
+func (r *exportRecordingRepo) PurgeChatwootAuditEntries(time.Time) (int64, error) { return 0, nil }
+
+func (r *exportRecordingRepo) GetChatwootSentMessageConversation(messageID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sent[messageID], nil
+}
+
+// fakeChatwoot implements the contact, conversation and message endpoints the forwarder uses and
+// records the messages of each conversation in the order they were created.
+type fakeChatwoot struct {
+	mu            sync.Mutex
+	contacts      []chatwoot.Contact
+	conversations map[int]int // contact ID -> conversation ID
+	messages      map[int][]string
+	sourceIDs     []string
+	attributes    []map[string]any // content_attributes of each message, in creation order
+	nextID        int
+
+	uploadDelay time.Duration // Slows down messages that carry attachments
+}
+
+func newFakeChatwoot(t *testing.T) *fakeChatwoot {
+	t.Helper()
+	fake := &fakeChatwoot{conversations: make(map[int]int), messages: make(map[int][]string)}
+	srv := httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(srv.Close)
+
+	// The fake's own HTTP client keeps the burst clear of the default client's rate limit
+	cw := chatwoot.GetDefaultClient()
+	origURL, origToken, origAccount, origInbox, origHTTP := cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient
+	cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient = srv.URL, "token", 1, 1, srv.Client()
+	t.Cleanup(func() {
+		cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient = origURL, origToken, origAccount, origInbox, origHTTP
+	})
+	return fake
+}
+
+func (f *fakeChatwoot) serve(w http.ResponseWriter, r *http.Request) {
+	multipartBody := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+	if multipartBody {
+		_ = r.ParseMultipartForm(1 << 20)
+		f.mu.Lock()
+		delay := f.uploadDelay
+		f.mu.Unlock()
+		time.Sleep(delay)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/accounts/1/")
+	parts := strings.Split(path, "/")
+	reply := func(v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	switch {
+	case r.Method == http.MethodGet && path == "contacts/search":
+		var found []chatwoot.Contact
+		for _, c := range f.contacts {
+			if q := r.URL.Query().Get("q"); c.PhoneNumber == q || (c.Identifier != "" && c.Identifier == q) {
+				found = append(found, c)
+			}
+		}
+		reply(map[string]any{"payload": found})
+	case r.Method == http.MethodPost && path == "contacts":
+		var contact chatwoot.Contact
+		_ = json.NewDecoder(r.Body).Decode(&contact)
+		f.nextID++
+		contact.ID = f.nextID
+		f.contacts = append(f.contacts, contact)
+		reply(map[string]any{"payload": map[string]any{"contact": contact}})
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "contacts" && parts[2] == "conversations":
+		contactID, _ := strconv.Atoi(parts[1])
+		var convs []map[string]any
+		if id, ok := f.conversations[contactID]; ok {
+			convs = append(convs, map[string]any{"id": id, "inbox_id": 1, "status": "open"})
+		}
+		reply(map[string]any{"payload": convs})
+	case r.Method == http.MethodPost && path == "conversations":
+		var req struct {
+			ContactID int `json:"contact_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.nextID++
+		f.conversations[req.ContactID] = f.nextID
+		reply(map[string]any{"id": f.nextID, "inbox_id": 1, "status": "open"})
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "conversations" && parts[2] == "messages":
+		convID, _ := strconv.Atoi(parts[1])
+		var req struct {
+			Content    string         `json:"content"`
+			SourceID   string         `json:"source_id"`
+			Attributes map[string]any `json:"content_attributes"`
+		}
+		if multipartBody {
+			req.Content, req.SourceID = r.FormValue("content")+" [attachment]", r.FormValue("source_id")
+			_ = json.Unmarshal([]byte(r.FormValue("content_attributes")), &req.Attributes)
+		} else {
+			_ = json.NewDecoder(r.Body).Decode(&req)
+		}
+		f.nextID++
+		f.messages[convID] = append(f.messages[convID], req.Content)
+		f.sourceIDs = append(f.sourceIDs, req.SourceID)
+		f.attributes = append(f.attributes, req.Attributes)
+		reply(map[string]any{"id": f.nextID})
+	default:
+		reply(map[string]any{})
+	}
+}
+
+func (f *fakeChatwoot) snapshot() map[int][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[int][]string, len(f.messages))
+	for id, msgs := range f.messages {
+		out[id] = append([]string(nil), msgs...)
+	}
+	return out
+}
+
+func waitForwardsIdle(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		forwardOrder.mu.Lock()
+		idle := forwardOrder.pending == 0 && len(forwardOrder.held) == 0 && len(forwardOrder.bursts) == 0
+		forwardOrder.mu.Unlock()
+		if idle {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("message forwards did not finish")
+}
+
+func resetChatwootForwardDeduper() {
+	chatwootForwardDeduper.mu.Lock()
+	defer chatwootForwardDeduper.mu.Unlock()
+	chatwootForwardDeduper.seen = make(map[string]time.Time)
+	chatwootForwardDeduper.extendedUntil = time.Time{}
+}
+
+// reconnectBurst is a captured-style replay: every chat's messages re-delivered out of order,
+// a third of them twice, mixed with protocol messages and reactions that must not reach Chatwoot.
+func reconnectBurst(base time.Time) (burst []*events.Message, want map[string][]string) {
+	want = make(map[string][]string)
+	var originals []*events.Message
+	for chat := 1; chat <= 4; chat++ {
+		jid := types.NewJID(fmt.Sprintf("62811100000%d", chat), types.DefaultUserServer)
+		for i := 0; i < 60; i++ {
+			text := fmt.Sprintf("chat %d message %02d", chat, i)
+			originals = append(originals, &events.Message{
+				Info: types.MessageInfo{
+					MessageSource: types.MessageSource{Chat: jid, Sender: jid},
+					ID:            fmt.Sprintf("BURST%d%02d", chat, i),
+					PushName:      fmt.Sprintf("Customer %d", chat),
+					Timestamp:     base.Add(time.Duration(i*4+chat) * time.Second),
+				},
+				Message: &waE2E.Message{Conversation: proto.String(text)},
+			})
+			want[jid.String()] = append(want[jid.String()], text)
+		}
+	}
+
+	burst = append(burst, originals...)
+	for i := 0; i < len(originals); i += 3 {
+		burst = append(burst, originals[i])
+	}
+	for i := 0; i < 20; i++ {
+		src := originals[i*7]
+		burst = append(burst, &events.Message{
+			Info: types.MessageInfo{MessageSource: src.Info.MessageSource, ID: fmt.Sprintf("PROTO%02d", i), Timestamp: src.Info.Timestamp},
+			Message: &waE2E.Message{ProtocolMessage: &waE2E.ProtocolMessage{
+				Type: waE2E.ProtocolMessage_APP_STATE_SYNC_KEY_SHARE.Enum(),
+			}},
+		})
+	}
+	for i := 0; i < 10; i++ {
+		src := originals[i*11]
+		burst = append(burst, &events.Message{
+			Info: types.MessageInfo{MessageSource: src.Info.MessageSource, ID: fmt.Sprintf("REACT%02d", i), Timestamp: src.Info.Timestamp},
+			Message: &waE2E.Message{ReactionMessage: &waE2E.ReactionMessage{
+				Key:  &waCommon.MessageKey{ID: proto.String(src.Info.ID)},
+				Text: proto.String("👍"),
+			}},
+		})
+	}
+
+	rand.New(rand.NewSource(786)).Shuffle(len(burst), func(i, j int) { burst[i], burst[j] = burst[j], burst[i] })
+	return burst, want
+}
+
+func TestReconnectBurst_ForwardsEachMessageOnceInOrder(t *testing.T) {
+	origEnabled, origWebhooks, origEvents := config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents
+	config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents = true, nil, nil
+	t.Cleanup(func() {
+		config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents = origEnabled, origWebhooks, origEvents
+	})
+	origLog := log
+	log = waLog.Noop
+	t.Cleanup(func() { log = origLog })
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	fake := newFakeChatwoot(t)
+	repo := &exportRecordingRepo{exported: make(map[string]int)}
+	inst := NewDeviceInstance("test-device", nil, repo)
+	burst, want := reconnectBurst(time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC))
+
+	replay := func() {
+		handler(context.Background(), inst, &events.Connected{})
+		for _, evt := range burst {
+			handler(context.Background(), inst, evt)
+		}
+		handler(context.Background(), inst, &events.OfflineSyncCompleted{Count: len(burst)})
+		waitForwardsIdle(t)
+	}
+	check := func(stage string) {
+		t.Helper()
+		got := fake.snapshot()
+		if len(got) != len(want) {
+			t.Fatalf("%s: %d conversations got messages, want %d", stage, len(got), len(want))
+		}
+		for _, msgs := range got {
+			if len(msgs) == 0 {
+				continue
+			}
+			chat := fmt.Sprintf("62811100000%c@s.whatsapp.net", msgs[0][5])
+			if strings.Join(msgs, "\n") != strings.Join(want[chat], "\n") {
+				t.Fatalf("%s: conversation of %s got %d messages:\n%s\nwant %d in timestamp order", stage, chat, len(msgs), strings.Join(msgs, "\n"), len(want[chat]))
+			}
+		}
+	}
+
+	replay()
+	check("first burst")
+
+	// A restart forgets the in-memory deduper; the export records still know what was forwarded.
+	resetChatwootForwardDeduper()
+	replay()
+	check("burst after restart")
+
+	if len(repo.exported) != 240 {
+		t.Fatalf("recorded %d forwards, want 240", len(repo.exported))
+	}
+
+	// Every event received is audited with one outcome: the first replay forwards each message, and the
+	// replay after the restart finds its export record. Redelivered events find the export record once
+	// the first forward has finished, and are duplicates while it is still running.
+	decisions := map[string]int{}
+	for _, entry := range repo.audit {
+		decisions[entry.Decision+"/"+entry.Reason]++
+	}
+	outcomes := decisions["forwarded/"] + decisions["skipped/"+chatwoot.AuditReasonAlreadyForwarded] + decisions["skipped/"+chatwoot.AuditReasonDuplicate]
+	if decisions["forwarded/"] != 240 || decisions["skipped/"+chatwoot.AuditReasonAlreadyForwarded] < 240 || decisions["received/"] != outcomes {
+		t.Fatalf("unexpected audit decisions %v", decisions)
+	}
+}
+
+func TestIsDuplicateChatwootForward_ReconnectExtendsTTL(t *testing.T) {
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	age := func(id string, d time.Duration) {
+		chatwootForwardDeduper.mu.Lock()
+		chatwootForwardDeduper.seen[id] = time.Now().Add(-d)
+		chatwootForwardDeduper.mu.Unlock()
+	}
+
+	if isDuplicateChatwootForward("A") {
+		t.Fatal("first forward reported as duplicate")
+	}
+	age("A", 5*time.Minute)
+	if isDuplicateChatwootForward("A") {
+		t.Fatal("forward older than the normal TTL reported as duplicate")
+	}
+
+	extendChatwootForwardDedupe(time.Now().Add(reconnectBurstDedupeTTL))
+	age("A", 5*time.Minute)
+	if !isDuplicateChatwootForward("A") {
+		t.Fatal("re-delivery within the reconnect TTL not reported as duplicate")
+	}
+	age("A", reconnectBurstDedupeTTL+time.Minute)
+	if isDuplicateChatwootForward("A") {
+		t.Fatal("forward older than the reconnect TTL reported as duplicate")
+	}
+}
+
+func TestEndReconnectBurst_ReleasesInTimestampOrder(t *testing.T) {
+	origEnabled := config.ChatwootEnabled
+	config.ChatwootEnabled = true
+	t.Cleanup(func() { config.ChatwootEnabled = origEnabled })
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	base := time.Now()
+	var (
+		mu  sync.Mutex
+		ran []int
+	)
+	beginReconnectBurst("a")
+	beginReconnectBurst("b")
+	for _, offset := range []int{3, 1, 4, 0, 2} {
+		offset := offset
+		dispatchOrderedForward("a", "chat", base.Add(time.Duration(offset)*time.Second), func() {
+			mu.Lock()
+			ran = append(ran, offset)
+			mu.Unlock()
+		}, nil)
+	}
+
+	// Another device keeps forwarding while "a" and "b" replay their offline messages.
+	other := make(chan struct{})
+	dispatchOrderedForward("c", "other-chat", base, func() { close(other) }, nil)
+	select {
+	case <-other:
+	case <-time.After(5 * time.Second):
+		t.Fatal("forward of a device without a burst was held")
+	}
+
+	endReconnectBurst("b")
+	forwardOrder.mu.Lock()
+	held := len(forwardOrder.held["a"])
+	forwardOrder.mu.Unlock()
+	if held != 5 {
+		t.Fatalf("released when another device finished syncing: %d held", held)
+	}
+
+	endReconnectBurst("a")
+	waitForwardsIdle(t)
+	if !sort.IntsAreSorted(ran) || len(ran) != 5 {
+		t.Fatalf("ran %v, want 0..4 in order", ran)
+	}
+}
+
+func TestForwardToChatwoot_SkipsLateEchoOfAgentMessage(t *testing.T) {
+	origEnabled, origWebhooks, origEvents := config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents
+	config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents = true, nil, nil
+	t.Cleanup(func() {
+		config.ChatwootEnabled, config.WhatsappWebhook, config.WhatsappWebhookEvents = origEnabled, origWebhooks, origEvents
+	})
+	origLog := log
+	log = waLog.Noop
+	t.Cleanup(func() { log = origLog })
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	fake := newFakeChatwoot(t)
+	repo := &exportRecordingRepo{exported: make(map[string]int), sent: map[string]int{"AGENT1": 9}}
+	inst := NewDeviceInstance("test-device", nil, repo)
+	jid := types.NewJID("628111000009", types.DefaultUserServer)
+	fromMe := func(id, text string) *events.Message {
+		return &events.Message{
+			Info: types.MessageInfo{
+				MessageSource: types.MessageSource{Chat: jid, Sender: jid, IsFromMe: true},
+				ID:            id,
+				Timestamp:     time.Now(),
+			},
+			Message: &waE2E.Message{Conversation: proto.String(text)},
+		}
+	}
+
+	// The echo arrives long after the in-memory deduper forgot the sent message
+	handler(context.Background(), inst, fromMe("AGENT1", "sent by an agent"))
+	handler(context.Background(), inst, fromMe("PHONE1", "sent from the phone"))
+	waitForwardsIdle(t)
+
+	var got []string
+	for _, msgs := range fake.snapshot() {
+		got = append(got, msgs...)
+	}
+	if len(got) != 1 || got[0] != "sent from the phone" {
+		t.Fatalf("expected only the phone's message to be forwarded, got %q", got)
+	}
+	if len(fake.sourceIDs) != 1 || fake.sourceIDs[0] != "wa:PHONE1" {
+		t.Fatalf("expected source_id wa:PHONE1, got %q", fake.sourceIDs)
+	}
+}
@@ -0,0 +1,91 @@
+package whatsapp
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const senderNameCacheTTL = 10 * time.Minute
+
+// contactGetter is the part of the whatsmeow contact store used to name group senders.
+type contactGetter interface {
+	GetContact(ctx context.Context, jid types.JID) (types.ContactInfo, error)
+}
+
+// senderNames caches the contact store name of group senders per device, "" when the store has
+// none. Entries are dropped when WhatsApp reports a new push name or address-book entry.
+var senderNames = utils.NewTTLCache[string, string](config.AppGroupNameCacheSize, senderNameCacheTTL)
+
+func senderNameKey(client *whatsmeow.Client, jid types.JID) string {
+	device := ""
+	if client != nil && client.Store != nil && client.Store.ID != nil {
+		device = client.Store.ID.User
+	}
+	return device + "|" + jid.ToNonAD().String()
+}
+
+// contactDisplayName picks the best name the contact store has for a contact: the address-book
+// name, then the push name and the business name.
+func contactDisplayName(contact types.ContactInfo) string {
+	for _, name := range []string{contact.FullName, contact.FirstName, contact.PushName, contact.BusinessName} {
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// storedSenderName returns the contact store name of jid, caching it under key.
+func storedSenderName(ctx context.Context, store contactGetter, key string, jid types.JID) string {
+	if name, ok := senderNames.Get(key); ok {
+		return name
+	}
+	contact, err := store.GetContact(ctx, jid.ToNonAD())
+	if err != nil {
+		// Not cached, so the next message tries again
+		logrus.Debugf("Chatwoot: Failed to look up contact %s: %v", jid, err)
+		return ""
+	}
+	name := contactDisplayName(contact)
+	senderNames.Set(key, name)
+	return name
+}
+
+// contactStoreName returns the name the contact store of client knows for jid, or "".
+func contactStoreName(ctx context.Context, client *whatsmeow.Client, jid types.JID) string {
+	if client == nil || client.Store == nil || client.Store.Contacts == nil {
+		return ""
+	}
+	return storedSenderName(ctx, client.Store.Contacts, senderNameKey(client, jid), jid)
+}
+
+// ResolveSenderName returns the name agents see for the sender of a group message: the name the
+// contact store of client knows, then pushName from the message, then the phone number. It matches
+// the resolver signature expected by chatwoot.SetSenderNameResolver.
+func ResolveSenderName(client *whatsmeow.Client, senderJID, pushName string) string {
+	if jid, err := types.ParseJID(senderJID); err == nil {
+		if name := contactStoreName(context.Background(), client, jid); name != "" {
+			return name
+		}
+	}
+	if pushName = strings.TrimSpace(pushName); pushName != "" {
+		return pushName
+	}
+	return utils.ExtractPhoneFromJID(senderJID)
+}
+
+// forgetSenderName drops the cached name of jid after its push name or address-book entry changed.
+// A LID is dropped under its phone number too, the form group senders are forwarded with.
+func forgetSenderName(ctx context.Context, client *whatsmeow.Client, jid types.JID) {
+	senderNames.Delete(senderNameKey(client, jid))
+	if pn := NormalizeJIDFromLID(ctx, jid, client); pn != jid {
+		senderNames.Delete(senderNameKey(client, pn))
+	}
+}
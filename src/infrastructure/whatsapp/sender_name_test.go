@@ -0,0 +1,85 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+type fakeContactStore struct {
+	contacts map[types.JID]types.ContactInfo
+	lookups  int
+	err      error
+}
+
+func (s *fakeContactStore) GetContact(_ context.Context, jid types.JID) (types.ContactInfo, error) {
+	s.lookups++
+	return s.contacts[jid], s.err
+}
+
+func TestContactDisplayName_PrefersAddressBook(t *testing.T) {
+	tests := []struct {
+		contact types.ContactInfo
+		want    string
+	}{
+		{types.ContactInfo{FullName: "Maria Silva", PushName: "Mari"}, "Maria Silva"},
+		{types.ContactInfo{FirstName: "Maria", PushName: "Mari"}, "Maria"},
+		{types.ContactInfo{PushName: " Mari ", BusinessName: "Silva Ltda"}, "Mari"},
+		{types.ContactInfo{BusinessName: "Silva Ltda"}, "Silva Ltda"},
+		{types.ContactInfo{}, ""},
+	}
+	for _, tt := range tests {
+		if got := contactDisplayName(tt.contact); got != tt.want {
+			t.Errorf("contactDisplayName(%+v) = %q, want %q", tt.contact, got, tt.want)
+		}
+	}
+}
+
+func TestStoredSenderName_CachesUntilForgotten(t *testing.T) {
+	jid := types.NewJID("5511999990001", types.DefaultUserServer)
+	store := &fakeContactStore{contacts: map[types.JID]types.ContactInfo{jid: {PushName: "Maria"}}}
+	key := senderNameKey(nil, jid)
+	t.Cleanup(func() { senderNames.Delete(key) })
+
+	for i := 0; i < 3; i++ {
+		if got := storedSenderName(context.Background(), store, key, jid); got != "Maria" {
+			t.Fatalf("storedSenderName = %q, want Maria", got)
+		}
+	}
+	if store.lookups != 1 {
+		t.Errorf("expected one contact store lookup, got %d", store.lookups)
+	}
+
+	store.contacts[jid] = types.ContactInfo{FullName: "Maria Silva", PushName: "Maria"}
+	forgetSenderName(context.Background(), nil, jid)
+	if got := storedSenderName(context.Background(), store, key, jid); got != "Maria Silva" || store.lookups != 2 {
+		t.Errorf("after the contact changed: %q after %d lookups, want Maria Silva after 2", got, store.lookups)
+	}
+}
+
+func TestStoredSenderName_FailedLookupIsNotCached(t *testing.T) {
+	jid := types.NewJID("5511999990002", types.DefaultUserServer)
+	store := &fakeContactStore{err: errors.New("database is locked")}
+	key := senderNameKey(nil, jid)
+	t.Cleanup(func() { senderNames.Delete(key) })
+
+	if got := storedSenderName(context.Background(), store, key, jid); got != "" {
+		t.Fatalf("expected no name on a failed lookup, got %q", got)
+	}
+	store.err = nil
+	store.contacts = map[types.JID]types.ContactInfo{jid: {PushName: "Joana"}}
+	if got := storedSenderName(context.Background(), store, key, jid); got != "Joana" {
+		t.Errorf("expected the lookup retried, got %q", got)
+	}
+}
+
+func TestResolveSenderName_FallsBackToPushNameThenNumber(t *testing.T) {
+	if got := ResolveSenderName(nil, "5511999990003@s.whatsapp.net", " Maria "); got != "Maria" {
+		t.Errorf("expected the push name, got %q", got)
+	}
+	if got := ResolveSenderName(nil, "5511999990003@s.whatsapp.net", ""); got != "5511999990003" {
+		t.Errorf("expected the number, got %q", got)
+	}
+}
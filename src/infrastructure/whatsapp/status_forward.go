@@ -0,0 +1,115 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// EventTypeStatusUpdate is the webhook event of a status (story) posted by a contact.
+const EventTypeStatusUpdate = "status.update"
+
+// statusPrefix starts the Chatwoot message of a forwarded status.
+const statusPrefix = "📢 Status"
+
+// forwardsStatuses reports whether statuses leave the server at all; by default they are ignored.
+func forwardsStatuses() bool {
+	return config.WhatsappForwardStatus || (config.ChatwootEnabled && config.ChatwootForwardStatus)
+}
+
+// forwardStatusUpdate forwards a status to the webhooks subscribed to status.update, with
+// WHATSAPP_FORWARD_STATUS, and to the status conversation of its author in Chatwoot, with
+// CHATWOOT_FORWARD_STATUS. Reactions, edits and deletions of statuses are not forwarded.
+func forwardStatusUpdate(ctx context.Context, client *whatsmeow.Client, evt *events.Message) error {
+	webhookEvent, err := createWebhookEvent(ctx, client, evt)
+	if err != nil {
+		return err
+	}
+	if webhookEvent.Event != EventTypeMessage {
+		logrus.Debugf("Skipping %s of status %s", webhookEvent.Event, evt.Info.ID)
+		return nil
+	}
+	payload := map[string]any{
+		"event":     EventTypeStatusUpdate,
+		"device_id": webhookEvent.DeviceID,
+		"payload":   webhookEvent.Payload,
+	}
+
+	if config.ChatwootEnabled && config.ChatwootForwardStatus && !evt.Info.IsFromMe {
+		if err := forwardStatusToChatwoot(ctx, webhookEvent.DeviceID, webhookEvent.Payload); err != nil {
+			logrus.Errorf("Chatwoot: Failed to forward status %s: %v", evt.Info.ID, err)
+			chatwoot.RecordBridgeError(chatwoot.BridgeToChatwoot, err)
+		}
+	}
+	if !config.WhatsappForwardStatus {
+		return nil
+	}
+	return forwardPayloadToConfiguredWebhooks(ctx, payload, EventTypeStatusUpdate)
+}
+
+// forwardStatusToChatwoot appends a status to the conversation labeled whatsapp-status of its author,
+// creating the conversation on their first status. Their chat conversation is left alone. View-once
+// statuses follow CHATWOOT_FORWARD_VIEW_ONCE like any view-once message.
+func forwardStatusToChatwoot(ctx context.Context, deviceJID string, data map[string]any) error {
+	cw := chatwootClientForContext(ctx, deviceJID)
+	if !cw.IsConfigured() {
+		return nil
+	}
+	msgID, _ := data["id"].(string)
+	if msgID != "" && isDuplicateChatwootForward("status:"+msgID) {
+		return nil
+	}
+
+	content, attachments, generated, supported := buildChatwootMessageContent(data, "", "")
+	defer removeGeneratedAttachments(generated)
+	if !supported {
+		logrus.Debugf("Chatwoot: Status %s has nothing to show, skipping", msgID)
+		return nil
+	}
+	if content == "" {
+		content = statusPrefix
+	} else {
+		content = statusPrefix + ": " + content
+	}
+
+	from, _ := data["from"].(string)
+	fromLID, _ := data["from_lid"].(string)
+	name, _ := data["from_name"].(string)
+	identifier, lid := resolveChatwootIdentity(ctx, from, fromLID)
+	if name == "" {
+		name = identifier
+	}
+
+	unlock := lockContact(identifier, "forwardStatusToChatwoot")
+	var (
+		contact *chatwoot.Contact
+		err     error
+	)
+	if lid != "" {
+		contact, err = cw.FindOrCreateContactWithLID(name, identifier, lid)
+	} else {
+		contact, err = cw.FindOrCreateContact(name, identifier, false)
+	}
+	unlock()
+	if err != nil {
+		return fmt.Errorf("failed to find/create contact for %s: %w", identifier, err)
+	}
+
+	conversation, err := cw.FindOrCreateStatusConversation(contact.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find/create status conversation for contact %d: %w", contact.ID, err)
+	}
+	chatwootMsgID, err := cw.CreateMessage(conversation.ID, content, "incoming", attachments, chatwoot.ForwardedMessageKey(msgID), "", time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to create message: %w", err)
+	}
+	cw.MarkMessageAsSent(chatwootMsgID)
+	chatwoot.RecordBridgedMessage(chatwoot.BridgeToChatwoot, identifier)
+	return nil
+}
@@ -0,0 +1,102 @@
+package whatsapp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+func statusMessage(id, text string) *events.Message {
+	sender := types.NewJID("628111000042", types.DefaultUserServer)
+	return &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{Chat: types.StatusBroadcastJID, Sender: sender},
+			ID:            id,
+			PushName:      "Customer",
+			Timestamp:     time.Now(),
+		},
+		Message: &waE2E.Message{Conversation: proto.String(text)},
+	}
+}
+
+// useStatusForwarding sets the status options and the webhook and Chatwoot settings for the test,
+// recording the webhook posts.
+func useStatusForwarding(t *testing.T, toWebhook, toChatwoot bool) (delivered func() []map[string]any) {
+	t.Helper()
+	origWebhooks, origEvents, origEnabled := config.WhatsappWebhook, config.WhatsappWebhookEvents, config.ChatwootEnabled
+	origStatus, origChatwootStatus := config.WhatsappForwardStatus, config.ChatwootForwardStatus
+	config.WhatsappWebhook, config.WhatsappWebhookEvents, config.ChatwootEnabled = []string{"https://hook"}, nil, toChatwoot
+	config.WhatsappForwardStatus, config.ChatwootForwardStatus = toWebhook, toChatwoot
+	t.Cleanup(func() {
+		config.WhatsappWebhook, config.WhatsappWebhookEvents, config.ChatwootEnabled = origWebhooks, origEvents, origEnabled
+		config.WhatsappForwardStatus, config.ChatwootForwardStatus = origStatus, origChatwootStatus
+	})
+	resetChatwootForwardDeduper()
+	t.Cleanup(resetChatwootForwardDeduper)
+
+	var (
+		mu    sync.Mutex
+		posts []map[string]any
+	)
+	origSubmit := submitWebhookFn
+	submitWebhookFn = func(_ context.Context, payload map[string]any, _ string) error {
+		mu.Lock()
+		posts = append(posts, payload)
+		mu.Unlock()
+		return nil
+	}
+	t.Cleanup(func() { submitWebhookFn = origSubmit })
+	return func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]any(nil), posts...)
+	}
+}
+
+func TestHandleWebhookForward_IgnoresStatusesByDefault(t *testing.T) {
+	delivered := useStatusForwarding(t, false, false)
+	fake := newFakeChatwoot(t)
+
+	handleWebhookForward(context.Background(), statusMessage("STATUS1", "new menu today"), nil)
+	waitForwardsIdle(t)
+
+	if posts := delivered(); len(posts) != 0 {
+		t.Fatalf("expected no webhook post for a status, got %+v", posts)
+	}
+	if got := fake.snapshot(); len(got) != 0 {
+		t.Fatalf("expected nothing in Chatwoot, got %+v", got)
+	}
+}
+
+func TestHandleWebhookForward_ForwardsStatusesWhenEnabled(t *testing.T) {
+	delivered := useStatusForwarding(t, true, true)
+	fake := newFakeChatwoot(t)
+
+	handleWebhookForward(context.Background(), statusMessage("STATUS2", "new menu today"), nil)
+	waitForwardsIdle(t)
+
+	posts := delivered()
+	if len(posts) != 1 || posts[0]["event"] != EventTypeStatusUpdate {
+		t.Fatalf("expected one %s post, got %+v", EventTypeStatusUpdate, posts)
+	}
+	if payload := posts[0]["payload"].(map[string]any); payload["id"] != "STATUS2" || payload["chat_id"] != "status@broadcast" {
+		t.Errorf("unexpected status payload %+v", payload)
+	}
+
+	got := fake.snapshot()
+	if len(got) != 1 {
+		t.Fatalf("expected the status in one conversation, got %+v", got)
+	}
+	for _, msgs := range got {
+		if len(msgs) != 1 || msgs[0] != statusPrefix+": new menu today" {
+			t.Fatalf("expected the status prefixed, got %q", msgs)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package whatsapp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+// storagePruneInterval is how often the pruner applies CHAT_STORAGE_RETENTION_DAYS.
+const storagePruneInterval = 6 * time.Hour
+
+var storagePrunerOnce sync.Once
+
+// StoragePruneOptions turns the retention settings into prune options. ok is false when
+// CHAT_STORAGE_RETENTION_DAYS keeps everything. Dedup rows are kept for CHAT_STORAGE_DEDUP_RETENTION_DAYS,
+// and never for less than the messages they deduplicate.
+func StoragePruneOptions(now time.Time, dryRun bool) (opts domainChatStorage.StoragePruneOptions, ok bool) {
+	if config.ChatStorageRetentionDays <= 0 {
+		return opts, false
+	}
+	dedupDays := max(config.ChatStorageDedupRetentionDays, config.ChatStorageRetentionDays)
+	return domainChatStorage.StoragePruneOptions{
+		MessagesBefore: now.AddDate(0, 0, -config.ChatStorageRetentionDays),
+		DedupBefore:    now.AddDate(0, 0, -dedupDays),
+		KeepMedia:      config.ChatStorageRetentionKeepMedia,
+		DryRun:         dryRun,
+	}, true
+}
+
+// PruneStorage applies the retention policy to repo once, stopping early when ctx ends. It reports
+// nothing deleted when retention is disabled.
+func PruneStorage(ctx context.Context, repo domainChatStorage.IChatStorageRepository, dryRun bool) (*domainChatStorage.StoragePruneResult, error) {
+	opts, ok := StoragePruneOptions(time.Now(), dryRun)
+	if !ok {
+		return &domainChatStorage.StoragePruneResult{DryRun: dryRun, Deleted: map[string]int64{}}, nil
+	}
+	return repo.PruneStorage(ctx, opts)
+}
+
+// StartStoragePruner applies the retention policy at startup and every storagePruneInterval.
+func StartStoragePruner(ctx context.Context, repo domainChatStorage.IChatStorageRepository) {
+	if repo == nil || config.ChatStorageRetentionDays <= 0 {
+		return
+	}
+	storagePrunerOnce.Do(func() {
+		go func() {
+			pruneStorageAndLog(ctx, repo)
+			ticker := time.NewTicker(storagePruneInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					pruneStorageAndLog(ctx, repo)
+				}
+			}
+		}()
+	})
+}
+
+func pruneStorageAndLog(ctx context.Context, repo domainChatStorage.IChatStorageRepository) {
+	result, err := PruneStorage(ctx, repo, false)
+	if err != nil {
+		logrus.Errorf("Storage pruner: %v", err)
+		return
+	}
+	logrus.Infof("Storage pruner: deleted %v", result.Deleted)
+}
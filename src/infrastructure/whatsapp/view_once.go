@@ -0,0 +1,52 @@
+package whatsapp
+
+import (
+	"os"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/sirupsen/logrus"
+)
+
+// viewOnceMediaFields are the payload fields view-once media arrives in.
+var viewOnceMediaFields = []string{"image", "video", "audio"}
+
+func isViewOncePayload(data map[string]interface{}) bool {
+	viewOnce, _ := data["view_once"].(bool)
+	return viewOnce
+}
+
+// applyViewOncePolicy rewrites a view-once message per CHATWOOT_FORWARD_VIEW_ONCE. It returns the
+// content and attachments to post, and the blurred previews it wrote, which the caller removes.
+func applyViewOncePolicy(data map[string]interface{}, content string, attachments []string) (string, []string, []string) {
+	mode := chatwoot.ViewOnceMode()
+	mediaType := ""
+	for _, field := range viewOnceMediaFields {
+		if _, ok := data[field]; ok {
+			mediaType = field
+			break
+		}
+	}
+	if mode == chatwoot.ViewOnceFull || mediaType == "" {
+		return content, attachments, nil
+	}
+
+	content = chatwoot.ViewOnceContent(mediaType, content)
+	if mode == chatwoot.ViewOncePlaceholder || mediaType == "audio" {
+		return content, nil, nil
+	}
+
+	// Only media downloaded to disk can be blurred; a bare media URL falls back to the placeholder.
+	var previews []string
+	for _, attachment := range attachments {
+		if _, err := os.Stat(attachment); err != nil {
+			continue
+		}
+		preview, err := chatwoot.BlurViewOnceMedia(attachment, mediaType == "video")
+		if err != nil {
+			logrus.Warnf("Chatwoot: Posting view-once %s without preview: %v", mediaType, err)
+			continue
+		}
+		previews = append(previews, preview)
+	}
+	return content, previews, previews
+}
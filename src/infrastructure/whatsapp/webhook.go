@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -42,27 +43,49 @@ func getWebhookHTTPClient(insecureSkipVerify bool) *http.Client {
 	return client
 }
 
-func submitWebhook(ctx context.Context, payload map[string]any, url string) error {
-	client := getWebhookHTTPClient(config.WhatsappWebhookInsecureSkipVerify)
+// Headers sent with signed webhook deliveries. X-Hub-Signature-256 covers the body only and is kept for
+// existing receivers; X-Webhook-Signature-256 covers "<timestamp>.<body>" so receivers can reject replays.
+const (
+	webhookSignatureHeader          = "X-Hub-Signature-256"
+	webhookTimestampHeader          = "X-Webhook-Timestamp"
+	webhookTimestampSignatureHeader = "X-Webhook-Signature-256"
+)
 
-	postBody, err := json.Marshal(payload)
-	if err != nil {
-		return pkgError.WebhookError(fmt.Sprintf("Failed to marshal body: %v", err))
+// signWebhookRequest adds the HMAC-SHA256 signature and timestamp headers. Nothing is added when secret is empty.
+func signWebhookRequest(req *http.Request, body []byte, secret string, now time.Time) error {
+	if secret == "" {
+		return nil
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	secretKey := []byte(secret)
+	signature, err := utils.GetMessageDigestOrSignature(body, secretKey)
 	if err != nil {
-		return pkgError.WebhookError(fmt.Sprintf("error when create http object %v", err))
+		return pkgError.WebhookError(fmt.Sprintf("error when create signature %v", err))
 	}
 
-	secretKey := []byte(config.WhatsappWebhookSecret)
-	signature, err := utils.GetMessageDigestOrSignature(postBody, secretKey)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signed := make([]byte, 0, len(timestamp)+1+len(body))
+	signed = append(signed, timestamp...)
+	signed = append(signed, '.')
+	signed = append(signed, body...)
+	timestampSignature, err := utils.GetMessageDigestOrSignature(signed, secretKey)
 	if err != nil {
 		return pkgError.WebhookError(fmt.Sprintf("error when create signature %v", err))
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Hub-Signature-256", fmt.Sprintf("sha256=%s", signature))
+	req.Header.Set(webhookSignatureHeader, fmt.Sprintf("sha256=%s", signature))
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookTimestampSignatureHeader, fmt.Sprintf("sha256=%s", timestampSignature))
+	return nil
+}
+
+func submitWebhook(ctx context.Context, payload map[string]any, url string) error {
+	client := getWebhookHTTPClient(config.WhatsappWebhookInsecureSkipVerify)
+
+	postBody, err := json.Marshal(payload)
+	if err != nil {
+		return pkgError.WebhookError(fmt.Sprintf("Failed to marshal body: %v", err))
+	}
 
 	var (
 		attempt       int
@@ -71,16 +94,13 @@ func submitWebhook(ctx context.Context, payload map[string]any, url string) erro
 	)
 
 	for attempt = 0; attempt < maxAttempts; attempt++ {
-		// Create new request body for each attempt
-		req.Body = io.NopCloser(bytes.NewBuffer(postBody))
-		resp, err := client.Do(req)
+		err = postWebhook(ctx, client, url, postBody)
 		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-				logrus.Infof("Successfully submitted webhook on attempt %d", attempt+1)
-				return nil
-			}
-			err = fmt.Errorf("webhook returned status %d", resp.StatusCode)
+			logrus.Infof("Successfully submitted webhook on attempt %d", attempt+1)
+			return nil
+		}
+		if _, ok := err.(pkgError.WebhookError); ok {
+			return err
 		}
 		logrus.Warnf("Attempt %d to submit webhook failed: %v", attempt+1, err)
 		if attempt < maxAttempts-1 {
@@ -95,3 +115,29 @@ func submitWebhook(ctx context.Context, payload map[string]any, url string) erro
 
 	return pkgError.WebhookError(fmt.Sprintf("error when submit webhook after %d attempts: %v", attempt, err))
 }
+
+// postWebhook performs a single signed delivery of an already-encoded body. Errors that retrying cannot fix
+// (bad URL, signing failure) are returned as pkgError.WebhookError; transport and non-2xx errors are plain.
+func postWebhook(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return pkgError.WebhookError(fmt.Sprintf("error when create http object %v", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Sign per request so the timestamp stays fresh across retries
+	if err := signWebhookRequest(req, body, config.WhatsappWebhookSecret, time.Now()); err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
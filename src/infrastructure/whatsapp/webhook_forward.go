@@ -2,101 +2,170 @@ package whatsapp
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"hash/fnv"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/metrics"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 )
 
-var submitWebhookFn = submitWebhook
-
-const mutexShardCount = 64
-
-var contactMutexShards [mutexShardCount]sync.Mutex
+var (
+	submitWebhookFn = submitWebhook
+	postWebhookFn   = postWebhook
+)
 
-type groupNameCacheEntry struct {
-	name      string
-	expiresAt time.Time
-}
+// errChatwootNotConfigured is returned by forwardToChatwoot while the Chatwoot client lacks settings.
+var errChatwootNotConfigured = errors.New("the Chatwoot client is not configured")
 
 var (
-	groupNameCache    sync.Map
-	groupNameCacheTTL = 5 * time.Minute
+	contactLocksOnce sync.Once
+	contactLocks     *utils.ShardedLock
+)
 
+var (
 	chatwootForwardDeduper = struct {
-		mu   sync.Mutex
-		seen map[string]time.Time
+		mu            sync.Mutex
+		seen          map[string]time.Time
+		extendedUntil time.Time // reconnectBurstDedupeTTL applies until then
 	}{
 		seen: make(map[string]time.Time),
 	}
 	chatwootForwardDeduperTTL = 2 * time.Minute
 )
 
-func getCachedGroupName(groupJID string) (string, bool) {
-	if entry, ok := groupNameCache.Load(groupJID); ok {
-		cached := entry.(groupNameCacheEntry)
-		if time.Now().Before(cached.expiresAt) {
-			return cached.name, true
-		}
-		groupNameCache.Delete(groupJID)
-	}
-	return "", false
-}
-
-func setCachedGroupName(groupJID, name string) {
-	groupNameCache.Store(groupJID, groupNameCacheEntry{
-		name:      name,
-		expiresAt: time.Now().Add(groupNameCacheTTL),
+// lockContact serialises Chatwoot contact/conversation creation per identifier.
+func lockContact(identifier, op string) func() {
+	contactLocksOnce.Do(func() {
+		contactLocks = utils.NewShardedLock("whatsapp.contact", config.AppLockShards, time.Duration(config.AppLockWaitWarnSec)*time.Second)
 	})
-}
-
-func getContactMutex(phone string) *sync.Mutex {
-	h := fnv.New32a()
-	_, _ = h.Write([]byte(phone))
-	return &contactMutexShards[h.Sum32()%mutexShardCount]
+	return contactLocks.Lock(identifier, op)
 }
 
 func forwardPayloadToConfiguredWebhooks(ctx context.Context, payload map[string]any, eventName string) error {
-	if len(config.WhatsappWebhookEvents) > 0 {
-		if !isEventWhitelisted(eventName) {
-			logrus.Debugf("Skipping event %s - not in webhook events whitelist", eventName)
-			return nil
+	toChatwoot := forwardsToChatwoot(eventName)
+
+	var held []string
+	if hasWebhookSubscriber(eventName) {
+		held = append(held, domainChatStorage.MaintenanceTargetWebhook)
+	}
+	if toChatwoot {
+		held = append(held, domainChatStorage.MaintenanceTargetChatwoot)
+	}
+	if eventName == "message" && config.ChatwootEnabled && !toChatwoot {
+		newChatwootAudit(ctx, payload).record(domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonNotWhitelisted, 0, "")
+	}
+
+	toWebhooks := true
+	for _, target := range bufferDuringMaintenance(payload, eventName, held) {
+		switch target {
+		case domainChatStorage.MaintenanceTargetWebhook:
+			toWebhooks = false
+		case domainChatStorage.MaintenanceTargetChatwoot:
+			toChatwoot = false
 		}
 	}
+	if !toWebhooks && !toChatwoot {
+		logrus.Debugf("Maintenance: buffered %s", eventName)
+		return nil
+	}
 
-	err := forwardToWebhooks(ctx, payload, eventName)
+	// From here on the forward saves itself if the shutdown cannot wait for it
+	var urls []string
+	if toWebhooks {
+		urls = subscribedWebhookURLs(eventName)
+	}
+	if len(urls) > 0 || toChatwoot {
+		fwd := beginForward(ctx, payload, eventName, urls, toChatwoot)
+		defer fwd.done()
+		inflightFromContext(ctx).handOver()
+		ctx = contextWithForward(ctx, fwd)
+	}
 
-	if eventName == "message" && config.ChatwootEnabled {
-		go forwardToChatwoot(ctx, payload)
+	// Message events arrive through dispatchOrderedForward, so Chatwoot is done before the next
+	// message of the chat is forwarded.
+	var chatwootDone chan struct{}
+	if toChatwoot {
+		chatwootDone = make(chan struct{})
+		go func() {
+			defer close(chatwootDone)
+			forwardChatwootMessage(ctx, eventName, payload)
+		}()
+	}
+	var err error
+	if toWebhooks {
+		err = forwardToWebhooks(ctx, payload, eventName)
+	}
+	if chatwootDone != nil {
+		<-chatwootDone
 	}
 
 	return err
 }
 
+// forwardsToChatwoot reports whether events named eventName go to Chatwoot, which keeps following
+// the global whitelist only.
+func forwardsToChatwoot(eventName string) bool {
+	return eventName == "message" && config.ChatwootEnabled &&
+		(len(config.WhatsappWebhookEvents) == 0 || isEventWhitelisted(eventName))
+}
+
+func hasWebhookSubscriber(eventName string) bool {
+	for _, target := range currentWebhookTargets() {
+		if target.Accepts(eventName) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribedWebhookURLs returns the URLs of the webhooks that accept events named eventName.
+func subscribedWebhookURLs(eventName string) []string {
+	var urls []string
+	for _, target := range currentWebhookTargets() {
+		if target.Accepts(eventName) {
+			urls = append(urls, target.URL)
+		}
+	}
+	return urls
+}
+
 func forwardToWebhooks(ctx context.Context, payload map[string]any, eventName string) error {
-	total := len(config.WhatsappWebhook)
-	logrus.Infof("Forwarding %s to %d configured webhook(s)", eventName, total)
+	urls := subscribedWebhookURLs(eventName)
+	fwd := forwardFromContext(ctx)
 
+	total := len(urls)
 	if total == 0 {
+		logrus.Debugf("Skipping event %s - no webhook subscribed to it", eventName)
 		return nil
 	}
+	logrus.Infof("Forwarding %s to %d configured webhook(s)", eventName, total)
 
 	var (
 		failed    []string
 		successes int
 	)
-	for _, url := range config.WhatsappWebhook {
-		if err := submitWebhookFn(ctx, payload, url); err != nil {
+	for _, url := range urls {
+		err := submitWebhookFn(ctx, payload, url)
+		metrics.WebhookDelivery(url, err)
+		queued := !fwd.settle(url) // The shutdown saved the post already
+		if err != nil {
 			failed = append(failed, fmt.Sprintf("%s: %v", url, err))
 			logrus.Warnf("Failed forwarding %s to %s: %v", eventName, url, err)
+			if !queued {
+				enqueueFailedWebhook(url, eventName, payload, err)
+			}
 			continue
 		}
 		successes++
@@ -115,11 +184,44 @@ func forwardToWebhooks(ctx context.Context, payload map[string]any, eventName st
 }
 
 type chatwootContactInfo struct {
-	Identifier string
-	Name       string
-	IsGroup    bool
-	FromName   string
-	IsFromMe   bool
+	Identifier  string
+	Name        string
+	LID         string // LID of the private chat partner, when known
+	IsGroup     bool
+	FromName    string // In groups, the name the contact store knows, see ResolveSenderName
+	IsFromMe    bool
+	DeviceAlias string // Set only when several devices share the Chatwoot inbox
+
+	// Contact identity of the participant who sent a group message, set with CHATWOOT_GROUP_PARTICIPANT_CONTACTS
+	ParticipantIdentifier string
+	ParticipantLID        string
+
+	// Recorded on the conversation as custom attributes; left empty, the conversation is not touched
+	Metadata chatwoot.ConversationMetadata
+}
+
+// chatwootSharedInboxDeviceAlias returns the alias of the device that owns deviceJID when more than
+// one device forwards into the single configured Chatwoot inbox. It returns "" in single-device setups
+// so conversations keep their plain display data.
+func chatwootSharedInboxDeviceAlias(deviceJID string) string {
+	dm := GetDeviceManager()
+	if dm == nil || len(dm.ListDevices()) < 2 {
+		return ""
+	}
+	inst, ok := dm.FindDeviceByJID(deviceJID)
+	if !ok {
+		return ""
+	}
+	return inst.ID()
+}
+
+// chatwootGroupSenderName prefixes the group sender with the device alias so agents can tell
+// which number received the message.
+func chatwootGroupSenderName(fromName, deviceAlias string) string {
+	if deviceAlias == "" || fromName == "" {
+		return fromName
+	}
+	return fmt.Sprintf("[%s] %s", deviceAlias, fromName)
 }
 
 func extractChatwootContactInfo(ctx context.Context, data map[string]interface{}) (*chatwootContactInfo, error) {
@@ -142,17 +244,32 @@ func extractChatwootContactInfo(ctx context.Context, data map[string]interface{}
 	}
 
 	if isGroup {
+		info.FromName = ResolveSenderName(ClientFromContext(ctx), from, fromName)
+		if config.ChatwootGroupParticipantContacts && !isFromMe {
+			fromLID, _ := data["from_lid"].(string)
+			info.ParticipantIdentifier, info.ParticipantLID = resolveChatwootIdentity(ctx, from, fromLID)
+		}
 		info.Identifier = chatID
 		info.Name = getGroupName(ctx, chatID)
 		if info.Name == "" {
 			info.Name = "Group: " + utils.ExtractPhoneFromJID(chatID)
 		}
 		logrus.Infof("Chatwoot: Detected group message, using group contact: %s", info.Name)
+	} else if utils.IsNewsletterJID(chatID) {
+		// Channels get a contact of their own, found by the channel JID like groups
+		info.Identifier = chatID
+		info.Name = getNewsletterName(ctx, chatID)
+		if info.Name == "" {
+			info.Name = "Channel: " + strings.TrimSuffix(chatID, "@newsletter")
+		}
+		info.FromName = info.Name
 	} else if isFromMe {
-		info.Identifier = utils.ExtractPhoneFromJID(chatID)
+		chatLID, _ := data["chat_lid"].(string)
+		info.Identifier, info.LID = resolveChatwootIdentity(ctx, chatID, chatLID)
 		info.Name = info.Identifier
 	} else {
-		info.Identifier = utils.ExtractPhoneFromJID(from)
+		fromLID, _ := data["from_lid"].(string)
+		info.Identifier, info.LID = resolveChatwootIdentity(ctx, from, fromLID)
 		info.Name = fromName
 		if info.Name == "" {
 			info.Name = info.Identifier
@@ -170,13 +287,13 @@ func classifyMessageSupport(data map[string]interface{}, content string, attachm
 	if t, ok := data["type"].(string); ok {
 		switch t {
 		case "sticker":
-			return true, "(Sticker)"
+			return true, chatwoot.RenderMessage(chatwoot.MessageSticker, chatwoot.MessageData{MediaType: t})
 		case "ephemeral":
 			return false, ""
 		case "protocol":
 			return false, ""
 		default:
-			return true, fmt.Sprintf("(Unsupported: %s)", t)
+			return true, chatwoot.RenderMessage(chatwoot.MessageUnsupported, chatwoot.MessageData{MediaType: t})
 		}
 	}
 
@@ -185,36 +302,97 @@ func classifyMessageSupport(data map[string]interface{}, content string, attachm
 
 var mediaFields = []string{"image", "audio", "video", "document", "sticker", "video_note"}
 
-func buildChatwootMessageContent(data map[string]interface{}, isGroup bool, fromName string) (string, []string, bool) {
-	content := extractBaseContent(data)
+// buildChatwootMessageContent returns the text and attachments to post for a message. groupName is
+// the subject of the group the message was sent to, "" outside groups. generated lists the
+// attachments written for this message only, such as location previews, .vcf files, view-once
+// previews, large video thumbnails and previews of linked images. The caller removes them once the
+// message was sent.
+func buildChatwootMessageContent(data map[string]interface{}, groupName, fromName string) (content string, attachments, generated []string, supported bool) {
+	content = extractBaseContent(data)
 	content, isEdited := extractEditedContent(data, content)
-	attachments := extractAttachments(data)
+	attachments = extractAttachments(data)
+	var viewOncePreviews, videoFrames, linkPreviews []string
+	if isViewOncePayload(data) {
+		content, attachments, viewOncePreviews = applyViewOncePolicy(data, content, attachments)
+	} else {
+		content, attachments, videoFrames = chatwoot.ApplyLargeVideoPolicy(content, attachments)
+		content, attachments, linkPreviews = chatwoot.ApplyMediaLinkPolicy(content, attachments)
+	}
+	if thumbnail := saveLocationThumbnail(data); thumbnail != "" {
+		generated = append(generated, thumbnail)
+	}
+	if vcf := saveSharedVCards(data); vcf != "" {
+		generated = append(generated, vcf)
+	}
+	attachments = append(attachments, generated...)
+	generated = append(generated, viewOncePreviews...)
+	generated = append(generated, videoFrames...)
+	generated = append(generated, linkPreviews...)
 
 	supported, fallback := classifyMessageSupport(data, content, attachments)
 	if !supported {
-		return "", nil, false
+		removeGeneratedAttachments(generated)
+		return "", nil, nil, false
 	}
 
 	if content == "" && fallback != "" {
 		content = fallback
 	}
 
+	tmplData := chatwoot.MessageData{
+		SenderName: fromName,
+		GroupName:  groupName,
+		Timestamp:  payloadMessageTime(data),
+		MediaType:  payloadMediaType(data),
+	}
 	if isEdited && content != "" {
-		content = "✏️ Editado: " + content
+		tmplData.Content = content
+		content = chatwoot.RenderMessage(chatwoot.MessageEdited, tmplData)
 	}
 
-	if isGroup && fromName != "" {
+	if groupName != "" && fromName != "" {
+		tmplData.Content = content
 		if content != "" {
-			content = fromName + ": " + content
+			content = chatwoot.RenderMessage(chatwoot.MessageGroupSender, tmplData)
 		} else if len(attachments) > 0 {
-			content = fromName + ": (media)"
+			content = chatwoot.RenderMessage(chatwoot.MessageGroupMedia, tmplData)
 		}
 	}
 
-	return content, attachments, true
+	return content, attachments, generated, true
 }
-func forwardTypingToChatwoot(evt *events.ChatPresence) {
-	cw := chatwoot.GetDefaultClient()
+
+// payloadMessageTime returns the time the message of a webhook payload was sent, formatted for
+// message templates; "" when the payload has none.
+func payloadMessageTime(data map[string]interface{}) string {
+	raw, _ := data["timestamp"].(string)
+	sent, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return ""
+	}
+	return chatwoot.MessageTime(sent)
+}
+
+// payloadMediaType returns the kind of media a webhook payload carries, or its message type.
+func payloadMediaType(data map[string]interface{}) string {
+	for _, field := range mediaFields {
+		if value, ok := data[field]; ok && value != nil {
+			return field
+		}
+	}
+	t, _ := data["type"].(string)
+	return t
+}
+
+func removeGeneratedAttachments(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("Chatwoot: Failed to remove temporary attachment %s: %v", path, err)
+		}
+	}
+}
+func forwardTypingToChatwoot(evt *events.ChatPresence, client *whatsmeow.Client) {
+	cw := chatwootClientFor(client)
 	if !cw.IsConfigured() {
 		return
 	}
@@ -314,19 +492,20 @@ var skipMessageTypes = map[string]struct{}{
 	"keep_in_chat":            {},
 }
 
-func shouldSkipMessage(data map[string]interface{}) bool {
+// shouldSkipMessage reports whether a message is kept out of Chatwoot, and the kind of message it is.
+func shouldSkipMessage(data map[string]interface{}) (bool, string) {
 	for _, key := range skipKeys {
 		if _, ok := data[key]; ok {
-			return true
+			return true, key
 		}
 	}
 
 	if typeVal, ok := data["type"].(string); ok {
 		_, skip := skipMessageTypes[typeVal]
-		return skip
+		return skip, typeVal
 	}
 
-	return false
+	return false, ""
 }
 
 func chatwootMessageTypeFromPayload(data map[string]interface{}) string {
@@ -337,102 +516,203 @@ func chatwootMessageTypeFromPayload(data map[string]interface{}) string {
 }
 
 func extractStructuredMessageContent(data map[string]interface{}) string {
-	if contact, ok := data["contact"]; ok && contact != nil {
-		if cm, ok := contact.(interface {
-			GetDisplayName() string
-			GetVcard() string
-		}); ok {
-			name := cm.GetDisplayName()
-			phone := extractPhoneFromVCard(cm.GetVcard())
-			switch {
-			case name != "" && phone != "":
-				return fmt.Sprintf("Contact: %s (%s)", name, phone)
-			case name != "":
-				return "Contact: " + name
-			case phone != "":
-				return "Contact: " + phone
+	if cards, ok := sharedContactCards(data); ok {
+		var contacts []utils.VCardContact
+		for _, card := range cards {
+			parsed := utils.ParseVCards(card.GetVcard())
+			if len(parsed) == 0 {
+				parsed = []utils.VCardContact{{}}
 			}
+			for _, contact := range parsed {
+				if contact.Name == "" {
+					contact.Name = card.GetDisplayName()
+				}
+				contacts = append(contacts, contact)
+			}
+		}
+		return chatwoot.FormatContactCards(contacts)
+	}
+
+	if poll, ok := data["poll"]; ok && poll != nil {
+		if pm, ok := poll.(*waE2E.PollCreationMessage); ok {
+			return chatwoot.FormatPoll(pm.GetName(), pollOptionNames(pm), int(pm.GetSelectableOptionsCount()))
 		}
-		return "Contact shared"
+		return chatwoot.RenderMessage(chatwoot.MessagePoll, chatwoot.MessageData{})
 	}
 
 	if location, ok := data["location"]; ok && location != nil {
-		if lm, ok := location.(interface {
-			GetDegreesLatitude() float64
-			GetDegreesLongitude() float64
-			GetName() string
-		}); ok {
-			name := lm.GetName()
-			if name != "" {
-				return fmt.Sprintf("Location: %s (%.6f, %.6f)", name, lm.GetDegreesLatitude(), lm.GetDegreesLongitude())
-			}
-			return fmt.Sprintf("Location: %.6f, %.6f", lm.GetDegreesLatitude(), lm.GetDegreesLongitude())
+		if lm, ok := location.(*waE2E.LocationMessage); ok {
+			return chatwoot.FormatLocation(chatwoot.Location{
+				Latitude:       lm.GetDegreesLatitude(),
+				Longitude:      lm.GetDegreesLongitude(),
+				Name:           lm.GetName(),
+				Address:        lm.GetAddress(),
+				Caption:        lm.GetComment(),
+				AccuracyMeters: lm.GetAccuracyInMeters(),
+			})
 		}
-		return "Location shared"
+		return chatwoot.RenderMessage(chatwoot.MessageLocation, chatwoot.MessageData{})
 	}
 
 	if liveLocation, ok := data["live_location"]; ok && liveLocation != nil {
-		if lm, ok := liveLocation.(interface {
-			GetDegreesLatitude() float64
-			GetDegreesLongitude() float64
-		}); ok {
-			return fmt.Sprintf("Live Location: %.6f, %.6f", lm.GetDegreesLatitude(), lm.GetDegreesLongitude())
+		if lm, ok := liveLocation.(*waE2E.LiveLocationMessage); ok {
+			return chatwoot.FormatLocation(chatwoot.Location{
+				Latitude:       lm.GetDegreesLatitude(),
+				Longitude:      lm.GetDegreesLongitude(),
+				Caption:        lm.GetCaption(),
+				AccuracyMeters: lm.GetAccuracyInMeters(),
+				Live:           true,
+			})
 		}
-		return "Live location shared"
+		return chatwoot.RenderMessage(chatwoot.MessageLiveLocation, chatwoot.MessageData{})
 	}
 
 	if list, ok := data["list"]; ok && list != nil {
+		title := ""
 		if lm, ok := list.(interface{ GetTitle() string }); ok {
-			title := lm.GetTitle()
-			if title != "" {
-				return "List: " + title
-			}
+			title = lm.GetTitle()
 		}
-		return "List message"
+		return chatwoot.RenderMessage(chatwoot.MessageList, chatwoot.MessageData{Title: title})
 	}
 
 	if order, ok := data["order"]; ok && order != nil {
+		title := ""
 		if om, ok := order.(interface{ GetOrderTitle() string }); ok {
-			title := om.GetOrderTitle()
-			if title != "" {
-				return "Order: " + title
-			}
+			title = om.GetOrderTitle()
 		}
-		return "Order message"
+		return chatwoot.RenderMessage(chatwoot.MessageOrder, chatwoot.MessageData{Title: title})
 	}
 
 	return ""
 }
 
-func extractPhoneFromVCard(vcard string) string {
-	for _, line := range strings.Split(vcard, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(strings.ToUpper(line), "TEL") {
-			if idx := strings.LastIndex(line, ":"); idx >= 0 {
-				return strings.TrimSpace(line[idx+1:])
-			}
+// saveLocationThumbnail writes the JPEG preview of a location message next to the other received
+// media and returns its path, so agents see the pin as an image.
+func saveLocationThumbnail(data map[string]interface{}) string {
+	var thumbnail []byte
+	if lm, ok := data["location"].(*waE2E.LocationMessage); ok {
+		thumbnail = lm.GetJPEGThumbnail()
+	} else if lm, ok := data["live_location"].(*waE2E.LiveLocationMessage); ok {
+		thumbnail = lm.GetJPEGThumbnail()
+	}
+	if len(thumbnail) == 0 {
+		return ""
+	}
+
+	file, err := os.CreateTemp(config.PathMedia, "location-*.jpg")
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to store location preview: %v", err)
+		return ""
+	}
+	defer file.Close()
+	if _, err := file.Write(thumbnail); err != nil {
+		logrus.Warnf("Chatwoot: Failed to store location preview: %v", err)
+		_ = os.Remove(file.Name())
+		return ""
+	}
+	return file.Name()
+}
+
+// sharedContactCards returns the contacts of a contact or contacts-array message. ok is true for
+// those messages even when their contents were lost, e.g. after a JSON round trip.
+func sharedContactCards(data map[string]interface{}) (cards []*waE2E.ContactMessage, ok bool) {
+	if contact, found := data["contact"]; found && contact != nil {
+		if cm, isCard := contact.(*waE2E.ContactMessage); isCard {
+			cards = append(cards, cm)
 		}
+		ok = true
 	}
-	return ""
+	if array, found := data["contacts_array"]; found && array != nil {
+		if am, isArray := array.(*waE2E.ContactsArrayMessage); isArray {
+			cards = append(cards, am.GetContacts()...)
+		}
+		ok = true
+	}
+	return cards, ok
 }
 
-func syncMessageToChatwoot(cw *chatwoot.Client, info *chatwootContactInfo, content string, attachments []string) error {
-	mu := getContactMutex(info.Identifier)
-	mu.Lock()
+// saveSharedVCards writes the vCards of a contact message to a .vcf file next to the other received
+// media and returns its path, so agents can import the contacts.
+func saveSharedVCards(data map[string]interface{}) string {
+	cards, _ := sharedContactCards(data)
+	var body strings.Builder
+	for _, card := range cards {
+		vcard := strings.TrimSpace(card.GetVcard())
+		if vcard == "" {
+			continue
+		}
+		body.WriteString(vcard)
+		body.WriteString("\r\n")
+	}
+	if body.Len() == 0 {
+		return ""
+	}
 
-	contact, err := cw.FindOrCreateContact(info.Name, info.Identifier, info.IsGroup)
+	file, err := os.CreateTemp(config.PathMedia, "contact-*.vcf")
 	if err != nil {
-		mu.Unlock()
-		return fmt.Errorf("failed to find/create contact for %s: %w", info.Identifier, err)
+		logrus.Warnf("Chatwoot: Failed to store shared contact: %v", err)
+		return ""
+	}
+	defer file.Close()
+	if _, err := file.WriteString(body.String()); err != nil {
+		logrus.Warnf("Chatwoot: Failed to store shared contact: %v", err)
+		_ = os.Remove(file.Name())
+		return ""
+	}
+	return file.Name()
+}
+
+// syncMessageToChatwoot posts a message to the contact's conversation and returns the ID Chatwoot
+// gave it. sourceID becomes the message's source_id, normally chatwoot.ForwardedMessageKey of the
+// WhatsApp message. The outcome is recorded in audit.
+func syncMessageToChatwoot(cw *chatwoot.Client, info *chatwootContactInfo, content string, attachments []string, sourceID string, audit chatwootAudit) (msgID int, err error) {
+	defer func() {
+		if err != nil {
+			audit.record(domainChatStorage.ChatwootAuditFailed, chatwoot.AuditReasonChatwootFailed, 0, err.Error())
+			return
+		}
+		audit.record(domainChatStorage.ChatwootAuditForwarded, "", msgID, "")
+	}()
+
+	unlock := lockContact(info.Identifier, "syncMessageToChatwoot")
+
+	var contact *chatwoot.Contact
+	if info.LID != "" && !info.IsGroup {
+		contact, err = cw.FindOrCreateContactWithLID(info.Name, info.Identifier, info.LID)
+	} else {
+		contact, err = cw.FindOrCreateContact(info.Name, info.Identifier, info.IsGroup)
+	}
+	if err != nil {
+		unlock()
+		return 0, fmt.Errorf("failed to find/create contact for %s: %w", info.Identifier, err)
 	}
 	logrus.Infof("Chatwoot: Contact ID: %d", contact.ID)
 
+	if info.DeviceAlias != "" {
+		if current, _ := contact.CustomAttributes["waha_device"].(string); current != info.DeviceAlias {
+			attrs := map[string]interface{}{
+				"waha_whatsapp_jid": info.Identifier,
+				"waha_device":       info.DeviceAlias,
+			}
+			if err := cw.UpdateContactAttributes(contact.ID, info.Identifier, attrs, info.IsGroup); err != nil {
+				logrus.Warnf("Chatwoot: Failed to store device %s on contact %d: %v", info.DeviceAlias, contact.ID, err)
+			}
+		}
+	}
+
 	conversation, err := cw.FindOrCreateConversation(contact.ID)
-	mu.Unlock()
+	unlock()
 	if err != nil {
-		return fmt.Errorf("failed to find/create conversation for contact %d: %w", contact.ID, err)
+		return 0, fmt.Errorf("failed to find/create conversation for contact %d: %w", contact.ID, err)
 	}
 	logrus.Infof("Chatwoot: Conversation ID: %d", conversation.ID)
+	cw.StoreConversationMetadata(conversation, info.Metadata)
+
+	if info.DeviceAlias != "" && config.ChatwootDeviceLabel {
+		if err := cw.AddConversationLabel(conversation.ID, chatwoot.DeviceLabel(info.DeviceAlias)); err != nil {
+			logrus.Warnf("Chatwoot: Failed to label conversation %d with device %s: %v", conversation.ID, info.DeviceAlias, err)
+		}
+	}
 
 	logrus.Infof("Chatwoot: Creating message (Length: %d, Attachments: %d)", len(content), len(attachments))
 	messageType := "incoming"
@@ -440,64 +720,270 @@ func syncMessageToChatwoot(cw *chatwoot.Client, info *chatwootContactInfo, conte
 		messageType = "outgoing"
 	}
 
-	msgID, err := cw.CreateMessage(conversation.ID, content, messageType, attachments, info.Identifier, "")
+	msg := chatwoot.CreateMessageRequest{Content: content, MessageType: messageType}
+	if info.ParticipantIdentifier != "" {
+		msg.ContentAttributes = chatwootParticipantAttributes(cw, info)
+	}
+	msgID, err = cw.CreateMessageFromRequest(conversation.ID, msg, attachments, sourceID)
 	if err != nil {
-		return fmt.Errorf("failed to create message: %w", err)
+		return 0, fmt.Errorf("failed to create message: %w", err)
 	}
-	chatwoot.MarkMessageAsSent(msgID)
+	cw.MarkMessageAsSent(msgID)
+	chatwoot.RecordBridgedMessage(chatwoot.BridgeToChatwoot, info.Identifier)
 
 	logrus.Infof("Chatwoot: Message synced successfully for %s", info.Identifier)
-	return nil
+	return msgID, nil
+}
+
+// chatwootParticipantAttributes finds or creates the contact of the participant who sent a group
+// message, the same contact their private chat uses, without opening a conversation for it. It
+// returns the content attributes that tag the message with the participant, or nil when the contact
+// could not be had; the message is then posted untagged.
+func chatwootParticipantAttributes(cw *chatwoot.Client, info *chatwootContactInfo) map[string]interface{} {
+	unlock := lockContact(info.ParticipantIdentifier, "chatwootParticipantAttributes")
+	defer unlock()
+
+	var (
+		contact *chatwoot.Contact
+		err     error
+	)
+	if info.ParticipantLID != "" {
+		contact, err = cw.FindOrCreateContactWithLID(info.FromName, info.ParticipantIdentifier, info.ParticipantLID)
+	} else {
+		contact, err = cw.FindOrCreateContact(info.FromName, info.ParticipantIdentifier, false)
+	}
+	if err != nil {
+		logrus.Warnf("Chatwoot: Failed to find/create the contact of group participant %s: %v", info.ParticipantIdentifier, err)
+		return nil
+	}
+	return map[string]interface{}{
+		"participant_contact_id": contact.ID,
+		"participant_name":       info.FromName,
+		"participant_identifier": info.ParticipantIdentifier,
+	}
+}
+
+// forwardChatwootMessage runs the Chatwoot forward of an event registered with beginForward. A
+// forward that fails is queued in the outbox for replay, unless the shutdown saved it meanwhile.
+func forwardChatwootMessage(ctx context.Context, eventName string, payload map[string]any) {
+	err := forwardToChatwoot(ctx, payload)
+	if !forwardFromContext(ctx).chatwootDone() || err == nil || errors.Is(err, errChatwootNotConfigured) {
+		return
+	}
+	enqueueChatwootForward(eventName, payload, err)
 }
 
-func forwardToChatwoot(ctx context.Context, payload map[string]any) {
+// forwardToChatwoot posts the message event payload to Chatwoot. It returns an error when the message
+// could not be posted and is worth trying again; messages skipped on purpose return nil.
+func forwardToChatwoot(ctx context.Context, payload map[string]any) error {
 	logrus.Info("Chatwoot: Attempting to forward message...")
-	cw := chatwoot.GetDefaultClient()
+	deviceJID, _ := payload["device_id"].(string)
+	cw := chatwootClientForContext(ctx, deviceJID)
 	if !cw.IsConfigured() {
 		logrus.Warn("Chatwoot: Client is not configured (check CHATWOOT_* env vars)")
-		return
+		return errChatwootNotConfigured
 	}
 
 	data, ok := payload["payload"].(map[string]interface{})
 	if !ok {
 		logrus.Error("Chatwoot: Invalid payload format (missing 'payload' object)")
-		return
+		return nil
 	}
 
 	if typeVal, ok := data["type"].(string); ok && typeVal == "revoked" {
-		go handleChatwootRevoke(ctx, cw, data)
-		return
+		work := trackInflight("Chatwoot revoke", nil)
+		go func() {
+			defer work.done()
+			handleChatwootRevoke(ctx, cw, data)
+		}()
+		return nil
 	}
 
-	if msgID, _ := data["id"].(string); msgID != "" {
-		if isDuplicateChatwootForward(msgID) {
-			logrus.Debugf("Chatwoot: Skipping duplicate forward for WhatsApp message %s", msgID)
-			return
+	audit := newChatwootAudit(ctx, payload)
+	repo, storageDeviceID := audit.repo, audit.deviceID
+	msgID, chatID := audit.messageID, audit.chatID
+	audit.record(domainChatStorage.ChatwootAuditReceived, "", 0, "")
+
+	// The export records outlive the in-memory deduper, which forgets IDs on restart, when WhatsApp
+	// replays the messages it delivered shortly before. They also hold the messages the history
+	// sync exported.
+	if repo != nil && msgID != "" && chatID != "" {
+		exported, err := repo.IsMessageExported(storageDeviceID, chatID, chatwoot.ForwardedMessageKey(msgID))
+		if err != nil {
+			logrus.Warnf("Chatwoot: Failed to check whether WhatsApp message %s was forwarded: %v", msgID, err)
+		} else if exported {
+			logrus.Debugf("Chatwoot: Skipping WhatsApp message %s, already forwarded", msgID)
+			audit.record(domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonAlreadyForwarded, 0, "")
+			return nil
+		}
+	}
+	// Catches a redelivery while the first forward of the message is still running
+	if msgID != "" && isDuplicateChatwootForward(msgID) {
+		logrus.Debugf("Chatwoot: Skipping duplicate forward for WhatsApp message %s", msgID)
+		audit.record(domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonDuplicate, 0, "")
+		return nil
+	}
+	// A message an agent sent from Chatwoot is already in its conversation, however late it comes back
+	if isFromMe, _ := data["is_from_me"].(bool); isFromMe && repo != nil && msgID != "" {
+		conversationID, err := repo.GetChatwootSentMessageConversation(msgID)
+		if err != nil {
+			logrus.Warnf("Chatwoot: Failed to check whether WhatsApp message %s was sent from Chatwoot: %v", msgID, err)
+		} else if conversationID != 0 {
+			logrus.Debugf("Chatwoot: Skipping WhatsApp message %s, sent from conversation %d", msgID, conversationID)
+			audit.record(domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonSentFromChatwoot, 0, fmt.Sprintf("conversation %d", conversationID))
+			return nil
 		}
 	}
 
-	if shouldSkipMessage(data) {
+	if skip, kind := shouldSkipMessage(data); skip {
 		logrus.Debug("Chatwoot: Skipping message type (reaction/poll_update/etc) to prevent spam")
-		return
+		audit.record(domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonUnsupportedType, 0, kind)
+		return nil
+	}
+	ephemeralExpiration, err := chatwootEphemeralExpiration(repo, storageDeviceID, chatID)
+	ephemeralMode := chatwoot.ChatEphemeralMode(ephemeralExpiration)
+	if err != nil {
+		// A timer that cannot be read is taken to be on, so a disappearing message is not exported
+		logrus.Warnf("Chatwoot: Failed to look up the disappearing-message timer of chat %s: %v", chatID, err)
+		ephemeralMode = chatwoot.EphemeralMode()
+	}
+	if ephemeralMode == chatwoot.EphemeralSkip {
+		logrus.Debugf("Chatwoot: Skipping WhatsApp message %s, chat %s has disappearing messages", msgID, chatID)
+		audit.record(domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonEphemeralChat, 0, "")
+		return nil
 	}
 
 	info, err := extractChatwootContactInfo(ctx, data)
 	if err != nil {
 		logrus.Warnf("Chatwoot: Skipping message: %v", err)
-		return
+		audit.record(domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonNoContact, 0, err.Error())
+		return nil
+	}
+	if deviceJID != "" {
+		info.DeviceAlias = chatwootSharedInboxDeviceAlias(deviceJID)
+	}
+	if storageDeviceID != "" && chatID != "" {
+		info.Metadata = chatwoot.NewConversationMetadata(storageDeviceID, chatID, ephemeralExpiration)
 	}
 
-	content, attachments, supported := buildChatwootMessageContent(data, info.IsGroup, info.FromName)
+	senderName := chatwootGroupSenderName(info.FromName, info.DeviceAlias)
+	groupName := ""
+	if info.IsGroup {
+		groupName = info.Name
+	}
+	content, attachments, generated, supported := buildChatwootMessageContent(data, groupName, senderName)
 	if !supported {
 		logrus.Debug("Chatwoot: Message classified as not supported for human display")
-		return
+		kind, _ := data["type"].(string)
+		audit.record(domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonUnsupportedType, 0, kind)
+		return nil
+	}
+	defer removeGeneratedAttachments(generated)
+	if ephemeralMode == chatwoot.EphemeralRedact {
+		content, attachments = chatwoot.EphemeralPlaceholder, nil
+		if info.IsGroup && senderName != "" {
+			content = senderName + ": " + content
+		}
+	}
+
+	if !info.IsGroup && !info.IsFromMe && len(attachments) == 0 {
+		handled, err := cw.HandleCSATReply(info.Identifier, content)
+		if err != nil {
+			logrus.Warnf("Chatwoot: Forwarding %s's reply as a message, CSAT submission failed: %v", info.Identifier, err)
+			chatwoot.RecordBridgeError(chatwoot.BridgeToChatwoot, err)
+		}
+		if handled {
+			chatwoot.RecordBridgedMessage(chatwoot.BridgeToChatwoot, info.Identifier)
+			audit.record(domainChatStorage.ChatwootAuditForwarded, chatwoot.AuditReasonCSATReply, 0, "")
+			return nil
+		}
 	}
 
-	if err := syncMessageToChatwoot(cw, info, content, attachments); err != nil {
+	chatwootMsgID, err := syncMessageToChatwoot(cw, info, content, attachments, chatwoot.ForwardedMessageKey(msgID), audit)
+	if err != nil {
 		logrus.Errorf("Chatwoot: %v", err)
+		chatwoot.RecordBridgeError(chatwoot.BridgeToChatwoot, err)
+		// A later attempt, such as an outbox replay, must not be taken for a duplicate
+		forgetChatwootForward(msgID)
+		return err
 	}
+	if repo != nil && msgID != "" && chatID != "" {
+		if err := repo.MarkMessageExported(storageDeviceID, chatID, chatwoot.ForwardedMessageKey(msgID), chatwootMsgID); err != nil {
+			logrus.Warnf("Chatwoot: Failed to record forward of WhatsApp message %s: %v", msgID, err)
+		}
+	}
+	return nil
 }
 
+// chatwootEphemeralExpiration returns the disappearing-message timer stored for chatID, which decides
+// how its messages reach Chatwoot under CHATWOOT_FORWARD_EPHEMERAL; 0 when it is off or the chat is
+// not stored.
+func chatwootEphemeralExpiration(repo domainChatStorage.IChatStorageRepository, deviceID, chatID string) (uint32, error) {
+	if repo == nil || chatID == "" {
+		return 0, nil
+	}
+	chat, err := repo.GetChatByDevice(deviceID, chatID)
+	if err != nil || chat == nil {
+		return 0, err
+	}
+	return chat.EphemeralExpiration, nil
+}
+
+// chatwootForwardStorage returns the chat storage of the device that received payload and the
+// device ID its Chatwoot export records are kept under, as used by the history sync.
+func chatwootForwardStorage(ctx context.Context, payload map[string]any) (domainChatStorage.IChatStorageRepository, string) {
+	inst, ok := DeviceFromContext(ctx)
+	if !ok || inst == nil {
+		deviceJID, _ := payload["device_id"].(string)
+		if dm := GetDeviceManager(); dm != nil && deviceJID != "" {
+			inst, ok = dm.FindDeviceByJID(deviceJID)
+		}
+	}
+	if !ok || inst == nil || inst.GetChatStorage() == nil {
+		return nil, ""
+	}
+	storageDeviceID := inst.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = inst.ID()
+	}
+	return inst.GetChatStorage(), storageDeviceID
+}
+
+// chatwootAudit records the bridging decisions taken for one WhatsApp message.
+type chatwootAudit struct {
+	repo      domainChatStorage.IChatStorageRepository
+	deviceID  string
+	chatID    string
+	messageID string
+}
+
+func newChatwootAudit(ctx context.Context, payload map[string]any) chatwootAudit {
+	audit := chatwootAudit{}
+	audit.repo, audit.deviceID = chatwootForwardStorage(ctx, payload)
+	if data, ok := payload["payload"].(map[string]interface{}); ok {
+		audit.chatID, _ = data["chat_id"].(string)
+		audit.messageID, _ = data["id"].(string)
+	}
+	return audit
+}
+
+func (a chatwootAudit) record(decision, reason string, chatwootMessageID int, detail string) {
+	chatwoot.RecordAudit(a.repo, &domainChatStorage.ChatwootAuditEntry{
+		DeviceID:          a.deviceID,
+		ChatJID:           a.chatID,
+		MessageID:         a.messageID,
+		Direction:         chatwoot.BridgeToChatwoot,
+		Decision:          decision,
+		Reason:            reason,
+		ChatwootMessageID: chatwootMessageID,
+		Detail:            detail,
+	})
+}
+
+// chatwootRevokeLookback is how far back a conversation is searched for a message deleted on WhatsApp,
+// which allows deleting for everyone for about two and a half days.
+const chatwootRevokeLookback = 72 * time.Hour
+
 func handleChatwootRevoke(ctx context.Context, cw *chatwoot.Client, data map[string]interface{}) {
 	info, err := extractChatwootContactInfo(ctx, data)
 	if err != nil {
@@ -516,6 +1002,11 @@ func handleChatwootRevoke(ctx context.Context, cw *chatwoot.Client, data map[str
 		return
 	}
 
+	if !cw.Capabilities().SupportsMessageDelete {
+		logrus.Debugf("Chatwoot: Not deleting revoked message %s, the Chatwoot version cannot delete messages", revokedID)
+		return
+	}
+
 	// Achar Contato e Conversa
 	contact, err := cw.FindContactByIdentifier(info.Identifier, info.IsGroup)
 	if err != nil || contact == nil {
@@ -528,7 +1019,7 @@ func handleChatwootRevoke(ctx context.Context, cw *chatwoot.Client, data map[str
 	}
 
 	// Buscar as mensagens daquela conversa no Chatwoot para achar a que tem o SourceID igual ao ID revogado
-	cwMsgs, err := cw.GetConversationMessages(conv.ID)
+	cwMsgs, err := cw.GetConversationMessages(conv.ID, time.Now().Add(-chatwootRevokeLookback))
 	if err != nil {
 		return
 	}
@@ -551,14 +1042,19 @@ func isDuplicateChatwootForward(messageID string) bool {
 	chatwootForwardDeduper.mu.Lock()
 	defer chatwootForwardDeduper.mu.Unlock()
 
+	ttl := chatwootForwardDeduperTTL
+	if now.Before(chatwootForwardDeduper.extendedUntil) {
+		ttl = reconnectBurstDedupeTTL
+	}
+
 	for id, ts := range chatwootForwardDeduper.seen {
-		if now.Sub(ts) > chatwootForwardDeduperTTL {
+		if now.Sub(ts) > ttl {
 			delete(chatwootForwardDeduper.seen, id)
 		}
 	}
 
 	if ts, exists := chatwootForwardDeduper.seen[messageID]; exists {
-		if now.Sub(ts) <= chatwootForwardDeduperTTL {
+		if now.Sub(ts) <= ttl {
 			return true
 		}
 	}
@@ -567,6 +1063,37 @@ func isDuplicateChatwootForward(messageID string) bool {
 	return false
 }
 
+// forgetChatwootForward drops messageID from the deduper, after its forward failed.
+func forgetChatwootForward(messageID string) {
+	if messageID == "" {
+		return
+	}
+	chatwootForwardDeduper.mu.Lock()
+	delete(chatwootForwardDeduper.seen, messageID)
+	chatwootForwardDeduper.mu.Unlock()
+}
+
+// MarkSentFromChatwoot tells the forwarder that messageID was sent by an agent from Chatwoot, so its
+// echo is skipped without a database lookup while the deduper remembers it.
+func MarkSentFromChatwoot(messageID string) {
+	if messageID == "" {
+		return
+	}
+	chatwootForwardDeduper.mu.Lock()
+	defer chatwootForwardDeduper.mu.Unlock()
+	chatwootForwardDeduper.seen[messageID] = time.Now()
+}
+
+// extendChatwootForwardDedupe keeps forwarded message IDs for reconnectBurstDedupeTTL until the
+// given time, so events re-delivered late in a reconnect burst are still recognised.
+func extendChatwootForwardDedupe(until time.Time) {
+	chatwootForwardDeduper.mu.Lock()
+	defer chatwootForwardDeduper.mu.Unlock()
+	if until.After(chatwootForwardDeduper.extendedUntil) {
+		chatwootForwardDeduper.extendedUntil = until
+	}
+}
+
 func isEventWhitelisted(eventName string) bool {
 	for _, allowed := range config.WhatsappWebhookEvents {
 		if strings.EqualFold(strings.TrimSpace(allowed), eventName) {
@@ -576,6 +1103,15 @@ func isEventWhitelisted(eventName string) bool {
 	return false
 }
 
+// getNewsletterName returns the name of a channel, asking WhatsApp when it is not cached.
+func getNewsletterName(ctx context.Context, newsletterJID string) string {
+	client := ClientFromContext(ctx)
+	if client == nil {
+		client = GetClient()
+	}
+	return ResolveNewsletterName(client, newsletterJID)
+}
+
 func getGroupName(ctx context.Context, groupJID string) string {
 	if name, ok := getCachedGroupName(groupJID); ok {
 		logrus.Debugf("Chatwoot: Using cached group name for %s: %s", groupJID, name)
@@ -592,28 +1128,5 @@ func getGroupName(ctx context.Context, groupJID string) string {
 		return ""
 	}
 
-	jid, err := types.ParseJID(groupJID)
-	if err != nil {
-		logrus.Warnf("Chatwoot: Failed to parse group JID %s: %v", groupJID, err)
-		return ""
-	}
-
-	freshCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	logrus.Debugf("Chatwoot: Fetching group info for %s", groupJID)
-	groupInfo, err := client.GetGroupInfo(freshCtx, jid)
-	if err != nil {
-		logrus.Warnf("Chatwoot: Failed to get group info for %s: %v", groupJID, err)
-		return ""
-	}
-
-	if groupInfo != nil && groupInfo.Name != "" {
-		logrus.Infof("Chatwoot: Got group name: %s", groupInfo.Name)
-		setCachedGroupName(groupJID, groupInfo.Name)
-		return groupInfo.Name
-	}
-
-	logrus.Debug("Chatwoot: GroupInfo is nil or Name is empty")
-	return ""
+	return GetGroupInfoService().Name(client, groupJID)
 }
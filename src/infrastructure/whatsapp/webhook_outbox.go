@@ -0,0 +1,278 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	webhookOutboxPollInterval = 15 * time.Second
+	webhookOutboxBatchSize    = 50
+	webhookOutboxBaseDelay    = 30 * time.Second
+	webhookOutboxMaxDelay     = 1 * time.Hour
+
+	// chatwootOutboxURL is the URL of outbox entries that replay a Chatwoot forward instead of
+	// posting to a webhook.
+	chatwootOutboxURL = "chatwoot:"
+)
+
+var (
+	webhookOutboxMu   sync.RWMutex
+	webhookOutboxRepo domainChatStorage.IChatStorageRepository
+
+	webhookOutboxDispatcherOnce sync.Once
+
+	// forwardToChatwootFn replays a queued Chatwoot forward; replaced in tests.
+	forwardToChatwootFn = forwardToChatwoot
+)
+
+// WebhookOutboxStats summarises the persistent retry queue.
+type WebhookOutboxStats struct {
+	Pending     int64 `json:"pending"`
+	Dead        int64 `json:"dead"`
+	MaxAttempts int   `json:"max_attempts"`
+}
+
+// SetWebhookOutboxRepository sets the storage used to persist failed webhook deliveries.
+func SetWebhookOutboxRepository(repo domainChatStorage.IChatStorageRepository) {
+	webhookOutboxMu.Lock()
+	defer webhookOutboxMu.Unlock()
+	webhookOutboxRepo = repo
+}
+
+func getWebhookOutboxRepository() domainChatStorage.IChatStorageRepository {
+	webhookOutboxMu.RLock()
+	defer webhookOutboxMu.RUnlock()
+	return webhookOutboxRepo
+}
+
+// webhookOutboxBackoff returns the delay before the next retry after the given number of failed attempts.
+func webhookOutboxBackoff(attempts int) time.Duration {
+	delay := webhookOutboxBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookOutboxMaxDelay {
+			return webhookOutboxMaxDelay
+		}
+	}
+	return delay
+}
+
+// enqueueFailedWebhook persists a delivery that exhausted its in-process retries so it is not dropped.
+func enqueueFailedWebhook(url, eventName string, payload map[string]any, cause error) {
+	repo := getWebhookOutboxRepository()
+	if repo == nil || config.WhatsappWebhookRetryMaxAttempts <= 0 {
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logrus.Errorf("Webhook outbox: failed to encode %s payload for %s: %v", eventName, url, err)
+		return
+	}
+
+	entry := &domainChatStorage.WebhookOutboxEntry{
+		URL:           url,
+		Event:         eventName,
+		Payload:       string(body),
+		Attempts:      1,
+		NextAttemptAt: time.Now().Add(webhookOutboxBackoff(1)),
+		Status:        domainChatStorage.WebhookOutboxPending,
+	}
+	if cause != nil {
+		entry.LastError = cause.Error()
+	}
+	if err := repo.EnqueueWebhookOutbox(entry); err != nil {
+		logrus.Errorf("Webhook outbox: failed to enqueue %s for %s: %v", eventName, url, err)
+		return
+	}
+	logrus.Infof("Webhook outbox: queued %s for %s as entry %d", eventName, url, entry.ID)
+}
+
+// enqueueChatwootForward persists a Chatwoot forward that did not finish, to be replayed by the
+// outbox dispatcher. It reports whether the forward was queued.
+func enqueueChatwootForward(eventName string, payload map[string]any, cause error) bool {
+	repo := getWebhookOutboxRepository()
+	if repo == nil || config.WhatsappWebhookRetryMaxAttempts <= 0 {
+		return false
+	}
+
+	body, err := encodeForwardPayload(payload)
+	if err != nil {
+		logrus.Errorf("Webhook outbox: failed to encode %s payload for Chatwoot: %v", eventName, err)
+		return false
+	}
+
+	entry := &domainChatStorage.WebhookOutboxEntry{
+		URL:           chatwootOutboxURL,
+		Event:         eventName,
+		Payload:       string(body),
+		NextAttemptAt: time.Now(),
+		Status:        domainChatStorage.WebhookOutboxPending,
+	}
+	if cause != nil {
+		entry.LastError = cause.Error()
+	}
+	if err := repo.EnqueueWebhookOutbox(entry); err != nil {
+		logrus.Errorf("Webhook outbox: failed to enqueue %s for Chatwoot: %v", eventName, err)
+		return false
+	}
+	logrus.Infof("Webhook outbox: queued %s for Chatwoot as entry %d", eventName, entry.ID)
+	return true
+}
+
+// StartWebhookOutboxDispatcher starts the background loop that retries queued webhook deliveries.
+func StartWebhookOutboxDispatcher(ctx context.Context) {
+	webhookOutboxDispatcherOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(webhookOutboxPollInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					dispatchDueWebhooks(ctx, time.Now())
+				}
+			}
+		}()
+	})
+}
+
+// dispatchDueWebhooks retries every entry that is due and returns how many were delivered.
+// Retries are held while maintenance mode is active.
+func dispatchDueWebhooks(ctx context.Context, now time.Time) int {
+	repo := getWebhookOutboxRepository()
+	if repo == nil || IsMaintenanceActive() {
+		return 0
+	}
+
+	entries, err := repo.GetDueWebhookOutbox(now, webhookOutboxBatchSize)
+	if err != nil {
+		logrus.Errorf("Webhook outbox: failed to load due entries: %v", err)
+		return 0
+	}
+
+	delivered := 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		if retryWebhookOutboxEntry(ctx, repo, entry, now) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+func retryWebhookOutboxEntry(ctx context.Context, repo domainChatStorage.IChatStorageRepository, entry *domainChatStorage.WebhookOutboxEntry, now time.Time) bool {
+	var err error
+	if entry.URL == chatwootOutboxURL {
+		err = replayChatwootForward(entry)
+	} else {
+		client := getWebhookHTTPClient(config.WhatsappWebhookInsecureSkipVerify)
+		err = postWebhookFn(ctx, client, entry.URL, []byte(entry.Payload))
+	}
+	if errors.Is(err, errForwardQueueFull) {
+		// The entry did not fail, the forward workers were busy: retry without spending an attempt
+		entry.NextAttemptAt = now.Add(webhookOutboxBackoff(1))
+		if updErr := repo.UpdateWebhookOutbox(entry); updErr != nil {
+			logrus.Errorf("Webhook outbox: failed to update entry %d: %v", entry.ID, updErr)
+		}
+		return false
+	}
+	if err == nil {
+		if delErr := repo.DeleteWebhookOutbox(entry.ID); delErr != nil {
+			logrus.Errorf("Webhook outbox: delivered entry %d but failed to remove it: %v", entry.ID, delErr)
+		}
+		logrus.Infof("Webhook outbox: delivered %s to %s after %d attempt(s)", entry.Event, entry.URL, entry.Attempts+1)
+		return true
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+	if entry.Attempts >= config.WhatsappWebhookRetryMaxAttempts {
+		entry.Status = domainChatStorage.WebhookOutboxDead
+		logrus.Warnf("Webhook outbox: giving up on entry %d (%s to %s) after %d attempts: %v", entry.ID, entry.Event, entry.URL, entry.Attempts, err)
+	} else {
+		entry.NextAttemptAt = now.Add(webhookOutboxBackoff(entry.Attempts))
+		logrus.Debugf("Webhook outbox: entry %d failed attempt %d, next retry at %s", entry.ID, entry.Attempts, entry.NextAttemptAt.Format(time.RFC3339))
+	}
+	if updErr := repo.UpdateWebhookOutbox(entry); updErr != nil {
+		logrus.Errorf("Webhook outbox: failed to update entry %d: %v", entry.ID, updErr)
+	}
+	return false
+}
+
+// replayChatwootForward forwards a queued Chatwoot entry again, as the device that received it. The
+// replay runs on the forward worker of its chat, so it never overlaps the live forwards of the chat.
+func replayChatwootForward(entry *domainChatStorage.WebhookOutboxEntry) error {
+	payload, err := decodeForwardPayload([]byte(entry.Payload))
+	if err != nil {
+		return fmt.Errorf("unreadable payload: %w", err)
+	}
+	replay := func() error {
+		return forwardToChatwootFn(forwardPayloadContext(payload), payload)
+	}
+	data, _ := payload["payload"].(map[string]any)
+	chat, _ := data["chat_id"].(string)
+	if chat == "" {
+		return replay()
+	}
+	return runOrderedForward(chat, replay)
+}
+
+// ListFailedWebhooks returns dead-lettered deliveries, newest first.
+func ListFailedWebhooks(limit int) ([]*domainChatStorage.WebhookOutboxEntry, error) {
+	repo := getWebhookOutboxRepository()
+	if repo == nil {
+		return nil, fmt.Errorf("webhook outbox is not initialized")
+	}
+	return repo.ListWebhookOutbox(domainChatStorage.WebhookOutboxDead, limit)
+}
+
+// GetWebhookOutboxStats returns the number of pending and dead-lettered deliveries.
+func GetWebhookOutboxStats() (WebhookOutboxStats, error) {
+	stats := WebhookOutboxStats{MaxAttempts: config.WhatsappWebhookRetryMaxAttempts}
+	repo := getWebhookOutboxRepository()
+	if repo == nil {
+		return stats, fmt.Errorf("webhook outbox is not initialized")
+	}
+	counts, err := repo.CountWebhookOutbox()
+	if err != nil {
+		return stats, err
+	}
+	stats.Pending = counts[domainChatStorage.WebhookOutboxPending]
+	stats.Dead = counts[domainChatStorage.WebhookOutboxDead]
+	return stats, nil
+}
+
+// ReplayFailedWebhook moves an entry back to the pending queue with a fresh attempt budget.
+// The returned entry is nil when the id does not exist.
+func ReplayFailedWebhook(id int64) (*domainChatStorage.WebhookOutboxEntry, error) {
+	repo := getWebhookOutboxRepository()
+	if repo == nil {
+		return nil, fmt.Errorf("webhook outbox is not initialized")
+	}
+
+	entry, err := repo.GetWebhookOutboxEntry(id)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	entry.Status = domainChatStorage.WebhookOutboxPending
+	entry.Attempts = 0
+	entry.NextAttemptAt = time.Now()
+	if err := repo.UpdateWebhookOutbox(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,154 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+)
+
+// memoryOutboxRepo implements only the outbox part of the storage interface.
+type memoryOutboxRepo struct {
+	domainChatStorage.IChatStorageRepository
+	entries map[int64]*domainChatStorage.WebhookOutboxEntry
+	nextID  int64
+}
+
+func newMemoryOutboxRepo() *memoryOutboxRepo {
+	return &memoryOutboxRepo{entries: make(map[int64]*domainChatStorage.WebhookOutboxEntry)}
+}
+
+func (m *memoryOutboxRepo) EnqueueWebhookOutbox(entry *domainChatStorage.WebhookOutboxEntry) error {
+	m.nextID++
+	entry.ID = m.nextID
+	copied := *entry
+	m.entries[entry.ID] = &copied
+	return nil
+}
+
+func (m *memoryOutboxRepo) GetDueWebhookOutbox(now time.Time, _ int) ([]*domainChatStorage.WebhookOutboxEntry, error) {
+	var due []*domainChatStorage.WebhookOutboxEntry
+	for _, e := range m.entries {
+		if e.Status == domainChatStorage.WebhookOutboxPending && !e.NextAttemptAt.After(now) {
+			copied := *e
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryOutboxRepo) GetWebhookOutboxEntry(id int64) (*domainChatStorage.WebhookOutboxEntry, error) {
+	e, ok := m.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *e
+	return &copied, nil
+}
+
+func (m *memoryOutboxRepo) UpdateWebhookOutbox(entry *domainChatStorage.WebhookOutboxEntry) error {
+	copied := *entry
+	m.entries[entry.ID] = &copied
+	return nil
+}
+
+func (m *memoryOutboxRepo) DeleteWebhookOutbox(id int64) error {
+	delete(m.entries, id)
+	return nil
+}
+
+func withOutboxRepo(t *testing.T, repo domainChatStorage.IChatStorageRepository, maxAttempts int) {
+	t.Helper()
+	originalMax := config.WhatsappWebhookRetryMaxAttempts
+	config.WhatsappWebhookRetryMaxAttempts = maxAttempts
+	SetWebhookOutboxRepository(repo)
+	t.Cleanup(func() {
+		config.WhatsappWebhookRetryMaxAttempts = originalMax
+		SetWebhookOutboxRepository(nil)
+	})
+}
+
+func TestWebhookOutboxBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		20: time.Hour,
+	}
+	for attempts, want := range cases {
+		if got := webhookOutboxBackoff(attempts); got != want {
+			t.Fatalf("attempts %d: expected %s, got %s", attempts, want, got)
+		}
+	}
+}
+
+func TestForwardToWebhooks_EnqueuesFailedTargets(t *testing.T) {
+	repo := newMemoryOutboxRepo()
+	withOutboxRepo(t, repo, 3)
+
+	originalWebhooks := config.WhatsappWebhook
+	config.WhatsappWebhook = []string{"https://fail1", "https://fail2"}
+	defer func() { config.WhatsappWebhook = originalWebhooks }()
+
+	originalSubmit := submitWebhookFn
+	submitWebhookFn = func(context.Context, map[string]any, string) error { return errors.New("boom") }
+	defer func() { submitWebhookFn = originalSubmit }()
+
+	if err := forwardToWebhooks(context.Background(), map[string]any{"event": "message"}, "message"); err == nil {
+		t.Fatal("expected error when all webhooks fail")
+	}
+	if len(repo.entries) != 2 {
+		t.Fatalf("expected 2 queued deliveries, got %d", len(repo.entries))
+	}
+	for _, e := range repo.entries {
+		if e.Status != domainChatStorage.WebhookOutboxPending || e.Attempts != 1 || e.Payload != `{"event":"message"}` {
+			t.Fatalf("unexpected queued entry: status=%s attempts=%d payload=%s", e.Status, e.Attempts, e.Payload)
+		}
+	}
+}
+
+func TestDispatchDueWebhooks_RetriesAndDeadLetters(t *testing.T) {
+	repo := newMemoryOutboxRepo()
+	withOutboxRepo(t, repo, 3)
+
+	now := time.Now()
+	_ = repo.EnqueueWebhookOutbox(&domainChatStorage.WebhookOutboxEntry{URL: "https://ok", Payload: `{}`, Attempts: 1, Status: domainChatStorage.WebhookOutboxPending, NextAttemptAt: now})
+	_ = repo.EnqueueWebhookOutbox(&domainChatStorage.WebhookOutboxEntry{URL: "https://down", Payload: `{}`, Attempts: 1, Status: domainChatStorage.WebhookOutboxPending, NextAttemptAt: now})
+
+	originalPost := postWebhookFn
+	postWebhookFn = func(_ context.Context, _ *http.Client, url string, _ []byte) error {
+		if url == "https://down" {
+			return errors.New("webhook returned status 503")
+		}
+		return nil
+	}
+	defer func() { postWebhookFn = originalPost }()
+
+	if delivered := dispatchDueWebhooks(context.Background(), now); delivered != 1 {
+		t.Fatalf("expected 1 delivery, got %d", delivered)
+	}
+	if _, ok := repo.entries[1]; ok {
+		t.Fatal("delivered entry should be removed")
+	}
+	down := repo.entries[2]
+	if down.Attempts != 2 || down.Status != domainChatStorage.WebhookOutboxPending || !down.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected retry state: attempts=%d status=%s next=%s", down.Attempts, down.Status, down.NextAttemptAt)
+	}
+
+	dispatchDueWebhooks(context.Background(), down.NextAttemptAt)
+	if down = repo.entries[2]; down.Status != domainChatStorage.WebhookOutboxDead || down.Attempts != 3 {
+		t.Fatalf("expected entry to be dead-lettered, got status=%s attempts=%d", down.Status, down.Attempts)
+	}
+
+	replayed, err := ReplayFailedWebhook(2)
+	if err != nil || replayed == nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if got := repo.entries[2]; got.Status != domainChatStorage.WebhookOutboxPending || got.Attempts != 0 {
+		t.Fatalf("expected replayed entry to be pending with reset attempts, got status=%s attempts=%d", got.Status, got.Attempts)
+	}
+}
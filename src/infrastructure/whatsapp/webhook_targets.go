@@ -0,0 +1,116 @@
+package whatsapp
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+// webhookEventWildcard subscribes a target to every event, ignoring the global whitelist.
+const webhookEventWildcard = "*"
+
+// WebhookTarget is a webhook URL with its own event filter.
+// Events == nil means the target follows the global WhatsappWebhookEvents whitelist.
+type WebhookTarget struct {
+	URL    string
+	Events []string
+}
+
+var (
+	webhookTargetsMu sync.RWMutex
+	webhookTargets   []WebhookTarget
+)
+
+// ParseWebhookTargets parses the webhook configuration. Both the flat format
+// ("URL1,URL2") and the per-URL format ("URL1|message,message.ack;URL2|*") are
+// accepted, including the per-URL format after it was split on commas by the
+// env/flag parsers. A token containing "://" or "|" starts a new target; any
+// other token is an event for the preceding target.
+func ParseWebhookTargets(raw []string) []WebhookTarget {
+	var (
+		targets []WebhookTarget
+		current = -1
+	)
+
+	for _, entry := range raw {
+		for i, segment := range strings.Split(entry, ";") {
+			if i > 0 {
+				// An event list never continues past ";"
+				current = -1
+			}
+			for _, token := range strings.Split(segment, ",") {
+				token = strings.TrimSpace(token)
+				if token == "" {
+					continue
+				}
+
+				if url, event, ok := strings.Cut(token, "|"); ok {
+					target := WebhookTarget{URL: strings.TrimSpace(url)}
+					if event = strings.TrimSpace(event); event != "" {
+						target.Events = []string{event}
+					}
+					targets = append(targets, target)
+					current = len(targets) - 1
+					continue
+				}
+
+				if strings.Contains(token, "://") || current < 0 {
+					targets = append(targets, WebhookTarget{URL: token})
+					current = -1
+					continue
+				}
+
+				targets[current].Events = append(targets[current].Events, token)
+			}
+		}
+	}
+
+	return targets
+}
+
+// WebhookTargetURLs returns the URLs of the given targets in order.
+func WebhookTargetURLs(targets []WebhookTarget) []string {
+	urls := make([]string, 0, len(targets))
+	for _, target := range targets {
+		urls = append(urls, target.URL)
+	}
+	return urls
+}
+
+// SetWebhookTargets installs the parsed per-URL webhook configuration.
+func SetWebhookTargets(targets []WebhookTarget) {
+	webhookTargetsMu.Lock()
+	defer webhookTargetsMu.Unlock()
+	webhookTargets = targets
+}
+
+// currentWebhookTargets returns the configured targets, falling back to the flat
+// config.WhatsappWebhook list when no per-URL configuration was installed.
+func currentWebhookTargets() []WebhookTarget {
+	webhookTargetsMu.RLock()
+	targets := webhookTargets
+	webhookTargetsMu.RUnlock()
+	if targets != nil {
+		return targets
+	}
+
+	targets = make([]WebhookTarget, 0, len(config.WhatsappWebhook))
+	for _, url := range config.WhatsappWebhook {
+		targets = append(targets, WebhookTarget{URL: url})
+	}
+	return targets
+}
+
+// Accepts reports whether the target should receive the given event.
+func (t WebhookTarget) Accepts(eventName string) bool {
+	if t.Events == nil {
+		return len(config.WhatsappWebhookEvents) == 0 || isEventWhitelisted(eventName)
+	}
+	for _, allowed := range t.Events {
+		if allowed == webhookEventWildcard || strings.EqualFold(allowed, eventName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package whatsapp
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func TestParseWebhookTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []string
+		want []WebhookTarget
+	}{
+		{
+			name: "legacy flat list",
+			raw:  []string{"https://a.com/hook", " https://b.com/hook "},
+			want: []WebhookTarget{{URL: "https://a.com/hook"}, {URL: "https://b.com/hook"}},
+		},
+		{
+			name: "per-url with wildcard",
+			raw:  []string{"https://a.com/hook|message,message.ack;https://b.com/hook|*"},
+			want: []WebhookTarget{
+				{URL: "https://a.com/hook", Events: []string{"message", "message.ack"}},
+				{URL: "https://b.com/hook", Events: []string{"*"}},
+			},
+		},
+		{
+			name: "per-url split on commas by env parser",
+			raw:  []string{"https://a.com/hook|message", "message.ack;https://b.com/hook|*"},
+			want: []WebhookTarget{
+				{URL: "https://a.com/hook", Events: []string{"message", "message.ack"}},
+				{URL: "https://b.com/hook", Events: []string{"*"}},
+			},
+		},
+		{
+			name: "mixed filtered and unfiltered",
+			raw:  []string{"https://a.com/hook|message;https://c.com/hook,https://d.com/hook|group.participants"},
+			want: []WebhookTarget{
+				{URL: "https://a.com/hook", Events: []string{"message"}},
+				{URL: "https://c.com/hook"},
+				{URL: "https://d.com/hook", Events: []string{"group.participants"}},
+			},
+		},
+		{
+			name: "empty filter inherits global",
+			raw:  []string{"https://a.com/hook|;"},
+			want: []WebhookTarget{{URL: "https://a.com/hook"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseWebhookTargets(tt.raw)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestForwardToWebhooks_PerTargetFilters(t *testing.T) {
+	originalEvents := config.WhatsappWebhookEvents
+	config.WhatsappWebhookEvents = []string{"message", "group.participants"}
+	SetWebhookTargets(ParseWebhookTargets([]string{
+		"https://n8n|message;https://analytics|*;https://legacy",
+	}))
+	defer func() {
+		config.WhatsappWebhookEvents = originalEvents
+		SetWebhookTargets(nil)
+	}()
+
+	var called []string
+	originalSubmit := submitWebhookFn
+	submitWebhookFn = func(_ context.Context, _ map[string]any, url string) error {
+		called = append(called, url)
+		return nil
+	}
+	defer func() { submitWebhookFn = originalSubmit }()
+
+	cases := map[string][]string{
+		"message":            {"https://analytics", "https://legacy", "https://n8n"},
+		"message.ack":        {"https://analytics"},
+		"group.participants": {"https://analytics", "https://legacy"},
+	}
+	for event, want := range cases {
+		called = nil
+		if err := forwardPayloadToConfiguredWebhooks(context.Background(), map[string]any{}, event); err != nil {
+			t.Fatalf("%s: unexpected error %v", event, err)
+		}
+		sort.Strings(called)
+		if !reflect.DeepEqual(called, want) {
+			t.Fatalf("%s: expected %v, got %v", event, want, called)
+		}
+	}
+}
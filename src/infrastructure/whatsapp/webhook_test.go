@@ -0,0 +1,87 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+)
+
+func computeTestSignature(secret string, parts ...[]byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSubmitWebhook_SignsBodyAndTimestamp(t *testing.T) {
+	originalSecret := config.WhatsappWebhookSecret
+	config.WhatsappWebhookSecret = "super-secret-key"
+	defer func() { config.WhatsappWebhookSecret = originalSecret }()
+
+	var verified bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+
+		if got, want := r.Header.Get(webhookSignatureHeader), computeTestSignature("super-secret-key", body); got != want {
+			t.Fatalf("body signature mismatch: got %q want %q", got, want)
+		}
+
+		timestamp := r.Header.Get(webhookTimestampHeader)
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			t.Fatalf("invalid timestamp header %q: %v", timestamp, err)
+		}
+		if age := time.Since(time.Unix(ts, 0)); age < -time.Minute || age > time.Minute {
+			t.Fatalf("timestamp %d is not current", ts)
+		}
+
+		want := computeTestSignature("super-secret-key", []byte(timestamp), []byte("."), body)
+		if got := r.Header.Get(webhookTimestampSignatureHeader); got != want {
+			t.Fatalf("timestamp signature mismatch: got %q want %q", got, want)
+		}
+
+		verified = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	if err := submitWebhook(context.Background(), map[string]any{"event": "message"}, srv.URL); err != nil {
+		t.Fatalf("submitWebhook returned error: %v", err)
+	}
+	if !verified {
+		t.Fatal("expected the test server to verify the signature")
+	}
+}
+
+func TestSubmitWebhook_NoSignatureWithoutSecret(t *testing.T) {
+	originalSecret := config.WhatsappWebhookSecret
+	config.WhatsappWebhookSecret = ""
+	defer func() { config.WhatsappWebhookSecret = originalSecret }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, header := range []string{webhookSignatureHeader, webhookTimestampHeader, webhookTimestampSignatureHeader} {
+			if r.Header.Get(header) != "" {
+				t.Fatalf("expected no %s header when secret is unset", header)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	if err := submitWebhook(context.Background(), map[string]any{"event": "message"}, srv.URL); err != nil {
+		t.Fatalf("submitWebhook returned error: %v", err)
+	}
+}
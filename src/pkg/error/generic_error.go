@@ -43,6 +43,22 @@ func (e ContextError) StatusCode() int {
 	return http.StatusRequestTimeout
 }
 
+// FFmpegUnavailableError is returned when a conversion needs ffmpeg, or one of its encoders, and the
+// server does not have it.
+type FFmpegUnavailableError string
+
+func (e FFmpegUnavailableError) Error() string {
+	return string(e)
+}
+
+func (e FFmpegUnavailableError) ErrCode() string {
+	return "FFMPEG_UNAVAILABLE"
+}
+
+func (e FFmpegUnavailableError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
 // TimeoutError represents a request timeout error
 type TimeoutError string
 
@@ -70,7 +70,46 @@ func (e WaUploadMediaError) StatusCode() int {
 	return http.StatusInternalServerError
 }
 
+// EditWindowError is returned when a message is too old to be edited on WhatsApp.
+type EditWindowError string
+
+// Error for complying the error interface
+func (e EditWindowError) Error() string {
+	return string(e)
+}
+
+// ErrCode will return the error code based on the error data type
+func (e EditWindowError) ErrCode() string {
+	return "EDIT_WINDOW_EXPIRED"
+}
+
+// StatusCode will return the HTTP status code based on the error data type
+func (e EditWindowError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// RevokeWindowError is returned when a message is too old to be deleted for everyone on WhatsApp.
+type RevokeWindowError string
+
+// Error for complying the error interface
+func (e RevokeWindowError) Error() string {
+	return string(e)
+}
+
+// ErrCode will return the error code based on the error data type
+func (e RevokeWindowError) ErrCode() string {
+	return "REVOKE_WINDOW_EXPIRED"
+}
+
+// StatusCode will return the HTTP status code based on the error data type
+func (e RevokeWindowError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+const ErrRevokeWindowExpired = RevokeWindowError("the message is too old to be deleted for everyone")
+
 const (
+	ErrEditWindowExpired = EditWindowError("the message is too old to be edited")
 	ErrInvalidJID        = InvalidJID("your JID is invalid")
 	ErrUserNotRegistered = InvalidJID("user is not registered")
 	ErrWaCLI             = WaCliError("your WhatsApp CLI is invalid or empty")
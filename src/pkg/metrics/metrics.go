@@ -0,0 +1,215 @@
+// Package metrics holds the Prometheus metrics of the Chatwoot bridge and webhook delivery, served at
+// GET /metrics. Labels never carry JIDs, phone numbers or message IDs, so their number stays bounded.
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric of this package plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	chatwootAPIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "chatwoot_api_requests_total",
+		Help: "Chatwoot API requests by HTTP method, endpoint and status class.",
+	}, []string{"method", "endpoint", "status"})
+
+	chatwootAPIDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "chatwoot_api_request_duration_seconds",
+		Help:    "Chatwoot API request latency by HTTP method and endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "endpoint"})
+
+	bridgedMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "chatwoot_bridged_messages_total",
+		Help: "Messages bridged between WhatsApp and Chatwoot by direction.",
+	}, []string{"direction"})
+
+	bridgeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "chatwoot_bridge_errors_total",
+		Help: "Failures to bridge a message between WhatsApp and Chatwoot by direction.",
+	}, []string{"direction"})
+
+	chatwootWebhookEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "chatwoot_webhook_events_total",
+		Help: "Chatwoot webhook events received by event type.",
+	}, []string{"event"})
+
+	syncMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "chatwoot_sync_messages_total",
+		Help: "Messages exported by the Chatwoot history sync by result and failure reason.",
+	}, []string{"result", "reason"})
+
+	syncMediaBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "chatwoot_sync_media_bytes_total",
+		Help: "Bytes of media downloaded from WhatsApp by the Chatwoot history sync.",
+	})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Webhook deliveries by target URL (without query) and result.",
+	}, []string{"url", "result"})
+
+	forwardQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "message_forward_queue_depth",
+		Help: "Message forwards to webhooks and Chatwoot waiting for a worker.",
+	})
+
+	forwardSpills = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "message_forward_spilled_total",
+		Help: "Message forwards saved for replay because the forward queue was full.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		chatwootAPIRequests,
+		chatwootAPIDuration,
+		bridgedMessages,
+		bridgeErrors,
+		chatwootWebhookEvents,
+		syncMessages,
+		syncMediaBytes,
+		webhookDeliveries,
+		forwardQueueDepth,
+		forwardSpills,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveChatwootRequest records a Chatwoot API request. status is 0 when no response arrived.
+func ObserveChatwootRequest(method, path string, status int, duration time.Duration) {
+	endpoint := ChatwootEndpoint(path)
+	chatwootAPIRequests.WithLabelValues(method, endpoint, StatusClass(status)).Inc()
+	chatwootAPIDuration.WithLabelValues(method, endpoint).Observe(duration.Seconds())
+}
+
+// BridgedMessage counts a message bridged in direction.
+func BridgedMessage(direction string) {
+	bridgedMessages.WithLabelValues(direction).Inc()
+}
+
+// BridgeError counts a failure to bridge a message in direction.
+func BridgeError(direction string) {
+	bridgeErrors.WithLabelValues(direction).Inc()
+}
+
+// chatwootWebhookEventNames are the webhook events counted by name; others count as "other".
+var chatwootWebhookEventNames = map[string]bool{
+	"message_created":             true,
+	"message_updated":             true,
+	"message_deleted":             true,
+	"conversation_created":        true,
+	"conversation_updated":        true,
+	"conversation_status_changed": true,
+	"contact_created":             true,
+	"contact_updated":             true,
+	"webwidget_triggered":         true,
+}
+
+// ChatwootWebhookEvent counts a webhook event received from Chatwoot.
+func ChatwootWebhookEvent(event string) {
+	if !chatwootWebhookEventNames[event] {
+		event = "other"
+	}
+	chatwootWebhookEvents.WithLabelValues(event).Inc()
+}
+
+// SyncedMessage counts a message the history sync exported.
+func SyncedMessage() {
+	syncMessages.WithLabelValues("synced", "").Inc()
+}
+
+// SyncFailedMessage counts a message the history sync failed to export for reason.
+func SyncFailedMessage(reason string) {
+	if reason == "" {
+		reason = "other"
+	}
+	syncMessages.WithLabelValues("failed", reason).Inc()
+}
+
+// SyncMediaDownloaded counts media bytes downloaded by the history sync.
+func SyncMediaDownloaded(bytes int64) {
+	if bytes > 0 {
+		syncMediaBytes.Add(float64(bytes))
+	}
+}
+
+// WebhookDelivery counts a delivery to a webhook target.
+func WebhookDelivery(target string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	webhookDeliveries.WithLabelValues(WebhookLabel(target), result).Inc()
+}
+
+// ForwardQueueDepth moves the number of message forwards waiting for a worker by delta.
+func ForwardQueueDepth(delta int) {
+	forwardQueueDepth.Add(float64(delta))
+}
+
+// ForwardSpilled counts a message forward saved for replay because the queue was full.
+func ForwardSpilled() {
+	forwardSpills.Inc()
+}
+
+// StatusClass turns an HTTP status into "2xx" to "5xx", or "error" when no response arrived.
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// ChatwootEndpoint reduces a Chatwoot API path to a label: the account prefix is dropped and every
+// segment holding a digit, such as an ID or a UUID, becomes ":id". API versions such as "v1" are kept.
+// "/api/v1/accounts/3/conversations/42/messages" becomes "conversations/:id/messages".
+func ChatwootEndpoint(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) >= 4 && segments[0] == "api" && segments[2] == "accounts" {
+		segments = segments[4:]
+	}
+	for i, segment := range segments {
+		if strings.ContainsAny(segment, "0123456789") && !apiVersion(segment) {
+			segments[i] = ":id"
+		}
+	}
+	if len(segments) == 0 || (len(segments) == 1 && segments[0] == "") {
+		return "account"
+	}
+	return strings.Join(segments, "/")
+}
+
+func apiVersion(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(segment[1:])
+	return err == nil
+}
+
+// WebhookLabel keeps the scheme, host and path of a webhook URL, dropping credentials and the query,
+// which may carry tokens.
+func WebhookLabel(target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Host == "" {
+		return "invalid"
+	}
+	return parsed.Scheme + "://" + parsed.Host + parsed.Path
+}
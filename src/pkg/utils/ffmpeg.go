@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FFmpegStatus is what the server found out about ffmpeg when it started. Features that shell out
+// to ffmpeg check it instead of looking up the binary on every call.
+type FFmpegStatus struct {
+	Available bool   `json:"available"`
+	Version   string `json:"version,omitempty"`
+	Opus      bool   `json:"opus"` // libopus encoder, needed to send voice notes (PTT)
+	MP3       bool   `json:"mp3"`  // libmp3lame encoder, needed to make WhatsApp audio playable in Chatwoot
+}
+
+var (
+	ffmpegOnce   sync.Once
+	ffmpegStatus FFmpegStatus
+)
+
+// DetectFFmpeg looks for ffmpeg and its audio encoders on the first call and returns the same
+// result afterwards.
+func DetectFFmpeg() FFmpegStatus {
+	ffmpegOnce.Do(func() {
+		ffmpegStatus = detectFFmpeg(exec.LookPath, func(ctx context.Context, path string, args ...string) ([]byte, error) {
+			return exec.CommandContext(ctx, path, args...).Output()
+		})
+	})
+	return ffmpegStatus
+}
+
+func detectFFmpeg(lookPath func(string) (string, error), run func(ctx context.Context, path string, args ...string) ([]byte, error)) FFmpegStatus {
+	path, err := lookPath("ffmpeg")
+	if err != nil {
+		return FFmpegStatus{}
+	}
+	status := FFmpegStatus{Available: true}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if out, err := run(ctx, path, "-hide_banner", "-version"); err == nil {
+		status.Version = parseFFmpegVersion(string(out))
+	}
+	if out, err := run(ctx, path, "-hide_banner", "-encoders"); err == nil {
+		status.Opus, status.MP3 = parseFFmpegEncoders(string(out))
+	}
+	return status
+}
+
+// parseFFmpegVersion reads the version from the first line of "ffmpeg -version":
+// "ffmpeg version 6.1.1-3ubuntu5 Copyright ...".
+func parseFFmpegVersion(output string) string {
+	line, _, _ := strings.Cut(output, "\n")
+	fields := strings.Fields(line)
+	if len(fields) >= 3 && fields[0] == "ffmpeg" && fields[1] == "version" {
+		return fields[2]
+	}
+	return ""
+}
+
+// parseFFmpegEncoders reports whether "ffmpeg -encoders" lists libopus and libmp3lame. Each
+// encoder line is " A....D libopus    libopus Opus".
+func parseFFmpegEncoders(output string) (opus, mp3 bool) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "A") {
+			continue
+		}
+		switch fields[1] {
+		case "libopus":
+			opus = true
+		case "libmp3lame":
+			mp3 = true
+		}
+	}
+	return opus, mp3
+}
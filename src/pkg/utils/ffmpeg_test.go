@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const ffmpegEncodersOutput = `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC
+ A....D aac                  AAC (Advanced Audio Coding)
+ A....D libmp3lame           libmp3lame MP3 (MPEG audio layer 3)
+ A....D libopus              libopus Opus
+`
+
+func TestDetectFFmpeg(t *testing.T) {
+	missing := detectFFmpeg(func(string) (string, error) { return "", errors.New("not found") }, nil)
+	if missing != (FFmpegStatus{}) {
+		t.Fatalf("expected nothing available, got %+v", missing)
+	}
+
+	run := func(_ context.Context, _ string, args ...string) ([]byte, error) {
+		switch args[len(args)-1] {
+		case "-version":
+			return []byte("ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13"), nil
+		case "-encoders":
+			return []byte(ffmpegEncodersOutput), nil
+		}
+		return nil, errors.New("unexpected arguments")
+	}
+	got := detectFFmpeg(func(string) (string, error) { return "/usr/bin/ffmpeg", nil }, run)
+	want := FFmpegStatus{Available: true, Version: "6.1.1-3ubuntu5", Opus: true, MP3: true}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseFFmpegEncoders_WithoutLibopus(t *testing.T) {
+	output := strings.Replace(ffmpegEncodersOutput, " A....D libopus              libopus Opus\n", " A....D opus                 Opus\n", 1)
+	opus, mp3 := parseFFmpegEncoders(output)
+	if opus || !mp3 {
+		t.Fatalf("expected only libmp3lame, got opus=%v mp3=%v", opus, mp3)
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+var reGeoURI = regexp.MustCompile(`geo:(-?\d+(?:\.\d+)?),(-?\d+(?:\.\d+)?)(?:;u=(\d+))?`)
+
+// LocationGeoURI formats coordinates as an RFC 5870 geo URI. A non-zero accuracy is added as
+// the uncertainty in meters.
+func LocationGeoURI(latitude, longitude float64, accuracyMeters uint32) string {
+	uri := "geo:" + strconv.FormatFloat(latitude, 'f', 6, 64) + "," + strconv.FormatFloat(longitude, 'f', 6, 64)
+	if accuracyMeters > 0 {
+		uri += ";u=" + strconv.FormatUint(uint64(accuracyMeters), 10)
+	}
+	return uri
+}
+
+// GeoURIMatch is a geo URI found in text by FindGeoURIs.
+type GeoURIMatch struct {
+	URI            string
+	Latitude       float64
+	Longitude      float64
+	AccuracyMeters uint32
+}
+
+// FindGeoURIs returns the geo URIs in text, in order.
+func FindGeoURIs(text string) []GeoURIMatch {
+	var matches []GeoURIMatch
+	for _, m := range reGeoURI.FindAllStringSubmatch(text, -1) {
+		lat, errLat := strconv.ParseFloat(m[1], 64)
+		lon, errLon := strconv.ParseFloat(m[2], 64)
+		if errLat != nil || errLon != nil {
+			continue
+		}
+		match := GeoURIMatch{URI: m[0], Latitude: lat, Longitude: lon}
+		if m[3] != "" {
+			if u, err := strconv.ParseUint(m[3], 10, 32); err == nil {
+				match.AccuracyMeters = uint32(u)
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// locationStorageText renders a location message for chat storage. The geo URI keeps the
+// coordinates so the Chatwoot sync can turn them into a map link later.
+func locationStorageText(msg *waE2E.Message) string {
+	if loc := msg.GetLocationMessage(); loc != nil {
+		label := strings.Join(nonEmpty(loc.GetName(), loc.GetAddress()), ", ")
+		return strings.Join(nonEmpty("📍", label, LocationGeoURI(loc.GetDegreesLatitude(), loc.GetDegreesLongitude(), loc.GetAccuracyInMeters())), " ")
+	}
+	if live := msg.GetLiveLocationMessage(); live != nil {
+		return strings.Join(nonEmpty("📍 Live location:", live.GetCaption(), LocationGeoURI(live.GetDegreesLatitude(), live.GetDegreesLongitude(), live.GetAccuracyInMeters())), " ")
+	}
+	return ""
+}
+
+func nonEmpty(values ...string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExtractMessageTextFromProto_Location(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *waE2E.Message
+		want string
+	}{
+		{
+			name: "StaticWithName",
+			msg: &waE2E.Message{LocationMessage: &waE2E.LocationMessage{
+				DegreesLatitude:  proto.Float64(-23.561414),
+				DegreesLongitude: proto.Float64(-46.655881),
+				Name:             proto.String("MASP"),
+				Address:          proto.String("Av. Paulista, 1578"),
+			}},
+			want: "📍 MASP, Av. Paulista, 1578 geo:-23.561414,-46.655881",
+		},
+		{
+			name: "LiveWithAccuracy",
+			msg: &waE2E.Message{LiveLocationMessage: &waE2E.LiveLocationMessage{
+				DegreesLatitude:  proto.Float64(51.5),
+				DegreesLongitude: proto.Float64(-0.12),
+				AccuracyInMeters: proto.Uint32(15),
+				Caption:          proto.String("On my way"),
+			}},
+			want: "📍 Live location: On my way geo:51.500000,-0.120000;u=15",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractMessageTextFromProto(tt.msg); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindGeoURIs(t *testing.T) {
+	matches := FindGeoURIs("📍 Live location: geo:51.500000,-0.120000;u=15 and geo:-1,2")
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %+v", matches)
+	}
+	if m := matches[0]; m.Latitude != 51.5 || m.Longitude != -0.12 || m.AccuracyMeters != 15 {
+		t.Fatalf("unexpected first match %+v", m)
+	}
+	if m := matches[1]; m.URI != "geo:-1,2" || m.AccuracyMeters != 0 {
+		t.Fatalf("unexpected second match %+v", m)
+	}
+}
@@ -1,36 +1,190 @@
 package utils
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
+)
 
 // NormalizePhoneE164 ensures phone has + prefix for E.164 format.
 // Strips WhatsApp JID suffixes (@s.whatsapp.net, @lid, etc.) before formatting.
-// Returns empty string if input is empty.
+// Returns empty string if input is empty or a newsletter JID.
 func NormalizePhoneE164(phone string) string {
-	phone = strings.TrimSpace(phone)
+	phone = ExtractPhoneFromJID(strings.TrimSpace(phone))
 	if phone == "" {
 		return phone
 	}
-	phone = ExtractPhoneFromJID(phone)
 	if !strings.HasPrefix(phone, "+") {
 		return "+" + phone
 	}
 	return phone
 }
 
+// CanonicalPhoneE164 is NormalizePhoneE164 for the form of the number WhatsApp registers, see
+// PhoneVariants. Returns empty string if input has no digits.
+func CanonicalPhoneE164(phone string) string {
+	canonical, _ := PhoneVariants(phone)
+	if canonical == "" {
+		return ""
+	}
+	return "+" + canonical
+}
+
 // StripPhonePrefix removes + prefix from phone number.
 func StripPhonePrefix(phone string) string {
 	return strings.TrimPrefix(strings.TrimSpace(phone), "+")
 }
 
 // ExtractPhoneFromJID extracts phone number from JID by stripping the domain part.
-// For example, "1234567890@s.whatsapp.net" becomes "1234567890".
+// For example, "1234567890@s.whatsapp.net" becomes "1234567890". Channels have no phone number, so
+// a newsletter JID gives "".
 func ExtractPhoneFromJID(jid string) string {
+	if IsNewsletterJID(jid) {
+		return ""
+	}
 	return strings.Split(jid, "@")[0]
 }
 
-// CleanPhoneForWhatsApp prepares a phone number for WhatsApp sending.
-// Removes + prefix and trims whitespace.
-func CleanPhoneForWhatsApp(phone string) string {
-	phone = strings.ReplaceAll(phone, "+", "")
-	return strings.TrimSpace(phone)
+// minPhoneNumberLength is the fewest digits, country code included, of a number outside
+// phoneCountries.
+const minPhoneNumberLength = 7
+
+// phoneCountry holds the length a national number has after a country code, and whether the country
+// writes a trunk 0 before the area code that is dropped in international form.
+type phoneCountry struct {
+	code        string
+	minNational int
+	maxNational int
+	trunkZero   bool
+}
+
+// phoneCountries are the countries whose numbers are checked for length. Other numbers only have to
+// fit E.164.
+var phoneCountries = []phoneCountry{
+	{code: "351", minNational: 9, maxNational: 9},                   // Portugal
+	{code: "52", minNational: 10, maxNational: 11},                  // Mexico, mobiles may keep the old 1 prefix
+	{code: "54", minNational: 10, maxNational: 11, trunkZero: true}, // Argentina, mobiles take a 9 prefix
+	{code: "55", minNational: 10, maxNational: 11, trunkZero: true}, // Brazil
+	{code: "1", minNational: 10, maxNational: 10},                   // United States, Canada and the rest of NANP
+}
+
+// CleanPhoneForWhatsApp prepares a phone number for WhatsApp sending: it returns the digits of the
+// number with its country code. Spaces, dashes, dots, slashes and brackets are dropped, as are an
+// 00 international prefix, a trunk 0 before the area code and a country code written twice. Numbers
+// with letters or an extension, or whose length does not fit their country, are rejected with a
+// ValidationError.
+func CleanPhoneForWhatsApp(phone string) (string, error) {
+	raw := strings.TrimSpace(phone)
+	if raw == "" {
+		return "", pkgError.ValidationError("phone number is empty")
+	}
+	lower := strings.ToLower(raw)
+	if strings.Contains(lower, "ext") || strings.ContainsAny(lower, "x#;,") {
+		return "", pkgError.ValidationError(fmt.Sprintf("phone number %q has an extension, which WhatsApp cannot dial", raw))
+	}
+
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && digits.Len() == 0:
+		case strings.ContainsRune(" -.()/\u00a0", r):
+		case unicode.IsLetter(r):
+			return "", pkgError.ValidationError(fmt.Sprintf("phone number %q contains letters", raw))
+		default:
+			return "", pkgError.ValidationError(fmt.Sprintf("phone number %q contains %q", raw, r))
+		}
+	}
+	number := digits.String()
+	if !strings.HasPrefix(strings.TrimLeft(raw, "( "), "+") {
+		number = strings.TrimPrefix(number, "00")
+	}
+
+	for _, country := range phoneCountries {
+		if !strings.HasPrefix(number, country.code) {
+			continue
+		}
+		national := strings.TrimPrefix(number, country.code)
+		fits := func(n string) bool { return len(n) >= country.minNational && len(n) <= country.maxNational }
+		if !fits(national) && strings.HasPrefix(national, country.code) && fits(strings.TrimPrefix(national, country.code)) {
+			national = strings.TrimPrefix(national, country.code)
+		}
+		if country.trunkZero && strings.HasPrefix(national, "0") {
+			national = national[1:]
+		}
+		if !fits(national) {
+			expected := fmt.Sprintf("%d", country.minNational)
+			if country.maxNational != country.minNational {
+				expected = fmt.Sprintf("%d or %d", country.minNational, country.maxNational)
+			}
+			return "", pkgError.ValidationError(fmt.Sprintf("phone number %q has %d digits after country code +%s, expected %s", raw, len(national), country.code, expected))
+		}
+		return country.code + national, nil
+	}
+
+	if len(number) < minPhoneNumberLength || len(number) > maxPhoneNumberLength {
+		return "", pkgError.ValidationError(fmt.Sprintf("phone number %q has %d digits, expected %d to %d with the country code", raw, len(number), minPhoneNumberLength, maxPhoneNumberLength))
+	}
+	return number, nil
+}
+
+// phoneVariantRules hold, per country code, how to tell the form WhatsApp registers a national
+// number in from its other form. Only the countries in WhatsappPhoneVariantCountries are applied.
+var phoneVariantRules = map[string]func(national string) (canonical, alternate string){
+	"55": brazilianPhoneVariants,
+}
+
+// PhoneVariants returns the digits of a phone number or user JID in the form WhatsApp registers it
+// and, for countries whose numbers also circulate in another form, that form; alternate is "" when
+// there is none. The rules of the country codes in WhatsappPhoneVariantCountries are applied.
+func PhoneVariants(phone string) (canonical, alternate string) {
+	var digits strings.Builder
+	for _, r := range ExtractPhoneFromJID(strings.TrimSpace(phone)) {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	canonical = digits.String()
+	for _, country := range config.WhatsappPhoneVariantCountries {
+		country = strings.TrimPrefix(strings.TrimSpace(country), "+")
+		rule, ok := phoneVariantRules[country]
+		if !ok || country == "" || !strings.HasPrefix(canonical, country) {
+			continue
+		}
+		national, other := rule(strings.TrimPrefix(canonical, country))
+		if other == "" {
+			return country + national, ""
+		}
+		return country + national, country + other
+	}
+	return canonical, ""
+}
+
+// brazilianPhoneVariants handles the ninth digit added to Brazilian mobile numbers: a mobile is
+// dialled as DDD + 9 + eight digits, but WhatsApp kept the eight-digit form for accounts of area codes
+// 31 and up whose number starts with 7, 8 or 9. Landlines and other numbers have no variant.
+func brazilianPhoneVariants(national string) (canonical, alternate string) {
+	if (len(national) != 10 && len(national) != 11) || national[0] == '0' || national[1] == '0' {
+		return national, ""
+	}
+	ddd, subscriber := national[:2], national[2:]
+	if len(subscriber) == 9 {
+		if subscriber[0] != '9' || subscriber[1] < '6' {
+			return national, ""
+		}
+		subscriber = subscriber[1:]
+	} else if subscriber[0] < '6' {
+		// Landline
+		return national, ""
+	}
+
+	withNine, withoutNine := ddd+"9"+subscriber, ddd+subscriber
+	if ddd >= "31" && subscriber[0] >= '7' {
+		return withoutNine, withNine
+	}
+	return withNine, withoutNine
 }
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
+)
+
+func TestExtractPhoneFromJID_LeavesChannelsWithoutNumber(t *testing.T) {
+	tests := map[string]string{
+		"628123456789@s.whatsapp.net":   "628123456789",
+		"120363000000000101@g.us":       "120363000000000101",
+		"120363144038483540@newsletter": "",
+	}
+	for jid, want := range tests {
+		if got := ExtractPhoneFromJID(jid); got != want {
+			t.Errorf("ExtractPhoneFromJID(%q) = %q, want %q", jid, got, want)
+		}
+	}
+	if got := NormalizePhoneE164("120363144038483540@newsletter"); got != "" {
+		t.Errorf("expected no E.164 number for a channel, got %q", got)
+	}
+}
+
+func TestPhoneVariants(t *testing.T) {
+	tests := []struct {
+		name      string
+		phone     string
+		canonical string
+		alternate string
+	}{
+		{"sao paulo mobile keeps the ninth digit", "5511987654321", "5511987654321", "551187654321"},
+		{"sao paulo legacy mobile gains the ninth digit", "551187654321", "5511987654321", "551187654321"},
+		{"espirito santo is the last area code with the ninth digit", "+55 28 98765-4321", "5528987654321", "552887654321"},
+		{"belo horizonte mobile drops the ninth digit", "5531987654321", "553187654321", "5531987654321"},
+		{"belo horizonte legacy mobile stays", "553187654321@s.whatsapp.net", "553187654321", "5531987654321"},
+		{"belo horizonte mobile starting with 6 keeps the ninth digit", "5531961234567", "5531961234567", "553161234567"},
+		{"recent range without legacy form", "5531951234567", "5531951234567", ""},
+		{"landline", "551133334444", "551133334444", ""},
+		{"too short", "55119876", "55119876", ""},
+		{"other country", "14155552671", "14155552671", ""},
+		{"empty", "", "", ""},
+		{"channel has no phone number", "120363144038483540@newsletter", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canonical, alternate := PhoneVariants(tt.phone)
+			if canonical != tt.canonical || alternate != tt.alternate {
+				t.Fatalf("PhoneVariants(%q) = %q, %q; want %q, %q", tt.phone, canonical, alternate, tt.canonical, tt.alternate)
+			}
+		})
+	}
+}
+
+func TestPhoneVariants_OnlyConfiguredCountries(t *testing.T) {
+	orig := config.WhatsappPhoneVariantCountries
+	config.WhatsappPhoneVariantCountries = nil
+	t.Cleanup(func() { config.WhatsappPhoneVariantCountries = orig })
+
+	if canonical, alternate := PhoneVariants("5531987654321"); canonical != "5531987654321" || alternate != "" {
+		t.Fatalf("expected the number unchanged without configured countries, got %q %q", canonical, alternate)
+	}
+	if got := CanonicalPhoneE164("5531987654321@s.whatsapp.net"); got != "+5531987654321" {
+		t.Fatalf("CanonicalPhoneE164 = %q", got)
+	}
+}
+
+func TestCleanPhoneForWhatsApp(t *testing.T) {
+	tests := []struct {
+		name    string
+		phone   string
+		want    string
+		wantErr string
+	}{
+		// Brazil
+		{"BR mobile formatted", "+55 (11) 98765-4321", "5511987654321", ""},
+		{"BR landline", "+55 11 3333-4444", "551133334444", ""},
+		{"BR digits only", "5511987654321", "5511987654321", ""},
+		{"BR trunk zero", "+55 (011) 98765-4321", "5511987654321", ""},
+		{"BR trunk zero without brackets", "55 0 31 8765 4321", "553187654321", ""},
+		{"BR international prefix", "0055 11 98765 4321", "5511987654321", ""},
+		{"BR doubled country code", "+55 55 11 98765-4321", "5511987654321", ""},
+		{"BR doubled country code digits", "555511987654321", "5511987654321", ""},
+		{"BR dots", "55.11.98765.4321", "5511987654321", ""},
+		{"BR too short", "+55 11 9876-543", "", "after country code +55, expected 10 or 11"},
+		{"BR too long", "+55 11 98765-43210", "", "after country code +55, expected 10 or 11"},
+
+		// United States
+		{"US formatted", "+1 (415) 555-2671", "14155552671", ""},
+		{"US dashes", "1-415-555-2671", "14155552671", ""},
+		{"US slashes", "+1 415/555/2671", "14155552671", ""},
+		{"US doubled country code", "+1 1 415 555 2671", "14155552671", ""},
+		{"US extension", "+1 415-555-2671 ext. 12", "", "has an extension"},
+		{"US extension with x", "+1 415-555-2671 x12", "", "has an extension"},
+		{"US extension with hash", "+1 415-555-2671#12", "", "has an extension"},
+		{"US too short", "+1 415 555 267", "", "after country code +1, expected 10"},
+
+		// Mexico
+		{"MX mobile", "+52 55 1234 5678", "525512345678", ""},
+		{"MX mobile with the old 1 prefix", "+52 1 55 1234 5678", "5215512345678", ""},
+		{"MX international prefix", "00 52 33 1234 5678", "523312345678", ""},
+		{"MX too short", "+52 55 1234 567", "", "after country code +52"},
+
+		// Argentina
+		{"AR mobile", "+54 9 11 1234-5678", "5491112345678", ""},
+		{"AR landline", "+54 11 4123-4567", "541141234567", ""},
+		{"AR trunk zero", "+54 (011) 4123-4567", "541141234567", ""},
+		{"AR doubled country code", "54 54 9 11 1234 5678", "5491112345678", ""},
+		{"AR too long", "+54 9 11 15 1234 5678", "", "after country code +54"},
+
+		// Portugal
+		{"PT mobile", "+351 912 345 678", "351912345678", ""},
+		{"PT landline", "+351 21 123 4567", "351211234567", ""},
+		{"PT international prefix", "00351 912345678", "351912345678", ""},
+		{"PT doubled country code", "+351 351 912 345 678", "351912345678", ""},
+		{"PT too short", "+351 91 234 567", "", "after country code +351, expected 9"},
+
+		// Anything else
+		{"other country", "+44 20 7946 0958", "442079460958", ""},
+		{"letters", "+55 11 ABCD-4321", "", "contains letters"},
+		{"symbols", "+55 11 98765*4321", "", "contains '*'"},
+		{"plus in the middle", "55+11987654321", "", "contains '+'"},
+		{"too short overall", "987654", "", "expected 7 to 15"},
+		{"too long overall", "9912345678901234", "", "expected 7 to 15"},
+		{"empty", "   ", "", "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CleanPhoneForWhatsApp(tt.phone)
+			if tt.wantErr != "" {
+				var validationErr pkgError.ValidationError
+				if err == nil || !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CleanPhoneForWhatsApp(%q) = %q, %v; want a validation error containing %q", tt.phone, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("CleanPhoneForWhatsApp(%q) = %q, %v; want %q", tt.phone, got, err, tt.want)
+			}
+		})
+	}
+}
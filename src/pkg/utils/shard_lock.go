@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LockWaitBuckets are the upper bounds of the lock wait histogram; the last bucket is "+Inf".
+var LockWaitBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// ShardedLock is a fixed set of mutexes selected by key hash. It records wait
+// time per shard and warns when a waiter is blocked longer than warnAfter,
+// naming both the waiter and the current holder.
+type ShardedLock struct {
+	name      string
+	warnAfter time.Duration
+	shards    []*lockShard
+}
+
+type lockShard struct {
+	ch chan struct{}
+
+	mu           sync.Mutex
+	holderKey    string
+	holderOp     string
+	heldSince    time.Time
+	acquisitions uint64
+	contended    uint64
+	totalWait    time.Duration
+	maxWait      time.Duration
+	buckets      []uint64
+}
+
+// ShardLockStats is a snapshot of one shard's counters.
+type ShardLockStats struct {
+	Lock         string            `json:"lock"`
+	Shard        int               `json:"shard"`
+	Acquisitions uint64            `json:"acquisitions"`
+	Contended    uint64            `json:"contended"`
+	TotalWaitMs  int64             `json:"total_wait_ms"`
+	MaxWaitMs    int64             `json:"max_wait_ms"`
+	Histogram    map[string]uint64 `json:"wait_histogram"`
+	HolderKey    string            `json:"holder_key,omitempty"`
+	HolderOp     string            `json:"holder_op,omitempty"`
+	HeldForMs    int64             `json:"held_for_ms,omitempty"`
+}
+
+var shardedLocks = struct {
+	mu    sync.Mutex
+	locks []*ShardedLock
+}{}
+
+// NewShardedLock creates and registers a sharded lock. warnAfter <= 0 disables the wait warning.
+func NewShardedLock(name string, shards int, warnAfter time.Duration) *ShardedLock {
+	if shards <= 0 {
+		shards = 1
+	}
+	l := &ShardedLock{name: name, warnAfter: warnAfter, shards: make([]*lockShard, shards)}
+	for i := range l.shards {
+		s := &lockShard{ch: make(chan struct{}, 1), buckets: make([]uint64, len(LockWaitBuckets)+1)}
+		s.ch <- struct{}{}
+		l.shards[i] = s
+	}
+
+	shardedLocks.mu.Lock()
+	shardedLocks.locks = append(shardedLocks.locks, l)
+	shardedLocks.mu.Unlock()
+	return l
+}
+
+// ShardIndex maps key to one of shards slots. The same key always gets the same slot.
+func ShardIndex(key string, shards int) int {
+	if shards <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Lock acquires the shard for key and returns its unlock function. op names the
+// caller in wait warnings and in the debug stats.
+func (l *ShardedLock) Lock(key, op string) func() {
+	idx := ShardIndex(key, len(l.shards))
+	s := l.shards[idx]
+
+	start := time.Now()
+	contended := false
+	select {
+	case <-s.ch:
+	default:
+		contended = true
+		l.wait(s, idx, key, op, start)
+	}
+	wait := time.Since(start)
+
+	s.mu.Lock()
+	s.acquisitions++
+	if contended {
+		s.contended++
+	}
+	s.totalWait += wait
+	if wait > s.maxWait {
+		s.maxWait = wait
+	}
+	s.buckets[lockWaitBucket(wait)]++
+	s.holderKey = key
+	s.holderOp = op
+	s.heldSince = time.Now()
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		s.holderKey = ""
+		s.holderOp = ""
+		s.heldSince = time.Time{}
+		s.mu.Unlock()
+		s.ch <- struct{}{}
+	}
+}
+
+func (l *ShardedLock) wait(s *lockShard, idx int, key, op string, start time.Time) {
+	if l.warnAfter <= 0 {
+		<-s.ch
+		return
+	}
+
+	timer := time.NewTimer(l.warnAfter)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.ch:
+			return
+		case <-timer.C:
+			s.mu.Lock()
+			holderKey, holderOp, heldSince := s.holderKey, s.holderOp, s.heldSince
+			s.mu.Unlock()
+			heldFor := "unknown"
+			if !heldSince.IsZero() {
+				heldFor = time.Since(heldSince).Round(time.Millisecond).String()
+			}
+			logrus.Warnf("Lock %s shard %d: %s (%s) waiting %s; held by %s (%s) for %s",
+				l.name, idx, op, key, time.Since(start).Round(time.Millisecond), holderOp, holderKey, heldFor)
+			timer.Reset(l.warnAfter)
+		}
+	}
+}
+
+func lockWaitBucket(wait time.Duration) int {
+	for i, bound := range LockWaitBuckets {
+		if wait <= bound {
+			return i
+		}
+	}
+	return len(LockWaitBuckets)
+}
+
+func lockWaitBucketLabel(i int) string {
+	if i >= len(LockWaitBuckets) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("le_%s", LockWaitBuckets[i])
+}
+
+// Stats returns a snapshot of every shard of the lock.
+func (l *ShardedLock) Stats() []ShardLockStats {
+	now := time.Now()
+	stats := make([]ShardLockStats, 0, len(l.shards))
+	for i, s := range l.shards {
+		s.mu.Lock()
+		st := ShardLockStats{
+			Lock:         l.name,
+			Shard:        i,
+			Acquisitions: s.acquisitions,
+			Contended:    s.contended,
+			TotalWaitMs:  s.totalWait.Milliseconds(),
+			MaxWaitMs:    s.maxWait.Milliseconds(),
+			Histogram:    make(map[string]uint64, len(s.buckets)),
+			HolderKey:    s.holderKey,
+			HolderOp:     s.holderOp,
+		}
+		if !s.heldSince.IsZero() {
+			st.HeldForMs = now.Sub(s.heldSince).Milliseconds()
+		}
+		for b, count := range s.buckets {
+			st.Histogram[lockWaitBucketLabel(b)] = count
+		}
+		s.mu.Unlock()
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// WorstLockShards returns up to limit shards across all registered locks, ordered by
+// total wait time and then by contention. Shards that never waited are skipped.
+func WorstLockShards(limit int) []ShardLockStats {
+	shardedLocks.mu.Lock()
+	locks := append([]*ShardedLock(nil), shardedLocks.locks...)
+	shardedLocks.mu.Unlock()
+
+	var all []ShardLockStats
+	for _, l := range locks {
+		for _, st := range l.Stats() {
+			if st.Contended > 0 || st.HolderKey != "" {
+				all = append(all, st)
+			}
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].TotalWaitMs != all[j].TotalWaitMs {
+			return all[i].TotalWaitMs > all[j].TotalWaitMs
+		}
+		return all[i].Contended > all[j].Contended
+	})
+	if limit > 0 && len(all) > limit {
+		all = all[:limit]
+	}
+	return all
+}
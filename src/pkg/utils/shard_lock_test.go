@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestShardedLock_RecordsContention(t *testing.T) {
+	l := NewShardedLock("test.contention", 1, 0)
+
+	unlock := l.Lock("a", "first")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.Lock("b", "second")()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	stats := l.Stats()
+	if stats[0].HolderOp != "first" || stats[0].HolderKey != "a" {
+		t.Fatalf("expected holder first/a, got %s/%s", stats[0].HolderOp, stats[0].HolderKey)
+	}
+	unlock()
+	wg.Wait()
+
+	stats = l.Stats()
+	if stats[0].Acquisitions != 2 || stats[0].Contended != 1 {
+		t.Fatalf("expected 2 acquisitions and 1 contended, got %d/%d", stats[0].Acquisitions, stats[0].Contended)
+	}
+	if stats[0].HolderOp != "" {
+		t.Fatalf("expected shard to be released, held by %s", stats[0].HolderOp)
+	}
+	var total uint64
+	for _, count := range stats[0].Histogram {
+		total += count
+	}
+	if total != 2 || stats[0].Histogram["+Inf"] != 0 {
+		t.Fatalf("unexpected histogram: %v", stats[0].Histogram)
+	}
+}
+
+func TestShardedLock_WarnsWithHolderAfterDeadline(t *testing.T) {
+	var buf bytes.Buffer
+	logrus.SetOutput(&buf)
+	defer logrus.SetOutput(os.Stderr)
+
+	l := NewShardedLock("test.deadline", 1, 10*time.Millisecond)
+	unlock := l.Lock("holder-key", "holderOp")
+
+	done := make(chan struct{})
+	go func() {
+		l.Lock("waiter-key", "waiterOp")()
+		close(done)
+	}()
+
+	time.Sleep(40 * time.Millisecond)
+	unlock()
+	<-done
+
+	out := buf.String()
+	for _, want := range []string{"test.deadline", "waiterOp (waiter-key)", "held by holderOp (holder-key)"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected warning to contain %q, got %q", want, out)
+		}
+	}
+}
+
+func TestWorstLockShards_OrdersByWait(t *testing.T) {
+	l := NewShardedLock("test.worst", 1, 0)
+	unlock := l.Lock("x", "hold")
+	go func() {
+		time.Sleep(15 * time.Millisecond)
+		unlock()
+	}()
+	l.Lock("y", "wait")()
+
+	worst := WorstLockShards(0)
+	for i := 1; i < len(worst); i++ {
+		if worst[i].TotalWaitMs > worst[i-1].TotalWaitMs {
+			t.Fatalf("shards not ordered by wait: %dms before %dms", worst[i-1].TotalWaitMs, worst[i].TotalWaitMs)
+		}
+	}
+	for _, st := range worst {
+		if st.Lock == "test.worst" {
+			if st.TotalWaitMs < 10 || st.Contended != 1 {
+				t.Fatalf("unexpected stats for test.worst: %dms, contended %d", st.TotalWaitMs, st.Contended)
+			}
+			return
+		}
+	}
+	t.Fatal("expected test.worst in the worst shards")
+}
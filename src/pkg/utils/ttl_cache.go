@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// TTLCache is a size-bounded LRU cache whose entries also expire after a fixed TTL.
+// When full, the least recently used entry is evicted. Expired entries are removed when
+// read and by Sweep.
+type TTLCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	order      *list.List // front = most recently used
+	items      map[K]*list.Element
+}
+
+type ttlCacheEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// NewTTLCache creates a cache holding at most maxEntries entries (minimum 1) for ttl each.
+func NewTTLCache[K comparable, V any](maxEntries int, ttl time.Duration) *TTLCache[K, V] {
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	return &TTLCache[K, V]{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		items:      make(map[K]*list.Element),
+	}
+}
+
+// Get returns the value for key if it is present and not expired.
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*ttlCacheEntry[K, V])
+	if !time.Now().Before(entry.expiresAt) {
+		c.removeElement(el)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// Set stores value for key with a fresh TTL, evicting the least recently used entry when full.
+func (c *TTLCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*ttlCacheEntry[K, V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&ttlCacheEntry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Delete removes key and reports whether it was present.
+func (c *TTLCache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if ok {
+		c.removeElement(el)
+	}
+	return ok
+}
+
+// Purge removes every entry and returns how many there were.
+func (c *TTLCache[K, V]) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.order.Len()
+	c.order.Init()
+	c.items = make(map[K]*list.Element)
+	return n
+}
+
+// Sweep removes expired entries and returns how many were removed.
+func (c *TTLCache[K, V]) Sweep() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for el := c.order.Back(); el != nil; {
+		prev := el.Prev()
+		if !now.Before(el.Value.(*ttlCacheEntry[K, V]).expiresAt) {
+			c.removeElement(el)
+			removed++
+		}
+		el = prev
+	}
+	return removed
+}
+
+// Len returns the number of entries, including expired ones not yet swept.
+func (c *TTLCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// StartSweeping runs Sweep every interval in the background for the life of the process.
+func (c *TTLCache[K, V]) StartSweeping(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.Sweep()
+		}
+	}()
+}
+
+func (c *TTLCache[K, V]) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*ttlCacheEntry[K, V]).key)
+}
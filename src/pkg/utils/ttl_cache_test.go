@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTTLCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewTTLCache[string, int](2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected b to be evicted as least recently used")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1, got %d %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestTTLCacheExpiresEntries(t *testing.T) {
+	c := NewTTLCache[string, string](10, 20*time.Millisecond)
+	c.Set("a", "x")
+	c.Set("b", "y")
+	time.Sleep(30 * time.Millisecond)
+	c.Set("c", "z")
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected a to be expired")
+	}
+	if removed := c.Sweep(); removed != 1 {
+		t.Fatalf("expected sweep to remove 1 expired entry, removed %d", removed)
+	}
+	if v, ok := c.Get("c"); !ok || v != "z" {
+		t.Fatalf("expected c=z, got %q %v", v, ok)
+	}
+}
+
+func TestTTLCacheDeleteAndPurge(t *testing.T) {
+	c := NewTTLCache[string, int](10, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	if !c.Delete("a") || c.Delete("a") {
+		t.Fatal("expected Delete to report presence once")
+	}
+	if n := c.Purge(); n != 1 {
+		t.Fatalf("expected purge to drop 1 entry, dropped %d", n)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected cache to be empty after purge")
+	}
+}
+
+func TestTTLCacheConcurrentAccess(t *testing.T) {
+	const size = 50
+	c := NewTTLCache[string, int](size, time.Minute)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("k%d", (g*31+i)%200)
+				c.Set(key, i)
+				c.Get(key)
+				if i%50 == 0 {
+					c.Delete(key)
+					c.Sweep()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := c.Len(); n > size {
+		t.Fatalf("cache grew past its bound: %d > %d", n, size)
+	}
+}
@@ -0,0 +1,152 @@
+package utils
+
+import "strings"
+
+// VCardContact is the part of a vCard shown to people: the name and every phone number.
+type VCardContact struct {
+	Name   string
+	Phones []string
+}
+
+// ParseVCards reads every BEGIN:VCARD..END:VCARD block in text. Folded lines are joined and escaped
+// characters (\, \; \n \\) are decoded. A TEL without a value falls back to its waid parameter.
+func ParseVCards(text string) []VCardContact {
+	var (
+		contacts  []VCardContact
+		current   *VCardContact
+		nameFromN string
+	)
+
+	for _, line := range unfoldVCardLines(text) {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		params := strings.Split(key, ";")
+		prop := strings.ToUpper(params[0])
+		if dot := strings.LastIndex(prop, "."); dot >= 0 {
+			prop = prop[dot+1:] // item1.TEL
+		}
+
+		switch prop {
+		case "BEGIN":
+			if strings.EqualFold(strings.TrimSpace(value), "VCARD") {
+				current = &VCardContact{}
+				nameFromN = ""
+			}
+			continue
+		case "END":
+			if current != nil {
+				if current.Name == "" {
+					current.Name = nameFromN
+				}
+				contacts = append(contacts, *current)
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch prop {
+		case "FN":
+			current.Name = singleLine(unescapeVCard(value))
+		case "N":
+			nameFromN = nameFromVCardN(value)
+		case "TEL":
+			phone := strings.TrimSpace(unescapeVCard(value))
+			if phone == "" {
+				phone = vcardParam(params[1:], "waid")
+			}
+			if phone != "" {
+				current.Phones = append(current.Phones, phone)
+			}
+		}
+	}
+	return contacts
+}
+
+// unfoldVCardLines splits text into logical lines; a line starting with a space or tab continues
+// the previous one.
+func unfoldVCardLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var lines []string
+	for _, raw := range strings.Split(text, "\n") {
+		if (strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += raw[1:]
+			continue
+		}
+		if raw = strings.TrimSpace(raw); raw != "" {
+			lines = append(lines, raw)
+		}
+	}
+	return lines
+}
+
+func unescapeVCard(value string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		if escaped {
+			switch r {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteRune(r)
+			}
+			escaped = false
+			continue
+		}
+		if r == '\\' {
+			escaped = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// splitVCardValue splits a structured value on separators that are not escaped.
+func splitVCardValue(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// nameFromVCardN turns "Family;Given;Additional;Prefix;Suffix" into a display name.
+func nameFromVCardN(value string) string {
+	parts := splitVCardValue(value, ';')
+	order := []int{3, 1, 2, 0, 4}
+	var words []string
+	for _, i := range order {
+		if i < len(parts) {
+			if part := singleLine(unescapeVCard(parts[i])); part != "" {
+				words = append(words, part)
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func vcardParam(params []string, name string) string {
+	for _, param := range params {
+		if key, value, ok := strings.Cut(param, "="); ok && strings.EqualFold(key, name) {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
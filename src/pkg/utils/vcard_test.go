@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVCards_MultipleTelLines(t *testing.T) {
+	vcard := "BEGIN:VCARD\r\nVERSION:3.0\r\nN:Souza;Ana;;;\r\nFN:Ana Souza\r\n" +
+		"item1.TEL;waid=5511912345678:+55 11 91234-5678\r\nitem1.X-ABLabel:Mobile\r\n" +
+		"TEL;type=WORK:+55 11 3333-4444\r\nTEL;type=CELL;waid=5511955556666:\r\nEND:VCARD"
+
+	got := ParseVCards(vcard)
+	want := []VCardContact{{Name: "Ana Souza", Phones: []string{"+55 11 91234-5678", "+55 11 3333-4444", "5511955556666"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseVCards_EscapedAndFolded(t *testing.T) {
+	vcard := "BEGIN:VCARD\nVERSION:3.0\nFN:Silva\\, Jo\\;ão\\nJr.\nTEL:+1 415 55\n 5 0100\nEND:VCARD\n" +
+		"BEGIN:VCARD\nVERSION:3.0\nN:Doe\\, Sr.;John;Q;Dr.;\nTEL;TYPE=\"voice,cell\":+1 212 555 0199\nEND:VCARD"
+
+	got := ParseVCards(vcard)
+	want := []VCardContact{
+		{Name: "Silva, Jo;ão Jr.", Phones: []string{"+1 415 555 0100"}},
+		{Name: "Dr. John Q Doe, Sr.", Phones: []string{"+1 212 555 0199"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseVCards_IgnoresTextOutsideCards(t *testing.T) {
+	if got := ParseVCards("FN:Nobody\nTEL:123"); len(got) != 0 {
+		t.Fatalf("expected no contacts, got %+v", got)
+	}
+}
@@ -66,6 +66,15 @@ func KnownDocumentExtensionByMIME(mimeType string) (string, bool) {
 	return resolveKnownDocumentExtension(mimeType)
 }
 
+// ExtensionByMIME returns the usual extension, with its dot, of files of mimeType, or "" when the
+// type says nothing about the file.
+func ExtensionByMIME(mimeType string) string {
+	if normalized := normalizeMimeType(mimeType); normalized == "" || normalized == "application/octet-stream" {
+		return ""
+	}
+	return determineMediaExtension("", mimeType)
+}
+
 func determineMediaExtension(originalFilename, mimeType string) string {
 	if originalFilename != "" {
 		if ext := filepath.Ext(originalFilename); ext != "" {
@@ -327,6 +336,11 @@ func ExtractMessageTextFromProto(msg *waE2E.Message) string {
 		return templateButtonReply.GetSelectedDisplayText()
 	}
 
+	// Check for static or live location
+	if location := locationStorageText(msg); location != "" {
+		return location
+	}
+
 	return ""
 }
 
@@ -664,6 +678,12 @@ func IsGroupJID(jid string) bool {
 	return strings.Contains(jid, "@g.us")
 }
 
+// IsNewsletterJID reports whether the JID is a WhatsApp Channel (newsletter). Channels are neither
+// groups nor people, and their IDs are not phone numbers.
+func IsNewsletterJID(jid string) bool {
+	return strings.HasSuffix(strings.TrimSpace(jid), "@newsletter")
+}
+
 // GetPlatformName returns the platform name based on device ID
 func GetPlatformName(deviceID int) string {
 	switch deviceID {
@@ -753,48 +773,110 @@ func SanitizePhone(phone *string) {
 	}
 }
 
-// IsOnWhatsapp checks if a number is registered on WhatsApp
-func IsOnWhatsapp(client *whatsmeow.Client, jid string) bool {
-	// only check if the jid is a user with @s.whatsapp.net
-	if strings.Contains(jid, "@s.whatsapp.net") {
-		// Extract phone number from JID and add + prefix for international format
-		phone := strings.TrimSuffix(jid, "@s.whatsapp.net")
-		if phone == "" {
-			return false
-		}
+const (
+	// onWhatsappCacheTTL is how long a registered number is reused before WhatsApp is asked again.
+	onWhatsappCacheTTL = time.Hour
+	// notOnWhatsappCacheTTL is how long a number found unregistered is reused. It is short: the
+	// number may register at any moment, and a send to it must not be refused for long after.
+	notOnWhatsappCacheTTL = time.Minute
+)
 
-		// whatsmeow expects international format with + prefix
-		if !strings.HasPrefix(phone, "+") {
-			phone = "+" + phone
-		}
+// OnWhatsappCache holds recent registration lookups by phone number. It is shared by message sends,
+// the Chatwoot webhook and the contact check endpoints. Only registered numbers are kept for
+// onWhatsappCacheTTL; unregistered ones live in notOnWhatsappCache.
+var OnWhatsappCache = NewTTLCache[string, bool](10000, onWhatsappCacheTTL)
 
-		// Add timeout to prevent indefinite blocking
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+// notOnWhatsappCache holds the numbers recent lookups found unregistered.
+var notOnWhatsappCache = NewTTLCache[string, bool](10000, notOnWhatsappCacheTTL)
 
-		data, err := client.IsOnWhatsApp(ctx, []string{phone})
-		if err != nil {
-			logrus.Error("Failed to check if user is on whatsapp: ", err)
-			return false
-		}
+// RememberOnWhatsapp records a registration lookup for phone, a number without the + prefix.
+func RememberOnWhatsapp(phone string, registered bool) {
+	if registered {
+		notOnWhatsappCache.Delete(phone)
+		OnWhatsappCache.Set(phone, true)
+		return
+	}
+	OnWhatsappCache.Delete(phone)
+	notOnWhatsappCache.Set(phone, false)
+}
+
+// cachedOnWhatsapp returns the remembered registration of phone, if any.
+func cachedOnWhatsapp(phone string) (registered bool, ok bool) {
+	if _, ok := OnWhatsappCache.Get(phone); ok {
+		return true, true
+	}
+	if _, ok := notOnWhatsappCache.Get(phone); ok {
+		return false, true
+	}
+	return false, false
+}
+
+// lookupOnWhatsapp asks WhatsApp which phones are registered; replaced in tests.
+var lookupOnWhatsapp = func(ctx context.Context, client *whatsmeow.Client, phones []string) ([]types.IsOnWhatsAppResponse, error) {
+	return client.IsOnWhatsApp(ctx, phones)
+}
 
-		// Empty response means number not found/invalid
-		if len(data) == 0 {
-			return false
+// CheckOnWhatsapp reports whether jid, a user JID or a bare phone number, is registered on WhatsApp.
+// Groups, LIDs and other JIDs are not looked up and reported as registered. Answers are remembered
+// with RememberOnWhatsapp; a lookup that fails returns the error and is not cached.
+func CheckOnWhatsapp(ctx context.Context, client *whatsmeow.Client, jid string) (bool, error) {
+	phone := jid
+	if user, server, found := strings.Cut(jid, "@"); found {
+		if server != types.DefaultUserServer {
+			return true, nil
 		}
+		phone = user
+	}
+	phone = strings.TrimPrefix(phone, "+")
+	if phone == "" {
+		return false, nil
+	}
+	if len(phone) > maxPhoneNumberLength {
+		return true, nil
+	}
 
-		// Check if any result indicates the number is NOT on WhatsApp
-		for _, v := range data {
-			if !v.IsIn {
-				return false
-			}
+	if registered, ok := cachedOnWhatsapp(phone); ok {
+		return registered, nil
+	}
+	if client == nil {
+		return false, pkgError.ErrWaCLI
+	}
+
+	// whatsmeow expects international format with + prefix
+	data, err := lookupOnWhatsapp(ctx, client, []string{"+" + phone})
+	if err != nil {
+		return false, err
+	}
+
+	// Empty response means number not found/invalid
+	registered := len(data) > 0
+	for _, v := range data {
+		if !v.IsIn {
+			registered = false
 		}
+	}
+	RememberOnWhatsapp(phone, registered)
+	return registered, nil
+}
 
+// IsOnWhatsapp checks if a number is registered on WhatsApp
+func IsOnWhatsapp(client *whatsmeow.Client, jid string) bool {
+	// only check if the jid is a user with @s.whatsapp.net
+	if !strings.Contains(jid, "@s.whatsapp.net") {
+		// For non-user JIDs (groups, newsletters), skip validation
 		return true
 	}
 
-	// For non-user JIDs (groups, newsletters), skip validation
-	return true
+	// Add timeout to prevent indefinite blocking
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	registered, err := CheckOnWhatsapp(ctx, client, jid)
+	if err != nil {
+		logrus.Error("Failed to check if user is on whatsapp: ", err)
+		return false
+	}
+	return registered
 }
 
 // ValidateJidWithLogin validates JID with login check
@@ -950,6 +1032,21 @@ func UnwrapMessage(msg *waE2E.Message) *waE2E.Message {
 	return inner
 }
 
+// IsViewOnceMessage reports whether msg is view-once media: either wrapped in a view-once container
+// or an image, video or audio message carrying the view-once flag itself.
+func IsViewOnceMessage(msg *waE2E.Message) bool {
+	if msg == nil {
+		return false
+	}
+	if msg.GetViewOnceMessage() != nil || msg.GetViewOnceMessageV2() != nil || msg.GetViewOnceMessageV2Extension() != nil {
+		return true
+	}
+	if em := msg.GetEphemeralMessage(); em != nil && em.GetMessage() != nil {
+		return IsViewOnceMessage(em.GetMessage())
+	}
+	return msg.GetImageMessage().GetViewOnce() || msg.GetVideoMessage().GetViewOnce() || msg.GetAudioMessage().GetViewOnce()
+}
+
 // BuildEventMessage builds event message structure
 func BuildEventMessage(evt *events.Message) (message EvtMessage) {
 	msg := UnwrapMessage(evt.Message)
@@ -1,6 +1,16 @@
 package utils
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
 
 func TestDetermineMediaExtension(t *testing.T) {
 	tests := []struct {
@@ -62,3 +72,99 @@ func TestDetermineMediaExtension(t *testing.T) {
 		})
 	}
 }
+
+func TestIsViewOnceMessage(t *testing.T) {
+	inner := &waE2E.Message{ImageMessage: &waE2E.ImageMessage{}}
+	tests := []struct {
+		name string
+		msg  *waE2E.Message
+		want bool
+	}{
+		{"plain image", inner, false},
+		{"flagged image", &waE2E.Message{ImageMessage: &waE2E.ImageMessage{ViewOnce: proto.Bool(true)}}, true},
+		{"v2 wrapper", &waE2E.Message{ViewOnceMessageV2: &waE2E.FutureProofMessage{Message: inner}}, true},
+		{"ephemeral wrapper", &waE2E.Message{EphemeralMessage: &waE2E.FutureProofMessage{Message: &waE2E.Message{ViewOnceMessage: &waE2E.FutureProofMessage{Message: inner}}}}, true},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsViewOnceMessage(tt.msg); got != tt.want {
+			t.Errorf("%s: IsViewOnceMessage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOnWhatsapp(t *testing.T) {
+	var lookups []string
+	lookupErr := error(nil)
+	orig := lookupOnWhatsapp
+	lookupOnWhatsapp = func(_ context.Context, _ *whatsmeow.Client, phones []string) ([]types.IsOnWhatsAppResponse, error) {
+		lookups = append(lookups, phones...)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		return []types.IsOnWhatsAppResponse{{Query: phones[0], IsIn: phones[0] == "+5511900000001"}}, nil
+	}
+	t.Cleanup(func() { lookupOnWhatsapp = orig })
+	client := &whatsmeow.Client{}
+
+	for _, tc := range []struct {
+		jid  string
+		want bool
+	}{
+		{"5511900000001@s.whatsapp.net", true},
+		{"+5511900000002", false},
+		{"5511900000001", true},
+		{"120363000000000001@g.us", true},
+		{"123456789012345@lid", true},
+	} {
+		got, err := CheckOnWhatsapp(context.Background(), client, tc.jid)
+		if err != nil || got != tc.want {
+			t.Errorf("CheckOnWhatsapp(%q) = %v, %v; want %v", tc.jid, got, err, tc.want)
+		}
+	}
+	if len(lookups) != 2 {
+		t.Errorf("expected one lookup per number, the rest from the cache or skipped, got %v", lookups)
+	}
+
+	lookupErr = errors.New("timeout")
+	if _, err := CheckOnWhatsapp(context.Background(), client, "5511900000003"); err == nil {
+		t.Fatal("expected the lookup error")
+	}
+	if _, ok := cachedOnWhatsapp("5511900000003"); ok {
+		t.Error("expected a failed lookup not to be cached")
+	}
+}
+
+func TestCheckOnWhatsapp_UnregisteredExpiresSoon(t *testing.T) {
+	registered := false
+	lookups := 0
+	orig, origNeg := lookupOnWhatsapp, notOnWhatsappCache
+	lookupOnWhatsapp = func(_ context.Context, _ *whatsmeow.Client, phones []string) ([]types.IsOnWhatsAppResponse, error) {
+		lookups++
+		return []types.IsOnWhatsAppResponse{{Query: phones[0], IsIn: registered}}, nil
+	}
+	notOnWhatsappCache = NewTTLCache[string, bool](10, 20*time.Millisecond)
+	t.Cleanup(func() {
+		lookupOnWhatsapp, notOnWhatsappCache = orig, origNeg
+		OnWhatsappCache.Delete("5511900000009")
+	})
+	client := &whatsmeow.Client{}
+
+	if got, _ := CheckOnWhatsapp(context.Background(), client, "5511900000009"); got {
+		t.Fatal("expected the number to be reported unregistered")
+	}
+	if _, ok := OnWhatsappCache.Get("5511900000009"); ok {
+		t.Error("expected an unregistered number to stay out of the long-lived cache")
+	}
+	_, _ = CheckOnWhatsapp(context.Background(), client, "5511900000009")
+	if lookups != 1 {
+		t.Errorf("expected the negative answer to be reused briefly, got %d lookups", lookups)
+	}
+
+	// The number registers: once the short negative entry expires, sends see it
+	registered = true
+	time.Sleep(30 * time.Millisecond)
+	if got, _ := CheckOnWhatsapp(context.Background(), client, "5511900000009"); !got || lookups != 2 {
+		t.Errorf("expected a fresh lookup to report the number registered, got %v after %d lookups", got, lookups)
+	}
+}
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Admin struct {
+	ChatStorageRepo domainChatStorage.IChatStorageRepository
+}
+
+func InitRestAdmin(app fiber.Router, chatStorageRepo domainChatStorage.IChatStorageRepository) Admin {
+	rest := Admin{ChatStorageRepo: chatStorageRepo}
+	app.Get("/admin/db/version", rest.DBVersion)
+	app.Get("/admin/db/stats", rest.DBStats)
+	return rest
+}
+
+// InitRestAdminStorage registers the storage maintenance routes, kept apart from the read-only ones
+// so they can require their own scope.
+func InitRestAdminStorage(app fiber.Router, chatStorageRepo domainChatStorage.IChatStorageRepository) Admin {
+	rest := Admin{ChatStorageRepo: chatStorageRepo}
+	app.Post("/admin/storage/prune", rest.PruneStorage)
+	return rest
+}
+
+// DBVersion reports the migration the chat storage database is at and how many this build has yet to apply.
+func (h *Admin) DBVersion(c *fiber.Ctx) error {
+	if h.ChatStorageRepo == nil {
+		return sendError(c, CodeInternalError, "Chat storage is not available")
+	}
+
+	version, err := h.ChatStorageRepo.GetSchemaVersion()
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to read the schema version: %v", err))
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Chat storage schema at version %d of %d", version.Version, version.Latest),
+		Results: version,
+	})
+}
+
+// DBStats reports the chat storage connection pool, to tune CHAT_STORAGE_MAX_OPEN_CONNS and friends.
+func (h *Admin) DBStats(c *fiber.Ctx) error {
+	if h.ChatStorageRepo == nil {
+		return sendError(c, CodeInternalError, "Chat storage is not available")
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Chat storage connection pool stats",
+		Results: h.ChatStorageRepo.GetDBStats(),
+	})
+}
+
+// PruneStorage applies the retention policy now. With dry_run=true it only counts the rows it would delete.
+// POST /admin/storage/prune?dry_run=true
+func (h *Admin) PruneStorage(c *fiber.Ctx) error {
+	if h.ChatStorageRepo == nil {
+		return sendError(c, CodeInternalError, "Chat storage is not available")
+	}
+	if config.ChatStorageRetentionDays <= 0 {
+		return sendError(c, CodeInvalidRequest, "Retention is disabled; set CHAT_STORAGE_RETENTION_DAYS to prune")
+	}
+
+	dryRun := c.QueryBool("dry_run", false)
+	result, err := whatsapp.PruneStorage(c.UserContext(), h.ChatStorageRepo, dryRun)
+	if err != nil {
+		return sendErrorWithResults(c, CodeInternalError, fmt.Sprintf("Failed to prune storage: %v", err), result)
+	}
+
+	message := "Storage pruned"
+	if dryRun {
+		message = "Dry run: rows that would be pruned"
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: message,
+		Results: result,
+	})
+}
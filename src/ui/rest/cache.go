@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Cache struct{}
+
+func InitRestCache(app fiber.Router) Cache {
+	rest := Cache{}
+	app.Delete("/caches/group-names", rest.PurgeGroupNames)
+	return rest
+}
+
+// PurgeGroupNames drops cached group subjects so the next lookup asks WhatsApp again.
+// With ?jid= only that group is dropped.
+func (h *Cache) PurgeGroupNames(c *fiber.Ctx) error {
+	if jid := c.Query("jid"); jid != "" {
+		removed := 0
+		if whatsapp.InvalidateGroupName(jid) {
+			removed = 1
+		}
+		return c.JSON(utils.ResponseData{
+			Status:  200,
+			Code:    "SUCCESS",
+			Message: "Group name cache entry invalidated",
+			Results: fiber.Map{"jid": jid, "removed": removed},
+		})
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Group name cache purged",
+		Results: fiber.Map{"removed": whatsapp.PurgeGroupNames()},
+	})
+}
@@ -1,10 +1,14 @@
 package rest
 
 import (
+	"bufio"
+	"fmt"
+
 	domainChat "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chat"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
 )
 
 type Chat struct {
@@ -17,6 +21,8 @@ func InitRestChat(app fiber.Router, service domainChat.IChatUsecase) Chat {
 	// Chat endpoints
 	app.Get("/chats", rest.ListChats)
 	app.Get("/chat/:chat_jid/messages", rest.GetChatMessages)
+	app.Get("/messages/search", rest.SearchMessages)
+	app.Get("/chats/:chat_jid/export", rest.ExportChat)
 	app.Post("/chat/:chat_jid/pin", rest.PinChat)
 	app.Post("/chat/:chat_jid/disappearing", rest.SetDisappearingTimer)
 	app.Post("/chat/:chat_jid/archive", rest.ArchiveChat)
@@ -81,6 +87,64 @@ func (controller *Chat) GetChatMessages(c *fiber.Ctx) error {
 	})
 }
 
+func (controller *Chat) SearchMessages(c *fiber.Ctx) error {
+	var request domainChat.SearchMessagesRequest
+
+	// Parse query parameters
+	request.Query = c.Query("q")
+	request.ChatJID = c.Query("chat_jid")
+	request.Limit = c.QueryInt("limit", 0)
+	request.Cursor = c.Query("cursor")
+	if from := c.Query("from"); from != "" {
+		request.From = &from
+	}
+	if to := c.Query("to"); to != "" {
+		request.To = &to
+	}
+
+	response, err := controller.Service.SearchMessages(whatsapp.ContextWithDevice(c.UserContext(), getDeviceFromCtx(c)), request)
+	utils.PanicIfNeeded(err)
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Success search messages",
+		Results: response,
+	})
+}
+
+// ExportChat streams a transcript of a chat as JSON lines, CSV or HTML, optionally zipped with its media.
+func (controller *Chat) ExportChat(c *fiber.Ctx) error {
+	var request domainChat.ExportChatRequest
+
+	// Parse path parameter
+	request.ChatJID = c.Params("chat_jid")
+
+	// Parse query parameters
+	request.Format = c.Query("format")
+	request.Media = c.Query("media")
+	if from := c.Query("from"); from != "" {
+		request.From = &from
+	}
+	if to := c.Query("to"); to != "" {
+		request.To = &to
+	}
+
+	response, err := controller.Service.ExportChat(whatsapp.ContextWithDevice(c.UserContext(), getDeviceFromCtx(c)), request)
+	utils.PanicIfNeeded(err)
+
+	c.Set(fiber.HeaderContentType, response.ContentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, response.Filename))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Headers are gone by now, so a failure can only cut the download short
+		if err := response.Write(w); err != nil {
+			logrus.Errorf("Chat export of %s failed: %v", request.ChatJID, err)
+		}
+		_ = w.Flush()
+	})
+	return nil
+}
+
 func (controller *Chat) PinChat(c *fiber.Ctx) error {
 	var request domainChat.PinChatRequest
 
@@ -1,7 +1,11 @@
 package rest
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"path"
@@ -15,8 +19,10 @@ import (
 	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/metrics"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
-	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/middleware"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/helpers"
 	"github.com/gofiber/fiber/v2"
 	"github.com/sirupsen/logrus"
 )
@@ -41,6 +47,13 @@ var audioAttachmentExtensions = map[string]struct{}{
 	".webm": {},
 }
 
+// playableAudioExtensions are the audio formats WhatsApp plays without converting them first.
+var playableAudioExtensions = map[string]struct{}{
+	".aac": {},
+	".m4a": {},
+	".mp3": {},
+}
+
 func attachmentExtension(att chatwoot.Attachment) string {
 	ext := strings.ToLower(strings.TrimSpace(att.Extension))
 	if ext != "" {
@@ -92,131 +105,409 @@ func sanitizeText(s string) string {
 	return s
 }
 
+// HandleWebhook sends Chatwoot agent replies, and later edits and deletions of them, to WhatsApp.
+// Authenticity is checked beforehand by middleware.ChatwootWebhookAuth.
 func (h *ChatwootHandler) HandleWebhook(c *fiber.Ctx) error {
-	if config.ChatwootWebhookToken != "" {
-		token := strings.TrimSpace(c.Get("X-Chatwoot-Token"))
-		if token == "" {
-			token = strings.TrimSpace(c.Query("token"))
-		}
-		if token == "" {
-			authHeader := strings.TrimSpace(c.Get("Authorization"))
-			if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
-				token = strings.TrimSpace(authHeader[len("Bearer "):])
-			}
-		}
-		if !middleware.IsSecureTokenMatch(token, config.ChatwootWebhookToken) {
-			return c.Status(fiber.StatusUnauthorized).JSON(utils.ResponseData{
-				Status:  fiber.StatusUnauthorized,
-				Code:    "UNAUTHORIZED_WEBHOOK",
-				Message: "invalid or missing chatwoot webhook token",
-			})
-		}
-	}
-
 	logrus.Debugf("Chatwoot Webhook raw body: %s", string(c.Body()))
 
-	instance, resolvedID, err := h.DeviceManager.ResolveDevice(config.ChatwootDeviceID)
-	if err != nil {
-		logrus.Errorf("Chatwoot Webhook: Failed to resolve device: %v", err)
-		return c.Status(fiber.StatusServiceUnavailable).JSON(utils.ResponseData{
-			Status:  fiber.StatusServiceUnavailable,
-			Code:    "DEVICE_NOT_AVAILABLE",
-			Message: fmt.Sprintf("No device available for Chatwoot: %v. Configure CHATWOOT_DEVICE_ID or ensure one device is registered.", err),
-		})
-	}
-	logrus.Debugf("Chatwoot Webhook: Using device %s", resolvedID)
-
-	c.SetUserContext(whatsapp.ContextWithDevice(c.UserContext(), instance))
-
 	var payload chatwoot.WebhookPayload
 	if err := c.BodyParser(&payload); err != nil {
-		return utils.ResponseError(c, "Invalid payload")
+		return sendError(c, CodeInvalidRequest, "Invalid payload")
+	}
+	cw, err := resolveWebhookClient(c)
+	if err != nil {
+		return sendError(c, CodeNotFound, err.Error())
 	}
+	c.SetUserContext(chatwoot.ContextWithClient(c.UserContext(), cw))
 
 	contact := payload.Conversation.Meta.Sender
-	logrus.Debugf("Chatwoot Webhook: event=%s message_type=%s message_id=%d contact_id=%d contact_phone=%s",
-		payload.Event, payload.MessageType, payload.ID, contact.ID, contact.PhoneNumber)
+	logrus.Debugf("Chatwoot Webhook: event=%s message_type=%s message_id=%d inbox_id=%d contact_id=%d contact_phone=%s",
+		payload.Event, payload.MessageType, payload.ID, payload.Conversation.InboxID, contact.ID, contact.PhoneNumber)
+	metrics.ChatwootWebhookEvent(payload.Event)
 
-	if payload.Event != "message_created" {
+	if payload.Event == "conversation_created" {
+		h.rememberConversationDestination(cw, payload.EventConversation())
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if payload.Event == "contact_updated" {
+		h.repairMergedContact(cw, payload.EventContact())
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if payload.Event == "conversation_status_changed" {
+		if payload.Status == "resolved" {
+			cw.ForgetContactConversation(payload.EventConversation().Meta.Sender.ID)
+		}
+		h.sendRatingPrompt(c, payload)
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if payload.Event != "message_created" && payload.Event != "message_updated" && payload.Event != "message_deleted" {
 		return c.SendStatus(fiber.StatusOK)
 	}
 	if payload.MessageType != "outgoing" {
 		return c.SendStatus(fiber.StatusOK)
 	}
+	// Edited notes and commands are not run again. Chatwoot reports deletions as an update with
+	// content_attributes.deleted; message_deleted is accepted as well.
+	isUpdate := payload.Event != "message_created"
+	isDeleted := payload.Event == "message_deleted" || (isUpdate && payload.IsDeleted())
 	if payload.Private {
+		if !isUpdate {
+			h.handlePrivateNote(cw, payload)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	}
+	// #info typed as a reply instead of a note is answered the same way and never reaches WhatsApp.
+	if chatwoot.IsInfoCommand(payload.Content) && len(payload.Attachments) == 0 {
+		if !isUpdate && (payload.ID == 0 || !cw.IsMessageSentByUs(payload.ID)) {
+			postPrivateNote(cw, payload.Conversation.ID, h.chatInfoNote(cw, payload.Conversation))
+		}
 		return c.SendStatus(fiber.StatusOK)
 	}
 
+	// New agent messages are audited; edits and deletions are not
+	audit := func(destination, decision, reason, waMessageID, detail string) {
+		if !isUpdate {
+			h.auditWebhook(cw, payload, destination, decision, reason, waMessageID, detail)
+		}
+	}
+	audit("", domainChatStorage.ChatwootAuditReceived, "", "", "")
+
+	instance, _, err := h.resolveWebhookDevice(cw, payload.Conversation)
+	if errors.Is(err, errWebhookDeviceDisconnected) {
+		logrus.Warnf("Chatwoot Webhook: %v", err)
+		if !isUpdate && !isBridgeEcho(cw, payload) {
+			reportDeliveryFailure(cw, payload.Conversation.ID, "", err.Error())
+		}
+		audit("", domainChatStorage.ChatwootAuditFailed, chatwoot.AuditReasonNoDevice, "", err.Error())
+		return sendError(c, CodeDeviceDisconnected, err.Error())
+	}
+	if err != nil {
+		logrus.Errorf("Chatwoot Webhook: Failed to resolve device: %v", err)
+		if !isUpdate && !isBridgeEcho(cw, payload) {
+			reportDeliveryFailure(cw, payload.Conversation.ID, "", "no WhatsApp device is available for this inbox")
+		}
+		audit("", domainChatStorage.ChatwootAuditFailed, chatwoot.AuditReasonNoDevice, "", err.Error())
+		return sendError(c, CodeDeviceNotAvailable, fmt.Sprintf("No device available for Chatwoot: %v. Configure CHATWOOT_DEVICE_ID or ensure one device is registered.", err))
+	}
+
+	c.SetUserContext(whatsapp.ContextWithDevice(c.UserContext(), instance))
+
 	// 1) Dedupe em memória (protege contra loops imediatos)
-	if payload.ID != 0 && chatwoot.IsMessageSentByUs(payload.ID) {
+	if payload.ID != 0 && cw.IsMessageSentByUs(payload.ID) {
 		logrus.Debugf("Chatwoot Webhook: Skipping echo message %d (memory dedupe)", payload.ID)
+		audit("", domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonEcho, "", "")
 		return c.SendStatus(fiber.StatusOK)
 	}
 
-	// 2) Dedupe persistente no banco (protege após restart, atrasos, retries)
+	// 2) The source_id set by the forwarder marks a message that came from WhatsApp
+	if chatwoot.IsForwardedSourceID(payload.SourceID) {
+		logrus.Debugf("Chatwoot Webhook: Skipping echo message %d (source_id %s)", payload.ID, payload.SourceID)
+		audit("", domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonEcho, "", "")
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	// 3) Dedupe persistente no banco (protege após restart, atrasos, retries)
 	if payload.ID != 0 && h.ChatStorageRepo != nil {
 		isFromUs, err := h.ChatStorageRepo.IsChatwootMessageFromUs(payload.ID)
 		if err == nil && isFromUs {
 			logrus.Debugf("Chatwoot Webhook: Skipping echo message %d (db dedupe)", payload.ID)
+			audit("", domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonEcho, "", "")
 			return c.SendStatus(fiber.StatusOK)
 		}
-	}
-
-	customAttrs := contact.CustomAttributes
-	var destination string
-	if val, ok := customAttrs["waha_whatsapp_jid"]; ok {
-		if strVal, ok := val.(string); ok {
-			destination = strVal
+		// A retried webhook of a message already sent to WhatsApp, however late
+		if sent, err := h.ChatStorageRepo.GetChatwootSentMessageIDs(payload.ID); err == nil && len(sent) > 0 && !isUpdate {
+			logrus.Debugf("Chatwoot Webhook: Skipping message %d, already sent to WhatsApp as %s", payload.ID, strings.Join(sent, ", "))
+			audit("", domainChatStorage.ChatwootAuditSkipped, chatwoot.AuditReasonAlreadyForwarded, sent[0], "")
+			return c.SendStatus(fiber.StatusOK)
 		}
 	}
-	if destination == "" && contact.PhoneNumber != "" {
-		destination = contact.PhoneNumber
-	}
 
+	destination, cached := cw.ConversationDestination(payload.Conversation.ID)
+	if !cached {
+		destination = webhookDestination(contact)
+	}
 	if destination == "" {
 		logrus.Warnf("Chatwoot Webhook: No destination phone for contact ID %d", contact.ID)
 		return c.SendStatus(fiber.StatusOK)
 	}
-
 	isGroup := utils.IsGroupJID(destination)
+	if isGroup {
+		destination = strings.TrimSpace(destination)
+	} else if !isLIDContact(contact) {
+		phone, err := utils.CleanPhoneForWhatsApp(destination)
+		if err != nil {
+			logrus.Warnf("Chatwoot Webhook: Not sending message %d to contact %d: %v", payload.ID, contact.ID, err)
+			if !isDeleted && !isUpdate {
+				reportDeliveryFailure(cw, payload.Conversation.ID, "", err.Error())
+			}
+			audit(destination, domainChatStorage.ChatwootAuditFailed, chatwoot.AuditReasonInvalidNumber, "", err.Error())
+			return c.SendStatus(fiber.StatusOK)
+		}
+		// A number saved in another form, e.g. a Brazilian mobile with the ninth digit, is sent to the JID WhatsApp registered
+		destination, _ = utils.PhoneVariants(phone)
+	}
 
-	destination = utils.CleanPhoneForWhatsApp(destination)
+	if isDeleted {
+		h.revokeSentMessage(c, payload, destination)
+		return webhookResult(c, payload.ID)
+	}
+	if isUpdate {
+		h.applyMessageEdit(c, payload, destination)
+		return webhookResult(c, payload.ID)
+	}
 
-	if !isGroup {
-		destination = utils.ExtractPhoneFromJID(destination)
+	if !isGroup && !isLIDContact(contact) && !destinationOnWhatsapp(c.Context(), instance, destination) {
+		logrus.Warnf("Chatwoot Webhook: Not sending message %d, %s is not on WhatsApp", payload.ID, destination)
+		reportDeliveryFailure(cw, payload.Conversation.ID, destination, "the number is not registered on WhatsApp")
+		audit(destination, domainChatStorage.ChatwootAuditFailed, chatwoot.AuditReasonNotOnWhatsApp, "", "")
+		return webhookResult(c, payload.ID)
 	}
 
 	logrus.Debugf("Chatwoot Webhook: Sending to destination=%s isGroup=%v", destination, isGroup)
 	h.triggerAvatarSync(instance, contact, destination)
 
+	// Automations may post a canned response shortcut instead of its text
+	payload.Content = cw.ExpandCannedShortcut(payload.Content, contact)
+
 	if len(payload.Attachments) > 0 {
-		for _, attachment := range payload.Attachments {
-			if err := h.handleAttachment(c, destination, attachment, payload.Content); err != nil {
-				logrus.Errorf("Chatwoot Webhook: Failed to send attachment %d: %v", attachment.ID, err)
-			}
-		}
-		return c.SendStatus(fiber.StatusOK)
+		h.sendAttachments(c, payload, destination)
+		return webhookResult(c, payload.ID)
+	}
+
+	if cmd, ok, err := chatwoot.ParsePollCommand(payload.Content, config.ChatwootPollPrefix); ok {
+		postPrivateNote(cw, payload.Conversation.ID, h.sendPollCommand(c, payload, destination, cmd, err))
+		return webhookResult(c, payload.ID)
+	}
+
+	content := payload.Content
+	surveyUUID := ""
+	if payload.ContentType == chatwoot.CSATContentType && !isGroup {
+		content, surveyUUID = csatSurveyMessage(cw.BaseURL, content, payload.Conversation.UUID)
 	}
 
-	if payload.Content != "" {
+	if content != "" {
 		req := domainSend.MessageRequest{
-			Message: sanitizeText(payload.Content),
+			Message: sanitizeText(content),
 		}
 		req.Phone = destination
 
-		_, err := h.SendUsecase.SendText(c.Context(), req)
+		resp, err := h.SendUsecase.SendText(chatwootSendContext(c), req)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"destination": destination,
 				"is_group":    isGroup,
 				"error":       err.Error(),
 			}).Error("Chatwoot Webhook: Failed to send message (returning 200 to prevent retry)")
-			return c.SendStatus(fiber.StatusOK)
+			chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("text to %s: %w", destination, err))
+			reportDeliveryFailure(cw, payload.Conversation.ID, destination, deliveryFailureReason(err))
+			audit(destination, domainChatStorage.ChatwootAuditFailed, chatwoot.AuditReasonSendFailed, "", err.Error())
+			return webhookResult(c, payload.ID)
 		}
+		h.trackSentMessage(c, resp.MessageID, destination, payload.Conversation.ID, payload.ID)
 		logrus.Infof("Chatwoot Webhook: Sent text message to %s", destination)
+		chatwoot.RecordBridgedMessage(chatwoot.BridgeToWhatsApp, destination)
+		audit(destination, domainChatStorage.ChatwootAuditForwarded, "", resp.MessageID, "")
+
+		if surveyUUID != "" {
+			if err := chatwoot.TrackCSATSurvey(destination, payload.Conversation.ID, surveyUUID); err != nil {
+				logrus.Warnf("Chatwoot Webhook: Failed to track CSAT survey for conversation %d: %v", payload.Conversation.ID, err)
+			}
+		}
+	}
+
+	return webhookResult(c, payload.ID)
+}
+
+// csatSurveyMessage returns the text to send for a CSAT survey message and the survey UUID. The survey
+// link is appended when Chatwoot left it out of the content, since WhatsApp cannot render the survey.
+func csatSurveyMessage(baseURL, content, conversationUUID string) (string, string) {
+	if surveyUUID := chatwoot.SurveyUUIDFromContent(content); surveyUUID != "" {
+		return content, surveyUUID
+	}
+	if conversationUUID == "" {
+		return content, ""
+	}
+	link := chatwoot.CSATSurveyLink(baseURL, conversationUUID)
+	if strings.TrimSpace(content) == "" {
+		return link, conversationUUID
+	}
+	return content + "\n" + link, conversationUUID
+}
+
+// webhookDestination resolves where a reply to this contact goes: the JID stored by the forwarder,
+// a JID identifier, or the phone number typed by the agent. Phone numbers are reduced to digits since
+// contacts created from Chatwoot's "New conversation" dialog keep the formatting the agent used.
+func webhookDestination(contact chatwoot.Contact) string {
+	candidates := []string{contact.PhoneNumber}
+	if strings.Contains(contact.Identifier, "@") {
+		candidates = append([]string{contact.Identifier}, candidates...)
+	}
+	if jid, ok := contact.CustomAttributes["waha_whatsapp_jid"].(string); ok {
+		candidates = append([]string{jid}, candidates...)
+	}
+
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		if utils.IsGroupJID(candidate) {
+			return candidate
+		}
+		if phone := digitsOnly(utils.ExtractPhoneFromJID(candidate)); phone != "" {
+			return phone
+		}
+	}
+	return ""
+}
+
+// isLIDContact reports whether the WhatsApp JID stored on the contact is a LID, whose digits are not a
+// phone number.
+func isLIDContact(contact chatwoot.Contact) bool {
+	jid, _ := contact.CustomAttributes["waha_whatsapp_jid"].(string)
+	if strings.TrimSpace(jid) == "" {
+		jid = contact.Identifier
+	}
+	return strings.HasSuffix(strings.TrimSpace(jid), config.WhatsappTypeLid)
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// repairMergedContact restores the WhatsApp mapping of a contact after agents merged it with another
+// one, and points its open conversation at the contact's chat again.
+func (h *ChatwootHandler) repairMergedContact(cw *chatwoot.Client, contact chatwoot.Contact) {
+	if !cw.IsConfigured() {
+		return
+	}
+	conversation, err := cw.RepairContactMapping(&contact)
+	if err != nil {
+		logrus.Warnf("Chatwoot Webhook: Failed to repair contact %d: %v", contact.ID, err)
+		return
+	}
+	if conversation == nil {
+		return
+	}
+	if destination := webhookDestination(contact); destination != "" {
+		cw.RememberConversationDestination(conversation.ID, destination)
+	}
+}
+
+// rememberConversationDestination pre-resolves the destination when an agent opens a conversation from
+// the Chatwoot UI, so the first message_created does not race the contact attribute updates.
+func (h *ChatwootHandler) rememberConversationDestination(cw *chatwoot.Client, conversation chatwoot.ConversationWebhook) {
+	destination := webhookDestination(conversation.Meta.Sender)
+	if destination == "" {
+		logrus.Debugf("Chatwoot Webhook: conversation %d created without a resolvable destination", conversation.ID)
+		return
+	}
+	cw.RememberConversationDestination(conversation.ID, destination)
+	logrus.Debugf("Chatwoot Webhook: conversation %d created, destination=%s", conversation.ID, destination)
+}
+
+// contactDeviceAlias returns the device alias stored on the contact by the forwarder when several
+// devices share the inbox (custom attribute waha_device).
+func contactDeviceAlias(contact chatwoot.Contact) string {
+	if contact.CustomAttributes == nil {
+		return ""
+	}
+	alias, _ := contact.CustomAttributes["waha_device"].(string)
+	return strings.TrimSpace(alias)
+}
+
+// errWebhookDeviceDisconnected signals that the inbox is mapped to a device that is offline,
+// so the webhook answers 422 and Chatwoot retries the delivery later.
+var errWebhookDeviceDisconnected = errors.New("device mapped to inbox is disconnected")
+
+// resolveWebhookDevice picks the device that owns the conversation. A device mapped to the
+// conversation inbox wins; otherwise the device stored on the contact is used, falling back to
+// CHATWOOT_DEVICE_ID when the contact carries no device attribute or it is no longer registered.
+func (h *ChatwootHandler) resolveWebhookDevice(cw *chatwoot.Client, conversation chatwoot.ConversationWebhook) (*whatsapp.DeviceInstance, string, error) {
+	if target, ok := chatwoot.GetClientRegistry().TargetOfClient(cw); ok {
+		return h.resolveTargetDevice(target, conversation)
+	}
+
+	if mappedID, ok := chatwoot.DeviceForInbox(conversation.InboxID); ok {
+		instance, resolvedID, err := h.DeviceManager.ResolveDevice(mappedID)
+		if err != nil {
+			return nil, resolvedID, fmt.Errorf("inbox %d is mapped to %s: %w", conversation.InboxID, mappedID, err)
+		}
+		if !instance.IsConnected() {
+			return nil, resolvedID, fmt.Errorf("%w: inbox %d device %s", errWebhookDeviceDisconnected, conversation.InboxID, resolvedID)
+		}
+		logrus.Infof("Chatwoot Webhook: Using device %s mapped to inbox %d", resolvedID, conversation.InboxID)
+		return instance, resolvedID, nil
+	}
+
+	contact := conversation.Meta.Sender
+	if alias := contactDeviceAlias(contact); alias != "" {
+		instance, resolvedID, err := h.DeviceManager.ResolveDevice(alias)
+		if err == nil {
+			logrus.Infof("Chatwoot Webhook: Using device %s stored on contact %d", resolvedID, contact.ID)
+			return instance, resolvedID, nil
+		}
+		logrus.Warnf("Chatwoot Webhook: Device %s stored on contact %d is unavailable (%v), falling back to default", alias, contact.ID, err)
+	}
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(config.ChatwootDeviceID)
+	if err == nil {
+		logrus.Infof("Chatwoot Webhook: Using default device %s for inbox %d", resolvedID, conversation.InboxID)
+	}
+	return instance, resolvedID, err
+}
+
+// resolveTargetDevice picks the device for a webhook of a CHATWOOT_TARGETS account: the device stored
+// on the contact when it is routed to the target, else the first connected device routed to it. The
+// inbox map and CHATWOOT_DEVICE_ID belong to the default account and are not used.
+func (h *ChatwootHandler) resolveTargetDevice(target string, conversation chatwoot.ConversationWebhook) (*whatsapp.DeviceInstance, string, error) {
+	registry := chatwoot.GetClientRegistry()
+	contact := conversation.Meta.Sender
+	if alias := contactDeviceAlias(contact); alias != "" {
+		if routed, _ := registry.TargetForDevice(alias); routed == target {
+			if instance, resolvedID, err := h.DeviceManager.ResolveDevice(alias); err == nil {
+				logrus.Infof("Chatwoot Webhook: Using device %s stored on contact %d", resolvedID, contact.ID)
+				return instance, resolvedID, nil
+			}
+		}
+	}
+
+	var disconnected *whatsapp.DeviceInstance
+	for _, deviceID := range registry.DevicesForTarget(target) {
+		instance, ok := h.targetDevice(deviceID)
+		if !ok {
+			continue
+		}
+		if instance.IsConnected() {
+			logrus.Infof("Chatwoot Webhook: Using device %s routed to Chatwoot target %s", instance.ID(), target)
+			return instance, instance.ID(), nil
+		}
+		if disconnected == nil {
+			disconnected = instance
+		}
+	}
+	if disconnected != nil {
+		return nil, disconnected.ID(), fmt.Errorf("%w: Chatwoot target %s device %s", errWebhookDeviceDisconnected, target, disconnected.ID())
 	}
+	return nil, "", fmt.Errorf("no device is routed to Chatwoot target %s", target)
+}
 
-	return c.SendStatus(fiber.StatusOK)
+// targetDevice finds a device routed by CHATWOOT_DEVICE_TARGETS, given by its ID or JID.
+func (h *ChatwootHandler) targetDevice(deviceID string) (*whatsapp.DeviceInstance, bool) {
+	if h.DeviceManager == nil {
+		return nil, false
+	}
+	if strings.Contains(deviceID, "@") {
+		return h.DeviceManager.FindDeviceByJID(deviceID)
+	}
+	instance, ok := h.DeviceManager.GetDevice(deviceID)
+	return instance, ok && instance != nil
 }
 
 func (h *ChatwootHandler) triggerAvatarSync(instance *whatsapp.DeviceInstance, contact chatwoot.Contact, destination string) {
@@ -255,8 +546,8 @@ func (h *ChatwootHandler) triggerAvatarSync(instance *whatsapp.DeviceInstance, c
 	}
 
 	if !strings.Contains(avatarJID, "@") {
-		cleanPhone := utils.ExtractPhoneFromJID(utils.CleanPhoneForWhatsApp(avatarJID))
-		if cleanPhone == "" {
+		cleanPhone, err := utils.CleanPhoneForWhatsApp(avatarJID)
+		if err != nil {
 			return
 		}
 		avatarJID = cleanPhone + "@s.whatsapp.net"
@@ -267,53 +558,266 @@ func (h *ChatwootHandler) triggerAvatarSync(instance *whatsapp.DeviceInstance, c
 		syncCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
-		if err := syncSvc.SyncContactAvatarSmart(syncCtx, jid, name, waClient); err != nil {
+		if err := syncSvc.SyncContactAvatarSmart(syncCtx, jid, name, waClient, true); err != nil {
 			logrus.Debugf("Chatwoot Webhook: Failed avatar sync for %s: %v", jid, err)
 		}
 	}(avatarJID, contactName)
 }
 
-func (h *ChatwootHandler) handleAttachment(c *fiber.Ctx, phone string, att chatwoot.Attachment, caption string) error {
+// checkOnWhatsapp is utils.CheckOnWhatsapp; replaced in tests.
+var checkOnWhatsapp = utils.CheckOnWhatsapp
+
+// destinationOnWhatsapp reports whether destination is a number registered on WhatsApp, so a reply
+// to a contact imported without WhatsApp is not attempted. LIDs are not checked. When the check is
+// disabled by WHATSAPP_ACCOUNT_VALIDATION or cannot be made, the send goes ahead and reports its own
+// failure.
+func destinationOnWhatsapp(ctx context.Context, instance *whatsapp.DeviceInstance, destination string) bool {
+	if !config.WhatsappAccountValidation || instance == nil {
+		return true
+	}
+	registered, err := checkOnWhatsapp(ctx, instance.GetClient(), destination)
+	if err != nil {
+		logrus.Debugf("Chatwoot Webhook: Could not check whether %s is on WhatsApp: %v", destination, err)
+		return true
+	}
+	return registered
+}
+
+// defaultWebhookClient is the Chatwoot client of the plain webhook URL; replaced in tests.
+var defaultWebhookClient = chatwoot.GetDefaultClient
+
+// resolveWebhookClient returns the Chatwoot client of the target named in the webhook URL, or the
+// default client for the plain webhook URL.
+func resolveWebhookClient(c *fiber.Ctx) (*chatwoot.Client, error) {
+	target := c.Params("target")
+	if target == "" {
+		return defaultWebhookClient(), nil
+	}
+	cw, ok := chatwoot.GetClientRegistry().Client(target)
+	if !ok {
+		return nil, fmt.Errorf("unknown Chatwoot target %q", target)
+	}
+	return cw, nil
+}
+
+// webhookClient returns the Chatwoot client HandleWebhook resolved for the request.
+func webhookClient(c *fiber.Ctx) *chatwoot.Client {
+	return chatwoot.ClientFromContext(c.UserContext())
+}
+
+// chatwootSendContext returns the context of the sends an agent makes from Chatwoot, marked so the
+// sent messages are not posted back to the conversation as API sends.
+func chatwootSendContext(c *fiber.Ctx) context.Context {
+	return whatsapp.ContextSentFromChatwoot(c.Context())
+}
+
+// isBridgeEcho reports whether a webhook is about a message the bridge itself posted to Chatwoot.
+func isBridgeEcho(cw *chatwoot.Client, payload chatwoot.WebhookPayload) bool {
+	return (payload.ID != 0 && cw.IsMessageSentByUs(payload.ID)) || chatwoot.IsForwardedSourceID(payload.SourceID)
+}
+
+// sendAttachments sends the attachments of an agent's message. Its text becomes the caption of the
+// first attachment that can carry one, and is sent as a message of its own when none did. Attachments
+// that could not be sent are reported in one delivery failure note.
+func (h *ChatwootHandler) sendAttachments(c *fiber.Ctx, payload chatwoot.WebhookPayload, destination string) {
+	cw := webhookClient(c)
+	caption := payload.Content
+	var failures []string
+	for i, attachment := range payload.Attachments {
+		attachmentCaption := ""
+		if !isAudioAttachment(attachment) {
+			attachmentCaption, caption = caption, ""
+		}
+
+		messageID, err := h.handleAttachment(c, destination, attachment, attachmentCaption)
+		if err != nil {
+			logrus.Errorf("Chatwoot Webhook: Failed to send attachment %d: %v", attachment.ID, err)
+			chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("attachment %d to %s: %w", attachment.ID, destination, err))
+			h.auditWebhook(cw, payload, destination, domainChatStorage.ChatwootAuditFailed, chatwoot.AuditReasonSendFailed, "", fmt.Sprintf("attachment %d: %v", attachment.ID, err))
+			failures = append(failures, fmt.Sprintf("%s %d: %s", attachmentKind(attachment), i+1, deliveryFailureReason(err)))
+			// The caption moves on to the next attachment
+			if attachmentCaption != "" {
+				caption = attachmentCaption
+			}
+			continue
+		}
+		h.trackSentMessage(c, messageID, destination, payload.Conversation.ID, payload.ID)
+		chatwoot.RecordBridgedMessage(chatwoot.BridgeToWhatsApp, destination)
+		h.auditWebhook(cw, payload, destination, domainChatStorage.ChatwootAuditForwarded, "", messageID, fmt.Sprintf("attachment %d", attachment.ID))
+	}
+
+	if caption != "" {
+		req := domainSend.MessageRequest{Message: sanitizeText(caption)}
+		req.Phone = destination
+		resp, err := h.SendUsecase.SendText(chatwootSendContext(c), req)
+		if err != nil {
+			logrus.Errorf("Chatwoot Webhook: Failed to send the text of message %d: %v", payload.ID, err)
+			chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("text to %s: %w", destination, err))
+			failures = append(failures, "text: "+deliveryFailureReason(err))
+			h.auditWebhook(cw, payload, destination, domainChatStorage.ChatwootAuditFailed, chatwoot.AuditReasonSendFailed, "", err.Error())
+		} else {
+			h.trackSentMessage(c, resp.MessageID, destination, payload.Conversation.ID, payload.ID)
+			chatwoot.RecordBridgedMessage(chatwoot.BridgeToWhatsApp, destination)
+			h.auditWebhook(cw, payload, destination, domainChatStorage.ChatwootAuditForwarded, "", resp.MessageID, "")
+		}
+	}
+
+	reportDeliveryFailure(cw, payload.Conversation.ID, destination, failures...)
+}
+
+// attachmentKind names an attachment in a failure note.
+func attachmentKind(att chatwoot.Attachment) string {
+	if isAudioAttachment(att) {
+		return "audio"
+	}
+	switch att.FileType {
+	case "image", "video":
+		return att.FileType
+	default:
+		return "file"
+	}
+}
+
+// deliveryFailureReason explains a failed send to the agent.
+func deliveryFailureReason(err error) string {
+	var ffmpegErr pkgError.FFmpegUnavailableError
+	if errors.As(err, &ffmpegErr) {
+		return err.Error()
+	}
+	if category, code, ok := whatsapp.ClassifySendError(err); ok {
+		return strings.TrimPrefix(whatsapp.MessageFailureNote(category, code), "Message not delivered to WhatsApp: ")
+	}
+	return err.Error()
+}
+
+// attachmentClient is the Chatwoot client agent attachments are downloaded with; replaced in tests.
+var attachmentClient = webhookClient
+
+// audioUploadMimeTypes maps types servers give audio files to one the audio send accepts.
+var audioUploadMimeTypes = map[string]string{
+	"application/ogg": "audio/ogg",
+	"audio/mp4":       "audio/m4a",
+	"audio/x-m4a":     "audio/m4a",
+	"video/mp4":       "audio/m4a",
+}
+
+// attachmentFilename is the name a downloaded attachment is sent with: the original one from the
+// webhook, else the last part of its URL, with the extension from the webhook or the download's
+// content type when the name has none.
+func attachmentFilename(att chatwoot.Attachment, file *chatwoot.DownloadedAttachment) string {
+	name := path.Base(strings.ReplaceAll(strings.TrimSpace(att.FileName), "\\", "/"))
+	if name == "" || name == "." || name == "/" {
+		name = file.Filename
+	}
+	if path.Ext(name) == "" {
+		ext := attachmentExtension(att)
+		if ext == "" {
+			ext = utils.ExtensionByMIME(file.MimeType)
+		}
+		name += ext
+	}
+	return name
+}
+
+// downloadAttachment fetches an agent's attachment from Chatwoot and checks it is what the webhook
+// says it is, so sending does not depend on WhatsApp reaching a private or expiring URL. The caller
+// removes the file.
+func downloadAttachment(ctx context.Context, cw *chatwoot.Client, att chatwoot.Attachment) (*chatwoot.DownloadedAttachment, error) {
+	kind := attachmentKind(att)
+	maxSize := config.WhatsappSettingMaxFileSize
+	switch kind {
+	case "image":
+		maxSize = config.WhatsappSettingMaxImageSize
+	case "video":
+		maxSize = config.WhatsappSettingMaxVideoSize
+	}
+
+	file, err := cw.DownloadAttachment(ctx, att.DataURL, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	ext := attachmentExtension(att)
+	file.Filename = attachmentFilename(att, file)
+
+	switch {
+	case file.MimeType == "text/html" && ext != ".html" && ext != ".htm":
+		err = fmt.Errorf("attachment download returned a web page instead of the %s", kind)
+	case (kind == "image" || kind == "video") && !strings.HasPrefix(file.MimeType, kind+"/"):
+		err = fmt.Errorf("attachment is not a valid %s (%s)", kind, file.MimeType)
+	case file.MimeType == "image/webp" && kind == "image":
+		err = file.ConvertWebPToPNG()
+	case kind == "audio" && audioUploadMimeTypes[file.MimeType] != "":
+		file.MimeType = audioUploadMimeTypes[file.MimeType]
+	}
+	if err != nil {
+		file.Remove()
+		return nil, err
+	}
+	return file, nil
+}
+
+func (h *ChatwootHandler) handleAttachment(c *fiber.Ctx, phone string, att chatwoot.Attachment, caption string) (string, error) {
 	logrus.Debugf("Chatwoot Webhook: handling attachment id=%d file_type=%s extension=%s data_url=%s",
 		att.ID, att.FileType, att.Extension, att.DataURL)
 
+	file, err := downloadAttachment(c.Context(), attachmentClient(c), att)
+	if err != nil {
+		return "", err
+	}
+	defer file.Remove()
+	upload, cleanup, err := helpers.FileHeaderFromPath(file.Path, file.Filename, file.MimeType)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the downloaded attachment: %w", err)
+	}
+	defer cleanup()
+
 	if isAudioAttachment(att) {
 		reqPTT := domainSend.AudioRequest{
 			BaseRequest: domainSend.BaseRequest{Phone: phone},
-			AudioURL:    &att.DataURL,
+			Audio:       upload,
 			PTT:         true, // First try as voice note (PTT)
 		}
-		_, err := h.SendUsecase.SendAudio(c.Context(), reqPTT)
+		resp, err := h.SendUsecase.SendAudio(chatwootSendContext(c), reqPTT)
 		if err == nil {
 			logrus.Infof("Chatwoot Webhook: Sent audio attachment as PTT to %s", phone)
-			return nil
+			return resp.MessageID, nil
+		}
+
+		// Without ffmpeg only formats WhatsApp plays as they are can go out; anything else would
+		// arrive as an unplayable file, so the agent is told instead.
+		var ffmpegErr pkgError.FFmpegUnavailableError
+		if errors.As(err, &ffmpegErr) {
+			if _, ok := playableAudioExtensions[attachmentExtension(att)]; !ok {
+				return "", err
+			}
 		}
 
 		logrus.Warnf("Chatwoot Webhook: Failed to send as PTT audio (%v), retrying as regular audio...", err)
 
 		reqAudio := domainSend.AudioRequest{
 			BaseRequest: domainSend.BaseRequest{Phone: phone},
-			AudioURL:    &att.DataURL,
+			Audio:       upload,
 			PTT:         false,
 		}
-		_, err = h.SendUsecase.SendAudio(c.Context(), reqAudio)
+		resp, err = h.SendUsecase.SendAudio(chatwootSendContext(c), reqAudio)
 		if err == nil {
 			logrus.Infof("Chatwoot Webhook: Sent audio attachment as regular audio to %s", phone)
-			return nil
+			return resp.MessageID, nil
 		}
 
 		logrus.Warnf("Chatwoot Webhook: Failed to send as regular audio (%v), retrying as file...", err)
 		// Last fallback to file
 		reqFile := domainSend.FileRequest{
 			BaseRequest: domainSend.BaseRequest{Phone: phone},
-			FileURL:     &att.DataURL,
+			File:        upload,
 			Caption:     caption,
+			Filename:    file.Filename,
 		}
-		_, err = h.SendUsecase.SendFile(c.Context(), reqFile)
+		resp, err = h.SendUsecase.SendFile(chatwootSendContext(c), reqFile)
 		if err == nil {
 			logrus.Infof("Chatwoot Webhook: Sent audio attachment as file to %s", phone)
 		}
-		return err
+		return resp.MessageID, err
 	}
 
 	switch att.FileType {
@@ -321,53 +825,124 @@ func (h *ChatwootHandler) handleAttachment(c *fiber.Ctx, phone string, att chatw
 		req := domainSend.ImageRequest{
 			BaseRequest: domainSend.BaseRequest{Phone: phone},
 			Caption:     caption,
-			ImageURL:    &att.DataURL,
+			Image:       upload,
 		}
-		_, err := h.SendUsecase.SendImage(c.Context(), req)
+		resp, err := h.SendUsecase.SendImage(chatwootSendContext(c), req)
 		if err == nil {
 			logrus.Infof("Chatwoot Webhook: Sent image attachment to %s", phone)
 		}
-		return err
+		return resp.MessageID, err
 
 	case "video":
 		req := domainSend.VideoRequest{
 			BaseRequest: domainSend.BaseRequest{Phone: phone},
 			Caption:     caption,
-			VideoURL:    &att.DataURL,
+			Video:       upload,
 		}
-		_, err := h.SendUsecase.SendVideo(c.Context(), req)
+		resp, err := h.SendUsecase.SendVideo(chatwootSendContext(c), req)
 		if err == nil {
 			logrus.Infof("Chatwoot Webhook: Sent video attachment to %s", phone)
 		}
-		return err
+		return resp.MessageID, err
 
 	default:
 		// Default to file for other types
 		req := domainSend.FileRequest{
 			BaseRequest: domainSend.BaseRequest{Phone: phone},
-			FileURL:     &att.DataURL,
+			File:        upload,
 			Caption:     caption,
+			Filename:    file.Filename,
 		}
-		_, err := h.SendUsecase.SendFile(c.Context(), req)
+		resp, err := h.SendUsecase.SendFile(chatwootSendContext(c), req)
 		if err == nil {
 			logrus.Infof("Chatwoot Webhook: Sent file attachment to %s", phone)
 		}
-		return err
+		return resp.MessageID, err
+	}
+}
+
+// sentMessagesKey holds the WhatsApp messages a webhook request sent, for its response.
+const sentMessagesKey = "chatwoot_sent_messages"
+
+// trackSentMessage remembers the chat, Chatwoot conversation and message a WhatsApp message was sent
+// for, so a later delivery failure can be posted there and neither its WhatsApp echo nor a webhook
+// retry is bridged again. The message is listed in the webhook response too.
+func (h *ChatwootHandler) trackSentMessage(c *fiber.Ctx, messageID, destination string, conversationID, chatwootMessageID int) {
+	whatsapp.MarkSentFromChatwoot(messageID)
+	if messageID == "" {
+		return
+	}
+	sent := &domainChatStorage.ChatwootSentMessage{
+		MessageID:         messageID,
+		ChatJID:           auditChatJID(destination),
+		ConversationID:    conversationID,
+		ChatwootMessageID: chatwootMessageID,
+		CreatedAt:         time.Now().UTC(),
+	}
+	sentMessages, _ := c.Locals(sentMessagesKey).([]*domainChatStorage.ChatwootSentMessage)
+	c.Locals(sentMessagesKey, append(sentMessages, sent))
+	if h.ChatStorageRepo == nil {
+		return
+	}
+	if err := h.ChatStorageRepo.SaveChatwootSentMessage(sent); err != nil {
+		logrus.Warnf("Chatwoot Webhook: Failed to record message %s of conversation %d: %v", messageID, conversationID, err)
+	}
+}
+
+// webhookResult answers a webhook that reached the sending stage with the WhatsApp messages it sent,
+// none when sending failed or was not needed. Failures are still answered with 200 so Chatwoot does
+// not retry them.
+func webhookResult(c *fiber.Ctx, chatwootMessageID int) error {
+	sentMessages, _ := c.Locals(sentMessagesKey).([]*domainChatStorage.ChatwootSentMessage)
+	if sentMessages == nil {
+		sentMessages = []*domainChatStorage.ChatwootSentMessage{}
 	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Sent %d WhatsApp message(s)", len(sentMessages)),
+		Results: map[string]any{
+			"chatwoot_message_id": chatwootMessageID,
+			"messages":            sentMessages,
+		},
+	})
 }
 
 // SyncHistory triggers a message history sync to Chatwoot
 // POST /chatwoot/sync
 func (h *ChatwootHandler) SyncHistory(c *fiber.Ctx) error {
-	// Parse request body
-	var req chatwoot.SyncRequest
-	if err := c.BodyParser(&req); err != nil {
-		// Try query parameters as fallback
-		req.DeviceID = c.Query("device_id", config.ChatwootDeviceID)
-		req.DaysLimit = c.QueryInt("days", config.ChatwootDaysLimitImportMessages)
-		req.IncludeMedia = c.QueryBool("media", config.ChatwootSyncIncludeMedia)
-		req.IncludeGroups = c.QueryBool("groups", config.ChatwootSyncIncludeGroups)
-		req.IncludeStatus = c.QueryBool("status", config.ChatwootSyncIncludeStatus)
+	// A JSON body is parsed strictly; query parameters are only used when no body was sent.
+	// Fields left out of the body keep the configured defaults.
+	settings := chatwoot.CurrentConfig()
+	req := chatwoot.SyncRequest{
+		DeviceID:      config.ChatwootDeviceID,
+		DaysLimit:     settings.DaysLimitImportMessages,
+		IncludeMedia:  settings.SyncIncludeMedia,
+		IncludeGroups: settings.SyncIncludeGroups,
+		IncludeStatus: config.ChatwootSyncIncludeStatus,
+		Concurrency:   config.ChatwootSyncConcurrency,
+	}
+	if body := bytes.TrimSpace(c.Body()); len(body) > 0 {
+		if err := helpers.DecodeStrictJSON(body, &req); err != nil {
+			return sendError(c, CodeInvalidRequest, fmt.Sprintf("Invalid sync request body: %v", err))
+		}
+	} else {
+		req.DeviceID = c.Query("device_id", req.DeviceID)
+		req.DaysLimit = c.QueryInt("days", req.DaysLimit)
+		req.IncludeMedia = c.QueryBool("media", req.IncludeMedia)
+		req.IncludeGroups = c.QueryBool("groups", req.IncludeGroups)
+		req.IncludeStatus = c.QueryBool("status", req.IncludeStatus)
+		req.Concurrency = c.QueryInt("concurrency", req.Concurrency)
+		req.DryRun = c.QueryBool("dry_run", req.DryRun)
+		req.IncludeJIDs = chatwoot.SplitJIDList(c.Query("include_jids"))
+		req.ExcludeJIDs = chatwoot.SplitJIDList(c.Query("exclude_jids"))
+		req.ContactsOnly = c.QueryBool("contacts_only", req.ContactsOnly)
+	}
+	if err := chatwoot.ValidateJIDPatterns(req.IncludeJIDs); err != nil {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("include_jids: %v", err))
+	}
+	if err := chatwoot.ValidateJIDPatterns(req.ExcludeJIDs); err != nil {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("exclude_jids: %v", err))
 	}
 
 	// Default values
@@ -375,31 +950,27 @@ func (h *ChatwootHandler) SyncHistory(c *fiber.Ctx) error {
 		req.DeviceID = config.ChatwootDeviceID
 	}
 	if req.DaysLimit <= 0 {
-		req.DaysLimit = config.ChatwootDaysLimitImportMessages
+		req.DaysLimit = settings.DaysLimitImportMessages
 	}
+	if req.Concurrency <= 0 {
+		req.Concurrency = config.ChatwootSyncConcurrency
+	}
+	req.Concurrency = min(req.Concurrency, chatwoot.MaxSyncConcurrency)
 
 	// Resolve device
 	instance, resolvedID, err := h.DeviceManager.ResolveDevice(req.DeviceID)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(utils.ResponseData{
-			Status:  fiber.StatusBadRequest,
-			Code:    "DEVICE_NOT_FOUND",
-			Message: fmt.Sprintf("Failed to resolve device: %v", err),
-		})
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
 	}
 
 	// Get Chatwoot client
-	cwClient := chatwoot.GetDefaultClient()
+	cwClient := chatwoot.ClientForDevice(resolvedID, instance.JID())
 	if !cwClient.IsConfigured() {
-		return c.Status(fiber.StatusBadRequest).JSON(utils.ResponseData{
-			Status:  fiber.StatusBadRequest,
-			Code:    "CHATWOOT_NOT_CONFIGURED",
-			Message: "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN, CHATWOOT_ACCOUNT_ID, and CHATWOOT_INBOX_ID.",
-		})
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN, CHATWOOT_ACCOUNT_ID, and CHATWOOT_INBOX_ID.")
 	}
 
 	// Get or create sync service
-	syncService := chatwoot.GetSyncService(cwClient, h.ChatStorageRepo)
+	syncService := chatwoot.GetSyncService(chatwoot.GetDefaultClient(), h.ChatStorageRepo)
 	waClient := instance.GetClient()
 
 	// Use JID as the storage device ID since chats are stored with the full JID
@@ -409,19 +980,6 @@ func (h *ChatwootHandler) SyncHistory(c *fiber.Ctx) error {
 		storageDeviceID = resolvedID
 	}
 
-	// Check if already running
-	if syncService.IsRunning(storageDeviceID) {
-		progress := syncService.GetProgress(storageDeviceID)
-		return c.Status(fiber.StatusConflict).JSON(utils.ResponseData{
-			Status:  fiber.StatusConflict,
-			Code:    "SYNC_ALREADY_RUNNING",
-			Message: "A sync is already in progress for this device",
-			Results: map[string]interface{}{
-				"progress": progress,
-			},
-		})
-	}
-
 	// Build sync options
 	opts := chatwoot.DefaultSyncOptions()
 	opts.DaysLimit = req.DaysLimit
@@ -432,15 +990,42 @@ func (h *ChatwootHandler) SyncHistory(c *fiber.Ctx) error {
 	opts.BatchSize = config.ChatwootSyncBatchSize
 	opts.DelayBetweenBatches = time.Duration(config.ChatwootSyncDelayMs) * time.Millisecond
 	opts.MaxMediaFileSize = config.ChatwootSyncMaxMediaFileSize
+	opts.Concurrency = req.Concurrency
+	opts.DryRun = req.DryRun
+	opts.IncludeJIDs = req.IncludeJIDs
+	opts.ExcludeJIDs = req.ExcludeJIDs
+	opts.ContactsOnly = req.ContactsOnly
 
-	// Start async sync
-	go func() {
-		ctx := context.Background()
-		progress, err := syncService.SyncHistory(ctx, storageDeviceID, waClient, opts)
+	// A dry run only reads local storage, so it answers right away and may run next to a sync
+	if opts.DryRun {
+		progress, err := syncService.SyncHistory(c.Context(), storageDeviceID, waClient, opts)
 		if err != nil {
-			logrus.Errorf("Chatwoot Sync: Failed for device %s: %v", storageDeviceID, err)
-		} else {
-			logrus.Infof("Chatwoot Sync: Completed for device %s - %d/%d messages synced",
+			return sendErrorWithResults(c, CodeInternalError, fmt.Sprintf("Dry run failed: %v", err), progress)
+		}
+		return c.JSON(utils.ResponseData{
+			Status:  200,
+			Code:    "SUCCESS",
+			Message: "Dry run completed; nothing was sent to Chatwoot",
+			Results: progress,
+		})
+	}
+
+	// Check if already running
+	if syncService.IsRunning(storageDeviceID) {
+		progress := syncService.GetProgress(storageDeviceID)
+		return sendErrorWithResults(c, CodeSyncAlreadyRunning, "A sync is already in progress for this device", map[string]interface{}{
+			"progress": progress,
+		})
+	}
+
+	// Start async sync
+	go func() {
+		ctx := context.Background()
+		progress, err := syncService.SyncHistory(ctx, storageDeviceID, waClient, opts)
+		if err != nil {
+			logrus.Errorf("Chatwoot Sync: Failed for device %s: %v", storageDeviceID, err)
+		} else {
+			logrus.Infof("Chatwoot Sync: Completed for device %s - %d/%d messages synced",
 				storageDeviceID, progress.SyncedMessages, progress.TotalMessages)
 		}
 	}()
@@ -459,6 +1044,11 @@ func (h *ChatwootHandler) SyncHistory(c *fiber.Ctx) error {
 			"batch_size":               opts.BatchSize,
 			"delay_between_batches_ms": int(opts.DelayBetweenBatches / time.Millisecond),
 			"max_media_file_size":      opts.MaxMediaFileSize,
+			"concurrency":              opts.Concurrency,
+			"include_jids":             opts.IncludeJIDs,
+			"exclude_jids":             opts.ExcludeJIDs,
+			"contacts_only":            opts.ContactsOnly,
+			"ephemeral_mode":           chatwoot.EphemeralMode(),
 		},
 	})
 }
@@ -470,13 +1060,115 @@ func (h *ChatwootHandler) SyncStatus(c *fiber.Ctx) error {
 
 	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(utils.ResponseData{
-			Status:  fiber.StatusBadRequest,
-			Code:    "DEVICE_NOT_FOUND",
-			Message: fmt.Sprintf("Failed to resolve device: %v", err),
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	// chat_jid selects the progress of a POST /chatwoot/sync/chat run
+	chatJID := strings.TrimSpace(c.Query("chat_jid"))
+	var progress *chatwoot.SyncProgress
+	if syncService := chatwoot.GetDefaultSyncService(); syncService != nil {
+		if chatJID != "" {
+			progress = syncService.GetChatSyncProgress(storageDeviceID, chatJID)
+		} else {
+			progress = syncService.GetProgress(storageDeviceID)
+		}
+	}
+	// Nothing ran since startup: report the last run recorded before the restart
+	if progress == nil && chatJID == "" && h.ChatStorageRepo != nil {
+		runs, err := h.ChatStorageRepo.GetChatwootSyncRuns(storageDeviceID, 1)
+		if err != nil {
+			return sendError(c, CodeInternalError, fmt.Sprintf("Failed to load sync runs: %v", err))
+		}
+		if len(runs) > 0 {
+			progress = chatwoot.SyncProgressFromRun(runs[0])
+		}
+	}
+	if progress == nil {
+		return c.JSON(utils.ResponseData{
+			Status:  200,
+			Code:    "SUCCESS",
+			Message: "No sync progress found for this device",
+			Results: map[string]interface{}{
+				"device_id": resolvedID,
+				"status":    "idle",
+			},
 		})
 	}
 
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Sync status retrieved",
+		Results: progress,
+	})
+}
+
+// Limits of GET /chatwoot/sync/history
+const (
+	defaultSyncHistoryLimit = 20
+	maxSyncHistoryLimit     = 100
+)
+
+// SyncRuns lists the past history syncs of a device, newest first
+// GET /chatwoot/sync/history?device_id=&limit=
+func (h *ChatwootHandler) SyncRuns(c *fiber.Ctx) error {
+	deviceID := c.Query("device_id", config.ChatwootDeviceID)
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	limit := c.QueryInt("limit", defaultSyncHistoryLimit)
+	if limit < 1 || limit > maxSyncHistoryLimit {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("limit must be between 1 and %d", maxSyncHistoryLimit))
+	}
+
+	runs, err := h.ChatStorageRepo.GetChatwootSyncRuns(storageDeviceID, limit)
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to load sync runs: %v", err))
+	}
+	if runs == nil {
+		runs = []*domainChatStorage.ChatwootSyncRun{}
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Found %d sync run(s)", len(runs)),
+		Results: map[string]interface{}{
+			"device_id": resolvedID,
+			"runs":      runs,
+		},
+	})
+}
+
+// syncEventsKeepAlive is how often GET /chatwoot/sync/events writes a comment while the sync is
+// quiet, so proxies keep the connection open and a gone client is noticed.
+const syncEventsKeepAlive = 15 * time.Second
+
+// SyncEvents streams the history sync progress of a device as Server-Sent Events: a "progress"
+// event after every change and a final "done" event once the sync completed, failed or was
+// cancelled
+// GET /chatwoot/sync/events?device_id=
+func (h *ChatwootHandler) SyncEvents(c *fiber.Ctx) error {
+	deviceID := c.Query("device_id", config.ChatwootDeviceID)
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
 	storageDeviceID := instance.JID()
 	if storageDeviceID == "" {
 		storageDeviceID = resolvedID
@@ -484,23 +1176,354 @@ func (h *ChatwootHandler) SyncStatus(c *fiber.Ctx) error {
 
 	syncService := chatwoot.GetDefaultSyncService()
 	if syncService == nil {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot sync is not initialized")
+	}
+	updates, unsubscribe := syncService.SubscribeProgress(storageDeviceID)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+		keepAlive := time.NewTicker(syncEventsKeepAlive)
+		defer keepAlive.Stop()
+
+		// Start with the current state so a client does not wait for the next change; a sync that
+		// already ended only sends its final state
+		if current := syncService.GetProgress(storageDeviceID); current != nil {
+			if writeSyncEvent(w, current) != nil || current.IsFinished() {
+				return
+			}
+		}
+		for {
+			select {
+			case progress := <-updates:
+				if writeSyncEvent(w, progress) != nil || progress.IsFinished() {
+					return
+				}
+			case <-keepAlive.C:
+				// A failed write means the client went away
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil || w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+func writeSyncEvent(w *bufio.Writer, progress *chatwoot.SyncProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	event := "progress"
+	if progress.IsFinished() {
+		event = "done"
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// CancelSync stops the running history sync of a device and returns its final progress
+// POST /chatwoot/sync/cancel?device_id=
+func (h *ChatwootHandler) CancelSync(c *fiber.Ctx) error {
+	deviceID := c.Query("device_id", config.ChatwootDeviceID)
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	syncService := chatwoot.GetDefaultSyncService()
+	if syncService == nil || syncService.Cancel(storageDeviceID) != nil {
+		return sendError(c, CodeSyncNotRunning, "No history sync is running for this device")
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SYNC_CANCELLED",
+		Message: "History sync cancelled; a later sync resumes where it stopped",
+		Results: syncService.GetProgress(storageDeviceID),
+	})
+}
+
+// RetryFailedSync exports again, in the background, the messages earlier history syncs of a
+// device failed to export
+// POST /chatwoot/sync/retry-failed?device_id=
+func (h *ChatwootHandler) RetryFailedSync(c *fiber.Ctx) error {
+	deviceID := c.Query("device_id", config.ChatwootDeviceID)
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	cwClient := chatwoot.ClientForDevice(resolvedID, instance.JID())
+	if !cwClient.IsConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN, CHATWOOT_ACCOUNT_ID, and CHATWOOT_INBOX_ID.")
+	}
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	reasons, err := h.ChatStorageRepo.CountChatwootFailedExports(storageDeviceID)
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to count failed messages: %v", err))
+	}
+	queued := 0
+	for _, n := range reasons {
+		queued += n
+	}
+	if queued == 0 {
 		return c.JSON(utils.ResponseData{
 			Status:  200,
 			Code:    "SUCCESS",
-			Message: "No sync has been initiated yet",
+			Message: "No failed messages to retry",
 			Results: map[string]interface{}{
 				"device_id": resolvedID,
-				"status":    "idle",
+				"queued":    0,
 			},
 		})
 	}
 
-	progress := syncService.GetProgress(storageDeviceID)
+	syncService := chatwoot.GetSyncService(chatwoot.GetDefaultClient(), h.ChatStorageRepo)
+	if syncService.IsRunning(storageDeviceID) {
+		return sendErrorWithResults(c, CodeSyncAlreadyRunning, "A sync is already in progress for this device", map[string]interface{}{
+			"progress": syncService.GetProgress(storageDeviceID),
+		})
+	}
+
+	// Failed messages are retried with their media; download failures are why most of them failed
+	opts := chatwoot.DefaultSyncOptions()
+	opts.IncludeMedia = true
+	opts.MaxMediaFileSize = config.ChatwootSyncMaxMediaFileSize
+
+	waClient := instance.GetClient()
+	go func() {
+		if _, err := syncService.RetryFailedExports(context.Background(), storageDeviceID, waClient, opts); err != nil {
+			logrus.Errorf("Chatwoot Sync: Retry of failed messages failed for device %s: %v", storageDeviceID, err)
+		}
+	}()
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SYNC_STARTED",
+		Message: "Retry of failed messages initiated in background",
+		Results: map[string]interface{}{
+			"device_id":       resolvedID,
+			"queued":          queued,
+			"failure_reasons": reasons,
+		},
+	})
+}
+
+// SyncChat syncs the history of one chat to Chatwoot, in the background or, with wait=true, before
+// responding
+// POST /chatwoot/sync/chat?wait=
+func (h *ChatwootHandler) SyncChat(c *fiber.Ctx) error {
+	settings := chatwoot.CurrentConfig()
+	req := chatwoot.SyncChatRequest{
+		DeviceID:     config.ChatwootDeviceID,
+		Days:         settings.DaysLimitImportMessages,
+		IncludeMedia: settings.SyncIncludeMedia,
+	}
+	if err := helpers.DecodeStrictJSON(c.Body(), &req); err != nil {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("Invalid sync request body: %v", err))
+	}
+	req.ChatJID = strings.TrimSpace(req.ChatJID)
+	if !strings.Contains(req.ChatJID, "@") {
+		return sendError(c, CodeInvalidRequest, "chat_jid must be a full JID, e.g. 628123456789@s.whatsapp.net")
+	}
+	if req.DeviceID == "" {
+		req.DeviceID = config.ChatwootDeviceID
+	}
+	if req.Days <= 0 {
+		req.Days = settings.DaysLimitImportMessages
+	}
+	wait := c.QueryBool("wait", false)
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(req.DeviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	cwClient := chatwoot.ClientForDevice(resolvedID, instance.JID())
+	if !cwClient.IsConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN, CHATWOOT_ACCOUNT_ID, and CHATWOOT_INBOX_ID.")
+	}
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	if chat, err := h.ChatStorageRepo.GetChatByDevice(storageDeviceID, req.ChatJID); err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to look up chat: %v", err))
+	} else if chat == nil {
+		return sendError(c, CodeNotFound, fmt.Sprintf("Chat %s not found for device %s", req.ChatJID, resolvedID))
+	}
+
+	// The chat was asked for by name, so group and status filters do not apply
+	opts := chatwoot.DefaultSyncOptions()
+	opts.DaysLimit = req.Days
+	opts.IncludeMedia = req.IncludeMedia
+	opts.IncludeGroups = true
+	opts.IncludeStatus = true
+	opts.MaxMessagesPerChat = config.ChatwootSyncMaxMessagesPerChat
+	opts.BatchSize = config.ChatwootSyncBatchSize
+	opts.DelayBetweenBatches = time.Duration(config.ChatwootSyncDelayMs) * time.Millisecond
+	opts.MaxMediaFileSize = config.ChatwootSyncMaxMediaFileSize
+
+	syncService := chatwoot.GetSyncService(chatwoot.GetDefaultClient(), h.ChatStorageRepo)
+	waClient := instance.GetClient()
+	if running := syncService.RunningSyncForChat(storageDeviceID, req.ChatJID); running != nil {
+		return sendErrorWithResults(c, CodeSyncAlreadyRunning, "A sync is already in progress for this device or chat", map[string]interface{}{
+			"progress": running,
+		})
+	}
+
+	if !wait {
+		go func() {
+			if _, err := syncService.SyncSingleChat(context.Background(), storageDeviceID, req.ChatJID, waClient, opts); err != nil {
+				logrus.Errorf("Chatwoot Sync: Failed to sync chat %s for device %s: %v", req.ChatJID, storageDeviceID, err)
+			}
+		}()
+		return c.JSON(utils.ResponseData{
+			Status:  200,
+			Code:    "SYNC_STARTED",
+			Message: "Chat sync initiated in background",
+			Results: map[string]interface{}{
+				"device_id":     resolvedID,
+				"chat_jid":      req.ChatJID,
+				"days":          opts.DaysLimit,
+				"include_media": opts.IncludeMedia,
+			},
+		})
+	}
+
+	progress, err := syncService.SyncSingleChat(c.Context(), storageDeviceID, req.ChatJID, waClient, opts)
+	switch {
+	case errors.Is(err, chatwoot.ErrSyncAlreadyRunning):
+		return sendErrorWithResults(c, CodeSyncAlreadyRunning, "A sync is already in progress for this device or chat", map[string]interface{}{
+			"progress": progress,
+		})
+	case err != nil:
+		return sendErrorWithResults(c, CodeInternalError, fmt.Sprintf("Failed to sync chat: %v", err), progress)
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Synced chat %s", req.ChatJID),
+		Results: progress,
+	})
+}
+
+// BackfillMedia attaches missing media to messages already imported into Chatwoot
+// POST /chatwoot/media/backfill?device_id=&days=
+func (h *ChatwootHandler) BackfillMedia(c *fiber.Ctx) error {
+	settings := chatwoot.CurrentConfig()
+	deviceID := c.Query("device_id", config.ChatwootDeviceID)
+	days := c.QueryInt("days", settings.DaysLimitImportMessages)
+	if days <= 0 {
+		days = settings.DaysLimitImportMessages
+	}
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	waClient := instance.GetClient()
+	if waClient == nil || !instance.IsConnected() {
+		return sendError(c, CodeDeviceDisconnected, "Device must be connected to download media from WhatsApp")
+	}
+
+	cwClient := chatwoot.ClientForDevice(resolvedID, instance.JID())
+	if !cwClient.IsConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN, CHATWOOT_ACCOUNT_ID, and CHATWOOT_INBOX_ID.")
+	}
+
+	syncService := chatwoot.GetSyncService(chatwoot.GetDefaultClient(), h.ChatStorageRepo)
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	if syncService.IsMediaBackfillRunning(storageDeviceID) {
+		return sendErrorWithResults(c, CodeBackfillAlreadyRunning, "A media backfill is already in progress for this device", map[string]interface{}{
+			"progress": syncService.GetMediaBackfillProgress(storageDeviceID),
+		})
+	}
+
+	opts := chatwoot.DefaultSyncOptions()
+	opts.DaysLimit = days
+	opts.IncludeMedia = true
+	opts.IncludeGroups = settings.SyncIncludeGroups
+	opts.IncludeStatus = config.ChatwootSyncIncludeStatus
+	opts.MaxMessagesPerChat = config.ChatwootSyncMaxMessagesPerChat
+	opts.BatchSize = config.ChatwootSyncBatchSize
+	opts.DelayBetweenBatches = time.Duration(config.ChatwootSyncDelayMs) * time.Millisecond
+	opts.MaxMediaFileSize = config.ChatwootSyncMaxMediaFileSize
+
+	go func() {
+		if _, err := syncService.BackfillMedia(context.Background(), storageDeviceID, waClient, opts); err != nil {
+			logrus.Errorf("Chatwoot Backfill: Failed for device %s: %v", storageDeviceID, err)
+		}
+	}()
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "BACKFILL_STARTED",
+		Message: "Media backfill initiated in background",
+		Results: map[string]interface{}{
+			"device_id":                resolvedID,
+			"days_limit":               opts.DaysLimit,
+			"include_groups":           opts.IncludeGroups,
+			"include_status":           opts.IncludeStatus,
+			"batch_size":               opts.BatchSize,
+			"delay_between_batches_ms": int(opts.DelayBetweenBatches / time.Millisecond),
+			"max_media_file_size":      opts.MaxMediaFileSize,
+		},
+	})
+}
+
+// BackfillMediaStatus returns the current media backfill progress
+// GET /chatwoot/media/backfill/status
+func (h *ChatwootHandler) BackfillMediaStatus(c *fiber.Ctx) error {
+	deviceID := c.Query("device_id", config.ChatwootDeviceID)
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	var progress *chatwoot.MediaBackfillProgress
+	if syncService := chatwoot.GetDefaultSyncService(); syncService != nil {
+		progress = syncService.GetMediaBackfillProgress(storageDeviceID)
+	}
 	if progress == nil {
 		return c.JSON(utils.ResponseData{
 			Status:  200,
 			Code:    "SUCCESS",
-			Message: "No sync progress found for this device",
+			Message: "No media backfill has been initiated for this device",
 			Results: map[string]interface{}{
 				"device_id": resolvedID,
 				"status":    "idle",
@@ -511,7 +1534,101 @@ func (h *ChatwootHandler) SyncStatus(c *fiber.Ctx) error {
 	return c.JSON(utils.ResponseData{
 		Status:  200,
 		Code:    "SUCCESS",
-		Message: "Sync status retrieved",
+		Message: "Media backfill status retrieved",
 		Results: progress,
 	})
 }
+
+// PushMessage creates one stored WhatsApp message in Chatwoot, e.g. after a fix for a message that
+// never appeared there.
+// POST /chatwoot/messages/:wa_message_id/push?device_id=&force=
+func (h *ChatwootHandler) PushMessage(c *fiber.Ctx) error {
+	messageID := strings.TrimSpace(c.Params("wa_message_id"))
+	if messageID == "" {
+		return sendError(c, CodeInvalidRequest, "wa_message_id is required")
+	}
+	deviceID := c.Query("device_id", config.ChatwootDeviceID)
+	force := c.QueryBool("force", false)
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	cwClient := chatwoot.ClientForDevice(resolvedID, instance.JID())
+	if !cwClient.IsConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN, CHATWOOT_ACCOUNT_ID, and CHATWOOT_INBOX_ID.")
+	}
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	opts := chatwoot.DefaultSyncOptions()
+	opts.IncludeMedia = true
+	opts.MaxMediaFileSize = config.ChatwootSyncMaxMediaFileSize
+
+	syncService := chatwoot.GetSyncService(chatwoot.GetDefaultClient(), h.ChatStorageRepo)
+	result, err := syncService.PushMessage(c.Context(), storageDeviceID, messageID, instance.GetClient(), opts, force)
+	switch {
+	case errors.Is(err, chatwoot.ErrPushMessageNotFound):
+		return sendError(c, CodeNotFound, fmt.Sprintf("Message %s not found for device %s", messageID, resolvedID))
+	case errors.Is(err, chatwoot.ErrPushMessageAlreadyExported):
+		return sendErrorWithResults(c, CodeMessageAlreadyExported,
+			fmt.Sprintf("Message %s is already Chatwoot message %d; pass force=true to push it again", messageID, result.PreviousChatwootMessageID), result)
+	case err != nil:
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to push message: %v", err))
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Pushed message %s to Chatwoot conversation %d", messageID, result.ConversationID),
+		Results: result,
+	})
+}
+
+// maxConversationRebuildChats bounds POST /chatwoot/cache/rebuild, which makes several Chatwoot API
+// calls per chat while the client waits.
+const maxConversationRebuildChats = 200
+
+// RebuildConversationCache re-resolves the Chatwoot conversations of recently active chats, e.g.
+// after CHATWOOT_INBOX_ID changed and the stored Chatwoot IDs were dropped at startup.
+// POST /chatwoot/cache/rebuild?device_id=&limit=
+func (h *ChatwootHandler) RebuildConversationCache(c *fiber.Ctx) error {
+	deviceID := c.Query("device_id", config.ChatwootDeviceID)
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > maxConversationRebuildChats {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("limit must be between 1 and %d", maxConversationRebuildChats))
+	}
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(deviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+
+	cwClient := chatwoot.ClientForDevice(resolvedID, instance.JID())
+	if !cwClient.IsConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN, CHATWOOT_ACCOUNT_ID, and CHATWOOT_INBOX_ID.")
+	}
+
+	storageDeviceID := instance.JID()
+	if storageDeviceID == "" {
+		storageDeviceID = resolvedID
+	}
+
+	syncService := chatwoot.GetSyncService(chatwoot.GetDefaultClient(), h.ChatStorageRepo)
+	result, err := syncService.RebuildConversations(c.Context(), storageDeviceID, instance.GetClient(), limit)
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to rebuild conversations: %v", err))
+	}
+	result.DeviceID = resolvedID
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Re-resolved %d of %d recent chats", result.Resolved, result.Chats),
+		Results: result,
+	})
+}
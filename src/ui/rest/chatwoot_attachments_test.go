@@ -0,0 +1,267 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/gofiber/fiber/v2"
+)
+
+// testAttachments is what the fake Chatwoot serves for each extension: a content type and the
+// first bytes of such a file.
+var testAttachments = map[string][2]string{
+	".jpg":  {"image/jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF"},
+	".ogg":  {"audio/ogg", "OggS\x00\x02"},
+	".mp3":  {"audio/mpeg", "ID3\x03\x00"},
+	".webm": {"audio/webm", "\x1a\x45\xdf\xa3"},
+	".pdf":  {"application/pdf", "%PDF-1.4"},
+	".xlsx": {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "PK\x03\x04"},
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// serveTestAttachments makes attachments on https://chatwoot.example downloadable.
+func serveTestAttachments(t *testing.T) {
+	t.Helper()
+	client := &chatwoot.Client{BaseURL: "https://chatwoot.example", APIToken: "t", HTTPClient: &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			file, ok := testAttachments[path.Ext(r.URL.Path)]
+			if !ok {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: r}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {file[0]}},
+				Body:       io.NopCloser(strings.NewReader(file[1])),
+				Request:    r,
+			}, nil
+		}),
+	}}
+	useAttachmentClient(t, client)
+}
+
+func useAttachmentClient(t *testing.T, client *chatwoot.Client) {
+	t.Helper()
+	prev := attachmentClient
+	attachmentClient = func(*fiber.Ctx) *chatwoot.Client { return client }
+	t.Cleanup(func() { attachmentClient = prev })
+}
+
+const threeImagesMessage = `{
+	"event": "message_created",
+	"id": 555500,
+	"message_type": "outgoing",
+	"content": "Here are the photos",
+	"attachments": [
+		{"id": 1, "file_type": "image", "extension": "jpg", "data_url": "https://chatwoot.example/1.jpg"},
+		{"id": 2, "file_type": "image", "extension": "jpg", "data_url": "https://chatwoot.example/2.jpg"},
+		{"id": 3, "file_type": "image", "extension": "jpg", "data_url": "https://chatwoot.example/3.jpg"}
+	],
+	"conversation": {"id": 9160, "meta": {"sender": {"id": 86, "phone_number": "+1 415 555 0100"}}}
+}`
+
+func TestHandleWebhook_CaptionOnlyOnFirstAttachment(t *testing.T) {
+	repo := &sentMessagesRepo{sent: make(map[int][]string), messages: make(map[string]*domainChatStorage.Message)}
+	app, sender := newChatwootWebhookTestAppWithRepo(t, repo)
+
+	postChatwootEvent(t, app, threeImagesMessage)
+
+	if len(sender.images) != 3 {
+		t.Fatalf("expected 3 images, got %d", len(sender.images))
+	}
+	for i, want := range []string{"Here are the photos", "", ""} {
+		if got := sender.images[i].Caption; got != want {
+			t.Errorf("image %d caption = %q, want %q", i+1, got, want)
+		}
+	}
+	if len(sender.texts) != 0 {
+		t.Errorf("expected the caption not to be repeated as text, got %+v", sender.texts)
+	}
+	if ids := repo.sent[555500]; len(ids) != 3 {
+		t.Errorf("expected all 3 images to be recorded for the Chatwoot message, got %v", ids)
+	}
+	if len(repo.audit) != 4 || repo.audit[0].Decision != domainChatStorage.ChatwootAuditReceived ||
+		repo.audit[3].Decision != domainChatStorage.ChatwootAuditForwarded || repo.audit[3].MessageID != "IMG3" ||
+		repo.audit[3].ChatJID != "14155550100@s.whatsapp.net" || repo.audit[3].ChatwootMessageID != 555500 {
+		t.Errorf("expected the message to be audited as received then forwarded per image, got %+v", repo.audit)
+	}
+}
+
+func TestHandleWebhook_FailedAttachmentsReportedOnce(t *testing.T) {
+	notes := recordPrivateNotes(t)
+	app, sender := newChatwootWebhookTestApp(t)
+	sender.imageErr = func(req domainSend.ImageRequest) error {
+		if req.Image.Filename != "2.jpg" {
+			return errors.New("download failed")
+		}
+		return nil
+	}
+
+	postChatwootEvent(t, app, threeImagesMessage)
+
+	// The caption of the failed first image moves to the second
+	if len(sender.images) != 3 || sender.images[0].Caption == "" || sender.images[1].Caption != "Here are the photos" || sender.images[2].Caption != "" {
+		t.Fatalf("unexpected image requests %+v", sender.images)
+	}
+	got := notes()
+	if len(got) != 1 {
+		t.Fatalf("expected one note for both failures, got %q", got)
+	}
+	if !strings.Contains(got[0], "- image 1: download failed") || !strings.Contains(got[0], "- image 3: download failed") {
+		t.Errorf("unexpected note %q", got[0])
+	}
+}
+
+func TestHandleWebhook_AudioCaptionSentAsText(t *testing.T) {
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 555510,
+		"message_type": "outgoing",
+		"content": "Listen to this",
+		"attachments": [{"id": 1, "file_type": "audio", "extension": "ogg", "data_url": "https://chatwoot.example/rec.ogg"}],
+		"conversation": {"id": 9161, "meta": {"sender": {"id": 87, "phone_number": "+1 415 555 0100"}}}
+	}`)
+
+	if len(sender.audios) != 1 || len(sender.texts) != 1 || sender.texts[0].Message != "Listen to this" {
+		t.Fatalf("expected the audio followed by its text, got audios=%+v texts=%+v", sender.audios, sender.texts)
+	}
+}
+
+func TestHandleWebhook_DownloadsAttachmentWithToken(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api_access_token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte(testAttachments[".jpg"][1]))
+	}))
+	t.Cleanup(srv.Close)
+	app, sender := newChatwootWebhookTestApp(t)
+	useAttachmentClient(t, &chatwoot.Client{BaseURL: srv.URL, APIToken: "secret", HTTPClient: srv.Client()})
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 555520,
+		"message_type": "outgoing",
+		"content": "Invoice",
+		"attachments": [{"id": 1, "file_type": "image", "data_url": "`+srv.URL+`/rails/active_storage/blobs/redirect/abc/photo.jpg"}],
+		"conversation": {"id": 9162, "meta": {"sender": {"id": 88, "phone_number": "+1 415 555 0100"}}}
+	}`)
+
+	if len(sender.images) != 1 || sender.images[0].ImageURL != nil || sender.images[0].Image == nil {
+		t.Fatalf("expected the image to be sent as a file, got %+v", sender.images)
+	}
+	image := sender.images[0].Image
+	if image.Filename != "photo.jpg" || image.Header.Get("Content-Type") != "image/jpeg" || image.Size != int64(len(testAttachments[".jpg"][1])) {
+		t.Errorf("unexpected upload %s %v %d", image.Filename, image.Header, image.Size)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("expected the downloaded attachment to be removed after sending, found %d files", len(entries))
+	}
+}
+
+func TestHandleWebhook_AttachmentDownloadFailureReported(t *testing.T) {
+	notes := recordPrivateNotes(t)
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 555530,
+		"message_type": "outgoing",
+		"attachments": [
+			{"id": 1, "file_type": "file", "data_url": "https://chatwoot.example/expired.zip"},
+			{"id": 2, "file_type": "image", "data_url": "https://chatwoot.example/report.pdf"}
+		],
+		"conversation": {"id": 9163, "meta": {"sender": {"id": 89, "phone_number": "+1 415 555 0100"}}}
+	}`)
+
+	if len(sender.files) != 0 || len(sender.images) != 0 {
+		t.Fatalf("expected nothing to be sent, got files=%+v images=%+v", sender.files, sender.images)
+	}
+	got := notes()
+	if len(got) != 1 || !strings.Contains(got[0], "- file 1: attachment download failed: HTTP 404") ||
+		!strings.Contains(got[0], "- image 2: attachment is not a valid image (application/pdf)") {
+		t.Errorf("expected both failures in one note, got %q", got)
+	}
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		att      chatwoot.Attachment
+		file     chatwoot.DownloadedAttachment
+		expected string
+	}{
+		{
+			name:     "original name from the webhook",
+			att:      chatwoot.Attachment{FileName: "invoice-2024.pdf", Extension: "pdf"},
+			file:     chatwoot.DownloadedAttachment{Filename: "download", MimeType: "application/pdf"},
+			expected: "invoice-2024.pdf",
+		},
+		{
+			name:     "extension from the webhook",
+			att:      chatwoot.Attachment{Extension: "docx"},
+			file:     chatwoot.DownloadedAttachment{Filename: "contract", MimeType: "application/octet-stream"},
+			expected: "contract.docx",
+		},
+		{
+			name:     "extension from the content type",
+			file:     chatwoot.DownloadedAttachment{Filename: "download", MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+			expected: "download.xlsx",
+		},
+		{
+			name:     "name from the url",
+			file:     chatwoot.DownloadedAttachment{Filename: "report.pdf", MimeType: "application/pdf"},
+			expected: "report.pdf",
+		},
+		{
+			name:     "unknown type",
+			file:     chatwoot.DownloadedAttachment{Filename: "download", MimeType: "application/octet-stream"},
+			expected: "download",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := attachmentFilename(tt.att, &tt.file); got != tt.expected {
+			t.Errorf("%s: attachmentFilename = %q, expected %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestHandleWebhook_FileKeepsOriginalName(t *testing.T) {
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 555540,
+		"message_type": "outgoing",
+		"attachments": [
+			{"id": 1, "file_type": "file", "file_name": "invoice-2024.pdf", "data_url": "https://chatwoot.example/blobs/abc.pdf"},
+			{"id": 2, "file_type": "file", "data_url": "https://chatwoot.example/blobs/sheet.xlsx"}
+		],
+		"conversation": {"id": 9164, "meta": {"sender": {"id": 90, "phone_number": "+1 415 555 0100"}}}
+	}`)
+
+	if len(sender.files) != 2 {
+		t.Fatalf("expected two files, got %+v", sender.files)
+	}
+	if sender.files[0].Filename != "invoice-2024.pdf" || sender.files[1].Filename != "sheet.xlsx" {
+		t.Errorf("expected the original names, got %q and %q", sender.files[0].Filename, sender.files[1].Filename)
+	}
+}
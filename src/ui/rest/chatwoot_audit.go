@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditChatJID turns a chat given as a JID or a phone number into the JID the audit trail is kept by.
+func auditChatJID(chat string) string {
+	chat = strings.TrimSpace(chat)
+	if chat == "" || strings.Contains(chat, "@") {
+		return chat
+	}
+	if phone, err := utils.CleanPhoneForWhatsApp(chat); err == nil {
+		chat = phone
+	}
+	return chat + "@s.whatsapp.net"
+}
+
+// auditWebhook records a decision taken for an agent message. The chat is destination, or when it is
+// not known yet the destination of the conversation.
+func (h *ChatwootHandler) auditWebhook(cw *chatwoot.Client, payload chatwoot.WebhookPayload, destination, decision, reason, waMessageID, detail string) {
+	if destination == "" {
+		cached, ok := cw.ConversationDestination(payload.Conversation.ID)
+		if !ok {
+			cached = webhookDestination(payload.Conversation.Meta.Sender)
+		}
+		destination = cached
+	}
+	chatwoot.RecordAudit(h.ChatStorageRepo, &domainChatStorage.ChatwootAuditEntry{
+		ChatJID:           auditChatJID(destination),
+		MessageID:         waMessageID,
+		Direction:         chatwoot.BridgeToWhatsApp,
+		Decision:          decision,
+		Reason:            reason,
+		ChatwootMessageID: payload.ID,
+		Detail:            detail,
+	})
+}
+
+// Audit returns the latest bridging decisions of a chat in both directions, oldest first.
+// GET /chatwoot/audit?chat_jid=...&limit=100
+func (h *ChatwootHandler) Audit(c *fiber.Ctx) error {
+	chatJID := auditChatJID(c.Query("chat_jid"))
+	if chatJID == "" {
+		return sendError(c, CodeInvalidRequest, "chat_jid is required")
+	}
+	limit := c.QueryInt("limit", defaultAuditLimit)
+	if limit < 1 || limit > maxAuditLimit {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit))
+	}
+	if h.ChatStorageRepo == nil {
+		return sendError(c, CodeInternalError, "Chat storage is not available")
+	}
+
+	entries, err := h.ChatStorageRepo.GetChatwootAuditEntries(chatJID, limit)
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to load the audit log: %v", err))
+	}
+	if entries == nil {
+		entries = []*domainChatStorage.ChatwootAuditEntry{}
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: fmt.Sprintf("Found %d audit entries for %s", len(entries), chatJID),
+		Results: fiber.Map{"chat_jid": chatJID, "entries": entries},
+	})
+}
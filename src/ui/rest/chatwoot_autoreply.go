@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// handlePrivateNote runs the commands agents can type as private notes. Notes that are not a command
+// stay in Chatwoot only.
+func (h *ChatwootHandler) handlePrivateNote(cw *chatwoot.Client, payload chatwoot.WebhookPayload) {
+	if chatwoot.IsInfoCommand(payload.Content) {
+		postPrivateNote(cw, payload.Conversation.ID, h.chatInfoNote(cw, payload.Conversation))
+		return
+	}
+
+	cmd, ok, err := chatwoot.ParseAutoReplyCommand(payload.Content)
+	if !ok {
+		return
+	}
+
+	note := h.applyAutoReplyCommand(cw, payload.Conversation, cmd, err, time.Now())
+	postPrivateNote(cw, payload.Conversation.ID, note)
+}
+
+// postPrivateNote tells the agents of a conversation something only they should see. The note is
+// marked as ours so its webhook echo is not handled again.
+func postPrivateNote(cw *chatwoot.Client, conversationID int, note string) {
+	if !cw.IsConfigured() {
+		return
+	}
+	id, err := cw.CreatePrivateNote(conversationID, note)
+	if err != nil {
+		logrus.Warnf("Chatwoot Webhook: Failed to post private note in conversation %d: %v", conversationID, err)
+		return
+	}
+	cw.MarkMessageAsSent(id)
+}
+
+// applyAutoReplyCommand stores or clears the auto-reply of the conversation's chat and returns the
+// private note that tells the agent what happened.
+func (h *ChatwootHandler) applyAutoReplyCommand(cw *chatwoot.Client, conversation chatwoot.ConversationWebhook, cmd chatwoot.AutoReplyCommand, parseErr error, now time.Time) string {
+	if parseErr != nil {
+		return fmt.Sprintf("Auto-reply not changed: %v", parseErr)
+	}
+	if h.ChatStorageRepo == nil {
+		return "Auto-reply not changed: chat storage is not available"
+	}
+
+	destination, cached := cw.ConversationDestination(conversation.ID)
+	if !cached {
+		destination = webhookDestination(conversation.Meta.Sender)
+	}
+	if destination == "" {
+		return "Auto-reply not changed: this conversation has no WhatsApp number"
+	}
+	if utils.IsGroupJID(destination) {
+		return "Auto-reply not changed: auto-replies are only sent in direct chats"
+	}
+	if strings.HasSuffix(destination, config.WhatsappTypeLid) || (!cached && isLIDContact(conversation.Meta.Sender)) {
+		return "Auto-reply not changed: this contact has no WhatsApp phone number, only a LID"
+	}
+	phone, err := utils.CleanPhoneForWhatsApp(utils.ExtractPhoneFromJID(destination))
+	if err != nil {
+		return fmt.Sprintf("Auto-reply not changed: %v", err)
+	}
+	chatJID := types.NewJID(phone, types.DefaultUserServer).String()
+
+	instance, resolvedID, err := h.resolveWebhookDevice(cw, conversation)
+	if err != nil {
+		return fmt.Sprintf("Auto-reply not changed: %v", err)
+	}
+	deviceID := instance.JID()
+	if deviceID == "" {
+		deviceID = resolvedID
+	}
+
+	if cmd.Off {
+		deleted, err := h.ChatStorageRepo.DeleteChatAutoReply(deviceID, chatJID)
+		if err != nil {
+			logrus.Errorf("Chatwoot Webhook: Failed to clear auto-reply for %s: %v", chatJID, err)
+			return "Auto-reply not changed: failed to clear it, see server logs"
+		}
+		if !deleted {
+			return "No auto-reply was set for this chat"
+		}
+		return "Auto-reply disabled for this chat"
+	}
+
+	reply := &domainChatStorage.ChatAutoReply{
+		DeviceID:       deviceID,
+		ChatJID:        chatJID,
+		Message:        cmd.Text,
+		ConversationID: conversation.ID,
+	}
+	if cmd.For > 0 {
+		expiresAt := now.Add(cmd.For)
+		reply.ExpiresAt = &expiresAt
+	}
+	if err := h.ChatStorageRepo.SaveChatAutoReply(reply); err != nil {
+		logrus.Errorf("Chatwoot Webhook: Failed to save auto-reply for %s: %v", chatJID, err)
+		return "Auto-reply not changed: failed to save it, see server logs"
+	}
+	logrus.Infof("Chatwoot Webhook: Auto-reply set for %s on device %s from conversation %d", chatJID, deviceID, conversation.ID)
+	return chatwoot.AutoReplyConfirmation(reply.Message, reply.ExpiresAt)
+}
+
+// ListAutoReplies returns the per-chat auto-replies that are still active.
+func (h *ChatwootHandler) ListAutoReplies(c *fiber.Ctx) error {
+	if h.ChatStorageRepo == nil {
+		return sendError(c, CodeInternalError, "Chat storage is not available")
+	}
+	replies, err := h.ChatStorageRepo.ListChatAutoReplies(time.Now())
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to list auto-replies: %v", err))
+	}
+	if replies == nil {
+		replies = []*domainChatStorage.ChatAutoReply{}
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Active per-chat auto-replies",
+		Results: fiber.Map{"auto_replies": replies},
+	})
+}
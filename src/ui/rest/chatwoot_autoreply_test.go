@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+)
+
+type memoryAutoReplyRepo struct {
+	domainChatStorage.IChatStorageRepository
+	replies map[string]*domainChatStorage.ChatAutoReply
+}
+
+func (r *memoryAutoReplyRepo) SaveChatAutoReply(reply *domainChatStorage.ChatAutoReply) error {
+	r.replies[reply.DeviceID+"|"+reply.ChatJID] = reply
+	return nil
+}
+
+func (r *memoryAutoReplyRepo) DeleteChatAutoReply(deviceID, chatJID string) (bool, error) {
+	_, ok := r.replies[deviceID+"|"+chatJID]
+	delete(r.replies, deviceID+"|"+chatJID)
+	return ok, nil
+}
+
+func TestApplyAutoReplyCommand(t *testing.T) {
+	repo := &memoryAutoReplyRepo{replies: map[string]*domainChatStorage.ChatAutoReply{}}
+	dm := whatsapp.NewDeviceManager(nil, nil, nil)
+	dm.AddDevice(whatsapp.NewDeviceInstance("test-device", nil, nil))
+	h := &ChatwootHandler{ChatStorageRepo: repo, DeviceManager: dm}
+	conversation := chatwoot.ConversationWebhook{ID: 9201}
+	conversation.Meta.Sender = chatwoot.Contact{ID: 80, PhoneNumber: "+55 11 98765-4321"}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	note := h.applyAutoReplyCommand(chatwoot.GetDefaultClient(), conversation, chatwoot.AutoReplyCommand{Text: "Away until Monday", For: 72 * time.Hour}, nil, now)
+	reply := repo.replies["test-device|5511987654321@s.whatsapp.net"]
+	if reply == nil || reply.Message != "Away until Monday" || reply.ConversationID != 9201 {
+		t.Fatalf("expected the auto-reply to be stored for the contact's chat, got %+v", repo.replies)
+	}
+	if reply.ExpiresAt == nil || !reply.ExpiresAt.Equal(now.Add(72*time.Hour)) {
+		t.Fatalf("expected expiry in 72h, got %v", reply.ExpiresAt)
+	}
+	if !strings.Contains(note, "Away until Monday") || !strings.Contains(note, "2026-10-19 12:00 UTC") {
+		t.Fatalf("expected the note to echo text and expiry, got %q", note)
+	}
+
+	if note := h.applyAutoReplyCommand(chatwoot.GetDefaultClient(), conversation, chatwoot.AutoReplyCommand{Off: true}, nil, now); note != "Auto-reply disabled for this chat" || len(repo.replies) != 0 {
+		t.Fatalf("expected the auto-reply to be cleared, got %q (%v)", note, repo.replies)
+	}
+	if note := h.applyAutoReplyCommand(chatwoot.GetDefaultClient(), conversation, chatwoot.AutoReplyCommand{Off: true}, nil, now); note != "No auto-reply was set for this chat" {
+		t.Fatalf("unexpected note %q", note)
+	}
+
+	if note := h.applyAutoReplyCommand(chatwoot.GetDefaultClient(), conversation, chatwoot.AutoReplyCommand{}, errors.New("usage"), now); !strings.HasPrefix(note, "Auto-reply not changed") {
+		t.Fatalf("expected a parse error note, got %q", note)
+	}
+
+	group := chatwoot.ConversationWebhook{ID: 9202}
+	group.Meta.Sender = chatwoot.Contact{ID: 81, Identifier: "120363000000000001@g.us"}
+	if note := h.applyAutoReplyCommand(chatwoot.GetDefaultClient(), group, chatwoot.AutoReplyCommand{Text: "Away"}, nil, now); !strings.Contains(note, "direct chats") || len(repo.replies) != 0 {
+		t.Fatalf("expected groups to be refused, got %q (%v)", note, repo.replies)
+	}
+
+	lid := chatwoot.ConversationWebhook{ID: 9203}
+	lid.Meta.Sender = chatwoot.Contact{ID: 82, CustomAttributes: map[string]any{"waha_whatsapp_jid": "123456789012345@lid"}}
+	if note := h.applyAutoReplyCommand(chatwoot.GetDefaultClient(), lid, chatwoot.AutoReplyCommand{Text: "Away"}, nil, now); !strings.Contains(note, "LID") || len(repo.replies) != 0 {
+		t.Fatalf("expected LID-only contacts to be refused, got %q (%v)", note, repo.replies)
+	}
+}
@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/helpers"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleBotWebhook answers the events Chatwoot posts to the agent bot. Customer messages of pending,
+// unassigned conversations get the reply of CHATWOOT_BOT_RULES; when the customer asks for a human, or
+// the bot runs out of answers, the conversation is handed to the agents. Group chats are left to them.
+// POST /chatwoot/bot
+func (h *ChatwootHandler) HandleBotWebhook(c *fiber.Ctx) error {
+	if !config.ChatwootBotEnabled {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	var payload chatwoot.WebhookPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return sendError(c, CodeInvalidRequest, "Invalid payload")
+	}
+	cw, err := resolveWebhookClient(c)
+	if err != nil {
+		return sendError(c, CodeNotFound, err.Error())
+	}
+	c.SetUserContext(chatwoot.ContextWithClient(c.UserContext(), cw))
+	logrus.Debugf("Chatwoot Bot: event=%s message_type=%s conversation_id=%d", payload.Event, payload.MessageType, payload.Conversation.ID)
+
+	// A conversation that is created, opened or resolved starts its count of unanswered messages over
+	if strings.HasPrefix(payload.Event, "conversation_") {
+		cw.ForgetBotTurns(payload.EventConversation().ID)
+		return c.SendStatus(fiber.StatusOK)
+	}
+	conversation := payload.Conversation
+	if payload.Event != "message_created" || payload.MessageType != "incoming" || payload.Private ||
+		conversation.Status != "pending" || conversation.Meta.Assignee != nil {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	destination, cached := cw.ConversationDestination(conversation.ID)
+	if !cached {
+		destination = webhookDestination(conversation.Meta.Sender)
+	}
+	if destination == "" || utils.IsGroupJID(destination) {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	turn := cw.DecideBotTurn(conversation.ID, payload.Content)
+	if turn.Reply != "" {
+		h.sendBotReply(c, cw, conversation, destination, turn.Reply)
+	}
+	if turn.Handoff {
+		if err := cw.HandOffConversation(conversation.ID); err != nil {
+			logrus.Errorf("Chatwoot Bot: Failed to hand conversation %d over to the agents: %v", conversation.ID, err)
+		} else {
+			logrus.Infof("Chatwoot Bot: Handed conversation %d over to the agents", conversation.ID)
+		}
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// sendBotReply sends a reply of the agent bot to WhatsApp and posts it to the conversation. It is
+// posted with the source_id of the sent message, so the inbox webhook does not send it again.
+func (h *ChatwootHandler) sendBotReply(c *fiber.Ctx, cw *chatwoot.Client, conversation chatwoot.ConversationWebhook, destination, text string) {
+	instance, _, err := h.resolveWebhookDevice(cw, conversation)
+	if err != nil {
+		logrus.Warnf("Chatwoot Bot: Reply in conversation %d not sent: %v", conversation.ID, err)
+		return
+	}
+	c.SetUserContext(whatsapp.ContextWithDevice(c.UserContext(), instance))
+
+	req := domainSend.MessageRequest{Message: text}
+	req.Phone = destination
+	resp, err := h.SendUsecase.SendText(chatwootSendContext(c), req)
+	if err != nil {
+		logrus.Errorf("Chatwoot Bot: Failed to send reply to %s: %v", destination, err)
+		chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("bot reply to %s: %w", destination, err))
+		return
+	}
+
+	messageID, err := cw.CreateMessage(conversation.ID, text, "outgoing", nil, chatwoot.ForwardedMessageKey(resp.MessageID), "", time.Time{})
+	if err != nil {
+		logrus.Warnf("Chatwoot Bot: Reply sent to %s but not posted to conversation %d: %v", destination, conversation.ID, err)
+	} else {
+		cw.MarkMessageAsSent(messageID)
+	}
+	h.trackSentMessage(c, resp.MessageID, destination, conversation.ID, messageID)
+}
+
+// SetupAgentBot registers the agent bot in Chatwoot, points it at /chatwoot/bot on this server and
+// connects it to the inbox. Running it again reuses the bot.
+// POST /chatwoot/bot/setup
+func (h *ChatwootHandler) SetupAgentBot(c *fiber.Ctx) error {
+	var req chatwoot.AgentBotSetupRequest
+	if len(bytes.TrimSpace(c.Body())) > 0 {
+		if err := helpers.DecodeStrictJSON(c.Body(), &req); err != nil {
+			return sendError(c, CodeInvalidRequest, fmt.Sprintf("Invalid bot setup request body: %v", err))
+		}
+	}
+
+	cwClient := chatwoot.GetDefaultClient()
+	if !cwClient.IsAccountConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN and CHATWOOT_ACCOUNT_ID.")
+	}
+	if _, err := chatwoot.AgentBotWebhookURL(); err != nil {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("Cannot point the agent bot at this server: %v", err))
+	}
+	if req.InboxID == 0 && cwClient.InboxID == 0 {
+		return sendError(c, CodeInvalidRequest, "inbox_id is required when CHATWOOT_INBOX_ID is not set")
+	}
+
+	result, err := cwClient.SetupAgentBot(req)
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to set up the Chatwoot agent bot: %v", err))
+	}
+
+	message := fmt.Sprintf("Chatwoot agent bot %d is set up for inbox %d", result.BotID, result.InboxID)
+	if result.Created {
+		message = fmt.Sprintf("Created Chatwoot agent bot %d for inbox %d", result.BotID, result.InboxID)
+	}
+	if !config.ChatwootBotEnabled {
+		message += "; set CHATWOOT_BOT_ENABLED=true for it to answer"
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: message,
+		Results: result,
+	})
+}
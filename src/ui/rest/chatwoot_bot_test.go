@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/gofiber/fiber/v2"
+)
+
+// recordBotRequests points the webhook client at a server that records the messages posted to
+// conversations and their status changes.
+func recordBotRequests(t *testing.T) func() []string {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		entry := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		switch entry {
+		case "messages":
+			entry = fmt.Sprintf("message %v %v %v", body["message_type"], body["source_id"], body["content"])
+		case "toggle_status":
+			entry = fmt.Sprintf("status %v", body["status"])
+		}
+		mu.Lock()
+		requests = append(requests, entry)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 700}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	prevClient := defaultWebhookClient
+	cw := &chatwoot.Client{BaseURL: srv.URL, APIToken: "token", AccountID: 1, InboxID: 1, HTTPClient: srv.Client()}
+	defaultWebhookClient = func() *chatwoot.Client { return cw }
+	t.Cleanup(func() { defaultWebhookClient = prevClient })
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests...)
+	}
+}
+
+func postBotMessage(t *testing.T, app *fiber.App, content, status, assignee string) {
+	t.Helper()
+	body := fmt.Sprintf(`{
+		"event": "message_created",
+		"id": 880001,
+		"message_type": "incoming",
+		"content": %q,
+		"conversation": {"id": 9160, "inbox_id": 1, "status": %q, "meta": {
+			"sender": {"id": 86, "phone_number": "+1 415 555 0100"}%s
+		}}
+	}`, content, status, assignee)
+	req := httptest.NewRequest("POST", "/chatwoot/bot", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("bot request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandleBotWebhook_RepliesAndHandsOff(t *testing.T) {
+	prevEnabled, prevRules, prevKeywords := config.ChatwootBotEnabled, config.ChatwootBotRules, config.ChatwootBotHandoffKeywords
+	prevMessage, prevTeam, prevFallback := config.ChatwootBotHandoffMessage, config.ChatwootBotHandoffTeamID, config.ChatwootBotFallbackReply
+	config.ChatwootBotEnabled, config.ChatwootBotRules, config.ChatwootBotHandoffKeywords = true, `{"price": "R$ 10"}`, []string{"agente"}
+	config.ChatwootBotHandoffMessage, config.ChatwootBotHandoffTeamID, config.ChatwootBotFallbackReply = "One moment", 0, ""
+	t.Cleanup(func() {
+		config.ChatwootBotEnabled, config.ChatwootBotRules, config.ChatwootBotHandoffKeywords = prevEnabled, prevRules, prevKeywords
+		config.ChatwootBotHandoffMessage, config.ChatwootBotHandoffTeamID, config.ChatwootBotFallbackReply = prevMessage, prevTeam, prevFallback
+	})
+	requests := recordBotRequests(t)
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postBotMessage(t, app, "what is the price?", "pending", "")
+	if len(sender.texts) != 1 || sender.texts[0].Phone != "14155550100" || sender.texts[0].Message != "R$ 10" {
+		t.Fatalf("expected the rule reply to be sent, got %+v", sender.texts)
+	}
+	if got := strings.Join(requests(), "|"); got != "message outgoing wa:WA1 R$ 10" {
+		t.Fatalf("expected the reply to be posted as a forwarded message, got %s", got)
+	}
+
+	// Conversations an agent has, or that are open, are not the bot's
+	postBotMessage(t, app, "price", "open", "")
+	postBotMessage(t, app, "price", "pending", `, "assignee": {"id": 4, "name": "Ana"}`)
+	if len(sender.texts) != 1 {
+		t.Fatalf("expected no reply outside pending unassigned conversations, got %+v", sender.texts)
+	}
+
+	postBotMessage(t, app, "quero um agente", "pending", "")
+	if len(sender.texts) != 2 || sender.texts[1].Message != "One moment" {
+		t.Fatalf("expected the handoff message to be sent, got %+v", sender.texts)
+	}
+	if got := strings.Join(requests(), "|"); got != "message outgoing wa:WA1 R$ 10|message outgoing wa:WA2 One moment|status open" {
+		t.Fatalf("expected the conversation to be opened after the handoff message, got %s", got)
+	}
+
+	config.ChatwootBotEnabled = false
+	postBotMessage(t, app, "price", "pending", "")
+	if len(sender.texts) != 2 {
+		t.Fatalf("expected a disabled bot to stay silent, got %+v", sender.texts)
+	}
+}
@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/helpers"
+	"github.com/gofiber/fiber/v2"
+)
+
+// chatwootConfigView is the Chatwoot config as GET and PUT /chatwoot/config return it, token masked.
+type chatwootConfigView struct {
+	domainChatStorage.ChatwootConfig
+	Locked      bool `json:"locked"`
+	Invalidated bool `json:"invalidated,omitempty"`
+}
+
+func newChatwootConfigView(cfg domainChatStorage.ChatwootConfig) chatwootConfigView {
+	cfg.APIToken = chatwoot.MaskToken(cfg.APIToken)
+	return chatwootConfigView{ChatwootConfig: cfg, Locked: config.ChatwootConfigLocked}
+}
+
+// GetConfig returns the Chatwoot settings in use, with the API token masked.
+// GET /chatwoot/config
+func (h *ChatwootHandler) GetConfig(c *fiber.Ctx) error {
+	cfg := chatwoot.CurrentConfig()
+	if h.ChatStorageRepo != nil && !config.ChatwootConfigLocked {
+		if stored, err := h.ChatStorageRepo.GetChatwootConfig(); err == nil && stored != nil {
+			cfg.UpdatedAt = stored.UpdatedAt
+		}
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Chatwoot config",
+		Results: newChatwootConfigView(cfg),
+	})
+}
+
+// UpdateConfig changes the Chatwoot settings without a restart. Fields left out of the body keep
+// their value, as does an api_token sent back masked. The new settings are checked against Chatwoot
+// before they are saved, and replace the env settings from then on.
+// PUT /chatwoot/config
+func (h *ChatwootHandler) UpdateConfig(c *fiber.Ctx) error {
+	if config.ChatwootConfigLocked {
+		return sendError(c, CodeChatwootConfigLocked, "")
+	}
+
+	current := chatwoot.CurrentConfig()
+	cfg := current
+	if err := helpers.DecodeStrictJSON(c.Body(), &cfg); err != nil {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("Invalid config request body: %v", err))
+	}
+	if cfg.APIToken == "" || cfg.APIToken == chatwoot.MaskToken(current.APIToken) {
+		cfg.APIToken = current.APIToken
+	}
+
+	invalidated, err := chatwoot.UpdateConfig(h.ChatStorageRepo, cfg)
+	switch {
+	case errors.Is(err, chatwoot.ErrConfigLocked):
+		return sendError(c, CodeChatwootConfigLocked, "")
+	case errors.Is(err, chatwoot.ErrInvalidConfig):
+		return sendError(c, CodeInvalidRequest, err.Error())
+	case err != nil:
+		return sendError(c, CodeInternalError, err.Error())
+	}
+
+	view := newChatwootConfigView(chatwoot.CurrentConfig())
+	view.Invalidated = invalidated
+	message := "Chatwoot config updated"
+	if invalidated {
+		message = "Chatwoot config updated; the stored Chatwoot IDs of the previous account/inbox were dropped"
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: message,
+		Results: view,
+	})
+}
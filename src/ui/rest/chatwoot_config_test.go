@@ -0,0 +1,72 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newChatwootConfigTestApp() *fiber.App {
+	handler := &ChatwootHandler{}
+	app := fiber.New()
+	app.Get("/chatwoot/config", handler.GetConfig)
+	app.Put("/chatwoot/config", handler.UpdateConfig)
+	return app
+}
+
+func TestChatwootConfig_GetMasksToken(t *testing.T) {
+	prevURL, prevToken, prevAccount := config.ChatwootURL, config.ChatwootAPIToken, config.ChatwootAccountID
+	config.ChatwootURL, config.ChatwootAPIToken, config.ChatwootAccountID = "https://chat.example.com", "supersecrettoken", 3
+	t.Cleanup(func() {
+		config.ChatwootURL, config.ChatwootAPIToken, config.ChatwootAccountID = prevURL, prevToken, prevAccount
+	})
+
+	resp, err := newChatwootConfigTestApp().Test(httptest.NewRequest("GET", "/chatwoot/config", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var body struct {
+		Results struct {
+			URL       string `json:"url"`
+			APIToken  string `json:"api_token"`
+			AccountID int    `json:"account_id"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if body.Results.APIToken != "****oken" || body.Results.URL != "https://chat.example.com" || body.Results.AccountID != 3 {
+		t.Errorf("unexpected config %+v", body.Results)
+	}
+}
+
+func TestChatwootConfig_PutRejected(t *testing.T) {
+	app := newChatwootConfigTestApp()
+	put := func(body string) int {
+		req := httptest.NewRequest("PUT", "/chatwoot/config", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := put(`{"inbox": 7}`); status != fiber.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown field, got %d", status)
+	}
+	if status := put(`{"url": "not a url", "api_token": "t", "account_id": 1}`); status != fiber.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid config, got %d", status)
+	}
+
+	prevLocked := config.ChatwootConfigLocked
+	config.ChatwootConfigLocked = true
+	t.Cleanup(func() { config.ChatwootConfigLocked = prevLocked })
+	if status := put(`{"inbox_id": 7}`); status != fiber.StatusForbidden {
+		t.Errorf("expected 403 while the config is locked, got %d", status)
+	}
+}
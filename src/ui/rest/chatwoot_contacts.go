@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/helpers"
+	"github.com/gofiber/fiber/v2"
+	"go.mau.fi/whatsmeow"
+)
+
+// LinkContacts links the Chatwoot contacts another integration created to their WhatsApp numbers, so
+// the bridge uses them instead of creating new ones. It runs while the client waits.
+// POST /chatwoot/contacts/link
+func (h *ChatwootHandler) LinkContacts(c *fiber.Ctx) error {
+	req := chatwoot.ContactLinkRequest{DeviceID: config.ChatwootDeviceID}
+	if len(bytes.TrimSpace(c.Body())) > 0 {
+		if err := helpers.DecodeStrictJSON(c.Body(), &req); err != nil {
+			return sendError(c, CodeInvalidRequest, fmt.Sprintf("Invalid contact link request body: %v", err))
+		}
+	}
+	if req.DeviceID == "" {
+		req.DeviceID = config.ChatwootDeviceID
+	}
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(req.DeviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+	waClient := instance.GetClient()
+	if waClient == nil || !instance.IsConnected() || !instance.IsLoggedIn() {
+		return sendError(c, CodeDeviceDisconnected, fmt.Sprintf("Device %s must be connected to check numbers on WhatsApp", resolvedID))
+	}
+
+	cwClient := chatwoot.ClientForDevice(resolvedID, instance.JID())
+	if !cwClient.IsAccountConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN and CHATWOOT_ACCOUNT_ID.")
+	}
+
+	result, err := cwClient.LinkContacts(c.UserContext(), whatsappLookup(waClient), req.DryRun)
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to link Chatwoot contacts: %v", err))
+	}
+	result.DeviceID = resolvedID
+
+	message := fmt.Sprintf("Linked %d of %d Chatwoot contacts to WhatsApp", result.Linked, result.Scanned)
+	if req.DryRun {
+		message = fmt.Sprintf("Would link %d of %d Chatwoot contacts to WhatsApp", result.Linked, result.Scanned)
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: message,
+		Results: result,
+	})
+}
+
+// whatsappLookup checks phone numbers with the WhatsApp account of client, and remembers the answers
+// for the sends that follow.
+func whatsappLookup(client *whatsmeow.Client) chatwoot.WhatsAppLookup {
+	return func(ctx context.Context, phones []string) (map[string]string, error) {
+		queries := make([]string, len(phones))
+		for i, phone := range phones {
+			queries[i] = "+" + phone
+		}
+		responses, err := client.IsOnWhatsApp(ctx, queries)
+		if err != nil {
+			return nil, err
+		}
+		registered := make(map[string]string, len(responses))
+		for _, resp := range responses {
+			phone := strings.TrimPrefix(resp.Query, "+")
+			utils.RememberOnWhatsapp(phone, resp.IsIn)
+			if resp.IsIn {
+				registered[phone] = resp.JID.ToNonAD().String()
+			}
+		}
+		return registered, nil
+	}
+}
+
+// DedupeContacts finds Chatwoot contacts that share a WhatsApp identity and keeps one of each set.
+// It only reports what it would do unless dry_run is false.
+// POST /chatwoot/contacts/dedupe
+func (h *ChatwootHandler) DedupeContacts(c *fiber.Ctx) error {
+	req := chatwoot.ContactDedupeRequest{DeviceID: config.ChatwootDeviceID}
+	if len(bytes.TrimSpace(c.Body())) > 0 {
+		if err := helpers.DecodeStrictJSON(c.Body(), &req); err != nil {
+			return sendError(c, CodeInvalidRequest, fmt.Sprintf("Invalid contact dedupe request body: %v", err))
+		}
+	}
+	if req.DeviceID == "" {
+		req.DeviceID = config.ChatwootDeviceID
+	}
+	dryRun := req.DryRun == nil || *req.DryRun
+
+	instance, resolvedID, err := h.DeviceManager.ResolveDevice(req.DeviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+	cwClient := chatwoot.ClientForDevice(resolvedID, instance.JID())
+	if !cwClient.IsAccountConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN and CHATWOOT_ACCOUNT_ID.")
+	}
+
+	result, err := cwClient.DedupeContacts(c.UserContext(), dryRun, req.Merge)
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to dedupe Chatwoot contacts: %v", err))
+	}
+	result.DeviceID = resolvedID
+
+	message := fmt.Sprintf("Repaired %d duplicate contacts in %d conflicts", result.Repaired, len(result.Conflicts))
+	if dryRun {
+		message = fmt.Sprintf("Found %d duplicate contacts in %d conflicts; nothing was changed", result.Duplicates, len(result.Conflicts))
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: message,
+		Results: result,
+	})
+}
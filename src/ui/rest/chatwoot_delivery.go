@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/sirupsen/logrus"
+)
+
+// deliveryFailureNoteInterval is the least time between two delivery failure notes in one
+// conversation, so a flapping device does not flood it.
+const deliveryFailureNoteInterval = 5 * time.Minute
+
+var deliveryFailureNotes = struct {
+	mu   sync.Mutex
+	last map[int]time.Time
+}{last: make(map[int]time.Time)}
+
+// reportDeliveryFailure tells the agents of a conversation that a reply did not reach WhatsApp, and
+// labels the conversation with ChatwootDeliveryFailedLabel when set. destination may be empty when
+// the failure happened before it was known; each reason becomes a line of the note.
+func reportDeliveryFailure(cw *chatwoot.Client, conversationID int, destination string, reasons ...string) {
+	if conversationID == 0 || len(reasons) == 0 {
+		return
+	}
+	if !allowDeliveryFailureNote(conversationID, time.Now()) {
+		logrus.Debugf("Chatwoot Webhook: Skipping delivery failure note in conversation %d, one was posted recently", conversationID)
+		return
+	}
+
+	to := "WhatsApp"
+	if destination != "" {
+		to = deliveryDestination(destination)
+	}
+	note := fmt.Sprintf("⚠️ Failed to deliver to %s: %s", to, reasons[0])
+	if len(reasons) > 1 {
+		note = fmt.Sprintf("⚠️ Failed to deliver to %s:\n- %s", to, strings.Join(reasons, "\n- "))
+	}
+	postPrivateNote(cw, conversationID, note)
+
+	if config.ChatwootDeliveryFailedLabel == "" {
+		return
+	}
+	if !cw.IsConfigured() {
+		return
+	}
+	if err := cw.AddConversationLabel(conversationID, config.ChatwootDeliveryFailedLabel); err != nil {
+		logrus.Warnf("Chatwoot Webhook: Failed to label conversation %d as %s: %v", conversationID, config.ChatwootDeliveryFailedLabel, err)
+	}
+}
+
+// allowDeliveryFailureNote reports whether a failure note may be posted in the conversation at now,
+// recording it when so.
+func allowDeliveryFailureNote(conversationID int, now time.Time) bool {
+	deliveryFailureNotes.mu.Lock()
+	defer deliveryFailureNotes.mu.Unlock()
+
+	if last, ok := deliveryFailureNotes.last[conversationID]; ok && now.Sub(last) < deliveryFailureNoteInterval {
+		return false
+	}
+	for id, last := range deliveryFailureNotes.last {
+		if now.Sub(last) >= deliveryFailureNoteInterval {
+			delete(deliveryFailureNotes.last, id)
+		}
+	}
+	deliveryFailureNotes.last[conversationID] = now
+	return true
+}
+
+// deliveryDestination shows a webhook destination the way agents know it: phone numbers with a
+// leading +, groups and other JIDs as they are.
+func deliveryDestination(destination string) string {
+	if strings.Contains(destination, "@") {
+		if user, server, _ := strings.Cut(destination, "@"); server == "s.whatsapp.net" {
+			return "+" + user
+		}
+		return destination
+	}
+	return "+" + strings.TrimPrefix(destination, "+")
+}
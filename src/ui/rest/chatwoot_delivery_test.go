@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func postFailingReply(t *testing.T, post func(string), id, conversationID int) {
+	t.Helper()
+	post(fmt.Sprintf(`{
+		"event": "message_created",
+		"id": %d,
+		"message_type": "outgoing",
+		"content": "Your order has shipped",
+		"conversation": {"id": %d, "meta": {"sender": {"id": 86, "phone_number": "+1 415 555 0100"}}}
+	}`, id, conversationID))
+}
+
+func TestHandleWebhook_SendFailurePostsPrivateNote(t *testing.T) {
+	notes := recordPrivateNotes(t)
+	app, sender := newChatwootWebhookTestApp(t)
+	sender.textErr = errors.New("device is not connected")
+
+	postFailingReply(t, func(body string) { postChatwootEvent(t, app, body) }, 556000, 9170)
+
+	got := notes()
+	if len(got) != 1 {
+		t.Fatalf("expected one private note, got %q", got)
+	}
+	if want := "⚠️ Failed to deliver to +14155550100: device is not connected"; got[0] != want {
+		t.Errorf("note = %q, want %q", got[0], want)
+	}
+}
+
+func TestHandleWebhook_SendFailureNotesAreRateLimited(t *testing.T) {
+	notes := recordPrivateNotes(t)
+	app, sender := newChatwootWebhookTestApp(t)
+	sender.textErr = errors.New("device is not connected")
+	post := func(body string) { postChatwootEvent(t, app, body) }
+
+	postFailingReply(t, post, 556010, 9171)
+	postFailingReply(t, post, 556011, 9171)
+	postFailingReply(t, post, 556012, 9172)
+
+	got := notes()
+	if len(got) != 2 {
+		t.Fatalf("expected one note per conversation, got %q", got)
+	}
+	if len(sender.texts) != 3 {
+		t.Errorf("expected every reply to be attempted, got %d", len(sender.texts))
+	}
+}
+
+func TestAllowDeliveryFailureNote(t *testing.T) {
+	now := time.Now()
+	if !allowDeliveryFailureNote(9180, now) {
+		t.Fatal("expected the first note to be allowed")
+	}
+	if allowDeliveryFailureNote(9180, now.Add(time.Minute)) {
+		t.Error("expected a note within the interval to be skipped")
+	}
+	if !allowDeliveryFailureNote(9180, now.Add(deliveryFailureNoteInterval)) {
+		t.Error("expected a note after the interval to be allowed")
+	}
+}
+
+func TestDeliveryDestination(t *testing.T) {
+	for in, want := range map[string]string{
+		"14155550100":                "+14155550100",
+		"+14155550100":               "+14155550100",
+		"14155550100@s.whatsapp.net": "+14155550100",
+		"120363000000000000@g.us":    "120363000000000000@g.us",
+	} {
+		if got := deliveryDestination(in); got != want {
+			t.Errorf("deliveryDestination(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+)
+
+// editFollowUpPrefix marks the message sent in place of an edit WhatsApp cannot apply.
+const editFollowUpPrefix = "✏️ "
+
+// applyMessageEdit brings an agent's edit of a reply to WhatsApp. Chatwoot sends message_updated for
+// other changes too, so nothing happens while the text matches what was sent. The WhatsApp message is
+// edited when possible; otherwise the new text goes out as a follow-up and a private note tells the
+// agent why.
+func (h *ChatwootHandler) applyMessageEdit(c *fiber.Ctx, payload chatwoot.WebhookPayload, destination string) {
+	cw := webhookClient(c)
+	content := sanitizeText(payload.Content)
+	if content == "" {
+		return
+	}
+
+	var sentIDs []string
+	if h.ChatStorageRepo != nil && payload.ID != 0 {
+		ids, err := h.ChatStorageRepo.GetChatwootSentMessageIDs(payload.ID)
+		if err != nil {
+			logrus.Warnf("Chatwoot Webhook: Failed to look up the WhatsApp messages of Chatwoot message %d: %v", payload.ID, err)
+			return
+		}
+		sentIDs = ids
+	}
+	if len(sentIDs) == 0 {
+		h.sendEditFollowUp(c, payload, destination, content,
+			"This reply was edited, but the WhatsApp message it was sent as is unknown, so the new text was sent as a separate message.")
+		return
+	}
+
+	// The latest message is the text, or a follow-up of an earlier edit
+	target := sentIDs[len(sentIDs)-1]
+	known, prefix := false, ""
+	for _, id := range sentIDs {
+		sent, err := h.ChatStorageRepo.GetMessageByID(id)
+		if err != nil || sent == nil {
+			continue
+		}
+		known = true
+		if sent.Content == content || sent.Content == payload.Content || sent.Content == editFollowUpPrefix+content {
+			return
+		}
+		if id == target && strings.HasPrefix(sent.Content, editFollowUpPrefix) {
+			prefix = editFollowUpPrefix
+		}
+	}
+
+	if len(payload.Attachments) > 0 {
+		if !known {
+			logrus.Debugf("Chatwoot Webhook: Ignoring update of message %d, its WhatsApp messages are not stored", payload.ID)
+			return
+		}
+		h.sendEditFollowUp(c, payload, destination, content,
+			"This reply was edited, but WhatsApp cannot edit the caption of an attachment sent from Chatwoot, so the new text was sent as a separate message.")
+		return
+	}
+
+	req := domainSend.EditMessageRequest{
+		BaseRequest: domainSend.BaseRequest{Phone: destination},
+		MessageID:   target,
+		Message:     prefix + content,
+	}
+	if _, err := h.SendUsecase.EditMessage(chatwootSendContext(c), req); err != nil {
+		if errors.Is(err, pkgError.ErrEditWindowExpired) {
+			h.sendEditFollowUp(c, payload, destination, content, fmt.Sprintf(
+				"This reply was edited, but WhatsApp only allows edits within %d minutes of sending, so the new text was sent as a separate message.",
+				int(whatsmeow.EditWindow.Minutes())))
+			return
+		}
+		logrus.Errorf("Chatwoot Webhook: Failed to edit WhatsApp message %s: %v", target, err)
+		chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("edit of %s to %s: %w", target, destination, err))
+		postPrivateNote(cw, payload.Conversation.ID, fmt.Sprintf("Edit not sent to WhatsApp: %v", err))
+		return
+	}
+
+	logrus.Infof("Chatwoot Webhook: Edited WhatsApp message %s to %s", target, destination)
+	chatwoot.RecordBridgedMessage(chatwoot.BridgeToWhatsApp, destination)
+}
+
+// sendEditFollowUp sends the edited text of a reply as a new message marked with editFollowUpPrefix
+// and posts note to the conversation.
+func (h *ChatwootHandler) sendEditFollowUp(c *fiber.Ctx, payload chatwoot.WebhookPayload, destination, content, note string) {
+	cw := webhookClient(c)
+	req := domainSend.MessageRequest{Message: editFollowUpPrefix + content}
+	req.Phone = destination
+
+	resp, err := h.SendUsecase.SendText(chatwootSendContext(c), req)
+	if err != nil {
+		logrus.Errorf("Chatwoot Webhook: Failed to send edited text to %s: %v", destination, err)
+		chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("edit follow-up to %s: %w", destination, err))
+		reportDeliveryFailure(cw, payload.Conversation.ID, destination, deliveryFailureReason(err))
+		return
+	}
+	h.trackSentMessage(c, resp.MessageID, destination, payload.Conversation.ID, payload.ID)
+
+	logrus.Infof("Chatwoot Webhook: Sent edited text to %s as a new message", destination)
+	chatwoot.RecordBridgedMessage(chatwoot.BridgeToWhatsApp, destination)
+	postPrivateNote(cw, payload.Conversation.ID, note)
+}
+
+// revokeSentMessage deletes for everyone the WhatsApp messages an agent's deleted reply was sent as.
+// When that is not possible a private note tells the agent the customer still sees the reply.
+func (h *ChatwootHandler) revokeSentMessage(c *fiber.Ctx, payload chatwoot.WebhookPayload, destination string) {
+	cw := webhookClient(c)
+	var sentIDs []string
+	if h.ChatStorageRepo != nil && payload.ID != 0 {
+		ids, err := h.ChatStorageRepo.GetChatwootSentMessageIDs(payload.ID)
+		if err != nil {
+			logrus.Warnf("Chatwoot Webhook: Failed to look up the WhatsApp messages of Chatwoot message %d: %v", payload.ID, err)
+			return
+		}
+		sentIDs = ids
+	}
+	if len(sentIDs) == 0 {
+		postPrivateNote(cw, payload.Conversation.ID,
+			"This reply was deleted, but the WhatsApp message it was sent as is unknown, so the customer still sees it.")
+		return
+	}
+
+	var failures []string
+	for _, id := range sentIDs {
+		req := domainSend.RevokeMessageRequest{
+			BaseRequest: domainSend.BaseRequest{Phone: destination},
+			MessageID:   id,
+		}
+		if _, err := h.SendUsecase.RevokeMessage(chatwootSendContext(c), req); err != nil {
+			if errors.Is(err, pkgError.ErrRevokeWindowExpired) {
+				postPrivateNote(cw, payload.Conversation.ID,
+					"This reply was deleted, but it is too old to be deleted for everyone on WhatsApp, so the customer still sees it.")
+				return
+			}
+			logrus.Errorf("Chatwoot Webhook: Failed to revoke WhatsApp message %s: %v", id, err)
+			chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("revoke of %s to %s: %w", id, destination, err))
+			failures = append(failures, err.Error())
+			continue
+		}
+		logrus.Infof("Chatwoot Webhook: Revoked WhatsApp message %s to %s", id, destination)
+	}
+	if len(failures) > 0 {
+		postPrivateNote(cw, payload.Conversation.ID, fmt.Sprintf("This reply was deleted, but deleting it on WhatsApp failed: %s", strings.Join(failures, "; ")))
+		return
+	}
+	chatwoot.RecordBridgedMessage(chatwoot.BridgeToWhatsApp, destination)
+}
@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
+)
+
+func TestHandleWebhook_MessageUpdated(t *testing.T) {
+	repo := &sentMessagesRepo{sent: make(map[int][]string), messages: make(map[string]*domainChatStorage.Message)}
+	app, sender := newChatwootWebhookTestAppWithRepo(t, repo)
+
+	post := func(event string, id int, content string) {
+		postChatwootEvent(t, app, fmt.Sprintf(`{
+			"event": %q,
+			"id": %d,
+			"message_type": "outgoing",
+			"content": %q,
+			"conversation": {"id": 9130, "meta": {"sender": {"id": 82, "phone_number": "+1 415 555 0100"}}}
+		}`, event, id, content))
+	}
+
+	post("message_created", 555300, "See you tomorow")
+	repo.messages["WA1"] = &domainChatStorage.Message{ID: "WA1", Content: "See you tomorow", Timestamp: time.Now()}
+
+	// An update that leaves the text alone, e.g. a status change
+	post("message_updated", 555300, "See you tomorow")
+	if len(sender.edits) != 0 || len(sender.texts) != 1 {
+		t.Fatalf("expected an unchanged update to be ignored, got edits=%+v texts=%d", sender.edits, len(sender.texts))
+	}
+
+	post("message_updated", 555300, "See you tomorrow")
+	if len(sender.edits) != 1 || sender.edits[0].MessageID != "WA1" || sender.edits[0].Message != "See you tomorrow" || sender.edits[0].Phone != "14155550100" {
+		t.Fatalf("expected WA1 to be edited, got %+v", sender.edits)
+	}
+	if len(sender.texts) != 1 {
+		t.Fatalf("expected no follow-up after a successful edit, got %d texts", len(sender.texts))
+	}
+
+	// Past WhatsApp's edit window the new text goes out as a follow-up
+	sender.editErr = pkgError.ErrEditWindowExpired
+	post("message_updated", 555300, "See you on Friday")
+	if len(sender.texts) != 2 || sender.texts[1].Message != "✏️ See you on Friday" {
+		t.Fatalf("expected a follow-up message, got %+v", sender.texts)
+	}
+	if ids := repo.sent[555300]; len(ids) != 2 || ids[1] != "WA2" {
+		t.Fatalf("expected the follow-up to be recorded for the Chatwoot message, got %v", ids)
+	}
+
+	// A reply whose WhatsApp message is unknown
+	post("message_updated", 555301, "Sorry, wrong chat")
+	if len(sender.texts) != 3 || sender.texts[2].Message != "✏️ Sorry, wrong chat" {
+		t.Fatalf("expected a follow-up for an unknown message, got %+v", sender.texts)
+	}
+}
+
+func TestHandleWebhook_MessageUpdatedIgnoresNotes(t *testing.T) {
+	repo := &sentMessagesRepo{sent: make(map[int][]string), messages: make(map[string]*domainChatStorage.Message)}
+	app, sender := newChatwootWebhookTestAppWithRepo(t, repo)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_updated",
+		"id": 555311,
+		"message_type": "outgoing",
+		"private": true,
+		"content": "/poll Lunch? | Pizza | Sushi",
+		"conversation": {"id": 9131, "meta": {"sender": {"id": 83, "phone_number": "+1 415 555 0100"}}}
+	}`)
+
+	if len(sender.edits) != 0 || len(sender.texts) != 0 || len(sender.polls) != 0 {
+		t.Fatalf("expected nothing to be sent, got edits=%+v texts=%+v polls=%+v", sender.edits, sender.texts, sender.polls)
+	}
+}
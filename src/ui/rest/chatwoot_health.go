@@ -0,0 +1,171 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// chatwootHealthCheck is the outcome of one check of GET /chatwoot/health. A skipped check does not
+// make the integration unhealthy.
+type chatwootHealthCheck struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	Skipped   bool   `json:"skipped,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+type chatwootHealthReport struct {
+	Healthy      bool                   `json:"healthy"`
+	CheckedAt    time.Time              `json:"checked_at"`
+	Checks       []chatwootHealthCheck  `json:"checks"`
+	Capabilities *chatwoot.Capabilities `json:"capabilities,omitempty"`
+}
+
+// runHealthCheck times check, which returns a detail and whether it passed.
+func runHealthCheck(name string, check func() (string, bool)) chatwootHealthCheck {
+	start := time.Now()
+	detail, ok := check()
+	return chatwootHealthCheck{Name: name, OK: ok, LatencyMs: time.Since(start).Milliseconds(), Detail: detail}
+}
+
+// Health checks the Chatwoot integration live: configuration, the account API, the inbox, the
+// webhook behind the public URL, the Chatwoot device and the local services, and reports the
+// Chatwoot version with its capabilities. Nothing is cached, so it can back a monitoring probe; it
+// answers 503 when a check fails. An unknown version does not fail it.
+// GET /chatwoot/health
+func (h *ChatwootHandler) Health(c *fiber.Ctx) error {
+	cw := chatwoot.GetDefaultClient()
+	checks := make([]chatwootHealthCheck, 7)
+
+	checks[0] = runHealthCheck("configuration", func() (string, bool) {
+		var missing []string
+		if cw.BaseURL == "" {
+			missing = append(missing, "CHATWOOT_URL")
+		}
+		if cw.APIToken == "" {
+			missing = append(missing, "CHATWOOT_API_TOKEN")
+		}
+		if cw.AccountID == 0 {
+			missing = append(missing, "CHATWOOT_ACCOUNT_ID")
+		}
+		if cw.InboxID == 0 {
+			missing = append(missing, "CHATWOOT_INBOX_ID")
+		}
+		if len(missing) > 0 {
+			return "missing " + strings.Join(missing, ", "), false
+		}
+		return "", cw.IsConfigured()
+	})
+
+	// The remote checks run at once so a slow Chatwoot does not add up.
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		checks[1] = runHealthCheck("chatwoot_api", func() (string, bool) {
+			if !cw.IsAccountConfigured() {
+				return "Chatwoot URL, API token or account ID is missing", false
+			}
+			if err := cw.Ping(); err != nil {
+				return err.Error(), false
+			}
+			return fmt.Sprintf("account %d reachable", cw.AccountID), true
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		checks[2] = runHealthCheck("inbox", func() (string, bool) {
+			if !cw.IsConfigured() {
+				return "Chatwoot is not configured", false
+			}
+			inbox, err := cw.GetInbox(cw.InboxID)
+			if err != nil {
+				return err.Error(), false
+			}
+			return fmt.Sprintf("inbox %d %q", inbox.ID, inbox.Name), true
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		webhookURL, err := chatwoot.InboxWebhookURL()
+		if err != nil {
+			checks[3] = chatwootHealthCheck{Name: "webhook", OK: true, Skipped: true, Detail: err.Error()}
+			return
+		}
+		checks[3] = runHealthCheck("webhook", func() (string, bool) {
+			if err := chatwoot.ProbeWebhook(webhookURL); err != nil {
+				return err.Error(), false
+			}
+			return "webhook answered through the public URL", true
+		})
+	}()
+
+	var capabilities *chatwoot.Capabilities
+	go func() {
+		defer wg.Done()
+		if !cw.IsAccountConfigured() {
+			return
+		}
+		// A version that cannot be read reports known=false with every feature assumed
+		caps, _ := cw.DetectVersion()
+		capabilities = &caps
+	}()
+
+	checks[4] = runHealthCheck("device", func() (string, bool) {
+		if h.DeviceManager == nil {
+			return "device manager is not initialized", false
+		}
+		instance, resolvedID, err := h.DeviceManager.ResolveDevice(config.ChatwootDeviceID)
+		if err != nil {
+			return err.Error(), false
+		}
+		if !instance.IsConnected() {
+			return fmt.Sprintf("device %s is disconnected", resolvedID), false
+		}
+		if !instance.IsLoggedIn() {
+			return fmt.Sprintf("device %s is not logged in", resolvedID), false
+		}
+		return fmt.Sprintf("device %s connected", resolvedID), true
+	})
+	checks[5] = runHealthCheck("sync_service", func() (string, bool) {
+		if chatwoot.GetDefaultSyncService() == nil {
+			return "sync service is not initialized", false
+		}
+		return "", true
+	})
+	checks[6] = runHealthCheck("chat_storage", func() (string, bool) {
+		if h.ChatStorageRepo == nil {
+			return "chat storage is not initialized", false
+		}
+		return "", true
+	})
+	wg.Wait()
+
+	report := chatwootHealthReport{Healthy: true, CheckedAt: time.Now().UTC(), Checks: checks, Capabilities: capabilities}
+	var failed []string
+	for _, check := range checks {
+		if !check.OK {
+			report.Healthy = false
+			failed = append(failed, check.Name)
+		}
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if !report.Healthy {
+		return sendErrorWithResults(c, CodeChatwootUnhealthy, "Chatwoot checks failed: "+strings.Join(failed, ", "), report)
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Chatwoot integration is healthy",
+		Results: report,
+	})
+}
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// chatInfoNote answers #info with the technical details of the WhatsApp chat behind a conversation.
+func (h *ChatwootHandler) chatInfoNote(cw *chatwoot.Client, conversation chatwoot.ConversationWebhook) string {
+	destination, cached := cw.ConversationDestination(conversation.ID)
+	if !cached {
+		destination = webhookDestination(conversation.Meta.Sender)
+	}
+	if destination == "" {
+		return "No WhatsApp chat is linked to this conversation"
+	}
+
+	info := chatwoot.ChatInfo{IsGroup: utils.IsGroupJID(destination)}
+	var client *whatsmeow.Client
+	storageDeviceID := ""
+	if h.DeviceManager == nil {
+		info.DeviceProblem = "no device manager"
+	} else if instance, resolvedID, err := h.resolveWebhookDevice(cw, conversation); err != nil {
+		info.DeviceProblem = err.Error()
+	} else {
+		info.DeviceID = resolvedID
+		info.DeviceJID = instance.JID()
+		info.DeviceState = string(instance.State())
+		client = instance.GetClient()
+		storageDeviceID = info.DeviceJID
+		if storageDeviceID == "" {
+			storageDeviceID = resolvedID
+		}
+	}
+
+	switch {
+	case info.IsGroup:
+		info.ChatJID = destination
+	case strings.HasSuffix(destination, config.WhatsappTypeLid) || (!cached && isLIDContact(conversation.Meta.Sender)):
+		// The digits of a LID are not a phone number: show the number only when the device knows it.
+		chatJID := utils.ResolveLIDToPhone(context.Background(), types.NewJID(digitsOnly(utils.ExtractPhoneFromJID(destination)), types.HiddenUserServer), client)
+		info.ChatJID = chatJID.String()
+		if chatJID.Server == types.DefaultUserServer {
+			info.ChatLink = chatwoot.WhatsAppChatLink(chatJID.User, false)
+		}
+	default:
+		info.ChatJID = types.NewJID(destination, types.DefaultUserServer).String()
+		info.ChatLink = chatwoot.WhatsAppChatLink(destination, false)
+	}
+
+	if h.ChatStorageRepo != nil && storageDeviceID != "" {
+		count, err := h.ChatStorageRepo.GetChatMessageCountByDevice(storageDeviceID, info.ChatJID)
+		if err != nil {
+			logrus.Warnf("Chatwoot Webhook: Failed to count messages of %s: %v", info.ChatJID, err)
+		}
+		info.Messages = count
+
+		state, err := h.ChatStorageRepo.GetChatExportState(storageDeviceID, info.ChatJID)
+		if err != nil {
+			logrus.Warnf("Chatwoot Webhook: Failed to load export state of %s: %v", info.ChatJID, err)
+		}
+		if state != nil {
+			info.LastExported = &state.LastExportedAt
+		}
+	}
+
+	return chatwoot.FormatChatInfo(info)
+}
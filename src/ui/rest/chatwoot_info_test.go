@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+)
+
+type memoryChatInfoRepo struct {
+	domainChatStorage.IChatStorageRepository
+	counts   map[string]int64
+	exported map[string]time.Time
+}
+
+func (r *memoryChatInfoRepo) GetChatMessageCountByDevice(deviceID, chatJID string) (int64, error) {
+	return r.counts[deviceID+"|"+chatJID], nil
+}
+
+func (r *memoryChatInfoRepo) GetChatExportState(deviceID, chatJID string) (*domainChatStorage.ChatExportState, error) {
+	at, ok := r.exported[deviceID+"|"+chatJID]
+	if !ok {
+		return nil, nil
+	}
+	return &domainChatStorage.ChatExportState{DeviceID: deviceID, ChatJID: chatJID, LastExportedAt: at}, nil
+}
+
+func TestChatInfoNote(t *testing.T) {
+	dm := whatsapp.NewDeviceManager(nil, nil, nil)
+	dm.AddDevice(whatsapp.NewDeviceInstance("test-device", nil, nil))
+	repo := &memoryChatInfoRepo{
+		counts:   map[string]int64{"test-device|5511987654321@s.whatsapp.net": 42},
+		exported: map[string]time.Time{"test-device|5511987654321@s.whatsapp.net": time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)},
+	}
+	h := &ChatwootHandler{DeviceManager: dm, ChatStorageRepo: repo}
+
+	conversation := chatwoot.ConversationWebhook{ID: 9301}
+	conversation.Meta.Sender = chatwoot.Contact{ID: 90, PhoneNumber: "+55 11 98765-4321"}
+	note := h.chatInfoNote(chatwoot.GetDefaultClient(), conversation)
+	for _, line := range []string{
+		"Chat JID:     5511987654321@s.whatsapp.net",
+		"Device:       test-device",
+		"Messages:     42 stored",
+		"History sync: 2026-10-01 08:00 UTC",
+		"Chat link:    https://wa.me/5511987654321",
+	} {
+		if !strings.Contains(note, line) {
+			t.Errorf("expected %q in note:\n%s", line, note)
+		}
+	}
+
+	// The digits of a LID are not a phone number, so without a known number there is no link.
+	lid := chatwoot.ConversationWebhook{ID: 9303}
+	lid.Meta.Sender = chatwoot.Contact{ID: 91, CustomAttributes: map[string]any{"waha_whatsapp_jid": "123456789012345@lid"}}
+	note = h.chatInfoNote(chatwoot.GetDefaultClient(), lid)
+	if !strings.Contains(note, "Chat JID:     123456789012345@lid") || !strings.Contains(note, "Chat link:    none") || strings.Contains(note, "wa.me") {
+		t.Errorf("expected the LID without a number or link, got:\n%s", note)
+	}
+
+	unlinked := chatwoot.ConversationWebhook{ID: 9302}
+	if note := h.chatInfoNote(chatwoot.GetDefaultClient(), unlinked); note != "No WhatsApp chat is linked to this conversation" {
+		t.Errorf("unexpected note for a conversation without a number: %q", note)
+	}
+}
+
+func TestHandleWebhook_InfoCommandNeverReachesWhatsApp(t *testing.T) {
+	app, sender := newChatwootWebhookTestApp(t)
+
+	for i, private := range []bool{true, false} {
+		postChatwootEvent(t, app, fmt.Sprintf(`{
+			"event": "message_created",
+			"id": %d,
+			"message_type": "outgoing",
+			"private": %v,
+			"content": "#info",
+			"conversation": {"id": 9130, "meta": {"sender": {"id": 82, "phone_number": "+1 415 555 0101"}}}
+		}`, 555300+i, private))
+	}
+	if len(sender.texts) != 0 {
+		t.Fatalf("expected #info to stay in Chatwoot, got texts %+v", sender.texts)
+	}
+}
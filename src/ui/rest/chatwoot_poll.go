@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// sendPollCommand sends an agent's poll message as a WhatsApp poll and returns the private note that
+// tells the agent whether it went out.
+func (h *ChatwootHandler) sendPollCommand(c *fiber.Ctx, payload chatwoot.WebhookPayload, destination string, cmd chatwoot.PollCommand, parseErr error) string {
+	usage := fmt.Sprintf("Usage: %s Question | Option 1 | Option 2", config.ChatwootPollPrefix)
+	if parseErr != nil {
+		return fmt.Sprintf("Poll not sent: %v. %s", parseErr, usage)
+	}
+
+	req := domainSend.PollRequest{
+		BaseRequest: domainSend.BaseRequest{Phone: destination},
+		Question:    cmd.Question,
+		Options:     cmd.Options,
+		MaxAnswer:   1,
+	}
+	resp, err := h.SendUsecase.SendPoll(chatwootSendContext(c), req)
+	if err != nil {
+		logrus.Errorf("Chatwoot Webhook: Failed to send poll to %s: %v", destination, err)
+		chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("poll to %s: %w", destination, err))
+		return fmt.Sprintf("Poll not sent: %v", err)
+	}
+	h.trackSentMessage(c, resp.MessageID, destination, payload.Conversation.ID, payload.ID)
+
+	logrus.Infof("Chatwoot Webhook: Sent poll to %s", destination)
+	chatwoot.RecordBridgedMessage(chatwoot.BridgeToWhatsApp, destination)
+	return fmt.Sprintf("Poll sent to WhatsApp: %s (%d options). Votes will be posted here.", cmd.Question, len(cmd.Options))
+}
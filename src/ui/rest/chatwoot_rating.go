@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// sendRatingPrompt asks the customer of a conversation resolved in one of CHATWOOT_RATING_PROMPT_INBOXES
+// to rate it. A bare 1-5 reply is then posted to the conversation by the forwarder.
+func (h *ChatwootHandler) sendRatingPrompt(c *fiber.Ctx, payload chatwoot.WebhookPayload) {
+	conversation := payload.EventConversation()
+	if payload.Status != "resolved" || !chatwoot.RatingPromptEnabled(conversation.InboxID) {
+		return
+	}
+	cw := webhookClient(c)
+
+	destination, cached := cw.ConversationDestination(conversation.ID)
+	if !cached {
+		destination = webhookDestination(conversation.Meta.Sender)
+	}
+	if destination == "" || utils.IsGroupJID(destination) {
+		return
+	}
+
+	instance, _, err := h.resolveWebhookDevice(cw, conversation)
+	if err != nil {
+		logrus.Warnf("Chatwoot Webhook: Rating prompt for conversation %d not sent: %v", conversation.ID, err)
+		return
+	}
+	c.SetUserContext(whatsapp.ContextWithDevice(c.UserContext(), instance))
+
+	agent := conversation.Meta.Assignee.DisplayName()
+	if agent == "" {
+		agent = "us"
+	}
+	link := ""
+	if cw.BaseURL != "" && conversation.UUID != "" {
+		link = chatwoot.CSATSurveyLink(cw.BaseURL, conversation.UUID)
+	}
+	req := domainSend.MessageRequest{Message: chatwoot.RatingPromptMessage(config.ChatwootRatingPromptTemplate, agent, link)}
+	req.Phone = destination
+
+	resp, err := h.SendUsecase.SendText(chatwootSendContext(c), req)
+	if err != nil {
+		logrus.Errorf("Chatwoot Webhook: Failed to send rating prompt to %s: %v", destination, err)
+		chatwoot.RecordBridgeError(chatwoot.BridgeToWhatsApp, fmt.Errorf("rating prompt to %s: %w", destination, err))
+		return
+	}
+	h.trackSentMessage(c, resp.MessageID, destination, conversation.ID, 0)
+	if err := chatwoot.TrackRatingPrompt(destination, conversation.ID); err != nil {
+		logrus.Warnf("Chatwoot Webhook: Failed to track rating prompt for conversation %d: %v", conversation.ID, err)
+	}
+
+	logrus.Infof("Chatwoot Webhook: Sent rating prompt for conversation %d to %s", conversation.ID, destination)
+	chatwoot.RecordBridgedMessage(chatwoot.BridgeToWhatsApp, destination)
+}
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+)
+
+func TestHandleWebhook_RatingPromptOnResolve(t *testing.T) {
+	prevInboxes, prevTemplate, prevClient := config.ChatwootRatingPromptInboxes, config.ChatwootRatingPromptTemplate, defaultWebhookClient
+	config.ChatwootRatingPromptInboxes = []string{"3"}
+	config.ChatwootRatingPromptTemplate = "How did {agent} do? Reply 1-5.\n{link}"
+	defaultWebhookClient = func() *chatwoot.Client { return &chatwoot.Client{BaseURL: "https://cw.example.com"} }
+	t.Cleanup(func() {
+		config.ChatwootRatingPromptInboxes, config.ChatwootRatingPromptTemplate, defaultWebhookClient = prevInboxes, prevTemplate, prevClient
+	})
+	app, sender := newChatwootWebhookTestApp(t)
+
+	post := func(inbox int, status string) {
+		postChatwootEvent(t, app, fmt.Sprintf(`{
+			"event": "conversation_status_changed",
+			"id": 9150,
+			"uuid": "6f1c2a3b-0000-4000-8000-000000009150",
+			"inbox_id": %d,
+			"status": %q,
+			"meta": {
+				"sender": {"id": 85, "phone_number": "+1 415 555 0100"},
+				"assignee": {"id": 4, "name": "Ana Souza", "available_name": "Ana"}
+			}
+		}`, inbox, status))
+	}
+
+	post(3, "open")
+	post(4, "resolved")
+	if len(sender.texts) != 0 {
+		t.Fatalf("expected no prompt for a reopened conversation or another inbox, got %+v", sender.texts)
+	}
+
+	post(3, "resolved")
+	if len(sender.texts) != 1 {
+		t.Fatalf("expected one rating prompt, got %+v", sender.texts)
+	}
+	want := "How did Ana do? Reply 1-5.\nhttps://cw.example.com/survey/responses/6f1c2a3b-0000-4000-8000-000000009150"
+	if got := sender.texts[0]; got.Phone != "14155550100" || got.Message != want {
+		t.Fatalf("unexpected prompt %+v", got)
+	}
+}
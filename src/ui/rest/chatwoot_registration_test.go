@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+)
+
+func stubCheckOnWhatsapp(t *testing.T, registered bool) *[]string {
+	t.Helper()
+	var checked []string
+	orig := checkOnWhatsapp
+	checkOnWhatsapp = func(_ context.Context, _ *whatsmeow.Client, jid string) (bool, error) {
+		checked = append(checked, jid)
+		return registered, nil
+	}
+	t.Cleanup(func() { checkOnWhatsapp = orig })
+	return &checked
+}
+
+func TestHandleWebhook_UnregisteredNumberIsNotSent(t *testing.T) {
+	notes := recordPrivateNotes(t)
+	checked := stubCheckOnWhatsapp(t, false)
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 556100,
+		"message_type": "outgoing",
+		"content": "Hello",
+		"conversation": {"id": 9190, "meta": {"sender": {"id": 90, "phone_number": "+55 11 90000-0001"}}}
+	}`)
+
+	if len(sender.texts) != 0 {
+		t.Fatalf("expected nothing sent to an unregistered number, got %+v", sender.texts)
+	}
+	if len(*checked) != 1 || (*checked)[0] != "5511900000001" {
+		t.Errorf("unexpected checks %v", *checked)
+	}
+	got := notes()
+	if len(got) != 1 || !strings.Contains(got[0], "+5511900000001: the number is not registered on WhatsApp") {
+		t.Errorf("unexpected notes %q", got)
+	}
+}
+
+func TestHandleWebhook_RegistrationNotCheckedForGroupsAndLIDs(t *testing.T) {
+	checked := stubCheckOnWhatsapp(t, false)
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 556110,
+		"message_type": "outgoing",
+		"content": "Hello group",
+		"conversation": {"id": 9191, "meta": {"sender": {"id": 91, "identifier": "120363000000000001@g.us"}}}
+	}`)
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 556111,
+		"message_type": "outgoing",
+		"content": "Hello",
+		"conversation": {"id": 9192, "meta": {"sender": {"id": 92, "custom_attributes": {"waha_whatsapp_jid": "123456789012345@lid"}}}}
+	}`)
+
+	if len(*checked) != 0 {
+		t.Errorf("expected no registration check, got %v", *checked)
+	}
+	if len(sender.texts) != 2 {
+		t.Errorf("expected both messages sent, got %+v", sender.texts)
+	}
+}
+
+func TestHandleWebhook_InvalidNumberIsNotSent(t *testing.T) {
+	notes := recordPrivateNotes(t)
+	checked := stubCheckOnWhatsapp(t, true)
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 556120,
+		"message_type": "outgoing",
+		"content": "Hello",
+		"conversation": {"id": 9193, "meta": {"sender": {"id": 93, "phone_number": "+55 11 9000"}}}
+	}`)
+
+	if len(sender.texts) != 0 || len(*checked) != 0 {
+		t.Fatalf("expected an invalid number to be neither checked nor sent, got checks %v sends %+v", *checked, sender.texts)
+	}
+	got := notes()
+	if len(got) != 1 || !strings.Contains(got[0], "Failed to deliver to WhatsApp") || !strings.Contains(got[0], "expected 10 or 11") {
+		t.Errorf("unexpected notes %q", got)
+	}
+}
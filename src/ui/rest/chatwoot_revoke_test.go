@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
+)
+
+// recordPrivateNotes points the default Chatwoot client at a server that records the private notes
+// posted by the handler.
+func recordPrivateNotes(t *testing.T) func() []string {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		notes []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Content string `json:"content"`
+			Private bool   `json:"private"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages") && msg.Private {
+			mu.Lock()
+			notes = append(notes, msg.Content)
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cw := chatwoot.GetDefaultClient()
+	origURL, origToken, origAccount, origInbox, origHTTP := cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient
+	cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient = srv.URL, "token", 1, 1, srv.Client()
+	t.Cleanup(func() {
+		cw.BaseURL, cw.APIToken, cw.AccountID, cw.InboxID, cw.HTTPClient = origURL, origToken, origAccount, origInbox, origHTTP
+	})
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), notes...)
+	}
+}
+
+func postDeletion(t *testing.T, post func(string), event string, id int) {
+	t.Helper()
+	post(fmt.Sprintf(`{
+		"event": %q,
+		"id": %d,
+		"message_type": "outgoing",
+		"content": "This message was deleted",
+		"content_attributes": {"deleted": true},
+		"conversation": {"id": 9140, "meta": {"sender": {"id": 84, "phone_number": "+1 415 555 0100"}}}
+	}`, event, id))
+}
+
+func TestHandleWebhook_DeletedReplyRevokesWhatsAppMessages(t *testing.T) {
+	notes := recordPrivateNotes(t)
+	repo := &sentMessagesRepo{
+		sent:     map[int][]string{555400: {"WA10", "WA11"}, 555401: {"WA12"}},
+		messages: make(map[string]*domainChatStorage.Message),
+	}
+	app, sender := newChatwootWebhookTestAppWithRepo(t, repo)
+	post := func(body string) { postChatwootEvent(t, app, body) }
+
+	postDeletion(t, post, "message_updated", 555400)
+	if len(sender.revokes) != 2 || sender.revokes[0].MessageID != "WA10" || sender.revokes[1].MessageID != "WA11" || sender.revokes[0].Phone != "14155550100" {
+		t.Fatalf("expected WA10 and WA11 to be revoked, got %+v", sender.revokes)
+	}
+	if len(sender.edits) != 0 || len(sender.texts) != 0 {
+		t.Fatalf("expected a deletion not to be sent as an edit, got edits=%+v texts=%+v", sender.edits, sender.texts)
+	}
+	if got := notes(); len(got) != 0 {
+		t.Fatalf("expected no note after a successful revoke, got %q", got)
+	}
+
+	postDeletion(t, post, "message_deleted", 555401)
+	if len(sender.revokes) != 3 || sender.revokes[2].MessageID != "WA12" {
+		t.Fatalf("expected message_deleted to revoke WA12, got %+v", sender.revokes)
+	}
+}
+
+func TestHandleWebhook_DeletedReplyFailuresPostNotes(t *testing.T) {
+	notes := recordPrivateNotes(t)
+	repo := &sentMessagesRepo{
+		sent:     map[int][]string{555410: {"OLD"}, 555411: {"BROKEN"}},
+		messages: make(map[string]*domainChatStorage.Message),
+	}
+	app, sender := newChatwootWebhookTestAppWithRepo(t, repo)
+	sender.revokeErr = func(req domainSend.RevokeMessageRequest) error {
+		if req.MessageID == "OLD" {
+			return pkgError.ErrRevokeWindowExpired
+		}
+		return errors.New("server returned error 479")
+	}
+	post := func(body string) { postChatwootEvent(t, app, body) }
+
+	postDeletion(t, post, "message_updated", 555410)
+	postDeletion(t, post, "message_updated", 555411)
+	postDeletion(t, post, "message_updated", 555412)
+
+	got := notes()
+	if len(got) != 3 {
+		t.Fatalf("expected one note per failed deletion, got %q", got)
+	}
+	for i, want := range []string{"too old", "server returned error 479", "is unknown"} {
+		if !strings.Contains(got[i], want) {
+			t.Errorf("note %d = %q, want it to mention %q", i, got[i], want)
+		}
+	}
+}
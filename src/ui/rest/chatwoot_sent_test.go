@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// webhookSentMessages posts a Chatwoot event and returns the WhatsApp messages listed in the response.
+func webhookSentMessages(t *testing.T, app *fiber.App, body string) (int, []domainChatStorage.ChatwootSentMessage) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("webhook request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result struct {
+		Results struct {
+			ChatwootMessageID int                                     `json:"chatwoot_message_id"`
+			Messages          []domainChatStorage.ChatwootSentMessage `json:"messages"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return result.Results.ChatwootMessageID, result.Results.Messages
+}
+
+func TestHandleWebhook_ResponseListsSentMessage(t *testing.T) {
+	repo := &sentMessagesRepo{sent: make(map[int][]string), messages: make(map[string]*domainChatStorage.Message)}
+	app, _ := newChatwootWebhookTestAppWithRepo(t, repo)
+
+	chatwootID, sent := webhookSentMessages(t, app, `{
+		"event": "message_created",
+		"id": 555700,
+		"message_type": "outgoing",
+		"content": "Your order shipped",
+		"conversation": {"id": 9170, "meta": {"sender": {"id": 87, "phone_number": "+1 415 555 0100"}}}
+	}`)
+
+	if chatwootID != 555700 || len(sent) != 1 || sent[0].MessageID != "WA1" || sent[0].ChatJID != "14155550100@s.whatsapp.net" {
+		t.Fatalf("expected WA1 to 14155550100 in the response, got %d %+v", chatwootID, sent)
+	}
+	if len(repo.records) != 1 {
+		t.Fatalf("expected one stored record, got %+v", repo.records)
+	}
+	if got := repo.records[0]; got.MessageID != "WA1" || got.ChatJID != "14155550100@s.whatsapp.net" || got.ConversationID != 9170 || got.ChatwootMessageID != 555700 {
+		t.Errorf("unexpected stored record %+v", got)
+	}
+}
+
+func TestHandleWebhook_ResponseListsEachAttachment(t *testing.T) {
+	repo := &sentMessagesRepo{sent: make(map[int][]string), messages: make(map[string]*domainChatStorage.Message)}
+	app, _ := newChatwootWebhookTestAppWithRepo(t, repo)
+
+	_, sent := webhookSentMessages(t, app, threeImagesMessage)
+
+	if len(sent) != 3 || len(repo.records) != 3 {
+		t.Fatalf("expected one message per image, got %+v and stored %+v", sent, repo.records)
+	}
+	for i, want := range []string{"IMG1", "IMG2", "IMG3"} {
+		if sent[i].MessageID != want || repo.records[i].MessageID != want || repo.records[i].ChatwootMessageID != 555500 {
+			t.Errorf("message %d: got %+v, stored %+v, want %s", i+1, sent[i], repo.records[i], want)
+		}
+	}
+}
+
+func TestHandleWebhook_FailedSendListsNoMessages(t *testing.T) {
+	recordPrivateNotes(t)
+	app, sender := newChatwootWebhookTestApp(t)
+	sender.textErr = errors.New("device is not connected")
+
+	_, sent := webhookSentMessages(t, app, `{
+		"event": "message_created",
+		"id": 555701,
+		"message_type": "outgoing",
+		"content": "hello",
+		"conversation": {"id": 9171, "meta": {"sender": {"id": 88, "phone_number": "+1 415 555 0100"}}}
+	}`)
+	if sent == nil || len(sent) != 0 {
+		t.Errorf("expected an empty list for a failed send, got %+v", sent)
+	}
+}
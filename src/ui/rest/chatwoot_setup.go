@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/ui/rest/helpers"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupInbox provisions the Chatwoot API channel inbox of a device and points its webhook at this
+// server. Running it again reuses the inbox.
+// POST /chatwoot/setup
+func (h *ChatwootHandler) SetupInbox(c *fiber.Ctx) error {
+	req := chatwoot.InboxSetupRequest{DeviceID: config.ChatwootDeviceID}
+	if len(bytes.TrimSpace(c.Body())) > 0 {
+		if err := helpers.DecodeStrictJSON(c.Body(), &req); err != nil {
+			return sendError(c, CodeInvalidRequest, fmt.Sprintf("Invalid setup request body: %v", err))
+		}
+	}
+	if req.DeviceID == "" {
+		req.DeviceID = config.ChatwootDeviceID
+	}
+
+	_, resolvedID, err := h.DeviceManager.ResolveDevice(req.DeviceID)
+	if err != nil {
+		return sendError(c, CodeDeviceNotFound, fmt.Sprintf("Failed to resolve device: %v", err))
+	}
+	req.DeviceID = resolvedID
+
+	cwClient := chatwoot.GetDefaultClient()
+	if !cwClient.IsAccountConfigured() {
+		return sendError(c, CodeChatwootNotConfigured, "Chatwoot is not configured. Set CHATWOOT_URL, CHATWOOT_API_TOKEN and CHATWOOT_ACCOUNT_ID.")
+	}
+	if _, err := chatwoot.InboxWebhookURL(); err != nil {
+		return sendError(c, CodeInvalidRequest, fmt.Sprintf("Cannot point the inbox webhook at this server: %v", err))
+	}
+
+	result, err := cwClient.SetupInbox(h.ChatStorageRepo, req)
+	if err != nil {
+		return sendError(c, CodeInternalError, fmt.Sprintf("Failed to set up the Chatwoot inbox: %v", err))
+	}
+
+	message := fmt.Sprintf("Chatwoot inbox %d is set up for device %s", result.InboxID, result.DeviceID)
+	if result.Created {
+		message = fmt.Sprintf("Created Chatwoot inbox %d for device %s", result.InboxID, result.DeviceID)
+	}
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: message,
+		Results: result,
+	})
+}
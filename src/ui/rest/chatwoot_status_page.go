@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed templates/chatwoot_status.html
+var chatwootStatusPageHTML string
+
+var chatwootStatusPageTemplate = template.Must(template.New("chatwoot_status").Funcs(template.FuncMap{
+	"fmtTime": func(v any) string {
+		switch t := v.(type) {
+		case time.Time:
+			return t.UTC().Format("2006-01-02 15:04:05 UTC")
+		case *time.Time:
+			if t != nil {
+				return t.UTC().Format("2006-01-02 15:04:05 UTC")
+			}
+		}
+		return "-"
+	},
+	"circuitClass": func(state string) string {
+		switch state {
+		case chatwoot.CircuitClosed:
+			return "ok"
+		case chatwoot.CircuitHalfOpen:
+			return "warn"
+		}
+		return "bad"
+	},
+	"progressClass": func(status string) string {
+		switch status {
+		case "completed":
+			return "ok"
+		case "failed":
+			return "bad"
+		}
+		return "warn"
+	},
+}).Parse(chatwootStatusPageHTML))
+
+type chatwootStatusPageData struct {
+	GeneratedAt time.Time
+	Configured  bool
+	ChatwootURL string
+	Bridge      chatwoot.BridgeStatus
+	Maintenance whatsapp.MaintenanceStatus
+	FFmpeg      utils.FFmpegStatus
+	Devices     []chatwootStatusDevice
+}
+
+type chatwootStatusDevice struct {
+	ID        string
+	JID       string
+	Name      string
+	Connected bool
+	LoggedIn  bool
+	Sync      *chatwoot.SyncProgress
+	Backfill  *chatwoot.MediaBackfillProgress
+}
+
+// StatusPage renders a read-only HTML overview of the bridge. It only reads local state, so it
+// keeps working while Chatwoot is unreachable.
+func (h *ChatwootHandler) StatusPage(c *fiber.Ctx) error {
+	cw := chatwoot.GetDefaultClient()
+	data := chatwootStatusPageData{
+		GeneratedAt: time.Now(),
+		Configured:  cw.IsConfigured(),
+		ChatwootURL: cw.BaseURL,
+		Bridge:      chatwoot.GetBridgeStatus(),
+		Maintenance: whatsapp.GetMaintenanceStatus(),
+		FFmpeg:      utils.DetectFFmpeg(),
+	}
+
+	syncService := chatwoot.GetDefaultSyncService()
+	if h.DeviceManager != nil {
+		for _, instance := range h.DeviceManager.ListDevices() {
+			device := chatwootStatusDevice{
+				ID:        instance.ID(),
+				JID:       instance.JID(),
+				Name:      instance.DisplayName(),
+				Connected: instance.IsConnected(),
+				LoggedIn:  instance.IsLoggedIn(),
+			}
+			if syncService != nil {
+				storageID := device.JID
+				if storageID == "" {
+					storageID = device.ID
+				}
+				device.Sync = syncService.GetProgress(storageID)
+				device.Backfill = syncService.GetMediaBackfillProgress(storageID)
+			}
+			data.Devices = append(data.Devices, device)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := chatwootStatusPageTemplate.Execute(&buf, data); err != nil {
+		logrus.Errorf("Chatwoot status page: failed to render: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to render status page")
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/gofiber/fiber/v2"
+)
+
+// waitingChatsRepo has no chats, and only answers once someone follows the sync progress.
+type waitingChatsRepo struct {
+	domainChatStorage.IChatStorageRepository
+	deviceID string
+}
+
+func (r *waitingChatsRepo) GetChats(*domainChatStorage.ChatFilter) ([]*domainChatStorage.Chat, error) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if svc := chatwoot.GetDefaultSyncService(); svc != nil && svc.ProgressSubscribers(r.deviceID) > 0 {
+			return nil, nil
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return nil, fmt.Errorf("nobody subscribed to the sync progress")
+}
+
+func (r *waitingChatsRepo) CreateChatwootSyncRun(*domainChatStorage.ChatwootSyncRun) error {
+	return nil
+}
+
+func (r *waitingChatsRepo) UpdateChatwootSyncRun(*domainChatStorage.ChatwootSyncRun) error {
+	return nil
+}
+
+func TestSyncEvents_StreamsProgressUntilDone(t *testing.T) {
+	const device = "events-device"
+	svc := chatwoot.GetSyncService(nil, &waitingChatsRepo{deviceID: device})
+
+	dm := whatsapp.NewDeviceManager(nil, nil, nil)
+	dm.AddDevice(whatsapp.NewDeviceInstance(device, nil, nil))
+	handler := NewChatwootHandler(nil, nil, dm, nil)
+	app := fiber.New()
+	app.Get("/chatwoot/sync/events", handler.SyncEvents)
+
+	syncDone := make(chan error, 1)
+	go func() {
+		_, err := svc.SyncHistory(context.Background(), device, nil, chatwoot.DefaultSyncOptions())
+		syncDone <- err
+	}()
+	for !svc.IsRunning(device) {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/chatwoot/sync/events?device_id="+device, nil), 5000)
+	if err != nil {
+		t.Fatalf("events request failed: %v", err)
+	}
+	if ct := resp.Header.Get(fiber.HeaderContentType); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %s", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := <-syncDone; err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	var events []string
+	for _, block := range strings.Split(strings.TrimSpace(string(body)), "\n\n") {
+		if name, ok := strings.CutPrefix(strings.SplitN(block, "\n", 2)[0], "event: "); ok {
+			events = append(events, name)
+		}
+	}
+	if len(events) < 2 || events[0] != "progress" || events[len(events)-1] != "done" {
+		t.Fatalf("expected progress events ending with done, got %v in:\n%s", events, body)
+	}
+	if !strings.Contains(string(body), `"status":"running"`) || !strings.Contains(string(body), `"status":"completed"`) {
+		t.Fatalf("expected running and completed snapshots in:\n%s", body)
+	}
+	if n := svc.ProgressSubscribers(device); n != 0 {
+		t.Fatalf("stream left %d subscribers behind", n)
+	}
+}
@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/gofiber/fiber/v2"
+)
+
+type syncRunsRepo struct {
+	domainChatStorage.IChatStorageRepository
+	runs []*domainChatStorage.ChatwootSyncRun
+}
+
+func (r *syncRunsRepo) GetChatwootSyncRuns(deviceID string, limit int) ([]*domainChatStorage.ChatwootSyncRun, error) {
+	var runs []*domainChatStorage.ChatwootSyncRun
+	for _, run := range r.runs {
+		if run.DeviceID == deviceID && len(runs) < limit {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+func TestSyncRuns_ListsPersistedRuns(t *testing.T) {
+	const device = "history-device"
+	finished := time.Now().Add(-time.Hour)
+	repo := &syncRunsRepo{runs: []*domainChatStorage.ChatwootSyncRun{
+		{ID: 2, DeviceID: device, Status: domainChatStorage.ChatwootSyncInterrupted, SyncedMessages: 7, StartedAt: finished.Add(-time.Minute), FinishedAt: &finished},
+		{ID: 1, DeviceID: device, Status: domainChatStorage.ChatwootSyncCompleted, StartedAt: finished.Add(-time.Hour)},
+	}}
+
+	dm := whatsapp.NewDeviceManager(nil, nil, nil)
+	dm.AddDevice(whatsapp.NewDeviceInstance(device, nil, nil))
+	handler := NewChatwootHandler(nil, nil, dm, repo)
+	app := fiber.New()
+	app.Get("/chatwoot/sync/history", handler.SyncRuns)
+	app.Get("/chatwoot/sync/status", handler.SyncStatus)
+
+	var history struct {
+		Results struct {
+			Runs []domainChatStorage.ChatwootSyncRun `json:"runs"`
+		} `json:"results"`
+	}
+	resp, err := app.Test(httptest.NewRequest("GET", "/chatwoot/sync/history?device_id="+device+"&limit=1", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("history request failed: %v (status %d)", err, resp.StatusCode)
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&history)
+	if len(history.Results.Runs) != 1 || history.Results.Runs[0].ID != 2 {
+		t.Fatalf("expected the latest run only, got %+v", history.Results.Runs)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/chatwoot/sync/history?device_id="+device+"&limit=500", nil))
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("limit above the maximum: got status %d, want 400", resp.StatusCode)
+	}
+
+	// Nothing ran in this process, so the status falls back to the latest persisted run
+	var status struct {
+		Results struct {
+			Status         string `json:"status"`
+			SyncedMessages int    `json:"synced_messages"`
+		} `json:"results"`
+	}
+	resp, err = app.Test(httptest.NewRequest("GET", "/chatwoot/sync/status?device_id="+device, nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status request failed: %v (status %d)", err, resp.StatusCode)
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&status)
+	if status.Results.Status != "interrupted" || status.Results.SyncedMessages != 7 {
+		t.Errorf("expected the interrupted run with 7 messages, got %+v", status.Results)
+	}
+}
@@ -0,0 +1,491 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatwoot"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
+	"github.com/gofiber/fiber/v2"
+)
+
+type recordingSendUsecase struct {
+	domainSend.ISendUsecase
+	texts    []domainSend.MessageRequest
+	audios   []domainSend.AudioRequest
+	files    []domainSend.FileRequest
+	polls    []domainSend.PollRequest
+	edits    []domainSend.EditMessageRequest
+	audioErr func(req domainSend.AudioRequest) error
+	editErr  error
+	textErr  error
+
+	revokes   []domainSend.RevokeMessageRequest
+	revokeErr func(req domainSend.RevokeMessageRequest) error
+
+	images   []domainSend.ImageRequest
+	imageErr func(req domainSend.ImageRequest) error
+}
+
+func (r *recordingSendUsecase) SendText(_ context.Context, req domainSend.MessageRequest) (domainSend.GenericResponse, error) {
+	r.texts = append(r.texts, req)
+	if r.textErr != nil {
+		return domainSend.GenericResponse{}, r.textErr
+	}
+	return domainSend.GenericResponse{MessageID: fmt.Sprintf("WA%d", len(r.texts))}, nil
+}
+
+func (r *recordingSendUsecase) EditMessage(_ context.Context, req domainSend.EditMessageRequest) (domainSend.GenericResponse, error) {
+	r.edits = append(r.edits, req)
+	return domainSend.GenericResponse{}, r.editErr
+}
+
+func (r *recordingSendUsecase) RevokeMessage(_ context.Context, req domainSend.RevokeMessageRequest) (domainSend.GenericResponse, error) {
+	r.revokes = append(r.revokes, req)
+	if r.revokeErr != nil {
+		return domainSend.GenericResponse{}, r.revokeErr(req)
+	}
+	return domainSend.GenericResponse{}, nil
+}
+
+func (r *recordingSendUsecase) SendImage(_ context.Context, req domainSend.ImageRequest) (domainSend.GenericResponse, error) {
+	r.images = append(r.images, req)
+	if r.imageErr != nil {
+		if err := r.imageErr(req); err != nil {
+			return domainSend.GenericResponse{}, err
+		}
+	}
+	return domainSend.GenericResponse{MessageID: fmt.Sprintf("IMG%d", len(r.images))}, nil
+}
+
+func (r *recordingSendUsecase) SendAudio(_ context.Context, req domainSend.AudioRequest) (domainSend.GenericResponse, error) {
+	r.audios = append(r.audios, req)
+	if r.audioErr != nil {
+		return domainSend.GenericResponse{}, r.audioErr(req)
+	}
+	return domainSend.GenericResponse{}, nil
+}
+
+func (r *recordingSendUsecase) SendFile(_ context.Context, req domainSend.FileRequest) (domainSend.GenericResponse, error) {
+	r.files = append(r.files, req)
+	return domainSend.GenericResponse{}, nil
+}
+
+func (r *recordingSendUsecase) SendPoll(_ context.Context, req domainSend.PollRequest) (domainSend.GenericResponse, error) {
+	r.polls = append(r.polls, req)
+	return domainSend.GenericResponse{}, nil
+}
+
+// sentMessagesRepo records the WhatsApp messages sent for each Chatwoot message.
+type sentMessagesRepo struct {
+	domainChatStorage.IChatStorageRepository
+	sent     map[int][]string
+	records  []*domainChatStorage.ChatwootSentMessage
+	messages map[string]*domainChatStorage.Message
+	audit    []*domainChatStorage.ChatwootAuditEntry
+}
+
+func (r *sentMessagesRepo) GetMessageByID(id string) (*domainChatStorage.Message, error) {
+	return r.messages[id], nil
+}
+
+func (r *sentMessagesRepo) IsChatwootMessageFromUs(int) (bool, error) { return false, nil }
+
+func (r *sentMessagesRepo) AddChatwootAuditEntry(entry *domainChatStorage.ChatwootAuditEntry) error {
+	r.audit = append(r.audit, entry)
+	return nil
+}
+
+func (r *sentMessagesRepo) PurgeChatwootAuditEntries(time.Time) (int64, error) { return 0, nil }
+
+func (r *sentMessagesRepo) SaveChatwootSentMessage(sent *domainChatStorage.ChatwootSentMessage) error {
+	r.sent[sent.ChatwootMessageID] = append(r.sent[sent.ChatwootMessageID], sent.MessageID)
+	r.records = append(r.records, sent)
+	return nil
+}
+
+func (r *sentMessagesRepo) GetChatwootSentMessageIDs(chatwootMessageID int) ([]string, error) {
+	return r.sent[chatwootMessageID], nil
+}
+
+func newChatwootWebhookTestApp(t *testing.T) (*fiber.App, *recordingSendUsecase) {
+	t.Helper()
+	return newChatwootWebhookTestAppWithRepo(t, nil)
+}
+
+func newChatwootWebhookTestAppWithRepo(t *testing.T, repo domainChatStorage.IChatStorageRepository) (*fiber.App, *recordingSendUsecase) {
+	t.Helper()
+
+	dm := whatsapp.NewDeviceManager(nil, nil, nil)
+	dm.AddDevice(whatsapp.NewDeviceInstance("test-device", nil, nil))
+
+	serveTestAttachments(t)
+	sender := &recordingSendUsecase{}
+	handler := NewChatwootHandler(nil, sender, dm, repo)
+
+	app := fiber.New()
+	app.Post("/chatwoot/webhook", handler.HandleWebhook)
+	app.Post("/chatwoot/webhook/:target", handler.HandleWebhook)
+	app.Post("/chatwoot/bot", handler.HandleBotWebhook)
+	return app, sender
+}
+
+func postChatwootEvent(t *testing.T, app *fiber.App, body string) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("webhook request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+// Sequence sent by Chatwoot when an agent starts a conversation from the "New conversation" dialog:
+// conversation_created carries the contact typed by the agent, then message_created arrives before
+// the forwarder ever touched the contact, so it has no phone or custom attributes yet.
+func TestHandleWebhook_UIInitiatedConversationUsesCachedDestination(t *testing.T) {
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postChatwootEvent(t, app, `{
+		"event": "conversation_created",
+		"id": 9101,
+		"inbox_id": 3,
+		"status": "open",
+		"contact_inbox": {"source_id": "0b4c5f1e-8d3a-4b7e-9a52-6c1d2e3f4a5b"},
+		"meta": {"sender": {"id": 77, "name": "Maria", "phone_number": "+55 (11) 98765-4321", "custom_attributes": {}}},
+		"messages": []
+	}`)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 555001,
+		"message_type": "outgoing",
+		"content": "Olá Maria!",
+		"private": false,
+		"conversation": {"id": 9101, "inbox_id": 3, "meta": {"sender": {"id": 77, "name": "Maria", "custom_attributes": {}}}},
+		"sender": {"id": 1, "name": "Agent"}
+	}`)
+
+	if len(sender.texts) != 1 {
+		t.Fatalf("expected one text to be sent, got %d", len(sender.texts))
+	}
+	if got := sender.texts[0].Phone; got != "5511987654321" {
+		t.Fatalf("expected destination 5511987654321, got %q", got)
+	}
+	if got := sender.texts[0].Message; got != "Olá Maria!" {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+func TestHandleWebhook_FormattedPhoneWithoutConversationEvent(t *testing.T) {
+	app, sender := newChatwootWebhookTestApp(t)
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 555002,
+		"message_type": "outgoing",
+		"content": "hi",
+		"conversation": {"id": 9102, "meta": {"sender": {"id": 78, "phone_number": "+1 (415) 555-0100"}}}
+	}`)
+
+	if len(sender.texts) != 1 || sender.texts[0].Phone != "14155550100" {
+		t.Fatalf("expected one text to 14155550100, got %+v", sender.texts)
+	}
+}
+
+func TestHandleWebhook_UnknownTarget(t *testing.T) {
+	app, sender := newChatwootWebhookTestApp(t)
+
+	req := httptest.NewRequest("POST", "/chatwoot/webhook/nosuchaccount", strings.NewReader(`{
+		"event": "message_created",
+		"id": 555003,
+		"message_type": "outgoing",
+		"content": "hi",
+		"conversation": {"id": 9103, "meta": {"sender": {"id": 78, "phone_number": "+1 415 555 0100"}}}
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("webhook request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown Chatwoot target, got %d", resp.StatusCode)
+	}
+	if len(sender.texts) != 0 {
+		t.Fatalf("expected nothing sent, got %+v", sender.texts)
+	}
+}
+
+// An inbox mapped to a disconnected device answers 422 so Chatwoot retries; an unmapped inbox is
+// served by the default device.
+func TestHandleWebhook_InboxDeviceMap(t *testing.T) {
+	prevMap := config.ChatwootInboxDeviceMap
+	config.ChatwootInboxDeviceMap = []string{"12:test-device"}
+	t.Cleanup(func() { config.ChatwootInboxDeviceMap = prevMap })
+	app, sender := newChatwootWebhookTestApp(t)
+
+	req := httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(`{
+		"event": "message_created",
+		"id": 555005,
+		"message_type": "outgoing",
+		"content": "hi",
+		"conversation": {"id": 9105, "inbox_id": 12, "meta": {"sender": {"id": 78, "phone_number": "+1 415 555 0100"}}}
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("webhook request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an inbox mapped to a disconnected device, got %d", resp.StatusCode)
+	}
+	if len(sender.texts) != 0 {
+		t.Fatalf("expected nothing sent, got %+v", sender.texts)
+	}
+
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 555006,
+		"message_type": "outgoing",
+		"content": "hi",
+		"conversation": {"id": 9106, "inbox_id": 34, "meta": {"sender": {"id": 78, "phone_number": "+1 415 555 0100"}}}
+	}`)
+	if len(sender.texts) != 1 || sender.texts[0].Phone != "14155550100" {
+		t.Fatalf("expected the unmapped inbox to use the default device, got %+v", sender.texts)
+	}
+}
+
+func TestHandleWebhook_SkipsDelayedRetryAndForwardedMessages(t *testing.T) {
+	repo := &sentMessagesRepo{sent: make(map[int][]string)}
+	app, sender := newChatwootWebhookTestAppWithRepo(t, repo)
+
+	message := `{
+		"event": "message_created",
+		"id": 555050,
+		"message_type": "outgoing",
+		"content": "See you tomorrow",
+		"conversation": {"id": 9105, "meta": {"sender": {"id": 79, "phone_number": "+1 415 555 0100"}}}
+	}`
+	postChatwootEvent(t, app, message)
+	if len(sender.texts) != 1 {
+		t.Fatalf("expected one text to be sent, got %d", len(sender.texts))
+	}
+	if ids := repo.sent[555050]; len(ids) != 1 || ids[0] != "WA1" {
+		t.Fatalf("expected Chatwoot message 555050 to map to WA1, got %v", ids)
+	}
+
+	// Chatwoot retries the webhook hours later; only the recorded mapping knows it was sent
+	postChatwootEvent(t, app, message)
+	if len(sender.texts) != 1 {
+		t.Fatalf("expected the retried webhook to be skipped, got %d sends", len(sender.texts))
+	}
+
+	// A message the forwarder created from WhatsApp is never sent back
+	postChatwootEvent(t, app, `{
+		"event": "message_created",
+		"id": 555051,
+		"message_type": "outgoing",
+		"source_id": "wa:3EB0C767D26A1D8B",
+		"content": "sent from the phone",
+		"conversation": {"id": 9105, "meta": {"sender": {"id": 79, "phone_number": "+1 415 555 0100"}}}
+	}`)
+	if len(sender.texts) != 1 {
+		t.Fatalf("expected the forwarded message to be skipped, got %d sends", len(sender.texts))
+	}
+}
+
+func TestHandleWebhook_AudioWithoutFFmpeg(t *testing.T) {
+	noFFmpeg := func(req domainSend.AudioRequest) error {
+		if req.PTT {
+			return pkgError.FFmpegUnavailableError("ffmpeg not installed (required for PTT voice notes)")
+		}
+		return nil
+	}
+	tests := []struct {
+		name       string
+		extension  string
+		wantAudios int
+	}{
+		// A browser recording cannot be played unconverted, so nothing is sent after the PTT attempt
+		{name: "webm recording", extension: "webm", wantAudios: 1},
+		{name: "mp3 goes out as regular audio", extension: "mp3", wantAudios: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, sender := newChatwootWebhookTestApp(t)
+			sender.audioErr = noFFmpeg
+
+			postChatwootEvent(t, app, `{
+				"event": "message_created",
+				"id": 555100,
+				"message_type": "outgoing",
+				"attachments": [{"id": 1, "file_type": "audio", "extension": "`+tt.extension+`", "data_url": "https://chatwoot.example/rec.`+tt.extension+`"}],
+				"conversation": {"id": 9110, "meta": {"sender": {"id": 80, "phone_number": "+1 415 555 0100"}}}
+			}`)
+
+			if len(sender.audios) != tt.wantAudios {
+				t.Fatalf("expected %d audio attempts, got %+v", tt.wantAudios, sender.audios)
+			}
+			if len(sender.files) != 0 {
+				t.Fatalf("expected no file fallback, got %+v", sender.files)
+			}
+		})
+	}
+}
+
+func TestHandleWebhook_PollCommand(t *testing.T) {
+	app, sender := newChatwootWebhookTestApp(t)
+
+	post := func(id int, content string) {
+		postChatwootEvent(t, app, fmt.Sprintf(`{
+			"event": "message_created",
+			"id": %d,
+			"message_type": "outgoing",
+			"content": %q,
+			"conversation": {"id": 9120, "meta": {"sender": {"id": 81, "phone_number": "+1 415 555 0100"}}}
+		}`, id, content))
+	}
+
+	post(555200, "/poll Which time works? | 9:00 | 14:00 | 17:00")
+	if len(sender.polls) != 1 || len(sender.texts) != 0 {
+		t.Fatalf("expected one poll and no text, got polls=%+v texts=%+v", sender.polls, sender.texts)
+	}
+	if poll := sender.polls[0]; poll.Phone != "14155550100" || poll.Question != "Which time works?" || len(poll.Options) != 3 || poll.MaxAnswer != 1 {
+		t.Fatalf("unexpected poll request %+v", poll)
+	}
+
+	post(555201, "/poll Lunch? | Pizza")
+	if len(sender.polls) != 1 || len(sender.texts) != 0 {
+		t.Fatalf("expected an invalid poll to be neither sent nor forwarded as text, got polls=%d texts=%d", len(sender.polls), len(sender.texts))
+	}
+
+	post(555202, "Answer with /poll Lunch? | Pizza | Sushi")
+	if len(sender.polls) != 1 || len(sender.texts) != 1 {
+		t.Fatalf("expected a mid-message prefix to be sent as text, got polls=%d texts=%d", len(sender.polls), len(sender.texts))
+	}
+}
+
+func TestHandleWebhook_ExpandsCannedShortcut(t *testing.T) {
+	prevExpand, prevClient := config.ChatwootExpandCannedResponses, defaultWebhookClient
+	config.ChatwootExpandCannedResponses = true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/canned_responses") {
+			_, _ = w.Write([]byte(`[{"id": 1, "short_code": "greeting", "content": "Hi {{contact.name}}, thanks for writing!"}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	cw := &chatwoot.Client{BaseURL: srv.URL, APIToken: "token", AccountID: 1, InboxID: 1, HTTPClient: srv.Client()}
+	defaultWebhookClient = func() *chatwoot.Client { return cw }
+	t.Cleanup(func() {
+		srv.Close()
+		config.ChatwootExpandCannedResponses, defaultWebhookClient = prevExpand, prevClient
+	})
+	app, sender := newChatwootWebhookTestApp(t)
+
+	post := func(id int, content string) {
+		postChatwootEvent(t, app, fmt.Sprintf(`{
+			"event": "message_created",
+			"id": %d,
+			"message_type": "outgoing",
+			"content": %q,
+			"conversation": {"id": 9170, "meta": {"sender": {"id": 87, "name": "Maria", "phone_number": "+1 415 555 0100"}}}
+		}`, id, content))
+	}
+
+	post(555300, "::greeting Your order ships today.")
+	post(555301, "::unknown")
+	if len(sender.texts) != 2 {
+		t.Fatalf("expected two texts, got %+v", sender.texts)
+	}
+	if got := sender.texts[0].Message; got != "Hi Maria, thanks for writing! Your order ships today." {
+		t.Fatalf("expected the shortcut to be expanded, got %q", got)
+	}
+	if got := sender.texts[1].Message; got != "::unknown" {
+		t.Fatalf("expected an unknown shortcut to be sent as typed, got %q", got)
+	}
+}
+
+func TestWebhookDestination(t *testing.T) {
+	tests := []struct {
+		name    string
+		contact chatwoot.Contact
+		want    string
+	}{
+		{
+			name:    "stored jid wins",
+			contact: chatwoot.Contact{PhoneNumber: "+1 555", CustomAttributes: map[string]interface{}{"waha_whatsapp_jid": "628123@s.whatsapp.net"}},
+			want:    "628123",
+		},
+		{
+			name:    "group identifier",
+			contact: chatwoot.Contact{Identifier: "120363@g.us"},
+			want:    "120363@g.us",
+		},
+		{
+			name:    "formatted phone",
+			contact: chatwoot.Contact{PhoneNumber: " +44 20 7946-0958 "},
+			want:    "442079460958",
+		},
+		{
+			name:    "nothing to send to",
+			contact: chatwoot.Contact{Name: "Unknown"},
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := webhookDestination(tt.contact); got != tt.want {
+				t.Fatalf("webhookDestination()=%q want=%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusPage_RendersWhileChatwootIsDown(t *testing.T) {
+	dm := whatsapp.NewDeviceManager(nil, nil, nil)
+	dm.AddDevice(whatsapp.NewDeviceInstance("status-device", nil, nil))
+	handler := NewChatwootHandler(nil, nil, dm, nil)
+
+	chatwoot.RecordBridgeError(chatwoot.BridgeToChatwoot, errors.New("dial tcp: connection refused"))
+
+	app := fiber.New()
+	app.Get("/chatwoot/status-page", handler.StatusPage)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/chatwoot/status-page", nil))
+	if err != nil {
+		t.Fatalf("status page request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("expected html, got %s", ct)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	page := string(body)
+	for _, want := range []string{`http-equiv="refresh" content="10"`, "status-device", "connection refused", "API circuit", "ffmpeg"} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("expected page to contain %q", want)
+		}
+	}
+}
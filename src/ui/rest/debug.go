@@ -0,0 +1,34 @@
+package rest
+
+import (
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Debug struct{}
+
+func InitRestDebug(app fiber.Router) Debug {
+	rest := Debug{}
+	app.Get("/debug/locks", rest.Locks)
+	return rest
+}
+
+// Locks reports the most contended lock shards and who currently holds them.
+func (h *Debug) Locks(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 10)
+	if limit <= 0 || limit > 500 {
+		limit = 10
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Lock shard statistics",
+		Results: fiber.Map{
+			"shards_per_lock": config.AppLockShards,
+			"wait_warn_sec":   config.AppLockWaitWarnSec,
+			"worst_shards":    utils.WorstLockShards(limit),
+		},
+	})
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"sort"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorCodesVersion is bumped whenever a code is removed, renamed or changes its HTTP status.
+// Adding a code does not change the version.
+const ErrorCodesVersion = 1
+
+// ErrorCode is the machine-readable "code" of an error response. The strings are part of the
+// API contract; TestErrorCodeCatalogSnapshot fails when one changes.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest           ErrorCode = "INVALID_REQUEST"
+	CodeInvalidID                ErrorCode = "INVALID_ID"
+	CodeNotFound                 ErrorCode = "NOT_FOUND"
+	CodeInternalError            ErrorCode = "INTERNAL_ERROR"
+	CodeDeviceNotFound           ErrorCode = "DEVICE_NOT_FOUND"
+	CodeDeviceNotAvailable       ErrorCode = "DEVICE_NOT_AVAILABLE"
+	CodeDeviceDisconnected       ErrorCode = "DEVICE_DISCONNECTED"
+	CodeChatwootNotConfigured    ErrorCode = "CHATWOOT_NOT_CONFIGURED"
+	CodeSyncAlreadyRunning       ErrorCode = "SYNC_ALREADY_RUNNING"
+	CodeSyncNotRunning           ErrorCode = "SYNC_NOT_RUNNING"
+	CodeBackfillAlreadyRunning   ErrorCode = "BACKFILL_ALREADY_RUNNING"
+	CodeMessageAlreadyExported   ErrorCode = "MESSAGE_ALREADY_EXPORTED"
+	CodeWebhookOutboxUnavailable ErrorCode = "WEBHOOK_OUTBOX_UNAVAILABLE"
+	CodeMaintenanceUnavailable   ErrorCode = "MAINTENANCE_UNAVAILABLE"
+	CodeChatwootUnhealthy        ErrorCode = "CHATWOOT_UNHEALTHY"
+	CodeChatwootConfigLocked     ErrorCode = "CHATWOOT_CONFIG_LOCKED"
+)
+
+type errorCodeSpec struct {
+	status      int
+	description string
+}
+
+var errorCodeCatalog = map[ErrorCode]errorCodeSpec{
+	CodeInvalidRequest:           {fiber.StatusBadRequest, "The request body or parameters are invalid"},
+	CodeInvalidID:                {fiber.StatusBadRequest, "The id path parameter is not a positive integer"},
+	CodeNotFound:                 {fiber.StatusNotFound, "The requested resource does not exist"},
+	CodeInternalError:            {fiber.StatusInternalServerError, "An unexpected server error occurred"},
+	CodeDeviceNotFound:           {fiber.StatusBadRequest, "The device_id does not match a registered device"},
+	CodeDeviceNotAvailable:       {fiber.StatusServiceUnavailable, "No device is available to handle the request"},
+	CodeDeviceDisconnected:       {fiber.StatusUnprocessableEntity, "The device exists but is not connected to WhatsApp; retry after it reconnects"},
+	CodeChatwootNotConfigured:    {fiber.StatusBadRequest, "Chatwoot URL, API token, account ID or inbox ID is missing"},
+	CodeSyncAlreadyRunning:       {fiber.StatusConflict, "A Chatwoot history sync is already running for the device"},
+	CodeSyncNotRunning:           {fiber.StatusConflict, "No Chatwoot history sync is running for the device"},
+	CodeBackfillAlreadyRunning:   {fiber.StatusConflict, "A Chatwoot media backfill is already running for the device"},
+	CodeMessageAlreadyExported:   {fiber.StatusConflict, "The WhatsApp message is already in Chatwoot; pass force=true to push it again"},
+	CodeWebhookOutboxUnavailable: {fiber.StatusServiceUnavailable, "The webhook retry queue is not initialized"},
+	CodeMaintenanceUnavailable:   {fiber.StatusServiceUnavailable, "The maintenance buffer is not initialized"},
+	CodeChatwootUnhealthy:        {fiber.StatusServiceUnavailable, "A Chatwoot integration health check failed"},
+	CodeChatwootConfigLocked:     {fiber.StatusForbidden, "CHATWOOT_CONFIG_LOCKED keeps the Chatwoot settings of the environment"},
+}
+
+// ErrorCodeEntry describes one error code in GET /meta/error-codes.
+type ErrorCodeEntry struct {
+	Code        ErrorCode `json:"code"`
+	HTTPStatus  int       `json:"http_status"`
+	Description string    `json:"description"`
+}
+
+// ErrorCodes returns the catalog sorted by code.
+func ErrorCodes() []ErrorCodeEntry {
+	entries := make([]ErrorCodeEntry, 0, len(errorCodeCatalog))
+	for code, spec := range errorCodeCatalog {
+		entries = append(entries, ErrorCodeEntry{Code: code, HTTPStatus: spec.status, Description: spec.description})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+// Status returns the HTTP status that always accompanies the code.
+func (code ErrorCode) Status() int {
+	if spec, ok := errorCodeCatalog[code]; ok {
+		return spec.status
+	}
+	return fiber.StatusInternalServerError
+}
+
+// NewErrorResponse builds the response body for code. An empty message falls back to the
+// catalog description.
+func NewErrorResponse(code ErrorCode, message string, results any) utils.ResponseData {
+	if message == "" {
+		message = errorCodeCatalog[code].description
+	}
+	return utils.ResponseData{
+		Status:  code.Status(),
+		Code:    string(code),
+		Message: message,
+		Results: results,
+	}
+}
+
+// sendError writes an error response whose status and code come from the catalog.
+func sendError(c *fiber.Ctx, code ErrorCode, message string) error {
+	return sendErrorWithResults(c, code, message, nil)
+}
+
+func sendErrorWithResults(c *fiber.Ctx, code ErrorCode, message string, results any) error {
+	resp := NewErrorResponse(code, message, results)
+	return c.Status(resp.Status).JSON(resp)
+}
+
+type Meta struct{}
+
+func InitRestMeta(app fiber.Router) Meta {
+	rest := Meta{}
+	app.Get("/meta/error-codes", rest.ErrorCodes)
+	return rest
+}
+
+// ErrorCodes lists the error codes clients can program against.
+func (h *Meta) ErrorCodes(c *fiber.Ctx) error {
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Error code catalog",
+		Results: fiber.Map{
+			"version": ErrorCodesVersion,
+			"codes":   ErrorCodes(),
+		},
+	})
+}
@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestErrorCodeCatalogSnapshot pins the published error codes. Changing a code string or its status
+// breaks clients: bump ErrorCodesVersion and update this snapshot deliberately.
+func TestErrorCodeCatalogSnapshot(t *testing.T) {
+	want := map[string]int{
+		"BACKFILL_ALREADY_RUNNING":   409,
+		"CHATWOOT_CONFIG_LOCKED":     403,
+		"CHATWOOT_NOT_CONFIGURED":    400,
+		"CHATWOOT_UNHEALTHY":         503,
+		"DEVICE_DISCONNECTED":        422,
+		"DEVICE_NOT_AVAILABLE":       503,
+		"DEVICE_NOT_FOUND":           400,
+		"INTERNAL_ERROR":             500,
+		"INVALID_ID":                 400,
+		"INVALID_REQUEST":            400,
+		"MAINTENANCE_UNAVAILABLE":    503,
+		"MESSAGE_ALREADY_EXPORTED":   409,
+		"NOT_FOUND":                  404,
+		"SYNC_ALREADY_RUNNING":       409,
+		"SYNC_NOT_RUNNING":           409,
+		"WEBHOOK_OUTBOX_UNAVAILABLE": 503,
+	}
+	if ErrorCodesVersion != 1 {
+		t.Fatalf("ErrorCodesVersion changed to %d; update the snapshot for the new version", ErrorCodesVersion)
+	}
+
+	got := ErrorCodes()
+	if len(got) != len(want) {
+		t.Fatalf("catalog has %d codes, snapshot has %d", len(got), len(want))
+	}
+	for i, entry := range got {
+		status, ok := want[string(entry.Code)]
+		if !ok {
+			t.Fatalf("code %s is not in the snapshot", entry.Code)
+		}
+		if entry.HTTPStatus != status {
+			t.Fatalf("code %s has status %d, snapshot says %d", entry.Code, entry.HTTPStatus, status)
+		}
+		if entry.Description == "" {
+			t.Fatalf("code %s has no description", entry.Code)
+		}
+		if i > 0 && got[i-1].Code >= entry.Code {
+			t.Fatalf("catalog is not sorted at %s", entry.Code)
+		}
+	}
+}
+
+func TestSendError_UsesCatalogStatus(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		return sendError(c, CodeDeviceDisconnected, "")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if body.Code != "DEVICE_DISCONNECTED" || body.Message == "" {
+		t.Fatalf("unexpected body %+v", body)
+	}
+}
+
+func TestMetaErrorCodes_ListsCatalog(t *testing.T) {
+	app := fiber.New()
+	InitRestMeta(app)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/meta/error-codes", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Results struct {
+			Version int              `json:"version"`
+			Codes   []ErrorCodeEntry `json:"codes"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if body.Results.Version != ErrorCodesVersion || len(body.Results.Codes) != len(errorCodeCatalog) {
+		t.Fatalf("unexpected catalog response %+v", body.Results)
+	}
+}
@@ -2,7 +2,12 @@ package helpers
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
 	"time"
 
 	domainApp "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/app"
@@ -51,3 +56,47 @@ func MultipartFormFileHeaderToBytes(fileHeader *multipart.FileHeader) []byte {
 
 	return fileBytes
 }
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// fileHeaderMemory is how much of a file FileHeaderFromPath keeps in memory; the rest is spooled to disk.
+const fileHeaderMemory = 10 << 20
+
+// FileHeaderFromPath wraps a local file in a multipart.FileHeader, as if it had been uploaded with
+// the given filename and content type, so it can be passed to the send usecases. Call the returned
+// func to remove the spooled copy once sent.
+func FileHeaderFromPath(path, filename, contentType string) (*multipart.FileHeader, func(), error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, quoteEscaper.Replace(filename)))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	form, err := multipart.NewReader(pr, writer.Boundary()).ReadForm(fileHeaderMemory)
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { _ = form.RemoveAll() }
+	if len(form.File["file"]) == 0 {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to read %s", path)
+	}
+	return form.File["file"][0], cleanup, nil
+}
@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+type strictJSONTestRequest struct {
+	DeviceID     string `json:"device_id"`
+	DaysLimit    int    `json:"days_limit"`
+	IncludeMedia bool   `json:"include_media"`
+}
+
+func TestDecodeStrictJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "valid", body: `{"device_id":"sales","days_limit":7}`},
+		{name: "wrong type", body: `{"days_limit":"30"}`, wantErr: `field "days_limit" must be of type int, got string`},
+		{name: "unknown field", body: `{"days":30}`, wantErr: `unknown field "days"`},
+		{name: "malformed", body: `{"days_limit":`, wantErr: "malformed JSON"},
+		{name: "trailing data", body: `{"days_limit":1}{}`, wantErr: "single JSON object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := strictJSONTestRequest{IncludeMedia: true}
+			err := DecodeStrictJSON([]byte(tt.body), &req)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if req.DeviceID != "sales" || req.DaysLimit != 7 || !req.IncludeMedia {
+					t.Fatalf("unexpected decoded request: %+v", req)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type Maintenance struct{}
+
+func InitRestMaintenance(app fiber.Router) Maintenance {
+	rest := Maintenance{}
+	app.Get("/maintenance/status", rest.Status)
+	app.Post("/maintenance/start", rest.Start)
+	app.Post("/maintenance/stop", rest.Stop)
+	return rest
+}
+
+// Status reports whether deliveries are held and how many events are buffered.
+func (h *Maintenance) Status(c *fiber.Ctx) error {
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Maintenance status",
+		Results: whatsapp.GetMaintenanceStatus(),
+	})
+}
+
+// Start holds webhook and Chatwoot deliveries for ?duration= (Go duration, default 30m).
+func (h *Maintenance) Start(c *fiber.Ctx) error {
+	duration := whatsapp.MaintenanceDefaultDuration
+	if raw := c.Query("duration"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > whatsapp.MaintenanceMaxDuration {
+			return sendError(c, CodeInvalidRequest, "duration must be a Go duration between 1s and 24h, e.g. 30m")
+		}
+		duration = parsed
+	}
+
+	status, err := whatsapp.StartMaintenance(duration)
+	if err != nil {
+		logrus.Errorf("Maintenance: failed to start: %v", err)
+		return sendError(c, CodeMaintenanceUnavailable, err.Error())
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Maintenance mode started",
+		Results: status,
+	})
+}
+
+// Stop ends maintenance mode; buffered events are then delivered in order in the background.
+func (h *Maintenance) Stop(c *fiber.Ctx) error {
+	message := "Maintenance mode stopped, draining buffered events"
+	if !whatsapp.StopMaintenance("stopped via API") {
+		message = "Maintenance mode was not active"
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: message,
+		Results: whatsapp.GetMaintenanceStatus(),
+	})
+}
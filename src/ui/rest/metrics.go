@@ -0,0 +1,20 @@
+package rest
+
+import (
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+type Metrics struct{}
+
+func InitRestMetrics(app fiber.Router) Metrics {
+	rest := Metrics{}
+	app.Get("/metrics", rest.Metrics)
+	return rest
+}
+
+// Metrics serves the Prometheus metrics of Chatwoot forwarding, the history sync and webhook delivery.
+func (h *Metrics) Metrics(c *fiber.Ctx) error {
+	return adaptor.HTTPHandler(metrics.Handler())(c)
+}
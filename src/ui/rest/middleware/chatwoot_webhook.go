@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChatwootWebhookAuth rejects Chatwoot webhook calls that fail the configured checks before the body is parsed.
+// token is compared against X-Chatwoot-Webhook-Token, X-Chatwoot-Token, the token query or a bearer header.
+// secret verifies X-Chatwoot-Signature, an HMAC-SHA256 of the raw body (hex, optionally prefixed with "sha256=").
+// When both are configured, both must match; when neither is configured, every request passes.
+func ChatwootWebhookAuth(token, secret string) fiber.Handler {
+	token = strings.TrimSpace(token)
+	secret = strings.TrimSpace(secret)
+
+	return func(c *fiber.Ctx) error {
+		if token != "" && !IsSecureTokenMatch(chatwootWebhookToken(c), token) {
+			return chatwootWebhookUnauthorized(c, "invalid or missing chatwoot webhook token")
+		}
+		if secret != "" && !IsValidWebhookSignature(c.Body(), c.Get("X-Chatwoot-Signature"), secret) {
+			return chatwootWebhookUnauthorized(c, "invalid or missing chatwoot webhook signature")
+		}
+		return c.Next()
+	}
+}
+
+// ChatwootBotAuth checks agent bot events against token. Chatwoot does not sign them, so the token
+// is their only check: without one every event is rejected.
+func ChatwootBotAuth(token string) fiber.Handler {
+	token = strings.TrimSpace(token)
+
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return chatwootWebhookUnauthorized(c, "the chatwoot agent bot needs a webhook token")
+		}
+		if !IsSecureTokenMatch(chatwootWebhookToken(c), token) {
+			return chatwootWebhookUnauthorized(c, "invalid or missing chatwoot webhook token")
+		}
+		return c.Next()
+	}
+}
+
+// IsValidWebhookSignature reports whether signature is the HMAC-SHA256 of body under secret, compared in constant time.
+func IsValidWebhookSignature(body []byte, signature, secret string) bool {
+	signature = strings.TrimSpace(signature)
+	signature = strings.TrimPrefix(signature, "sha256=")
+	if signature == "" || secret == "" {
+		return false
+	}
+
+	provided, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(provided, mac.Sum(nil))
+}
+
+func chatwootWebhookToken(c *fiber.Ctx) string {
+	for _, header := range []string{"X-Chatwoot-Webhook-Token", "X-Chatwoot-Token"} {
+		if token := strings.TrimSpace(c.Get(header)); token != "" {
+			return token
+		}
+	}
+	if token := strings.TrimSpace(c.Query("token")); token != "" {
+		return token
+	}
+	authHeader := strings.TrimSpace(c.Get("Authorization"))
+	if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+		return strings.TrimSpace(authHeader[len("Bearer "):])
+	}
+	return ""
+}
+
+func chatwootWebhookUnauthorized(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(utils.ResponseData{
+		Status:  fiber.StatusUnauthorized,
+		Code:    "UNAUTHORIZED_WEBHOOK",
+		Message: message,
+	})
+}
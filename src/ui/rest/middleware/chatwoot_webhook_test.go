@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const chatwootTestBody = `{"event":"message_created","message_type":"outgoing","content":"hi"}`
+
+func newChatwootWebhookTestApp(token, secret string) (*fiber.App, *bool) {
+	reached := false
+	app := fiber.New()
+	app.Post("/chatwoot/webhook", ChatwootWebhookAuth(token, secret), func(c *fiber.Ctx) error {
+		reached = true
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app, &reached
+}
+
+func signChatwootBody(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestChatwootWebhookAuth_ValidSignature(t *testing.T) {
+	app, reached := newChatwootWebhookTestApp("", "hmac-secret")
+
+	req := httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(chatwootTestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Chatwoot-Signature", "sha256="+signChatwootBody(chatwootTestBody, "hmac-secret"))
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.True(t, *reached)
+}
+
+func TestChatwootWebhookAuth_MissingSignature(t *testing.T) {
+	app, reached := newChatwootWebhookTestApp("", "hmac-secret")
+
+	req := httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(chatwootTestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	assert.False(t, *reached)
+}
+
+func TestChatwootWebhookAuth_TamperedBody(t *testing.T) {
+	app, reached := newChatwootWebhookTestApp("", "hmac-secret")
+	signature := signChatwootBody(chatwootTestBody, "hmac-secret")
+	tampered := strings.Replace(chatwootTestBody, `"hi"`, `"send money"`, 1)
+
+	req := httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(tampered))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Chatwoot-Signature", signature)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	assert.False(t, *reached)
+}
+
+func TestChatwootWebhookAuth_Token(t *testing.T) {
+	app, _ := newChatwootWebhookTestApp("cw-token", "")
+
+	req := httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(chatwootTestBody))
+	req.Header.Set("X-Chatwoot-Webhook-Token", "cw-token")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	req = httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(chatwootTestBody))
+	req.Header.Set("X-Chatwoot-Webhook-Token", "wrong")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestChatwootWebhookAuth_NothingConfigured(t *testing.T) {
+	app, reached := newChatwootWebhookTestApp("", "")
+
+	req := httptest.NewRequest("POST", "/chatwoot/webhook", strings.NewReader(chatwootTestBody))
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.True(t, *reached)
+}
+
+func TestIsValidWebhookSignature_RejectsMalformedHex(t *testing.T) {
+	assert.False(t, IsValidWebhookSignature([]byte(chatwootTestBody), "not-hex", "hmac-secret"))
+	assert.False(t, IsValidWebhookSignature([]byte(chatwootTestBody), signChatwootBody(chatwootTestBody, "other"), "hmac-secret"))
+}
+
+func TestChatwootBotAuth_RequiresToken(t *testing.T) {
+	post := func(handler fiber.Handler, token string) int {
+		app := fiber.New()
+		app.Post("/chatwoot/bot", handler, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+		req := httptest.NewRequest("POST", "/chatwoot/bot", strings.NewReader(chatwootTestBody))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("X-Chatwoot-Token", token)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// Without a configured token every event is rejected, even one carrying a token
+	assert.Equal(t, fiber.StatusUnauthorized, post(ChatwootBotAuth(""), ""))
+	assert.Equal(t, fiber.StatusUnauthorized, post(ChatwootBotAuth(" "), "guess"))
+	assert.Equal(t, fiber.StatusUnauthorized, post(ChatwootBotAuth("bot-token"), "wrong"))
+	assert.Equal(t, fiber.StatusOK, post(ChatwootBotAuth("bot-token"), "bot-token"))
+}
@@ -22,6 +22,7 @@ func InitRestUser(app fiber.Router, service domainUser.IUserUsecase) User {
 	app.Get("/user/my/newsletters", rest.UserMyListNewsletter)
 	app.Get("/user/my/contacts", rest.UserMyListContacts)
 	app.Get("/user/check", rest.UserCheck)
+	app.Get("/contacts/check", rest.UserCheck)
 	app.Get("/user/business-profile", rest.UserBusinessProfile)
 
 	return rest
@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"strconv"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type Webhook struct{}
+
+func InitRestWebhook(app fiber.Router) Webhook {
+	rest := Webhook{}
+	app.Get("/webhooks/failed", rest.ListFailed)
+	app.Post("/webhooks/failed/:id/replay", rest.ReplayFailed)
+	return rest
+}
+
+// ListFailed returns dead-lettered webhook deliveries together with queue counts.
+func (h *Webhook) ListFailed(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	stats, err := whatsapp.GetWebhookOutboxStats()
+	if err != nil {
+		logrus.Errorf("Webhook outbox: failed to count entries: %v", err)
+		return sendError(c, CodeWebhookOutboxUnavailable, "Webhook retry queue is unavailable")
+	}
+
+	entries, err := whatsapp.ListFailedWebhooks(limit)
+	if err != nil {
+		logrus.Errorf("Webhook outbox: failed to list dead entries: %v", err)
+		return sendError(c, CodeInternalError, "Failed to list failed webhooks")
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Failed webhook deliveries",
+		Results: fiber.Map{
+			"stats":       stats,
+			"entries":     entries,
+			"maintenance": whatsapp.GetMaintenanceStatus(),
+		},
+	})
+}
+
+// ReplayFailed moves a dead-lettered delivery back to the retry queue.
+func (h *Webhook) ReplayFailed(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return sendError(c, CodeInvalidID, "id must be a positive integer")
+	}
+
+	entry, err := whatsapp.ReplayFailedWebhook(id)
+	if err != nil {
+		logrus.Errorf("Webhook outbox: failed to replay entry %d: %v", id, err)
+		return sendError(c, CodeInternalError, "Failed to replay webhook")
+	}
+	if entry == nil {
+		return sendError(c, CodeNotFound, "Webhook delivery not found")
+	}
+
+	return c.JSON(utils.ResponseData{
+		Status:  200,
+		Code:    "SUCCESS",
+		Message: "Webhook delivery queued for retry",
+		Results: entry,
+	})
+}
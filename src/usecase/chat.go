@@ -12,7 +12,9 @@ import (
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/validations"
 	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types"
 )
 
 type serviceChat struct {
@@ -62,6 +64,7 @@ func (service serviceChat) ListChats(ctx context.Context, request domainChat.Lis
 			Name:                chat.Name,
 			LastMessageTime:     chat.LastMessageTime.Format(time.RFC3339),
 			EphemeralExpiration: chat.EphemeralExpiration,
+			Type:                chat.Type,
 			CreatedAt:           chat.CreatedAt.Format(time.RFC3339),
 			UpdatedAt:           chat.UpdatedAt.Format(time.RFC3339),
 		}
@@ -185,6 +188,7 @@ func (service serviceChat) GetChatMessages(ctx context.Context, request domainCh
 		Name:                chat.Name,
 		LastMessageTime:     chat.LastMessageTime.Format(time.RFC3339),
 		EphemeralExpiration: chat.EphemeralExpiration,
+		Type:                chat.Type,
 		CreatedAt:           chat.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:           chat.UpdatedAt.Format(time.RFC3339),
 	}
@@ -210,6 +214,123 @@ func (service serviceChat) GetChatMessages(ctx context.Context, request domainCh
 	return response, nil
 }
 
+func (service serviceChat) SearchMessages(ctx context.Context, request domainChat.SearchMessagesRequest) (response domainChat.SearchMessagesResponse, err error) {
+	if err = validations.ValidateSearchMessages(ctx, &request); err != nil {
+		return response, err
+	}
+
+	deviceID := deviceIDFromContext(ctx)
+	if deviceID == "" {
+		return response, fmt.Errorf("device identification required")
+	}
+
+	filter := &domainChatStorage.MessageSearchFilter{
+		DeviceID: deviceID,
+		Query:    request.Query,
+		ChatJID:  request.ChatJID,
+		Limit:    request.Limit,
+		Cursor:   request.Cursor,
+	}
+	if request.From != nil && *request.From != "" {
+		from, err := time.Parse(time.RFC3339, *request.From)
+		if err != nil {
+			return response, pkgError.ValidationError(fmt.Sprintf("invalid from format: %v", err))
+		}
+		filter.From = &from
+	}
+	if request.To != nil && *request.To != "" {
+		to, err := time.Parse(time.RFC3339, *request.To)
+		if err != nil {
+			return response, pkgError.ValidationError(fmt.Sprintf("invalid to format: %v", err))
+		}
+		filter.To = &to
+	}
+
+	page, err := service.chatStorageRepo.SearchMessagesFullText(filter)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to search messages")
+		return response, err
+	}
+
+	ids := make([]string, 0, len(page.Hits))
+	for _, hit := range page.Hits {
+		ids = append(ids, hit.ID)
+	}
+	receipts, err := service.chatStorageRepo.GetMessageReceipts(deviceID, ids)
+	if err != nil {
+		// Hits are still useful without their receipt state
+		logrus.WithError(err).Warn("Failed to load message receipts")
+	}
+
+	client := whatsapp.ClientFromContext(ctx)
+	recipients := map[string]int{}
+	response.Data = make([]domainChat.MessageSearchHit, 0, len(page.Hits))
+	for _, hit := range page.Hits {
+		count, ok := recipients[hit.ChatJID]
+		if !ok && len(receipts[hit.ID]) > 0 {
+			count = receiptRecipients(ctx, client, hit.ChatJID)
+			recipients[hit.ChatJID] = count
+		}
+		response.Data = append(response.Data, domainChat.MessageSearchHit{
+			ID:        hit.ID,
+			ChatJID:   hit.ChatJID,
+			SenderJID: hit.Sender,
+			Timestamp: hit.Timestamp.Format(time.RFC3339),
+			MediaType: hit.MediaType,
+			Snippet:   hit.Snippet,
+			Receipt:   messageReceiptInfo(receipts[hit.ID], count),
+		})
+	}
+	response.NextCursor = page.NextCursor
+	return response, nil
+}
+
+// fetchGroupInfo looks a group up on WhatsApp; replaced in tests.
+var fetchGroupInfo = func(ctx context.Context, client *whatsmeow.Client, jid types.JID) (*types.GroupInfo, error) {
+	return client.GetGroupInfo(ctx, jid)
+}
+
+// receiptRecipients returns how many members besides us a message in chatJID goes to, for the
+// "read by N of M" summary of its receipts. It is 0, so the receipts are counted instead, for chats
+// other than groups and when the group cannot be looked up.
+func receiptRecipients(ctx context.Context, client *whatsmeow.Client, chatJID string) int {
+	if client == nil || !utils.IsGroupJID(chatJID) {
+		return 0
+	}
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		return 0
+	}
+	info, err := fetchGroupInfo(ctx, client, jid)
+	if err != nil || info == nil || len(info.Participants) == 0 {
+		logrus.WithError(err).Debugf("Failed to look up the members of %s for its receipts", chatJID)
+		return 0
+	}
+	return len(info.Participants) - 1
+}
+
+// messageReceiptInfo summarizes the receipts of a message sent to recipients people, or returns nil
+// when it has none. recipients is 0 when unknown.
+func messageReceiptInfo(receipts []*domainChatStorage.MessageReceipt, recipients int) *domainChat.MessageReceiptInfo {
+	if len(receipts) == 0 {
+		return nil
+	}
+	summary := domainChatStorage.SummarizeReceipts(receipts, recipients)
+	info := &domainChat.MessageReceiptInfo{
+		Summary:    summary.String(),
+		Recipients: summary.Recipients,
+		Delivered:  summary.Delivered,
+		Read:       summary.Read,
+	}
+	if summary.DeliveredAt != nil {
+		info.DeliveredAt = summary.DeliveredAt.Format(time.RFC3339)
+	}
+	if summary.ReadAt != nil {
+		info.ReadAt = summary.ReadAt.Format(time.RFC3339)
+	}
+	return info
+}
+
 func deviceIDFromContext(ctx context.Context) string {
 	if inst, ok := whatsapp.DeviceFromContext(ctx); ok && inst != nil {
 		if jid := inst.JID(); jid != "" {
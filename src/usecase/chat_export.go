@@ -0,0 +1,416 @@
+package usecase
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
+	domainChat "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chat"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/utils"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/validations"
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+)
+
+// exportPageSize is how many messages an export loads from storage at a time.
+const exportPageSize = 500
+
+var exportFormats = map[string]struct {
+	extension   string
+	contentType string
+}{
+	"json": {"jsonl", "application/x-ndjson"},
+	"csv":  {"csv", "text/csv; charset=utf-8"},
+	"html": {"html", "text/html; charset=utf-8"},
+}
+
+// exportMediaExtensions are used for media stored without a file name.
+var exportMediaExtensions = map[string]string{
+	"image":    ".jpg",
+	"video":    ".mp4",
+	"audio":    ".ogg",
+	"sticker":  ".webp",
+	"document": ".bin",
+}
+
+// exportMediaDownload fetches the media of a stored message from WhatsApp. Tests replace it.
+var exportMediaDownload = func(ctx context.Context, client *whatsmeow.Client, message *domainChatStorage.Message) ([]byte, error) {
+	if client == nil {
+		return nil, pkgError.ErrWaCLI
+	}
+	downloadable, err := storedMediaDownloadable(message)
+	if err != nil {
+		return nil, err
+	}
+	data, err := client.Download(ctx, downloadable)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > config.WhatsappSettingMaxDownloadSize {
+		return nil, fmt.Errorf("file size exceeds the maximum limit of %d bytes", config.WhatsappSettingMaxDownloadSize)
+	}
+	return data, nil
+}
+
+func (service serviceChat) ExportChat(ctx context.Context, request domainChat.ExportChatRequest) (response domainChat.ExportChatResponse, err error) {
+	if err = validations.ValidateExportChat(ctx, &request); err != nil {
+		return response, err
+	}
+
+	deviceID := deviceIDFromContext(ctx)
+	if deviceID == "" {
+		return response, fmt.Errorf("device identification required")
+	}
+
+	chat, err := service.chatStorageRepo.GetChat(request.ChatJID)
+	if err != nil {
+		return response, err
+	}
+	if chat == nil {
+		return response, fmt.Errorf("chat with JID %s not found", request.ChatJID)
+	}
+
+	client := whatsapp.ClientFromContext(ctx)
+	export := &chatExport{
+		repo:       service.chatStorageRepo,
+		chat:       chat,
+		format:     request.Format,
+		filter:     domainChatStorage.MessageFilter{DeviceID: deviceID, ChatJID: request.ChatJID, OldestFirst: true},
+		recipients: receiptRecipients(ctx, client, chat.JID),
+	}
+	if request.From != nil && *request.From != "" {
+		from, err := time.Parse(time.RFC3339, *request.From)
+		if err != nil {
+			return response, pkgError.ValidationError(fmt.Sprintf("invalid from format: %v", err))
+		}
+		export.filter.StartTime = &from
+	}
+	if request.To != nil && *request.To != "" {
+		to, err := time.Parse(time.RFC3339, *request.To)
+		if err != nil {
+			return response, pkgError.ValidationError(fmt.Sprintf("invalid to format: %v", err))
+		}
+		export.filter.EndTime = &to
+	}
+
+	name := "chat-" + utils.ExtractPhoneNumber(chat.JID)
+	if request.Media == "zip" {
+		response.Filename = name + ".zip"
+		response.ContentType = "application/zip"
+		response.Write = func(w io.Writer) error { return export.writeZip(ctx, w, client) }
+		return response, nil
+	}
+	response.Filename = name + "." + exportFormats[request.Format].extension
+	response.ContentType = exportFormats[request.Format].contentType
+	response.Write = func(w io.Writer) error { return export.writeTranscript(w, nil) }
+	return response, nil
+}
+
+type chatExport struct {
+	repo       domainChatStorage.IChatStorageRepository
+	chat       *domainChatStorage.Chat
+	format     string
+	filter     domainChatStorage.MessageFilter
+	recipients int // Members of a group besides us, 0 for other chats
+}
+
+// eachMessage calls fn with every exported message, oldest first, and the receipts of the message,
+// loading one page at a time so a long chat is never held in memory.
+func (e *chatExport) eachMessage(fn func(*domainChatStorage.Message, []*domainChatStorage.MessageReceipt) error) error {
+	filter := e.filter
+	filter.Limit = exportPageSize
+	for offset := 0; ; offset += exportPageSize {
+		filter.Offset = offset
+		messages, err := e.repo.GetMessages(&filter)
+		if err != nil {
+			return fmt.Errorf("failed to load messages: %w", err)
+		}
+		ids := make([]string, 0, len(messages))
+		for _, message := range messages {
+			if message.IsFromMe {
+				ids = append(ids, message.ID)
+			}
+		}
+		receipts, err := e.repo.GetMessageReceipts(e.filter.DeviceID, ids)
+		if err != nil {
+			return fmt.Errorf("failed to load receipts: %w", err)
+		}
+		for _, message := range messages {
+			if err := fn(message, receipts[message.ID]); err != nil {
+				return err
+			}
+		}
+		if len(messages) < exportPageSize {
+			return nil
+		}
+	}
+}
+
+// writeTranscript writes the transcript in the requested format. mediaPaths holds, by message ID,
+// the file of each media message bundled alongside the transcript.
+func (e *chatExport) writeTranscript(w io.Writer, mediaPaths map[string]string) error {
+	var tw transcriptWriter
+	switch e.format {
+	case "csv":
+		tw = &csvTranscript{w: csv.NewWriter(w), recipients: e.recipients}
+	case "html":
+		tw = &htmlTranscript{w: w, chat: e.chat, recipients: e.recipients}
+	default:
+		tw = &jsonTranscript{enc: json.NewEncoder(w), recipients: e.recipients}
+	}
+
+	if err := tw.begin(); err != nil {
+		return err
+	}
+	if err := e.eachMessage(func(message *domainChatStorage.Message, receipts []*domainChatStorage.MessageReceipt) error {
+		return tw.write(message, receipts, mediaPaths[message.ID])
+	}); err != nil {
+		return err
+	}
+	return tw.end()
+}
+
+// writeZip streams a zip holding the media files of the chat under media/ and then the transcript
+// linking to them. Media that cannot be downloaded any more is left out and logged.
+func (e *chatExport) writeZip(ctx context.Context, w io.Writer, client *whatsmeow.Client) error {
+	zw := zip.NewWriter(w)
+	mediaPaths := map[string]string{}
+	err := e.eachMessage(func(message *domainChatStorage.Message, _ []*domainChatStorage.MessageReceipt) error {
+		if message.MediaType == "" || message.URL == "" {
+			return nil
+		}
+		data, err := exportMediaDownload(ctx, client, message)
+		if err != nil {
+			logrus.Warnf("Chat export: media of message %s left out: %v", message.ID, err)
+			return nil
+		}
+		path := "media/" + exportMediaName(message)
+		// Media is already compressed
+		file, err := zw.CreateHeader(&zip.FileHeader{Name: path, Method: zip.Store, Modified: message.Timestamp})
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			return err
+		}
+		mediaPaths[message.ID] = path
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	transcript, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "transcript." + exportFormats[e.format].extension,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.writeTranscript(transcript, mediaPaths); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// exportMediaName names the file of a media message after its ID, which is unique in the chat.
+func exportMediaName(message *domainChatStorage.Message) string {
+	name := strings.Map(func(c rune) rune {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_' {
+			return c
+		}
+		return '_'
+	}, message.ID)
+	extension := strings.ToLower(filepath.Ext(message.Filename))
+	if extension == "" {
+		extension = exportMediaExtensions[message.MediaType]
+	}
+	return name + extension
+}
+
+type transcriptWriter interface {
+	begin() error
+	write(message *domainChatStorage.Message, receipts []*domainChatStorage.MessageReceipt, mediaPath string) error
+	end() error
+}
+
+// exportedMessage is one line of a JSON transcript. Media keys are left out.
+type exportedMessage struct {
+	ID         string `json:"id"`
+	ChatJID    string `json:"chat_jid"`
+	SenderJID  string `json:"sender_jid"`
+	IsFromMe   bool   `json:"is_from_me"`
+	Timestamp  string `json:"timestamp"`
+	Content    string `json:"content"`
+	MediaType  string `json:"media_type,omitempty"`
+	Filename   string `json:"filename,omitempty"`
+	FileLength uint64 `json:"file_length,omitempty"`
+	URL        string `json:"url,omitempty"`
+	IsViewOnce bool   `json:"is_view_once,omitempty"`
+	MediaPath  string `json:"media_path,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+
+	Receipt  *domainChat.MessageReceiptInfo `json:"receipt,omitempty"`
+	Receipts []exportedReceipt              `json:"receipts,omitempty"` // Per recipient
+}
+
+type exportedReceipt struct {
+	Participant string `json:"participant"`
+	DeliveredAt string `json:"delivered_at,omitempty"`
+	ReadAt      string `json:"read_at,omitempty"`
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+type jsonTranscript struct {
+	enc        *json.Encoder
+	recipients int
+}
+
+func (t *jsonTranscript) begin() error { return nil }
+
+func (t *jsonTranscript) write(message *domainChatStorage.Message, receipts []*domainChatStorage.MessageReceipt, mediaPath string) error {
+	exported := exportedMessage{
+		ID:         message.ID,
+		ChatJID:    message.ChatJID,
+		SenderJID:  message.Sender,
+		IsFromMe:   message.IsFromMe,
+		Timestamp:  message.Timestamp.Format(time.RFC3339),
+		Content:    message.Content,
+		MediaType:  message.MediaType,
+		Filename:   message.Filename,
+		FileLength: message.FileLength,
+		URL:        message.URL,
+		IsViewOnce: message.IsViewOnce,
+		MediaPath:  mediaPath,
+		CreatedAt:  message.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  message.UpdatedAt.Format(time.RFC3339),
+		Receipt:    messageReceiptInfo(receipts, t.recipients),
+	}
+	for _, receipt := range receipts {
+		exported.Receipts = append(exported.Receipts, exportedReceipt{
+			Participant: receipt.Participant,
+			DeliveredAt: formatOptionalTime(receipt.DeliveredAt),
+			ReadAt:      formatOptionalTime(receipt.ReadAt),
+		})
+	}
+	return t.enc.Encode(exported)
+}
+
+func (t *jsonTranscript) end() error { return nil }
+
+type csvTranscript struct {
+	w          *csv.Writer
+	recipients int
+}
+
+func (t *csvTranscript) begin() error {
+	return t.w.Write([]string{"id", "timestamp", "sender_jid", "is_from_me", "content", "media_type", "filename", "file_length", "media_path",
+		"delivered_at", "read_at", "receipt"})
+}
+
+func (t *csvTranscript) write(message *domainChatStorage.Message, receipts []*domainChatStorage.MessageReceipt, mediaPath string) error {
+	var deliveredAt, readAt, summary string
+	if info := messageReceiptInfo(receipts, t.recipients); info != nil {
+		deliveredAt, readAt, summary = info.DeliveredAt, info.ReadAt, info.Summary
+	}
+	return t.w.Write([]string{
+		message.ID,
+		message.Timestamp.Format(time.RFC3339),
+		message.Sender,
+		strconv.FormatBool(message.IsFromMe),
+		message.Content,
+		message.MediaType,
+		message.Filename,
+		strconv.FormatUint(message.FileLength, 10),
+		mediaPath,
+		deliveredAt,
+		readAt,
+		summary,
+	})
+}
+
+func (t *csvTranscript) end() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+var htmlTranscriptTemplate = template.Must(template.New("transcript").Parse(`
+{{define "begin"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>
+body{font-family:sans-serif;max-width:48rem;margin:2rem auto;color:#222}
+.msg{border-left:3px solid #ccc;margin:.75rem 0;padding:.25rem .75rem}
+.msg.me{border-color:#25d366}
+.meta{color:#666;font-size:.85rem}
+.content{white-space:pre-wrap;margin:.25rem 0}
+</style></head><body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.JID}}, exported {{.ExportedAt}}</p>
+{{end}}
+{{define "message"}}<div class="msg{{if .IsFromMe}} me{{end}}">
+<div class="meta">{{.Sender}}{{if .IsFromMe}} (me){{end}} &middot; <time>{{.Timestamp}}</time>{{with .Receipt}} &middot; {{.}}{{end}}</div>
+{{if .Content}}<p class="content">{{.Content}}</p>{{end}}
+{{if .MediaType}}<p class="media">{{if .MediaPath}}<a href="{{.MediaPath}}">{{.MediaType}}{{with .Filename}}: {{.}}{{end}}</a>{{else}}[{{.MediaType}}{{with .Filename}}: {{.}}{{end}}]{{end}}</p>{{end}}
+</div>
+{{end}}
+{{define "end"}}</body></html>
+{{end}}`))
+
+type htmlTranscript struct {
+	w          io.Writer
+	chat       *domainChatStorage.Chat
+	recipients int
+}
+
+func (t *htmlTranscript) begin() error {
+	title := t.chat.Name
+	if title == "" {
+		title = t.chat.JID
+	}
+	return htmlTranscriptTemplate.ExecuteTemplate(t.w, "begin", map[string]string{
+		"Title":      title,
+		"JID":        t.chat.JID,
+		"ExportedAt": time.Now().Format(time.RFC3339),
+	})
+}
+
+func (t *htmlTranscript) write(message *domainChatStorage.Message, receipts []*domainChatStorage.MessageReceipt, mediaPath string) error {
+	var receipt string
+	if info := messageReceiptInfo(receipts, t.recipients); info != nil {
+		receipt = info.Summary
+	}
+	return htmlTranscriptTemplate.ExecuteTemplate(t.w, "message", map[string]any{
+		"Receipt":   receipt,
+		"Sender":    message.Sender,
+		"IsFromMe":  message.IsFromMe,
+		"Timestamp": message.Timestamp.Format(time.RFC3339),
+		"Content":   message.Content,
+		"MediaType": message.MediaType,
+		"Filename":  message.Filename,
+		"MediaPath": mediaPath,
+	})
+}
+
+func (t *htmlTranscript) end() error {
+	return htmlTranscriptTemplate.ExecuteTemplate(t.w, "end", nil)
+}
@@ -0,0 +1,230 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	domainChat "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chat"
+	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/chatstorage"
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
+	_ "github.com/mattn/go-sqlite3"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const exportChatJID = "6289685028129@s.whatsapp.net"
+
+// newExportTestService seeds a chat with more messages than one storage page, an image and a
+// message of another device, and returns the chat service with a device context.
+func newExportTestService(t *testing.T) (domainChat.IChatUsecase, context.Context) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "chatstorage.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo := chatstorage.NewStorageRepository(db)
+	if err := repo.InitializeSchema(); err != nil {
+		if strings.Contains(err.Error(), "CGO_ENABLED=0") || strings.Contains(err.Error(), "requires cgo") {
+			t.Skipf("skipping chat export tests without cgo: %v", err)
+		}
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	if err := repo.StoreChat(&domainChatStorage.Chat{DeviceID: "dev", JID: exportChatJID, Name: "Ana <Support>", LastMessageTime: time.Now()}); err != nil {
+		t.Fatalf("store chat failed: %v", err)
+	}
+	base := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < exportPageSize+2; i++ {
+		message := &domainChatStorage.Message{
+			ID: fmt.Sprintf("M%04d", i), ChatJID: exportChatJID, DeviceID: "dev", Sender: exportChatJID,
+			Content: fmt.Sprintf("message %d", i), Timestamp: base.Add(time.Duration(i) * time.Minute),
+		}
+		if i == 1 {
+			message.Content = "<b>quote, \"comma\"</b>\nsecond line"
+			message.IsFromMe = true
+		}
+		if i == 2 {
+			message.Content, message.MediaType, message.Filename, message.URL = "", "image", "image_1.jpg", "https://mmg.whatsapp.net/x"
+		}
+		if err := repo.StoreMessage(message); err != nil {
+			t.Fatalf("store message failed: %v", err)
+		}
+	}
+	readAt := base.Add(2 * time.Minute)
+	if err := repo.UpdateMessageReceipt(&domainChatStorage.MessageReceipt{
+		DeviceID: "dev", ChatJID: exportChatJID, MessageID: "M0001", Participant: exportChatJID, ReadAt: &readAt,
+	}); err != nil {
+		t.Fatalf("update receipt failed: %v", err)
+	}
+	if err := repo.StoreMessage(&domainChatStorage.Message{
+		ID: "OTHER", ChatJID: exportChatJID, DeviceID: "dev2", Sender: "x", Content: "other device", Timestamp: base,
+	}); err != nil {
+		t.Fatalf("store message failed: %v", err)
+	}
+
+	ctx := whatsapp.ContextWithDevice(context.Background(), whatsapp.NewDeviceInstance("dev", nil, nil))
+	return NewChatService(repo), ctx
+}
+
+func runExport(t *testing.T, service domainChat.IChatUsecase, ctx context.Context, request domainChat.ExportChatRequest) (domainChat.ExportChatResponse, []byte) {
+	t.Helper()
+	response, err := service.ExportChat(ctx, request)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := response.Write(&buf); err != nil {
+		t.Fatalf("writing the export failed: %v", err)
+	}
+	return response, buf.Bytes()
+}
+
+func TestExportChat_JSONLines(t *testing.T) {
+	service, ctx := newExportTestService(t)
+	response, data := runExport(t, service, ctx, domainChat.ExportChatRequest{ChatJID: exportChatJID})
+
+	if response.Filename != "chat-6289685028129.jsonl" || response.ContentType != "application/x-ndjson" {
+		t.Fatalf("unexpected file: %+v", response)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != exportPageSize+2 {
+		t.Fatalf("expected every message of the device across pages, got %d lines", len(lines))
+	}
+	var first, sent, media exportedMessage
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("invalid json line: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &sent); err != nil {
+		t.Fatalf("invalid json line: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &media); err != nil {
+		t.Fatalf("invalid json line: %v", err)
+	}
+	if first.ID != "M0000" || first.Timestamp != "2026-05-01T09:00:00Z" || media.MediaType != "image" || media.MediaPath != "" {
+		t.Fatalf("expected the oldest message first with metadata, got %+v and %+v", first, media)
+	}
+	if first.Receipt != nil || sent.Receipt == nil || sent.Receipt.Summary != "read by 1 of 1" || len(sent.Receipts) != 1 || sent.Receipts[0].ReadAt != "2026-05-01T09:02:00Z" {
+		t.Fatalf("expected the receipt of the sent message only, got %+v and %+v", first.Receipt, sent)
+	}
+}
+
+func TestExportChat_CSVWithTimeRange(t *testing.T) {
+	service, ctx := newExportTestService(t)
+	from, to := "2026-05-01T09:01:00Z", "2026-05-01T09:03:00Z"
+	_, data := runExport(t, service, ctx, domainChat.ExportChatRequest{ChatJID: exportChatJID, Format: "csv", From: &from, To: &to})
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv: %v", err)
+	}
+	if len(records) != 4 || records[0][0] != "id" || records[1][0] != "M0001" || records[3][0] != "M0003" {
+		t.Fatalf("expected a header and three messages, got %v", records)
+	}
+	if records[1][4] != "<b>quote, \"comma\"</b>\nsecond line" || records[1][3] != "true" || records[2][5] != "image" || records[1][11] != "read by 1 of 1" {
+		t.Fatalf("expected flattened columns, got %v", records[1:3])
+	}
+}
+
+func TestExportChat_HTMLEscapesContent(t *testing.T) {
+	service, ctx := newExportTestService(t)
+	to := "2026-05-01T09:02:00Z"
+	response, data := runExport(t, service, ctx, domainChat.ExportChatRequest{ChatJID: exportChatJID, Format: "html", To: &to})
+
+	html := string(data)
+	if response.ContentType != "text/html; charset=utf-8" || !strings.HasPrefix(html, "<!DOCTYPE html>") || !strings.HasSuffix(strings.TrimSpace(html), "</html>") {
+		t.Fatalf("expected a complete html document, got %q", html)
+	}
+	if strings.Contains(html, "<b>quote") || !strings.Contains(html, "&lt;b&gt;quote") || !strings.Contains(html, "Ana &lt;Support&gt;") {
+		t.Fatal("expected message content and chat name to be escaped")
+	}
+	if !strings.Contains(html, "[image: image_1.jpg]") || strings.Count(html, `class="msg`) != 3 {
+		t.Fatalf("expected three messages with the media reference, got %q", html)
+	}
+}
+
+func TestExportChat_ZipBundlesMedia(t *testing.T) {
+	service, ctx := newExportTestService(t)
+	original := exportMediaDownload
+	t.Cleanup(func() { exportMediaDownload = original })
+	exportMediaDownload = func(_ context.Context, _ *whatsmeow.Client, message *domainChatStorage.Message) ([]byte, error) {
+		if message.ID != "M0002" {
+			return nil, errors.New("unexpected media")
+		}
+		return []byte("jpeg-bytes"), nil
+	}
+
+	to := "2026-05-01T09:05:00Z"
+	response, data := runExport(t, service, ctx, domainChat.ExportChatRequest{ChatJID: exportChatJID, Format: "html", Media: "zip", To: &to})
+	if response.Filename != "chat-6289685028129.zip" {
+		t.Fatalf("unexpected file name %s", response.Filename)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s failed: %v", file.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[file.Name] = string(content)
+	}
+	if files["media/M0002.jpg"] != "jpeg-bytes" {
+		t.Fatalf("expected the image in the zip, got files %v", len(files))
+	}
+	if !strings.Contains(files["transcript.html"], `<a href="media/M0002.jpg">image: image_1.jpg</a>`) {
+		t.Fatalf("expected the transcript to link the bundled media, got %q", files["transcript.html"])
+	}
+}
+
+func TestExportChat_RejectsUnknownFormat(t *testing.T) {
+	service, ctx := newExportTestService(t)
+	if _, err := service.ExportChat(ctx, domainChat.ExportChatRequest{ChatJID: exportChatJID, Format: "pdf"}); err == nil {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+	if _, err := service.ExportChat(ctx, domainChat.ExportChatRequest{ChatJID: "404@s.whatsapp.net"}); err == nil {
+		t.Fatal("expected an unknown chat to be rejected")
+	}
+}
+
+func TestMessageReceiptInfo_CountsGroupMembers(t *testing.T) {
+	orig := fetchGroupInfo
+	t.Cleanup(func() { fetchGroupInfo = orig })
+	fetchGroupInfo = func(_ context.Context, _ *whatsmeow.Client, jid types.JID) (*types.GroupInfo, error) {
+		if jid.User != "120363000000000001" {
+			return nil, errors.New("not a member")
+		}
+		return &types.GroupInfo{Participants: make([]types.GroupParticipant, 5)}, nil
+	}
+	client := &whatsmeow.Client{}
+	readAt := time.Date(2026, 5, 1, 9, 2, 0, 0, time.UTC)
+	receipts := []*domainChatStorage.MessageReceipt{{Participant: "1@s.whatsapp.net", ReadAt: &readAt, DeliveredAt: &readAt}}
+
+	recipients := receiptRecipients(context.Background(), client, "120363000000000001@g.us")
+	if info := messageReceiptInfo(receipts, recipients); info == nil || info.Summary != "read by 1 of 4" || info.Recipients != 4 {
+		t.Fatalf("expected the group members besides us to be counted, got %+v", info)
+	}
+	if got := receiptRecipients(context.Background(), client, "120363000000000002@g.us"); got != 0 {
+		t.Errorf("expected an unknown group to count its receipts, got %d", got)
+	}
+	if got := receiptRecipients(context.Background(), client, exportChatJID); got != 0 {
+		t.Errorf("expected a direct chat not to be looked up, got %d", got)
+	}
+}
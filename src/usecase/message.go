@@ -280,85 +280,90 @@ func (service serviceMessage) DownloadMedia(ctx context.Context, request domainM
 		return response, fmt.Errorf("failed to create directory: %v", err)
 	}
 
-	// Create a downloadable message interface based on media type
-	var downloadableMsg interface{}
+	downloadableMsg, err := storedMediaDownloadable(message)
+	if err != nil {
+		return response, err
+	}
+
+	// Download the media using existing utils.ExtractMedia function
+	extractedMedia, err := utils.ExtractMedia(ctx, client, dateDir, downloadableMsg)
+	if err != nil {
+		return response, fmt.Errorf("failed to download media: %v", err)
+	}
+
+	// Get file size
+	fileInfo, err := os.Stat(extractedMedia.MediaPath)
+	if err != nil {
+		logrus.Warnf("Could not get file size for %s: %v", extractedMedia.MediaPath, err)
+	}
+
+	// Build response
+	response.MessageID = request.MessageID
+	response.Status = fmt.Sprintf("Media downloaded successfully to %s", extractedMedia.MediaPath)
+	response.MediaType = message.MediaType
+	response.Filename = filepath.Base(extractedMedia.MediaPath)
+	response.FilePath = extractedMedia.MediaPath
+	if fileInfo != nil {
+		response.FileSize = fileInfo.Size()
+	}
+
+	logrus.Info(map[string]any{
+		"message_id": request.MessageID,
+		"phone":      request.Phone,
+		"chat":       dataWaRecipient.String(),
+		"media_type": response.MediaType,
+		"file_path":  response.FilePath,
+		"file_size":  response.FileSize,
+	})
+
+	return response, nil
+}
 
+// storedMediaDownloadable rebuilds the downloadable media of a stored message from its media key and hashes.
+func storedMediaDownloadable(message *domainChatStorage.Message) (whatsmeow.DownloadableMessage, error) {
 	switch message.MediaType {
 	case "image":
-		downloadableMsg = &waE2E.ImageMessage{
+		return &waE2E.ImageMessage{
 			URL:           proto.String(message.URL),
 			MediaKey:      message.MediaKey,
 			FileSHA256:    message.FileSHA256,
 			FileEncSHA256: message.FileEncSHA256,
 			FileLength:    proto.Uint64(message.FileLength),
-		}
+		}, nil
 	case "video":
-		downloadableMsg = &waE2E.VideoMessage{
+		return &waE2E.VideoMessage{
 			URL:           proto.String(message.URL),
 			MediaKey:      message.MediaKey,
 			FileSHA256:    message.FileSHA256,
 			FileEncSHA256: message.FileEncSHA256,
 			FileLength:    proto.Uint64(message.FileLength),
-		}
+		}, nil
 	case "audio":
-		downloadableMsg = &waE2E.AudioMessage{
+		return &waE2E.AudioMessage{
 			URL:           proto.String(message.URL),
 			MediaKey:      message.MediaKey,
 			FileSHA256:    message.FileSHA256,
 			FileEncSHA256: message.FileEncSHA256,
 			FileLength:    proto.Uint64(message.FileLength),
-		}
+		}, nil
 	case "document":
-		downloadableMsg = &waE2E.DocumentMessage{
+		return &waE2E.DocumentMessage{
 			URL:           proto.String(message.URL),
 			MediaKey:      message.MediaKey,
 			FileSHA256:    message.FileSHA256,
 			FileEncSHA256: message.FileEncSHA256,
 			FileLength:    proto.Uint64(message.FileLength),
 			FileName:      proto.String(message.Filename),
-		}
+		}, nil
 	case "sticker":
-		downloadableMsg = &waE2E.StickerMessage{
+		return &waE2E.StickerMessage{
 			URL:           proto.String(message.URL),
 			MediaKey:      message.MediaKey,
 			FileSHA256:    message.FileSHA256,
 			FileEncSHA256: message.FileEncSHA256,
 			FileLength:    proto.Uint64(message.FileLength),
-		}
+		}, nil
 	default:
-		return response, fmt.Errorf("unsupported media type: %s", message.MediaType)
-	}
-
-	// Download the media using existing utils.ExtractMedia function
-	extractedMedia, err := utils.ExtractMedia(ctx, client, dateDir, downloadableMsg.(whatsmeow.DownloadableMessage))
-	if err != nil {
-		return response, fmt.Errorf("failed to download media: %v", err)
-	}
-
-	// Get file size
-	fileInfo, err := os.Stat(extractedMedia.MediaPath)
-	if err != nil {
-		logrus.Warnf("Could not get file size for %s: %v", extractedMedia.MediaPath, err)
-	}
-
-	// Build response
-	response.MessageID = request.MessageID
-	response.Status = fmt.Sprintf("Media downloaded successfully to %s", extractedMedia.MediaPath)
-	response.MediaType = message.MediaType
-	response.Filename = filepath.Base(extractedMedia.MediaPath)
-	response.FilePath = extractedMedia.MediaPath
-	if fileInfo != nil {
-		response.FileSize = fileInfo.Size()
+		return nil, fmt.Errorf("unsupported media type: %s", message.MediaType)
 	}
-
-	logrus.Info(map[string]any{
-		"message_id": request.MessageID,
-		"phone":      request.Phone,
-		"chat":       dataWaRecipient.String(),
-		"media_type": response.MediaType,
-		"file_path":  response.FilePath,
-		"file_size":  response.FileSize,
-	})
-
-	return response, nil
 }
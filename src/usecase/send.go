@@ -19,6 +19,7 @@ import (
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/domains/app"
 	domainChatStorage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chatstorage"
+	domainMessage "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/message"
 	domainSend "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/send"
 	"github.com/aldinokemal/go-whatsapp-web-multidevice/infrastructure/whatsapp"
 	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
@@ -38,6 +39,12 @@ import (
 // webpCanvasSizeRegex is compiled once at package level for efficiency
 var webpCanvasSizeRegex = regexp.MustCompile(`Canvas size:\s*(\d+)\s*x\s*(\d+)`)
 
+// revokeWindow is how long after sending WhatsApp lets a message be deleted for everyone.
+const revokeWindow = 60 * time.Hour
+
+// voiceNoteBitrate is the Opus bitrate of converted voice notes; WhatsApp records its own at 16-48kbps.
+const voiceNoteBitrate = "32k"
+
 type serviceSend struct {
 	appService      app.IAppUsecase
 	chatStorageRepo domainChatStorage.IChatStorageRepository
@@ -54,6 +61,15 @@ func NewSendService(appService app.IAppUsecase, chatStorageRepo domainChatStorag
 func (service serviceSend) wrapSendMessage(ctx context.Context, client *whatsmeow.Client, recipient types.JID, msg *waE2E.Message, content string) (whatsmeow.SendResponse, error) {
 	ts, err := client.SendMessage(ctx, recipient, msg)
 	if err != nil {
+		if category, code, ok := whatsapp.ClassifySendError(err); ok && ts.ID != "" {
+			// The request context ends with the response; keep only the device for the report.
+			reportCtx := context.Background()
+			if inst, found := whatsapp.DeviceFromContext(ctx); found {
+				reportCtx = whatsapp.ContextWithDevice(reportCtx, inst)
+			}
+			failure := whatsapp.MessageFailure{MessageID: ts.ID, ChatJID: recipient.String(), Category: category, Code: code}
+			go whatsapp.ReportMessageFailure(reportCtx, client, failure)
+		}
 		return whatsmeow.SendResponse{}, err
 	}
 
@@ -77,6 +93,7 @@ func (service serviceSend) wrapSendMessage(ctx context.Context, client *whatsmeo
 			}
 		}
 	}()
+	whatsapp.ForwardSentMessageToChatwoot(ctx, client, recipient, ts, msg)
 
 	return ts, nil
 }
@@ -191,6 +208,64 @@ func (service serviceSend) SendText(ctx context.Context, request domainSend.Mess
 	return response, nil
 }
 
+// EditMessage replaces the text of a message sent by this device, as UpdateMessage of the message
+// usecase does. WhatsApp only shows edits made within whatsmeow.EditWindow of the original message;
+// older ones fail with ErrEditWindowExpired.
+func (service serviceSend) EditMessage(ctx context.Context, request domainSend.EditMessageRequest) (response domainSend.GenericResponse, err error) {
+	// Without the stored message the edit is attempted; WhatsApp ignores it when it is too late
+	original, err := service.chatStorageRepo.GetMessageByID(request.MessageID)
+	if err != nil {
+		logrus.Warnf("Error retrieving message %s to edit: %v", request.MessageID, err)
+	}
+	if original != nil && time.Since(original.Timestamp) > whatsmeow.EditWindow {
+		return response, pkgError.ErrEditWindowExpired
+	}
+
+	updated, err := NewMessageService(service.chatStorageRepo).UpdateMessage(ctx, domainMessage.UpdateMessageRequest{
+		MessageID: request.MessageID,
+		Message:   request.Message,
+		Phone:     request.Phone,
+	})
+	if err != nil {
+		return response, err
+	}
+
+	if original != nil {
+		original.Content = request.Message
+		if err := service.chatStorageRepo.StoreMessage(original); err != nil {
+			logrus.Warnf("Failed to store edited message %s: %v", request.MessageID, err)
+		}
+	}
+
+	response.MessageID = updated.MessageID
+	response.Status = updated.Status
+	return response, nil
+}
+
+// RevokeMessage deletes a message sent by this device for everyone, as RevokeMessage of the message
+// usecase does. Messages older than revokeWindow fail with ErrRevokeWindowExpired.
+func (service serviceSend) RevokeMessage(ctx context.Context, request domainSend.RevokeMessageRequest) (response domainSend.GenericResponse, err error) {
+	original, err := service.chatStorageRepo.GetMessageByID(request.MessageID)
+	if err != nil {
+		logrus.Warnf("Error retrieving message %s to revoke: %v", request.MessageID, err)
+	}
+	if original != nil && time.Since(original.Timestamp) > revokeWindow {
+		return response, pkgError.ErrRevokeWindowExpired
+	}
+
+	revoked, err := NewMessageService(service.chatStorageRepo).RevokeMessage(ctx, domainMessage.RevokeRequest{
+		MessageID: request.MessageID,
+		Phone:     request.Phone,
+	})
+	if err != nil {
+		return response, err
+	}
+
+	response.MessageID = revoked.MessageID
+	response.Status = revoked.Status
+	return response, nil
+}
+
 func (service serviceSend) SendImage(ctx context.Context, request domainSend.ImageRequest) (response domainSend.GenericResponse, err error) {
 	err = validations.ValidateSendImage(ctx, request)
 	if err != nil {
@@ -389,6 +464,7 @@ func (service serviceSend) SendFile(ctx context.Context, request domainSend.File
 		fileBytes = helpers.MultipartFormFileHeaderToBytes(request.File)
 		fileName = request.File.Filename
 	}
+	fileName = documentFilename(request.Filename, fileName, fileBytes)
 
 	fileMimeType := resolveDocumentMIME(fileName, fileBytes)
 
@@ -440,6 +516,23 @@ func (service serviceSend) SendFile(ctx context.Context, request domainSend.File
 	return response, nil
 }
 
+// documentFilename is the name a document is sent with: requested when given, keeping the extension
+// of the original name, or of the content when neither has one.
+func documentFilename(requested, original string, fileBytes []byte) string {
+	name := strings.TrimSpace(filepath.Base(strings.ReplaceAll(requested, "\\", "/")))
+	if requested == "" || name == "." || name == "/" {
+		name = original
+	}
+	if filepath.Ext(name) == "" {
+		ext := filepath.Ext(original)
+		if ext == "" {
+			ext = utils.ExtensionByMIME(http.DetectContentType(fileBytes))
+		}
+		name += ext
+	}
+	return name
+}
+
 func resolveDocumentMIME(filename string, fileBytes []byte) string {
 	extension := strings.ToLower(filepath.Ext(filename))
 	if extension != "" {
@@ -494,6 +587,68 @@ func isLikelyOpusOgg(audioBytes []byte) bool {
 	return bytes.Contains(audioBytes[:minInt(len(audioBytes), 4096)], []byte("OpusHead"))
 }
 
+// voiceNoteNeedsTranscode reports whether audio must be converted before it can go out as a voice
+// note. The bytes decide, not the file name or MIME type: a ".ogg" file may hold Vorbis, and
+// Chatwoot labels Opus recordings inconsistently.
+func voiceNoteNeedsTranscode(audioBytes []byte) bool {
+	return !isLikelyOpusOgg(audioBytes)
+}
+
+// transcodeAudioToOpus converts sourcePath to a mono OGG Opus file in dir, the format WhatsApp
+// plays as a voice note, and returns its path. The caller removes the file.
+func transcodeAudioToOpus(ctx context.Context, sourcePath, dir string) (string, error) {
+	// ffmpeg and its Opus encoder are detected once at startup
+	if ffmpeg := utils.DetectFFmpeg(); !ffmpeg.Available {
+		return "", pkgError.FFmpegUnavailableError("ffmpeg not installed (required for PTT voice notes): install ffmpeg or send the audio as MP3 without ptt")
+	} else if !ffmpeg.Opus {
+		return "", pkgError.FFmpegUnavailableError("ffmpeg has no libopus encoder (required for PTT voice notes): install an ffmpeg build with libopus or send the audio as MP3 without ptt")
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "audio_ptt_*.ogg")
+	if err != nil {
+		return "", pkgError.InternalServerError(fmt.Sprintf("failed to create temp file for voice note: %v", err))
+	}
+	targetPath := tmpFile.Name()
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(targetPath)
+		return "", pkgError.InternalServerError(fmt.Sprintf("failed to close temp file: %v", err))
+	}
+
+	convCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	// -b:a 32k with -application voip is plenty for speech; Opus only runs at 48kHz and WhatsApp
+	// voice notes are mono.
+	cmd := exec.CommandContext(convCtx, "ffmpeg",
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", sourcePath,
+		"-vn",
+		"-c:a", "libopus",
+		"-b:a", voiceNoteBitrate,
+		"-vbr", "on",
+		"-application", "voip",
+		"-ar", "48000",
+		"-ac", "1",
+		"-f", "ogg",
+		targetPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		_ = os.Remove(targetPath)
+		if convCtx.Err() == context.DeadlineExceeded {
+			return "", pkgError.InternalServerError("ffmpeg timeout while converting audio to OGG Opus for PTT")
+		}
+		if len(output) > 0 {
+			return "", pkgError.InternalServerError(fmt.Sprintf("failed to convert audio to OGG Opus for PTT: %s", strings.TrimSpace(string(output))))
+		}
+		return "", pkgError.InternalServerError(fmt.Sprintf("failed to convert audio to OGG Opus for PTT: %v", err))
+	}
+
+	return targetPath, nil
+}
+
 func minInt(a, b int) int {
 	if a < b {
 		return a
@@ -1059,102 +1214,42 @@ func (service serviceSend) SendAudio(ctx context.Context, request domainSend.Aud
 		defer os.Remove(tempAudioPath)
 	}
 
-	// For PTT (voice notes), WhatsApp requires "audio/ogg; codecs=opus"
-	// Check if it's an OGG file and add codec info for PTT
-	if request.PTT && strings.HasPrefix(audioMimeType, "audio/ogg") {
-		audioMimeType = "audio/ogg; codecs=opus"
-	}
-
 	// Generate waveform for PTT voice notes
 	var waveformData []byte
 	if request.PTT && tempAudioPath != "" {
 		waveformData = generateWaveform(tempAudioPath)
 	}
 
-	// If PTT is requested, convert audio to OGG Opus format for WhatsApp voice note compatibility
-	// WhatsApp clients require OGG Opus format for voice notes to play correctly
+	// WhatsApp clients only play voice notes that are OGG Opus; anything else, including OGG Vorbis
+	// and the WebM or MP3 recordings Chatwoot agents upload, is converted first.
 	if request.PTT {
-		// Check if already OGG format and actually Opus codec.
-		// Some files are ".ogg" but not Opus (e.g., Vorbis), which WhatsApp can render as file attachment.
-		isAlreadyOgg := strings.HasPrefix(audioMimeType, "audio/ogg") ||
-			strings.HasPrefix(audioMimeType, "application/ogg")
-		isAlreadyOpus := isAlreadyOgg && isLikelyOpusOgg(audioBytes)
-
-		if !isAlreadyOpus {
-			if isAlreadyOgg {
-				logrus.Debug("PTT requested: source is OGG but not Opus, converting to OGG Opus")
-			}
-
-			// Check if ffmpeg is installed
-			_, err := exec.LookPath("ffmpeg")
-			if err != nil {
-				return response, pkgError.InternalServerError("ffmpeg not installed (required for PTT voice notes)")
-			}
-
+		if voiceNoteNeedsTranscode(audioBytes) {
 			// Get absolute base directory for temporary files
 			absBaseDir, err := filepath.Abs(config.PathSendItems)
 			if err != nil {
 				return response, pkgError.InternalServerError(fmt.Sprintf("failed to resolve base directory: %v", err))
 			}
 
-			generateUUID := fiberUtils.UUIDv4()
-
-			// Save input audio to temporary file
-			inputPath := filepath.Join(absBaseDir, fmt.Sprintf("audio_input_%s", generateUUID))
+			inputPath := filepath.Join(absBaseDir, fmt.Sprintf("audio_input_%s", fiberUtils.UUIDv4()))
 			if err := os.WriteFile(inputPath, audioBytes, 0644); err != nil {
 				return response, pkgError.InternalServerError(fmt.Sprintf("failed to save audio for conversion: %v", err))
 			}
 			deletedItems = append(deletedItems, inputPath)
 
-			// Output path for converted OGG Opus file
-			outputPath := filepath.Join(absBaseDir, fmt.Sprintf("audio_ptt_%s.ogg", generateUUID))
-			deletedItems = append(deletedItems, outputPath)
-
-			// Convert to OGG Opus using ffmpeg
-			// Opus codec is required for WhatsApp voice notes
-			// -c:a libopus: Use Opus codec
-			// -b:a 64k: Bitrate (64kbps is good quality for voice)
-			// -vbr on: Variable bitrate for better quality
-			// -application voip: Optimize for voice
-			// -ar 48000: Sample rate (Opus requires 48kHz)
-			// -ac 1: Mono (WhatsApp voice notes are mono)
-			convCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
-			defer cancel()
-
-			cmdConvert := exec.CommandContext(convCtx, "ffmpeg",
-				"-i", inputPath,
-				"-c:a", "libopus",
-				"-b:a", "64k",
-				"-vbr", "on",
-				"-application", "voip",
-				"-ar", "48000",
-				"-ac", "1",
-				"-y", // Overwrite output if exists
-				outputPath,
-			)
-
-			var stderr bytes.Buffer
-			cmdConvert.Stderr = &stderr
-
-			if err := cmdConvert.Run(); err != nil {
-				logrus.Errorf("ffmpeg PTT conversion failed: %v, stderr: %s", err, stderr.String())
-				return response, pkgError.InternalServerError(fmt.Sprintf("failed to convert audio to OGG Opus for PTT: %v", err))
+			outputPath, err := transcodeAudioToOpus(ctx, inputPath, absBaseDir)
+			if err != nil {
+				logrus.Errorf("PTT conversion of %s audio failed: %v", audioMimeType, err)
+				return response, err
 			}
+			deletedItems = append(deletedItems, outputPath)
 
-			// Read converted audio
 			audioBytes, err = os.ReadFile(outputPath)
 			if err != nil {
 				return response, pkgError.InternalServerError(fmt.Sprintf("failed to read converted audio: %v", err))
 			}
-
-			// Update MIME type to OGG Opus
-			audioMimeType = "audio/ogg; codecs=opus"
-
-			logrus.Infof("Converted audio to OGG Opus for PTT: %d bytes", len(audioBytes))
-		} else {
-			// Already OGG Opus, ensure MIME type is correctly set
-			audioMimeType = "audio/ogg; codecs=opus"
+			logrus.Infof("Converted %s audio to OGG Opus for PTT: %d bytes", audioMimeType, len(audioBytes))
 		}
+		audioMimeType = "audio/ogg; codecs=opus"
 	}
 
 	// upload to WhatsApp servers
@@ -1237,6 +1332,18 @@ func (service serviceSend) SendPoll(ctx context.Context, request domainSend.Poll
 		return response, err
 	}
 
+	// Keep the options so votes on this poll can be tallied
+	if err := service.chatStorageRepo.SavePoll(&domainChatStorage.Poll{
+		MessageID:       ts.ID,
+		ChatJID:         dataWaRecipient.String(),
+		Question:        request.Question,
+		Options:         request.Options,
+		SelectableCount: request.MaxAnswer,
+		CreatedAt:       ts.Timestamp,
+	}); err != nil {
+		logrus.Warnf("Failed to store poll %s: %v", ts.ID, err)
+	}
+
 	response.MessageID = ts.ID
 	response.Status = fmt.Sprintf("Send poll success %s (server timestamp: %s)", request.BaseRequest.Phone, ts.Timestamp.String())
 	return response, nil
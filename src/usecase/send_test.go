@@ -40,6 +40,69 @@ func TestResolveDocumentMIME(t *testing.T) {
 	}
 }
 
+func TestDocumentFilename(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		original  string
+		content   string
+		wantName  string
+		wantMIME  string
+	}{
+		{
+			name:      "RequestedNameWins",
+			requested: "invoice-2024.pdf",
+			original:  "download",
+			content:   "%PDF-1.7",
+			wantName:  "invoice-2024.pdf",
+			wantMIME:  "application/pdf",
+		},
+		{
+			name:      "DocxKeepsOriginalExtension",
+			requested: "contract",
+			original:  "blob.docx",
+			content:   "PK\x03\x04",
+			wantName:  "contract.docx",
+			wantMIME:  "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		},
+		{
+			name:     "XlsxWithoutRequestedName",
+			original: "report.xlsx",
+			content:  "PK\x03\x04",
+			wantName: "report.xlsx",
+			wantMIME: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		},
+		{
+			name:      "PdfExtensionFromContent",
+			requested: "statement",
+			original:  "download",
+			content:   "%PDF-1.7",
+			wantName:  "statement.pdf",
+			wantMIME:  "application/pdf",
+		},
+		{
+			name:      "PathsAreStripped",
+			requested: "..\\..\\etc/invoice.pdf",
+			original:  "download.pdf",
+			content:   "%PDF-1.7",
+			wantName:  "invoice.pdf",
+			wantMIME:  "application/pdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := documentFilename(tt.requested, tt.original, []byte(tt.content))
+			if got != tt.wantName {
+				t.Fatalf("documentFilename() = %q, want %q", got, tt.wantName)
+			}
+			if mimeType := resolveDocumentMIME(got, []byte(tt.content)); mimeType != tt.wantMIME {
+				t.Fatalf("resolveDocumentMIME(%q) = %q, want %q", got, mimeType, tt.wantMIME)
+			}
+		})
+	}
+}
+
 func TestIsLikelyOpusOgg(t *testing.T) {
 	tests := []struct {
 		name string
@@ -72,3 +135,46 @@ func TestIsLikelyOpusOgg(t *testing.T) {
 		})
 	}
 }
+
+func TestVoiceNoteNeedsTranscode(t *testing.T) {
+	padding := make([]byte, 80)
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{
+			name: "OggOpus",
+			data: append([]byte("OggS\x00\x02xxxxOpusHead\x01\x01"), padding...),
+			want: false,
+		},
+		{
+			name: "OggVorbis",
+			data: append([]byte("OggS\x00\x02xxxx\x01vorbis"), padding...),
+			want: true,
+		},
+		{
+			name: "WebM",
+			data: append([]byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01webm"), padding...),
+			want: true,
+		},
+		{
+			name: "MP3",
+			data: append([]byte("ID3\x04\x00\x00"), padding...),
+			want: true,
+		},
+		{
+			name: "Empty",
+			data: nil,
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := voiceNoteNeedsTranscode(tt.data); got != tt.want {
+				t.Fatalf("voiceNoteNeedsTranscode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package validations
 import (
 	"context"
 
+	"github.com/aldinokemal/go-whatsapp-web-multidevice/config"
 	domainChat "github.com/aldinokemal/go-whatsapp-web-multidevice/domains/chat"
 	pkgError "github.com/aldinokemal/go-whatsapp-web-multidevice/pkg/error"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
@@ -45,6 +46,43 @@ func ValidateGetChatMessages(ctx context.Context, request *domainChat.GetChatMes
 	return nil
 }
 
+func ValidateSearchMessages(ctx context.Context, request *domainChat.SearchMessagesRequest) error {
+	// Set default limit if not provided
+	if request.Limit == 0 {
+		request.Limit = min(20, config.ChatStorageSearchMaxResults)
+	}
+
+	err := validation.ValidateStructWithContext(ctx, request,
+		validation.Field(&request.Query, validation.Required, validation.Length(1, 200)),
+		validation.Field(&request.Limit, validation.Min(1), validation.Max(config.ChatStorageSearchMaxResults)),
+	)
+
+	if err != nil {
+		return pkgError.ValidationError(err.Error())
+	}
+
+	return nil
+}
+
+func ValidateExportChat(ctx context.Context, request *domainChat.ExportChatRequest) error {
+	// Set default format if not provided
+	if request.Format == "" {
+		request.Format = "json"
+	}
+
+	err := validation.ValidateStructWithContext(ctx, request,
+		validation.Field(&request.ChatJID, validation.Required),
+		validation.Field(&request.Format, validation.In("json", "csv", "html")),
+		validation.Field(&request.Media, validation.In("zip")),
+	)
+
+	if err != nil {
+		return pkgError.ValidationError(err.Error())
+	}
+
+	return nil
+}
+
 func ValidatePinChat(ctx context.Context, request *domainChat.PinChatRequest) error {
 	err := validation.ValidateStructWithContext(ctx, request,
 		validation.Field(&request.ChatJID, validation.Required),
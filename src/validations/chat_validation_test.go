@@ -141,6 +141,47 @@ func TestValidateGetChatMessages(t *testing.T) {
 	}
 }
 
+func TestValidateSearchMessages(t *testing.T) {
+	type args struct {
+		request domainChat.SearchMessagesRequest
+	}
+	tests := []struct {
+		name string
+		args args
+		err  any
+	}{
+		{
+			name: "should success with zero limit (auto set to default)",
+			args: args{request: domainChat.SearchMessagesRequest{
+				Query: "meeting tomorrow",
+			}},
+			err: nil,
+		},
+		{
+			name: "should error with empty query",
+			args: args{request: domainChat.SearchMessagesRequest{
+				Limit: 20,
+			}},
+			err: pkgError.ValidationError("q: cannot be blank."),
+		},
+		{
+			name: "should error with limit above the configured max",
+			args: args{request: domainChat.SearchMessagesRequest{
+				Query: "meeting",
+				Limit: 101,
+			}},
+			err: pkgError.ValidationError("limit: must be no greater than 100."),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSearchMessages(context.Background(), &tt.args.request)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
 func TestValidatePinChat(t *testing.T) {
 	type args struct {
 		request domainChat.PinChatRequest
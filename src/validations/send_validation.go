@@ -198,6 +198,7 @@ func ValidateSendSticker(ctx context.Context, request domainSend.StickerRequest)
 func ValidateSendFile(ctx context.Context, request domainSend.FileRequest) error {
 	err := validation.ValidateStructWithContext(ctx, &request,
 		validation.Field(&request.Phone, validation.Required),
+		validation.Field(&request.Filename, validation.Length(0, 255)),
 	)
 
 	if err != nil {